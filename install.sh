@@ -103,7 +103,7 @@ if [[ -x "$BIN_PATH" ]]; then
 else
     echo -e "${BLUE}→${NC} Installing ${NEW_VERSION:-unknown}..."
 fi
-mkdir -p "$CONFIG_DIR" "$DATA_DIR"
+mkdir -p "$CONFIG_DIR" "$CONFIG_DIR/config.d" "$DATA_DIR"
 install -m 755 "$SOURCE_BIN" "$BIN_PATH"
 echo -e "${GREEN}✓${NC} Installed $BIN_PATH ($("$BIN_PATH" version 2>/dev/null | head -1 | sed 's/^LAN Orangutan //'))"
 
@@ -150,11 +150,8 @@ if command -v ufw &>/dev/null && ufw status | grep -q "active"; then
     [[ "$fw" =~ ^[Yy]$ ]] && { ufw allow "$PORT/tcp"; echo -e "${GREEN}✓${NC} Firewall configured"; }
 fi
 
-# Install service
-cp "$SOURCE_DIR/systemd/lan-orangutan.service" /etc/systemd/system/
-systemctl daemon-reload
-systemctl enable "$SERVICE_NAME"
-systemctl restart "$SERVICE_NAME"
+# Install service (runs as its own user, with the capabilities scanning needs)
+"$BIN_PATH" --config "$CONFIG_DIR/config.ini" install-service
 sleep 2
 
 if systemctl is-active --quiet "$SERVICE_NAME"; then
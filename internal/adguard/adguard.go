@@ -0,0 +1,229 @@
+// Package adguard reads what an AdGuard Home server knows about the devices
+// on the network: the names given to its clients, by hand or found by
+// reverse DNS, the leases of its DHCP server, and which clients sent it a
+// query lately. Scans use it to name devices and to find those that ignored
+// the sweep but are still browsing.
+package adguard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// requestTimeout bounds each request, so that an AdGuard Home that has gone
+// away does not hold up the scan waiting on it.
+const requestTimeout = 10 * time.Second
+
+// activeWindow is how recently a client must have sent a query to count as
+// present.
+const activeWindow = 10 * time.Minute
+
+// queryLogLimit is how many of the latest queries are read to see who is
+// present. A busy network sends more than this in ten minutes, but then the
+// clients that are present are among them.
+const queryLogLimit = 1000
+
+// ClientTagPrefix starts the tag given to a device for each tag of its
+// AdGuard Home client, as in "adguard:device_phone".
+const ClientTagPrefix = "adguard:"
+
+// Options say which AdGuard Home to ask.
+type Options struct {
+	// URL is the address of the web interface, such as http://192.168.1.2:3000.
+	URL string
+	// Username and Password log in to it. Empty means it has no login.
+	Username string
+	Password string
+	// ClientTags tags each device with the tags of its client, such as
+	// device_phone or user_child.
+	ClientTags bool
+}
+
+// Client asks an AdGuard Home server about the devices it knows.
+type Client struct {
+	opts Options
+	http *http.Client
+	// now is the clock, replaced in tests.
+	now func() time.Time
+}
+
+// New returns a client asking the AdGuard Home opts describe.
+func New(opts Options) *Client {
+	return &Client{opts: opts, http: &http.Client{Timeout: requestTimeout}, now: time.Now}
+}
+
+// ValidURL checks that u is an http or https URL AdGuard Home can be
+// reached at.
+func ValidURL(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("%q is not an http or https URL", u)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("%q has no host", u)
+	}
+	return nil
+}
+
+// Name implements scanner.Source.
+func (c *Client) Name() string { return "adguard" }
+
+// The parts of the API's replies that are used.
+type (
+	dhcpReply struct {
+		Leases       []lease `json:"leases"`
+		StaticLeases []lease `json:"static_leases"`
+	}
+	lease struct {
+		MAC      string `json:"mac"`
+		IP       string `json:"ip"`
+		Hostname string `json:"hostname"`
+	}
+	clientsReply struct {
+		Clients []struct {
+			Name string   `json:"name"`
+			IDs  []string `json:"ids"`
+			Tags []string `json:"tags"`
+		} `json:"clients"`
+		AutoClients []struct {
+			IP   string `json:"ip"`
+			Name string `json:"name"`
+		} `json:"auto_clients"`
+	}
+	queryLogReply struct {
+		Data []struct {
+			Client string    `json:"client"`
+			Time   time.Time `json:"time"`
+		} `json:"data"`
+	}
+)
+
+// Devices returns the devices in network that AdGuard Home knows, named
+// after its clients and DHCP leases. Those that sent a query in the last
+// few minutes have LastSeen set.
+func (c *Client) Devices(ctx context.Context, network *net.IPNet) ([]types.Device, error) {
+	var dhcp dhcpReply
+	if err := c.get(ctx, "/control/dhcp/status", &dhcp); err != nil {
+		return nil, err
+	}
+	var clients clientsReply
+	if err := c.get(ctx, "/control/clients", &clients); err != nil {
+		return nil, err
+	}
+	var log queryLogReply
+	if err := c.get(ctx, fmt.Sprintf("/control/querylog?limit=%d", queryLogLimit), &log); err != nil {
+		return nil, err
+	}
+	return merge(network, dhcp, clients, log, c.opts.ClientTags, c.now()), nil
+}
+
+// merge returns the devices in network from AdGuard Home's leases, clients
+// and query log. A name given to a client by hand wins over a lease's
+// hostname, which wins over a name AdGuard Home found itself.
+func merge(network *net.IPNet, dhcp dhcpReply, clients clientsReply, log queryLogReply, clientTags bool, now time.Time) []types.Device {
+	var devices []types.Device
+	byIP := make(map[string]int)
+	at := func(ip string) *types.Device {
+		if i, ok := byIP[ip]; ok {
+			return &devices[i]
+		}
+		parsed := net.ParseIP(ip)
+		if parsed == nil || !network.Contains(parsed) {
+			return nil
+		}
+		byIP[ip] = len(devices)
+		devices = append(devices, types.Device{IP: ip})
+		return &devices[len(devices)-1]
+	}
+
+	for _, l := range append(dhcp.StaticLeases, dhcp.Leases...) {
+		if d := at(l.IP); d != nil && d.Hostname == "" {
+			d.Hostname = l.Hostname
+			d.MAC = strings.ToUpper(l.MAC)
+		}
+	}
+	for _, a := range clients.AutoClients {
+		if d := at(a.IP); d != nil && d.Hostname == "" {
+			d.Hostname = a.Name
+		}
+	}
+	for _, q := range log.Data {
+		d := at(q.Client)
+		if d != nil && now.Sub(q.Time) < activeWindow && q.Time.After(d.LastSeen) {
+			d.LastSeen = q.Time
+		}
+	}
+
+	// Persistent clients name devices by address or MAC address; a CIDR
+	// or a ClientID names no single device.
+	byMAC := make(map[string]int)
+	for i, d := range devices {
+		if d.MAC != "" {
+			byMAC[d.MAC] = i
+		}
+	}
+	for _, cl := range clients.Clients {
+		for _, id := range cl.IDs {
+			var d *types.Device
+			if net.ParseIP(id) != nil {
+				d = at(id)
+			} else if _, err := net.ParseMAC(id); err == nil {
+				if i, ok := byMAC[strings.ToUpper(id)]; ok {
+					d = &devices[i]
+				}
+			}
+			if d == nil {
+				continue
+			}
+			if cl.Name != "" {
+				d.Hostname = cl.Name
+			}
+			if clientTags {
+				for _, tag := range cl.Tags {
+					d.AddTag(ClientTagPrefix + tag)
+				}
+			}
+		}
+	}
+	return devices
+}
+
+// get fetches path from the API and decodes the JSON answer into reply.
+func (c *Client) get(ctx context.Context, path string, reply any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(c.opts.URL, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.opts.Username != "" || c.opts.Password != "" {
+		req.SetBasicAuth(c.opts.Username, c.opts.Password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return errors.New("AdGuard Home refused the username and password")
+	case resp.StatusCode/100 != 2:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("AdGuard Home answered %s to %s: %s", resp.Status, path, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(reply); err != nil {
+		return fmt.Errorf("reading AdGuard Home's answer to %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package adguard
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// fakeAdGuard answers like AdGuard Home for the login admin:s3cret.
+func fakeAdGuard(t *testing.T, now time.Time) *httptest.Server {
+	t.Helper()
+	replies := map[string]any{
+		"/control/dhcp/status": map[string]any{
+			"leases": []map[string]any{
+				{"mac": "aa:bb:cc:00:00:05", "ip": "192.168.1.5", "hostname": "android-1234"},
+				{"mac": "aa:bb:cc:00:00:09", "ip": "10.0.0.9", "hostname": "elsewhere"},
+			},
+			"static_leases": []map[string]any{
+				{"mac": "aa:bb:cc:00:00:06", "ip": "192.168.1.6", "hostname": "printer"},
+			},
+		},
+		"/control/clients": map[string]any{
+			"clients": []map[string]any{
+				{"name": "Anna's phone", "ids": []string{"AA:BB:CC:00:00:05"}, "tags": []string{"device_phone", "user_child"}},
+				{"name": "Office", "ids": []string{"192.168.2.0/24", "laptop-id"}},
+			},
+			"auto_clients": []map[string]any{
+				{"ip": "192.168.1.6", "name": "printer.lan", "source": "rDNS"},
+				{"ip": "192.168.1.7", "name": "tv.lan", "source": "rDNS"},
+			},
+		},
+		"/control/querylog": map[string]any{"data": []map[string]any{
+			{"client": "192.168.1.5", "time": now.Add(-2 * time.Minute)},
+			{"client": "192.168.1.5", "time": now.Add(-5 * time.Minute)},
+			{"client": "192.168.1.7", "time": now.Add(-time.Hour)},
+			{"client": "192.168.1.8", "time": now.Add(-time.Minute)},
+		}},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		reply, ok := replies[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(reply)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDevices(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	srv := fakeAdGuard(t, now)
+	c := New(Options{URL: srv.URL + "/", Username: "admin", Password: "s3cret", ClientTags: true})
+	c.now = func() time.Time { return now }
+	_, network, _ := net.ParseCIDR("192.168.1.0/24")
+
+	devices, err := c.Devices(context.Background(), network)
+	if err != nil {
+		t.Fatalf("Devices: %v", err)
+	}
+	got := make(map[string]string)
+	for _, d := range devices {
+		got[d.IP] = d.Hostname
+	}
+	if len(devices) != 4 || got["192.168.1.5"] != "Anna's phone" || got["192.168.1.6"] != "printer" ||
+		got["192.168.1.7"] != "tv.lan" || got["192.168.1.8"] != "" {
+		t.Fatalf("devices = %+v", devices)
+	}
+	phone := devices[slices.IndexFunc(devices, func(d types.Device) bool { return d.IP == "192.168.1.5" })]
+	if phone.MAC != "AA:BB:CC:00:00:05" || !phone.LastSeen.Equal(now.Add(-2*time.Minute)) {
+		t.Errorf("phone = %+v", phone)
+	}
+	if !slices.Equal(phone.Tags, []string{"adguard:device_phone", "adguard:user_child"}) {
+		t.Errorf("tags = %v", phone.Tags)
+	}
+	for _, d := range devices {
+		if active := !d.LastSeen.IsZero(); active != (d.IP == "192.168.1.5" || d.IP == "192.168.1.8") {
+			t.Errorf("%s active = %v", d.IP, active)
+		}
+	}
+}
+
+func TestDevicesWrongPassword(t *testing.T) {
+	srv := fakeAdGuard(t, time.Now())
+	_, network, _ := net.ParseCIDR("192.168.1.0/24")
+	_, err := New(Options{URL: srv.URL, Username: "admin", Password: "guess"}).Devices(context.Background(), network)
+	if err == nil || !strings.Contains(err.Error(), "refused") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestValidURL(t *testing.T) {
+	for u, ok := range map[string]bool{
+		"http://192.168.1.2:3000": true,
+		"https://adguard.lan/":    true,
+		"192.168.1.2:3000":        false,
+		"ftp://adguard.lan":       false,
+		"http://":                 false,
+	} {
+		if err := ValidURL(u); (err == nil) != ok {
+			t.Errorf("ValidURL(%q) = %v", u, err)
+		}
+	}
+}
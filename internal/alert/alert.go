@@ -0,0 +1,489 @@
+// Package alert tells people about what happens on the network as it
+// happens: a device joining, a watched device going offline, a scan
+// failing. Rules say which events, and which devices, are worth an alert and
+// which notifiers send it, so that new devices can go to one channel and the
+// NAS dropping off to another.
+//
+// Alerts are made from the event log, which the storage writes as it merges
+// each scan, so they say the same as the notification panel does. Scans
+// finishing are the exception: they are noticed from the time each network
+// was last scanned, as the event log would fill with them.
+package alert
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// pollInterval is how often Run looks for new events.
+const pollInterval = 5 * time.Second
+
+// sendTimeout bounds sending one alert, so that a service that has gone away
+// does not hold up the rest.
+const sendTimeout = 15 * time.Second
+
+// EventScanCompleted is the type of the events Run makes up when a network
+// has been scanned. They are not in the event log.
+const EventScanCompleted = "scan_completed"
+
+// eventNames are the names rules give event types, and the titles of their
+// alerts.
+var eventNames = []struct {
+	name, kind, title string
+}{
+	{"new", types.EventDeviceNew, "New device"},
+	{"offline", types.EventDeviceOffline, "Device offline"},
+	{"scan_failed", types.EventScanFailed, "Scan failed"},
+	{"scan_completed", EventScanCompleted, "Scan finished"},
+	{"anomaly", types.EventDeviceAnomaly, "Unusual device change"},
+	{"arrival", types.EventPersonArrived, "Arrived home"},
+	{"departure", types.EventPersonLeft, "Left home"},
+	{"cert_expiring", types.EventCertExpiring, "Certificate expiring"},
+	{"intrusion", types.EventIntrusion, "Device not allowed"},
+}
+
+// EventNames lists the names rules give event types, as errors say them:
+// "new, offline, ... or anomaly".
+func EventNames() string {
+	names := make([]string, len(eventNames))
+	for i, e := range eventNames {
+		names[i] = e.name
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+// EventType returns the event type a rule's name for it stands for, one of
+// EventNames, or the type itself, such as device_new.
+func EventType(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, e := range eventNames {
+		if name == e.name || name == e.kind {
+			return e.kind, true
+		}
+	}
+	return "", false
+}
+
+// title returns the title of an alert about an event of type kind.
+func title(kind string) string {
+	for _, e := range eventNames {
+		if kind == e.kind {
+			return e.title
+		}
+	}
+	return "LAN Orangutan"
+}
+
+// Rule says which events to alert about and who to tell.
+type Rule struct {
+	Name string
+	// Events are the event types the rule is for. Empty means new devices
+	// and devices going offline.
+	Events []string
+	// Devices, when set, limits the rule to these devices, each given by its
+	// address, MAC, label or hostname. Groups limits it to devices in these
+	// groups. A device matching either is alerted about.
+	Devices []string
+	Groups  []string
+	// Notify are the names of the notifiers to send the alert with. Empty
+	// means all of them.
+	Notify []string
+	// Template is the text of the alert in Go's text/template syntax, with
+	// the fields of Data. Empty means the event's own message.
+	Template string
+	// Escalate, when set, is the name of the escalation policy whose steps
+	// send the alert instead of Notify, until someone acknowledges it.
+	Escalate string
+}
+
+// Data is what a rule's template is given.
+type Data struct {
+	// Event is the rule's name for the event, one of EventNames.
+	Event   string
+	Time    time.Time
+	IP      string
+	Name    string
+	Network string
+	Detail  string
+	Message string
+	// The device's details, when the inventory still has it.
+	MAC      string
+	Vendor   string
+	Hostname string
+	Label    string
+	Group    string
+}
+
+// ParseTemplate checks that text is a template a rule can use.
+func ParseTemplate(text string) error {
+	_, err := template.New("alert").Option("missingkey=error").Parse(text)
+	return err
+}
+
+// Notification is an alert as a notifier sends it.
+type Notification struct {
+	// Title is short, such as "New device", for services that show one.
+	Title string
+	Text  string
+	Event types.Event
+	// Device is the device the event is about, or nil when it is not about
+	// one or the inventory no longer has it.
+	Device *types.Device
+}
+
+// Notifier sends alerts to a service.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// escaper is implemented by notifiers whose service reads markup in what it
+// is sent, so that a hostname a device picked for itself cannot ping a
+// channel or forge a link.
+type escaper interface {
+	Escape(s string) string
+}
+
+// Source is where events and the devices they are about come from; the
+// storage is one.
+type Source interface {
+	GetEvents(limit int, unreadOnly bool) []types.Event
+	GetDevice(ip string) *types.Device
+}
+
+// rule is a Rule ready to match events against.
+type rule struct {
+	Rule
+	events   map[string]bool
+	template *template.Template
+}
+
+// Engine matches events against rules and sends the alerts.
+type Engine struct {
+	rules     []rule
+	notifiers map[string]Notifier
+	// windows are the maintenance windows; see SetWindows.
+	windows []Window
+	// policies are the escalation policies, by name; see SetPolicies.
+	policies map[string]Policy
+	// next is what Run alerts by from its next poll on; see Replace.
+	next atomic.Pointer[Engine]
+}
+
+// New returns an engine alerting by rules with notifiers, keyed by name.
+// With no rules, every notifier is sent new devices and devices going
+// offline.
+func New(rules []Rule, notifiers map[string]Notifier) (*Engine, error) {
+	if len(rules) == 0 {
+		rules = []Rule{{Name: "default"}}
+	}
+	e := &Engine{notifiers: notifiers}
+	for _, r := range rules {
+		compiled := rule{Rule: r, events: make(map[string]bool)}
+		events := r.Events
+		if len(events) == 0 {
+			events = []string{types.EventDeviceNew, types.EventDeviceOffline}
+		}
+		for _, name := range events {
+			kind, ok := EventType(name)
+			if !ok {
+				return nil, fmt.Errorf("alert %q: %q is not %s", r.Name, name, EventNames())
+			}
+			compiled.events[kind] = true
+		}
+		for _, name := range r.Notify {
+			if _, ok := notifiers[name]; !ok {
+				return nil, fmt.Errorf("alert %q: there is no notifier %q", r.Name, name)
+			}
+		}
+		if r.Template != "" {
+			t, err := template.New(r.Name).Option("missingkey=error").Parse(r.Template)
+			if err != nil {
+				return nil, fmt.Errorf("alert %q: %w", r.Name, err)
+			}
+			compiled.template = t
+		}
+		e.rules = append(e.rules, compiled)
+	}
+	// Rules are tried by name, so which one words an alert does not depend
+	// on the order of a map.
+	sort.SliceStable(e.rules, func(i, j int) bool { return e.rules[i].Name < e.rules[j].Name })
+	return e, nil
+}
+
+// Delivery is an alert to send with one notifier.
+type Delivery struct {
+	Notifier     string
+	Notification Notification
+}
+
+// Route returns the alerts to send about ev, which is about the device d, or
+// nil when no rule is for it. d is nil when the event is not about a device,
+// or the inventory no longer has it. Each notifier is sent an event once,
+// worded by the first rule, by name, that routes it there. Rules that
+// escalate are left out: Run sends their alerts a step at a time.
+func (e *Engine) Route(ev types.Event, d *types.Device) []Delivery {
+	var deliveries []Delivery
+	sent := make(map[string]bool)
+	for _, r := range e.rules {
+		if r.Escalate != "" || !r.events[ev.Type] || !r.matches(ev, d) {
+			continue
+		}
+		names := r.Notify
+		if len(names) == 0 {
+			names = e.names()
+		}
+		for _, name := range names {
+			if sent[name] {
+				continue
+			}
+			n, err := r.render(ev, d, e.notifiers[name])
+			if err != nil {
+				slog.Warn("cannot word alert", "alert", r.Name, "error", err)
+				continue
+			}
+			sent[name] = true
+			deliveries = append(deliveries, Delivery{Notifier: name, Notification: n})
+		}
+	}
+	return deliveries
+}
+
+// names returns the names of the notifiers, sorted.
+func (e *Engine) names() []string {
+	names := make([]string, 0, len(e.notifiers))
+	for name := range e.notifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// matches reports whether ev, about the device d, is one the rule is for.
+func (r rule) matches(ev types.Event, d *types.Device) bool {
+	if len(r.Devices) == 0 && len(r.Groups) == 0 {
+		return true
+	}
+	for _, want := range r.Devices {
+		if want == "" {
+			continue
+		}
+		if strings.EqualFold(want, ev.IP) || strings.EqualFold(want, ev.Name) {
+			return true
+		}
+		if d != nil && (strings.EqualFold(want, d.MAC) || strings.EqualFold(want, d.Label) || strings.EqualFold(want, d.Hostname)) {
+			return true
+		}
+	}
+	if d != nil && d.Group != "" {
+		for _, want := range r.Groups {
+			if strings.EqualFold(want, d.Group) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// render words the alert about ev for the notifier n.
+func (r rule) render(ev types.Event, d *types.Device, n Notifier) (Notification, error) {
+	result := Notification{Title: title(ev.Type), Text: ev.Message, Event: ev, Device: d}
+	if r.template == nil {
+		return result, nil
+	}
+	data := newData(ev, d)
+	if esc, ok := n.(escaper); ok {
+		data = data.escaped(esc.Escape)
+	}
+	var buf bytes.Buffer
+	if err := r.template.Execute(&buf, data); err != nil {
+		return result, err
+	}
+	result.Text = strings.TrimSpace(buf.String())
+	return result, nil
+}
+
+func newData(ev types.Event, d *types.Device) Data {
+	data := Data{
+		Event:   ev.Type,
+		Time:    ev.Time,
+		IP:      ev.IP,
+		Name:    ev.Name,
+		Network: ev.Network,
+		Detail:  ev.Detail,
+		Message: ev.Message,
+	}
+	for _, e := range eventNames {
+		if ev.Type == e.kind {
+			data.Event = e.name
+		}
+	}
+	if d != nil {
+		data.MAC = d.MAC
+		data.Vendor = d.Vendor
+		data.Hostname = d.Hostname
+		data.Label = d.Label
+		data.Group = d.Group
+	}
+	return data
+}
+
+// escaped returns d with every field that came from the network passed
+// through escape.
+func (d Data) escaped(escape func(string) string) Data {
+	for _, s := range []*string{&d.IP, &d.Name, &d.Network, &d.Detail, &d.Message, &d.MAC, &d.Vendor, &d.Hostname, &d.Label, &d.Group} {
+		*s = escape(*s)
+	}
+	return d
+}
+
+// Send sends the alerts, logging those that fail rather than giving up on
+// the rest.
+func (e *Engine) Send(ctx context.Context, deliveries []Delivery) {
+	for _, d := range deliveries {
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err := e.notifiers[d.Notifier].Notify(sendCtx, d.Notification)
+		cancel()
+		if err != nil {
+			slog.Warn("cannot send alert", "notifier", d.Notifier, "event", d.Notification.Event.Type, "error", err)
+		}
+	}
+}
+
+// Replace has a running engine alert as next does, with its rules,
+// notifiers, maintenance windows and escalation policies, from its next poll
+// on. Escalations under way carry on if next still has their policy. It is
+// how a config reloaded on a running server takes effect; next itself is
+// never run.
+func (e *Engine) Replace(next *Engine) {
+	e.next.Store(next)
+}
+
+// takeNext has e alert as the engine last passed to Replace does, if any.
+func (e *Engine) takeNext() {
+	if next := e.next.Swap(nil); next != nil {
+		e.rules, e.notifiers, e.windows, e.policies = next.rules, next.notifiers, next.windows, next.policies
+	}
+}
+
+// scanSource is implemented by sources that know when each network was
+// last scanned, as the storage does, so that Run can alert about scans
+// finishing.
+type scanSource interface {
+	ScannedNetworks() []string
+	GetLastScan(network string) time.Time
+}
+
+// Run sends alerts about the events recorded in source from now on until
+// ctx is done. Events already in the log when it starts were there before,
+// and are not alerted about again. Events an open maintenance window or a
+// silence in source covers are not alerted about at all. Escalations are
+// kept in source, so that they go on after a restart and can be
+// acknowledged there.
+func (e *Engine) Run(ctx context.Context, source Source) {
+	last := latestEventID(source)
+	scans, _ := source.(scanSource)
+	var scanned map[string]time.Time
+	if scans != nil {
+		scanned = make(map[string]time.Time)
+		for _, cidr := range scans.ScannedNetworks() {
+			scanned[cidr] = scans.GetLastScan(cidr)
+		}
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		e.takeNext()
+		events := eventsAfter(source, last)
+		// Silences are looked up only when there is something to send.
+		var silences []types.Silence
+		looked := false
+		now := time.Now()
+		send := func(ev types.Event, d *types.Device) {
+			if s, ok := source.(silenceSource); ok && !looked {
+				silences, looked = s.GetSilences(false), true
+			}
+			if why := e.silenced(ev, d, silences, now); why != "" {
+				slog.Info("alert silenced", "event", ev.Type, "ip", ev.IP, "network", ev.Network, "by", why)
+				return
+			}
+			e.Send(ctx, e.Route(ev, d))
+			e.startEscalations(source, ev, d, now)
+		}
+		for _, ev := range events {
+			var d *types.Device
+			if ev.IP != "" {
+				d = source.GetDevice(ev.IP)
+			}
+			send(ev, d)
+			last = ev.ID
+		}
+		// After the devices a scan found, so that its finishing comes last.
+		if scans != nil {
+			for _, ev := range scansAfter(scans, scanned) {
+				send(ev, nil)
+			}
+		}
+		e.escalate(ctx, source, now)
+	}
+}
+
+// scansAfter returns an event for each network source has scanned since the
+// time in last, oldest first, and moves last on to it.
+func scansAfter(source scanSource, last map[string]time.Time) []types.Event {
+	var result []types.Event
+	for _, cidr := range source.ScannedNetworks() {
+		t := source.GetLastScan(cidr)
+		previous := last[cidr]
+		last[cidr] = t
+		if !t.After(previous) {
+			continue
+		}
+		result = append(result, types.Event{
+			Type:    EventScanCompleted,
+			Time:    t,
+			Network: cidr,
+			Message: fmt.Sprintf("Scan of %s finished", cidr),
+		})
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Time.Before(result[j].Time) })
+	return result
+}
+
+// latestEventID returns the ID of the newest event in source, or 0 when
+// there are none.
+func latestEventID(source Source) int64 {
+	if events := source.GetEvents(1, false); len(events) > 0 {
+		return events[0].ID
+	}
+	return 0
+}
+
+// eventsAfter returns the events in source newer than the event with ID
+// after, oldest first.
+func eventsAfter(source Source, after int64) []types.Event {
+	var result []types.Event
+	for _, ev := range source.GetEvents(0, false) {
+		if ev.ID <= after {
+			break
+		}
+		result = append(result, ev)
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
@@ -0,0 +1,230 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// recorder is a notifier that keeps what it is sent.
+type recorder struct {
+	sent []Notification
+}
+
+func (r *recorder) Notify(ctx context.Context, n Notification) error {
+	r.sent = append(r.sent, n)
+	return nil
+}
+
+func newEvent(kind, ip, name string) types.Event {
+	return types.Event{Type: kind, IP: ip, Name: name, Network: "192.168.1.0/24", Message: name + " event"}
+}
+
+func TestRoute(t *testing.T) {
+	notifiers := map[string]Notifier{"ops": &recorder{}, "family": &recorder{}}
+	engine, err := New([]Rule{
+		{Name: "everything new", Events: []string{"new"}},
+		{Name: "servers", Events: []string{"offline"}, Devices: []string{"nas", "aa:bb:cc:dd:ee:ff"}, Notify: []string{"ops"},
+			Template: "{{.Name}} ({{.IP}}, {{.Vendor}}) is {{.Event}}"},
+		{Name: "kids", Events: []string{"offline"}, Groups: []string{"Kids"}, Notify: []string{"family"}},
+	}, notifiers)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	nas := &types.Device{IP: "192.168.1.10", Label: "NAS", Vendor: "Synology"}
+	tablet := &types.Device{IP: "192.168.1.20", MAC: "AA:BB:CC:DD:EE:FF", Group: "kids"}
+	tests := []struct {
+		name  string
+		event types.Event
+		d     *types.Device
+		want  map[string]string
+	}{
+		{"new goes everywhere", newEvent(types.EventDeviceNew, "192.168.1.30", "phone"), nil,
+			map[string]string{"family": "phone event", "ops": "phone event"}},
+		{"watched by label", newEvent(types.EventDeviceOffline, "192.168.1.10", "NAS"), nas,
+			map[string]string{"ops": "NAS (192.168.1.10, Synology) is offline"}},
+		{"watched by MAC and group", newEvent(types.EventDeviceOffline, "192.168.1.20", "tablet"), tablet,
+			map[string]string{"family": "tablet event", "ops": "tablet (192.168.1.20, ) is offline"}},
+		{"not watched", newEvent(types.EventDeviceOffline, "192.168.1.30", "phone"), nil, map[string]string{}},
+		{"no rule for the event", newEvent(types.EventScanFailed, "", ""), nil, map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string]string)
+			for _, d := range engine.Route(tt.event, tt.d) {
+				if _, twice := got[d.Notifier]; twice {
+					t.Errorf("%s sent twice", d.Notifier)
+				}
+				got[d.Notifier] = d.Notification.Text
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Route = %q, want %q", got, tt.want)
+			}
+			for name, text := range tt.want {
+				if got[name] != text {
+					t.Errorf("%s got %q, want %q", name, got[name], text)
+				}
+			}
+		})
+	}
+}
+
+func TestNewWithoutRulesSendsJoinsAndDepartures(t *testing.T) {
+	engine, err := New(nil, map[string]Notifier{"ops": &recorder{}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if len(engine.Route(newEvent(types.EventDeviceOffline, "192.168.1.10", "nas"), nil)) != 1 {
+		t.Error("offline device not alerted about")
+	}
+	if len(engine.Route(newEvent(types.EventScanFailed, "", ""), nil)) != 0 {
+		t.Error("failed scan alerted about without a rule asking for it")
+	}
+}
+
+func TestReplace(t *testing.T) {
+	engine, err := New(nil, map[string]Notifier{"ops": &recorder{}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	next, err := New([]Rule{{Name: "failures", Events: []string{"scan_failed"}}}, map[string]Notifier{"pager": &recorder{}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	engine.Replace(next)
+	if got := engine.Route(newEvent(types.EventDeviceOffline, "192.168.1.10", "nas"), nil); len(got) != 1 || got[0].Notifier != "ops" {
+		t.Fatalf("before its next poll Route = %+v; want the old rules still used", got)
+	}
+	engine.takeNext()
+	if got := engine.Route(newEvent(types.EventDeviceOffline, "192.168.1.10", "nas"), nil); len(got) != 0 {
+		t.Errorf("after Replace an offline device was alerted about: %+v", got)
+	}
+	if got := engine.Route(newEvent(types.EventScanFailed, "", ""), nil); len(got) != 1 || got[0].Notifier != "pager" {
+		t.Errorf("after Replace Route = %+v; want the failed scan sent with pager", got)
+	}
+}
+
+func TestNewRejectsBadRules(t *testing.T) {
+	notifiers := map[string]Notifier{"ops": &recorder{}}
+	for _, r := range []Rule{
+		{Name: "bad event", Events: []string{"reboot"}},
+		{Name: "bad notifier", Notify: []string{"pager"}},
+		{Name: "bad template", Template: "{{.Name"},
+	} {
+		if _, err := New([]Rule{r}, notifiers); err == nil {
+			t.Errorf("%s: New succeeded", r.Name)
+		}
+	}
+}
+
+func TestSlackEscapesWhatDevicesName(t *testing.T) {
+	engine, err := New([]Rule{{Name: "new", Template: "<!here> new: {{.Name}}"}}, map[string]Notifier{"slack": &Slack{}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	got := engine.Route(newEvent(types.EventDeviceNew, "192.168.1.5", "<!channel>"), nil)
+	if len(got) != 1 || got[0].Notification.Text != "<!here> new: &lt;!channel&gt;" {
+		t.Errorf("Route = %+v", got)
+	}
+}
+
+// fakeSource is a Source holding events in the order they were recorded.
+type fakeSource struct {
+	events []types.Event
+}
+
+func (f *fakeSource) GetEvents(limit int, unreadOnly bool) []types.Event {
+	var result []types.Event
+	for i := len(f.events) - 1; i >= 0; i-- {
+		result = append(result, f.events[i])
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+	return result
+}
+
+func (f *fakeSource) GetDevice(ip string) *types.Device { return nil }
+
+func TestEventsAfter(t *testing.T) {
+	source := &fakeSource{}
+	for id := int64(1); id <= 4; id++ {
+		source.events = append(source.events, types.Event{ID: id})
+	}
+	if latestEventID(source) != 4 {
+		t.Errorf("latestEventID = %d, want 4", latestEventID(source))
+	}
+	got := eventsAfter(source, 2)
+	if len(got) != 2 || got[0].ID != 3 || got[1].ID != 4 {
+		t.Errorf("eventsAfter = %+v, want 3 then 4", got)
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	var body map[string]any
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = nil
+		json.Unmarshal(data, &body)
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			io.WriteString(w, "invalid_token")
+		}
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	slack, err := NewNotifier(NotifierOptions{Type: "slack", URL: server.URL, Channel: "#network"})
+	if err != nil {
+		t.Fatalf("NewNotifier: %v", err)
+	}
+	if err := slack.Notify(ctx, Notification{Text: "hello"}); err != nil {
+		t.Fatalf("Slack: %v", err)
+	}
+	if body["text"] != "hello" || body["channel"] != "#network" {
+		t.Errorf("Slack sent %v", body)
+	}
+
+	discord, err := NewNotifier(NotifierOptions{Type: "discord", URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewNotifier: %v", err)
+	}
+	if err := discord.Notify(ctx, Notification{Text: strings.Repeat("x", 2500)}); err != nil {
+		t.Fatalf("Discord: %v", err)
+	}
+	if content, _ := body["content"].(string); len([]rune(content)) != discordLimit {
+		t.Errorf("Discord sent %d characters, want %d", len([]rune(content)), discordLimit)
+	}
+	if _, ok := body["allowed_mentions"]; !ok {
+		t.Error("Discord sent no allowed_mentions")
+	}
+
+	status = http.StatusForbidden
+	err = slack.Notify(ctx, Notification{Text: "hello"})
+	if err == nil || !strings.Contains(err.Error(), "invalid_token") {
+		t.Errorf("Slack refused: err = %v", err)
+	}
+}
+
+func TestNewNotifierRejects(t *testing.T) {
+	for _, opts := range []NotifierOptions{
+		{},
+		{Type: "teams", URL: "https://example.com"},
+		{Type: "slack"},
+		{Type: "discord", URL: "discord.com/api/webhooks/1/x"},
+	} {
+		if _, err := NewNotifier(opts); err == nil {
+			t.Errorf("NewNotifier(%+v) succeeded", opts)
+		}
+	}
+}
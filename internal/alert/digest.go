@@ -0,0 +1,208 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/schedule"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+	"github.com/291-Group/LAN-Orangutan/internal/uptime"
+)
+
+//go:embed digest.tmpl
+var defaultDigest string
+
+// digestSchedules are the schedules a digest may be named by, rather than
+// given as a cron expression. Mornings, so the digest is there to read with
+// the first coffee.
+var digestSchedules = map[string]string{
+	"daily":  "0 8 * * *",
+	"weekly": "0 8 * * mon",
+}
+
+// DigestSchedule returns when to send a digest: daily, weekly, or a cron
+// expression as package schedule reads them.
+func DigestSchedule(s string) (*schedule.Schedule, error) {
+	if expr, ok := digestSchedules[strings.ToLower(s)]; ok {
+		s = expr
+	}
+	return schedule.Parse(s)
+}
+
+// DigestTemplate returns the template a digest is written with: the one in
+// the file at path, or the built-in one when path is empty.
+func DigestTemplate(path string) (*template.Template, error) {
+	text := defaultDigest
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		text = string(data)
+	}
+	return template.New("digest").Option("missingkey=error").Parse(text)
+}
+
+// DigestSource is where a digest's devices, events, changes, sightings and
+// scans come from; the storage is one.
+type DigestSource interface {
+	Devices
+	uptime.Sightings
+	GetEvents(limit int, unreadOnly bool) []types.Event
+	GetChanges(ip string) []types.Change
+	ScannedNetworks() []string
+	GetLastScan(network string) time.Time
+}
+
+// Digest sums up what happened on the network over a period, for a digest's
+// template.
+type Digest struct {
+	// Title names the digest, such as "LAN Orangutan daily digest".
+	Title  string
+	Since  time.Time
+	Now    time.Time
+	Total  int
+	Online int
+	// New and Offline are the devices that joined and left, Anomalies the
+	// devices that changed in ways they should not, and ScanFailures the
+	// scans that failed, oldest first.
+	New          []types.Event
+	Offline      []types.Event
+	Anomalies    []types.Event
+	Changes      []DigestChange
+	ScanFailures []types.Event
+	// Outliers are the devices online much less of the period than usual,
+	// and Scans how each network's scans went.
+	Outliers []uptime.Outlier
+	Scans    []DigestScan
+	// Empty is set when nothing joined, left or changed.
+	Empty bool
+}
+
+// DigestScan is how one network's scans went over a digest's period.
+type DigestScan struct {
+	Network  string
+	Last     time.Time
+	Failures int
+	// Stale is set when the network was not scanned at all in the period.
+	Stale bool
+}
+
+// DigestChange is a change to one of a device's details.
+type DigestChange struct {
+	types.Change
+	IP   string
+	Name string
+}
+
+// BuildDigest sums up what source recorded between since and now.
+func BuildDigest(source DigestSource, title string, since, now time.Time) Digest {
+	d := Digest{Title: title, Since: since, Now: now}
+	devices := source.GetDevices()
+	d.Total = len(devices)
+	for ip, dev := range devices {
+		if dev.IsOnline() {
+			d.Online++
+		}
+		for _, c := range source.GetChanges(ip) {
+			if !c.Time.Before(since) && c.Time.Before(now) {
+				d.Changes = append(d.Changes, DigestChange{Change: c, IP: ip, Name: deviceName(dev)})
+			}
+		}
+	}
+	sort.SliceStable(d.Changes, func(i, j int) bool { return d.Changes[i].Time.Before(d.Changes[j].Time) })
+
+	events := source.GetEvents(0, false)
+	for i := len(events) - 1; i >= 0; i-- {
+		e := events[i]
+		if e.Time.Before(since) || !e.Time.Before(now) {
+			continue
+		}
+		switch e.Type {
+		case types.EventDeviceNew:
+			d.New = append(d.New, e)
+		case types.EventDeviceOffline:
+			d.Offline = append(d.Offline, e)
+		case types.EventDeviceAnomaly:
+			d.Anomalies = append(d.Anomalies, e)
+		case types.EventScanFailed:
+			d.ScanFailures = append(d.ScanFailures, e)
+		}
+	}
+	d.Outliers = uptime.Outliers(devices, source, now.Sub(since), now)
+	for _, network := range source.ScannedNetworks() {
+		scan := DigestScan{Network: network, Last: source.GetLastScan(network)}
+		scan.Stale = scan.Last.Before(since)
+		for _, e := range d.ScanFailures {
+			if e.Network == network {
+				scan.Failures++
+			}
+		}
+		d.Scans = append(d.Scans, scan)
+	}
+	d.Empty = len(d.New) == 0 && len(d.Offline) == 0 && len(d.Anomalies) == 0 && len(d.Changes) == 0
+	return d
+}
+
+// Render writes the digest with tmpl.
+func (d Digest) Render(tmpl *template.Template) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, d); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// DigestTitle returns the title of a digest sent on the schedule name.
+func DigestTitle(name string) string {
+	if _, ok := digestSchedules[strings.ToLower(name)]; ok {
+		return "LAN Orangutan " + strings.ToLower(name) + " digest"
+	}
+	return "LAN Orangutan digest"
+}
+
+// DigestPeriod returns how long a digest sent on sched at t covers: as long
+// as the gap to the one after it.
+func DigestPeriod(sched *schedule.Schedule, t time.Time) time.Duration {
+	return sched.Next(t).Sub(t)
+}
+
+// RunDigest sends n a digest of what source recorded since the last one, at
+// the times sched gives, until ctx is done. name, such as "daily", goes into
+// the digest's title. The first digest covers as long as the gap to the one
+// after it.
+func RunDigest(ctx context.Context, n Notifier, name string, sched *schedule.Schedule, tmpl *template.Template, source DigestSource) {
+	title := DigestTitle(name)
+	var last time.Time
+	for {
+		next := sched.Next(time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+		since := last
+		if since.IsZero() {
+			since = next.Add(-DigestPeriod(sched, next))
+		}
+		last = next
+
+		text, err := BuildDigest(source, title, since, next).Render(tmpl)
+		if err != nil {
+			slog.Warn("cannot write digest", "error", err)
+			continue
+		}
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		if err := n.Notify(sendCtx, Notification{Title: title, Text: text}); err != nil && ctx.Err() == nil {
+			slog.Warn("cannot send digest", "digest", title, "error", err)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,141 @@
+package alert
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// digestSource is a DigestSource holding events in the order they were
+// recorded.
+type digestSource struct {
+	fakeSource
+	devices   map[string]*types.Device
+	changes   map[string][]types.Change
+	sightings map[string][]types.Sighting
+	scans     map[string]time.Time
+}
+
+func (s *digestSource) GetDevices() map[string]*types.Device { return s.devices }
+func (s *digestSource) GetChanges(ip string) []types.Change  { return s.changes[ip] }
+func (s *digestSource) GetSightings(ip string, since time.Time) []types.Sighting {
+	return s.sightings[ip]
+}
+func (s *digestSource) GetLastScan(network string) time.Time { return s.scans[network] }
+func (s *digestSource) ScannedNetworks() []string {
+	var networks []string
+	for n := range s.scans {
+		networks = append(networks, n)
+	}
+	sort.Strings(networks)
+	return networks
+}
+
+func TestBuildDigest(t *testing.T) {
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.Local)
+	since := now.Add(-24 * time.Hour)
+	source := &digestSource{
+		fakeSource: fakeSource{events: []types.Event{
+			{ID: 1, Type: types.EventDeviceNew, Time: since.Add(-time.Hour), Name: "old", IP: "192.168.1.2"},
+			{ID: 2, Type: types.EventDeviceNew, Time: since.Add(time.Hour), Name: "phone", IP: "192.168.1.5", Network: "192.168.1.0/24"},
+			{ID: 3, Type: types.EventDeviceOffline, Time: since.Add(2 * time.Hour), Name: "printer", IP: "192.168.1.9"},
+			{ID: 4, Type: types.EventScanFailed, Time: since.Add(3 * time.Hour), Network: "10.0.0.0/24", Message: "Scan of 10.0.0.0/24 failed: timed out"},
+		}},
+		devices: map[string]*types.Device{
+			"192.168.1.5":  {IP: "192.168.1.5", Hostname: "phone", LastSeen: time.Now()},
+			"192.168.1.9":  {IP: "192.168.1.9", Hostname: "printer", FirstSeen: now.AddDate(0, -2, 0)},
+			"192.168.1.10": {IP: "192.168.1.10", Label: "NAS", LastSeen: time.Now()},
+		},
+		changes: map[string][]types.Change{
+			"192.168.1.10": {
+				{Time: since.Add(-time.Minute), Field: "label", New: "Old NAS"},
+				{Time: since.Add(4 * time.Hour), Field: "label", Old: "Old NAS", New: "NAS"},
+			},
+		},
+		sightings: map[string][]types.Sighting{
+			// On all month until two hours into the day
+			"192.168.1.9": {{Start: now.AddDate(0, 0, -31), End: since.Add(2 * time.Hour), Closed: true}},
+		},
+		scans: map[string]time.Time{
+			"192.168.1.0/24": now.Add(-5 * time.Minute),
+			"10.0.0.0/24":    since.Add(-time.Hour),
+		},
+	}
+
+	d := BuildDigest(source, "LAN Orangutan daily digest", since, now)
+	if d.Total != 3 || d.Online != 2 {
+		t.Errorf("Total, Online = %d, %d, want 3, 2", d.Total, d.Online)
+	}
+	if len(d.New) != 1 || d.New[0].Name != "phone" || len(d.Offline) != 1 || len(d.ScanFailures) != 1 {
+		t.Errorf("New = %v, Offline = %v, ScanFailures = %v", d.New, d.Offline, d.ScanFailures)
+	}
+	if len(d.Changes) != 1 || d.Changes[0].Name != "NAS" || d.Empty {
+		t.Errorf("Changes = %v, Empty = %v", d.Changes, d.Empty)
+	}
+
+	tmpl, err := DigestTemplate("")
+	if err != nil {
+		t.Fatalf("DigestTemplate: %v", err)
+	}
+	text, err := d.Render(tmpl)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	for _, want := range []string{
+		"LAN Orangutan daily digest\n",
+		"2 of 3 devices online now.",
+		"Joined (1)\n  Sun 09:00  phone (192.168.1.5) on 192.168.1.0/24",
+		"Went offline (1)\n  Sun 10:00  printer (192.168.1.9)",
+		"Changed (1)\n  Sun 12:00  NAS (192.168.1.10): label Old NAS -> NAS",
+		"Failed scans (1)\n  Sun 11:00  Scan of 10.0.0.0/24 failed: timed out",
+		"Online less than usual (1)\n  printer (192.168.1.9): 8%, usually 100%",
+		"Scans\n  10.0.0.0/24  not scanned since Sun 1 Mar 07:00, 1 failed\n  192.168.1.0/24  last Mon 07:55",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("digest lacks %q:\n%s", want, text)
+		}
+	}
+
+	quiet, _ := BuildDigest(source, "LAN Orangutan digest", now, now.Add(time.Hour)).Render(tmpl)
+	if !strings.Contains(quiet, "Nothing joined, left or changed.") {
+		t.Errorf("quiet digest:\n%s", quiet)
+	}
+}
+
+func TestDigestTemplateFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "digest.tmpl")
+	os.WriteFile(path, []byte("{{len .New}} new"), 0o600)
+	tmpl, err := DigestTemplate(path)
+	if err != nil {
+		t.Fatalf("DigestTemplate: %v", err)
+	}
+	text, _ := Digest{New: make([]types.Event, 2)}.Render(tmpl)
+	if text != "2 new" {
+		t.Errorf("Render = %q", text)
+	}
+	if _, err := DigestTemplate(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("DigestTemplate read a missing file")
+	}
+}
+
+func TestDigestSchedule(t *testing.T) {
+	sched, err := DigestSchedule("weekly")
+	if err != nil {
+		t.Fatalf("DigestSchedule: %v", err)
+	}
+	monday := time.Date(2026, 3, 2, 8, 0, 0, 0, time.Local)
+	if next := sched.Next(monday.Add(-time.Hour)); !next.Equal(monday) {
+		t.Errorf("Next = %v, want %v", next, monday)
+	}
+	if p := DigestPeriod(sched, monday); p != 7*24*time.Hour {
+		t.Errorf("DigestPeriod = %v, want a week", p)
+	}
+	if _, err := DigestSchedule("fortnightly"); err == nil {
+		t.Error("DigestSchedule accepted fortnightly")
+	}
+}
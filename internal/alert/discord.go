@@ -0,0 +1,33 @@
+package alert
+
+import "context"
+
+// discordLimit is the most characters Discord accepts in a message.
+const discordLimit = 2000
+
+// Discord posts alerts to a Discord webhook.
+type Discord struct {
+	URL      string
+	Username string
+}
+
+// discordMessage is the payload of a webhook.
+type discordMessage struct {
+	Content  string `json:"content"`
+	Username string `json:"username,omitempty"`
+	// AllowedMentions is left empty so that no @everyone or role mention in
+	// a device's name pings anyone.
+	AllowedMentions struct {
+		Parse []string `json:"parse"`
+	} `json:"allowed_mentions"`
+}
+
+// Notify posts the alert.
+func (d *Discord) Notify(ctx context.Context, n Notification) error {
+	msg := discordMessage{Content: n.Text, Username: d.Username}
+	msg.AllowedMentions.Parse = []string{}
+	if runes := []rune(msg.Content); len(runes) > discordLimit {
+		msg.Content = string(runes[:discordLimit-1]) + "…"
+	}
+	return postJSON(ctx, d.URL, msg)
+}
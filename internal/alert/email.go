@@ -0,0 +1,198 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ways of securing the connection to the mail server.
+const (
+	// SecurityStartTLS connects in the clear and switches to TLS before
+	// anything is sent, as submission on port 587 does.
+	SecurityStartTLS = "starttls"
+	// SecurityTLS connects with TLS from the start, as on port 465.
+	SecurityTLS = "tls"
+	// SecurityNone never uses TLS, for a relay on the same machine or LAN.
+	SecurityNone = "none"
+)
+
+// Email sends alerts by email through an SMTP server.
+type Email struct {
+	Host string
+	// Port is the server's port. 0 means 465 for SecurityTLS and 587
+	// otherwise.
+	Port int
+	// Security is SecurityStartTLS, SecurityTLS or SecurityNone. Empty means
+	// SecurityStartTLS.
+	Security string
+	// Username and Password log in to the server, when it needs it.
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// ValidSecurity reports whether s is a way of securing the connection
+// Email knows.
+func ValidSecurity(s string) bool {
+	switch s {
+	case "", SecurityStartTLS, SecurityTLS, SecurityNone:
+		return true
+	}
+	return false
+}
+
+// validEmail checks that opts describe mail that can be sent.
+func validEmail(opts NotifierOptions) error {
+	if opts.Host == "" {
+		return errors.New("no host set")
+	}
+	if !ValidSecurity(opts.Security) {
+		return fmt.Errorf("security %q is not starttls, tls or none", opts.Security)
+	}
+	if _, err := mail.ParseAddress(opts.From); err != nil {
+		return fmt.Errorf("from %q is not an email address", opts.From)
+	}
+	if len(opts.To) == 0 {
+		return errors.New("no to set")
+	}
+	for _, to := range opts.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("to: %q is not an email address", to)
+		}
+	}
+	return nil
+}
+
+// Notify mails the alert, with its title and the device's name as the
+// subject.
+func (e *Email) Notify(ctx context.Context, n Notification) error {
+	subject := n.Title
+	if n.Event.Name != "" {
+		subject += ": " + n.Event.Name
+	}
+	return e.Send(ctx, subject, n.Text)
+}
+
+// Send mails body, as plain text, with subject.
+func (e *Email) Send(ctx context.Context, subject, body string) error {
+	from, err := mail.ParseAddress(e.From)
+	if err != nil {
+		return fmt.Errorf("from: %w", err)
+	}
+	var to []string
+	for _, addr := range e.To {
+		a, err := mail.ParseAddress(addr)
+		if err != nil {
+			return fmt.Errorf("to: %w", err)
+		}
+		to = append(to, a.Address)
+	}
+
+	c, err := e.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if e.Security == "" || e.Security == SecurityStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s does not offer STARTTLS; set security = tls or none", e.Host)
+		}
+		if err := c.StartTLS(&tls.Config{ServerName: e.Host}); err != nil {
+			return err
+		}
+	}
+	if e.Username != "" {
+		// PlainAuth refuses to send the password in the clear to anything
+		// but this machine.
+		if err := c.Auth(smtp.PlainAuth("", e.Username, e.Password, e.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return fmt.Errorf("%s: %w", addr, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message(from, e.To, subject, body, time.Now())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// dial connects to the server, with TLS from the start for SecurityTLS, and
+// bounds the whole conversation by ctx's deadline.
+func (e *Email) dial(ctx context.Context) (*smtp.Client, error) {
+	port := e.Port
+	if port == 0 {
+		port = 587
+		if e.Security == SecurityTLS {
+			port = 465
+		}
+	}
+	addr := net.JoinHostPort(e.Host, strconv.Itoa(port))
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if e.Security == SecurityTLS {
+		conn = tls.Client(conn, &tls.Config{ServerName: e.Host})
+	}
+	c, err := smtp.NewClient(conn, e.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// message returns the mail to send: its headers, then body as quoted
+// printable, so that lines of any length and any characters arrive intact.
+func message(from *mail.Address, to []string, subject, body string, now time.Time) []byte {
+	var b bytes.Buffer
+	id := make([]byte, 12)
+	rand.Read(id)
+	_, domain, _ := strings.Cut(from.Address, "@")
+
+	fmt.Fprintf(&b, "From: %s\r\n", from.String())
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), domain)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	b.WriteString("Auto-Submitted: auto-generated\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&b)
+	qp.Write([]byte(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")))
+	qp.Close()
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
@@ -0,0 +1,165 @@
+package alert
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// smtpSession is what a fakeSMTP server was told.
+type smtpSession struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTP accepts one session on a local port, offering AUTH PLAIN but not
+// STARTTLS, and sends what it was told on the returned channel.
+func fakeSMTP(t *testing.T) (port int, got <-chan smtpSession) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	sessions := make(chan smtpSession, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+		var s smtpSession
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch verb {
+			case "EHLO":
+				reply("250-fake")
+				reply("250 AUTH PLAIN")
+			case "AUTH":
+				fields := strings.Fields(line)
+				decoded, _ := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+				s.auth = string(decoded)
+				reply("235 ok")
+			case "MAIL":
+				s.from = line
+				reply("250 ok")
+			case "RCPT":
+				s.to = append(s.to, line)
+				reply("250 ok")
+			case "DATA":
+				reply("354 go on")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				s.data = data.String()
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				sessions <- s
+				return
+			default:
+				reply("502 not here")
+			}
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, sessions
+}
+
+func TestEmailSends(t *testing.T) {
+	port, got := fakeSMTP(t)
+	n, err := NewNotifier(NotifierOptions{
+		Type:     "email",
+		Host:     "127.0.0.1",
+		Port:     port,
+		Security: SecurityNone,
+		Username: "orangutan",
+		Password: "s3cret",
+		From:     "LAN Orangutan <orangutan@example.com>",
+		To:       []string{"me@example.com", "Ops <ops@example.com>"},
+	})
+	if err != nil {
+		t.Fatalf("NewNotifier: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	text := "New device phone (192.168.1.5)\n" + strings.Repeat("long line ", 20)
+	err = n.Notify(ctx, Notification{Title: "New device", Text: text, Event: types.Event{Name: "phone"}})
+	if err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	s := <-got
+	if s.auth != "\x00orangutan\x00s3cret" {
+		t.Errorf("auth = %q", s.auth)
+	}
+	if s.from != "MAIL FROM:<orangutan@example.com>" || len(s.to) != 2 || s.to[1] != "RCPT TO:<ops@example.com>" {
+		t.Errorf("from = %q, to = %q", s.from, s.to)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(s.data))
+	if err != nil {
+		t.Fatalf("reading the mail: %v", err)
+	}
+	if subject := msg.Header.Get("Subject"); subject != "New device: phone" {
+		t.Errorf("Subject = %q", subject)
+	}
+	body, _ := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if got := strings.ReplaceAll(strings.TrimSpace(string(body)), "\r\n", "\n"); got != strings.TrimSpace(text) {
+		t.Errorf("body = %q, want %q", got, text)
+	}
+}
+
+func TestEmailWantsSTARTTLS(t *testing.T) {
+	port, _ := fakeSMTP(t)
+	e := &Email{Host: "127.0.0.1", Port: port, From: "a@example.com", To: []string{"b@example.com"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := e.Send(ctx, "hello", "hello")
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("err = %v, want it to refuse a server without STARTTLS", err)
+	}
+}
+
+func TestNewNotifierRejectsBadEmail(t *testing.T) {
+	good := NotifierOptions{Type: "email", Host: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com"}}
+	for name, change := range map[string]func(*NotifierOptions){
+		"no host":      func(o *NotifierOptions) { o.Host = "" },
+		"bad from":     func(o *NotifierOptions) { o.From = "orangutan" },
+		"no to":        func(o *NotifierOptions) { o.To = nil },
+		"bad to":       func(o *NotifierOptions) { o.To = []string{"b@example.com", "nobody"} },
+		"bad security": func(o *NotifierOptions) { o.Security = "ssl" },
+	} {
+		opts := good
+		change(&opts)
+		if _, err := NewNotifier(opts); err == nil {
+			t.Errorf("%s: NewNotifier succeeded", name)
+		}
+	}
+	if _, err := NewNotifier(good); err != nil {
+		t.Errorf("NewNotifier: %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/query"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// Policy is an escalation policy: who to tell about an alert, and who to
+// tell next while nobody has acknowledged it. A rule follows one with
+// Rule.Escalate.
+type Policy struct {
+	Name string
+	// Steps are in the order they are sent.
+	Steps []Step
+}
+
+// Step is one step of a Policy: the notifiers to send an alert with once it
+// has gone unacknowledged for After. The first step usually has none.
+type Step struct {
+	After  time.Duration
+	Notify []string
+}
+
+// escalationSource is implemented by sources that keep escalations, as the
+// storage does, so that Run can follow them from one poll to the next and
+// across restarts, and others can acknowledge them.
+type escalationSource interface {
+	AddEscalation(e types.Escalation) (types.Escalation, error)
+	GetEscalations(all bool) []types.Escalation
+	SetEscalationSteps(id int64, steps int) (bool, error)
+	ResolveEscalation(id int64) (bool, error)
+}
+
+// SetPolicies sets the escalation policies the rules follow. It fails when a
+// step names a notifier there is not, or a rule a policy there is not.
+func (e *Engine) SetPolicies(policies []Policy) error {
+	e.policies = make(map[string]Policy, len(policies))
+	for _, p := range policies {
+		if len(p.Steps) == 0 {
+			return fmt.Errorf("escalation %q has no steps", p.Name)
+		}
+		steps := append([]Step(nil), p.Steps...)
+		sort.SliceStable(steps, func(i, j int) bool { return steps[i].After < steps[j].After })
+		for _, step := range steps {
+			for _, name := range step.Notify {
+				if _, ok := e.notifiers[name]; !ok {
+					return fmt.Errorf("escalation %q: there is no notifier %q", p.Name, name)
+				}
+			}
+		}
+		p.Steps = steps
+		e.policies[p.Name] = p
+	}
+	for _, r := range e.rules {
+		if _, ok := e.policies[r.Escalate]; r.Escalate != "" && !ok {
+			return fmt.Errorf("alert %q: there is no escalation %q", r.Name, r.Escalate)
+		}
+	}
+	return nil
+}
+
+// startEscalations starts an escalation in source for each rule that
+// escalates and is for ev, which is about the device d. Their first steps
+// are sent by the next call to escalate.
+func (e *Engine) startEscalations(source Source, ev types.Event, d *types.Device, now time.Time) {
+	es, ok := source.(escalationSource)
+	if !ok {
+		return
+	}
+	for _, r := range e.rules {
+		if r.Escalate == "" || !r.events[ev.Type] || !r.matches(ev, d) {
+			continue
+		}
+		if _, err := es.AddEscalation(types.Escalation{Policy: r.Escalate, Rule: r.Name, Event: ev, Started: now}); err != nil {
+			slog.Warn("cannot start escalation", "alert", r.Name, "escalation", r.Escalate, "error", err)
+		}
+	}
+}
+
+// escalate sends the steps of the escalations in source that have come due
+// by now. An escalation about a device going offline stops by itself once
+// the device is seen again.
+func (e *Engine) escalate(ctx context.Context, source Source, now time.Time) {
+	es, ok := source.(escalationSource)
+	if !ok || len(e.policies) == 0 {
+		return
+	}
+	for _, esc := range es.GetEscalations(false) {
+		var d *types.Device
+		if esc.Event.IP != "" {
+			d = source.GetDevice(esc.Event.IP)
+		}
+		if esc.Event.Type == types.EventDeviceOffline && d != nil && d.LastSeen.After(esc.Event.Time) {
+			if _, err := es.ResolveEscalation(esc.ID); err != nil {
+				slog.Warn("cannot save escalation", "escalation", esc.ID, "error", err)
+			}
+			continue
+		}
+		p, ok := e.policies[esc.Policy]
+		r := e.rule(esc.Rule)
+		if !ok || r == nil {
+			// The config has changed since it started.
+			continue
+		}
+
+		var deliveries []Delivery
+		steps := esc.Steps
+		for ; steps < len(p.Steps) && !now.Before(esc.Started.Add(p.Steps[steps].After)); steps++ {
+			for _, name := range p.Steps[steps].Notify {
+				n, err := r.render(esc.Event, d, e.notifiers[name])
+				if err != nil {
+					slog.Warn("cannot word alert", "alert", r.Name, "error", err)
+					continue
+				}
+				n.Text += escalationNote(esc.ID, p, steps)
+				deliveries = append(deliveries, Delivery{Notifier: name, Notification: n})
+			}
+		}
+		if steps == esc.Steps {
+			continue
+		}
+		// Recorded before sending, so that a step is not sent again every
+		// poll when the file cannot be written. When it is no longer going,
+		// someone has just acknowledged it.
+		if going, err := es.SetEscalationSteps(esc.ID, steps); err != nil || !going {
+			if err != nil {
+				slog.Warn("cannot save escalation", "escalation", esc.ID, "error", err)
+			}
+			continue
+		}
+		e.Send(ctx, deliveries)
+	}
+}
+
+// rule returns the rule called name, or nil when there is none.
+func (e *Engine) rule(name string) *rule {
+	for i := range e.rules {
+		if e.rules[i].Name == name {
+			return &e.rules[i]
+		}
+	}
+	return nil
+}
+
+// escalationNote is added to the alert of step of the escalation id, which
+// follows p, to say how to stop it.
+func escalationNote(id int64, p Policy, step int) string {
+	note := "\n\n"
+	if after := p.Steps[step].After; after > 0 {
+		note += fmt.Sprintf("Not acknowledged after %s. ", query.FormatAge(after))
+	}
+	note += fmt.Sprintf("Acknowledge it in the dashboard or with 'orangutan ack %d'", id)
+	if step < len(p.Steps)-1 {
+		return note + " before it goes further."
+	}
+	return note + "."
+}
@@ -0,0 +1,112 @@
+package alert
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// escalationStore is a source that keeps escalations, as the storage does.
+type escalationStore struct {
+	fakeSource
+	devices     map[string]*types.Device
+	escalations []types.Escalation
+}
+
+func (s *escalationStore) GetDevice(ip string) *types.Device { return s.devices[ip] }
+
+func (s *escalationStore) AddEscalation(e types.Escalation) (types.Escalation, error) {
+	e.ID = int64(len(s.escalations) + 1)
+	s.escalations = append(s.escalations, e)
+	return e, nil
+}
+
+func (s *escalationStore) GetEscalations(all bool) []types.Escalation {
+	var result []types.Escalation
+	for _, e := range s.escalations {
+		if all || e.Open() {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func (s *escalationStore) SetEscalationSteps(id int64, steps int) (bool, error) {
+	s.escalations[id-1].Steps = steps
+	return s.escalations[id-1].Open(), nil
+}
+
+func (s *escalationStore) ResolveEscalation(id int64) (bool, error) {
+	s.escalations[id-1].Resolved = time.Now()
+	return true, nil
+}
+
+func TestEscalation(t *testing.T) {
+	phone, email, boss := &recorder{}, &recorder{}, &recorder{}
+	engine, err := New([]Rule{
+		{Name: "critical gear", Events: []string{"offline"}, Groups: []string{"Critical"}, Escalate: "critical"},
+	}, map[string]Notifier{"phone": phone, "email": email, "boss": boss})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := engine.SetPolicies([]Policy{{Name: "critical", Steps: []Step{
+		{After: 2 * time.Hour, Notify: []string{"boss"}},
+		{Notify: []string{"phone"}},
+		{After: 30 * time.Minute, Notify: []string{"email"}},
+	}}}); err != nil {
+		t.Fatalf("SetPolicies: %v", err)
+	}
+
+	start := time.Now()
+	nas := &types.Device{IP: "192.168.1.10", Group: "Critical", LastSeen: start.Add(-time.Hour)}
+	router := &types.Device{IP: "192.168.1.1", Group: "Critical", LastSeen: start.Add(-time.Hour)}
+	store := &escalationStore{devices: map[string]*types.Device{nas.IP: nas, router.IP: router}}
+	nasDown := newEvent(types.EventDeviceOffline, nas.IP, "nas")
+	nasDown.Time = start
+	routerDown := newEvent(types.EventDeviceOffline, router.IP, "router")
+	routerDown.Time = start
+
+	if got := engine.Route(nasDown, nas); len(got) != 0 {
+		t.Errorf("Route = %+v; want the escalating rule left to Run", got)
+	}
+	engine.startEscalations(store, nasDown, nas, start)
+	engine.startEscalations(store, routerDown, router, start)
+
+	ctx := context.Background()
+	engine.escalate(ctx, store, start)
+	if len(phone.sent) != 2 || len(email.sent) != 0 || !strings.Contains(phone.sent[0].Text, "'orangutan ack 1' before it goes further") {
+		t.Fatalf("at once: phone sent %+v, email %d; want the phone told of both", phone.sent, len(email.sent))
+	}
+	engine.escalate(ctx, store, start.Add(10*time.Minute))
+	if len(phone.sent) != 2 || len(email.sent) != 0 {
+		t.Errorf("after 10m: phone %d, email %d; want nothing more", len(phone.sent), len(email.sent))
+	}
+
+	// The router comes back, and someone takes on the NAS.
+	router.LastSeen = start.Add(5 * time.Minute)
+	engine.escalate(ctx, store, start.Add(31*time.Minute))
+	if len(email.sent) != 1 || !strings.Contains(email.sent[0].Text, "Not acknowledged after 30m") {
+		t.Fatalf("after 31m: email sent %+v; want only the NAS escalated", email.sent)
+	}
+	if store.escalations[1].Resolved.IsZero() {
+		t.Error("the router's escalation was not resolved when it came back")
+	}
+	store.escalations[0].Acknowledged = start.Add(40 * time.Minute)
+	engine.escalate(ctx, store, start.Add(3*time.Hour))
+	if len(boss.sent) != 0 {
+		t.Errorf("boss sent %+v after the NAS was acknowledged", boss.sent)
+	}
+}
+
+func TestSetPoliciesChecksNames(t *testing.T) {
+	engine, _ := New([]Rule{{Name: "servers", Escalate: "critical"}}, map[string]Notifier{"phone": &recorder{}})
+	if err := engine.SetPolicies(nil); err == nil {
+		t.Error("SetPolicies accepted a rule following a policy there is not")
+	}
+	if err := engine.SetPolicies([]Policy{{Name: "critical", Steps: []Step{{Notify: []string{"pager"}}}}}); err == nil {
+		t.Error("SetPolicies accepted a step with a notifier there is not")
+	}
+}
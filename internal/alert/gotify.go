@@ -0,0 +1,46 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// gotifyPriorities are Gotify's numbers for Priorities. Its Android app is
+// silent below 4 and pops up from 8.
+var gotifyPriorities = []int{1, 3, 5, 8, 10}
+
+// Gotify sends alerts to a Gotify server as an application.
+type Gotify struct {
+	// URL is the server's, such as https://gotify.example.com.
+	URL string
+	// Token is the application's token, which the server gives when the
+	// application is created.
+	Token string
+	// Priority is one of Priorities; empty means "default".
+	Priority string
+}
+
+// gotifyMessage is what Gotify takes to create a message.
+type gotifyMessage struct {
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+}
+
+// Notify sends the alert.
+func (g *Gotify) Notify(ctx context.Context, n Notification) error {
+	data, err := json.Marshal(gotifyMessage{
+		Title:    n.Title,
+		Message:  n.Text,
+		Priority: gotifyPriorities[priorityLevel(g.Priority)],
+	})
+	if err != nil {
+		return err
+	}
+	// In a header rather than the URL, so that it stays out of the
+	// server's access log.
+	header := http.Header{"X-Gotify-Key": {g.Token}}
+	return post(ctx, strings.TrimSuffix(g.URL, "/")+"/message", "application/json", data, header)
+}
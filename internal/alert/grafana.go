@@ -0,0 +1,57 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// GrafanaTag is on every annotation, for dashboards to show them by. The
+// dashboard in docs/grafana asks for it.
+const GrafanaTag = "lan-orangutan"
+
+// Grafana adds alerts to Grafana as annotations, so that a device joining
+// or dropping off is marked on the charts at the time it happened.
+type Grafana struct {
+	// URL is Grafana's, such as http://grafana.lan:3000.
+	URL string
+	// Token is a service account token allowed to write annotations.
+	Token string
+}
+
+// grafanaAnnotation is what Grafana takes to create an annotation. With no
+// dashboard it belongs to the organization, and shows on any dashboard
+// asking for its tags.
+type grafanaAnnotation struct {
+	Time int64    `json:"time"`
+	Tags []string `json:"tags"`
+	Text string   `json:"text"`
+}
+
+// Notify adds the annotation, tagged with the event's type and the device's
+// address, so a dashboard can show one kind or one device.
+func (g *Grafana) Notify(ctx context.Context, n Notification) error {
+	when := n.Event.Time
+	if when.IsZero() {
+		when = time.Now()
+	}
+	tags := []string{GrafanaTag}
+	if n.Event.Type != "" {
+		tags = append(tags, n.Event.Type)
+	}
+	if n.Event.IP != "" {
+		tags = append(tags, "ip:"+n.Event.IP)
+	}
+	data, err := json.Marshal(grafanaAnnotation{
+		Time: when.UnixMilli(),
+		Tags: tags,
+		Text: n.Text,
+	})
+	if err != nil {
+		return err
+	}
+	header := http.Header{"Authorization": {"Bearer " + g.Token}}
+	return post(ctx, strings.TrimSuffix(g.URL, "/")+"/api/annotations", "application/json", data, header)
+}
@@ -0,0 +1,56 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// journalSocket is where journald takes entries in its native protocol.
+const journalSocket = "/run/systemd/journal/socket"
+
+// Journald logs alerts to the systemd journal, with the event's details as
+// fields of their own, such as ORANGUTAN_MAC, to filter on:
+// journalctl ORANGUTAN_EVENT=new.
+type Journald struct {
+	// socket is where journald listens; tests point it elsewhere.
+	socket string
+}
+
+// Notify logs the alert.
+func (j *Journald) Notify(ctx context.Context, n Notification) error {
+	var entry bytes.Buffer
+	journalField(&entry, "MESSAGE", n.Text)
+	journalField(&entry, "PRIORITY", strconv.Itoa(severity(n.Event.Type)))
+	journalField(&entry, "SYSLOG_IDENTIFIER", syslogApp)
+	for _, f := range eventFields(n) {
+		journalField(&entry, "ORANGUTAN_"+f.name, f.value)
+	}
+
+	socket := j.socket
+	if socket == "" {
+		socket = journalSocket
+	}
+	conn, err := (&net.Dialer{}).DialContext(ctx, "unixgram", socket)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write(entry.Bytes())
+	return err
+}
+
+// journalField adds the field name with value to an entry. A value with a
+// newline in it is written with its length instead, as the protocol asks.
+func journalField(b *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		b.WriteString(name + "=" + value + "\n")
+		return
+	}
+	b.WriteString(name + "\n")
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value + "\n")
+}
@@ -0,0 +1,115 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// matrixTxn numbers the messages sent, so that each has its own
+// transaction ID and a retry is not taken for a repeat.
+var matrixTxn atomic.Int64
+
+// Matrix sends alerts to a Matrix room, as the user whose access token it
+// has. Create an account for LAN Orangutan and invite it to the room.
+type Matrix struct {
+	// URL is the homeserver's, such as https://matrix.example.org.
+	URL string
+	// Token is the access token of the account alerts are sent as.
+	Token string
+	// Room is the room's ID, such as !abc123:example.org, or an alias, such
+	// as #lan:example.org.
+	Room string
+
+	mu sync.Mutex
+	// roomID is Room with an alias resolved to the room's ID.
+	roomID string
+}
+
+// Notify sends the alert as a notice, which Matrix clients show without
+// pinging anyone and bots do not answer. It goes as plain text, so nothing
+// in a device's name is read as markup.
+func (m *Matrix) Notify(ctx context.Context, n Notification) error {
+	roomID, err := m.resolveRoom(ctx)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(map[string]string{"msgtype": "m.notice", "body": n.Text})
+	if err != nil {
+		return err
+	}
+	txn := fmt.Sprintf("orangutan-%d-%d", time.Now().UnixNano(), matrixTxn.Add(1))
+	u := m.api("rooms", roomID, "send", "m.room.message", txn)
+	return send(ctx, http.MethodPut, u, "application/json", data, m.header())
+}
+
+// resolveRoom returns the ID of the room, asking the homeserver about an
+// alias the first time.
+func (m *Matrix) resolveRoom(ctx context.Context) (string, error) {
+	if !strings.HasPrefix(m.Room, "#") {
+		return m.Room, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.roomID != "" {
+		return m.roomID, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.api("directory", "room", m.Room), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header = m.header()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var reply struct {
+		RoomID string `json:"room_id"`
+		Error  string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&reply)
+	if resp.StatusCode != http.StatusOK || reply.RoomID == "" {
+		if reply.Error != "" {
+			return "", fmt.Errorf("room %s: answered %s: %s", m.Room, resp.Status, reply.Error)
+		}
+		return "", fmt.Errorf("room %s: answered %s", m.Room, resp.Status)
+	}
+	m.roomID = reply.RoomID
+	return m.roomID, nil
+}
+
+// api returns the URL of the client API endpoint made of parts, each
+// escaped.
+func (m *Matrix) api(parts ...string) string {
+	u := strings.TrimSuffix(m.URL, "/") + "/_matrix/client/v3"
+	for _, p := range parts {
+		u += "/" + url.PathEscape(p)
+	}
+	return u
+}
+
+// header carries the access token, in a header rather than the URL so that
+// it stays out of the homeserver's access log.
+func (m *Matrix) header() http.Header {
+	return http.Header{"Authorization": {"Bearer " + m.Token}}
+}
+
+// validMatrixRoom checks that room is a room ID or alias.
+func validMatrixRoom(room string) error {
+	if room == "" {
+		return errors.New("no room set")
+	}
+	if (room[0] != '!' && room[0] != '#') || !strings.Contains(room, ":") {
+		return fmt.Errorf("room %q is not a room ID such as !abc123:example.org or an alias such as #lan:example.org", room)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeHomeserver knows the room #lan:example.org as !room1:example.org,
+// and records the messages sent to it with the token "syt_token".
+func fakeHomeserver(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer syt_token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, `{"errcode":"M_UNKNOWN_TOKEN","error":"Invalid access token"}`)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/_matrix/client/v3/directory/room/#lan:example.org":
+			io.WriteString(w, `{"room_id":"!room1:example.org","servers":["example.org"]}`)
+		case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/_matrix/client/v3/rooms/!room1:example.org/send/m.room.message/"):
+			data, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			sent = append(sent, string(data))
+			mu.Unlock()
+			io.WriteString(w, `{"event_id":"$ev"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"errcode":"M_NOT_FOUND","error":"Room alias not found"}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), sent...)
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	server, sent := fakeHomeserver(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, room := range []string{"!room1:example.org", "#lan:example.org"} {
+		n, err := NewNotifier(NotifierOptions{Type: "matrix", URL: server.URL + "/", Token: "syt_token", Room: room})
+		if err != nil {
+			t.Fatalf("NewNotifier: %v", err)
+		}
+		// Twice, so that the alias is resolved once and each message has
+		// its own transaction.
+		for range 2 {
+			if err := n.Notify(ctx, pushAlert); err != nil {
+				t.Fatalf("Notify to %s: %v", room, err)
+			}
+		}
+	}
+	got := sent()
+	if len(got) != 4 {
+		t.Fatalf("sent %d messages; want 4", len(got))
+	}
+	var msg map[string]string
+	json.Unmarshal([]byte(got[0]), &msg)
+	if msg["msgtype"] != "m.notice" || msg["body"] != pushAlert.Text {
+		t.Errorf("sent %s", got[0])
+	}
+}
+
+func TestMatrixErrors(t *testing.T) {
+	server, _ := fakeHomeserver(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, m := range []*Matrix{
+		{URL: server.URL, Token: "wrong", Room: "!room1:example.org"},
+		{URL: server.URL, Token: "syt_token", Room: "#nowhere:example.org"},
+	} {
+		if err := m.Notify(ctx, pushAlert); err == nil {
+			t.Errorf("Notify with token %q to %s succeeded", m.Token, m.Room)
+		}
+	}
+
+	for _, room := range []string{"", "lan", "#lan", "@someone:example.org"} {
+		if _, err := NewNotifier(NotifierOptions{Type: "matrix", URL: server.URL, Token: "syt_token", Room: room}); err == nil {
+			t.Errorf("NewNotifier accepted room %q", room)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// NotifierTypes are the services alerts can be sent to.
+var NotifierTypes = []string{"slack", "discord", "telegram", "matrix", "email", "ntfy", "gotify", "pushover", "webhook", "syslog", "journald", "snmp", "grafana"}
+
+// NotifierOptions say where a notifier sends alerts. Which of them are
+// needed depends on Type.
+type NotifierOptions struct {
+	// Type is the service: one of NotifierTypes.
+	Type string
+	// URL is the webhook alerts are posted to.
+	URL string
+	// Channel, for Slack, is the channel to post to instead of the
+	// webhook's own, where the webhook allows it.
+	Channel string
+	// Username is the name alerts are posted under instead of the webhook's
+	// own, where the service allows it. For email it is the name to log in
+	// to the mail server with.
+	Username string
+	// Token and ChatID, for Telegram, are the bot's token and the chat it
+	// sends to. For Matrix, ntfy, Gotify and Pushover, Token is the access
+	// or application token, and for Grafana the service account's.
+	Token  string
+	ChatID string
+	// User, for Pushover, is the user or group key alerts go to.
+	User string
+	// Room, for Matrix, is the room alerts go to, by ID or alias.
+	Room string
+	// Priority, for ntfy, Gotify and Pushover, is one of Priorities.
+	// Empty means "default".
+	Priority string
+	// Secret, for a webhook, signs each post. BodyTemplate is a file with
+	// the Go template to write the body with instead of the built-in JSON.
+	Secret       string
+	BodyTemplate string
+	// Facility, for syslog, is the facility alerts are logged to, such as
+	// daemon or local0.
+	Facility string
+	// Community, for SNMP, is the community string traps are sent with.
+	Community string
+	// The mail server and the mail for email, as Email has them.
+	Host     string
+	Port     int
+	Security string
+	Password string
+	From     string
+	To       []string
+}
+
+// NewNotifier returns the notifier opts describe.
+func NewNotifier(opts NotifierOptions) (Notifier, error) {
+	switch opts.Type {
+	case "slack":
+		if err := validWebhook(opts.URL); err != nil {
+			return nil, err
+		}
+		return &Slack{URL: opts.URL, Channel: opts.Channel, Username: opts.Username}, nil
+	case "discord":
+		if err := validWebhook(opts.URL); err != nil {
+			return nil, err
+		}
+		return &Discord{URL: opts.URL, Username: opts.Username}, nil
+	case "telegram":
+		if opts.Token == "" {
+			return nil, errors.New("no token set")
+		}
+		if opts.ChatID == "" {
+			return nil, errors.New("no chat_id set")
+		}
+		return &Telegram{Token: opts.Token, ChatID: opts.ChatID}, nil
+	case "matrix":
+		if err := validWebhook(opts.URL); err != nil {
+			return nil, err
+		}
+		if opts.Token == "" {
+			return nil, errors.New("no token set")
+		}
+		if err := validMatrixRoom(opts.Room); err != nil {
+			return nil, err
+		}
+		return &Matrix{URL: opts.URL, Token: opts.Token, Room: opts.Room}, nil
+	case "email":
+		if err := validEmail(opts); err != nil {
+			return nil, err
+		}
+		return &Email{
+			Host:     opts.Host,
+			Port:     opts.Port,
+			Security: opts.Security,
+			Username: opts.Username,
+			Password: opts.Password,
+			From:     opts.From,
+			To:       opts.To,
+		}, nil
+	case "ntfy":
+		if err := validWebhook(opts.URL); err != nil {
+			return nil, err
+		}
+		if err := validPriority(opts.Priority); err != nil {
+			return nil, err
+		}
+		return &Ntfy{URL: opts.URL, Token: opts.Token, Username: opts.Username, Password: opts.Password, Priority: opts.Priority}, nil
+	case "gotify":
+		if err := validWebhook(opts.URL); err != nil {
+			return nil, err
+		}
+		if opts.Token == "" {
+			return nil, errors.New("no token set")
+		}
+		if err := validPriority(opts.Priority); err != nil {
+			return nil, err
+		}
+		return &Gotify{URL: opts.URL, Token: opts.Token, Priority: opts.Priority}, nil
+	case "pushover":
+		if opts.Token == "" {
+			return nil, errors.New("no token set")
+		}
+		if opts.User == "" {
+			return nil, errors.New("no user set")
+		}
+		if err := validPriority(opts.Priority); err != nil {
+			return nil, err
+		}
+		return &Pushover{Token: opts.Token, User: opts.User, Priority: opts.Priority}, nil
+	case "webhook":
+		if err := validWebhook(opts.URL); err != nil {
+			return nil, err
+		}
+		w := &Webhook{URL: opts.URL, Secret: opts.Secret}
+		if opts.BodyTemplate != "" {
+			t, err := WebhookTemplate(opts.BodyTemplate)
+			if err != nil {
+				return nil, fmt.Errorf("body_template: %w", err)
+			}
+			w.Template = t
+		}
+		return w, nil
+	case "syslog":
+		if err := validSyslog(opts); err != nil {
+			return nil, err
+		}
+		return &Syslog{URL: opts.URL, Facility: opts.Facility}, nil
+	case "journald":
+		return &Journald{}, nil
+	case "snmp":
+		if err := validSNMP(opts); err != nil {
+			return nil, err
+		}
+		return &SNMP{URL: opts.URL, Community: opts.Community}, nil
+	case "grafana":
+		if err := validWebhook(opts.URL); err != nil {
+			return nil, err
+		}
+		if opts.Token == "" {
+			return nil, errors.New("no token set")
+		}
+		return &Grafana{URL: opts.URL, Token: opts.Token}, nil
+	case "":
+		return nil, errors.New("no type set")
+	default:
+		last := len(NotifierTypes) - 1
+		return nil, fmt.Errorf("type %q is not %s or %s", opts.Type, strings.Join(NotifierTypes[:last], ", "), NotifierTypes[last])
+	}
+}
+
+// Priorities are how urgently a push service may interrupt, least first.
+// They are ntfy's names; Gotify and Pushover have their own numbers for them.
+var Priorities = []string{"min", "low", "default", "high", "urgent"}
+
+// priorityLevel returns where p comes in Priorities, counting empty as
+// "default".
+func priorityLevel(p string) int {
+	if p == "" {
+		p = "default"
+	}
+	for i, name := range Priorities {
+		if p == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// validPriority checks that p is one of Priorities, or empty.
+func validPriority(p string) error {
+	if priorityLevel(p) < 0 {
+		last := len(Priorities) - 1
+		return fmt.Errorf("priority %q is not %s or %s", p, strings.Join(Priorities[:last], ", "), Priorities[last])
+	}
+	return nil
+}
+
+// validWebhook checks that u is a URL a webhook can be posted to.
+func validWebhook(u string) error {
+	if u == "" {
+		return errors.New("no url set")
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return fmt.Errorf("url: %w", err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		// Not quoted: a webhook URL carries the secret that lets anyone post.
+		return errors.New("url is not an http or https URL")
+	}
+	return nil
+}
+
+// httpClient sends the webhooks. Each send has its own deadline from Send.
+var httpClient = &http.Client{}
+
+// postJSON posts body as JSON to u, returning what the service answered
+// when it was not a success.
+func postJSON(ctx context.Context, u string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return post(ctx, u, "application/json", data, nil)
+}
+
+// post posts body to u with header, returning what the service answered
+// when it was not a success.
+func post(ctx context.Context, u, contentType string, body []byte, header http.Header) error {
+	return send(ctx, http.MethodPost, u, contentType, body, header)
+}
+
+// send is post for services that want another method, such as PUT.
+func send(ctx context.Context, method, u, contentType string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// The error names the URL, and the URL is the secret.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	reply, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode/100 != 2 {
+		return &statusError{code: resp.StatusCode, status: resp.Status, reply: strings.TrimSpace(string(reply))}
+	}
+	return nil
+}
+
+// statusError is a service answering with something other than success.
+type statusError struct {
+	code   int
+	status string
+	reply  string
+}
+
+func (e *statusError) Error() string {
+	if e.reply != "" {
+		return fmt.Sprintf("answered %s: %s", e.status, e.reply)
+	}
+	return "answered " + e.status
+}
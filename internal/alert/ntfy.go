@@ -0,0 +1,56 @@
+package alert
+
+import (
+	"context"
+	"encoding/base64"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// Ntfy publishes alerts to an ntfy topic, on ntfy.sh or a server of your
+// own, for its phone app to show.
+type Ntfy struct {
+	// URL is the topic's URL, such as https://ntfy.sh/my-lan. On ntfy.sh
+	// the topic's name is all that keeps others from reading it.
+	URL string
+	// Token, or Username and Password, log in to a server that wants it.
+	Token    string
+	Username string
+	Password string
+	// Priority is one of Priorities; empty means "default".
+	Priority string
+}
+
+// ntfyTags are the emoji ntfy shows beside the title of each kind of event.
+var ntfyTags = map[string]string{
+	types.EventDeviceNew:     "new",
+	types.EventDeviceOffline: "warning",
+	types.EventScanFailed:    "x",
+	types.EventDeviceAnomaly: "rotating_light",
+	types.EventPersonArrived: "house",
+	types.EventPersonLeft:    "wave",
+	types.EventCertExpiring:  "lock",
+	types.EventIntrusion:     "no_entry",
+}
+
+// Notify publishes the alert, with its title as the notification's.
+func (n *Ntfy) Notify(ctx context.Context, note Notification) error {
+	header := http.Header{}
+	// Headers are ASCII; a title with anything else goes encoded, which ntfy
+	// decodes.
+	header.Set("Title", mime.QEncoding.Encode("utf-8", note.Title))
+	header.Set("Priority", strconv.Itoa(priorityLevel(n.Priority)+1))
+	if tag := ntfyTags[note.Event.Type]; tag != "" {
+		header.Set("Tags", tag)
+	}
+	switch {
+	case n.Token != "":
+		header.Set("Authorization", "Bearer "+n.Token)
+	case n.Username != "":
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(n.Username+":"+n.Password)))
+	}
+	return post(ctx, n.URL, "text/plain; charset=utf-8", []byte(note.Text), header)
+}
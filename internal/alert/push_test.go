@@ -0,0 +1,166 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// pushRequest is what a push service was sent.
+type pushRequest struct {
+	path   string
+	header http.Header
+	body   string
+}
+
+// pushServer records the last request it was sent.
+func pushServer(t *testing.T) (*httptest.Server, *pushRequest) {
+	t.Helper()
+	got := &pushRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		*got = pushRequest{path: r.URL.Path, header: r.Header, body: string(data)}
+	}))
+	t.Cleanup(server.Close)
+	return server, got
+}
+
+var pushAlert = Notification{
+	Title: "Device offline",
+	Text:  "printer (192.168.1.9) went offline",
+	Event: types.Event{Type: types.EventDeviceOffline},
+}
+
+func TestNtfy(t *testing.T) {
+	server, got := pushServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	n, err := NewNotifier(NotifierOptions{Type: "ntfy", URL: server.URL + "/my-lan", Token: "tk_abc", Priority: "high"})
+	if err != nil {
+		t.Fatalf("NewNotifier: %v", err)
+	}
+	if err := n.Notify(ctx, pushAlert); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if got.path != "/my-lan" || got.body != pushAlert.Text {
+		t.Errorf("posted %q to %s", got.body, got.path)
+	}
+	for key, want := range map[string]string{
+		"Title":         "Device offline",
+		"Priority":      "4",
+		"Tags":          "warning",
+		"Authorization": "Bearer tk_abc",
+	} {
+		if v := got.header.Get(key); v != want {
+			t.Errorf("%s = %q, want %q", key, v, want)
+		}
+	}
+
+	n, _ = NewNotifier(NotifierOptions{Type: "ntfy", URL: server.URL + "/my-lan", Username: "me", Password: "pw"})
+	n.Notify(ctx, Notification{Title: "Nouvel appareil", Text: "hello"})
+	if user, pass, ok := (&http.Request{Header: got.header}).BasicAuth(); !ok || user != "me" || pass != "pw" {
+		t.Errorf("logged in as %q, %q", user, pass)
+	}
+	if got.header.Get("Priority") != "3" || got.header.Get("Tags") != "" {
+		t.Errorf("Priority, Tags = %q, %q", got.header.Get("Priority"), got.header.Get("Tags"))
+	}
+}
+
+func TestGotify(t *testing.T) {
+	server, got := pushServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	n, err := NewNotifier(NotifierOptions{Type: "gotify", URL: server.URL + "/", Token: "app-token"})
+	if err != nil {
+		t.Fatalf("NewNotifier: %v", err)
+	}
+	if err := n.Notify(ctx, pushAlert); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	var msg gotifyMessage
+	json.Unmarshal([]byte(got.body), &msg)
+	if got.path != "/message" || got.header.Get("X-Gotify-Key") != "app-token" {
+		t.Errorf("posted to %s with key %q", got.path, got.header.Get("X-Gotify-Key"))
+	}
+	if msg != (gotifyMessage{Title: "Device offline", Message: pushAlert.Text, Priority: 5}) {
+		t.Errorf("sent %+v", msg)
+	}
+}
+
+func TestPushover(t *testing.T) {
+	server, got := pushServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	p := &Pushover{Token: "app", User: "user", Priority: "urgent", api: server.URL}
+	if err := p.Notify(ctx, Notification{Title: "Scan failed", Text: strings.Repeat("x", 2000)}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	form, _ := url.ParseQuery(got.body)
+	if form.Get("token") != "app" || form.Get("user") != "user" || form.Get("title") != "Scan failed" {
+		t.Errorf("sent %v", form)
+	}
+	if form.Get("priority") != "2" || form.Get("retry") == "" || form.Get("expire") == "" {
+		t.Errorf("urgent sent priority %q, retry %q, expire %q", form.Get("priority"), form.Get("retry"), form.Get("expire"))
+	}
+	if n := len([]rune(form.Get("message"))); n != pushoverLimit {
+		t.Errorf("sent %d characters, want %d", n, pushoverLimit)
+	}
+
+	p.Priority = "low"
+	p.Notify(ctx, pushAlert)
+	if form, _ := url.ParseQuery(got.body); form.Get("priority") != "-1" || form.Has("retry") {
+		t.Errorf("low sent %v", form)
+	}
+}
+
+func TestGrafana(t *testing.T) {
+	server, got := pushServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	n, err := NewNotifier(NotifierOptions{Type: "grafana", URL: server.URL + "/", Token: "glsa_abc"})
+	if err != nil {
+		t.Fatalf("NewNotifier: %v", err)
+	}
+	when := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	alert := pushAlert
+	alert.Event.Time, alert.Event.IP = when, "192.168.1.9"
+	if err := n.Notify(ctx, alert); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if got.path != "/api/annotations" || got.header.Get("Authorization") != "Bearer glsa_abc" {
+		t.Errorf("posted to %s with %q", got.path, got.header.Get("Authorization"))
+	}
+	var a grafanaAnnotation
+	json.Unmarshal([]byte(got.body), &a)
+	want := []string{"lan-orangutan", "device_offline", "ip:192.168.1.9"}
+	if a.Time != when.UnixMilli() || a.Text != pushAlert.Text || strings.Join(a.Tags, ",") != strings.Join(want, ",") {
+		t.Errorf("sent %+v", a)
+	}
+}
+
+func TestNewNotifierRejectsBadPush(t *testing.T) {
+	for _, opts := range []NotifierOptions{
+		{Type: "ntfy"},
+		{Type: "ntfy", URL: "https://ntfy.sh/my-lan", Priority: "loud"},
+		{Type: "gotify", URL: "https://gotify.example.com"},
+		{Type: "pushover", Token: "app"},
+		{Type: "pushover", User: "user"},
+		{Type: "grafana", URL: "http://grafana.lan:3000"},
+	} {
+		if _, err := NewNotifier(opts); err == nil {
+			t.Errorf("NewNotifier(%+v) succeeded", opts)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package alert
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// pushoverAPI is where Pushover takes messages.
+const pushoverAPI = "https://api.pushover.net/1/messages.json"
+
+// Pushover's limits on a message and its title, in characters.
+const (
+	pushoverLimit      = 1024
+	pushoverTitleLimit = 250
+)
+
+// Pushover sends alerts with Pushover.
+type Pushover struct {
+	// Token is the application's API token, and User the user or group
+	// key of who it goes to.
+	Token string
+	User  string
+	// Priority is one of Priorities; empty means "default". "urgent" is
+	// Pushover's emergency priority, which repeats the alert every minute
+	// for an hour until it is acknowledged.
+	Priority string
+
+	// api is where messages are sent; tests point it elsewhere.
+	api string
+}
+
+// Notify sends the alert.
+func (p *Pushover) Notify(ctx context.Context, n Notification) error {
+	form := url.Values{
+		"token":    {p.Token},
+		"user":     {p.User},
+		"title":    {truncate(n.Title, pushoverTitleLimit)},
+		"message":  {truncate(n.Text, pushoverLimit)},
+		"priority": {strconv.Itoa(priorityLevel(p.Priority) - 2)},
+	}
+	if priorityLevel(p.Priority) == len(Priorities)-1 {
+		form.Set("retry", "60")
+		form.Set("expire", "3600")
+	}
+	api := p.api
+	if api == "" {
+		api = pushoverAPI
+	}
+	return post(ctx, api, "application/x-www-form-urlencoded", []byte(form.Encode()), nil)
+}
+
+// truncate shortens s to at most limit characters, marking that it was.
+func truncate(s string, limit int) string {
+	if runes := []rune(s); len(runes) > limit {
+		return string(runes[:limit-1]) + "…"
+	}
+	return s
+}
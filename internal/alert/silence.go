@@ -0,0 +1,87 @@
+package alert
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/schedule"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// Window is a maintenance window: a stretch of time, starting on a schedule,
+// when alerts about what it covers are not sent, such as the lab network at
+// weekends. Events are still recorded.
+type Window struct {
+	Name     string
+	Schedule *schedule.Schedule
+	Duration time.Duration
+	// Devices, Groups and Networks are what it covers, as for a Silence.
+	// All empty covers everything.
+	Devices  []string
+	Groups   []string
+	Networks []string
+}
+
+// Active reports whether the window is open at now: whether it started
+// within Duration before.
+func (w Window) Active(now time.Time) bool {
+	start := w.Schedule.Next(now.Add(-w.Duration))
+	return !start.IsZero() && !start.After(now)
+}
+
+// silenceSource is implemented by sources that keep silences, as the
+// storage does, so that Run can leave out the alerts they mute.
+type silenceSource interface {
+	GetSilences(all bool) []types.Silence
+}
+
+// SetWindows sets the maintenance windows during which alerts about what
+// they cover are not sent.
+func (e *Engine) SetWindows(windows []Window) {
+	e.windows = windows
+}
+
+// silenced returns why alerts about ev, which is about the device d, are not
+// sent at now, or "" when they are: an open maintenance window or one of
+// silences covers it.
+func (e *Engine) silenced(ev types.Event, d *types.Device, silences []types.Silence, now time.Time) string {
+	for _, w := range e.windows {
+		if w.Active(now) && covers(w.Devices, w.Groups, w.Networks, ev, d) {
+			return fmt.Sprintf("maintenance window %q", w.Name)
+		}
+	}
+	for _, s := range silences {
+		if s.Active(now) && covers(s.Devices, s.Groups, s.Networks, ev, d) {
+			return fmt.Sprintf("silence %d by %s", s.ID, s.CreatedBy)
+		}
+	}
+	return ""
+}
+
+// covers reports whether ev, about the device d, is about one of devices,
+// by address, MAC, label or hostname, a device in one of groups, or one of
+// networks. With all three empty it covers everything.
+func covers(devices, groups, networks []string, ev types.Event, d *types.Device) bool {
+	if len(devices) == 0 && len(groups) == 0 && len(networks) == 0 {
+		return true
+	}
+	r := rule{Rule: Rule{Devices: devices, Groups: groups}}
+	if (len(devices) > 0 || len(groups) > 0) && r.matches(ev, d) {
+		return true
+	}
+	for _, cidr := range networks {
+		_, ipNet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			continue
+		}
+		if ev.Network != "" && ev.Network == ipNet.String() {
+			return true
+		}
+		if ip := net.ParseIP(ev.IP); ip != nil && ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
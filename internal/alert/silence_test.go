@@ -0,0 +1,64 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/schedule"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func TestWindowActive(t *testing.T) {
+	weekends, err := schedule.Parse("0 0 * * sat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := Window{Name: "lab weekends", Schedule: weekends, Duration: 48 * time.Hour}
+	for at, want := range map[string]bool{
+		"2026-05-08 23:59": false, // Friday
+		"2026-05-09 00:00": true,
+		"2026-05-10 23:59": true,
+		"2026-05-11 00:00": false, // Monday
+	} {
+		now, _ := time.ParseInLocation("2006-01-02 15:04", at, time.Local)
+		if got := w.Active(now); got != want {
+			t.Errorf("Active at %s = %v, want %v", at, got, want)
+		}
+	}
+}
+
+func TestSilenced(t *testing.T) {
+	always, _ := schedule.Parse("* * * * *")
+	engine, err := New(nil, map[string]Notifier{"ops": &recorder{}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	engine.SetWindows([]Window{{Name: "lab", Schedule: always, Duration: time.Hour, Networks: []string{"10.0.20.1/24"}}})
+
+	now := time.Now()
+	bulb := &types.Device{IP: "192.168.1.50", MAC: "aa:bb:cc:00:00:50", Label: "Hall bulb"}
+	silences := []types.Silence{
+		{ID: 1, Devices: []string{"hall bulb"}, CreatedBy: "alice", Expires: now.Add(7 * 24 * time.Hour)},
+		{ID: 2, Groups: []string{"Cameras"}, CreatedBy: "bob", Expires: now.Add(-time.Minute)},
+		{ID: 3, CreatedBy: "carol", Expires: now.Add(time.Hour), Removed: now.Add(-time.Minute)},
+	}
+	tests := []struct {
+		name  string
+		event types.Event
+		d     *types.Device
+		want  string
+	}{
+		{"silenced device", newEvent(types.EventDeviceOffline, bulb.IP, "Hall bulb"), bulb, "silence 1 by alice"},
+		{"in the window's network", types.Event{Type: types.EventScanFailed, Network: "10.0.20.0/24"}, nil, `maintenance window "lab"`},
+		{"device in the window's network", newEvent(types.EventDeviceNew, "10.0.20.7", "probe"), nil, `maintenance window "lab"`},
+		{"silence expired", newEvent(types.EventDeviceOffline, "192.168.1.60", "camera"), &types.Device{IP: "192.168.1.60", Group: "cameras"}, ""},
+		{"silence removed", newEvent(types.EventDeviceNew, "192.168.1.70", "laptop"), nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := engine.silenced(tt.event, tt.d, silences, now); got != tt.want {
+				t.Errorf("silenced = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package alert
+
+import (
+	"context"
+	"strings"
+)
+
+// Slack posts alerts to a Slack incoming webhook.
+type Slack struct {
+	URL      string
+	Channel  string
+	Username string
+}
+
+// slackMessage is the payload of an incoming webhook. channel and username
+// are only honoured by legacy webhooks; app webhooks post to their own.
+type slackMessage struct {
+	Text     string `json:"text"`
+	Channel  string `json:"channel,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+// Notify posts the alert.
+func (s *Slack) Notify(ctx context.Context, n Notification) error {
+	return postJSON(ctx, s.URL, slackMessage{Text: n.Text, Channel: s.Channel, Username: s.Username})
+}
+
+// Escape escapes the characters Slack reads as the start of a link or
+// mention, such as <!channel>.
+func (s *Slack) Escape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
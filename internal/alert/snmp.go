@@ -0,0 +1,201 @@
+package alert
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// The OIDs of LAN-ORANGUTAN-MIB (docs/LAN-ORANGUTAN-MIB.txt), under the
+// enterprise number syslog's structured data uses too.
+var (
+	snmpNotifications = []int{1, 3, 6, 1, 4, 1, 32473, 291, 0}
+	snmpObjects       = []int{1, 3, 6, 1, 4, 1, 32473, 291, 1}
+
+	// sysUpTime.0 and snmpTrapOID.0, which every SNMPv2 trap starts with.
+	snmpSysUpTime = []int{1, 3, 6, 1, 2, 1, 1, 3, 0}
+	snmpTrapOID   = []int{1, 3, 6, 1, 6, 3, 1, 1, 4, 1, 0}
+)
+
+// snmpTraps are the last number of the notification sent for each event
+// type. Anything else, such as a test message or a digest, is sent as
+// orangutanMessage.
+var snmpTraps = map[string]int{
+	types.EventDeviceNew:     1,
+	types.EventDeviceOffline: 2,
+	types.EventScanFailed:    3,
+	EventScanCompleted:       4,
+	types.EventDeviceAnomaly: 6,
+	types.EventPersonArrived: 7,
+	types.EventPersonLeft:    8,
+	types.EventCertExpiring:  9,
+	types.EventIntrusion:     10,
+}
+
+const snmpMessageTrap = 5
+
+// snmpStart is when sysUpTime counts from.
+var snmpStart = time.Now()
+
+// SNMP sends alerts as SNMPv2c traps, for network operations centres whose
+// tools only take SNMP.
+type SNMP struct {
+	// URL is the trap receiver, as udp://host:162; the port may be left out.
+	URL string
+	// Community is the community string the receiver accepts. Empty means
+	// "public".
+	Community string
+}
+
+// validSNMP checks that opts describe a trap receiver.
+func validSNMP(opts NotifierOptions) error {
+	if opts.URL == "" {
+		return errors.New("no url set")
+	}
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return fmt.Errorf("url: %w", err)
+	}
+	if u.Scheme != "udp" || u.Hostname() == "" {
+		return fmt.Errorf("url %q is not udp://HOST or udp://HOST:PORT", opts.URL)
+	}
+	return nil
+}
+
+// Notify sends the alert as a trap.
+func (s *SNMP) Notify(ctx context.Context, n Notification) error {
+	u, _ := url.Parse(s.URL)
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "162")
+	}
+	community := s.Community
+	if community == "" {
+		community = "public"
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", host)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	_, err = conn.Write(snmpTrap(community, n, time.Since(snmpStart)))
+	return err
+}
+
+// snmpTrap returns the alert n as an SNMPv2c trap message from a sender
+// that has been up for uptime. Every object of the notification is sent,
+// empty when the event does not say, so receivers can read them by
+// position.
+func snmpTrap(community string, n Notification, uptime time.Duration) []byte {
+	trap, ok := snmpTraps[n.Event.Type]
+	if !ok {
+		trap = snmpMessageTrap
+	}
+	details := map[string]string{}
+	for _, f := range eventFields(n) {
+		details[f.name] = f.value
+	}
+	objects := []string{
+		details["EVENT"], details["IP"], details["NAME"], details["NETWORK"], details["DETAIL"],
+		details["MAC"], details["VENDOR"], details["HOSTNAME"], details["LABEL"], details["GROUP"],
+		strings.Join(strings.Fields(n.Text), " "),
+	}
+
+	bindings := [][]byte{
+		berSequence(berOID(snmpSysUpTime), berTLV(0x43, berUint(uint64(uint32(uptime/(10*time.Millisecond)))))),
+		berSequence(berOID(snmpTrapOID), berOID(slices.Concat(snmpNotifications, []int{trap}))),
+	}
+	for i, value := range objects {
+		// DisplayString is at most 255 octets.
+		for len(value) > 255 || !utf8.ValidString(value) {
+			value = value[:min(len(value), 255)-1]
+		}
+		oid := slices.Concat(snmpObjects, []int{i + 1, 0})
+		bindings = append(bindings, berSequence(berOID(oid), berTLV(0x04, []byte(value))))
+	}
+
+	var id [4]byte
+	_, _ = rand.Read(id[:])
+	pdu := berTLV(0xa7, slices.Concat(
+		berInt(int64(binary.BigEndian.Uint32(id[:])&0x7fffffff)),
+		berInt(0), // error-status
+		berInt(0), // error-index
+		berSequence(bindings...),
+	))
+	return berSequence(berInt(1), berTLV(0x04, []byte(community)), pdu) // version 1 is v2c
+}
+
+// berTLV encodes a value of type tag in BER.
+func berTLV(tag byte, value []byte) []byte {
+	out := []byte{tag}
+	switch n := len(value); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n <= 0xff:
+		out = append(out, 0x81, byte(n))
+	default:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	}
+	return append(out, value...)
+}
+
+// berSequence encodes items as a SEQUENCE.
+func berSequence(items ...[]byte) []byte {
+	return berTLV(0x30, slices.Concat(items...))
+}
+
+// berInt encodes v as an INTEGER, in the fewest octets of two's complement.
+func berInt(v int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(v))
+	for len(b) > 1 && (b[0] == 0 && b[1]&0x80 == 0 || b[0] == 0xff && b[1]&0x80 != 0) {
+		b = b[1:]
+	}
+	return berTLV(0x02, b)
+}
+
+// berUint returns the content octets of an unsigned number such as
+// TimeTicks, with a leading zero where the top bit would make it negative.
+func berUint(v uint64) []byte {
+	var b []byte
+	for {
+		b = append([]byte{byte(v)}, b...)
+		v >>= 8
+		if v == 0 {
+			break
+		}
+	}
+	if b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return b
+}
+
+// berOID encodes oid as an OBJECT IDENTIFIER.
+func berOID(oid []int) []byte {
+	out := []byte{byte(oid[0]*40 + oid[1])}
+	for _, arc := range oid[2:] {
+		var enc []byte
+		enc = append(enc, byte(arc&0x7f))
+		for arc >>= 7; arc > 0; arc >>= 7 {
+			enc = append([]byte{byte(arc&0x7f) | 0x80}, enc...)
+		}
+		out = append(out, enc...)
+	}
+	return berTLV(0x06, out)
+}
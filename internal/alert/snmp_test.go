@@ -0,0 +1,123 @@
+package alert
+
+import (
+	"context"
+	"encoding/asn1"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// snmpVarBind is a variable binding of a trap, as encoding/asn1 reads it.
+type snmpVarBind struct {
+	OID   asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+// readTrap decodes an SNMPv2c trap message, failing t if it is not one.
+func readTrap(t *testing.T, msg []byte) (community string, binds []snmpVarBind) {
+	t.Helper()
+	var m struct {
+		Version   int
+		Community []byte
+		PDU       asn1.RawValue
+	}
+	if rest, err := asn1.Unmarshal(msg, &m); err != nil || len(rest) > 0 {
+		t.Fatalf("not a message: %v (%d bytes left)", err, len(rest))
+	}
+	if m.Version != 1 || m.PDU.Class != asn1.ClassContextSpecific || m.PDU.Tag != 7 {
+		t.Fatalf("version %d, PDU class %d tag %d; want a v2c trap", m.Version, m.PDU.Class, m.PDU.Tag)
+	}
+	var requestID, errStatus, errIndex int
+	rest := m.PDU.Bytes
+	for _, v := range []*int{&requestID, &errStatus, &errIndex} {
+		var err error
+		if rest, err = asn1.Unmarshal(rest, v); err != nil {
+			t.Fatalf("PDU: %v", err)
+		}
+	}
+	if _, err := asn1.Unmarshal(rest, &binds); err != nil {
+		t.Fatalf("variable bindings: %v", err)
+	}
+	return string(m.Community), binds
+}
+
+func TestSNMPTrap(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	n, err := NewNotifier(NotifierOptions{Type: "snmp", URL: "udp://" + conn.LocalAddr().String(), Community: "noc"})
+	if err != nil {
+		t.Fatalf("NewNotifier: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n.Notify(ctx, syslogAlert); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	size, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	community, binds := readTrap(t, buf[:size])
+	if community != "noc" {
+		t.Errorf("community = %q", community)
+	}
+	if len(binds) != 13 {
+		t.Fatalf("%d variable bindings, want 13", len(binds))
+	}
+	if binds[0].OID.String() != "1.3.6.1.2.1.1.3.0" || binds[0].Value.Class != asn1.ClassApplication || binds[0].Value.Tag != 3 {
+		t.Errorf("first binding is %s, want sysUpTime.0 as TimeTicks", binds[0].OID)
+	}
+	var trap asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(binds[1].Value.FullBytes, &trap); err != nil || trap.String() != "1.3.6.1.4.1.32473.291.0.1" {
+		t.Errorf("snmpTrapOID.0 = %s, %v; want orangutanDeviceNew", trap, err)
+	}
+	want := map[string]string{
+		"1.3.6.1.4.1.32473.291.1.1.0":  "new",
+		"1.3.6.1.4.1.32473.291.1.2.0":  "192.168.1.5",
+		"1.3.6.1.4.1.32473.291.1.4.0":  "",
+		"1.3.6.1.4.1.32473.291.1.6.0":  "aa:bb:cc:dd:ee:ff",
+		"1.3.6.1.4.1.32473.291.1.7.0":  "Apple",
+		"1.3.6.1.4.1.32473.291.1.11.0": "New device phone (192.168.1.5) from Apple",
+	}
+	for _, b := range binds[2:] {
+		value, ok := want[b.OID.String()]
+		if !ok {
+			continue
+		}
+		if b.Value.Tag != asn1.TagOctetString || string(b.Value.Bytes) != value {
+			t.Errorf("%s = %q (tag %d), want %q", b.OID, b.Value.Bytes, b.Value.Tag, value)
+		}
+	}
+}
+
+func TestSNMPTrapOfATestMessage(t *testing.T) {
+	long := strings.Repeat("é", 200)
+	_, binds := readTrap(t, snmpTrap("public", Notification{Text: long}, 600*time.Second))
+	var trap asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(binds[1].Value.FullBytes, &trap); err != nil || trap.String() != "1.3.6.1.4.1.32473.291.0.5" {
+		t.Errorf("snmpTrapOID.0 = %s, %v; want orangutanMessage", trap, err)
+	}
+	// 60000 has its top bit set, so it takes a zero in front.
+	if ticks := binds[0].Value.Bytes; string(ticks) != "\x00\xea\x60" {
+		t.Errorf("sysUpTime = % x, want 60000 hundredths", ticks)
+	}
+	if text := binds[12].Value.Bytes; len(text) > 255 || !strings.HasPrefix(long, string(text)) || len(text)%2 != 0 {
+		t.Errorf("message of %d octets is not the start of the text", len(text))
+	}
+}
+
+func TestNewNotifierRejectsBadSNMP(t *testing.T) {
+	for _, u := range []string{"", "tcp://nms:162", "udp://", "nms:162"} {
+		if _, err := NewNotifier(NotifierOptions{Type: "snmp", URL: u}); err == nil {
+			t.Errorf("url %q accepted", u)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package alert
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// syslogApp is the name events are logged under.
+const syslogApp = "lan-orangutan"
+
+// syslogSDID names the structured data element of an event. 32473 is the
+// enterprise number set aside for examples and private use.
+const syslogSDID = "orangutan@32473"
+
+// syslogFacilities are the facilities events may be logged to.
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// Syslog severities.
+const (
+	severityErr     = 3
+	severityWarning = 4
+	severityNotice  = 5
+	severityInfo    = 6
+)
+
+// severity returns how serious an event of type kind is, for syslog and
+// journald: a failed scan or a device where it is not allowed is an error,
+// a device dropping off or acting oddly or a certificate running out a
+// warning, and a new device worth noticing.
+func severity(kind string) int {
+	switch kind {
+	case types.EventScanFailed, types.EventIntrusion:
+		return severityErr
+	case types.EventDeviceOffline, types.EventDeviceAnomaly, types.EventCertExpiring:
+		return severityWarning
+	case types.EventDeviceNew:
+		return severityNotice
+	default:
+		return severityInfo
+	}
+}
+
+// Syslog logs alerts to syslog: to the local daemon, or to a collector over
+// the network, with the event's details as structured data.
+type Syslog struct {
+	// URL is the collector: udp://host:514, tcp://host:601 or
+	// tls://host:6514. Empty means the local daemon, through /dev/log.
+	URL string
+	// Facility is one of the names syslog gives facilities, such as daemon
+	// or local0. Empty means daemon.
+	Facility string
+}
+
+// validSyslog checks that opts describe somewhere syslog can be sent.
+func validSyslog(opts NotifierOptions) error {
+	if _, ok := syslogFacilities[strings.ToLower(opts.Facility)]; !ok && opts.Facility != "" {
+		return fmt.Errorf("facility %q is not a syslog facility, such as daemon or local0", opts.Facility)
+	}
+	if opts.URL == "" {
+		return nil
+	}
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return fmt.Errorf("url: %w", err)
+	}
+	switch u.Scheme {
+	case "udp", "tcp", "tls":
+	default:
+		return fmt.Errorf("url %q is not udp://, tcp:// or tls://", opts.URL)
+	}
+	if u.Hostname() == "" || u.Port() == "" {
+		return fmt.Errorf("url %q does not name a host and port", opts.URL)
+	}
+	return nil
+}
+
+// Notify logs the alert.
+func (s *Syslog) Notify(ctx context.Context, n Notification) error {
+	facility := syslogFacilities["daemon"]
+	if f, ok := syslogFacilities[strings.ToLower(s.Facility)]; ok {
+		facility = f
+	}
+	priority := facility*8 + severity(n.Event.Type)
+	// A line each, so that one alert is one entry.
+	text := strings.Join(strings.Fields(n.Text), " ")
+
+	if s.URL == "" {
+		return s.local(ctx, fmt.Sprintf("<%d>%s %s[%d]: %s\n", priority, time.Now().Format(time.Stamp), syslogApp, os.Getpid(), text))
+	}
+	u, _ := url.Parse(s.URL)
+	msg := syslogMessage(priority, n, text, time.Now())
+	var d net.Dialer
+	var conn net.Conn
+	var err error
+	switch u.Scheme {
+	case "udp":
+		conn, err = d.DialContext(ctx, "udp", u.Host)
+	case "tls":
+		conn, err = (&tls.Dialer{NetDialer: &d, Config: &tls.Config{ServerName: u.Hostname()}}).DialContext(ctx, "tcp", u.Host)
+	default:
+		conn, err = d.DialContext(ctx, "tcp", u.Host)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if u.Scheme != "udp" {
+		// Over a stream, each message is framed by its length (RFC 6587).
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+	_, err = conn.Write([]byte(msg))
+	return err
+}
+
+// syslogSockets are where the local syslog daemon listens, by system.
+var syslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// local sends msg to the local syslog daemon.
+func (s *Syslog) local(ctx context.Context, msg string) error {
+	var d net.Dialer
+	for _, path := range syslogSockets {
+		for _, network := range []string{"unixgram", "unix"} {
+			conn, err := d.DialContext(ctx, network, path)
+			if err != nil {
+				continue
+			}
+			defer conn.Close()
+			_, err = conn.Write([]byte(msg))
+			return err
+		}
+	}
+	return errors.New("no syslog daemon is listening on " + strings.Join(syslogSockets, ", "))
+}
+
+// syslogMessage returns the alert n, worded as text, as an RFC 5424 message
+// with the event's details as structured data.
+func syslogMessage(priority int, n Notification, text string, now time.Time) string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "-"
+	}
+	msgID := "-"
+	if n.Event.Type != "" {
+		msgID = newData(n.Event, nil).Event
+	}
+	var sd strings.Builder
+	for _, f := range eventFields(n) {
+		fmt.Fprintf(&sd, ` %s="%s"`, strings.ToLower(f.name), sdEscape(f.value))
+	}
+	data := "-"
+	if sd.Len() > 0 {
+		data = "[" + syslogSDID + sd.String() + "]"
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s", priority, now.Format(time.RFC3339Nano), host, syslogApp, os.Getpid(), msgID, data, text)
+}
+
+// sdEscape escapes what cannot appear as is in a structured data value.
+func sdEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
+
+// eventField is one of the details of an event logged with it.
+type eventField struct {
+	name, value string
+}
+
+// eventFields returns the details of the event n is about that are set,
+// named as journald fields are.
+func eventFields(n Notification) []eventField {
+	ev := n.Event
+	fields := []eventField{
+		{"EVENT", newData(ev, nil).Event},
+		{"IP", ev.IP},
+		{"NAME", ev.Name},
+		{"NETWORK", ev.Network},
+		{"DETAIL", ev.Detail},
+	}
+	if d := n.Device; d != nil {
+		fields = append(fields,
+			eventField{"MAC", d.MAC},
+			eventField{"VENDOR", d.Vendor},
+			eventField{"HOSTNAME", d.Hostname},
+			eventField{"LABEL", d.Label},
+			eventField{"GROUP", d.Group},
+		)
+	}
+	result := fields[:0]
+	for _, f := range fields {
+		if f.value != "" {
+			result = append(result, f)
+		}
+	}
+	return result
+}
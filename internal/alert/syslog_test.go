@@ -0,0 +1,134 @@
+package alert
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var syslogAlert = Notification{
+	Title: "New device",
+	Text:  "New device phone (192.168.1.5)\nfrom Apple",
+	Event: types.Event{Type: types.EventDeviceNew, IP: "192.168.1.5", Name: `my "phone"]`},
+	Device: &types.Device{
+		IP:     "192.168.1.5",
+		MAC:    "aa:bb:cc:dd:ee:ff",
+		Vendor: "Apple",
+	},
+}
+
+func TestSyslogOverUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	n, err := NewNotifier(NotifierOptions{Type: "syslog", URL: "udp://" + conn.LocalAddr().String(), Facility: "local0"})
+	if err != nil {
+		t.Fatalf("NewNotifier: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n.Notify(ctx, syslogAlert); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	size, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// local0 is facility 16, and a new device a notice, severity 5.
+	want := regexp.MustCompile(`^<133>1 \S+ \S+ lan-orangutan \d+ new ` +
+		regexp.QuoteMeta(`[orangutan@32473 event="new" ip="192.168.1.5" name="my \"phone\"\]" mac="aa:bb:cc:dd:ee:ff" vendor="Apple"] New device phone (192.168.1.5) from Apple`) + `$`)
+	if got := string(buf[:size]); !want.MatchString(got) {
+		t.Errorf("sent %q", got)
+	}
+}
+
+func TestSyslogOverTCPFramesMessages(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	got := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var length int
+		fmt.Fscanf(r, "%d ", &length)
+		msg := make([]byte, length)
+		r.Read(msg)
+		got <- string(msg)
+	}()
+
+	s := &Syslog{URL: "tcp://" + ln.Addr().String()}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Notify(ctx, Notification{Text: "Scan of 10.0.0.0/24 failed", Event: types.Event{Type: types.EventScanFailed}}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	// daemon is facility 3, and a failed scan an error, severity 3.
+	if msg := <-got; !strings.HasPrefix(msg, "<27>1 ") || !strings.HasSuffix(msg, " Scan of 10.0.0.0/24 failed") {
+		t.Errorf("sent %q", msg)
+	}
+}
+
+func TestJournald(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.socket")
+	conn, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Skipf("no unix datagram sockets: %v", err)
+	}
+	defer conn.Close()
+
+	j := &Journald{socket: path}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := j.Notify(ctx, syslogAlert); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	size, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var length bytes.Buffer
+	binary.Write(&length, binary.LittleEndian, uint64(len(syslogAlert.Text)))
+	want := "MESSAGE\n" + length.String() + syslogAlert.Text + "\n" +
+		"PRIORITY=5\nSYSLOG_IDENTIFIER=lan-orangutan\nORANGUTAN_EVENT=new\nORANGUTAN_IP=192.168.1.5\n" +
+		"ORANGUTAN_NAME=my \"phone\"]\nORANGUTAN_MAC=aa:bb:cc:dd:ee:ff\nORANGUTAN_VENDOR=Apple\n"
+	if got := string(buf[:size]); got != want {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+func TestNewNotifierRejectsBadSyslog(t *testing.T) {
+	for _, opts := range []NotifierOptions{
+		{Type: "syslog", URL: "https://siem.example.com"},
+		{Type: "syslog", URL: "udp://siem.example.com"},
+		{Type: "syslog", Facility: "local9"},
+	} {
+		if _, err := NewNotifier(opts); err == nil {
+			t.Errorf("NewNotifier(%+v) succeeded", opts)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// telegramAPI is where the Bot API is served.
+const telegramAPI = "https://api.telegram.org"
+
+// telegramLimit is the most characters Telegram accepts in a message.
+const telegramLimit = 4096
+
+// telegramPoll is how long the Bot API may hold a request for updates open
+// waiting for a message.
+const telegramPoll = 50 * time.Second
+
+// Telegram sends alerts with a Telegram bot to a chat, and with Listen,
+// answers questions about the devices asked there.
+type Telegram struct {
+	// Token is the bot's token, from @BotFather.
+	Token string
+	// ChatID is the chat alerts go to and the only one questions are
+	// answered in: a user, group or channel ID, or @channelname.
+	ChatID string
+
+	// api is where the Bot API is served; tests point it elsewhere.
+	api string
+}
+
+// Notify sends the alert. It goes as plain text, so nothing in a device's
+// name is read as markup.
+func (t *Telegram) Notify(ctx context.Context, n Notification) error {
+	return t.send(ctx, n.Text)
+}
+
+func (t *Telegram) send(ctx context.Context, text string) error {
+	if runes := []rune(text); len(runes) > telegramLimit {
+		text = string(runes[:telegramLimit-1]) + "…"
+	}
+	return t.call(ctx, "sendMessage", map[string]any{
+		"chat_id":                  t.ChatID,
+		"text":                     text,
+		"disable_web_page_preview": true,
+	}, nil)
+}
+
+// call calls the Bot API method with params, decoding what it returns into
+// result unless that is nil.
+func (t *Telegram) call(ctx context.Context, method string, params, result any) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	base := t.api
+	if base == "" {
+		base = telegramAPI
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/bot"+t.Token+"/"+method, bytes.NewReader(data))
+	if err != nil {
+		return errors.New("bad token")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// The error names the URL, and the URL holds the token.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	var reply struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return fmt.Errorf("answered %s", resp.Status)
+	}
+	if !reply.OK {
+		return fmt.Errorf("answered %s: %s", resp.Status, reply.Description)
+	}
+	if result != nil {
+		return json.Unmarshal(reply.Result, result)
+	}
+	return nil
+}
+
+// telegramUpdate is the part of an update Listen reads.
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Text string `json:"text"`
+		Chat struct {
+			ID       int64  `json:"id"`
+			Username string `json:"username"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+// Devices is where the devices questions are about come from; the storage
+// is one.
+type Devices interface {
+	GetDevices() map[string]*types.Device
+}
+
+// Listen answers the commands sent to the bot in its chat, from the
+// devices, until ctx is done. Messages from any other chat are ignored, so
+// that whoever finds the bot cannot ask it about the network.
+//
+// Only one program may ask Telegram for a bot's messages at a time, so the
+// bot must not listen in two places at once.
+func (t *Telegram) Listen(ctx context.Context, devices Devices) {
+	var offset int64
+	// Logged when asking starts failing and again when it recovers.
+	failing := false
+	for ctx.Err() == nil {
+		var updates []telegramUpdate
+		pollCtx, cancel := context.WithTimeout(ctx, telegramPoll+sendTimeout)
+		err := t.call(pollCtx, "getUpdates", map[string]any{
+			"offset":          offset,
+			"timeout":         int(telegramPoll / time.Second),
+			"allowed_updates": []string{"message"},
+		}, &updates)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		switch {
+		case err != nil && !failing:
+			slog.Warn("cannot read Telegram messages", "error", err)
+		case err == nil && failing:
+			slog.Info("reading Telegram messages again")
+		}
+		failing = err != nil
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Minute):
+			}
+			continue
+		}
+
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			m := u.Message
+			if m == nil || !strings.HasPrefix(m.Text, "/") || !t.fromChat(m.Chat.ID, m.Chat.Username) {
+				continue
+			}
+			answer := Answer(m.Text, devices.GetDevices(), time.Now())
+			sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+			if err := t.send(sendCtx, answer); err != nil && ctx.Err() == nil {
+				slog.Warn("cannot answer on Telegram", "error", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// fromChat reports whether a message in the chat with id and username came
+// from the bot's own chat.
+func (t *Telegram) fromChat(id int64, username string) bool {
+	if name, ok := strings.CutPrefix(t.ChatID, "@"); ok {
+		return strings.EqualFold(name, username)
+	}
+	return t.ChatID == strconv.FormatInt(id, 10)
+}
+
+// telegramHelp is the answer to /help and to what is not understood.
+const telegramHelp = `/devices - the devices online now
+/devices offline - the devices not seen for an hour
+/devices all - every device
+/who 192.168.1.42 - a device, by address, MAC, label or hostname`
+
+// Answer returns the answer to the bot command text about devices: which
+// devices are online, or everything known of one.
+func Answer(text string, devices map[string]*types.Device, now time.Time) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return telegramHelp
+	}
+	// In groups, commands name the bot they are for: /devices@orangutan_bot.
+	command, _, _ := strings.Cut(strings.ToLower(fields[0]), "@")
+	args := fields[1:]
+
+	switch command {
+	case "/devices":
+		state := "online"
+		if len(args) > 0 {
+			state = strings.ToLower(args[0])
+		}
+		switch state {
+		case "online", "offline", "all":
+			return listDevices(devices, state, now)
+		}
+	case "/who":
+		// "/who is 192.168.1.42" reads better than "/who 192.168.1.42".
+		if len(args) > 1 && strings.EqualFold(args[0], "is") {
+			args = args[1:]
+		}
+		if len(args) > 0 {
+			return describeDevice(devices, strings.Join(args, " "), now)
+		}
+	case "/start", "/help":
+		return telegramHelp
+	}
+	return "Not understood. Ask:\n" + telegramHelp
+}
+
+// listDevices lists the devices that are online, offline or all of them, in
+// address order.
+func listDevices(devices map[string]*types.Device, state string, now time.Time) string {
+	var list []*types.Device
+	for _, d := range devices {
+		online := d.IsOnline()
+		if state == "all" || (state == "online") == online {
+			list = append(list, d)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return types.LessIP(list[i].IP, list[j].IP) })
+
+	var b strings.Builder
+	switch state {
+	case "online":
+		fmt.Fprintf(&b, "%d of %d devices online", len(list), len(devices))
+	case "offline":
+		fmt.Fprintf(&b, "%d of %d devices offline", len(list), len(devices))
+	default:
+		fmt.Fprintf(&b, "%d devices", len(list))
+	}
+	for i, d := range list {
+		line := fmt.Sprintf("\n%s  %s", d.IP, deviceName(d))
+		if state != "online" && !d.IsOnline() {
+			line += "  (seen " + ago(d.LastSeen, now) + ")"
+		}
+		// Leave room to say how many did not fit.
+		if b.Len()+len(line) > telegramLimit-40 {
+			fmt.Fprintf(&b, "\n…and %d more", len(list)-i)
+			break
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// describeDevice returns everything known of the device named by query.
+func describeDevice(devices map[string]*types.Device, query string, now time.Time) string {
+	var d *types.Device
+	for _, candidate := range devices {
+		if strings.EqualFold(query, candidate.IP) || strings.EqualFold(query, candidate.MAC) ||
+			strings.EqualFold(query, candidate.Label) || strings.EqualFold(query, candidate.Hostname) {
+			if d == nil || types.LessIP(candidate.IP, d.IP) {
+				d = candidate
+			}
+		}
+	}
+	if d == nil {
+		return "No device " + query + " is known."
+	}
+
+	var b strings.Builder
+	b.WriteString(deviceName(d))
+	if d.IsOnline() {
+		b.WriteString(" is online")
+	} else {
+		b.WriteString(" is offline")
+	}
+	b.WriteString(", last seen " + ago(d.LastSeen, now))
+	for _, field := range []struct{ name, value string }{
+		{"Address", d.IP},
+		{"MAC", d.MAC},
+		{"Vendor", d.Vendor},
+		{"Hostname", d.Hostname},
+		{"Group", d.Group},
+		{"Notes", d.Notes},
+	} {
+		if field.value != "" {
+			fmt.Fprintf(&b, "\n%s: %s", field.name, field.value)
+		}
+	}
+	if !d.FirstSeen.IsZero() {
+		b.WriteString("\nFirst seen: " + d.FirstSeen.Format("2006-01-02"))
+	}
+	return b.String()
+}
+
+// deviceName is how a device is named in answers: its label if it has one,
+// then its hostname, then its address.
+func deviceName(d *types.Device) string {
+	switch {
+	case d.Label != "":
+		return d.Label
+	case d.Hostname != "":
+		return d.Hostname
+	default:
+		return d.IP
+	}
+}
+
+// ago says how long before now t was.
+func ago(t time.Time, now time.Time) string {
+	diff := now.Sub(t)
+	switch {
+	case t.IsZero():
+		return "never"
+	case diff < time.Minute:
+		return "just now"
+	case diff < time.Hour:
+		return fmt.Sprintf("%d min ago", int(diff.Minutes()))
+	case diff < 48*time.Hour:
+		return fmt.Sprintf("%d h ago", int(diff.Hours()))
+	default:
+		return fmt.Sprintf("%d days ago", int(diff.Hours()/24))
+	}
+}
@@ -0,0 +1,137 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func testDevices(now time.Time) map[string]*types.Device {
+	return map[string]*types.Device{
+		"192.168.1.10": {IP: "192.168.1.10", Label: "NAS", MAC: "AA:BB:CC:00:00:10", Vendor: "Synology", LastSeen: now},
+		"192.168.1.9":  {IP: "192.168.1.9", Hostname: "printer", LastSeen: now.Add(-3 * time.Hour)},
+		"192.168.1.42": {IP: "192.168.1.42", Hostname: "pixel-7", Group: "Phones", LastSeen: now.Add(-time.Minute)},
+	}
+}
+
+func TestAnswer(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{"/devices", []string{"2 of 3 devices online\n192.168.1.10  NAS\n192.168.1.42  pixel-7"}},
+		{"/devices@orangutan_bot offline", []string{"1 of 3 devices offline\n192.168.1.9  printer  (seen 3 h ago)"}},
+		{"/devices all", []string{"3 devices\n192.168.1.9  printer"}},
+		{"/who is 192.168.1.42", []string{"pixel-7 is online", "Group: Phones"}},
+		{"/who nas", []string{"NAS is online", "MAC: AA:BB:CC:00:00:10", "Vendor: Synology"}},
+		{"/who 10.0.0.1", []string{"No device 10.0.0.1 is known."}},
+		{"/reboot", []string{"Not understood", "/devices offline"}},
+	}
+	for _, tt := range tests {
+		got := Answer(tt.command, testDevices(now), now)
+		for _, want := range tt.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("Answer(%q) = %q, want it to contain %q", tt.command, got, want)
+			}
+		}
+	}
+}
+
+// fakeBotAPI serves getUpdates, handing out updates once, and sendMessage,
+// keeping what was sent.
+type fakeBotAPI struct {
+	mu      sync.Mutex
+	updates []map[string]any
+	sent    []map[string]any
+	gotSent chan struct{}
+}
+
+func (f *fakeBotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/bot123:abc/") {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, `{"ok":false,"description":"Unauthorized"}`)
+		return
+	}
+	var params map[string]any
+	json.NewDecoder(r.Body).Decode(&params)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch strings.TrimPrefix(r.URL.Path, "/bot123:abc/") {
+	case "getUpdates":
+		json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": f.updates})
+		f.updates = nil
+	case "sendMessage":
+		f.sent = append(f.sent, params)
+		io.WriteString(w, `{"ok":true,"result":{}}`)
+		select {
+		case f.gotSent <- struct{}{}:
+		default:
+		}
+	}
+}
+
+type deviceMap map[string]*types.Device
+
+func (m deviceMap) GetDevices() map[string]*types.Device { return m }
+
+func TestTelegramListenAnswersItsChatOnly(t *testing.T) {
+	message := func(id int64, chat int64, text string) map[string]any {
+		return map[string]any{"update_id": id, "message": map[string]any{"text": text, "chat": map[string]any{"id": chat}}}
+	}
+	api := &fakeBotAPI{
+		updates: []map[string]any{
+			message(1, 666, "/devices all"),
+			message(2, 42, "hello"),
+			message(3, 42, "/who nas"),
+		},
+		gotSent: make(chan struct{}, 1),
+	}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	tg := &Telegram{Token: "123:abc", ChatID: "42", api: server.URL}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		tg.Listen(ctx, deviceMap(testDevices(time.Now())))
+		close(done)
+	}()
+	select {
+	case <-api.gotSent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no answer sent")
+	}
+	cancel()
+	<-done
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if len(api.sent) != 1 {
+		t.Fatalf("sent %d messages, want 1: %v", len(api.sent), api.sent)
+	}
+	if api.sent[0]["chat_id"] != "42" || !strings.HasPrefix(api.sent[0]["text"].(string), "NAS is online") {
+		t.Errorf("sent %v", api.sent[0])
+	}
+}
+
+func TestTelegramNotifyReportsRefusal(t *testing.T) {
+	server := httptest.NewServer(&fakeBotAPI{})
+	defer server.Close()
+	tg := &Telegram{Token: "999:wrong", ChatID: "42", api: server.URL}
+	err := tg.Notify(context.Background(), Notification{Text: "hello"})
+	if err == nil || !strings.Contains(err.Error(), "Unauthorized") {
+		t.Errorf("err = %v", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "999:wrong") {
+		t.Errorf("error gives the token away: %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"text/template"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// webhookAttempts is how many times a webhook is tried before the alert is
+// given up on. The waits between them double from webhookBackoff, and all
+// must fit in the time Send allows an alert.
+const (
+	webhookAttempts = 4
+	webhookBackoff  = time.Second
+)
+
+// Webhook posts alerts as JSON to a URL of your own, for any program to act
+// on.
+type Webhook struct {
+	URL string
+	// Secret, when set, signs each post, so the receiver can tell it came
+	// from here: the X-Orangutan-Signature header is "sha256=" and the hex
+	// HMAC-SHA256 of the body keyed with Secret.
+	Secret string
+	// Template, when set, writes the body instead of the built-in JSON. It
+	// is given a WebhookPayload.
+	Template *template.Template
+
+	// backoff is the first wait before trying again; tests shorten it.
+	backoff time.Duration
+}
+
+// WebhookPayload is what a webhook posts, and what its template is given.
+type WebhookPayload struct {
+	// Event is the rule's name for the event, one of EventNames.
+	Event   string    `json:"event"`
+	Time    time.Time `json:"time"`
+	Title   string    `json:"title"`
+	Message string    `json:"message"`
+	IP      string    `json:"ip,omitempty"`
+	Name    string    `json:"name,omitempty"`
+	Network string    `json:"network,omitempty"`
+	Detail  string    `json:"detail,omitempty"`
+	// Device is the device as the API gives it, when the inventory has it.
+	Device *types.Device `json:"device,omitempty"`
+}
+
+// WebhookTemplate returns the template in the file at path, for a webhook's
+// body. Its json function writes a value as JSON, so that a device's name
+// cannot break out of a string: {"text": {{json .Message}}}.
+func WebhookTemplate(path string) (*template.Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return template.New("webhook").Option("missingkey=error").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}).Parse(string(data))
+}
+
+// Notify posts the alert, trying again with longer waits between while the
+// receiver cannot be reached or answers that it is failing or busy.
+func (w *Webhook) Notify(ctx context.Context, n Notification) error {
+	payload := WebhookPayload{
+		Event:   newData(n.Event, nil).Event,
+		Time:    n.Event.Time,
+		Title:   n.Title,
+		Message: n.Text,
+		IP:      n.Event.IP,
+		Name:    n.Event.Name,
+		Network: n.Event.Network,
+		Detail:  n.Event.Detail,
+		Device:  n.Device,
+	}
+	var body []byte
+	if w.Template != nil {
+		var buf bytes.Buffer
+		if err := w.Template.Execute(&buf, payload); err != nil {
+			return err
+		}
+		body = buf.Bytes()
+	} else {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return err
+		}
+	}
+
+	header := http.Header{}
+	header.Set("User-Agent", "LAN-Orangutan")
+	header.Set("X-Orangutan-Event", payload.Event)
+	// The same on every attempt, so that a receiver that got an earlier one
+	// after all can tell it is seeing it again.
+	id := make([]byte, 16)
+	rand.Read(id)
+	header.Set("X-Orangutan-Delivery", hex.EncodeToString(id))
+	if w.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.Secret))
+		mac.Write(body)
+		header.Set("X-Orangutan-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	wait := w.backoff
+	if wait == 0 {
+		wait = webhookBackoff
+	}
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = post(ctx, w.URL, "application/json", body, header)
+		if err == nil || attempt == webhookAttempts || !retryable(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// retryable reports whether a post that failed with err may succeed if
+// tried again: the receiver could not be reached, or answered that it is
+// failing or that it is being sent too much.
+func retryable(err error) bool {
+	var status *statusError
+	if errors.As(err, &status) {
+		return status.code >= 500 || status.code == http.StatusTooManyRequests
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package alert
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func TestWebhookSignsAndRetries(t *testing.T) {
+	var bodies []string
+	var deliveries []string
+	failures := 2
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(data))
+		deliveries = append(deliveries, r.Header.Get("X-Orangutan-Delivery"))
+
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write(data)
+		if r.Header.Get("X-Orangutan-Signature") != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			t.Errorf("bad signature %q", r.Header.Get("X-Orangutan-Signature"))
+		}
+		if r.Header.Get("X-Orangutan-Event") != "new" {
+			t.Errorf("X-Orangutan-Event = %q", r.Header.Get("X-Orangutan-Event"))
+		}
+		if failures > 0 {
+			failures--
+			w.WriteHeader(status)
+		}
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	w := &Webhook{URL: server.URL, Secret: "s3cret", backoff: time.Millisecond}
+	device := &types.Device{IP: "192.168.1.5", MAC: "aa:bb:cc:dd:ee:ff", Hostname: "phone"}
+	n := Notification{
+		Title:  "New device",
+		Text:   "phone (192.168.1.5) joined",
+		Event:  types.Event{Type: types.EventDeviceNew, IP: "192.168.1.5", Name: "phone"},
+		Device: device,
+	}
+	if err := w.Notify(ctx, n); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if len(bodies) != 3 || deliveries[0] != deliveries[2] {
+		t.Fatalf("tried %d times, as deliveries %q; want 3 of one delivery", len(bodies), deliveries)
+	}
+	var payload WebhookPayload
+	if err := json.Unmarshal([]byte(bodies[2]), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Event != "new" || payload.Message != n.Text || payload.Device == nil || payload.Device.MAC != device.MAC {
+		t.Errorf("posted %+v", payload)
+	}
+
+	// A receiver that refuses the post will refuse it again.
+	bodies, failures, status = nil, 1, http.StatusBadRequest
+	if err := w.Notify(ctx, n); err == nil || len(bodies) != 1 {
+		t.Errorf("err = %v after %d tries, want an error after 1", err, len(bodies))
+	}
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "body.tmpl")
+	os.WriteFile(path, []byte(`{"text": {{json .Message}}, "kind": "{{.Event}}"}`), 0o600)
+	n, err := NewNotifier(NotifierOptions{Type: "webhook", URL: server.URL, BodyTemplate: path})
+	if err != nil {
+		t.Fatalf("NewNotifier: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = n.Notify(ctx, Notification{Text: `"evil" joined`, Event: types.Event{Type: EventScanCompleted}})
+	if err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if want := `{"text": "\"evil\" joined", "kind": "scan_completed"}`; body != want {
+		t.Errorf("posted %s, want %s", body, want)
+	}
+
+	if _, err := NewNotifier(NotifierOptions{Type: "webhook", URL: server.URL, BodyTemplate: path + ".missing"}); err == nil {
+		t.Error("NewNotifier read a missing template")
+	}
+}
+
+// scanTimes is a scanSource.
+type scanTimes map[string]time.Time
+
+func (s scanTimes) ScannedNetworks() []string {
+	var networks []string
+	for cidr := range s {
+		networks = append(networks, cidr)
+	}
+	return networks
+}
+
+func (s scanTimes) GetLastScan(cidr string) time.Time { return s[cidr] }
+
+func TestScansAfter(t *testing.T) {
+	start := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	scans := scanTimes{"192.168.1.0/24": start}
+	last := map[string]time.Time{"192.168.1.0/24": start}
+	if got := scansAfter(scans, last); len(got) != 0 {
+		t.Errorf("scansAfter = %v before any scan", got)
+	}
+
+	scans["192.168.1.0/24"] = start.Add(2 * time.Minute)
+	scans["10.0.0.0/24"] = start.Add(time.Minute)
+	got := scansAfter(scans, last)
+	if len(got) != 2 || got[0].Network != "10.0.0.0/24" || got[1].Network != "192.168.1.0/24" || got[1].Type != EventScanCompleted {
+		t.Errorf("scansAfter = %v", got)
+	}
+	if got := scansAfter(scans, last); len(got) != 0 {
+		t.Errorf("scansAfter = %v again", got)
+	}
+}
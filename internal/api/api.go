@@ -6,37 +6,87 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"errors"
+	"io"
+	"log/slog"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/291-Group/LAN-Orangutan/internal/config"
+	"github.com/291-Group/LAN-Orangutan/internal/dnszone"
+	"github.com/291-Group/LAN-Orangutan/internal/influx"
 	"github.com/291-Group/LAN-Orangutan/internal/network"
+	"github.com/291-Group/LAN-Orangutan/internal/query"
 	"github.com/291-Group/LAN-Orangutan/internal/scanner"
 	"github.com/291-Group/LAN-Orangutan/internal/storage"
 	"github.com/291-Group/LAN-Orangutan/internal/types"
+	"github.com/291-Group/LAN-Orangutan/internal/uptime"
+	"github.com/291-Group/LAN-Orangutan/internal/zabbix"
 )
 
 // Handler handles API requests
 type Handler struct {
-	store   *storage.Storage
-	cfg     *config.Config
-	scanner *scanner.Scanner
+	store *storage.Storage
+	// cfg and scanner are replaced whole when the config is reloaded, so a
+	// request sees either the old settings or the new ones, never a mix.
+	cfg     atomic.Pointer[config.Config]
+	scanner atomic.Pointer[scanner.Scanner]
+	// influx writes each scan to InfluxDB, or is nil when that is not set up.
+	influx atomic.Pointer[influx.Client]
+	// zabbix sends each scan to Zabbix, or is nil when that is not set up.
+	// It is kept across scans for the hosts it has registered.
+	zabbix atomic.Pointer[zabbix.Client]
+
+	// userFor returns who made a request, for the record of who silenced
+	// what, or is nil when nobody signs in. See SetUserFor.
+	userFor func(*http.Request) string
 
 	// jobMu guards job, which holds the most recent background scan. Only one
 	// scan runs at a time.
 	jobMu sync.Mutex
 	job   *scanJob
+
+	// deepMu guards deep, the latest deep scan started through the API.
+	deepMu sync.Mutex
+	deep   deepScanStatus
 }
 
 // NewHandler creates a new API handler
 func NewHandler(store *storage.Storage, cfg *config.Config) *Handler {
-	return &Handler{
-		store:   store,
-		cfg:     cfg,
-		scanner: scanner.New(cfg.Scanning.MinScanInterval),
+	h := &Handler{store: store}
+	h.SetConfig(cfg)
+	return h
+}
+
+// SetUserFor has the handler ask userFor who made each request, such as the
+// signed in user's name. It returns "" for a request made with the password
+// or API token, which may say who it is for itself.
+func (h *Handler) SetUserFor(userFor func(*http.Request) string) {
+	h.userFor = userFor
+}
+
+// SetConfig switches to the settings in cfg, for a reloaded config file.
+// Requests already being served finish with the settings they started with.
+func (h *Handler) SetConfig(cfg *config.Config) {
+	h.cfg.Store(cfg)
+	h.store.SetApproval(cfg.Approval.Pending())
+	h.store.SetOfflineAfter(cfg.Offline.Grace())
+	h.store.SetPeople(cfg.People())
+	h.store.SetRestricted(cfg.RestrictedZones())
+	h.scanner.Store(cfg.NewScanner())
+	if cfg.InfluxDB.URL != "" {
+		h.influx.Store(influx.New(cfg.InfluxDB.Options()))
+	} else {
+		h.influx.Store(nil)
+	}
+	if cfg.Zabbix.Server != "" {
+		h.zabbix.Store(zabbix.New(cfg.Zabbix.Options()))
+	} else {
+		h.zabbix.Store(nil)
 	}
 }
 
@@ -63,8 +113,18 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	switch {
 	case path == "devices":
 		h.handleDevices(w, r)
+	case path == "devices/batch":
+		h.handleDevicesBatch(w, r)
 	case path == "device":
 		h.handleDevice(w, r)
+	case path == "device/wake":
+		h.handleDeviceWake(w, r)
+	case path == "device/sightings":
+		h.handleDeviceSightings(w, r)
+	case path == "uptime":
+		h.handleUptime(w, r)
+	case path == "presence":
+		h.handlePresence(w, r)
 	case path == "networks":
 		h.handleNetworks(w, r)
 	case path == "scan":
@@ -75,20 +135,50 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.handleScanProgress(w, r)
 	case path == "scan/cancel":
 		h.handleScanCancel(w, r)
+	case path == "events":
+		h.handleEvents(w, r)
+	case path == "events/read":
+		h.handleEventsRead(w, r)
+	case path == "vulnscan":
+		h.handleVulnScan(w, r)
+	case path == "findings":
+		h.handleFindings(w, r)
+	case path == "vulnerable":
+		h.handleVulnerable(w, r)
+	case path == "risk":
+		h.handleRisk(w, r)
+	case path == "certs":
+		h.handleCerts(w, r)
+	case path == "fingerprints":
+		h.handleFingerprints(w, r)
+	case path == "silences":
+		h.handleSilences(w, r)
+	case path == "escalations":
+		h.handleEscalations(w, r)
+	case path == "escalations/ack":
+		h.handleEscalationsAck(w, r)
 	case path == "tailscale":
 		h.handleTailscale(w, r)
+	case path == "tailscale/peers":
+		h.handleTailscalePeers(w, r)
+	case path == "tailscale/promote":
+		h.handleTailscalePromote(w, r)
 	case path == "stats":
 		h.handleStats(w, r)
 	case path == "status":
 		h.handleStatus(w, r)
 	case path == "settings":
 		h.handleSettings(w, r)
+	case path == "config":
+		h.handleConfig(w, r)
 	default:
 		h.error(w, http.StatusNotFound, "endpoint not found")
 	}
 }
 
-// handleDevices handles GET /api/devices
+// handleDevices handles GET /api/devices. The optional q parameter filters
+// the devices with the search language of package query, and min_risk keeps
+// those whose risk score is at least that.
 func (h *Handler) handleDevices(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		h.error(w, http.StatusMethodNotAllowed, "method not allowed")
@@ -96,6 +186,22 @@ func (h *Handler) handleDevices(w http.ResponseWriter, r *http.Request) {
 	}
 
 	devices := h.store.GetDevices()
+	if q := r.URL.Query().Get("q"); q != "" {
+		parsed, err := query.Parse(q)
+		if err != nil {
+			h.error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		devices = parsed.Filter(devices)
+	}
+	if v := r.URL.Query().Get("min_risk"); v != "" {
+		minRisk, err := strconv.Atoi(v)
+		if err != nil {
+			h.error(w, http.StatusBadRequest, "min_risk must be a number from 0 to 100")
+			return
+		}
+		devices = filterRisk(devices, h.store.GetDeepScans(), minRisk)
+	}
 
 	if r.URL.Query().Get("format") == "csv" {
 		h.writeDevicesCSV(w, devices)
@@ -142,7 +248,8 @@ func (h *Handler) writeDevicesCSV(w http.ResponseWriter, devices map[string]*typ
 	}
 }
 
-// handleDevice handles GET/POST/DELETE /api/device
+// handleDevice handles GET/POST/DELETE /api/device. DELETE takes the
+// device as ?ip= or, by any name, as ?device=.
 func (h *Handler) handleDevice(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
@@ -164,6 +271,7 @@ func (h *Handler) handleDevice(w http.ResponseWriter, r *http.Request) {
 			Label *string `json:"label"`
 			Notes *string `json:"notes"`
 			Group *string `json:"group"`
+			Type  *string `json:"type"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			h.error(w, http.StatusBadRequest, "invalid JSON")
@@ -180,16 +288,38 @@ func (h *Handler) handleDevice(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if err := h.store.UpdateDeviceFields(ip, req.Label, req.Notes, req.Group); err != nil {
+		if req.Type != nil && !scanner.ValidType(*req.Type) {
+			h.error(w, http.StatusBadRequest, "unknown device type")
+			return
+		}
+
+		if err := h.store.UpdateDeviceFields(ip, req.Label, req.Notes, req.Group, req.Type); err != nil {
 			h.error(w, http.StatusNotFound, err.Error())
 			return
 		}
+		if req.Label != nil {
+			h.writeDnsmasq()
+		}
 		h.success(w, map[string]string{"message": "device updated"})
 
 	case http.MethodDelete:
+		// The device parameter names the device any way the user knows it:
+		// by address, MAC, label or hostname.
 		ip := r.URL.Query().Get("ip")
+		if key := r.URL.Query().Get("device"); key != "" && ip == "" {
+			d, err := h.store.FindDevice(key)
+			if errors.Is(err, storage.ErrAmbiguous) {
+				h.error(w, http.StatusConflict, err.Error())
+				return
+			}
+			if err != nil {
+				h.error(w, http.StatusNotFound, err.Error())
+				return
+			}
+			ip = d.IP
+		}
 		if ip == "" {
-			h.error(w, http.StatusBadRequest, "ip parameter required")
+			h.error(w, http.StatusBadRequest, "ip or device parameter required")
 			return
 		}
 		if err := h.store.DeleteDevice(ip); err != nil {
@@ -203,6 +333,215 @@ func (h *Handler) handleDevice(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleDevicesBatch handles POST /api/devices/batch, which applies one action
+// to many devices at once:
+//
+//	{"ips": [...], "action": "group", "value": "Server"}
+//	{"ips": [...], "action": "add_tag", "value": "office"}
+//	{"ips": [...], "action": "remove_tag", "value": "office"}
+//	{"ips": [...], "action": "approve"}
+//	{"ips": [...], "action": "offline_after", "value": "2h"}
+//	{"ips": [...], "action": "watch", "value": "true"}
+//	{"ips": [...], "action": "delete"}
+//
+// Addresses with no device are skipped; the response says how many devices
+// the action applied to.
+func (h *Handler) handleDevicesBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req struct {
+		IPs    []string `json:"ips"`
+		Action string   `json:"action"`
+		Value  string   `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.error(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	if len(req.IPs) == 0 {
+		h.error(w, http.StatusBadRequest, "ips required")
+		return
+	}
+	value := strings.TrimSpace(req.Value)
+
+	var n int
+	var err error
+	switch req.Action {
+	case "group":
+		n, err = h.store.UpdateDevices(req.IPs, func(d *types.Device) { d.Group = value })
+	case "add_tag", "remove_tag":
+		if value == "" {
+			h.error(w, http.StatusBadRequest, "tag required")
+			return
+		}
+		n, err = h.store.UpdateDevices(req.IPs, func(d *types.Device) {
+			if req.Action == "add_tag" {
+				d.AddTag(value)
+			} else {
+				d.RemoveTag(value)
+			}
+		})
+	case "approve":
+		n, err = h.store.ApproveDevices(req.IPs)
+	case "offline_after":
+		// An empty value clears the device's own grace period.
+		var after time.Duration
+		if value != "" {
+			if after, err = query.ParseAge(value); err != nil {
+				h.error(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+		n, err = h.store.UpdateDevices(req.IPs, func(d *types.Device) { d.OfflineAfter = int(after / time.Second) })
+	case "watch":
+		// An empty value puts them on the watchlist, as for approve.
+		watch := true
+		if value != "" {
+			if watch, err = strconv.ParseBool(value); err != nil {
+				h.error(w, http.StatusBadRequest, "watch takes true or false")
+				return
+			}
+		}
+		n, err = h.store.UpdateDevices(req.IPs, func(d *types.Device) { d.Watch = watch })
+	case "delete":
+		n, err = h.store.DeleteDevices(req.IPs)
+	default:
+		h.error(w, http.StatusBadRequest, "unknown action")
+		return
+	}
+	if err != nil {
+		slog.Error("failed to save devices", "action", req.Action, "error", err)
+		h.error(w, http.StatusInternalServerError, "failed to save devices")
+		return
+	}
+	h.success(w, map[string]int{"updated": n})
+}
+
+// handleDeviceWake handles POST /api/device/wake
+func (h *Handler) handleDeviceWake(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req struct {
+		IP string `json:"ip"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.error(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	if req.IP == "" {
+		h.error(w, http.StatusBadRequest, "ip required")
+		return
+	}
+
+	device := h.store.GetDevice(req.IP)
+	if device == nil {
+		h.error(w, http.StatusNotFound, "device not found")
+		return
+	}
+	// The packet is addressed by MAC, so without one there is nothing to send.
+	// Tailscale peers never have one, and could not be woken through the
+	// tunnel anyway.
+	if device.MAC == "" {
+		h.error(w, http.StatusBadRequest, "no MAC address is known for this device")
+		return
+	}
+
+	networks, err := network.DetectNetworks()
+	if err != nil {
+		slog.Warn("failed to detect networks for wake-on-LAN", "error", err)
+	}
+	networks = network.WithConfigured(networks, h.cfg.Load().Scanning.Networks)
+	if err := network.Wake(device.MAC, device.IP, networks); err != nil {
+		h.error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.success(w, map[string]string{"message": "wake packet sent"})
+}
+
+// handleDeviceSightings handles GET /api/device/sightings?ip=&days=, the
+// stretches of time a device was seen over the last few days (7 by default,
+// at most 30, which is all the history that is kept).
+func (h *Handler) handleDeviceSightings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	ip := r.URL.Query().Get("ip")
+	if ip == "" {
+		h.error(w, http.StatusBadRequest, "ip required")
+		return
+	}
+	if h.store.GetDevice(ip) == nil {
+		h.error(w, http.StatusNotFound, "device not found")
+		return
+	}
+
+	days := 7
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 30 {
+			h.error(w, http.StatusBadRequest, "days must be between 1 and 30")
+			return
+		}
+		days = n
+	}
+
+	h.success(w, h.store.GetSightings(ip, time.Now().AddDate(0, 0, -days)))
+}
+
+// handleUptime handles GET /api/uptime?window=&ip=&q=, how much of the
+// window (7d by default, at most 30d) each device was online, least
+// available first. ip picks one device and q filters them as
+// /api/devices does.
+func (h *Handler) handleUptime(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	window, err := uptime.ParseWindow(r.URL.Query().Get("window"))
+	if err != nil {
+		h.error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	devices := h.store.GetDevices()
+	if ip := r.URL.Query().Get("ip"); ip != "" {
+		d, ok := devices[ip]
+		if !ok {
+			h.error(w, http.StatusNotFound, "device not found")
+			return
+		}
+		devices = map[string]*types.Device{ip: d}
+	}
+	if q := r.URL.Query().Get("q"); q != "" {
+		parsed, err := query.Parse(q)
+		if err != nil {
+			h.error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		devices = parsed.Filter(devices)
+	}
+
+	h.success(w, uptime.ComputeAll(devices, h.store, window, time.Now()))
+}
+
+// handlePresence handles GET /api/presence, whether each person of the
+// [person] sections is home.
+func (h *Handler) handlePresence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	h.success(w, h.store.GetPresence())
+}
+
 // handleNetworks handles GET /api/networks
 func (h *Handler) handleNetworks(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -215,7 +554,7 @@ func (h *Handler) handleNetworks(w http.ResponseWriter, r *http.Request) {
 		h.error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	h.success(w, network.WithConfigured(networks, h.cfg.Scanning.Networks))
+	h.success(w, network.WithConfigured(networks, h.cfg.Load().Scanning.Networks))
 }
 
 // handleScan handles GET /api/scan
@@ -231,9 +570,15 @@ func (h *Handler) handleScan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	timeout, err := h.scanTimeout(r)
+	if err != nil {
+		h.error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// "all" scans every detected network, matching the CLI's behaviour
 	if strings.EqualFold(cidr, "all") {
-		h.scanAllNetworks(w, r)
+		h.scanAllNetworks(w, r, timeout)
 		return
 	}
 
@@ -245,7 +590,7 @@ func (h *Handler) handleScan(w http.ResponseWriter, r *http.Request) {
 
 	// Check rate limit
 	lastScan := h.store.GetLastScan(cidr)
-	canScan, waitTime := h.scanner.CheckRateLimit(lastScan)
+	canScan, waitTime := h.scanner.Load().CheckRateLimit(lastScan)
 	if !canScan {
 		h.error(w, http.StatusTooManyRequests,
 			"rate limited, wait "+waitTime.Round(time.Second).String())
@@ -253,28 +598,37 @@ func (h *Handler) handleScan(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Perform scan
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+	extendWriteDeadline(w, timeout)
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
 	defer cancel()
 
-	result, err := h.scanner.Scan(ctx, cidr)
+	result, err := h.scanner.Load().ScanWith(ctx, cidr, h.cfg.Load().ForNetwork(cidr).ScanOptions())
 	if err != nil {
+		h.recordScanFailure(cidr, err.Error())
 		h.error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	if !result.Success {
+		h.recordScanFailure(cidr, result.Error)
 		h.error(w, http.StatusInternalServerError, result.Error)
 		return
 	}
 
 	// Merge devices into storage
-	if err := h.store.MergeDevices(result.Devices); err != nil {
+	if err := h.store.MergeScan(cidr, result.Devices); err != nil {
+		slog.Error("failed to save scan results", "network", cidr, "error", err)
 		h.error(w, http.StatusInternalServerError, "failed to save devices")
 		return
 	}
 
 	// Update last scan time
-	h.store.SetLastScan(cidr, time.Now())
+	if err := h.store.SetLastScan(cidr, time.Now()); err != nil {
+		slog.Warn("failed to save scan time", "network", cidr, "error", err)
+	}
+	slog.Info("scanned", "network", cidr, "scanner", result.Scanner, "devices", result.DeviceCount, "seconds", result.Duration)
+	h.exportScan(cidr, result)
+	h.writeDnsmasq()
 
 	h.success(w, result)
 }
@@ -299,12 +653,13 @@ type scanAllResult struct {
 	Timestamp    time.Time            `json:"timestamp"`
 }
 
-// scanAllNetworks scans every detected network. A network that is rate limited
-// or fails is reported in the response rather than failing the whole request,
-// so one bad interface cannot mask results from the others.
-func (h *Handler) scanAllNetworks(w http.ResponseWriter, r *http.Request) {
+// scanAllNetworks scans every detected network. A network that is disabled in
+// the config, rate limited or fails is reported in the response rather than
+// failing the whole request, so one bad interface cannot mask results from
+// the others.
+func (h *Handler) scanAllNetworks(w http.ResponseWriter, r *http.Request, timeout time.Duration) {
 	detected, err := network.DetectNetworks()
-	detected = network.WithConfigured(detected, h.cfg.Scanning.Networks)
+	detected = network.WithConfigured(detected, h.cfg.Load().Scanning.Networks)
 	if err != nil {
 		h.error(w, http.StatusInternalServerError, "failed to detect networks: "+err.Error())
 		return
@@ -314,6 +669,8 @@ func (h *Handler) scanAllNetworks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	extendWriteDeadline(w, time.Duration(len(detected))*timeout)
+
 	result := scanAllResult{
 		Networks:     make([]networkScanSummary, 0, len(detected)),
 		NetworkCount: len(detected),
@@ -323,16 +680,24 @@ func (h *Handler) scanAllNetworks(w http.ResponseWriter, r *http.Request) {
 	for _, n := range detected {
 		summary := networkScanSummary{Network: n.CIDR}
 
+		if !h.cfg.Load().ForNetwork(n.CIDR).Enable {
+			summary.Status = "skipped"
+			summary.Error = "disabled in the config"
+			result.Networks = append(result.Networks, summary)
+			continue
+		}
+
 		lastScan := h.store.GetLastScan(n.CIDR)
-		if canScan, waitTime := h.scanner.CheckRateLimit(lastScan); !canScan {
+		if canScan, waitTime := h.scanner.Load().CheckRateLimit(lastScan); !canScan {
 			summary.Status = "skipped"
 			summary.Error = "rate limited, wait " + waitTime.Round(time.Second).String()
 			result.Networks = append(result.Networks, summary)
 			continue
 		}
 
-		scan, err := h.scanNetwork(r.Context(), n.CIDR)
+		scan, err := h.scanNetwork(r.Context(), n.CIDR, timeout)
 		if err != nil {
+			h.recordScanFailure(n.CIDR, err.Error())
 			summary.Status = "failed"
 			summary.Error = err.Error()
 			result.Networks = append(result.Networks, summary)
@@ -353,7 +718,8 @@ func (h *Handler) scanAllNetworks(w http.ResponseWriter, r *http.Request) {
 }
 
 // resolveScanTargets turns the network parameter into the list of networks to
-// scan. "all" expands to every detected network, matching the CLI.
+// scan. "all" expands to every detected network the config does not disable,
+// matching the CLI.
 func (h *Handler) resolveScanTargets(cidr string) ([]string, error) {
 	if !strings.EqualFold(cidr, "all") {
 		if !network.ValidateCIDR(cidr) {
@@ -363,7 +729,7 @@ func (h *Handler) resolveScanTargets(cidr string) ([]string, error) {
 	}
 
 	detected, err := network.DetectNetworks()
-	detected = network.WithConfigured(detected, h.cfg.Scanning.Networks)
+	detected = network.WithConfigured(detected, h.cfg.Load().Scanning.Networks)
 	if err != nil {
 		return nil, errors.New("failed to detect networks: " + err.Error())
 	}
@@ -371,9 +737,15 @@ func (h *Handler) resolveScanTargets(cidr string) ([]string, error) {
 		return nil, errors.New("no networks detected")
 	}
 
+	cfg := h.cfg.Load()
 	networks := make([]string, 0, len(detected))
 	for _, n := range detected {
-		networks = append(networks, n.CIDR)
+		if cfg.ForNetwork(n.CIDR).Enable {
+			networks = append(networks, n.CIDR)
+		}
+	}
+	if len(networks) == 0 {
+		return nil, errors.New("the config disables every detected network")
 	}
 	return networks, nil
 }
@@ -399,6 +771,11 @@ func (h *Handler) handleScanStart(w http.ResponseWriter, r *http.Request) {
 		h.error(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	timeout, err := h.scanTimeout(r)
+	if err != nil {
+		h.error(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	h.jobMu.Lock()
 	defer h.jobMu.Unlock()
@@ -408,7 +785,7 @@ func (h *Handler) handleScanStart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.job = h.startScanJob(networks)
+	h.job = h.startScanJob(networks, timeout)
 	h.success(w, h.job.snapshot())
 }
 
@@ -451,11 +828,11 @@ func (h *Handler) handleScanCancel(w http.ResponseWriter, r *http.Request) {
 }
 
 // scanNetwork scans a single network and merges the results into storage.
-func (h *Handler) scanNetwork(ctx context.Context, cidr string) (*types.ScanResult, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+func (h *Handler) scanNetwork(ctx context.Context, cidr string, timeout time.Duration) (*types.ScanResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	result, err := h.scanner.Scan(ctx, cidr)
+	result, err := h.scanner.Load().ScanWith(ctx, cidr, h.cfg.Load().ForNetwork(cidr).ScanOptions())
 	if err != nil {
 		return nil, err
 	}
@@ -463,17 +840,295 @@ func (h *Handler) scanNetwork(ctx context.Context, cidr string) (*types.ScanResu
 		return nil, errors.New(result.Error)
 	}
 
-	if err := h.store.MergeDevices(result.Devices); err != nil {
+	if err := h.store.MergeScan(cidr, result.Devices); err != nil {
+		slog.Error("failed to save scan results", "network", cidr, "error", err)
 		return nil, errors.New("failed to save devices")
 	}
-	h.store.SetLastScan(cidr, time.Now())
+	if err := h.store.SetLastScan(cidr, time.Now()); err != nil {
+		slog.Warn("failed to save scan time", "network", cidr, "error", err)
+	}
 	// Remember how long this took so the next scan of the same network can show
 	// a progress estimate based on real measured time.
-	h.store.SetLastDuration(cidr, result.Duration)
+	if err := h.store.SetLastDuration(cidr, result.Duration); err != nil {
+		slog.Warn("failed to save scan duration", "network", cidr, "error", err)
+	}
+	slog.Info("scanned", "network", cidr, "scanner", result.Scanner, "devices", result.DeviceCount, "seconds", result.Duration)
+	h.exportScan(cidr, result)
+	h.writeDnsmasq()
 
 	return result, nil
 }
 
+// exportScan writes the scan of cidr to InfluxDB and Zabbix, if set up, in
+// the background: a slow or missing server must not hold up the scan's
+// response or the next network.
+func (h *Handler) exportScan(cidr string, result *types.ScanResult) {
+	client, zc := h.influx.Load(), h.zabbix.Load()
+	if client == nil && zc == nil {
+		return
+	}
+	// Copied now, as the devices go on changing under later scans.
+	devices := make(map[string]*types.Device)
+	for ip, d := range h.store.GetDevices() {
+		copied := *d
+		devices[ip] = &copied
+	}
+	now := time.Now()
+	if client != nil {
+		go func() {
+			if err := client.WriteScan(context.Background(), cidr, result, devices, now); err != nil {
+				slog.Warn("failed to write the scan to InfluxDB", "network", cidr, "error", err)
+			}
+		}()
+	}
+	if zc != nil {
+		go func() {
+			if err := zc.SendScan(context.Background(), cidr, result, devices, now); err != nil {
+				slog.Warn("failed to send the scan to Zabbix", "network", cidr, "error", err)
+			}
+		}()
+	}
+}
+
+// writeDnsmasq rewrites the dnsmasq_file in the [dns] section, if set, with
+// the names of the labelled devices. A failure is logged rather than
+// returned: the scan or edit that called it has been saved all the same.
+func (h *Handler) writeDnsmasq() {
+	cfg := h.cfg.Load()
+	if cfg.DNS.DnsmasqFile == "" {
+		return
+	}
+	records, _ := dnszone.Records(h.store.GetDevices())
+	changed, err := dnszone.WriteFile(cfg.DNS.DnsmasqFile, "dnsmasq", cfg.DNS.Domain, records)
+	if err != nil {
+		slog.Warn("failed to write the dnsmasq file", "path", cfg.DNS.DnsmasqFile, "error", err)
+	} else if changed {
+		slog.Info("wrote the dnsmasq file", "path", cfg.DNS.DnsmasqFile, "names", len(records))
+	}
+}
+
+// scanTimeout returns how long each network of a scan request may take: the
+// timeout parameter, as a duration such as 90s or 15m or a number of seconds,
+// or else scan_timeout from the config.
+func (h *Handler) scanTimeout(r *http.Request) (time.Duration, error) {
+	v := r.URL.Query().Get("timeout")
+	if v == "" {
+		return h.cfg.Load().Scanning.Timeout(), nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		n, nerr := strconv.Atoi(v)
+		if nerr != nil {
+			return 0, errors.New("invalid timeout, use a duration such as 90s or 15m")
+		}
+		d = time.Duration(n) * time.Second
+	}
+	if d <= 0 {
+		return 0, errors.New("timeout must be more than zero")
+	}
+	return d, nil
+}
+
+// extendWriteDeadline gives a request that scans while the client waits long
+// enough to answer. The server's write timeout suits every other request but
+// would cut off a scan allowed to run for minutes.
+func extendWriteDeadline(w http.ResponseWriter, scanTime time.Duration) {
+	// Not every ResponseWriter supports deadlines, and one that does not has
+	// none to extend.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(scanTime + time.Minute))
+}
+
+// recordScanFailure logs a failed scan and records it in the event log. A
+// failure to record it is logged too, as the request has an error of its own
+// to report.
+func (h *Handler) recordScanFailure(cidr, reason string) {
+	slog.Warn("scan failed", "network", cidr, "error", reason)
+	if err := h.store.RecordScanFailure(cidr, reason); err != nil {
+		slog.Error("failed to record scan failure", "network", cidr, "error", err)
+	}
+}
+
+// defaultEventLimit is how many events /api/events returns unless asked for
+// more, which is plenty for the notification panel.
+const defaultEventLimit = 50
+
+// handleEvents handles GET /api/events
+func (h *Handler) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	limit := defaultEventLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			h.error(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	h.success(w, map[string]interface{}{
+		"events": h.store.GetEvents(limit, unreadOnly),
+		"unread": h.store.UnreadEvents(),
+	})
+}
+
+// handleEventsRead handles POST /api/events/read. The body lists the IDs to
+// mark as read; leaving them out marks everything.
+func (h *Handler) handleEventsRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req struct {
+		IDs []int64 `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.error(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	if err := h.store.MarkEventsRead(req.IDs); err != nil {
+		slog.Error("failed to save events", "error", err)
+		h.error(w, http.StatusInternalServerError, "failed to save events")
+		return
+	}
+	h.success(w, map[string]int{"unread": h.store.UnreadEvents()})
+}
+
+// handleSilences handles /api/silences: GET lists the silences in force, or
+// all of them with all=true; POST adds one, for a duration such as 7d; and
+// DELETE?id= ends one early. Who asked is as requester says.
+func (h *Handler) handleSilences(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.success(w, h.store.GetSilences(r.URL.Query().Get("all") == "true"))
+
+	case http.MethodPost:
+		var req struct {
+			Devices  []string `json:"devices"`
+			Groups   []string `json:"groups"`
+			Networks []string `json:"networks"`
+			// All must be set to silence everything, so that a request
+			// that names nothing does not do so by mistake.
+			All      bool   `json:"all"`
+			Duration string `json:"duration"`
+			Reason   string `json:"reason"`
+			By       string `json:"by"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.error(w, http.StatusBadRequest, "invalid JSON")
+			return
+		}
+		if len(req.Devices) == 0 && len(req.Groups) == 0 && len(req.Networks) == 0 && !req.All {
+			h.error(w, http.StatusBadRequest, "devices, groups or networks required, or all to silence everything")
+			return
+		}
+		duration, err := query.ParseAge(req.Duration)
+		if err != nil || duration <= 0 {
+			h.error(w, http.StatusBadRequest, "duration required, such as 2h or 7d")
+			return
+		}
+		now := time.Now()
+		silence, err := h.store.AddSilence(types.Silence{
+			Devices:   req.Devices,
+			Groups:    req.Groups,
+			Networks:  req.Networks,
+			Reason:    req.Reason,
+			CreatedBy: h.requester(r, req.By),
+			Created:   now,
+			Expires:   now.Add(duration),
+		})
+		if err != nil {
+			h.error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.success(w, silence)
+
+	case http.MethodDelete:
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			h.error(w, http.StatusBadRequest, "id parameter required")
+			return
+		}
+		ok, err := h.store.RemoveSilence(id, h.requester(r, r.URL.Query().Get("by")))
+		if err != nil {
+			slog.Error("failed to save silences", "error", err)
+			h.error(w, http.StatusInternalServerError, "failed to save silences")
+			return
+		}
+		if !ok {
+			h.error(w, http.StatusNotFound, "no such silence in force")
+			return
+		}
+		h.success(w, map[string]string{"message": "silence removed"})
+
+	default:
+		h.error(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleEscalations handles GET /api/escalations, the escalations still
+// going, or all of them with all=true.
+func (h *Handler) handleEscalations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	h.success(w, h.store.GetEscalations(r.URL.Query().Get("all") == "true"))
+}
+
+// handleEscalationsAck handles POST /api/escalations/ack, which stops an
+// escalation. Who acknowledged it is as requester says.
+func (h *Handler) handleEscalationsAck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req struct {
+		ID int64  `json:"id"`
+		By string `json:"by"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.error(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	if req.ID == 0 {
+		h.error(w, http.StatusBadRequest, "id required")
+		return
+	}
+	ok, err := h.store.AcknowledgeEscalation(req.ID, h.requester(r, req.By))
+	if err != nil {
+		slog.Error("failed to save escalations", "error", err)
+		h.error(w, http.StatusInternalServerError, "failed to save escalations")
+		return
+	}
+	if !ok {
+		h.error(w, http.StatusNotFound, "no such escalation still going")
+		return
+	}
+	h.success(w, map[string]string{"message": "escalation acknowledged"})
+}
+
+// requester returns who made r, for the record: the signed in user, or else
+// given, the name the request gives, or else "api".
+func (h *Handler) requester(r *http.Request, given string) string {
+	if h.userFor != nil {
+		if name := h.userFor(r); name != "" {
+			return name
+		}
+	}
+	if given != "" {
+		return given
+	}
+	return "api"
+}
+
 // handleTailscale handles GET /api/tailscale
 func (h *Handler) handleTailscale(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -485,6 +1140,83 @@ func (h *Handler) handleTailscale(w http.ResponseWriter, r *http.Request) {
 	h.success(w, status)
 }
 
+// handleTailscalePeers handles GET /api/tailscale/peers
+func (h *Handler) handleTailscalePeers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	h.success(w, network.GetTailscalePeers())
+}
+
+// handleTailscalePromote handles POST /api/tailscale/promote, which adds a
+// tailnet peer to the device list. Scans only record peers that are online at
+// the time, so this is how an offline peer, or one on a tailnet that is never
+// scanned, gets a label and a place in the inventory.
+func (h *Handler) handleTailscalePromote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req struct {
+		IP    string `json:"ip"`
+		Label string `json:"label"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.error(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	if req.IP == "" {
+		h.error(w, http.StatusBadRequest, "ip required")
+		return
+	}
+
+	var peer *types.TailscalePeer
+	for _, p := range network.GetTailscalePeers() {
+		if p.IP == req.IP {
+			peer = &p
+			break
+		}
+	}
+	if peer == nil {
+		h.error(w, http.StatusNotFound, "no tailnet peer has that address")
+		return
+	}
+
+	lastSeen := peer.LastSeen
+	if peer.Online || lastSeen.IsZero() {
+		lastSeen = time.Now()
+	}
+	device := &types.Device{
+		IP:       peer.IP,
+		Hostname: peer.Name,
+		// As for scanned peers, the OS stands in for the hardware vendor.
+		Vendor:   peer.OS,
+		Label:    strings.TrimSpace(req.Label),
+		LastSeen: lastSeen,
+	}
+	if existing := h.store.GetDevice(peer.IP); existing != nil {
+		// Keep the real last sighting of a device the inventory already has.
+		if existing.LastSeen.After(device.LastSeen) {
+			device.LastSeen = existing.LastSeen
+		}
+	} else {
+		device.FirstSeen = time.Now()
+	}
+
+	if err := h.store.UpdateDevice(device); err != nil {
+		slog.Error("failed to save device", "ip", device.IP, "error", err)
+		h.error(w, http.StatusInternalServerError, "failed to save device")
+		return
+	}
+	if device.Label != "" {
+		h.writeDnsmasq()
+	}
+	h.success(w, device)
+}
+
 // handleStats handles GET /api/stats
 func (h *Handler) handleStats(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -503,13 +1235,14 @@ func (h *Handler) handleStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	cfg := h.cfg.Load()
 	status := map[string]interface{}{
 		"server":    "running",
 		"timestamp": time.Now().Format(time.RFC3339),
 		"config": map[string]interface{}{
-			"port":        h.cfg.Server.Port,
-			"bind":        h.cfg.Server.BindAddress,
-			"api_enabled": h.cfg.Server.EnableAPI,
+			"port":        cfg.Server.Port,
+			"bind":        cfg.Server.BindAddress,
+			"api_enabled": cfg.Server.EnableAPI,
 		},
 	}
 	h.success(w, status)
@@ -519,10 +1252,11 @@ func (h *Handler) handleStatus(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) handleSettings(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
+		cfg := h.cfg.Load()
 		settings := map[string]interface{}{
-			"theme":          h.cfg.UI.Theme,
-			"scan_interval":  h.cfg.Scanning.ScanInterval,
-			"retention_days": h.cfg.Storage.RetentionDays,
+			"theme":          cfg.UI.Theme,
+			"scan_interval":  cfg.Scanning.ScanInterval,
+			"retention_days": cfg.Storage.RetentionDays,
 		}
 		h.success(w, settings)
 
@@ -536,6 +1270,17 @@ func (h *Handler) handleSettings(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleConfig handles GET /api/config, which lists every setting the server
+// is running with and where each came from: the only way to see the flags
+// and environment of a server started elsewhere.
+func (h *Handler) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	h.success(w, h.cfg.Load().Effective())
+}
+
 // success sends a successful JSON response
 func (h *Handler) success(w http.ResponseWriter, data interface{}) {
 	resp := types.APIResponse{
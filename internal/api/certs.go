@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// handleCerts handles GET /api/certs, the TLS certificates devices served
+// when last checked, soonest to expire first. expiring=true keeps those
+// within the warning period of [certs] of expiring, or expired.
+func (h *Handler) handleCerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	expiring := r.URL.Query().Get("expiring") == "true"
+	warn := h.cfg.Load().Certs.Warn
+	now := time.Now()
+	result := make([]types.Certificate, 0)
+	for _, certs := range h.store.GetCertificates() {
+		for _, c := range certs {
+			if !expiring || c.Expiry(now, warn) != "" {
+				result = append(result, c)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].NotAfter.Equal(result[j].NotAfter) {
+			return result[i].NotAfter.Before(result[j].NotAfter)
+		}
+		if result[i].IP != result[j].IP {
+			return result[i].IP < result[j].IP
+		}
+		return result[i].Port < result[j].Port
+	})
+	h.success(w, result)
+}
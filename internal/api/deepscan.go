@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/cve"
+	"github.com/291-Group/LAN-Orangutan/internal/scanner"
+)
+
+// deepScanStatus is the state of the latest deep scan started through the
+// API, which runs in the background as a scan job does: looking at every
+// service of a few devices takes minutes.
+type deepScanStatus struct {
+	Running  bool      `json:"running"`
+	IPs      []string  `json:"ips,omitempty"`
+	Started  time.Time `json:"started,omitempty"`
+	Finished time.Time `json:"finished,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// handleVulnScan handles /api/vulnscan. GET returns the state of the latest
+// deep scan, and POST starts one of the devices at ips, if [vulnscan] allows
+// it, with the scripts the config gives. Only one runs at a time; what it
+// finds is at /api/findings.
+func (h *Handler) handleVulnScan(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.deepMu.Lock()
+		status := h.deep
+		h.deepMu.Unlock()
+		h.success(w, status)
+	case http.MethodPost:
+		cfg := h.cfg.Load()
+		if !cfg.VulnScan.Enable {
+			h.error(w, http.StatusForbidden, "deep scans are off; set enable = true in [vulnscan] to start them from the dashboard and API")
+			return
+		}
+		var req struct {
+			IPs []string `json:"ips"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.error(w, http.StatusBadRequest, "invalid JSON")
+			return
+		}
+		if len(req.IPs) == 0 {
+			h.error(w, http.StatusBadRequest, "ips required")
+			return
+		}
+		for _, ip := range req.IPs {
+			if h.store.GetDevice(ip) == nil {
+				h.error(w, http.StatusNotFound, "device not found: "+ip)
+				return
+			}
+		}
+
+		h.deepMu.Lock()
+		defer h.deepMu.Unlock()
+		if h.deep.Running {
+			h.error(w, http.StatusConflict, "a deep scan is already running")
+			return
+		}
+		h.deep = deepScanStatus{Running: true, IPs: req.IPs, Started: time.Now()}
+		opts := scanner.DeepScanOptions{Scripts: cfg.VulnScan.Scripts, Ports: cfg.VulnScan.Ports}
+		go h.deepScan(req.IPs, opts, cfg.VulnScan.TimeoutDuration())
+		h.success(w, h.deep)
+	default:
+		h.error(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// deepScan runs a deep scan of ips in the background and records what it
+// finds, as handleVulnScan started it.
+func (h *Handler) deepScan(ips []string, opts scanner.DeepScanOptions, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var failure string
+	scans, err := h.scanner.Load().DeepScan(ctx, ips, opts)
+	if err != nil {
+		slog.Warn("deep scan failed", "ips", ips, "error", err)
+		failure = err.Error()
+	}
+	for _, scan := range scans {
+		if err := h.store.SetDeepScan(scan); err != nil {
+			slog.Warn("cannot record deep scan", "ip", scan.IP, "error", err)
+			failure = "failed to save findings"
+			continue
+		}
+		slog.Info("deep scan finished", "ip", scan.IP, "services", len(scan.Services), "findings", len(scan.Findings))
+	}
+	if cfg := h.cfg.Load(); cfg.CVE.Enable && len(scans) > 0 {
+		// The scan's own time is spent; the lookup gets as long again.
+		lookupCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := cve.New(cfg.CVEOptions()).Update(lookupCtx, h.store, ips, false); err != nil {
+			slog.Warn("CVE lookup failed", "ips", ips, "error", err)
+			failure = "CVE lookup failed: " + err.Error()
+		}
+	}
+
+	h.deepMu.Lock()
+	defer h.deepMu.Unlock()
+	h.deep.Running = false
+	h.deep.Finished = time.Now()
+	h.deep.Error = failure
+}
+
+// handleFindings handles GET /api/findings, the latest deep scan of each
+// device that has had one by IP, or with ip= of that one device, which is
+// null before its first.
+func (h *Handler) handleFindings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if ip := r.URL.Query().Get("ip"); ip != "" {
+		if h.store.GetDevice(ip) == nil {
+			h.error(w, http.StatusNotFound, "device not found")
+			return
+		}
+		h.success(w, h.store.GetDeepScan(ip))
+		return
+	}
+	h.success(w, h.store.GetDeepScans())
+}
+
+// handleVulnerable handles GET /api/vulnerable, a summary of what the latest
+// deep scan of each device found wrong with it, leaving out those with
+// nothing worse than information, worst first.
+func (h *Handler) handleVulnerable(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	h.success(w, cve.Exposures(h.store.GetDeepScans()))
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// handleFingerprints handles GET /api/fingerprints, the last fingerprint of
+// each device, by address. changed=true keeps those that have ever changed
+// enough to be reported, most recently changed first.
+func (h *Handler) handleFingerprints(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	changed := r.URL.Query().Get("changed") == "true"
+	result := make([]types.Fingerprint, 0)
+	for _, fp := range h.store.GetFingerprints() {
+		if !changed || !fp.Changed.IsZero() {
+			result = append(result, fp)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Changed.Equal(result[j].Changed) {
+			return result[i].Changed.After(result[j].Changed)
+		}
+		return result[i].IP < result[j].IP
+	})
+	h.success(w, result)
+}
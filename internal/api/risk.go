@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/291-Group/LAN-Orangutan/internal/risk"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// handleRisk handles GET /api/risk, the risk score of each device with what
+// it is made of, highest first. min= keeps those scoring at least that, and
+// ip= returns the one device's.
+func (h *Handler) handleRisk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if ip := r.URL.Query().Get("ip"); ip != "" {
+		d := h.store.GetDevice(ip)
+		if d == nil {
+			h.error(w, http.StatusNotFound, "device not found")
+			return
+		}
+		h.success(w, risk.Assess(d, h.store.GetDeepScan(ip)))
+		return
+	}
+	minRisk := 0
+	if v := r.URL.Query().Get("min"); v != "" {
+		var err error
+		if minRisk, err = strconv.Atoi(v); err != nil {
+			h.error(w, http.StatusBadRequest, "min must be a number from 0 to 100")
+			return
+		}
+	}
+	result := make([]risk.Assessment, 0)
+	for _, a := range risk.AssessAll(h.store.GetDevices(), h.store.GetDeepScans()) {
+		if a.Score >= minRisk {
+			result = append(result, a)
+		}
+	}
+	h.success(w, result)
+}
+
+// filterRisk returns the devices whose risk score is at least minRisk.
+func filterRisk(devices map[string]*types.Device, scans map[string]types.DeepScan, minRisk int) map[string]*types.Device {
+	result := make(map[string]*types.Device)
+	for _, a := range risk.AssessAll(devices, scans) {
+		if a.Score >= minRisk {
+			result[a.IP] = devices[a.IP]
+		}
+	}
+	return result
+}
@@ -7,10 +7,6 @@ import (
 	"time"
 )
 
-// scanJobTimeout caps how long a single network may be scanned for before it is
-// abandoned. Large networks are slow, so this is deliberately generous.
-const scanJobTimeout = 10 * time.Minute
-
 // percentUnknown is reported when a network has never been scanned before and
 // there is therefore no timing history to estimate progress from.
 const percentUnknown = -1
@@ -109,9 +105,9 @@ func (j *scanJob) snapshot() scanProgress {
 	return p
 }
 
-// startScanJob begins scanning the given networks in the background. The caller
-// must hold h.jobMu.
-func (h *Handler) startScanJob(networks []string) *scanJob {
+// startScanJob begins scanning the given networks in the background, giving
+// each up to timeout. The caller must hold h.jobMu.
+func (h *Handler) startScanJob(networks []string, timeout time.Duration) *scanJob {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	job := &scanJob{
@@ -123,14 +119,14 @@ func (h *Handler) startScanJob(networks []string) *scanJob {
 		results:   make([]networkScanSummary, 0, len(networks)),
 	}
 
-	go job.run(ctx, h)
+	go job.run(ctx, h, timeout)
 	return job
 }
 
 // run scans each network in turn, recording the outcome of each. A network that
 // is rate limited or fails does not abort the job, so one bad interface cannot
 // hide results from the others.
-func (j *scanJob) run(ctx context.Context, h *Handler) {
+func (j *scanJob) run(ctx context.Context, h *Handler, timeout time.Duration) {
 	for i, cidr := range j.networks {
 		if ctx.Err() != nil {
 			j.finish("cancelled", "")
@@ -142,16 +138,14 @@ func (j *scanJob) run(ctx context.Context, h *Handler) {
 		summary := networkScanSummary{Network: cidr}
 
 		lastScan := h.store.GetLastScan(cidr)
-		if canScan, waitTime := h.scanner.CheckRateLimit(lastScan); !canScan {
+		if canScan, waitTime := h.scanner.Load().CheckRateLimit(lastScan); !canScan {
 			summary.Status = "skipped"
 			summary.Error = "rate limited, wait " + waitTime.Round(time.Second).String()
 			j.addResult(summary, 0)
 			continue
 		}
 
-		scanCtx, cancel := context.WithTimeout(ctx, scanJobTimeout)
-		result, err := h.scanNetwork(scanCtx, cidr)
-		cancel()
+		result, err := h.scanNetwork(ctx, cidr, timeout)
 
 		if err != nil {
 			// A cancelled job surfaces as a scan error, but it is not a failure.
@@ -159,6 +153,7 @@ func (j *scanJob) run(ctx context.Context, h *Handler) {
 				j.finish("cancelled", "")
 				return
 			}
+			h.recordScanFailure(cidr, err.Error())
 			summary.Status = "failed"
 			summary.Error = err.Error()
 			j.addResult(summary, 0)
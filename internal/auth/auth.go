@@ -7,13 +7,30 @@
 //
 // When the server is bound to loopback only, or the operator has explicitly
 // opted out, no password is required at all.
+//
+// A browser session also carries a CSRF token. The session cookie is sent with
+// any request to this host, including one a hostile page on the same network
+// tricks the browser into making, so anything that changes data must also
+// present the token, which only pages served by this app know.
+//
+// Scripts can skip the login form entirely by presenting an API token as a
+// bearer token. That carries no ambient credentials, so it needs no CSRF token.
+//
+// Users can also be kept elsewhere: in a directory, such as an LDAP server,
+// that the login form checks usernames and passwords with, or at an OpenID
+// Connect provider that signs them in. Each such user has a role, from the
+// groups they are in, that limits what they may do. The password and the API
+// token are admin.
 package auth
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
@@ -29,11 +46,21 @@ const (
 	// SessionCookie is the name of the cookie holding the session token.
 	SessionCookie = "orangutan_session"
 
+	// CSRFHeader carries the session's CSRF token on requests made by script.
+	// Plain HTML forms send it as the CSRFField form value instead.
+	CSRFHeader = "X-CSRF-Token"
+	CSRFField  = "csrf_token"
+
 	// These paths are reachable without a session.
 	LoginPath  = "/login"
 	LogoutPath = "/logout"
 	SetupPath  = "/setup"
 
+	// SingleSignOnPath sends the browser to the OpenID Connect provider,
+	// which sends it back to SingleSignOnCallbackPath.
+	SingleSignOnPath         = "/login/sso"
+	SingleSignOnCallbackPath = "/login/sso/callback"
+
 	// MinPasswordLength is the shortest password the setup page accepts.
 	MinPasswordLength = 8
 
@@ -47,6 +74,9 @@ const (
 	maxAttempts    = 5
 	attemptWindow  = 15 * time.Minute
 	sessionIDBytes = 32
+
+	// directoryTimeout bounds checking a login with the directory.
+	directoryTimeout = 15 * time.Second
 )
 
 // Authenticator guards HTTP handlers with a password.
@@ -63,13 +93,41 @@ type Authenticator struct {
 	// to the setup page rather than let straight through.
 	setupRequired bool
 
+	// username, when set, must accompany the password at login. Empty means
+	// the password alone is enough, which is how a single-user install works.
+	username string
+
+	// apiToken, when set, authenticates requests that present it as a bearer
+	// token, for scripts that cannot go through the login form.
+	apiToken string
+
+	// directory, when set, checks usernames and passwords at login, and
+	// roles gives its users and those of single sign-on their roles.
+	directory Directory
+	roles     Roles
+
+	// singleSignOn means users can sign in with an OpenID Connect provider.
+	singleSignOn bool
+
 	// sessionTTL is how long a session stays valid after login.
 	sessionTTL time.Duration
 
-	sessions map[string]time.Time // token -> expiry
+	sessions map[string]*session // token -> session
 	attempts map[string]*attemptRecord
 }
 
+// session is a signed in browser.
+type session struct {
+	expires time.Time
+	// csrf must accompany every request from this session that changes
+	// something.
+	csrf string
+	// user is who signed in, or "" for the password, and role what they may
+	// do.
+	user string
+	role Role
+}
+
 type attemptRecord struct {
 	count int
 	// resets is when the count returns to zero.
@@ -84,7 +142,7 @@ type attemptRecord struct {
 func New(password string, sessionTTL time.Duration) (*Authenticator, error) {
 	a := &Authenticator{
 		sessionTTL: sessionTTL,
-		sessions:   make(map[string]time.Time),
+		sessions:   make(map[string]*session),
 		attempts:   make(map[string]*attemptRecord),
 	}
 
@@ -117,11 +175,80 @@ func isBcryptHash(s string) bool {
 		strings.HasPrefix(s, "$2y$")
 }
 
-// Enabled reports whether a password is currently set.
+// Enabled reports whether signing in is needed: a password is set, or users
+// sign in with a directory or single sign-on.
 func (a *Authenticator) Enabled() bool {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	return len(a.hash) > 0
+	return a.enabledLocked()
+}
+
+func (a *Authenticator) enabledLocked() bool {
+	return len(a.hash) > 0 || a.directory != nil || a.singleSignOn
+}
+
+// SetDirectory has the login form check usernames and passwords with d.
+// The password still signs in, as admin, for when d cannot be reached. nil
+// stops asking a directory.
+func (a *Authenticator) SetDirectory(d Directory) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.directory = d
+}
+
+// SetRoles sets the roles of the users of the directory and single sign-on.
+func (a *Authenticator) SetRoles(roles Roles) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.roles = roles
+}
+
+// SetSingleSignOn records whether users can sign in with an OpenID Connect
+// provider, which signs them in with SignIn.
+func (a *Authenticator) SetSingleSignOn(enabled bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.singleSignOn = enabled
+}
+
+// SingleSignOn reports whether users can sign in with an OpenID Connect
+// provider.
+func (a *Authenticator) SingleSignOn() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.singleSignOn
+}
+
+// PasswordLogin reports whether the login form has anything to check a
+// password with: the password, or a directory.
+func (a *Authenticator) PasswordLogin() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.hash) > 0 || a.directory != nil
+}
+
+// SetUsername requires name to be entered alongside the password at login.
+// An empty name means only the password is asked for.
+func (a *Authenticator) SetUsername(name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.username = strings.TrimSpace(name)
+}
+
+// UsernameRequired reports whether the login form must ask for a username.
+func (a *Authenticator) UsernameRequired() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.username != "" || a.directory != nil
+}
+
+// SetAPIToken sets the token that scripts may present as
+// "Authorization: Bearer <token>" instead of signing in. Empty disables token
+// access.
+func (a *Authenticator) SetAPIToken(token string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.apiToken = strings.TrimSpace(token)
 }
 
 // SetSetupRequired controls what happens when no password is set: either
@@ -132,11 +259,12 @@ func (a *Authenticator) SetSetupRequired(required bool) {
 	a.setupRequired = required
 }
 
-// NeedsSetup reports whether the user still has to create a password.
+// NeedsSetup reports whether the user still has to create a password. Not
+// when users sign in some other way.
 func (a *Authenticator) NeedsSetup() bool {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	return a.setupRequired && len(a.hash) == 0
+	return a.setupRequired && !a.enabledLocked()
 }
 
 // SetPassword establishes a new password and returns its hash so the caller can
@@ -156,7 +284,7 @@ func (a *Authenticator) SetPassword(plaintext string) (string, error) {
 	a.hash = hash
 	// Any session issued while there was no password predates this one and
 	// should not survive it.
-	a.sessions = make(map[string]time.Time)
+	a.sessions = make(map[string]*session)
 
 	return string(hash), nil
 }
@@ -192,7 +320,18 @@ func (a *Authenticator) Middleware(next http.Handler) http.Handler {
 			// No password, and none required. Open access.
 			next.ServeHTTP(w, r)
 
+		case a.bearerAuthenticated(r):
+			next.ServeHTTP(w, r)
+
 		case a.authenticated(r):
+			if isMutating(r.Method) && !a.validCSRF(r) {
+				a.forbid(w, r, "missing or invalid CSRF token")
+				return
+			}
+			if s := a.sessionFor(r); s == nil || !s.role.allows(r.Method, r.URL.Path) {
+				a.forbid(w, r, "your role does not allow this")
+				return
+			}
 			next.ServeHTTP(w, r)
 
 		default:
@@ -217,30 +356,112 @@ func (a *Authenticator) reject(w http.ResponseWriter, r *http.Request, redirectT
 	http.Redirect(w, r, redirectTo, http.StatusSeeOther)
 }
 
-// authenticated reports whether r carries a valid, unexpired session cookie.
-func (a *Authenticator) authenticated(r *http.Request) bool {
+// forbid turns away a signed in request that failed the CSRF check. Unlike
+// reject it does not send the browser to the login page: the session is fine,
+// it is this particular request that cannot be trusted.
+func (a *Authenticator) forbid(w http.ResponseWriter, r *http.Request, reason string) {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]any{
+			"success": false,
+			"error":   reason,
+		})
+		return
+	}
+	http.Error(w, reason, http.StatusForbidden)
+}
+
+// isMutating reports whether a request method can change stored data.
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// sessionFor returns the live session carried by r, or nil.
+func (a *Authenticator) sessionFor(r *http.Request) *session {
 	cookie, err := r.Cookie(SessionCookie)
 	if err != nil || cookie.Value == "" {
-		return false
+		return nil
 	}
 
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	expiry, ok := a.sessions[cookie.Value]
+	s, ok := a.sessions[cookie.Value]
 	if !ok {
-		return false
+		return nil
 	}
-	if time.Now().After(expiry) {
+	if time.Now().After(s.expires) {
 		delete(a.sessions, cookie.Value)
+		return nil
+	}
+	return s
+}
+
+// authenticated reports whether r carries a valid, unexpired session cookie.
+func (a *Authenticator) authenticated(r *http.Request) bool {
+	return a.sessionFor(r) != nil
+}
+
+// bearerAuthenticated reports whether r presents the configured API token.
+func (a *Authenticator) bearerAuthenticated(r *http.Request) bool {
+	a.mu.Lock()
+	want := a.apiToken
+	a.mu.Unlock()
+	if want == "" {
 		return false
 	}
-	return true
+
+	header := r.Header.Get("Authorization")
+	got, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimSpace(got)), []byte(want)) == 1
+}
+
+// validCSRF reports whether r carries the CSRF token of its session.
+func (a *Authenticator) validCSRF(r *http.Request) bool {
+	s := a.sessionFor(r)
+	if s == nil {
+		return false
+	}
+
+	got := r.Header.Get(CSRFHeader)
+	if got == "" {
+		got = r.PostFormValue(CSRFField)
+	}
+	if got == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(s.csrf)) == 1
+}
+
+// CSRFToken returns the CSRF token for the session carried by r, for pages to
+// embed. It is empty when r has no session, which is the case whenever no
+// password is set, and then no token is checked either.
+func (a *Authenticator) CSRFToken(r *http.Request) string {
+	if s := a.sessionFor(r); s != nil {
+		return s.csrf
+	}
+	return ""
 }
 
 // Login verifies password and, on success, returns a new session token.
 // The bool reports whether the attempt succeeded.
+//
+// It is for installs with no username configured; see LoginAs.
 func (a *Authenticator) Login(remoteAddr, password string) (string, bool) {
+	return a.LoginAs(remoteAddr, "", password)
+}
+
+// LoginAs verifies a username and password and, on success, returns a new
+// session token. The username is ignored unless one has been configured.
+func (a *Authenticator) LoginAs(remoteAddr, username, password string) (string, bool) {
 	if !a.Enabled() {
 		return "", false
 	}
@@ -252,9 +473,42 @@ func (a *Authenticator) Login(remoteAddr, password string) (string, bool) {
 
 	a.mu.Lock()
 	hash := append([]byte(nil), a.hash...)
+	wantUser := a.username
+	directory, roles := a.directory, a.roles
 	a.mu.Unlock()
 
-	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil {
+	// The directory is asked first. The password is tried after it, so that
+	// it still signs in when the directory is down.
+	if name := strings.TrimSpace(username); directory != nil && name != "" && password != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), directoryTimeout)
+		groups, ok, err := directory.Authenticate(ctx, name, password)
+		cancel()
+		if err != nil {
+			slog.Warn("could not check the login with the directory", "user", name, "error", err)
+		}
+		if ok {
+			role := roles.For(groups)
+			if role != RoleNone {
+				a.mu.Lock()
+				defer a.mu.Unlock()
+				delete(a.attempts, key)
+				return a.newSessionLocked(name, role)
+			}
+			slog.Warn("login refused: the user is in no group with a role", "user", name, "groups", groups)
+		}
+	}
+	if len(hash) == 0 {
+		a.recordFailure(key)
+		return "", false
+	}
+
+	// Check the password even when the username is wrong, so the response
+	// takes the same time either way and does not reveal which was at fault.
+	passwordOK := bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+	userOK := wantUser == "" ||
+		subtle.ConstantTimeCompare([]byte(strings.TrimSpace(username)), []byte(wantUser)) == 1
+
+	if !passwordOK || !userOK {
 		a.recordFailure(key)
 		return "", false
 	}
@@ -262,29 +516,46 @@ func (a *Authenticator) Login(remoteAddr, password string) (string, bool) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 	delete(a.attempts, key)
-	a.pruneSessionsLocked()
-
-	token, err := newToken()
-	if err != nil {
-		return "", false
-	}
-	a.sessions[token] = time.Now().Add(a.sessionTTL)
-	return token, true
+	return a.newSessionLocked("", RoleAdmin)
 }
 
 // StartSession issues a session without checking a password. It exists so that
 // completing setup signs the user straight in rather than bouncing them to a
 // login form for the password they just chose.
 func (a *Authenticator) StartSession() (string, bool) {
-	token, err := newToken()
-	if err != nil {
-		return "", false
-	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.newSessionLocked("", RoleAdmin)
+}
 
+// SignIn issues a session for user, whom an OpenID Connect provider has
+// signed in, with the role their groups give them. It returns that role, and
+// false when they have none.
+func (a *Authenticator) SignIn(user string, groups []string) (string, Role, bool) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
+	role := a.roles.For(groups)
+	if role == RoleNone {
+		return "", role, false
+	}
+	token, ok := a.newSessionLocked(user, role)
+	return token, role, ok
+}
+
+// newSessionLocked records a new session for user with role and returns its
+// token. Callers must hold a.mu.
+func (a *Authenticator) newSessionLocked(user string, role Role) (string, bool) {
 	a.pruneSessionsLocked()
-	a.sessions[token] = time.Now().Add(a.sessionTTL)
+
+	token, err := newToken()
+	if err != nil {
+		return "", false
+	}
+	csrf, err := newToken()
+	if err != nil {
+		return "", false
+	}
+	a.sessions[token] = &session{expires: time.Now().Add(a.sessionTTL), csrf: csrf, user: user, role: role}
 	return token, true
 }
 
@@ -363,8 +634,8 @@ func (a *Authenticator) recordFailure(key string) {
 // bound on a long-running server. Callers must hold a.mu.
 func (a *Authenticator) pruneSessionsLocked() {
 	now := time.Now()
-	for token, expiry := range a.sessions {
-		if now.After(expiry) {
+	for token, s := range a.sessions {
+		if now.After(s.expires) {
 			delete(a.sessions, token)
 		}
 	}
@@ -399,3 +399,96 @@ func TestSetPasswordRejectsOverlongInput(t *testing.T) {
 		t.Error("a refused password must not become the active one")
 	}
 }
+
+// signedInPost builds a POST to the API carrying a valid session cookie.
+func signedInPost(t *testing.T, a *Authenticator) (*http.Request, string) {
+	t.Helper()
+
+	token, ok := a.Login("192.168.1.5:5000", testPassword)
+	if !ok {
+		t.Fatal("login failed")
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/device", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: SessionCookie, Value: token})
+	return req, token
+}
+
+func TestMutatingRequestNeedsCSRFToken(t *testing.T) {
+	a := newTestAuth(t)
+	req, _ := signedInPost(t, a)
+
+	rec := httptest.NewRecorder()
+	a.Middleware(okHandler()).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("a POST without a CSRF token should be refused, got status %d", rec.Code)
+	}
+}
+
+func TestMutatingRequestWithCSRFTokenIsAllowed(t *testing.T) {
+	a := newTestAuth(t)
+	req, _ := signedInPost(t, a)
+	req.Header.Set(CSRFHeader, a.CSRFToken(req))
+
+	rec := httptest.NewRecorder()
+	a.Middleware(okHandler()).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("a POST with the session's CSRF token should pass, got status %d", rec.Code)
+	}
+}
+
+func TestCSRFTokenFromAnotherSessionIsRejected(t *testing.T) {
+	a := newTestAuth(t)
+	other, _ := signedInPost(t, a)
+	req, _ := signedInPost(t, a)
+	req.Header.Set(CSRFHeader, a.CSRFToken(other))
+
+	rec := httptest.NewRecorder()
+	a.Middleware(okHandler()).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("another session's token must not be accepted, got status %d", rec.Code)
+	}
+}
+
+func TestBearerTokenGrantsAccessWithoutCSRF(t *testing.T) {
+	a := newTestAuth(t)
+	a.SetAPIToken("script-token")
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/device?ip=10.0.0.1", nil)
+	req.Header.Set("Authorization", "Bearer script-token")
+	rec := httptest.NewRecorder()
+	a.Middleware(okHandler()).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("the API token should authenticate on its own, got status %d", rec.Code)
+	}
+}
+
+func TestWrongBearerTokenIsRejected(t *testing.T) {
+	a := newTestAuth(t)
+	a.SetAPIToken("script-token")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/devices", nil)
+	req.Header.Set("Authorization", "Bearer guess")
+	rec := httptest.NewRecorder()
+	a.Middleware(okHandler()).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("a wrong token should be refused, got status %d", rec.Code)
+	}
+}
+
+func TestUsernameIsCheckedWhenConfigured(t *testing.T) {
+	a := newTestAuth(t)
+	a.SetUsername("admin")
+
+	if _, ok := a.LoginAs("192.168.1.5:5000", "someone", testPassword); ok {
+		t.Error("the wrong username should not sign in, even with the right password")
+	}
+	if _, ok := a.LoginAs("192.168.1.5:5000", "admin", testPassword); !ok {
+		t.Error("the right username and password should sign in")
+	}
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Role is what a signed in user may do.
+type Role int
+
+// Roles, each allowed everything the one before it is.
+const (
+	// RoleNone may do nothing; it is the role of a user in none of the
+	// groups given roles, when there is no default role.
+	RoleNone Role = iota
+	// RoleViewer may look but change nothing.
+	RoleViewer
+	// RoleEditor may also label, group, delete and scan devices.
+	RoleEditor
+	// RoleAdmin may also see the server's settings. The password and the API
+	// token sign in as admin.
+	RoleAdmin
+)
+
+// RoleNames are the names of the roles, as the config file gives them.
+var RoleNames = []string{"none", "viewer", "editor", "admin"}
+
+func (r Role) String() string {
+	if r < 0 || int(r) >= len(RoleNames) {
+		return fmt.Sprintf("Role(%d)", int(r))
+	}
+	return RoleNames[r]
+}
+
+// ParseRole returns the role named name.
+func ParseRole(name string) (Role, error) {
+	for i, n := range RoleNames {
+		if strings.EqualFold(strings.TrimSpace(name), n) {
+			return Role(i), nil
+		}
+	}
+	return RoleNone, fmt.Errorf("%q is not none, viewer, editor or admin", name)
+}
+
+// pathRoles are the roles paths need whatever the method. The settings page
+// and the server's configuration are for admins. The API routes that act,
+// rather than read, need an editor even when asked with GET: a GET of
+// /api/scan scans. Marking events read only changes what the reader has
+// seen, so a viewer may POST it. Any other path needs an editor to change
+// something and a viewer to look.
+var pathRoles = map[string]Role{
+	"/settings":      RoleAdmin,
+	"/settings.html": RoleAdmin,
+	"/api/config":    RoleAdmin,
+	"/api/settings":  RoleAdmin,
+
+	"/api/scan":              RoleEditor,
+	"/api/scan/start":        RoleEditor,
+	"/api/scan/cancel":       RoleEditor,
+	"/api/devices/batch":     RoleEditor,
+	"/api/device/wake":       RoleEditor,
+	"/api/escalations/ack":   RoleEditor,
+	"/api/tailscale/promote": RoleEditor,
+
+	"/api/events/read": RoleViewer,
+}
+
+// allows reports whether r may make a request with method for path.
+func (r Role) allows(method, path string) bool {
+	if need, ok := pathRoles[strings.TrimSuffix(path, "/")]; ok {
+		return r >= need
+	}
+	if isMutating(method) {
+		return r >= RoleEditor
+	}
+	return r >= RoleViewer
+}
+
+// Roles says which role users get from the groups they are in, as a
+// directory or identity provider reports them.
+type Roles struct {
+	Admin  []string
+	Editor []string
+	Viewer []string
+	// Default is the role of users in none of the groups. RoleNone turns
+	// them away.
+	Default Role
+}
+
+// For returns the highest role any of groups gives, or the default.
+func (r Roles) For(groups []string) Role {
+	for _, level := range []struct {
+		role   Role
+		groups []string
+	}{{RoleAdmin, r.Admin}, {RoleEditor, r.Editor}, {RoleViewer, r.Viewer}} {
+		for _, want := range level.groups {
+			for _, g := range groups {
+				if sameGroup(g, want) {
+					return level.role
+				}
+			}
+		}
+	}
+	return r.Default
+}
+
+// sameGroup reports whether the group a user is in is want. Directories
+// name groups by their DN, such as cn=admins,ou=groups,dc=example,dc=com,
+// which matches either in full or by its first value, admins.
+func sameGroup(group, want string) bool {
+	group, want = strings.TrimSpace(group), strings.TrimSpace(want)
+	if want == "" {
+		return false
+	}
+	if strings.EqualFold(group, want) {
+		return true
+	}
+	first, _, isDN := strings.Cut(group, ",")
+	if _, value, ok := strings.Cut(first, "="); ok && isDN {
+		return strings.EqualFold(strings.TrimSpace(value), want)
+	}
+	return false
+}
+
+// Directory checks usernames and passwords against somewhere users are
+// kept, such as an LDAP server, and returns the groups the user is in. A
+// wrong username or password gives ok false and no error; err is for a
+// directory that could not be asked.
+type Directory interface {
+	Authenticate(ctx context.Context, username, password string) (groups []string, ok bool, err error)
+}
+
+// User is who made a request, as UserFor reports it.
+type User struct {
+	// Name is the username, or "" for the password, the API token or
+	// open access.
+	Name string
+	Role Role
+}
+
+// UserFor returns who made r and their role. With no password or provider
+// set up everyone is admin, as they may do anything.
+func (a *Authenticator) UserFor(r *http.Request) User {
+	if !a.Enabled() {
+		return User{Role: RoleAdmin}
+	}
+	if a.bearerAuthenticated(r) {
+		return User{Role: RoleAdmin}
+	}
+	if s := a.sessionFor(r); s != nil {
+		return User{Name: s.user, Role: s.role}
+	}
+	return User{}
+}
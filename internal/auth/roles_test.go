@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeDirectory knows users by password, and the groups of each.
+type fakeDirectory struct {
+	passwords map[string]string
+	groups    map[string][]string
+	err       error
+}
+
+func (d fakeDirectory) Authenticate(ctx context.Context, username, password string) ([]string, bool, error) {
+	if d.err != nil {
+		return nil, false, d.err
+	}
+	if want, ok := d.passwords[username]; !ok || want != password {
+		return nil, false, nil
+	}
+	return d.groups[username], true, nil
+}
+
+var testRoles = Roles{
+	Admin:  []string{"admins"},
+	Editor: []string{"cn=it,ou=groups,dc=example,dc=com"},
+	Viewer: []string{"staff"},
+}
+
+func TestRolesFor(t *testing.T) {
+	for _, tt := range []struct {
+		groups []string
+		want   Role
+	}{
+		{[]string{"cn=admins,ou=groups,dc=example,dc=com"}, RoleAdmin},
+		{[]string{"staff", "admins"}, RoleAdmin},
+		{[]string{"CN=IT,OU=Groups,DC=example,DC=com"}, RoleEditor},
+		{[]string{"cn=it,ou=other,dc=example,dc=com"}, RoleNone},
+		{[]string{"Staff"}, RoleViewer},
+		{nil, RoleNone},
+	} {
+		if got := testRoles.For(tt.groups); got != tt.want {
+			t.Errorf("For(%q) = %v; want %v", tt.groups, got, tt.want)
+		}
+	}
+	withDefault := testRoles
+	withDefault.Default = RoleViewer
+	if got := withDefault.For([]string{"visitors"}); got != RoleViewer {
+		t.Errorf("For a group with no role = %v; want the default, viewer", got)
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	for _, name := range RoleNames {
+		r, err := ParseRole(name)
+		if err != nil || r.String() != name {
+			t.Errorf("ParseRole(%q) = %v, %v", name, r, err)
+		}
+	}
+	if _, err := ParseRole("owner"); err == nil {
+		t.Error("ParseRole accepted a role that does not exist")
+	}
+}
+
+func newDirectoryAuth(t *testing.T, d Directory) *Authenticator {
+	t.Helper()
+	a := newTestAuth(t)
+	a.SetDirectory(d)
+	a.SetRoles(testRoles)
+	return a
+}
+
+// request returns a request for method and path in the session token, with
+// its CSRF token.
+func request(a *Authenticator, token, method, path string) *http.Request {
+	req := httptest.NewRequest(method, path, nil)
+	req.AddCookie(&http.Cookie{Name: SessionCookie, Value: token})
+	req.Header.Set(CSRFHeader, a.CSRFToken(req))
+	return req
+}
+
+func TestRolesLimitRequests(t *testing.T) {
+	a := newDirectoryAuth(t, fakeDirectory{
+		passwords: map[string]string{"vera": "viewer-pw", "eddie": "editor-pw", "ada": "admin-pw"},
+		groups: map[string][]string{
+			"vera":  {"staff"},
+			"eddie": {"cn=it,ou=groups,dc=example,dc=com"},
+			"ada":   {"admins"},
+		},
+	})
+
+	for _, tt := range []struct {
+		user, password string
+		method, path   string
+		want           int
+	}{
+		{"vera", "viewer-pw", http.MethodGet, "/api/devices", http.StatusOK},
+		{"vera", "viewer-pw", http.MethodPost, "/api/scan", http.StatusForbidden},
+		{"vera", "viewer-pw", http.MethodGet, "/api/scan", http.StatusForbidden},
+		{"vera", "viewer-pw", http.MethodGet, "/api/silences", http.StatusOK},
+		{"vera", "viewer-pw", http.MethodPost, "/api/silences", http.StatusForbidden},
+		{"vera", "viewer-pw", http.MethodPost, "/api/events/read", http.StatusOK},
+		{"eddie", "editor-pw", http.MethodGet, "/api/scan", http.StatusOK},
+		{"eddie", "editor-pw", http.MethodPost, "/api/scan", http.StatusOK},
+		{"eddie", "editor-pw", http.MethodGet, "/settings", http.StatusForbidden},
+		{"eddie", "editor-pw", http.MethodGet, "/api/config", http.StatusForbidden},
+		{"ada", "admin-pw", http.MethodGet, "/settings", http.StatusOK},
+	} {
+		token, ok := a.LoginAs("192.168.1.5:5000", tt.user, tt.password)
+		if !ok {
+			t.Fatalf("%s could not sign in", tt.user)
+		}
+		if got := a.UserFor(request(a, token, http.MethodGet, "/")); got.Name != tt.user {
+			t.Errorf("UserFor = %+v; want %s", got, tt.user)
+		}
+		rec := httptest.NewRecorder()
+		a.Middleware(okHandler()).ServeHTTP(rec, request(a, token, tt.method, tt.path))
+		if rec.Code != tt.want {
+			t.Errorf("%s %s %s: status %d; want %d", tt.user, tt.method, tt.path, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestDirectoryLoginWithoutRoleIsRefused(t *testing.T) {
+	a := newDirectoryAuth(t, fakeDirectory{passwords: map[string]string{"guest": "guest-pw"}})
+	if _, ok := a.LoginAs("192.168.1.5:5000", "guest", "guest-pw"); ok {
+		t.Error("a user in no group with a role should not sign in")
+	}
+}
+
+func TestPasswordWorksWhenDirectoryIsDown(t *testing.T) {
+	a := newDirectoryAuth(t, fakeDirectory{err: errors.New("connection refused")})
+	token, ok := a.LoginAs("192.168.1.5:5000", "anyone", testPassword)
+	if !ok {
+		t.Fatal("the password should still sign in when the directory cannot be reached")
+	}
+	if got := a.UserFor(request(a, token, http.MethodGet, "/")); got.Role != RoleAdmin {
+		t.Errorf("the password signed in as %v; want admin", got.Role)
+	}
+}
+
+func TestSignIn(t *testing.T) {
+	a, err := New("", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.SetSingleSignOn(true)
+	a.SetRoles(testRoles)
+	if !a.Enabled() || a.PasswordLogin() || a.NeedsSetup() {
+		t.Fatalf("with only single sign-on: Enabled %v, PasswordLogin %v, NeedsSetup %v", a.Enabled(), a.PasswordLogin(), a.NeedsSetup())
+	}
+
+	if _, _, ok := a.SignIn("mallory", []string{"visitors"}); ok {
+		t.Error("a user in no group with a role should not sign in")
+	}
+	token, role, ok := a.SignIn("vera", []string{"staff"})
+	if !ok || role != RoleViewer {
+		t.Fatalf("SignIn = %v, %v; want viewer", role, ok)
+	}
+	rec := httptest.NewRecorder()
+	a.Middleware(okHandler()).ServeHTTP(rec, request(a, token, http.MethodGet, "/api/devices"))
+	if rec.Code != http.StatusOK {
+		t.Errorf("a viewer reading devices got status %d", rec.Code)
+	}
+}
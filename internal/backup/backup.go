@@ -0,0 +1,156 @@
+// Package backup writes and reads the single-file archives made by
+// `orangutan backup`: a gzipped tar of the data files and config, with a
+// manifest saying where and when it was made.
+//
+// The format is a plain tar.gz so a backup can be inspected or picked apart
+// with standard tools when this program is not at hand.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ManifestName is the archive entry holding the Manifest. It is written
+// first so that listing an archive shows what it is straight away.
+const ManifestName = "manifest.json"
+
+// maxFileSize bounds each file read from an archive. The data files of a
+// large network run to a few megabytes; anything near this is not a backup.
+const maxFileSize = 256 << 20
+
+// Manifest describes a backup.
+type Manifest struct {
+	// Version is the version of LAN Orangutan that made the backup.
+	Version  string    `json:"version"`
+	Created  time.Time `json:"created"`
+	Hostname string    `json:"hostname"`
+	// Files lists the other entries of the archive.
+	Files []string `json:"files"`
+}
+
+// Archive is the content of a backup.
+type Archive struct {
+	Manifest Manifest
+	// Files maps each entry's name, such as "data/devices.json", to its
+	// content.
+	Files map[string][]byte
+}
+
+// Write writes files to w as a backup archive. The manifest's file list is
+// filled in from files, in sorted order so the same data always gives the
+// same archive layout.
+func Write(w io.Writer, m Manifest, files map[string][]byte) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if err := checkName(name); err != nil {
+			return err
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	m.Files = names
+
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	write := func(name string, data []byte) error {
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    0o600,
+			Size:    int64(len(data)),
+			ModTime: m.Created,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	if err := write(ManifestName, manifest); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := write(name, files[name]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Read reads a backup archive. It fails on an archive with no manifest, and
+// on entries whose names could point outside the directory they are restored
+// to.
+func Read(r io.Reader) (*Archive, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a backup archive: %w", err)
+	}
+	defer gz.Close()
+
+	a := &Archive{Files: make(map[string][]byte)}
+	haveManifest := false
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("not a backup archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if hdr.Size > maxFileSize {
+			return nil, fmt.Errorf("%s is too large to be part of a backup", hdr.Name)
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, maxFileSize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", hdr.Name, err)
+		}
+
+		if hdr.Name == ManifestName {
+			if err := json.Unmarshal(data, &a.Manifest); err != nil {
+				return nil, fmt.Errorf("invalid manifest: %w", err)
+			}
+			haveManifest = true
+			continue
+		}
+		if err := checkName(hdr.Name); err != nil {
+			return nil, err
+		}
+		a.Files[hdr.Name] = data
+	}
+
+	if !haveManifest {
+		return nil, fmt.Errorf("not a backup archive: no %s", ManifestName)
+	}
+	return a, nil
+}
+
+// checkName rejects entry names that are absolute or climb out of the
+// archive, which a tampered archive could use to overwrite any file.
+func checkName(name string) error {
+	if name == "" || name == ManifestName || strings.Contains(name, `\`) ||
+		path.IsAbs(name) || path.Clean(name) != name || strings.HasPrefix(name, "../") || name == ".." {
+		return fmt.Errorf("invalid file name in backup: %q", name)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteThenRead(t *testing.T) {
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	files := map[string][]byte{
+		"data/devices.json": []byte(`{"192.168.1.1":{"ip":"192.168.1.1"}}`),
+		"config.ini":        []byte("[server]\nport = 291\n"),
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, Manifest{Version: "3.2.0", Created: created, Hostname: "nas"}, files); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	a, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if a.Manifest.Version != "3.2.0" || a.Manifest.Hostname != "nas" || !a.Manifest.Created.Equal(created) {
+		t.Errorf("manifest = %+v", a.Manifest)
+	}
+	if got := strings.Join(a.Manifest.Files, ","); got != "config.ini,data/devices.json" {
+		t.Errorf("manifest files = %s, want them sorted", got)
+	}
+	if len(a.Files) != 2 {
+		t.Fatalf("read %d files, want 2", len(a.Files))
+	}
+	for name, want := range files {
+		if !bytes.Equal(a.Files[name], want) {
+			t.Errorf("%s = %q, want %q", name, a.Files[name], want)
+		}
+	}
+}
+
+func TestWriteRejectsUnsafeNames(t *testing.T) {
+	for _, name := range []string{"../etc/passwd", "/etc/passwd", "data/../../x", ManifestName, ""} {
+		var buf bytes.Buffer
+		if err := Write(&buf, Manifest{}, map[string][]byte{name: nil}); err == nil {
+			t.Errorf("Write accepted %q", name)
+		}
+	}
+}
+
+// rawArchive builds a tar.gz by hand, for archives Write would refuse to make.
+func rawArchive(t *testing.T, files map[string]string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, body := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(body))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestReadRejectsPathTraversal(t *testing.T) {
+	buf := rawArchive(t, map[string]string{
+		ManifestName:        `{"version":"1"}`,
+		"../../.bashrc":     "oops",
+		"data/devices.json": "{}",
+	})
+	if _, err := Read(buf); err == nil {
+		t.Fatal("Read accepted an entry outside the archive")
+	}
+}
+
+func TestReadNeedsManifest(t *testing.T) {
+	buf := rawArchive(t, map[string]string{"data/devices.json": "{}"})
+	if _, err := Read(buf); err == nil {
+		t.Fatal("Read accepted an archive with no manifest")
+	}
+}
+
+func TestReadRejectsOtherFiles(t *testing.T) {
+	if _, err := Read(strings.NewReader("IP,MAC\n")); err == nil {
+		t.Fatal("Read accepted a CSV file")
+	}
+}
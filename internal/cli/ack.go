@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var (
+	ackAll bool
+	ackBy  string
+)
+
+var ackCmd = &cobra.Command{
+	Use:   "ack [id...]",
+	Short: "Acknowledge escalating alerts",
+	Long: `An alert rule with escalate = NAME goes on to the next step of the
+[escalation "NAME"] section, telling more people, until someone acknowledges
+it. With no arguments, list the escalations still going; give their IDs, which
+the alerts also say, to acknowledge them. The dashboard's notifications can
+acknowledge them too.
+
+  orangutan ack
+  orangutan ack 12
+  orangutan ack --all     # Every escalation, with who acknowledged each`,
+	RunE: runAck,
+}
+
+func init() {
+	ackCmd.Flags().BoolVar(&ackAll, "all", false, "List every escalation, including those that have stopped")
+	ackCmd.Flags().StringVar(&ackBy, "by", currentUser(), "Who is acknowledging, for the record")
+}
+
+func runAck(cmd *cobra.Command, args []string) error {
+	if ackAll && len(args) > 0 {
+		return fmt.Errorf("--all lists escalations; give IDs without it to acknowledge them")
+	}
+	var ids []int64
+	for _, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not an escalation ID; 'orangutan ack' lists them", arg)
+		}
+		ids = append(ids, id)
+	}
+
+	c, err := remoteClient()
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		ctx, cancel := remoteContext()
+		defer cancel()
+		if len(ids) == 0 {
+			escalations, err := c.Escalations(ctx, ackAll)
+			if err != nil {
+				return err
+			}
+			return printEscalations(escalations)
+		}
+		for _, id := range ids {
+			if err := c.AcknowledgeEscalation(ctx, id, ackBy); err != nil {
+				return fmt.Errorf("escalation %d: %w", id, err)
+			}
+			fmt.Printf("Acknowledged escalation %d\n", id)
+		}
+		return nil
+	}
+
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return printEscalations(store.GetEscalations(ackAll))
+	}
+	for _, id := range ids {
+		ok, err := store.AcknowledgeEscalation(id, ackBy)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("there is no escalation %d still going", id)
+		}
+		fmt.Printf("Acknowledged escalation %d\n", id)
+	}
+	return nil
+}
+
+// printEscalations lists escalations with how far each has gone.
+func printEscalations(escalations []types.Escalation) error {
+	if len(escalations) == 0 {
+		fmt.Println("No escalations waiting for acknowledgement")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tALERT\tSTARTED\tPOLICY\tSTEPS SENT\tSTATUS")
+	for _, e := range escalations {
+		status := "waiting"
+		switch {
+		case !e.Acknowledged.IsZero():
+			status = fmt.Sprintf("acknowledged by %s after %s", e.AcknowledgedBy, formatDuration(e.Acknowledged.Sub(e.Started)))
+		case !e.Resolved.IsZero():
+			status = "resolved by itself"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%s\n", e.ID, truncate(e.Event.Message, 40), e.Started.Local().Format("2006-01-02 15:04"), e.Policy, e.Steps, status)
+	}
+	return w.Flush()
+}
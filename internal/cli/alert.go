@@ -0,0 +1,210 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/alert"
+	"github.com/291-Group/LAN-Orangutan/internal/config"
+	"github.com/291-Group/LAN-Orangutan/internal/storage"
+)
+
+var notifyDigest bool
+
+var notifyCmd = &cobra.Command{
+	Use:   "notify NAME [MESSAGE]",
+	Short: "Send a test message with a notifier",
+	Long: `Send a message with the notifier of a [notify "NAME"] section, to check
+that alerts will get through before one is needed. Without MESSAGE a test
+message is sent; with --digest, the digest the section asks for is sent now,
+covering the period up to now.
+
+The server and monitor send alerts and digests themselves, as the [alert]
+and [notify] sections say.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runNotify,
+}
+
+func init() {
+	notifyCmd.Flags().BoolVar(&notifyDigest, "digest", false, "Send the notifier's digest now")
+}
+
+func runNotify(cmd *cobra.Command, args []string) error {
+	name := strings.ToLower(args[0])
+	n := cfg.Notify[name]
+	if n == nil {
+		names := make([]string, 0, len(cfg.Notify))
+		for name := range cfg.Notify {
+			names = append(names, name)
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("no notifier %q: the config has no [notify] sections", name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("no notifier %q; the config has %s", name, strings.Join(names, ", "))
+	}
+	notifier, err := alert.NewNotifier(n.Options())
+	if err != nil {
+		return fmt.Errorf("notifier %q: %w", name, err)
+	}
+	if notifyDigest {
+		if len(args) == 2 {
+			return fmt.Errorf("--digest takes no MESSAGE")
+		}
+		return sendDigestNow(cmd, name, n, notifier)
+	}
+	text := "Test alert from LAN Orangutan"
+	if host, err := os.Hostname(); err == nil {
+		text += " on " + host
+	}
+	if len(args) == 2 {
+		text = args[1]
+	}
+	if err := notifier.Notify(cmd.Context(), alert.Notification{Title: "LAN Orangutan", Text: text}); err != nil {
+		return fmt.Errorf("notifier %q: %w", name, err)
+	}
+	fmt.Printf("Sent with %s.\n", name)
+	return nil
+}
+
+// sendDigestNow sends the notifier name the digest its section asks for, or
+// a daily one when it asks for none, covering the period up to now.
+func sendDigestNow(cmd *cobra.Command, name string, n *config.NotifyConfig, notifier alert.Notifier) error {
+	schedule := n.Digest
+	if schedule == "" {
+		schedule = "daily"
+	}
+	sched, err := alert.DigestSchedule(schedule)
+	if err != nil {
+		return fmt.Errorf("notifier %q: digest: %w", name, err)
+	}
+	tmpl, err := alert.DigestTemplate(n.DigestTemplate)
+	if err != nil {
+		return fmt.Errorf("notifier %q: digest_template: %w", name, err)
+	}
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	title := alert.DigestTitle(schedule)
+	text, err := alert.BuildDigest(store, title, now.Add(-alert.DigestPeriod(sched, now)), now).Render(tmpl)
+	if err != nil {
+		return fmt.Errorf("notifier %q: digest: %w", name, err)
+	}
+	if err := notifier.Notify(cmd.Context(), alert.Notification{Title: title, Text: text}); err != nil {
+		return fmt.Errorf("notifier %q: %w", name, err)
+	}
+	fmt.Printf("Sent the digest with %s.\n", name)
+	return nil
+}
+
+// alerter sends the alerts and digests, and has the Telegram bots that take
+// commands answer them, as the config says.
+type alerter struct {
+	ctx   context.Context
+	store *storage.Storage
+
+	mu     sync.Mutex
+	engine *alert.Engine
+	// stop stops the digests and command listeners of the notifiers in use.
+	stop context.CancelFunc
+}
+
+// startAlerts sends alerts about the events recorded in store, as the
+// [alert] sections say, with the notifiers of the [notify] sections, until
+// ctx is done. It also sends the notifiers' digests and has the Telegram bots
+// that take commands answer them. Nothing is sent while there are no
+// notifiers; reload can add them.
+func startAlerts(ctx context.Context, store *storage.Storage) *alerter {
+	a := &alerter{ctx: ctx, store: store}
+	a.reload(cfg)
+	return a
+}
+
+// reload alerts as c says from now on: the notifiers, rules, maintenance
+// windows and escalation policies are replaced in the running engine, and
+// the digests and command listeners of the notifiers before are stopped and
+// those of c started. When c's rules cannot be used the ones before stay.
+func (a *alerter) reload(c *config.Config) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(c.Notify) == 0 && a.engine == nil {
+		return
+	}
+	notifiers := make(map[string]alert.Notifier, len(c.Notify))
+	all := make(map[string]alert.Notifier, len(c.Notify))
+	for name, n := range c.Notify {
+		notifier, err := alert.NewNotifier(n.Options())
+		if err != nil {
+			// Validate has reported it; the other notifiers still work.
+			slog.Warn("notifier not used", "notifier", name, "error", err)
+			continue
+		}
+		all[name] = notifier
+		if n.Alerts {
+			notifiers[name] = notifier
+		}
+	}
+	var rules []alert.Rule
+	for name, r := range c.Alert {
+		rules = append(rules, r.Rule(name))
+	}
+	engine, err := alert.New(rules, notifiers)
+	if err == nil {
+		engine.SetWindows(c.Windows())
+		err = engine.SetPolicies(c.Policies())
+	}
+	if err != nil {
+		if a.engine == nil {
+			slog.Warn("alerts not sent", "error", err)
+		} else {
+			slog.Warn("alerts still sent as before", "error", err)
+		}
+		return
+	}
+
+	if a.stop != nil {
+		a.stop()
+	}
+	ctx, stop := context.WithCancel(a.ctx)
+	a.stop = stop
+	for name, notifier := range all {
+		n := c.Notify[name]
+		if tg, ok := notifier.(*alert.Telegram); ok && n.Commands {
+			go tg.Listen(ctx, a.store)
+		}
+		if n.Digest != "" {
+			startDigest(ctx, a.store, name, n, notifier)
+		}
+	}
+	if a.engine == nil {
+		a.engine = engine
+		go engine.Run(a.ctx, a.store)
+		return
+	}
+	a.engine.Replace(engine)
+}
+
+// startDigest sends the notifier name the digest its section asks for.
+func startDigest(ctx context.Context, store *storage.Storage, name string, n *config.NotifyConfig, notifier alert.Notifier) {
+	sched, err := alert.DigestSchedule(n.Digest)
+	if err != nil {
+		slog.Warn("digest not sent", "notifier", name, "error", err)
+		return
+	}
+	tmpl, err := alert.DigestTemplate(n.DigestTemplate)
+	if err != nil {
+		slog.Warn("digest not sent", "notifier", name, "error", err)
+		return
+	}
+	go alert.RunDigest(ctx, notifier, n.Digest, sched, tmpl, store)
+}
@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/scanner"
+	"github.com/291-Group/LAN-Orangutan/internal/storage"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var approveAll bool
+
+var approveCmd = &cobra.Command{
+	Use:   "approve [<ip|mac|label|hostname>...]",
+	Short: "Review and approve new devices",
+	Long: `Devices that scans find for the first time wait for approval, so anything
+that joins the network stands out until someone says it is known. With no
+arguments, list the devices waiting, newest first; name devices to approve
+them, or pass --all to approve every one.
+
+  orangutan approve
+  orangutan approve 192.168.1.77 "Kitchen tablet"
+  orangutan approve --all
+
+A device that should not be there can be taken out with 'orangutan delete'.
+Devices can be approved as they are found by vendor or group with
+auto_approve_vendors and auto_approve_groups in [approval], and enable = false
+there turns approval off.`,
+	RunE: runApprove,
+}
+
+func init() {
+	approveCmd.Flags().BoolVar(&approveAll, "all", false, "Approve every device waiting")
+}
+
+func runApprove(cmd *cobra.Command, args []string) error {
+	if approveAll && len(args) > 0 {
+		return fmt.Errorf("name devices or pass --all, not both")
+	}
+	devices, err := loadDevices(cmd)
+	if err != nil {
+		return err
+	}
+	pending := storage.Pending(devices)
+
+	if !approveAll && len(args) == 0 {
+		if len(pending) == 0 {
+			fmt.Println("No devices waiting for approval")
+			return nil
+		}
+		return printPending(pending)
+	}
+
+	var ips []string
+	if approveAll {
+		for _, d := range pending {
+			ips = append(ips, d.IP)
+		}
+	} else {
+		// Every name is looked up before anything is approved, so a typo in
+		// the last one does not leave the rest half done.
+		for _, key := range args {
+			d, err := storage.Find(devices, key)
+			if err != nil {
+				return err
+			}
+			if !d.Pending {
+				fmt.Printf("%s is already approved\n", deviceDisplayName(d))
+				continue
+			}
+			ips = append(ips, d.IP)
+		}
+	}
+	if len(ips) == 0 {
+		return nil
+	}
+
+	c, err := remoteClient()
+	if err != nil {
+		return err
+	}
+	var n int
+	if c != nil {
+		ctx, cancel := remoteContext()
+		defer cancel()
+		n, err = c.Batch(ctx, ips, "approve", "")
+	} else {
+		store, serr := openStore(cmd)
+		if serr != nil {
+			return serr
+		}
+		n, err = store.ApproveDevices(ips)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to approve devices: %w", err)
+	}
+	fmt.Printf("Approved %d devices\n", n)
+	return nil
+}
+
+// printPending lists the devices waiting for approval.
+func printPending(pending []*types.Device) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IP\tMAC\tNAME\tVENDOR\tFIRST SEEN\tSTATUS")
+	fmt.Fprintln(w, "--\t---\t----\t------\t----------\t------")
+	for _, d := range pending {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			d.IP, dash(d.MAC), truncate(deviceDisplayName(d), 25), truncate(dash(scanner.ResolveVendor(d.Vendor, d.MAC)), 25),
+			d.FirstSeen.Format("2006-01-02 15:04"), deviceStatus(d))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d devices waiting for approval\n", len(pending))
+	return nil
+}
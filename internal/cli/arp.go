@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/network"
+	"github.com/291-Group/LAN-Orangutan/internal/scanner"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var arpUnknown bool
+
+var arpCmd = &cobra.Command{
+	Use:   "arp",
+	Short: "Show the system's ARP/neighbor table",
+	Long: `Print the hosts the operating system already knows are on the local
+network, from its ARP (IPv4) and neighbor (IPv6) table, alongside the names
+you have given them. No scan is run and no privileges are needed, so this is
+a quick check of what is around.
+
+Entries that match a stored device by address, or by MAC address when the IP
+has changed, show its label or hostname.`,
+	Args: cobra.NoArgs,
+	RunE: runARP,
+}
+
+func init() {
+	arpCmd.Flags().BoolVar(&arpUnknown, "unknown", false, "Only show hosts that are not stored devices")
+}
+
+func runARP(cmd *cobra.Command, args []string) error {
+	neighbors, err := network.Neighbors()
+	if err != nil {
+		return err
+	}
+
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+	devices := store.GetDevices()
+	byMAC := make(map[string]*types.Device, len(devices))
+	for _, d := range devices {
+		if d.MAC != "" {
+			byMAC[strings.ToLower(d.MAC)] = d
+		}
+	}
+
+	sort.SliceStable(neighbors, func(i, j int) bool {
+		return types.LessIP(neighbors[i].IP, neighbors[j].IP)
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IP\tMAC\tINTERFACE\tSTATE\tVENDOR\tDEVICE")
+	fmt.Fprintln(w, "--\t---\t---------\t-----\t------\t------")
+	shown := 0
+	for _, n := range neighbors {
+		name := ""
+		if d, ok := devices[n.IP]; ok {
+			name = deviceDisplayName(d)
+		} else if d, ok := byMAC[n.MAC]; ok {
+			// Same hardware at a new address: DHCP handed it a different lease.
+			name = fmt.Sprintf("%s (stored as %s)", deviceDisplayName(d), d.IP)
+		}
+		if arpUnknown && name != "" {
+			continue
+		}
+		if name == "" {
+			name = "(not stored)"
+		}
+
+		vendor := scanner.GetMACVendor(n.MAC)
+		if vendor == "Unknown" && scanner.IsLocallyAdministered(n.MAC) {
+			vendor = "Private address"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			n.IP, n.MAC, dash(n.Interface), dash(n.State), truncate(vendor, 20), name)
+		shown++
+	}
+
+	if shown == 0 {
+		if arpUnknown {
+			fmt.Println("Every host in the neighbor table is a stored device")
+		} else {
+			fmt.Println("The neighbor table is empty")
+		}
+		return nil
+	}
+	return w.Flush()
+}
+
+// deviceDisplayName names a stored device the way the dashboard does: its
+// label, else its hostname, else its address.
+func deviceDisplayName(d *types.Device) string {
+	switch {
+	case d.Label != "":
+		return d.Label
+	case d.Hostname != "":
+		return d.Hostname
+	default:
+		return d.IP
+	}
+}
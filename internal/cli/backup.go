@@ -0,0 +1,277 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/backup"
+	"github.com/291-Group/LAN-Orangutan/internal/config"
+	"github.com/291-Group/LAN-Orangutan/internal/storage"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var (
+	backupNoConfig  bool
+	restoreMerge    bool
+	restoreNoConfig bool
+)
+
+// backupDataFiles are the files in the data directory a backup holds. The
+// password hash is among them, so a restored install signs in as before.
+var backupDataFiles = []string{
+	"devices.json", "scan_state.json", "events.json", "sightings.json", "changes.json", "silences.json", "escalations.json", "findings.json", "certs.json", "fingerprints.json", "auth",
+}
+
+// Names of the config and data files inside a backup archive. The config
+// file is config.ini, config.yaml or config.toml after its format.
+const (
+	backupConfigName = "config."
+	backupDataPrefix = "data/"
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup [file]",
+	Short: "Save devices, history and config to one archive",
+	Long: `Write the device list, scan state, event log, sighting and change history,
+password and config file to a single .tar.gz archive, for moving to another
+machine or as a safety net before an upgrade. Restore it with
+'orangutan restore'.
+
+The archive holds the password hash and any API token, so it is written
+readable only by you; keep it somewhere as private as the config file.
+
+Without a file name the archive is written to the current directory, named
+after the date and time.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runBackup,
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <file>",
+	Short: "Restore an archive made by backup",
+	Long: `Replace the data files and config file with those from an archive made by
+'orangutan backup'. Data files the archive does not have, such as history
+recorded since it was made, are removed, so the result is exactly what was
+backed up.
+
+With --merge the current data is kept instead: devices from the archive are
+added, and their labels, groups, notes, types and tags are applied to the
+matching devices here, as 'orangutan import' does. History and config are
+left alone.
+
+Stop the server before restoring; a running server keeps the old data in
+memory and would save it back over the restored files.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRestore,
+}
+
+func init() {
+	backupCmd.Flags().BoolVar(&backupNoConfig, "no-config", false, "Leave the config file out of the archive")
+	restoreCmd.Flags().BoolVar(&restoreMerge, "merge", false, "Add the archive's devices to the current ones instead of replacing everything")
+	restoreCmd.Flags().BoolVar(&restoreNoConfig, "no-config", false, "Keep the current config file")
+}
+
+func runBackup(cmd *cobra.Command, args []string) error {
+	files := make(map[string][]byte)
+	for _, name := range backupDataFiles {
+		data, err := os.ReadFile(filepath.Join(cfg.Storage.DataDir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		files[backupDataPrefix+name] = data
+	}
+	if _, ok := files[backupDataPrefix+"devices.json"]; !ok {
+		return fmt.Errorf("nothing to back up: no device list in %s", cfg.Storage.DataDir)
+	}
+
+	if !backupNoConfig {
+		data, err := os.ReadFile(cfgFile)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read the config file: %w", err)
+		}
+		if err == nil {
+			files[backupConfigName+config.Format(cfgFile)] = data
+		}
+	}
+
+	now := time.Now()
+	path := "orangutan-backup-" + now.Format("20060102-150405") + ".tar.gz"
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	hostname, _ := os.Hostname()
+	manifest := backup.Manifest{Version: Version, Created: now, Hostname: hostname}
+
+	// Written to a temporary file first, so a failure part way through does
+	// not leave a truncated archive that looks like a good one.
+	file, err := os.CreateTemp(filepath.Dir(path), ".orangutan-backup-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	if err := backup.Write(file, manifest, files); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, strings.TrimPrefix(name, backupDataPrefix))
+	}
+	sort.Strings(names)
+	fmt.Printf("Backed up %s to %s\n", strings.Join(names, ", "), path)
+	return nil
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	file, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", args[0], err)
+	}
+	archive, err := backup.Read(file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", args[0], err)
+	}
+	if _, ok := archive.Files[backupDataPrefix+"devices.json"]; !ok {
+		return fmt.Errorf("%s has no device list", args[0])
+	}
+
+	// A config file is only of use in the format the config path is read
+	// in, which is checked before anything is replaced.
+	var configData []byte
+	if !restoreNoConfig {
+		for _, format := range []string{"ini", "yaml", "toml"} {
+			data, ok := archive.Files[backupConfigName+format]
+			if !ok {
+				continue
+			}
+			if want := config.Format(cfgFile); format != want {
+				return fmt.Errorf("the backup's config file is %s but %s is read as %s; use --config with a .%s path, or --no-config", strings.ToUpper(format), cfgFile, strings.ToUpper(want), format)
+			}
+			configData = data
+		}
+	}
+
+	// The data files are unpacked beside the real ones and opened as a store
+	// before anything is replaced, so a damaged archive changes nothing.
+	dataDir := cfg.Storage.DataDir
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	staging, err := os.MkdirTemp(dataDir, ".restore-*")
+	if err != nil {
+		return fmt.Errorf("failed to unpack %s: %w", args[0], err)
+	}
+	defer os.RemoveAll(staging)
+
+	var restored []string
+	for _, name := range backupDataFiles {
+		data, ok := archive.Files[backupDataPrefix+name]
+		if !ok {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(staging, name), data, 0o600); err != nil {
+			return fmt.Errorf("failed to unpack %s: %w", name, err)
+		}
+		restored = append(restored, name)
+	}
+	backedUp, err := storage.New(filepath.Join(staging, "devices.json"), filepath.Join(staging, "scan_state.json"))
+	if err != nil {
+		return fmt.Errorf("%s is damaged: %w", args[0], err)
+	}
+
+	m := archive.Manifest
+	fmt.Printf("Backup of %s made %s", dash(m.Hostname), m.Created.Local().Format("2006-01-02 15:04"))
+	if m.Version != "" {
+		fmt.Printf(" by version %s", m.Version)
+	}
+	fmt.Println()
+
+	if restoreMerge {
+		return mergeBackup(cmd, backedUp)
+	}
+
+	for _, name := range backupDataFiles {
+		dst := filepath.Join(dataDir, name)
+		if _, ok := archive.Files[backupDataPrefix+name]; ok {
+			if err := os.Rename(filepath.Join(staging, name), dst); err != nil {
+				return fmt.Errorf("failed to restore %s: %w", name, err)
+			}
+		} else if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+	}
+
+	if configData != nil {
+		if err := restoreConfig(configData); err != nil {
+			return err
+		}
+		restored = append(restored, cfgFile)
+	}
+
+	fmt.Printf("Restored %d devices and %s\n", len(backedUp.GetDevices()), strings.Join(restored, ", "))
+	return nil
+}
+
+// mergeBackup adds the devices of a backup to the current ones.
+func mergeBackup(cmd *cobra.Command, backedUp *storage.Storage) error {
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	var devices []types.Device
+	for _, d := range backedUp.GetDevices() {
+		devices = append(devices, *d)
+	}
+	sort.Slice(devices, func(i, j int) bool { return types.LessIP(devices[i].IP, devices[j].IP) })
+
+	result, err := store.ImportDevices(devices, false)
+	if err != nil {
+		return fmt.Errorf("failed to save devices: %w", err)
+	}
+	fmt.Printf("Merged %d devices: %d new, %d updated, %d unchanged\n",
+		len(devices), result.Created, result.Updated, result.Unchanged)
+	return nil
+}
+
+// restoreConfig replaces the config file with data. It may hold a password
+// or API token, so it is kept readable only by its owner.
+func restoreConfig(data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(cfgFile), 0o755); err != nil {
+		return fmt.Errorf("failed to restore the config file: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(cfgFile), ".config-*")
+	if err != nil {
+		return fmt.Errorf("failed to restore the config file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to restore the config file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to restore the config file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), cfgFile); err != nil {
+		return fmt.Errorf("failed to restore the config file: %w", err)
+	}
+	return nil
+}
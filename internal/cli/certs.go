@@ -0,0 +1,209 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/storage"
+	"github.com/291-Group/LAN-Orangutan/internal/tlscert"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var (
+	certsCheck    bool
+	certsExpiring bool
+	certsFormat   string
+)
+
+var certsCmd = &cobra.Command{
+	Use:   "certs [ip|mac|label...]",
+	Short: "List the TLS certificates devices serve, soonest to expire first",
+	Long: `List the TLS certificates devices on the network serve, or the devices
+given, with when each expires, soonest first. NAS boxes, Proxmox, UniFi
+controllers and self-hosted services each have their own, and nothing else
+says when one is about to run out.
+
+A running server or monitor checks every online device as often as interval
+in [certs] says, on the ports it lists and any other a deep scan found
+speaking TLS, and records a cert_expiring event for one that comes within
+warn of its expiry date and again once it has expired, which alert rules can
+send on.
+--check looks now instead of listing what was last found.
+
+  orangutan certs
+  orangutan certs --expiring
+  orangutan certs nas --check`,
+	RunE: runCerts,
+}
+
+func init() {
+	certsCmd.Flags().BoolVar(&certsCheck, "check", false, "Fetch the certificates now and record them")
+	certsCmd.Flags().BoolVar(&certsExpiring, "expiring", false, "Only list certificates within warn in [certs] of expiring, or expired")
+	certsCmd.Flags().StringVar(&certsFormat, "format", "table", "Output format (table, json)")
+}
+
+func runCerts(cmd *cobra.Command, args []string) error {
+	if certsFormat != "table" && certsFormat != "json" {
+		return fmt.Errorf("unknown format %q (use table or json)", certsFormat)
+	}
+	var ips []string
+	for _, arg := range args {
+		ip, d, err := resolveTarget(cmd, arg)
+		if err != nil {
+			return err
+		}
+		if d == nil {
+			return fmt.Errorf("device not found: %s", arg)
+		}
+		ips = append(ips, ip)
+	}
+	devices, err := loadDevices(cmd)
+	if err != nil {
+		return err
+	}
+
+	var all map[string][]types.Certificate
+	c, err := remoteClient()
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		if certsCheck {
+			return fmt.Errorf("a server checks certificates as often as interval in [certs] says; run 'orangutan certs --check' on the server to look now")
+		}
+		ctx, cancel := remoteContext()
+		defer cancel()
+		list, err := c.Certs(ctx)
+		if err != nil {
+			return err
+		}
+		all = make(map[string][]types.Certificate)
+		for _, cert := range list {
+			all[cert.IP] = append(all[cert.IP], cert)
+		}
+	} else {
+		store, err := openStore(cmd)
+		if err != nil {
+			return err
+		}
+		if certsCheck {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			n, err := newCertChecker().CheckAll(ctx, store, ips)
+			if err != nil {
+				return err
+			}
+			if certsFormat == "table" {
+				fmt.Printf("Checked the certificates of %d device(s)\n\n", n)
+			}
+		}
+		all = store.GetCertificates()
+	}
+
+	wanted := make(map[string]bool)
+	for _, ip := range ips {
+		wanted[ip] = true
+	}
+	now := time.Now()
+	var rows []types.Certificate
+	for ip, certs := range all {
+		if len(wanted) > 0 && !wanted[ip] {
+			continue
+		}
+		for _, cert := range certs {
+			if !certsExpiring || cert.Expiry(now, cfg.Certs.Warn) != "" {
+				rows = append(rows, cert)
+			}
+		}
+	}
+	sortCerts(rows)
+
+	if certsFormat == "json" {
+		if rows == nil {
+			rows = []types.Certificate{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	}
+	if len(rows) == 0 {
+		if certsExpiring {
+			fmt.Println("No certificate is about to expire")
+		} else {
+			fmt.Println("No certificates found; run 'orangutan certs --check' to look")
+		}
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "EXPIRES\tLEFT\tDEVICE\tPORT\tSUBJECT\tISSUER")
+	for _, cert := range rows {
+		name := cert.IP
+		if d := devices[cert.IP]; d != nil {
+			name = deviceDisplayName(d)
+		}
+		issuer := cert.Issuer
+		if cert.SelfSigned {
+			issuer = "self-signed"
+		}
+		subject := cert.Subject
+		if subject == "" {
+			subject = strings.Join(cert.Names, ", ")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", cert.NotAfter.Local().Format("2006-01-02"), certLeft(cert, now), truncate(name, 20), cert.Port, truncate(subject, 30), truncate(issuer, 24))
+	}
+	return w.Flush()
+}
+
+// certLeft says how long cert has left at now, as in "12d", or "expired".
+func certLeft(cert types.Certificate, now time.Time) string {
+	left := cert.NotAfter.Sub(now)
+	if left <= 0 {
+		return "expired"
+	}
+	if left < 24*time.Hour {
+		return fmt.Sprintf("%dh", int(left/time.Hour))
+	}
+	return fmt.Sprintf("%dd", int(left/(24*time.Hour)))
+}
+
+// sortCerts puts the certificates soonest to expire first.
+func sortCerts(certs []types.Certificate) {
+	sort.Slice(certs, func(i, j int) bool {
+		if !certs[i].NotAfter.Equal(certs[j].NotAfter) {
+			return certs[i].NotAfter.Before(certs[j].NotAfter)
+		}
+		if certs[i].IP != certs[j].IP {
+			return types.LessIP(certs[i].IP, certs[j].IP)
+		}
+		return certs[i].Port < certs[j].Port
+	})
+}
+
+// newCertChecker returns a certificate checker set up as [certs] says.
+func newCertChecker() *tlscert.Checker {
+	return &tlscert.Checker{
+		Ports:    cfg.Certs.Ports,
+		Warn:     cfg.Certs.Warn,
+		Interval: cfg.Certs.Interval,
+	}
+}
+
+// startCerts checks the certificates of the online devices as often as
+// [certs] says, until ctx is done, so that those about to expire are alerted
+// about. It does nothing when the interval is 0.
+func startCerts(ctx context.Context, store *storage.Storage) {
+	if cfg.Certs.Interval <= 0 {
+		return
+	}
+	go newCertChecker().Run(ctx, store)
+}
@@ -0,0 +1,232 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/storage"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var (
+	checkDevices      []string
+	checkGroups       []string
+	checkTags         []string
+	checkUnknownSince time.Duration
+	checkStale        time.Duration
+)
+
+// Nagios plugin states, which are also the exit statuses monitoring systems
+// read them from.
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+	checkUnknown  = 3
+)
+
+var checkStateNames = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the inventory as a Nagios or Icinga plugin",
+	Long: `Check the inventory as a Nagios, Icinga, Naemon or Checkmk plugin would: print
+one line of status with performance data, the details below it, and exit
+with the plugin status.
+
+  0 OK        the watched devices are online and nothing unknown appeared
+  1 WARNING   devices without a label appeared within --unknown-since
+  2 CRITICAL  a watched device is offline
+  3 UNKNOWN   the check could not tell, such as when no scan has seen
+              anything for --stale or a --device names nothing
+
+Watch devices by address, MAC, label or hostname with --device, or whole
+groups and tags with --group and --tag. The check only reads what scans
+stored, so run the server, watch or monitor, or scan from cron, to keep it
+current:
+
+  orangutan check --device nas --device router --group servers`,
+	Args: cobra.NoArgs,
+	Run:  runCheck,
+}
+
+func init() {
+	checkCmd.Flags().StringArrayVar(&checkDevices, "device", nil, "Watch this device: IP, MAC, label or hostname (repeatable)")
+	checkCmd.Flags().StringArrayVar(&checkGroups, "group", nil, "Watch the devices in this group (repeatable)")
+	_ = checkCmd.RegisterFlagCompletionFunc("group", completeGroups)
+	checkCmd.Flags().StringArrayVar(&checkTags, "tag", nil, "Watch the devices with this tag (repeatable)")
+	checkCmd.Flags().DurationVar(&checkUnknownSince, "unknown-since", 24*time.Hour,
+		"Warn about devices without a label first seen this recently (0 to not warn)")
+	checkCmd.Flags().DurationVar(&checkStale, "stale", 2*time.Hour,
+		"Unknown when no device has been seen for this long (0 to not check)")
+}
+
+// checkResult is the outcome of a check.
+type checkResult struct {
+	state   int
+	summary []string
+	details []string
+	perf    []string
+}
+
+// runCheck prints the outcome of the check and exits with its state. It
+// does not return errors: to a monitoring system, any status 2 means
+// CRITICAL, so a check that could not be done must say UNKNOWN instead.
+func runCheck(cmd *cobra.Command, args []string) {
+	devices, err := loadDevices(cmd)
+	var r checkResult
+	if err != nil {
+		r = checkResult{state: checkUnknown, summary: []string{err.Error()}}
+	} else {
+		r = evaluateCheck(devices, time.Now())
+	}
+
+	line := "LAN ORANGUTAN " + checkStateNames[r.state] + " - " + strings.Join(r.summary, "; ")
+	if len(r.perf) > 0 {
+		line += " | " + strings.Join(r.perf, " ")
+	}
+	fmt.Println(line)
+	for _, d := range r.details {
+		fmt.Println(d)
+	}
+	os.Exit(r.state)
+}
+
+// evaluateCheck checks devices against the flags at now.
+func evaluateCheck(devices map[string]*types.Device, now time.Time) checkResult {
+	var r checkResult
+	raise := func(state int) { r.state = max(r.state, state) }
+
+	if len(devices) == 0 {
+		return checkResult{state: checkUnknown, summary: []string{"no devices in the inventory; has a scan run?"}}
+	}
+
+	watched := make(map[string]*types.Device)
+	for _, key := range checkDevices {
+		d, err := storage.Find(devices, key)
+		if err != nil {
+			// A typo must not turn into a check that always passes.
+			return checkResult{state: checkUnknown, summary: []string{fmt.Sprintf("--device %s: %v", key, err)}}
+		}
+		watched[d.IP] = d
+	}
+	for _, d := range devices {
+		if containsFold(checkGroups, d.Group) || hasAnyTag(d, checkTags) {
+			watched[d.IP] = d
+		}
+	}
+
+	var newest time.Time
+	online := 0
+	for _, d := range devices {
+		if d.LastSeen.After(newest) {
+			newest = d.LastSeen
+		}
+		if d.IsOnline() {
+			online++
+		}
+	}
+	if checkStale > 0 && now.Sub(newest) > checkStale {
+		raise(checkUnknown)
+		r.summary = append(r.summary, fmt.Sprintf("no device seen for %s; are scans running?", formatDuration(now.Sub(newest))))
+	}
+
+	var offline []*types.Device
+	for _, d := range watched {
+		if !d.IsOnline() {
+			offline = append(offline, d)
+		}
+	}
+	sortDevicesByIP(offline)
+	if len(offline) > 0 {
+		raise(checkCritical)
+		names := make([]string, len(offline))
+		for i, d := range offline {
+			names[i] = checkName(d)
+			r.details = append(r.details, fmt.Sprintf("Offline: %s, last seen %s", checkName(d), checkAgo(d.LastSeen, now)))
+		}
+		r.summary = append(r.summary, fmt.Sprintf("%d of %d watched devices offline: %s", len(offline), len(watched), strings.Join(names, ", ")))
+	} else if len(watched) > 0 {
+		r.summary = append(r.summary, fmt.Sprintf("all %d watched devices online", len(watched)))
+	}
+
+	var unknown []*types.Device
+	if checkUnknownSince > 0 {
+		for _, d := range devices {
+			if d.Label == "" && !d.FirstSeen.IsZero() && now.Sub(d.FirstSeen) <= checkUnknownSince {
+				unknown = append(unknown, d)
+			}
+		}
+	}
+	sortDevicesByIP(unknown)
+	if len(unknown) > 0 {
+		raise(checkWarning)
+		r.summary = append(r.summary, fmt.Sprintf("%d unknown devices appeared", len(unknown)))
+		for _, d := range unknown {
+			detail := fmt.Sprintf("Unknown: %s, first seen %s", checkName(d), checkAgo(d.FirstSeen, now))
+			if d.Vendor != "" && d.Vendor != "Unknown" {
+				detail += ", " + d.Vendor
+			}
+			r.details = append(r.details, detail)
+		}
+	}
+
+	if len(r.summary) == 0 {
+		r.summary = append(r.summary, fmt.Sprintf("%d of %d devices online", online, len(devices)))
+	}
+	r.perf = []string{
+		fmt.Sprintf("devices=%d;;;0", len(devices)),
+		fmt.Sprintf("online=%d;;;0;%d", online, len(devices)),
+		fmt.Sprintf("watched_offline=%d;;1;0;%d", len(offline), len(watched)),
+		fmt.Sprintf("unknown=%d;1;;0", len(unknown)),
+	}
+	return r
+}
+
+// checkName names d for the check's output: its label or hostname, and its
+// address.
+func checkName(d *types.Device) string {
+	if name := deviceDisplayName(d); name != d.IP {
+		return fmt.Sprintf("%s (%s)", name, d.IP)
+	}
+	return d.IP
+}
+
+// checkAgo says how long before now t was.
+func checkAgo(t, now time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return formatDuration(now.Sub(t)) + " ago"
+}
+
+// containsFold reports whether values holds s, ignoring case. An empty s
+// is never held.
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if s != "" && strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAnyTag reports whether d carries any of tags.
+func hasAnyTag(d *types.Device, tags []string) bool {
+	for _, tag := range tags {
+		if d.HasTag(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortDevicesByIP sorts devices by address.
+func sortDevicesByIP(devices []*types.Device) {
+	sort.Slice(devices, func(i, j int) bool { return types.LessIP(devices[i].IP, devices[j].IP) })
+}
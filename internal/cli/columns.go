@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/291-Group/LAN-Orangutan/internal/scanner"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// deviceColumn is a column the table and CSV output can show.
+type deviceColumn struct {
+	// Name is how --columns refers to it.
+	Name string
+	// Header heads the CSV column; the table shows it in capitals.
+	Header string
+	// Width truncates the value in the table, when more than zero, so one
+	// long hostname does not push every other column off the screen. CSV
+	// always has the whole value.
+	Width int
+	Value func(d *types.Device) string
+}
+
+// timeFormat is how the table and CSV show first and last seen.
+const timeFormat = "2006-01-02 15:04:05"
+
+// deviceColumns are the columns --columns takes, in the order listed in its
+// help.
+var deviceColumns = []deviceColumn{
+	{"ip", "IP", 0, func(d *types.Device) string { return d.IP }},
+	{"mac", "MAC", 0, func(d *types.Device) string { return d.MAC }},
+	{"hostname", "Hostname", 25, func(d *types.Device) string { return d.Hostname }},
+	{"vendor", "Vendor", 20, func(d *types.Device) string { return scanner.ResolveVendor(d.Vendor, d.MAC) }},
+	{"label", "Label", 0, func(d *types.Device) string { return d.Label }},
+	{"notes", "Notes", 30, func(d *types.Device) string { return d.Notes }},
+	{"group", "Group", 0, func(d *types.Device) string { return d.Group }},
+	// The type chosen by hand, or else the detected one, as the dashboard
+	// shows it.
+	{"type", "Type", 0, func(d *types.Device) string {
+		if d.Type != "" {
+			return d.Type
+		}
+		return scanner.DetectType(d)
+	}},
+	// Semicolons, as import splits exported tags at them.
+	{"tags", "Tags", 30, func(d *types.Device) string { return strings.Join(d.Tags, "; ") }},
+	{"status", "Status", 0, deviceStatus},
+	{"first_seen", "First Seen", 0, func(d *types.Device) string { return d.FirstSeen.Format(timeFormat) }},
+	{"last_seen", "Last Seen", 0, func(d *types.Device) string { return d.LastSeen.Format(timeFormat) }},
+	{"response_time", "Response Time", 0, func(d *types.Device) string {
+		if d.ResponseTime == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*d.ResponseTime, 'f', 2, 64)
+	}},
+	{"ssid", "SSID", 20, func(d *types.Device) string {
+		if d.Wireless == nil {
+			return ""
+		}
+		return d.Wireless.SSID
+	}},
+	{"band", "Band", 0, func(d *types.Device) string {
+		if d.Wireless == nil {
+			return ""
+		}
+		return d.Wireless.Band
+	}},
+	// In dBm, as the router last heard the device.
+	{"signal", "Signal", 0, func(d *types.Device) string {
+		if d.Wireless == nil {
+			return ""
+		}
+		return strconv.Itoa(d.Wireless.Signal)
+	}},
+}
+
+// Columns shown when --columns is not given.
+var (
+	defaultTableColumns = []string{"ip", "mac", "hostname", "vendor", "label", "group", "status"}
+	defaultCSVColumns   = []string{"ip", "mac", "hostname", "vendor", "label", "notes", "group", "first_seen", "last_seen"}
+)
+
+// deviceColumnNames returns the names --columns takes, for its help and
+// errors.
+func deviceColumnNames() string {
+	names := make([]string, len(deviceColumns))
+	for i, c := range deviceColumns {
+		names[i] = c.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// parseColumns returns the columns a comma-separated list names, in its
+// order. It returns nil for an empty list, leaving each format its defaults.
+func parseColumns(list string) ([]deviceColumn, error) {
+	var columns []deviceColumn
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		c, ok := findColumn(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q (use %s)", name, deviceColumnNames())
+		}
+		columns = append(columns, c)
+	}
+	return columns, nil
+}
+
+// mustColumns returns the named columns, which are known to exist.
+func mustColumns(names []string) []deviceColumn {
+	columns := make([]deviceColumn, len(names))
+	for i, name := range names {
+		c, ok := findColumn(name)
+		if !ok {
+			panic("unknown column " + name)
+		}
+		columns[i] = c
+	}
+	return columns
+}
+
+func findColumn(name string) (deviceColumn, bool) {
+	for _, c := range deviceColumns {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return deviceColumn{}, false
+}
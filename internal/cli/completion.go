@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/client"
+	"github.com/291-Group/LAN-Orangutan/internal/storage"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// completionTimeout bounds the call to a server for completions. The user is
+// waiting at the prompt; no suggestions beat a long pause.
+const completionTimeout = 3 * time.Second
+
+// The functions below complete arguments and flags naming devices and groups,
+// for the commands' ValidArgsFunction and RegisterFlagCompletionFunc. The
+// candidates come from the stored devices, or from the server in remote mode,
+// so they are whatever the inventory holds when Tab is pressed.
+
+func init() {
+	// Flag completions are registered beside the flags, which must exist
+	// first.
+	for _, cmd := range []*cobra.Command{setCmd, historyCmd} {
+		cmd.ValidArgsFunction = completeOne(completeDeviceAddrs)
+	}
+	for _, cmd := range []*cobra.Command{showCmd, pingCmd, tracerouteCmd} {
+		cmd.ValidArgsFunction = completeOne(completeDeviceNames)
+	}
+	deleteCmd.ValidArgsFunction = completeDeviceNames
+	resolveCmd.ValidArgsFunction = completeDeviceIPs
+	groupRenameCmd.ValidArgsFunction = completeOne(completeGroups)
+	groupDeleteCmd.ValidArgsFunction = completeOne(completeGroups)
+	groupAssignCmd.ValidArgsFunction = completeGroupAssign
+}
+
+// completeGroupAssign completes a group, then the devices to put in it.
+func completeGroupAssign(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return completeGroups(cmd, args, toComplete)
+	}
+	return completeDeviceAddrs(cmd, args, toComplete)
+}
+
+// completeOne completes the first argument with complete, and nothing after
+// it, for commands that take a single argument.
+func completeOne(complete cobra.CompletionFunc) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return complete(cmd, args, toComplete)
+	}
+}
+
+// completeDeviceIPs suggests the IP addresses of stored devices, leaving out
+// those already given.
+func completeDeviceIPs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return deviceCompletions(args, toComplete, func(d *types.Device) []string {
+		return []string{d.IP}
+	})
+}
+
+// completeDeviceAddrs suggests the IP and MAC addresses of stored devices.
+func completeDeviceAddrs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return deviceCompletions(args, toComplete, func(d *types.Device) []string {
+		return []string{d.IP, d.MAC}
+	})
+}
+
+// completeDeviceNames suggests addresses, labels and hostnames, everything
+// resolveTarget accepts.
+func completeDeviceNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return deviceCompletions(args, toComplete, func(d *types.Device) []string {
+		return []string{d.IP, d.MAC, d.Label, d.Hostname}
+	})
+}
+
+// deviceCompletions returns the values of the stored devices that start with
+// toComplete, ignoring case, and are not among args. Each is described by the
+// device's name, which shells that show descriptions print beside it.
+func deviceCompletions(args []string, toComplete string, values func(*types.Device) []string) ([]string, cobra.ShellCompDirective) {
+	devices := completionDevices()
+	given := make(map[string]bool, len(args))
+	for _, a := range args {
+		given[strings.ToLower(a)] = true
+	}
+
+	seen := make(map[string]bool)
+	var completions []string
+	for _, d := range devices {
+		for _, v := range values(d) {
+			key := strings.ToLower(v)
+			if v == "" || seen[key] || given[key] || !strings.HasPrefix(key, strings.ToLower(toComplete)) {
+				continue
+			}
+			seen[key] = true
+			if name := deviceDisplayName(d); name != v {
+				v += "\t" + name
+			}
+			completions = append(completions, v)
+		}
+	}
+	sort.Strings(completions)
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeGroups suggests the groups devices are in, in the spelling that
+// comes first alphabetically where devices disagree on case.
+func completeGroups(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	byKey := make(map[string]string)
+	for _, d := range completionDevices() {
+		key := strings.ToLower(d.Group)
+		if d.Group == "" || !strings.HasPrefix(key, strings.ToLower(toComplete)) {
+			continue
+		}
+		if name, ok := byKey[key]; !ok || d.Group < name {
+			byKey[key] = d.Group
+		}
+	}
+	groups := make([]string, 0, len(byKey))
+	for _, name := range byKey {
+		groups = append(groups, name)
+	}
+	sort.Strings(groups)
+	return groups, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completionDevices returns the devices to complete from, or none when they
+// cannot be read: completion has nowhere to report an error but cobra's
+// debug log.
+//
+// The config is loaded again because cobra's completion command only parses
+// the flags of the line being completed after initConfig has run, so a
+// --config or --profile on that line would otherwise be ignored.
+func completionDevices() map[string]*types.Device {
+	conf, err := loadConfig()
+	if err != nil {
+		cobra.CompDebugln(err.Error(), false)
+		return nil
+	}
+
+	if serverAddr != "" {
+		c, err := client.New(serverAddr, conf.Server.APIToken)
+		if err != nil {
+			cobra.CompDebugln(err.Error(), false)
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+		defer cancel()
+		devices, err := c.Devices(ctx)
+		if err != nil {
+			cobra.CompDebugln(err.Error(), false)
+		}
+		return devices
+	}
+
+	store, err := storage.New(conf.DevicesFile(), conf.StateFile())
+	if err != nil {
+		cobra.CompDebugln(err.Error(), false)
+		return nil
+	}
+	return store.GetDevices()
+}
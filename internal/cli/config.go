@@ -2,21 +2,69 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 
 	"github.com/291-Group/LAN-Orangutan/internal/auth"
+	"github.com/291-Group/LAN-Orangutan/internal/config"
+	"github.com/291-Group/LAN-Orangutan/internal/query"
+	"github.com/291-Group/LAN-Orangutan/internal/tlscert"
 )
 
+var configEffective bool
+
 var configCmd = &cobra.Command{
 	Use:   "config",
 	Short: "Display current configuration",
-	RunE:  runConfig,
+	Long: `Display the configuration in effect.
+
+--effective lists every setting with where its value came from: the default,
+a line of the config file or of a drop-in, an environment variable, or a
+flag. With --server it shows the running server's settings, flags and all.`,
+	RunE: runConfig,
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration for mistakes",
+	Long: `Check the config file, its drop-ins and the ORANGUTAN_ environment
+variables for anything that would be skipped or could not work: unknown
+sections and keys, values of the wrong type, ports and intervals out of range,
+networks and exclusions that are not CIDRs, and a data directory that cannot
+be written. Each problem names the line it is on.
+
+Exits with status 1 when there are problems, so it can guard a deployment:
+
+  orangutan config validate && systemctl restart lan-orangutan`,
+	Args: cobra.NoArgs,
+	RunE: runConfigValidate,
+}
+
+func init() {
+	configCmd.Flags().BoolVar(&configEffective, "effective", false, "List every setting with where its value came from")
+	configCmd.AddCommand(configValidateCmd)
 }
 
 func runConfig(cmd *cobra.Command, args []string) error {
+	if configEffective {
+		return runConfigEffective()
+	}
+	if serverAddr != "" {
+		return fmt.Errorf("config shows this machine's settings; add --effective for the server's")
+	}
+
 	fmt.Println("=== LAN Orangutan Configuration ===")
 	fmt.Printf("Config file: %s\n", cfgFile)
+	for _, f := range config.DropIns(cfgFile) {
+		fmt.Printf("Drop-in:     %s\n", f)
+	}
+	if profiles := cfg.Profiles(); len(profiles) > 0 {
+		fmt.Printf("Profile:     %s (of %s)\n", dash(cfg.Profile()), strings.Join(profiles, ", "))
+	}
 	fmt.Println()
 
 	fmt.Println("[server]")
@@ -26,34 +74,391 @@ func runConfig(cmd *cobra.Command, args []string) error {
 	// Never print the password or its hash. Show only whether one is set, and
 	// where it came from, which is what someone checking their setup needs.
 	fmt.Printf("  password = %s\n", passwordSummary())
+	fmt.Printf("  username = %s\n", cfg.Server.Username)
+	fmt.Printf("  api_token = %s\n", secretSummary(cfg.Server.APIToken))
 	fmt.Printf("  session_hours = %d\n", cfg.Server.SessionHours)
 	fmt.Printf("  allow_insecure = %v\n", cfg.Server.AllowInsecure)
+	fmt.Printf("  unprivileged_port = %d\n", cfg.Server.UnprivilegedPort)
+	fmt.Printf("  mdns = %v\n", cfg.Server.MDNS)
+	fmt.Printf("  mdns_name = %s\n", cfg.Server.MDNSName)
 	fmt.Println()
 
 	fmt.Println("[scanning]")
 	fmt.Printf("  scan_interval = %d\n", cfg.Scanning.ScanInterval)
 	fmt.Printf("  min_scan_interval = %d\n", cfg.Scanning.MinScanInterval)
+	fmt.Printf("  scan_timeout = %d\n", cfg.Scanning.ScanTimeout)
 	fmt.Printf("  enable_port_scan = %v\n", cfg.Scanning.EnablePortScan)
 	fmt.Printf("  port_scan_range = %s\n", cfg.Scanning.PortScanRange)
+	fmt.Printf("  schedule = %s\n", cfg.Scanning.Schedule)
+	fmt.Printf("  privileged = %s\n", cfg.Scanning.Privileged)
+	fmt.Printf("  backends = %s\n", strings.Join(cfg.Scanning.Backends, ", "))
 	fmt.Println()
 
+	cidrs := make([]string, 0, len(cfg.Network))
+	for cidr := range cfg.Network {
+		cidrs = append(cidrs, cidr)
+	}
+	sort.Strings(cidrs)
+	for _, cidr := range cidrs {
+		n := cfg.ForNetwork(cidr)
+		fmt.Printf("[network %q]\n", cidr)
+		fmt.Printf("  scan_interval = %d\n", n.ScanInterval)
+		fmt.Printf("  schedule = %s\n", n.Schedule)
+		fmt.Printf("  profile = %s\n", n.Profile)
+		fmt.Printf("  exclude = %s\n", strings.Join(n.Exclude, ", "))
+		fmt.Printf("  enable = %v\n", n.Enable)
+		fmt.Println()
+	}
+
 	fmt.Println("[storage]")
 	fmt.Printf("  max_devices = %d\n", cfg.Storage.MaxDevices)
 	fmt.Printf("  retention_days = %d\n", cfg.Storage.RetentionDays)
 	fmt.Printf("  data_dir = %s\n", cfg.Storage.DataDir)
 	fmt.Println()
 
+	fmt.Println("[approval]")
+	fmt.Printf("  enable = %v\n", cfg.Approval.Enable)
+	fmt.Printf("  auto_approve_vendors = %s\n", strings.Join(cfg.Approval.Vendors, ", "))
+	fmt.Printf("  auto_approve_groups = %s\n", strings.Join(cfg.Approval.Groups, ", "))
+	fmt.Println()
+
+	fmt.Println("[offline]")
+	fmt.Printf("  after = %s\n", query.FormatAge(cfg.Offline.After))
+	fmt.Printf("  groups = %s\n", cfg.Offline.FormatGroups())
+	fmt.Println()
+
+	fmt.Println("[reports]")
+	fmt.Printf("  schedule = %s\n", cfg.Reports.Schedule)
+	fmt.Printf("  dir = %s\n", cfg.ReportsDir())
+	fmt.Printf("  keep = %d\n", cfg.Reports.Keep)
+	fmt.Println()
+
+	fmt.Println("[watchlist]")
+	fmt.Printf("  interval = %d\n", cfg.Watchlist.Interval)
+	fmt.Printf("  failures = %d\n", cfg.Watchlist.Failures)
+	fmt.Println()
+
+	fmt.Println("[vulnscan]")
+	fmt.Printf("  enable = %v\n", cfg.VulnScan.Enable)
+	fmt.Printf("  scripts = %s\n", strings.Join(cfg.VulnScan.Scripts, ", "))
+	fmt.Printf("  ports = %s\n", cfg.VulnScan.Ports)
+	fmt.Printf("  timeout = %d\n", cfg.VulnScan.Timeout)
+	fmt.Println()
+
+	fmt.Println("[cve]")
+	fmt.Printf("  enable = %v\n", cfg.CVE.Enable)
+	fmt.Printf("  url = %s\n", cfg.CVE.URL)
+	fmt.Printf("  api_key = %s\n", secretSummary(cfg.CVE.APIKey))
+	fmt.Printf("  cache = %s\n", query.FormatAge(cfg.CVE.Cache))
+	fmt.Println()
+
+	fmt.Println("[credcheck]")
+	fmt.Printf("  enable = %v\n", cfg.CredCheck.Enable)
+	fmt.Printf("  types = %s\n", strings.Join(cfg.CredCheck.Types, ", "))
+	fmt.Printf("  logins = %s\n", secretSummary(strings.Join(cfg.CredCheck.Logins, ", ")))
+	fmt.Printf("  delay = %d\n", cfg.CredCheck.Delay)
+	fmt.Println()
+
+	fmt.Println("[certs]")
+	fmt.Printf("  interval = %s\n", query.FormatAge(cfg.Certs.Interval))
+	fmt.Printf("  ports = %s\n", tlscert.FormatPorts(cfg.Certs.Ports))
+	fmt.Printf("  warn = %s\n", query.FormatAge(cfg.Certs.Warn))
+	fmt.Println()
+
+	fmt.Println("[fingerprint]")
+	fmt.Printf("  interval = %s\n", query.FormatAge(cfg.Fingerprint.Interval))
+	fmt.Printf("  mdns = %v\n", cfg.Fingerprint.MDNS)
+	fmt.Println()
+
 	fmt.Println("[tailscale]")
 	fmt.Printf("  enable = %v\n", cfg.Tailscale.Enable)
 	fmt.Printf("  auto_detect = %v\n", cfg.Tailscale.AutoDetect)
+	fmt.Printf("  serve = %v\n", cfg.Tailscale.Serve)
+	fmt.Printf("  hostname = %s\n", cfg.Tailscale.Hostname)
+	fmt.Printf("  auth_key = %s\n", secretSummary(cfg.Tailscale.AuthKey))
+	fmt.Printf("  https = %v\n", cfg.Tailscale.HTTPS)
 	fmt.Println()
 
 	fmt.Println("[ui]")
 	fmt.Printf("  theme = %s\n", cfg.UI.Theme)
+	fmt.Printf("  language = %s\n", cfg.UI.Language)
+	fmt.Println()
+
+	fmt.Println("[mqtt]")
+	fmt.Printf("  broker = %s\n", cfg.MQTT.Broker)
+	fmt.Printf("  client_id = %s\n", cfg.MQTT.ClientID)
+	fmt.Printf("  username = %s\n", cfg.MQTT.Username)
+	fmt.Printf("  password = %s\n", secretSummary(cfg.MQTT.Password))
+	fmt.Printf("  topic_prefix = %s\n", cfg.MQTT.TopicPrefix)
+	fmt.Printf("  qos = %d\n", cfg.MQTT.QoS)
+	fmt.Printf("  retain = %v\n", cfg.MQTT.Retain)
+	fmt.Printf("  snapshot_interval = %d\n", cfg.MQTT.SnapshotInterval)
+	fmt.Printf("  homeassistant = %v\n", cfg.MQTT.HomeAssistant)
+	fmt.Printf("  discovery_prefix = %s\n", cfg.MQTT.DiscoveryPrefix)
+	fmt.Println()
+
+	fmt.Println("[metrics]")
+	fmt.Printf("  textfile = %s\n", cfg.Metrics.Textfile)
+	fmt.Printf("  textfile_interval = %d\n", cfg.Metrics.TextfileInterval)
+	fmt.Println()
+
+	fmt.Println("[influxdb]")
+	fmt.Printf("  url = %s\n", cfg.InfluxDB.URL)
+	fmt.Printf("  org = %s\n", cfg.InfluxDB.Org)
+	fmt.Printf("  bucket = %s\n", cfg.InfluxDB.Bucket)
+	fmt.Printf("  token = %s\n", secretSummary(cfg.InfluxDB.Token))
+	fmt.Println()
+
+	fmt.Println("[zabbix]")
+	fmt.Printf("  server = %s\n", cfg.Zabbix.Server)
+	fmt.Printf("  host = %s\n", cfg.Zabbix.Host)
+	fmt.Printf("  register = %v\n", cfg.Zabbix.Register)
+	fmt.Printf("  metadata = %s\n", cfg.Zabbix.Metadata)
+	fmt.Println()
+
+	fmt.Println("[pihole]")
+	fmt.Printf("  url = %s\n", cfg.Pihole.URL)
+	fmt.Printf("  token = %s\n", secretSummary(cfg.Pihole.Token))
+	fmt.Printf("  group_tags = %v\n", cfg.Pihole.GroupTags)
+	fmt.Println()
+
+	fmt.Println("[adguard]")
+	fmt.Printf("  url = %s\n", cfg.AdGuard.URL)
+	fmt.Printf("  username = %s\n", cfg.AdGuard.Username)
+	fmt.Printf("  password = %s\n", secretSummary(cfg.AdGuard.Password))
+	fmt.Printf("  client_tags = %v\n", cfg.AdGuard.ClientTags)
+	fmt.Println()
+
+	fmt.Println("[openwrt]")
+	fmt.Printf("  url = %s\n", cfg.OpenWrt.URL)
+	fmt.Printf("  username = %s\n", cfg.OpenWrt.Username)
+	fmt.Printf("  password = %s\n", secretSummary(cfg.OpenWrt.Password))
+	fmt.Println()
+
+	fmt.Println("[firewall]")
+	fmt.Printf("  type = %s\n", cfg.Firewall.Type)
+	fmt.Printf("  url = %s\n", cfg.Firewall.URL)
+	fmt.Printf("  key = %s\n", secretSummary(cfg.Firewall.Key))
+	fmt.Printf("  secret = %s\n", secretSummary(cfg.Firewall.Secret))
+	fmt.Printf("  ca_file = %s\n", cfg.Firewall.CAFile)
+	fmt.Printf("  tls_verify = %v\n", cfg.Firewall.TLSVerify)
+	fmt.Println()
+
+	fmt.Println("[docker]")
+	fmt.Printf("  enable = %v\n", cfg.Docker.Enable)
+	fmt.Printf("  socket = %s\n", cfg.Docker.Socket)
+	fmt.Println()
+
+	fmt.Println("[kubernetes]")
+	fmt.Printf("  enable = %v\n", cfg.Kubernetes.Enable)
+	fmt.Printf("  kubeconfig = %s\n", cfg.Kubernetes.Kubeconfig)
+	fmt.Printf("  context = %s\n", cfg.Kubernetes.Context)
+	fmt.Println()
+
+	fmt.Println("[dns]")
+	fmt.Printf("  domain = %s\n", cfg.DNS.Domain)
+	fmt.Printf("  dnsmasq_file = %s\n", cfg.DNS.DnsmasqFile)
+
+	names := make([]string, 0, len(cfg.Notify))
+	for name := range cfg.Notify {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		n := cfg.Notify[name]
+		fmt.Println()
+		fmt.Printf("[notify %q]\n", name)
+		fmt.Printf("  type = %s\n", n.Type)
+		fmt.Printf("  url = %s\n", secretSummary(n.URL))
+		fmt.Printf("  channel = %s\n", n.Channel)
+		fmt.Printf("  username = %s\n", n.Username)
+		fmt.Printf("  token = %s\n", secretSummary(n.Token))
+		fmt.Printf("  chat_id = %s\n", n.ChatID)
+		fmt.Printf("  user = %s\n", n.User)
+		fmt.Printf("  priority = %s\n", n.Priority)
+		fmt.Printf("  secret = %s\n", secretSummary(n.Secret))
+		fmt.Printf("  body_template = %s\n", n.BodyTemplate)
+		fmt.Printf("  facility = %s\n", n.Facility)
+		fmt.Printf("  community = %s\n", secretSummary(n.Community))
+		fmt.Printf("  commands = %v\n", n.Commands)
+		fmt.Printf("  host = %s\n", n.Host)
+		fmt.Printf("  port = %d\n", n.Port)
+		fmt.Printf("  security = %s\n", n.Security)
+		fmt.Printf("  password = %s\n", secretSummary(n.Password))
+		fmt.Printf("  from = %s\n", n.From)
+		fmt.Printf("  to = %s\n", strings.Join(n.To, ", "))
+		fmt.Printf("  alerts = %v\n", n.Alerts)
+		fmt.Printf("  digest = %s\n", n.Digest)
+		fmt.Printf("  digest_template = %s\n", n.DigestTemplate)
+	}
+	names = names[:0]
+	for name := range cfg.Alert {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		a := cfg.Alert[name]
+		fmt.Println()
+		fmt.Printf("[alert %q]\n", name)
+		fmt.Printf("  events = %s\n", strings.Join(a.Events, ", "))
+		fmt.Printf("  devices = %s\n", strings.Join(a.Devices, ", "))
+		fmt.Printf("  groups = %s\n", strings.Join(a.Groups, ", "))
+		fmt.Printf("  notify = %s\n", strings.Join(a.Notify, ", "))
+		fmt.Printf("  template = %s\n", a.Template)
+		fmt.Printf("  escalate = %s\n", a.Escalate)
+	}
+	names = names[:0]
+	for name := range cfg.Escalation {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Println()
+		fmt.Printf("[escalation %q]\n", name)
+		fmt.Printf("  steps = %s\n", config.FormatSteps(cfg.Escalation[name].Steps))
+	}
+	names = names[:0]
+	for name := range cfg.Maintenance {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		m := cfg.Maintenance[name]
+		fmt.Println()
+		fmt.Printf("[maintenance %q]\n", name)
+		fmt.Printf("  schedule = %s\n", m.Schedule)
+		fmt.Printf("  duration = %s\n", query.FormatAge(m.Duration))
+		fmt.Printf("  devices = %s\n", strings.Join(m.Devices, ", "))
+		fmt.Printf("  groups = %s\n", strings.Join(m.Groups, ", "))
+		fmt.Printf("  networks = %s\n", strings.Join(m.Networks, ", "))
+	}
+	names = names[:0]
+	for name := range cfg.Person {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := cfg.Person[name]
+		fmt.Println()
+		fmt.Printf("[person %q]\n", name)
+		fmt.Printf("  name = %s\n", p.Name)
+		fmt.Printf("  macs = %s\n", strings.Join(p.MACs, ", "))
+		fmt.Printf("  away_after = %s\n", query.FormatAge(p.AwayAfter))
+	}
+	names = names[:0]
+	for name := range cfg.Restricted {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r := cfg.Restricted[name]
+		fmt.Println()
+		fmt.Printf("[restricted %q]\n", name)
+		fmt.Printf("  networks = %s\n", strings.Join(r.Networks, ", "))
+		fmt.Printf("  groups = %s\n", strings.Join(r.Groups, ", "))
+		fmt.Printf("  allowed = %s\n", strings.Join(r.Allowed, ", "))
+	}
 
 	return nil
 }
 
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	problems, err := config.Check(cfgFile)
+	if err != nil {
+		return err
+	}
+	problems = append(problems, config.CheckEnv()...)
+	problems = append(problems, cfg.Validate()...)
+	problems = append(problems, validateOtherProfiles()...)
+	// The first scan creates the data directory, so this asks only that it
+	// could.
+	if r := doctorDataDir(); r.status == doctorFail {
+		problems = append(problems, "data_dir: "+r.detail)
+	}
+
+	if len(problems) == 0 {
+		fmt.Printf("%s: OK\n", cfgFile)
+		return nil
+	}
+	fmt.Printf("%s:\n", cfgFile)
+	for _, p := range problems {
+		fmt.Printf("  %s\n", p)
+	}
+	return &foundError{reason: fmt.Sprintf("%d problems found", len(problems))}
+}
+
+// validateOtherProfiles describes the settings of the profiles not in use
+// that cannot work, so that a mistake in the travel profile is found at
+// home rather than at the airport. Problems the profiles share with the
+// settings in use are left out, having been reported already.
+func validateOtherProfiles() []string {
+	seen := make(map[string]bool)
+	for _, p := range cfg.Validate() {
+		seen[p] = true
+	}
+	var problems []string
+	for _, name := range cfg.Profiles() {
+		if name == cfg.Profile() {
+			continue
+		}
+		conf, err := config.Load(cfgFile)
+		if err != nil {
+			return append(problems, err.Error())
+		}
+		if err := conf.UseProfile(name); err != nil {
+			return append(problems, err.Error())
+		}
+		conf.ApplyEnv()
+		for _, p := range conf.Validate() {
+			if !seen[p] {
+				problems = append(problems, fmt.Sprintf("profile %s: %s", name, p))
+			}
+		}
+	}
+	return problems
+}
+
+// runConfigEffective prints every setting with its source.
+func runConfigEffective() error {
+	var settings []config.Setting
+	c, err := remoteClient()
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		ctx, cancel := remoteContext()
+		defer cancel()
+		if settings, err = c.Config(ctx); err != nil {
+			return err
+		}
+	} else {
+		settings = cfg.Effective()
+		for i, s := range settings {
+			// The server falls back to the password made during setup.
+			if s.Key == "server.password" && s.Value == "" && auth.LoadHash(cfg.PasswordFile()) != "" {
+				settings[i].Value = "(set)"
+				settings[i].Source = "setup " + cfg.PasswordFile()
+			}
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SETTING\tVALUE\tSOURCE")
+	fmt.Fprintln(w, "-------\t-----\t------")
+	for _, s := range settings {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.Key, dash(s.Value), s.Source)
+	}
+	return w.Flush()
+}
+
+// secretSummary says whether a secret is set without revealing it.
+func secretSummary(secret string) string {
+	if secret == "" {
+		return "(not set)"
+	}
+	return "(set)"
+}
+
 // passwordSummary describes the password state without revealing it.
 func passwordSummary() string {
 	if cfg.Server.Password != "" {
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/credcheck"
+	"github.com/291-Group/LAN-Orangutan/internal/scanner"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var credcheckCmd = &cobra.Command{
+	Use:   "credcheck [ip|mac|label...]",
+	Short: "Find devices that still accept their factory default logins",
+	Long: `Try the logins devices ship with, such as admin/admin, against the
+admin interfaces of the devices given, or of every online device of the
+types [credcheck] lists: routers, cameras and printers unless it says
+otherwise. A device that lets one in gets a high finding, which
+'orangutan findings' and 'orangutan vulnerable' list with the rest.
+
+It is careful. A handful of logins are tried on each interface, one at a
+time with a pause between them, and it stops at the first that works. Web
+interfaces are tried only when they ask for HTTP basic authentication, and
+SSH logins that work are closed at once. Even so, trying logins is for the
+owner of the devices to decide, so it only runs once enable = true is set
+in [credcheck].
+
+  orangutan credcheck
+  orangutan credcheck router camera`,
+	RunE: runCredcheck,
+}
+
+func runCredcheck(cmd *cobra.Command, args []string) error {
+	if !cfg.CredCheck.Enable {
+		return fmt.Errorf("the default login check is off; set enable = true in [credcheck] to run it on devices you look after")
+	}
+	c, err := remoteClient()
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		return fmt.Errorf("the default login check runs from the machine it is started on; run it without --server")
+	}
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	var devices []*types.Device
+	for _, arg := range args {
+		_, d, err := resolveTarget(cmd, arg)
+		if err != nil {
+			return err
+		}
+		if d == nil {
+			return fmt.Errorf("device not found: %s", arg)
+		}
+		devices = append(devices, d)
+	}
+	if len(args) == 0 {
+		for _, d := range store.GetDevices() {
+			if d.IsOnline() && slices.Contains(cfg.CredCheck.Types, credcheckType(d)) {
+				devices = append(devices, d)
+			}
+		}
+		sort.Slice(devices, func(i, j int) bool { return types.LessIP(devices[i].IP, devices[j].IP) })
+	}
+	if len(devices) == 0 {
+		fmt.Println("No online devices of the types [credcheck] lists")
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	checker := credcheck.New(cfg.CredCheckOptions())
+	fmt.Printf("Trying default logins on %d device(s)\n", len(devices))
+	accepted := 0
+	for _, d := range devices {
+		findings, err := checker.Check(ctx, d.IP, credcheckType(d))
+		if err != nil {
+			return err
+		}
+		if _, err := store.SetFindings(d.IP, types.CheckDefaultLogins, findings); err != nil {
+			return err
+		}
+		if len(findings) == 0 {
+			fmt.Printf("  %s (%s): no default login accepted\n", deviceDisplayName(d), d.IP)
+			continue
+		}
+		accepted++
+		for _, f := range findings {
+			fmt.Printf("  %s (%s): %s on %d/%s\n", deviceDisplayName(d), d.IP, f.Title, f.Port, f.Protocol)
+		}
+	}
+	if accepted > 0 {
+		fmt.Printf("\n%d device(s) still accept a default login; change their passwords\n", accepted)
+	}
+	return nil
+}
+
+// credcheckType returns the type of d chosen by hand, or the one detected,
+// which says which logins it is tried with.
+func credcheckType(d *types.Device) string {
+	if d.Type != "" {
+		return d.Type
+	}
+	return scanner.DetectType(d)
+}
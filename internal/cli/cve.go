@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/cve"
+)
+
+var (
+	cveRefresh bool
+
+	vulnerableFormat string
+)
+
+var cveCmd = &cobra.Command{
+	Use:   "cve [ip|mac|label...]",
+	Short: "Look the services deep scans found up in NVD",
+	Long: `Match the products and versions the latest deep scan of each device
+identified, or of the devices given, against NVD's database of known
+vulnerabilities, and keep the CVEs found, with their CVSS scores, among the
+device's findings.
+
+With [cve] enabled this happens after each deep scan by itself; run it to
+look again, as NVD learns of new holes in old versions. What NVD said of each
+product is kept for as long as cache in [cve] allows, so only products not
+looked up lately are asked about; --refresh asks about every one again.
+Without an API key NVD answers a request every six seconds.
+
+  orangutan cve
+  orangutan cve nas --refresh
+  orangutan vulnerable               # Which devices have known holes`,
+	RunE: runCVE,
+}
+
+var vulnerableCmd = &cobra.Command{
+	Use:   "vulnerable",
+	Short: "List the devices with known vulnerabilities, worst first",
+	Long: `List the devices whose latest deep scan found anything worse than
+information, with the worst severity, the highest CVSS score and the CVEs
+among their findings. 'orangutan findings DEVICE' lists each finding.
+
+  orangutan vulnerable
+  orangutan vulnerable --format json`,
+	Args: cobra.NoArgs,
+	RunE: runVulnerable,
+}
+
+func init() {
+	cveCmd.Flags().BoolVar(&cveRefresh, "refresh", false, "Ask NVD about every product again, however recently it was")
+	vulnerableCmd.Flags().StringVar(&vulnerableFormat, "format", "table", "Output format (table, json)")
+}
+
+func runCVE(cmd *cobra.Command, args []string) error {
+	c, err := remoteClient()
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		return fmt.Errorf("a server looks CVEs up after each deep scan when [cve] is enabled; run 'orangutan cve' on the server to look again")
+	}
+
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+	var ips []string
+	for _, arg := range args {
+		ip, d, err := resolveTarget(cmd, arg)
+		if err != nil {
+			return err
+		}
+		if d == nil {
+			return fmt.Errorf("device not found: %s", arg)
+		}
+		if store.GetDeepScan(ip) == nil {
+			return fmt.Errorf("%s has had no deep scan; 'orangutan vulnscan %s' runs one", arg, arg)
+		}
+		ips = append(ips, ip)
+	}
+	if len(args) == 0 {
+		for ip := range store.GetDeepScans() {
+			ips = append(ips, ip)
+		}
+		sort.Strings(ips)
+	}
+	if len(ips) == 0 {
+		fmt.Println("No deep scans yet; 'orangutan vulnscan' runs one")
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("Looking up the services of %d device(s) in NVD\n", len(ips))
+	if err := cve.New(cfg.CVEOptions()).Update(ctx, store, ips, cveRefresh); err != nil {
+		return err
+	}
+	devices := store.GetDevices()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IP\tNAME\tCVES\tWORST")
+	for _, ip := range ips {
+		count, worst := 0, ""
+		for _, f := range store.GetDeepScan(ip).Findings {
+			if f.Check != cve.Check {
+				continue
+			}
+			if count == 0 {
+				worst = fmt.Sprintf("%s %s", strings.ToUpper(f.Severity), f.ID)
+			}
+			count++
+		}
+		name := ip
+		if d := devices[ip]; d != nil {
+			name = deviceDisplayName(d)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", ip, truncate(name, 20), count, worst)
+	}
+	return w.Flush()
+}
+
+func runVulnerable(cmd *cobra.Command, args []string) error {
+	if vulnerableFormat != "table" && vulnerableFormat != "json" {
+		return fmt.Errorf("unknown format %q (use table or json)", vulnerableFormat)
+	}
+	devices, err := loadDevices(cmd)
+	if err != nil {
+		return err
+	}
+
+	var exposures []cve.Exposure
+	c, err := remoteClient()
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		ctx, cancel := remoteContext()
+		defer cancel()
+		if exposures, err = c.Vulnerable(ctx); err != nil {
+			return err
+		}
+	} else {
+		store, err := openStore(cmd)
+		if err != nil {
+			return err
+		}
+		exposures = cve.Exposures(store.GetDeepScans())
+	}
+
+	if vulnerableFormat == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(exposures)
+	}
+	if len(exposures) == 0 {
+		fmt.Println("No device has a known vulnerability")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SEVERITY\tIP\tNAME\tCVSS\tFINDINGS\tCVES\tSCANNED")
+	for _, e := range exposures {
+		name := e.IP
+		if d := devices[e.IP]; d != nil {
+			name = deviceDisplayName(d)
+		}
+		cvss := "-"
+		if e.CVSS > 0 {
+			cvss = fmt.Sprintf("%.1f", e.CVSS)
+		}
+		if e.Exploit {
+			cvss += " (exploit)"
+		}
+		cves := strings.Join(e.CVEs, ", ")
+		if len(e.CVEs) > 3 {
+			cves = fmt.Sprintf("%s and %d more", strings.Join(e.CVEs[:3], ", "), len(e.CVEs)-3)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", strings.ToUpper(e.Severity), e.IP, truncate(name, 20), cvss, e.Findings(), cves, e.Scanned.Local().Format("2006-01-02"))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// prepareDataDir makes sure the data directory exists and can be written
+// before anything is stored in it. Left to storage.New, a directory owned by
+// someone else fails with a bare "permission denied", or worse, only when a
+// scan that took minutes comes to save; here the error says what to run.
+//
+// Directories it creates under sudo in the invoking user's home are handed
+// to that user, so that running without sudo afterwards still works.
+func prepareDataDir(dir string) error {
+	existing := nearestExisting(dir)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("cannot create the data directory %s: %w%s", dir, err, dataDirHint(err, "create it for yourself with: sudo install -d -o %s %s", dir))
+	}
+	if existing != dir {
+		if err := chownToSudoUser(dir, existing); err != nil {
+			return err
+		}
+	}
+	if err := checkWritable(dir); err != nil {
+		return fmt.Errorf("cannot write to the data directory: %w%s", err, dataDirHint(err, "take ownership with: sudo chown -R %s %s", dir))
+	}
+	return nil
+}
+
+// nearestExisting returns dir, or the nearest directory above it that
+// exists.
+func nearestExisting(dir string) string {
+	existing := dir
+	for {
+		if _, err := os.Stat(existing); err == nil {
+			return existing
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return existing
+		}
+		existing = parent
+	}
+}
+
+// checkWritable checks that files can be created in dir and that the data
+// files already there can be saved over. Files left by a scan under sudo
+// belong to root, so a directory that is writable is not enough.
+func checkWritable(dir string) error {
+	probe, err := os.CreateTemp(dir, ".orangutan-*")
+	if err != nil {
+		return err
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		f, err := os.OpenFile(filepath.Join(dir, e.Name()), os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		f.Close()
+	}
+	return nil
+}
+
+// dataDirHint says how to get past err, met at dir: by running fix, a command
+// taking the user's name and dir, or by choosing another directory. The parent of a
+// directory that cannot be created is not the user's to take: it may well be
+// /var/lib.
+func dataDirHint(err error, fix, dir string) string {
+	if !errors.Is(err, fs.ErrPermission) {
+		return ""
+	}
+	if runtime.GOOS == "windows" || os.Getuid() == 0 {
+		return "; choose a directory you can write with --data-dir"
+	}
+	name := "$(id -un)"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	return "; " + fmt.Sprintf(fix, name, dir) + ", or choose another directory with --data-dir"
+}
+
+// chownToSudoUser gives the directories from existing down to dir, which
+// were just created, to the user who ran sudo, when dir is in their home.
+// Root's own directories, such as /var/lib/lan-orangutan, are left to root.
+func chownToSudoUser(dir, existing string) error {
+	if runtime.GOOS == "windows" || os.Getuid() != 0 {
+		return nil
+	}
+	u, err := user.LookupId(os.Getenv("SUDO_UID"))
+	if err != nil || u.HomeDir == "" || !strings.HasPrefix(dir, u.HomeDir+string(filepath.Separator)) {
+		return nil
+	}
+	uid, err1 := strconv.Atoi(u.Uid)
+	gid, err2 := strconv.Atoi(u.Gid)
+	if err1 != nil || err2 != nil {
+		return nil
+	}
+	for d := dir; d != existing && d != filepath.Dir(d); d = filepath.Dir(d) {
+		if err := os.Chown(d, uid, gid); err != nil {
+			return fmt.Errorf("failed to give the data directory %s to %s: %w", d, u.Username, err)
+		}
+	}
+	return nil
+}
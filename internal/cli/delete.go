@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/storage"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var deleteYes bool
+
+var deleteCmd = &cobra.Command{
+	Use:   "delete <ip|mac|label|hostname>...",
+	Short: "Delete devices",
+	Long: `Delete devices and their history, naming each by IP address, MAC address,
+label or hostname. A name that matches several devices is refused; give the
+IP address to pick one.
+
+The devices are listed and confirmed first; --yes skips the question, and is
+needed when there is nobody to answer it.
+
+  orangutan delete "Old laptop"
+  orangutan delete aa:bb:cc:dd:ee:ff 192.168.1.77 --yes
+
+To delete devices by age, group or tag, see 'orangutan prune'.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runDelete,
+}
+
+func init() {
+	deleteCmd.Flags().BoolVarP(&deleteYes, "yes", "y", false, "Delete without asking")
+}
+
+func runDelete(cmd *cobra.Command, args []string) error {
+	devices, err := loadDevices(cmd)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	var victims []*types.Device
+	for _, key := range args {
+		d, err := storage.Find(devices, key)
+		if err != nil {
+			return err
+		}
+		if !seen[d.IP] {
+			seen[d.IP] = true
+			victims = append(victims, d)
+		}
+	}
+	sort.Slice(victims, func(i, j int) bool {
+		return types.LessIP(victims[i].IP, victims[j].IP)
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IP\tMAC\tNAME\tGROUP\tLAST SEEN")
+	fmt.Fprintln(w, "--\t---\t----\t-----\t---------")
+	ips := make([]string, len(victims))
+	for i, d := range victims {
+		ips[i] = d.IP
+		lastSeen := "never"
+		if !d.LastSeen.IsZero() {
+			lastSeen = d.LastSeen.Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			d.IP, dash(d.MAC), truncate(deviceDisplayName(d), 25), dash(d.Group), lastSeen)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Println()
+
+	if !deleteYes {
+		if !isTerminal(os.Stdin) {
+			return fmt.Errorf("not deleting without confirmation: pass --yes")
+		}
+		fmt.Printf("Delete %d device(s) and their history? [y/N] ", len(victims))
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			fmt.Println("Nothing deleted")
+			return nil
+		}
+	}
+
+	c, err := remoteClient()
+	if err != nil {
+		return err
+	}
+	var n int
+	if c != nil {
+		ctx, cancel := remoteContext()
+		defer cancel()
+		n, err = c.Batch(ctx, ips, "delete", "")
+	} else {
+		store, serr := openStore(cmd)
+		if serr != nil {
+			return serr
+		}
+		n, err = store.DeleteDevices(ips)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete devices: %w", err)
+	}
+	fmt.Printf("Deleted %d devices\n", n)
+	return nil
+}
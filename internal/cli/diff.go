@@ -0,0 +1,265 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/importer"
+	"github.com/291-Group/LAN-Orangutan/internal/storage"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var (
+	diffSince         time.Duration
+	diffExitCode      bool
+	diffFailOnNew     bool
+	diffFailOnMissing bool
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [file]",
+	Short: "Show devices added, removed or changed",
+	Long: `Compare the device inventory with an earlier snapshot, or report what
+recent scans found.
+
+Given a file, such as one written by 'orangutan export' or a copy of
+devices.json, it lists devices added and removed since, and changes to their
+details. Blank values in the file are not compared, so an export without a
+notes column does not make every note look new.
+
+Without a file it reports what the latest scan found: new devices, devices
+that went offline, and changed details. Use --since to cover a longer period,
+for example --since 24h in a daily cron job.
+
+With --exit-code the command exits with status 1 when there is something to
+report. --fail-on-new and --fail-on-missing do the same for just added and
+just removed or offline devices, so a nightly job can alert on an unknown
+device without also alerting on a renamed one. Errors exit with status 2.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runDiff,
+}
+
+func init() {
+	diffCmd.Flags().DurationVar(&diffSince, "since", 0, "Report everything since this long ago instead of the latest scan (e.g. 24h)")
+	diffCmd.Flags().BoolVar(&diffExitCode, "exit-code", false, "Exit with status 1 if there are differences")
+	diffCmd.Flags().BoolVar(&diffFailOnNew, "fail-on-new", false, "Exit with status 1 if devices were added")
+	diffCmd.Flags().BoolVar(&diffFailOnMissing, "fail-on-missing", false, "Exit with status 1 if devices were removed or went offline")
+}
+
+// diffSection is one heading of the report and its lines. The mark is "+"
+// for devices that appeared and "-" for those that went.
+type diffSection struct {
+	title, mark string
+	lines       []string
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	var sections []diffSection
+	if len(args) == 1 {
+		if diffSince != 0 {
+			return fmt.Errorf("--since cannot be used with a file")
+		}
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", args[0], err)
+		}
+		format := ""
+		switch strings.ToLower(filepath.Ext(args[0])) {
+		case ".csv":
+			format = "csv"
+		case ".json":
+			format = "json"
+		}
+		snapshot, err := importer.Parse(data, format)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", args[0], err)
+		}
+		sections = diffSnapshot(snapshot, store.GetDevices())
+	} else {
+		sections = diffRecent(store, diffSince)
+	}
+
+	changed := false
+	added, gone := 0, 0
+	for _, sec := range sections {
+		if len(sec.lines) == 0 {
+			continue
+		}
+		changed = true
+		switch sec.mark {
+		case "+":
+			added += len(sec.lines)
+		case "-":
+			gone += len(sec.lines)
+		}
+		fmt.Printf("%s (%d):\n", sec.title, len(sec.lines))
+		for _, line := range sec.lines {
+			fmt.Printf("  %s %s\n", sec.mark, line)
+		}
+		fmt.Println()
+	}
+	if !changed {
+		fmt.Println("No differences")
+		return nil
+	}
+	if diffExitCode {
+		return &foundError{}
+	}
+	return failOn(diffFailOnNew, diffFailOnMissing, added, gone)
+}
+
+// diffSnapshot compares an earlier list of devices with the current ones,
+// matching them by IP address.
+func diffSnapshot(snapshot []types.Device, current map[string]*types.Device) []diffSection {
+	added := diffSection{title: "Added", mark: "+"}
+	removed := diffSection{title: "Removed", mark: "-"}
+	changed := diffSection{title: "Changed", mark: "~"}
+
+	before := make(map[string]types.Device, len(snapshot))
+	for _, d := range snapshot {
+		if d.IP != "" {
+			before[d.IP] = d
+		}
+	}
+
+	for _, ip := range sortedIPs(current) {
+		d := current[ip]
+		old, ok := before[ip]
+		if !ok {
+			added.lines = append(added.lines, describeDevice(d))
+			continue
+		}
+		if diffs := fieldDiffs(&old, d); len(diffs) > 0 {
+			changed.lines = append(changed.lines, fmt.Sprintf("%s (%s): %s", ip, deviceDisplayName(d), strings.Join(diffs, ", ")))
+		}
+	}
+
+	var gone []string
+	for ip := range before {
+		if _, ok := current[ip]; !ok {
+			gone = append(gone, ip)
+		}
+	}
+	sort.Slice(gone, func(i, j int) bool { return types.LessIP(gone[i], gone[j]) })
+	for _, ip := range gone {
+		d := before[ip]
+		removed.lines = append(removed.lines, describeDevice(&d))
+	}
+
+	return []diffSection{added, removed, changed}
+}
+
+// fieldDiffs lists how the details of a device differ from an earlier copy
+// of it, skipping fields the earlier copy leaves blank.
+func fieldDiffs(old, d *types.Device) []string {
+	fields := []struct{ name, old, new string }{
+		{"MAC", old.MAC, d.MAC},
+		{"hostname", old.Hostname, d.Hostname},
+		{"label", old.Label, d.Label},
+		{"group", old.Group, d.Group},
+		{"notes", old.Notes, d.Notes},
+		{"type", old.Type, d.Type},
+		{"tags", strings.Join(old.Tags, ", "), strings.Join(d.Tags, ", ")},
+	}
+
+	var diffs []string
+	for _, f := range fields {
+		if f.old == "" || strings.EqualFold(f.old, f.new) {
+			continue
+		}
+		diffs = append(diffs, fmt.Sprintf("%s %q -> %q", f.name, f.old, f.new))
+	}
+	return diffs
+}
+
+// diffRecent reports the events and detail changes recorded by the latest
+// scan, or over the last since if it is set.
+func diffRecent(store *storage.Storage, since time.Duration) []diffSection {
+	devices := store.GetDevices()
+
+	// Every device a scan finds is stamped with the same instant, and so are
+	// the events and changes the scan records; the newest stamp marks the
+	// latest scan.
+	var cutoff time.Time
+	if since > 0 {
+		cutoff = time.Now().Add(-since)
+	} else {
+		for _, d := range devices {
+			if d.LastSeen.After(cutoff) {
+				cutoff = d.LastSeen
+			}
+		}
+		if cutoff.IsZero() {
+			return nil
+		}
+	}
+
+	added := diffSection{title: "New", mark: "+"}
+	offline := diffSection{title: "Went offline", mark: "-"}
+	changed := diffSection{title: "Changed", mark: "~"}
+
+	events := store.GetEvents(0, false)
+	for i := len(events) - 1; i >= 0; i-- {
+		e := events[i]
+		if e.Time.Before(cutoff) {
+			continue
+		}
+		line := fmt.Sprintf("%s  %s", e.IP, e.Name)
+		if since > 0 {
+			line = e.Time.Format("2006-01-02 15:04") + "  " + line
+		}
+		switch e.Type {
+		case types.EventDeviceNew:
+			added.lines = append(added.lines, line)
+		case types.EventDeviceOffline:
+			offline.lines = append(offline.lines, line)
+		}
+	}
+
+	for _, ip := range sortedIPs(devices) {
+		var diffs []string
+		for _, c := range store.GetChanges(ip) {
+			if !c.Time.Before(cutoff) {
+				diffs = append(diffs, describeChange(c))
+			}
+		}
+		if len(diffs) > 0 {
+			changed.lines = append(changed.lines, fmt.Sprintf("%s (%s): %s", ip, deviceDisplayName(devices[ip]), strings.Join(diffs, "; ")))
+		}
+	}
+
+	return []diffSection{added, offline, changed}
+}
+
+// describeDevice sums a device up on one line.
+func describeDevice(d *types.Device) string {
+	parts := []string{d.IP}
+	if d.MAC != "" {
+		parts = append(parts, d.MAC)
+	}
+	if name := deviceDisplayName(d); name != d.IP {
+		parts = append(parts, name)
+	}
+	return strings.Join(parts, "  ")
+}
+
+// sortedIPs returns the addresses of devices in order.
+func sortedIPs(devices map[string]*types.Device) []string {
+	ips := make([]string, 0, len(devices))
+	for ip := range devices {
+		ips = append(ips, ip)
+	}
+	sort.Slice(ips, func(i, j int) bool { return types.LessIP(ips[i], ips[j]) })
+	return ips
+}
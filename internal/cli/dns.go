@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/dnszone"
+	"github.com/291-Group/LAN-Orangutan/internal/storage"
+)
+
+var (
+	dnsFormat string
+	dnsDomain string
+	dnsOutput string
+	dnsWrite  bool
+)
+
+var dnsCmd = &cobra.Command{
+	Use:   "dns",
+	Short: "Write local DNS names for the labelled devices",
+	Long: `Write a local DNS name for each labelled device, made from its label: a
+device labelled "Living room TV" is living-room-tv, in the domain from
+[dns] (lan unless set).
+
+  hosts    lines for /etc/hosts, or a dnsmasq addn-hosts or hostsdir file
+  bind     A and AAAA records to $INCLUDE in a BIND zone
+  dnsmasq  host-record settings for /etc/dnsmasq.d
+
+Devices without a label are left out, as are those whose label makes a name
+a device at a lower address has already; those are listed on standard error.
+
+With dnsmasq_file set in [dns], the server, scan and watch rewrite that file
+after each scan; --write rewrites it now.
+
+  orangutan dns --format bind -o /etc/bind/lan.orangutan`,
+	Args: cobra.NoArgs,
+	RunE: runDNS,
+}
+
+func init() {
+	dnsCmd.Flags().StringVar(&dnsFormat, "format", "hosts", "Format: "+strings.Join(dnszone.Formats, ", "))
+	dnsCmd.Flags().StringVar(&dnsDomain, "domain", "", "Domain of the names (default from [dns])")
+	dnsCmd.Flags().StringVarP(&dnsOutput, "output", "o", "", "Write to this file instead of standard output")
+	dnsCmd.Flags().BoolVar(&dnsWrite, "write", false, "Rewrite the dnsmasq_file from [dns] now")
+	dnsCmd.MarkFlagsMutuallyExclusive("write", "output")
+}
+
+func runDNS(cmd *cobra.Command, args []string) error {
+	domain := cfg.DNS.Domain
+	if dnsDomain != "" {
+		domain = dnsDomain
+	}
+	if !slices.Contains(dnszone.Formats, dnsFormat) {
+		return fmt.Errorf("unknown format %q (use %s)", dnsFormat, strings.Join(dnszone.Formats, ", "))
+	}
+	if err := dnszone.ValidDomain(domain); err != nil {
+		return fmt.Errorf("domain %v", err)
+	}
+	devices, err := loadDevices(cmd)
+	if err != nil {
+		return err
+	}
+	records, clashes := dnszone.Records(devices)
+	for _, c := range clashes {
+		fmt.Fprintf(os.Stderr, "Skipped %s (%s): %s is already %s\n", c.IP, c.Label, c.Name, c.Owner)
+	}
+
+	if dnsWrite {
+		if cfg.DNS.DnsmasqFile == "" {
+			return errors.New("no dnsmasq_file is set in the [dns] section of the config file")
+		}
+		changed, err := dnszone.WriteFile(cfg.DNS.DnsmasqFile, "dnsmasq", domain, records)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", cfg.DNS.DnsmasqFile, err)
+		}
+		if changed {
+			fmt.Printf("Wrote %d names to %s; restart dnsmasq to serve them\n", len(records), cfg.DNS.DnsmasqFile)
+		} else {
+			fmt.Printf("%s already has the %d names\n", cfg.DNS.DnsmasqFile, len(records))
+		}
+		return nil
+	}
+
+	out := io.Writer(os.Stdout)
+	if dnsOutput != "" {
+		file, err := os.Create(dnsOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create file: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+	return dnszone.Write(out, dnsFormat, domain, records)
+}
+
+// writeDnsmasq rewrites the dnsmasq_file in the [dns] section with the names
+// of the devices in store. It does nothing when none is set.
+func writeDnsmasq(store *storage.Storage) error {
+	if cfg.DNS.DnsmasqFile == "" {
+		return nil
+	}
+	records, _ := dnszone.Records(store.GetDevices())
+	_, err := dnszone.WriteFile(cfg.DNS.DnsmasqFile, "dnsmasq", cfg.DNS.Domain, records)
+	return err
+}
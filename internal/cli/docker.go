@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/docker"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var (
+	dockerNetworks []string
+	dockerGroup    string
+	dockerDryRun   bool
+)
+
+var dockerCmd = &cobra.Command{
+	Use:   "docker",
+	Short: "Name devices after the containers of the local Docker engine",
+	Long: `Read the running containers of the local Docker engine and their addresses
+on its bridge, macvlan and other networks, from the socket in the [docker]
+section. With enable = true there, every scan names the containers it finds
+the same way.`,
+}
+
+var dockerContainersCmd = &cobra.Command{
+	Use:   "containers",
+	Short: "List the containers and their addresses",
+	Long: `List the running containers with their address on each network, and the
+device each is in the inventory, to answer "what is 172.17.0.5".`,
+	Args: cobra.NoArgs,
+	RunE: runDockerContainers,
+}
+
+var dockerImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Add the containers to the inventory",
+	Long: `Add the containers to the inventory, named after the container and tagged
+with each Docker network they are on, as "docker:frontend". Scans of the
+LAN do not reach bridge networks, so this is how their containers get in.`,
+	Args: cobra.NoArgs,
+	RunE: runDockerImport,
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{dockerContainersCmd, dockerImportCmd} {
+		cmd.Flags().StringSliceVar(&dockerNetworks, "network", nil, "Only addresses in this network (repeatable)")
+		dockerCmd.AddCommand(cmd)
+	}
+	dockerImportCmd.Flags().StringVar(&dockerGroup, "group", "", "Put the imported containers in this group")
+	_ = dockerImportCmd.RegisterFlagCompletionFunc("group", completeGroups)
+	dockerImportCmd.Flags().BoolVar(&dockerDryRun, "dry-run", false, "Show what would change without saving")
+}
+
+// dockerContainers returns the running containers, and the networks the
+// flags limit their addresses to.
+func dockerContainers() ([]docker.Container, []*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, cidr := range dockerNetworks {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("--network %s is not a network such as 172.17.0.0/16", cidr)
+		}
+		networks = append(networks, n)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	containers, err := docker.New(cfg.Docker.Socket).Containers(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return containers, networks, nil
+}
+
+func runDockerContainers(cmd *cobra.Command, args []string) error {
+	containers, networks, err := dockerContainers()
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		fmt.Println("No containers running")
+		return nil
+	}
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTAINER\tIMAGE\tNETWORK\tDRIVER\tIP\tMAC\tLABEL")
+	fmt.Fprintln(w, "---------\t-----\t-------\t------\t--\t---\t-----")
+	for _, ct := range containers {
+		if len(ct.Endpoints) == 0 && len(networks) == 0 {
+			fmt.Fprintf(w, "%s\t%s\thost\t-\t-\t-\t-\n", ct.Name, ct.Image)
+			continue
+		}
+		for _, e := range ct.Endpoints {
+			ip := e.IP
+			if ip == "" {
+				ip = e.IPv6
+			}
+			if len(networks) > 0 && !inNetworks(net.ParseIP(ip), networks) {
+				continue
+			}
+			label := ""
+			if stored := store.GetDevice(ip); stored != nil {
+				label = stored.Label
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", ct.Name, ct.Image, e.Network, dash(e.Driver), ip, dash(e.MAC), dash(label))
+		}
+	}
+	return w.Flush()
+}
+
+func runDockerImport(cmd *cobra.Command, args []string) error {
+	containers, networks, err := dockerContainers()
+	if err != nil {
+		return err
+	}
+	var devices []types.Device
+	for _, d := range docker.ContainerDevices(containers, nil, time.Now()) {
+		if len(networks) > 0 && !inNetworks(net.ParseIP(d.IP), networks) {
+			continue
+		}
+		d.Group = dockerGroup
+		devices = append(devices, d)
+	}
+	if len(devices) == 0 {
+		fmt.Println("No containers to import")
+		return nil
+	}
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	result, err := store.ImportDevices(devices, dockerDryRun)
+	if err != nil {
+		return fmt.Errorf("failed to save devices: %w", err)
+	}
+
+	verb := "Imported"
+	if dockerDryRun {
+		verb = "Would import"
+	}
+	fmt.Printf("%s %d container addresses: %d new, %d updated, %d unchanged\n",
+		verb, len(devices), result.Created, result.Updated, result.Unchanged)
+	if dockerDryRun {
+		fmt.Println("Dry run, nothing saved")
+	}
+	return nil
+}
@@ -0,0 +1,356 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/config"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the setup and suggest fixes",
+	Long: `Check the things LAN Orangutan depends on: the scanning tools, permission
+to send raw packets, the data directory, the server port, the config file and
+the system clock. Each problem comes with a suggested fix.
+
+Exits with status 1 if anything failed, so it can be used in scripts.`,
+	Args: cobra.NoArgs,
+	RunE: runDoctor,
+}
+
+// Outcomes of a doctor check.
+const (
+	doctorOK   = "ok"
+	doctorWarn = "warn"
+	doctorFail = "fail"
+	doctorSkip = "skip"
+)
+
+// doctorResult is the outcome of one check, with what to do about it.
+type doctorResult struct {
+	name   string
+	status string
+	detail string
+	fixes  []string
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	checks := []func() doctorResult{
+		doctorTools,
+		doctorRawSockets,
+		doctorDataDir,
+		doctorPort,
+		doctorConfig,
+		doctorClock,
+	}
+
+	failed, warned := 0, 0
+	for _, check := range checks {
+		r := check()
+		fmt.Printf("[%-4s] %-12s %s\n", strings.ToUpper(r.status), r.name, r.detail)
+		for _, fix := range r.fixes {
+			fmt.Printf("%20s%s\n", "", fix)
+		}
+		switch r.status {
+		case doctorFail:
+			failed++
+		case doctorWarn:
+			warned++
+		}
+	}
+
+	fmt.Println()
+	if failed == 0 && warned == 0 {
+		fmt.Println("Everything looks good")
+		return nil
+	}
+	fmt.Printf("%d failed, %d warnings\n", failed, warned)
+	if failed > 0 {
+		os.Exit(exitFound)
+	}
+	return nil
+}
+
+// doctorTools checks for a scanner. Any of the configured backends will do.
+// Tailscale is asked through its daemon, or the macOS app, so its command is
+// not looked for on the PATH.
+func doctorTools() doctorResult {
+	r := doctorResult{name: "Tools"}
+	var found, missing []string
+	for _, tool := range []string{"nmap", "arp-scan"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			missing = append(missing, tool)
+			continue
+		}
+		if v := getToolVersion(tool); v != "" {
+			found = append(found, v)
+		} else {
+			found = append(found, tool)
+		}
+	}
+	r.detail = strings.Join(found, "; ")
+	if len(missing) > 0 {
+		if r.detail != "" {
+			r.detail += "; "
+		}
+		r.detail += "not found: " + strings.Join(missing, ", ")
+	}
+
+	// Only the backends scans are configured to use count.
+	var usable []string
+	nmapWanted := false
+	for _, b := range cfg.Scanning.Backends {
+		if _, err := exec.LookPath(b); err == nil {
+			usable = append(usable, b)
+		}
+		nmapWanted = nmapWanted || b == "nmap"
+	}
+	_, nmapErr := exec.LookPath("nmap")
+	switch {
+	case len(usable) == 0:
+		r.status = doctorFail
+		r.fixes = append(r.fixes, "Install nmap: "+installHint("nmap"))
+		if !nmapWanted {
+			r.fixes = append(r.fixes, "and add it to backends in [scanning], which lists only "+strings.Join(cfg.Scanning.Backends, ", "))
+		}
+	case nmapWanted && nmapErr != nil:
+		// arp-scan only sees the local segment and needs root every time.
+		r.status = doctorWarn
+		r.fixes = append(r.fixes, "Install nmap for hostnames and routed networks: "+installHint("nmap"))
+	default:
+		r.status = doctorOK
+	}
+	return r
+}
+
+// installHint says how to install a package on this system.
+func installHint(pkg string) string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "brew install " + pkg
+	case "windows":
+		return "download it from https://nmap.org/download"
+	}
+	for _, pm := range []struct{ bin, cmd string }{
+		{"apt-get", "sudo apt install "},
+		{"dnf", "sudo dnf install "},
+		{"pacman", "sudo pacman -S "},
+		{"apk", "sudo apk add "},
+		{"zypper", "sudo zypper install "},
+	} {
+		if _, err := exec.LookPath(pm.bin); err == nil {
+			return pm.cmd + pkg
+		}
+	}
+	return "install the " + pkg + " package"
+}
+
+// doctorRawSockets checks that scans can send the ARP requests that find MAC
+// addresses. Without them nmap falls back to ping and connect scans, and
+// devices show up with no MAC address or vendor.
+func doctorRawSockets() doctorResult {
+	r := doctorResult{name: "Raw sockets"}
+	switch runtime.GOOS {
+	case "windows":
+		r.status = doctorSkip
+		r.detail = "not checked on Windows; run as Administrator for MAC addresses"
+		return r
+	case "linux":
+		if os.Geteuid() == 0 {
+			r.status, r.detail = doctorOK, "running as root"
+			return r
+		}
+		if nmap, err := exec.LookPath("nmap"); err == nil {
+			if out, err := exec.Command("getcap", nmap).Output(); err == nil && strings.Contains(string(out), "cap_net_raw") {
+				r.status, r.detail = doctorOK, "nmap has the cap_net_raw capability"
+				return r
+			}
+			r.fixes = append(r.fixes,
+				"Run scans with sudo, or let nmap send raw packets without it:",
+				"  sudo setcap cap_net_raw,cap_net_admin,cap_net_bind_service+eip "+nmap,
+				"  (scans then find it and use raw sockets without sudo)")
+		} else {
+			r.fixes = append(r.fixes, "Run scans with sudo")
+		}
+	default:
+		if os.Geteuid() == 0 {
+			r.status, r.detail = doctorOK, "running as root"
+			return r
+		}
+		r.fixes = append(r.fixes, "Run scans with sudo")
+	}
+	r.status = doctorWarn
+	r.detail = "not running as root; scans will miss MAC addresses and vendors"
+	return r
+}
+
+// doctorDataDir checks that the data directory can be written. One that
+// does not exist yet is created by the first scan, so then it is the nearest
+// directory above it that must be writable.
+func doctorDataDir() doctorResult {
+	dir := cfg.Storage.DataDir
+	r := doctorResult{name: "Data dir", detail: dir}
+
+	existing := nearestExisting(dir)
+	if err := checkWritable(existing); err != nil {
+		r.status = doctorFail
+		r.detail = fmt.Sprintf("%s is not writable: %v", existing, err)
+		r.fixes = dataDirFixes(existing)
+		return r
+	}
+	if existing != dir {
+		r.status = doctorOK
+		r.detail = dir + " will be created by the first scan"
+		return r
+	}
+
+	r.status = doctorOK
+	return r
+}
+
+// dataDirFixes suggests how to make dir writable.
+func dataDirFixes(dir string) []string {
+	fixes := []string{"Set data_dir in the config, or ORANGUTAN_DATA_DIR, to a directory you own"}
+	if runtime.GOOS != "windows" {
+		fixes = append([]string{"Take ownership: sudo chown -R $(id -un) " + dir}, fixes...)
+	}
+	return fixes
+}
+
+// doctorPort checks that the server could listen on its port.
+func doctorPort() doctorResult {
+	port := cfg.Server.Port
+	addr := net.JoinHostPort(cfg.Server.BindAddress, strconv.Itoa(port))
+	r := doctorResult{name: "Port", detail: addr}
+
+	listener, err := net.Listen(listenNetwork(cfg.Server.BindAddress), addr)
+	if err == nil {
+		listener.Close()
+		r.status = doctorOK
+		r.detail = addr + " is free"
+		return r
+	}
+
+	switch {
+	case isAddrInUse(err):
+		// Most often the server itself is already running.
+		if orangutanAnswers(addr) {
+			r.status = doctorOK
+			r.detail = addr + " is in use by a running LAN Orangutan server"
+			return r
+		}
+		r.status = doctorFail
+		r.detail = addr + " is in use by another program"
+		r.fixes = []string{"Stop that program, or choose another port with --port or port = in the config"}
+	case errors.Is(err, os.ErrPermission):
+		r.status = doctorWarn
+		r.detail = fmt.Sprintf("%s needs root: ports below 1024 are privileged", addr)
+		r.fixes = []string{"Run serve with sudo, or choose a port above 1024 with --port 8080"}
+	default:
+		r.status = doctorFail
+		r.detail = fmt.Sprintf("cannot listen on %s: %v", addr, err)
+		r.fixes = []string{"Check bind_address in the config is an address of this machine"}
+	}
+	return r
+}
+
+// orangutanAnswers reports whether a LAN Orangutan server answers at addr.
+// Its API answers in its own JSON envelope even when asking for a password.
+func orangutanAnswers(addr string) bool {
+	host, port, _ := net.SplitHostPort(addr)
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+net.JoinHostPort(host, port)+"/api/networks", nil)
+	if err != nil {
+		return false
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	var body types.APIResponse
+	return json.NewDecoder(resp.Body).Decode(&body) == nil && (body.Success || body.Error != "")
+}
+
+// doctorConfig checks the config file for lines that are skipped and
+// settings that cannot work.
+func doctorConfig() doctorResult {
+	r := doctorResult{name: "Config"}
+	if _, err := os.Stat(cfgFile); os.IsNotExist(err) {
+		r.detail = fmt.Sprintf("no file at %s, using defaults", cfgFile)
+	} else {
+		r.detail = cfgFile
+	}
+
+	problems, err := config.Check(cfgFile)
+	if err != nil {
+		r.status = doctorFail
+		r.detail = err.Error()
+		return r
+	}
+	problems = append(problems, config.CheckEnv()...)
+	problems = append(problems, cfg.Validate()...)
+	if len(problems) == 0 {
+		r.status = doctorOK
+		return r
+	}
+
+	r.status = doctorWarn
+	r.detail = fmt.Sprintf("%s has %d problems", cfgFile, len(problems))
+	r.fixes = problems
+	r.fixes = append(r.fixes, "See config.example.ini for every setting and its allowed values")
+	return r
+}
+
+// doctorClock checks the system clock is synchronised. Devices are marked
+// online by how recently they were seen, and sign-in sessions expire by the
+// clock, so a clock that jumps makes both misbehave.
+func doctorClock() doctorResult {
+	r := doctorResult{name: "Clock", detail: time.Now().Format("2006-01-02 15:04:05 MST")}
+	if time.Now().Year() < 2024 {
+		r.status = doctorFail
+		r.detail += " looks wrong"
+		r.fixes = []string{"Set the date and time, and turn on network time"}
+		return r
+	}
+	if runtime.GOOS != "linux" {
+		r.status = doctorSkip
+		r.detail += "; synchronisation not checked on this system"
+		return r
+	}
+
+	out, err := exec.Command("timedatectl", "show", "--property=NTPSynchronized", "--value").Output()
+	if err != nil {
+		r.status = doctorSkip
+		r.detail += "; timedatectl is not available to check synchronisation"
+		return r
+	}
+	if strings.TrimSpace(string(out)) != "yes" {
+		r.status = doctorWarn
+		r.detail += ", not synchronised with network time"
+		r.fixes = []string{"Turn on network time: sudo timedatectl set-ntp true"}
+		return r
+	}
+	r.status = doctorOK
+	r.detail += ", synchronised"
+	return r
+}
@@ -1,29 +1,72 @@
 package cli
 
 import (
+	"bufio"
 	"encoding/csv"
 	"fmt"
+	"html/template"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/291-Group/LAN-Orangutan/internal/scanner"
-	"github.com/291-Group/LAN-Orangutan/internal/storage"
 	"github.com/291-Group/LAN-Orangutan/internal/types"
+	"github.com/291-Group/LAN-Orangutan/internal/xlsx"
 )
 
+var exportFormat string
+
 var exportCmd = &cobra.Command{
 	Use:   "export <file>",
-	Short: "Export devices to CSV file",
-	Args:  cobra.ExactArgs(1),
-	RunE:  runExport,
+	Short: "Export devices to a CSV, JSON, HTML or Excel file",
+	Long: `Export all devices to a file.
+
+The format is taken from --format, or else from the file's extension
+(.csv, .json, .html or .xlsx), with CSV for anything else. The HTML page
+and Excel workbook are meant for handing to people who do not use this
+tool; the CSV and JSON files can be read back with 'orangutan import' and
+compared with 'orangutan diff'.
+
+  orangutan export devices.xlsx
+  orangutan export inventory --format html`,
+	Args: cobra.ExactArgs(1),
+	RunE: runExport,
+}
+
+func init() {
+	exportCmd.Flags().StringVar(&exportFormat, "format", "", "File format (csv, json, html, xlsx); by default from the file's extension")
+}
+
+// exportFormats maps file extensions to the formats they stand for.
+var exportFormats = map[string]string{
+	".csv":  "csv",
+	".json": "json",
+	".html": "html",
+	".htm":  "html",
+	".xlsx": "xlsx",
 }
 
 func runExport(cmd *cobra.Command, args []string) error {
 	outputPath := args[0]
 
+	format := strings.ToLower(exportFormat)
+	if format == "" {
+		format = exportFormats[strings.ToLower(filepath.Ext(outputPath))]
+		if format == "" {
+			format = "csv"
+		}
+	}
+	switch format {
+	case "csv", "json", "html", "xlsx":
+	default:
+		return fmt.Errorf("unknown format %q (use csv, json, html or xlsx)", format)
+	}
+
 	// Validate path (prevent path traversal)
 	absPath, err := filepath.Abs(outputPath)
 	if err != nil {
@@ -36,21 +79,18 @@ func runExport(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("directory does not exist: %s", dir)
 	}
 
-	// Initialize storage
-	store, err := storage.New(cfg.DevicesFile(), cfg.StateFile())
+	devices, err := loadDevices(cmd)
 	if err != nil {
-		return fmt.Errorf("failed to initialize storage: %w", err)
+		return err
 	}
 
-	devices := store.GetDevices()
-
 	// Convert to slice and sort by IP
 	var deviceList []*types.Device
 	for _, d := range devices {
 		deviceList = append(deviceList, d)
 	}
 	sort.Slice(deviceList, func(i, j int) bool {
-		return ipToSortKey(deviceList[i].IP) < ipToSortKey(deviceList[j].IP)
+		return types.LessIP(deviceList[i].IP, deviceList[j].IP)
 	})
 
 	// Create output file
@@ -60,34 +100,56 @@ func runExport(cmd *cobra.Command, args []string) error {
 	}
 	defer file.Close()
 
-	// Write CSV
-	w := csv.NewWriter(file)
-	defer w.Flush()
-
-	// Header
-	if err := w.Write([]string{
-		"IP Address",
-		"MAC Address",
-		"Hostname",
-		"Vendor",
-		"Label",
-		"Notes",
-		"Group",
-		"First Seen",
-		"Last Seen",
-		"Status",
-	}); err != nil {
-		return fmt.Errorf("failed to write header: %w", err)
+	w := bufio.NewWriter(file)
+	switch format {
+	case "json":
+		err = outputJSON(w, deviceList)
+	case "html":
+		err = exportHTML(w, deviceList)
+	case "xlsx":
+		err = xlsx.Write(w, "Devices", append([][]string{exportHeader}, exportRows(deviceList)...))
+	default:
+		err = exportCSV(w, deviceList)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = file.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", absPath, err)
 	}
 
-	// Data rows
-	for _, d := range deviceList {
+	fmt.Printf("Exported %d devices to %s\n", len(deviceList), absPath)
+	return nil
+}
+
+// exportHeader heads the columns of the CSV, HTML and Excel exports. The
+// dashboard's CSV download has the same ones, so the files are
+// interchangeable.
+var exportHeader = []string{
+	"IP Address",
+	"MAC Address",
+	"Hostname",
+	"Vendor",
+	"Label",
+	"Notes",
+	"Group",
+	"First Seen",
+	"Last Seen",
+	"Status",
+}
+
+func exportRows(devices []*types.Device) [][]string {
+	rows := make([][]string, len(devices))
+	for i, d := range devices {
 		status := "offline"
 		if d.IsOnline() {
 			status = "online"
 		}
 
-		if err := w.Write([]string{
+		rows[i] = []string{
 			d.IP,
 			d.MAC,
 			d.Hostname,
@@ -95,14 +157,70 @@ func runExport(cmd *cobra.Command, args []string) error {
 			d.Label,
 			d.Notes,
 			d.Group,
-			d.FirstSeen.Format("2006-01-02 15:04:05"),
-			d.LastSeen.Format("2006-01-02 15:04:05"),
+			d.FirstSeen.Format(timeFormat),
+			d.LastSeen.Format(timeFormat),
 			status,
-		}); err != nil {
-			return fmt.Errorf("failed to write row: %w", err)
 		}
 	}
+	return rows
+}
+
+func exportCSV(out io.Writer, devices []*types.Device) error {
+	w := csv.NewWriter(out)
+	if err := w.Write(exportHeader); err != nil {
+		return err
+	}
+	if err := w.WriteAll(exportRows(devices)); err != nil {
+		return err
+	}
+	return w.Error()
+}
 
-	fmt.Printf("Exported %d devices to %s\n", len(deviceList), absPath)
-	return nil
+// exportHTMLTemplate is a page that stands on its own, with its styles inline,
+// so it can be mailed or put on a file share and still look right.
+var exportHTMLTemplate = template.Must(template.New("export").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Network devices</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Roboto, sans-serif; margin: 2rem; color: #1f2328; }
+h1 { font-size: 1.4rem; margin-bottom: 0.25rem; }
+p { color: #59636e; margin-top: 0; }
+table { border-collapse: collapse; width: 100%; font-size: 0.9rem; }
+th, td { text-align: left; padding: 0.4rem 0.6rem; border-bottom: 1px solid #d1d9e0; vertical-align: top; }
+th { background: #f6f8fa; position: sticky; top: 0; }
+tr:nth-child(even) td { background: #fafbfc; }
+.online { color: #1a7f37; }
+.offline { color: #8c959f; }
+</style>
+</head>
+<body>
+<h1>Network devices</h1>
+<p>{{len .Rows}} devices, exported {{.Exported.Format "2 January 2006 15:04"}}</p>
+<table>
+<thead><tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{- range .Rows}}
+<tr>{{range $i, $v := .}}<td{{if eq $i $.StatusColumn}} class="{{$v}}"{{end}}>{{$v}}</td>{{end}}</tr>
+{{- end}}
+</tbody>
+</table>
+</body>
+</html>
+`))
+
+func exportHTML(out io.Writer, devices []*types.Device) error {
+	return exportHTMLTemplate.Execute(out, struct {
+		Header       []string
+		Rows         [][]string
+		StatusColumn int
+		Exported     time.Time
+	}{
+		Header:       exportHeader,
+		Rows:         exportRows(devices),
+		StatusColumn: len(exportHeader) - 1,
+		Exported:     time.Now(),
+	})
 }
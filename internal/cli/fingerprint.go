@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/fingerprint"
+	"github.com/291-Group/LAN-Orangutan/internal/mdns"
+	"github.com/291-Group/LAN-Orangutan/internal/storage"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var (
+	fingerprintCheck   bool
+	fingerprintChanged bool
+	fingerprintFormat  string
+)
+
+var fingerprintCmd = &cobra.Command{
+	Use:   "fingerprint [ip|mac|label...]",
+	Short: "List what each device looks like from the network",
+	Long: `List the fingerprint of each device, or of the devices given: its vendor,
+the open ports its last deep scan found, its hostname with numbers and
+serials left out, and the multicast DNS services it advertises.
+
+A running server or monitor takes them as often as interval in [fingerprint]
+says and records an anomaly event when one changes too much at once to be
+the same device: a new vendor, most of its open ports different, or a new
+hostname together with new services. That is how a device swapped for another, or
+taken over, shows behind an address and MAC that stayed the same. --check
+takes them now instead of listing the last, and --changed lists only
+devices whose fingerprint has changed that much.
+
+  orangutan fingerprint
+  orangutan fingerprint --changed
+  orangutan fingerprint camera --check`,
+	RunE: runFingerprint,
+}
+
+func init() {
+	fingerprintCmd.Flags().BoolVar(&fingerprintCheck, "check", false, "Take the fingerprints now and record them")
+	fingerprintCmd.Flags().BoolVar(&fingerprintChanged, "changed", false, "Only list devices whose fingerprint has changed enough to be reported")
+	fingerprintCmd.Flags().StringVar(&fingerprintFormat, "format", "table", "Output format (table, json)")
+}
+
+func runFingerprint(cmd *cobra.Command, args []string) error {
+	if fingerprintFormat != "table" && fingerprintFormat != "json" {
+		return fmt.Errorf("unknown format %q (use table or json)", fingerprintFormat)
+	}
+	var ips []string
+	for _, arg := range args {
+		ip, d, err := resolveTarget(cmd, arg)
+		if err != nil {
+			return err
+		}
+		if d == nil {
+			return fmt.Errorf("device not found: %s", arg)
+		}
+		ips = append(ips, ip)
+	}
+	devices, err := loadDevices(cmd)
+	if err != nil {
+		return err
+	}
+
+	var all map[string]types.Fingerprint
+	c, err := remoteClient()
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		if fingerprintCheck {
+			return fmt.Errorf("a server takes fingerprints as often as interval in [fingerprint] says; run 'orangutan fingerprint --check' on the server to take them now")
+		}
+		ctx, cancel := remoteContext()
+		defer cancel()
+		list, err := c.Fingerprints(ctx)
+		if err != nil {
+			return err
+		}
+		all = make(map[string]types.Fingerprint, len(list))
+		for _, fp := range list {
+			all[fp.IP] = fp
+		}
+	} else {
+		store, err := openStore(cmd)
+		if err != nil {
+			return err
+		}
+		if fingerprintCheck {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			n, changed, err := newFingerprintWatcher().CheckAll(ctx, store, ips)
+			if err != nil {
+				return err
+			}
+			if fingerprintFormat == "table" {
+				fmt.Printf("Took the fingerprints of %d device(s), %d changed\n\n", n, changed)
+			}
+		}
+		all = store.GetFingerprints()
+	}
+
+	wanted := make(map[string]bool)
+	for _, ip := range ips {
+		wanted[ip] = true
+	}
+	var rows []types.Fingerprint
+	for ip, fp := range all {
+		if len(wanted) > 0 && !wanted[ip] {
+			continue
+		}
+		if !fingerprintChanged || !fp.Changed.IsZero() {
+			rows = append(rows, fp)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return types.LessIP(rows[i].IP, rows[j].IP) })
+
+	if fingerprintFormat == "json" {
+		if rows == nil {
+			rows = []types.Fingerprint{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	}
+	if len(rows) == 0 {
+		if fingerprintChanged {
+			fmt.Println("No device's fingerprint has changed")
+		} else {
+			fmt.Println("No fingerprints taken; run 'orangutan fingerprint --check' to take them")
+		}
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DEVICE\tVENDOR\tHOSTNAME\tPORTS\tSERVICES\tCHANGED")
+	for _, fp := range rows {
+		name := fp.IP
+		if d := devices[fp.IP]; d != nil {
+			name = deviceDisplayName(d)
+		}
+		ports := "-"
+		if !fp.Scanned.IsZero() {
+			ports = fingerprint.FormatPorts(fp.Ports)
+		}
+		changed := "-"
+		if !fp.Changed.IsZero() {
+			changed = fp.Changed.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", truncate(name, 20), truncate(dash(fp.Vendor), 20), dash(fp.Hostname), truncate(ports, 24), truncate(dash(strings.Join(fp.Services, " ")), 30), changed)
+	}
+	return w.Flush()
+}
+
+// newFingerprintWatcher returns a fingerprint watcher set up as
+// [fingerprint] says.
+func newFingerprintWatcher() *fingerprint.Watcher {
+	w := &fingerprint.Watcher{Interval: cfg.Fingerprint.Interval}
+	if cfg.Fingerprint.MDNS {
+		w.Browse = func(ctx context.Context) (map[string][]string, error) {
+			return mdns.Browse(ctx, fingerprint.DefaultBrowse)
+		}
+	}
+	return w
+}
+
+// startFingerprints takes the fingerprints of the online devices as often as
+// [fingerprint] says, until ctx is done, so that a device that has started
+// to look like another is alerted about. It does nothing when the interval
+// is 0.
+func startFingerprints(ctx context.Context, store *storage.Storage) {
+	if cfg.Fingerprint.Interval <= 0 {
+		return
+	}
+	go newFingerprintWatcher().Run(ctx, store)
+}
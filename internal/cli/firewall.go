@@ -0,0 +1,204 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/firewall"
+	"github.com/291-Group/LAN-Orangutan/internal/scanner"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var (
+	firewallActive   bool
+	firewallNetworks []string
+	firewallGroup    string
+	firewallDryRun   bool
+)
+
+// vlanTagPrefix starts the tag that notes the VLAN a device imported from
+// the firewall is on, as in "vlan:IOT".
+const vlanTagPrefix = "vlan:"
+
+var firewallCmd = &cobra.Command{
+	Use:   "firewall",
+	Short: "Read devices from an OPNsense or pfSense firewall",
+	Long: `Read the ARP table and DHCP leases of the OPNsense or pfSense firewall in the
+[firewall] section. The firewall sees every VLAN it routes, including those
+this machine cannot reach to scan.`,
+}
+
+var firewallDevicesCmd = &cobra.Command{
+	Use:   "devices",
+	Short: "List the devices the firewall knows",
+	Long: `List the devices in the firewall's ARP table and DHCP leases, with the VLAN
+each is on and the label it has in the inventory.`,
+	Args: cobra.NoArgs,
+	RunE: runFirewallDevices,
+}
+
+var firewallImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Add the firewall's devices to the inventory",
+	Long: `Add the devices the firewall knows to the inventory, tagged with the VLAN
+they are on, as "vlan:IOT". Devices in the ARP table are recorded as seen
+now. Those only known from a DHCP lease may have left since, so they are
+added without a time and do not show as online; use --active to leave them
+out.
+
+Run it from cron or a systemd timer to keep VLANs this machine cannot scan
+up to date.`,
+	Args: cobra.NoArgs,
+	RunE: runFirewallImport,
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{firewallDevicesCmd, firewallImportCmd} {
+		cmd.Flags().BoolVar(&firewallActive, "active", false, "Only devices in the ARP table now")
+		cmd.Flags().StringSliceVar(&firewallNetworks, "network", nil, "Only devices in this network (repeatable)")
+		firewallCmd.AddCommand(cmd)
+	}
+	firewallImportCmd.Flags().StringVar(&firewallGroup, "group", "", "Put the imported devices in this group")
+	_ = firewallImportCmd.RegisterFlagCompletionFunc("group", completeGroups)
+	firewallImportCmd.Flags().BoolVar(&firewallDryRun, "dry-run", false, "Show what would change without saving")
+}
+
+// firewallDevices returns the devices the firewall knows that the flags
+// ask for.
+func firewallDevices() ([]firewall.Device, error) {
+	if cfg.Firewall.URL == "" {
+		return nil, errors.New("no firewall is set up; set url in the [firewall] section of the config file")
+	}
+	var networks []*net.IPNet
+	for _, cidr := range firewallNetworks {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("--network %s is not a network such as 10.0.20.0/24", cidr)
+		}
+		networks = append(networks, n)
+	}
+
+	client, err := firewall.New(cfg.Firewall.Options())
+	if err != nil {
+		return nil, fmt.Errorf("firewall: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	all, err := client.Devices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("asking the firewall: %w", err)
+	}
+
+	var devices []firewall.Device
+	for _, d := range all {
+		if firewallActive && !d.Active {
+			continue
+		}
+		if len(networks) > 0 && !inNetworks(net.ParseIP(d.IP), networks) {
+			continue
+		}
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
+
+// inNetworks reports whether ip is in any of networks.
+func inNetworks(ip net.IP, networks []*net.IPNet) bool {
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func runFirewallDevices(cmd *cobra.Command, args []string) error {
+	devices, err := firewallDevices()
+	if err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		fmt.Println("No devices found")
+		return nil
+	}
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IP\tMAC\tHOSTNAME\tVLAN\tSEEN\tLABEL")
+	fmt.Fprintln(w, "--\t---\t--------\t----\t----\t-----")
+	for _, d := range devices {
+		seen := "lease only"
+		if d.Active {
+			seen = "now"
+		}
+		label := ""
+		if stored := store.GetDevice(d.IP); stored != nil {
+			label = stored.Label
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", d.IP, dash(d.MAC), dash(d.Hostname), dash(d.Interface), seen, dash(label))
+	}
+	return w.Flush()
+}
+
+func runFirewallImport(cmd *cobra.Command, args []string) error {
+	found, err := firewallDevices()
+	if err != nil {
+		return err
+	}
+	if len(found) == 0 {
+		fmt.Println("No devices to import")
+		return nil
+	}
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	devices := make([]types.Device, 0, len(found))
+	for _, f := range found {
+		d := types.Device{
+			IP:       f.IP,
+			MAC:      f.MAC,
+			Hostname: f.Hostname,
+			Vendor:   f.Vendor,
+			Group:    firewallGroup,
+		}
+		if d.Vendor == "" && d.MAC != "" {
+			d.Vendor = scanner.GetMACVendor(d.MAC)
+		}
+		if f.Interface != "" {
+			d.Tags = []string{vlanTagPrefix + f.Interface}
+		}
+		if f.Active {
+			d.FirstSeen, d.LastSeen = now, now
+		}
+		devices = append(devices, d)
+	}
+
+	result, err := store.ImportDevices(devices, firewallDryRun)
+	if err != nil {
+		return fmt.Errorf("failed to save devices: %w", err)
+	}
+
+	verb := "Imported"
+	if firewallDryRun {
+		verb = "Would import"
+	}
+	fmt.Printf("%s %d devices: %d new, %d updated, %d unchanged\n",
+		verb, len(devices), result.Created, result.Updated, result.Unchanged)
+	if firewallDryRun {
+		fmt.Println("Dry run, nothing saved")
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var groupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "List, rename and assign device groups",
+	Long: `Manage device groups. A group exists while at least one device is in it,
+and group names match whatever their case, as they do in 'orangutan list'.`,
+}
+
+var groupListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List groups with how many devices each has",
+	Args:  cobra.NoArgs,
+	RunE:  runGroupList,
+}
+
+var groupRenameCmd = &cobra.Command{
+	Use:   "rename <group> <new-name>",
+	Short: "Rename a group on every device in it",
+	Long: `Rename a group on every device in it at once. Renaming to the name of a
+group that already exists merges the two.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runGroupRename,
+}
+
+var groupDeleteCmd = &cobra.Command{
+	Use:   "delete <group>",
+	Short: "Take every device out of a group",
+	Long:  `Remove a group by clearing it from every device in it. The devices themselves are kept.`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  runGroupDelete,
+}
+
+var groupAssignCmd = &cobra.Command{
+	Use:   "assign <group> <ip|mac>...",
+	Short: "Put devices in a group",
+	Args:  cobra.MinimumNArgs(2),
+	RunE:  runGroupAssign,
+}
+
+func init() {
+	groupCmd.AddCommand(groupListCmd)
+	groupCmd.AddCommand(groupRenameCmd)
+	groupCmd.AddCommand(groupDeleteCmd)
+	groupCmd.AddCommand(groupAssignCmd)
+}
+
+func runGroupList(cmd *cobra.Command, args []string) error {
+	devices, err := loadDevices(cmd)
+	if err != nil {
+		return err
+	}
+
+	// Groups are counted under the spelling most of their devices use.
+	type groupCount struct {
+		spellings map[string]int
+		total     int
+		online    int
+	}
+	groups := make(map[string]*groupCount)
+	ungrouped := 0
+	for _, d := range devices {
+		if d.Group == "" {
+			ungrouped++
+			continue
+		}
+		key := strings.ToLower(d.Group)
+		g := groups[key]
+		if g == nil {
+			g = &groupCount{spellings: make(map[string]int)}
+			groups[key] = g
+		}
+		g.spellings[d.Group]++
+		g.total++
+		if d.IsOnline() {
+			g.online++
+		}
+	}
+
+	if len(groups) == 0 {
+		fmt.Println("No groups")
+		return nil
+	}
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "GROUP\tDEVICES\tONLINE")
+	fmt.Fprintln(w, "-----\t-------\t------")
+	for _, key := range keys {
+		g := groups[key]
+		name := ""
+		for spelling, n := range g.spellings {
+			if n > g.spellings[name] || (n == g.spellings[name] && spelling < name) {
+				name = spelling
+			}
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\n", name, g.total, g.online)
+	}
+	if ungrouped > 0 {
+		fmt.Fprintf(w, "(none)\t%d\t\n", ungrouped)
+	}
+	return w.Flush()
+}
+
+func runGroupRename(cmd *cobra.Command, args []string) error {
+	name := strings.TrimSpace(args[1])
+	if name == "" {
+		return fmt.Errorf("the new name cannot be empty; use 'orangutan group delete' to remove a group")
+	}
+	n, err := assignGroup(cmd, inGroup(args[0]), name)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("no devices in group %q", args[0])
+	}
+	fmt.Printf("Renamed %q to %q on %d devices\n", args[0], name, n)
+	return nil
+}
+
+func runGroupDelete(cmd *cobra.Command, args []string) error {
+	n, err := assignGroup(cmd, inGroup(args[0]), "")
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("no devices in group %q", args[0])
+	}
+	fmt.Printf("Removed %d devices from %q\n", n, args[0])
+	return nil
+}
+
+func runGroupAssign(cmd *cobra.Command, args []string) error {
+	name := strings.TrimSpace(args[0])
+	if name == "" {
+		return fmt.Errorf("the group name cannot be empty")
+	}
+
+	// Every address is looked up before anything is changed, so a typo in
+	// the last one does not leave the rest half assigned.
+	devices, err := loadDevices(cmd)
+	if err != nil {
+		return err
+	}
+	wanted := make(map[string]bool)
+	for _, key := range args[1:] {
+		d, err := findDevice(devices, key)
+		if err != nil {
+			return err
+		}
+		wanted[d.IP] = true
+	}
+
+	n, err := assignGroup(cmd, func(d *types.Device) bool { return wanted[d.IP] }, name)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Put %d devices in %q\n", n, name)
+	return nil
+}
+
+// inGroup matches the devices in group, whatever its case.
+func inGroup(group string) func(*types.Device) bool {
+	group = strings.TrimSpace(group)
+	return func(d *types.Device) bool {
+		return d.Group != "" && strings.EqualFold(d.Group, group)
+	}
+}
+
+// assignGroup puts the devices match picks in group, through the server in
+// remote mode, and returns how many there were.
+func assignGroup(cmd *cobra.Command, match func(*types.Device) bool, group string) (int, error) {
+	c, err := remoteClient()
+	if err != nil {
+		return 0, err
+	}
+	if c != nil {
+		ctx, cancel := remoteContext()
+		defer cancel()
+		devices, err := c.Devices(ctx)
+		if err != nil {
+			return 0, err
+		}
+		ips := matchingIPs(devices, match)
+		if len(ips) == 0 {
+			return 0, nil
+		}
+		n, err := c.Batch(ctx, ips, "group", group)
+		if err != nil {
+			return 0, fmt.Errorf("failed to save devices: %w", err)
+		}
+		return n, nil
+	}
+
+	store, err := openStore(cmd)
+	if err != nil {
+		return 0, err
+	}
+	ips := matchingIPs(store.GetDevices(), match)
+	if len(ips) == 0 {
+		return 0, nil
+	}
+	n, err := store.UpdateDevices(ips, func(d *types.Device) { d.Group = group })
+	if err != nil {
+		return 0, fmt.Errorf("failed to save devices: %w", err)
+	}
+	return n, nil
+}
+
+// matchingIPs returns the addresses of the devices match picks.
+func matchingIPs(devices map[string]*types.Device, match func(*types.Device) bool) []string {
+	var ips []string
+	for ip, d := range devices {
+		if match(d) {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
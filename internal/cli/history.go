@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var historyDays int
+
+var historyCmd = &cobra.Command{
+	Use:   "history <ip|mac>",
+	Short: "Show the history of a device",
+	Long: `Print a timeline of one device: when it was online, when it appeared and
+went offline, and changes to its details such as a new hostname or label.
+
+When the device's MAC address is stored under other IP addresses too, as
+happens when DHCP hands out a new lease, their history is included. Give a
+MAC address to see every address it has used.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runHistory,
+}
+
+func init() {
+	historyCmd.Flags().IntVar(&historyDays, "days", 0, "Only show the last N days (default: everything kept)")
+}
+
+// historyEntry is one line of the timeline. End is set for stretches of
+// time, such as a sighting.
+type historyEntry struct {
+	start, end time.Time
+	text       string
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	devices := relatedDevices(store.GetDevices(), args[0])
+	if len(devices) == 0 {
+		return fmt.Errorf("device not found: %s", args[0])
+	}
+
+	var since time.Time
+	if historyDays > 0 {
+		since = time.Now().AddDate(0, 0, -historyDays)
+	}
+
+	ips := make(map[string]bool, len(devices))
+	var entries []historyEntry
+	for _, d := range devices {
+		ips[d.IP] = true
+		where := ""
+		if len(devices) > 1 {
+			where = " at " + d.IP
+		}
+
+		for _, sg := range store.GetSightings(d.IP, since) {
+			if sg.End.Equal(sg.Start) {
+				entries = append(entries, historyEntry{start: sg.Start, text: "Seen" + where})
+				continue
+			}
+			entries = append(entries, historyEntry{start: sg.Start, end: sg.End,
+				text: fmt.Sprintf("Online%s for %s", where, formatDuration(sg.End.Sub(sg.Start)))})
+		}
+		for _, c := range store.GetChanges(d.IP) {
+			if c.Time.Before(since) {
+				continue
+			}
+			entries = append(entries, historyEntry{start: c.Time, text: describeChange(c) + where})
+		}
+	}
+	for _, e := range store.GetEvents(0, false) {
+		if !ips[e.IP] || e.Time.Before(since) {
+			continue
+		}
+		switch e.Type {
+		case types.EventDeviceNew:
+			entries = append(entries, historyEntry{start: e.Time, text: fmt.Sprintf("Discovered at %s on %s", e.IP, e.Network)})
+		case types.EventDeviceOffline:
+			entries = append(entries, historyEntry{start: e.Time, text: fmt.Sprintf("Went offline from %s", e.IP)})
+		case types.EventDeviceAnomaly, types.EventCertExpiring, types.EventIntrusion:
+			entries = append(entries, historyEntry{start: e.Time, text: e.Message})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].start.Before(entries[j].start) })
+
+	printHistoryHeader(devices)
+	if len(entries) == 0 {
+		fmt.Println("No history recorded")
+		return nil
+	}
+
+	day := ""
+	for _, e := range entries {
+		if d := e.start.Format("Mon 2006-01-02"); d != day {
+			day = d
+			fmt.Printf("\n%s\n", day)
+		}
+		when := e.start.Format("15:04")
+		if !e.end.IsZero() {
+			end := e.end.Format("15:04")
+			if e.end.Format("2006-01-02") != e.start.Format("2006-01-02") {
+				end = e.end.Format("Jan 2 15:04")
+			}
+			when += " - " + end
+		}
+		fmt.Printf("  %-20s %s\n", when, e.text)
+	}
+	return nil
+}
+
+// relatedDevices returns the stored devices key refers to: the device at an
+// IP address along with any others sharing its MAC address, or every device
+// with a given MAC address. They are sorted by when they were last seen, most
+// recent first.
+func relatedDevices(devices map[string]*types.Device, key string) []*types.Device {
+	mac := ""
+	if d, ok := devices[key]; ok {
+		mac = d.MAC
+		if mac == "" {
+			return []*types.Device{d}
+		}
+	} else if hw, err := net.ParseMAC(key); err == nil {
+		mac = hw.String()
+	} else {
+		return nil
+	}
+
+	var result []*types.Device
+	for _, d := range devices {
+		if hw, err := net.ParseMAC(d.MAC); err == nil && strings.EqualFold(hw.String(), mac) {
+			result = append(result, d)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].LastSeen.After(result[j].LastSeen) })
+	return result
+}
+
+// printHistoryHeader sums up the device a history is about.
+func printHistoryHeader(devices []*types.Device) {
+	d := devices[0]
+	fmt.Println(deviceDisplayName(d))
+	if len(devices) > 1 {
+		addrs := make([]string, len(devices))
+		for i, dev := range devices {
+			addrs[i] = dev.IP
+		}
+		fmt.Printf("  Addresses:  %s\n", strings.Join(addrs, ", "))
+	} else {
+		fmt.Printf("  Address:    %s\n", d.IP)
+	}
+	if d.MAC != "" {
+		fmt.Printf("  MAC:        %s\n", d.MAC)
+	}
+
+	var first, last time.Time
+	for _, dev := range devices {
+		if !dev.FirstSeen.IsZero() && (first.IsZero() || dev.FirstSeen.Before(first)) {
+			first = dev.FirstSeen
+		}
+		if dev.LastSeen.After(last) {
+			last = dev.LastSeen
+		}
+	}
+	if !first.IsZero() {
+		fmt.Printf("  First seen: %s\n", first.Format("2006-01-02 15:04"))
+	}
+	if !last.IsZero() {
+		fmt.Printf("  Last seen:  %s\n", last.Format("2006-01-02 15:04"))
+	}
+}
+
+// describeChange puts a change to a device's details into words.
+func describeChange(c types.Change) string {
+	field := c.Field
+	if field == "mac" {
+		field = "MAC address"
+	}
+	field = strings.ToUpper(field[:1]) + field[1:]
+
+	switch {
+	case c.Old == "":
+		return fmt.Sprintf("%s set to %q", field, c.New)
+	case c.New == "":
+		return fmt.Sprintf("%s cleared (was %q)", field, c.Old)
+	default:
+		return fmt.Sprintf("%s changed from %q to %q", field, c.Old, c.New)
+	}
+}
+
+// formatDuration writes d to the nearest minute in the largest units that
+// fit, such as "3d 4h" or "45m".
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm", minutes)
+	default:
+		return "under a minute"
+	}
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/importer"
+	"github.com/291-Group/LAN-Orangutan/internal/scanner"
+)
+
+var (
+	importFormat string
+	importDryRun bool
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import device labels, groups and notes from CSV or JSON",
+	Long: `Load device details from a CSV or JSON file, such as one written by
+'orangutan export', the dashboard's export menu, a spreadsheet or another
+scanner. Use - to read from standard input.
+
+CSV files need a header row with an IP or MAC address column; Label (or
+Name), Group, Notes, Tags, Type, Hostname, Vendor, First Seen and Last Seen
+are picked up when present. Records are matched to stored devices by IP, then
+by MAC address, and records that match nothing are added as new devices.
+
+To move over from another tool, give the format of its export: fing for the
+CSV of the Fing app or command line tool, angryip for Angry IP Scanner's CSV,
+and netbox-csv for NetBox's list of IP addresses or devices. Their names,
+comments and descriptions become labels and notes, and Fing's device types
+become the nearest type here.
+
+Blank values never clear what is stored, so importing an old export is safe.`,
+	Example: `  orangutan import devices.csv
+  orangutan import fing-export.csv --format fing
+  orangutan import scan.csv --format angryip --dry-run`,
+	Args: cobra.ExactArgs(1),
+	RunE: runImport,
+}
+
+func init() {
+	importCmd.Flags().StringVar(&importFormat, "format", "", "File format: csv, json, fing, angryip or netbox-csv (default: csv or json, from the file name or content)")
+	importCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "Show what would change without saving")
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	var data []byte
+	var err error
+	if args[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", args[0], err)
+	}
+
+	format := importFormat
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(args[0])) {
+		case ".csv":
+			format = "csv"
+		case ".json":
+			format = "json"
+		}
+	}
+
+	devices, err := importer.Parse(data, format)
+	if err != nil {
+		return fmt.Errorf("failed to import %s: %w", args[0], err)
+	}
+	if len(devices) == 0 {
+		fmt.Println("No devices in the file")
+		return nil
+	}
+	for _, d := range devices {
+		if !scanner.ValidType(d.Type) {
+			return fmt.Errorf("failed to import %s: %s has unknown device type %q", args[0], d.IP, d.Type)
+		}
+	}
+
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	result, err := store.ImportDevices(devices, importDryRun)
+	if err != nil {
+		return fmt.Errorf("failed to save devices: %w", err)
+	}
+
+	verb := "Imported"
+	if importDryRun {
+		verb = "Would import"
+	}
+	fmt.Printf("%s %d devices: %d new, %d updated, %d unchanged\n",
+		verb, len(devices), result.Created, result.Updated, result.Unchanged)
+	if result.Skipped > 0 {
+		fmt.Printf("Skipped %d records with only a MAC address that matches no stored device\n", result.Skipped)
+	}
+	if importDryRun {
+		fmt.Println("Dry run, nothing saved")
+	}
+	return nil
+}
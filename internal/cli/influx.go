@@ -0,0 +1,19 @@
+package cli
+
+import (
+	"context"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/influx"
+	"github.com/291-Group/LAN-Orangutan/internal/storage"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// exportScan writes the scan of cidr, already merged into store, to the
+// InfluxDB in the [influxdb] section. It does nothing when none is set.
+func exportScan(store *storage.Storage, cidr string, result *types.ScanResult) error {
+	if cfg.InfluxDB.URL == "" {
+		return nil
+	}
+	return influx.New(cfg.InfluxDB.Options()).WriteScan(context.Background(), cidr, result, store.GetDevices(), time.Now())
+}
@@ -0,0 +1,235 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/scanner"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var (
+	inventoryAnsible bool
+	inventoryList    bool
+	inventoryHost    string
+	inventoryOnline  bool
+	inventoryGroup   string
+	inventoryOutput  string
+)
+
+var inventoryCmd = &cobra.Command{
+	Use:   "inventory",
+	Short: "Write the devices as an inventory for other tools",
+	Long: `Write the devices as an inventory other tools can read. --ansible writes an
+Ansible dynamic inventory: each device is a host, named by its label or
+hostname and reached at its address, in a group for its LAN Orangutan group,
+one for each tag (tag_NAME), one for its type (type_NAME), and "online" while
+it is online.
+
+Ansible runs an inventory script with --list or --host NAME, which this
+takes too, so a two line script makes the inventory live:
+
+  #!/bin/sh
+  exec orangutan inventory --ansible "$@"
+
+Or write a static copy with 'orangutan inventory --ansible -o hosts.json'.`,
+	Args: cobra.NoArgs,
+	RunE: runInventory,
+}
+
+func init() {
+	inventoryCmd.Flags().BoolVar(&inventoryAnsible, "ansible", false, "Write an Ansible dynamic inventory")
+	inventoryCmd.Flags().BoolVar(&inventoryList, "list", false, "Write the whole inventory, as Ansible asks (the default)")
+	inventoryCmd.Flags().StringVar(&inventoryHost, "host", "", "Write only the variables of this host, as Ansible asks")
+	inventoryCmd.Flags().BoolVar(&inventoryOnline, "online", false, "Only online devices")
+	inventoryCmd.Flags().StringVar(&inventoryGroup, "group", "", "Only devices in this group")
+	_ = inventoryCmd.RegisterFlagCompletionFunc("group", completeGroups)
+	inventoryCmd.Flags().StringVarP(&inventoryOutput, "output", "o", "", "Write to this file instead of standard output")
+	inventoryCmd.MarkFlagsMutuallyExclusive("list", "host")
+}
+
+func runInventory(cmd *cobra.Command, args []string) error {
+	if !inventoryAnsible {
+		return errors.New("say which inventory to write: --ansible")
+	}
+	devices, err := loadDevices(cmd)
+	if err != nil {
+		return err
+	}
+
+	var selected []*types.Device
+	for _, d := range devices {
+		if inventoryOnline && !d.IsOnline() {
+			continue
+		}
+		if inventoryGroup != "" && !strings.EqualFold(d.Group, inventoryGroup) {
+			continue
+		}
+		selected = append(selected, d)
+	}
+	inv := ansibleInventory(selected)
+
+	var reply any = inv
+	if inventoryHost != "" {
+		// Every host's variables are in _meta already, so Ansible only asks
+		// for one when run against an older script; unknown hosts have none.
+		vars := inv.Meta.HostVars[inventoryHost]
+		if vars == nil {
+			vars = map[string]any{}
+		}
+		reply = vars
+	}
+
+	out := io.Writer(os.Stdout)
+	if inventoryOutput != "" {
+		file, err := os.Create(inventoryOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create file: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(reply)
+}
+
+// ansibleGroup is a group of an Ansible inventory.
+type ansibleGroup struct {
+	Hosts    []string `json:"hosts,omitempty"`
+	Children []string `json:"children,omitempty"`
+}
+
+// ansibleInv is an Ansible dynamic inventory as --list returns it: the
+// groups by name, and every host's variables under _meta, so Ansible need
+// not ask for each host in turn.
+type ansibleInv struct {
+	Groups map[string]*ansibleGroup
+	Meta   struct {
+		HostVars map[string]map[string]any `json:"hostvars"`
+	}
+}
+
+func (inv ansibleInv) MarshalJSON() ([]byte, error) {
+	all := make(map[string]any, len(inv.Groups)+1)
+	for name, g := range inv.Groups {
+		all[name] = g
+	}
+	all["_meta"] = inv.Meta
+	return json.Marshal(all)
+}
+
+// ansibleInventory returns the inventory of devices.
+func ansibleInventory(devices []*types.Device) ansibleInv {
+	sort.Slice(devices, func(i, j int) bool { return types.LessIP(devices[i].IP, devices[j].IP) })
+
+	inv := ansibleInv{Groups: map[string]*ansibleGroup{}}
+	inv.Meta.HostVars = make(map[string]map[string]any)
+	var hosts []string
+	add := func(group, host string) {
+		g := inv.Groups[group]
+		if g == nil {
+			g = &ansibleGroup{}
+			inv.Groups[group] = g
+		}
+		g.Hosts = append(g.Hosts, host)
+	}
+
+	for _, d := range devices {
+		host := ansibleHostName(d)
+		// Labels and hostnames need not be unique, but host names must be.
+		if _, taken := inv.Meta.HostVars[host]; taken {
+			host = d.IP
+		}
+
+		deviceType := d.Type
+		if deviceType == "" {
+			deviceType = scanner.DetectType(d)
+		}
+		vars := map[string]any{
+			"ansible_host":        d.IP,
+			"orangutan_ip":        d.IP,
+			"orangutan_online":    d.IsOnline(),
+			"orangutan_last_seen": d.LastSeen.UTC().Format(time.RFC3339),
+		}
+		for key, value := range map[string]string{
+			"orangutan_mac":      d.MAC,
+			"orangutan_hostname": d.Hostname,
+			"orangutan_vendor":   scanner.ResolveVendor(d.Vendor, d.MAC),
+			"orangutan_label":    d.Label,
+			"orangutan_group":    d.Group,
+			"orangutan_type":     deviceType,
+		} {
+			if value != "" && value != "Unknown" {
+				vars[key] = value
+			}
+		}
+		if len(d.Tags) > 0 {
+			vars["orangutan_tags"] = d.Tags
+		}
+		inv.Meta.HostVars[host] = vars
+
+		hosts = append(hosts, host)
+		if d.Group != "" {
+			add(ansibleGroupName("", d.Group), host)
+		}
+		for _, tag := range d.Tags {
+			add(ansibleGroupName("tag_", tag), host)
+		}
+		if deviceType != "" {
+			add(ansibleGroupName("type_", deviceType), host)
+		}
+		if d.IsOnline() {
+			add("online", host)
+		}
+	}
+
+	children := make([]string, 0, len(inv.Groups))
+	for name := range inv.Groups {
+		children = append(children, name)
+	}
+	sort.Strings(children)
+	// Every host is in all, so those in no other group are still listed.
+	inv.Groups["all"] = &ansibleGroup{Hosts: hosts, Children: children}
+	return inv
+}
+
+// ansibleHostName names d as a host: its label, or else its hostname, made
+// safe to type on a command line, or else its address.
+func ansibleHostName(d *types.Device) string {
+	for _, name := range []string{d.Label, d.Hostname} {
+		name = strings.ToLower(strings.Join(strings.Fields(name), "-"))
+		if name != "" {
+			return name
+		}
+	}
+	return d.IP
+}
+
+// ansibleGroupName turns name into a group name Ansible accepts, which
+// holds only letters, digits and underscores and does not start with a
+// digit, after prefix.
+func ansibleGroupName(prefix, name string) string {
+	var b strings.Builder
+	b.WriteString(prefix)
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	s := b.String()
+	if s == "" || s[0] >= '0' && s[0] <= '9' {
+		s = "_" + s
+	}
+	return s
+}
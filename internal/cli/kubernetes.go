@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/kube"
+)
+
+var (
+	kubernetesGroup  string
+	kubernetesDryRun bool
+)
+
+var kubernetesCmd = &cobra.Command{
+	Use:     "kubernetes",
+	Aliases: []string{"k8s"},
+	Short:   "Tell the nodes of a Kubernetes cluster apart",
+	Long: `Read the nodes of the Kubernetes cluster in the kubeconfig from the
+[kubernetes] section, or the one kubectl uses. With enable = true there,
+every scan names the nodes it finds and tags them k8s:node, and
+k8s:control-plane for those running the control plane.
+
+The kubeconfig must log in with a client certificate or a token, as the
+one k3s writes to /etc/rancher/k3s/k3s.yaml does; exec plugins are not
+supported. Listing nodes is all it needs.`,
+}
+
+var kubernetesNodesCmd = &cobra.Command{
+	Use:   "nodes",
+	Short: "List the nodes, their addresses and pod networks",
+	Long: `List the cluster's nodes with their addresses, the networks their pods get
+addresses in, and the label each has in the inventory.`,
+	Args: cobra.NoArgs,
+	RunE: runKubernetesNodes,
+}
+
+var kubernetesImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Add the nodes to the inventory",
+	Long: `Add the cluster's nodes to the inventory, named after the node and tagged
+k8s:node. Ready nodes are recorded as seen now.`,
+	Args: cobra.NoArgs,
+	RunE: runKubernetesImport,
+}
+
+func init() {
+	kubernetesCmd.AddCommand(kubernetesNodesCmd, kubernetesImportCmd)
+	kubernetesImportCmd.Flags().StringVar(&kubernetesGroup, "group", "", "Put the imported nodes in this group")
+	_ = kubernetesImportCmd.RegisterFlagCompletionFunc("group", completeGroups)
+	kubernetesImportCmd.Flags().BoolVar(&kubernetesDryRun, "dry-run", false, "Show what would change without saving")
+}
+
+// kubernetesNodes returns the nodes of the cluster.
+func kubernetesNodes() ([]kube.Node, error) {
+	client, err := kube.New(cfg.Kubernetes.Kubeconfig, cfg.Kubernetes.Context)
+	if err != nil {
+		return nil, fmt.Errorf("kubeconfig: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	nodes, err := client.Nodes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("asking the cluster: %w", err)
+	}
+	return nodes, nil
+}
+
+func runKubernetesNodes(cmd *cobra.Command, args []string) error {
+	nodes, err := kubernetesNodes()
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		fmt.Println("No nodes found")
+		return nil
+	}
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tIP\tROLE\tREADY\tPOD CIDR\tVERSION\tLABEL")
+	fmt.Fprintln(w, "----\t--\t----\t-----\t--------\t-------\t-----")
+	for _, n := range nodes {
+		role := "worker"
+		if n.ControlPlane {
+			role = "control-plane"
+		}
+		ready := "no"
+		if n.Ready {
+			ready = "yes"
+		}
+		label := ""
+		if stored := store.GetDevice(n.InternalIP); stored != nil {
+			label = stored.Label
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", n.Name, dash(n.InternalIP), role, ready,
+			dash(strings.Join(n.PodCIDRs, ", ")), dash(n.Kubelet), dash(label))
+	}
+	return w.Flush()
+}
+
+func runKubernetesImport(cmd *cobra.Command, args []string) error {
+	nodes, err := kubernetesNodes()
+	if err != nil {
+		return err
+	}
+	devices := kube.NodeDevices(nodes, nil, time.Now())
+	if len(devices) == 0 {
+		fmt.Println("No nodes to import")
+		return nil
+	}
+	for i := range devices {
+		devices[i].Group = kubernetesGroup
+	}
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	result, err := store.ImportDevices(devices, kubernetesDryRun)
+	if err != nil {
+		return fmt.Errorf("failed to save devices: %w", err)
+	}
+
+	verb := "Imported"
+	if kubernetesDryRun {
+		verb = "Would import"
+	}
+	fmt.Printf("%s %d node addresses: %d new, %d updated, %d unchanged\n",
+		verb, len(devices), result.Created, result.Updated, result.Unchanged)
+	if kubernetesDryRun {
+		fmt.Println("Dry run, nothing saved")
+	}
+	return nil
+}
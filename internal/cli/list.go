@@ -2,17 +2,20 @@ package cli
 
 import (
 	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
-	"net"
+	"io"
 	"os"
 	"sort"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 
 	"github.com/291-Group/LAN-Orangutan/internal/scanner"
-	"github.com/291-Group/LAN-Orangutan/internal/storage"
 	"github.com/291-Group/LAN-Orangutan/internal/types"
 )
 
@@ -21,30 +24,56 @@ var (
 	listOffline bool
 	listGroup   string
 	listFormat  string
+	listOutput  string
+	listColumns string
+
+	listFailOnMissing bool
+	listFailOnUnknown bool
 )
 
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List discovered devices",
-	Long:  `List all discovered devices with optional filtering by status or group.`,
-	RunE:  runList,
+	Long: `List all discovered devices with optional filtering by status or group.
+
+The table and CSV output can show other columns than their usual ones with
+--columns, in the order given:
+
+  orangutan list --columns ip,label,vendor,last_seen
+  orangutan list --format csv --columns ip,mac,type,tags
+
+--fail-on-missing exits with status 1 when a listed device is offline, and
+--fail-on-unknown when one has no label, so a check such as
+'orangutan list --group servers --fail-on-missing' can run from cron. Errors
+exit with status 2.`,
+	RunE: runList,
 }
 
 func init() {
 	listCmd.Flags().BoolVar(&listOnline, "online", false, "Show only online devices")
 	listCmd.Flags().BoolVar(&listOffline, "offline", false, "Show only offline devices")
 	listCmd.Flags().StringVar(&listGroup, "group", "", "Filter by group")
-	listCmd.Flags().StringVar(&listFormat, "format", "table", "Output format (table, csv, json)")
+	_ = listCmd.RegisterFlagCompletionFunc("group", completeGroups)
+	listCmd.Flags().BoolVar(&listFailOnMissing, "fail-on-missing", false, "Exit with status 1 if a listed device is offline")
+	listCmd.Flags().BoolVar(&listFailOnUnknown, "fail-on-unknown", false, "Exit with status 1 if a listed device has no label")
+	listCmd.Flags().StringVar(&listFormat, "format", "table", "Output format (table, csv, json, yaml, xml)")
+	listCmd.Flags().StringVarP(&listOutput, "output", "o", "", "Write to this file instead of standard output")
+	listCmd.Flags().StringVar(&listColumns, "columns", "", "Comma-separated columns for the table or CSV ("+deviceColumnNames()+")")
 }
 
 func runList(cmd *cobra.Command, args []string) error {
-	// Initialize storage
-	store, err := storage.New(cfg.DevicesFile(), cfg.StateFile())
+	columns, err := parseColumns(listColumns)
 	if err != nil {
-		return fmt.Errorf("failed to initialize storage: %w", err)
+		return err
+	}
+	if columns != nil && listFormat != "table" && listFormat != "csv" {
+		return fmt.Errorf("--columns applies to the table and CSV; %s output has every field", listFormat)
 	}
 
-	devices := store.GetDevices()
+	devices, err := loadDevices(cmd)
+	if err != nil {
+		return err
+	}
 
 	// Convert to slice and filter
 	var filtered []*types.Device
@@ -64,107 +93,253 @@ func runList(cmd *cobra.Command, args []string) error {
 
 	// Sort by IP
 	sort.Slice(filtered, func(i, j int) bool {
-		return ipToSortKey(filtered[i].IP) < ipToSortKey(filtered[j].IP)
+		return types.LessIP(filtered[i].IP, filtered[j].IP)
 	})
 
-	// Output based on format
-	switch listFormat {
+	if listOutput == "" {
+		if err := outputDevices(os.Stdout, listFormat, columns, filtered); err != nil {
+			return err
+		}
+		return listFailOn(filtered)
+	}
+
+	file, err := os.Create(listOutput)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	if err := outputDevices(file, listFormat, columns, filtered); err != nil {
+		file.Close()
+		return err
+	}
+	// A full disk may only show when what is buffered is written out.
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", listOutput, err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d devices to %s\n", len(filtered), listOutput)
+	return listFailOn(filtered)
+}
+
+// listFailOn applies --fail-on-missing and --fail-on-unknown to the listed
+// devices.
+func listFailOn(devices []*types.Device) error {
+	offline, unknown := 0, 0
+	for _, d := range devices {
+		if !d.IsOnline() {
+			offline++
+		}
+		if d.Label == "" {
+			unknown++
+		}
+	}
+	var reasons []string
+	if listFailOnMissing && offline > 0 {
+		reasons = append(reasons, fmt.Sprintf("offline devices: %d", offline))
+	}
+	if listFailOnUnknown && unknown > 0 {
+		reasons = append(reasons, fmt.Sprintf("devices without a label: %d", unknown))
+	}
+	if len(reasons) == 0 {
+		return nil
+	}
+	return &foundError{reason: strings.Join(reasons, ", ")}
+}
+
+// outputDevices writes devices in format, one of those --format takes.
+// Anything else gets the table. The table and CSV show columns, or their
+// usual ones when it is nil; the other formats always have every field.
+func outputDevices(out io.Writer, format string, columns []deviceColumn, devices []*types.Device) error {
+	switch format {
 	case "csv":
-		return outputCSV(filtered)
+		if columns == nil {
+			columns = mustColumns(defaultCSVColumns)
+		}
+		return outputCSV(out, columns, devices)
 	case "json":
-		return outputJSON(filtered)
+		return outputJSON(out, devices)
+	case "yaml":
+		return outputYAML(out, devices)
+	case "xml":
+		return outputXML(out, devices)
+	default:
+		if columns == nil {
+			columns = mustColumns(defaultTableColumns)
+		}
+		return outputTable(out, columns, devices)
+	}
+}
+
+// deviceStatus describes how recently d was seen, as the dashboard's status
+// dot does.
+func deviceStatus(d *types.Device) string {
+	switch {
+	case d.IsRecent():
+		return "online"
+	case d.IsOnline():
+		return "seen"
 	default:
-		return outputTable(filtered)
+		return "offline"
 	}
 }
 
-func outputTable(devices []*types.Device) error {
+func outputTable(out io.Writer, columns []deviceColumn, devices []*types.Device) error {
 	if len(devices) == 0 {
-		fmt.Println("No devices found")
+		fmt.Fprintln(out, "No devices found")
 		return nil
 	}
 
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "IP\tMAC\tHOSTNAME\tVENDOR\tLABEL\tGROUP\tSTATUS")
-	fmt.Fprintln(w, "--\t---\t--------\t------\t-----\t-----\t------")
+	headers := make([]string, len(columns))
+	rules := make([]string, len(columns))
+	for i, c := range columns {
+		headers[i] = strings.ToUpper(c.Header)
+		rules[i] = strings.Repeat("-", len(c.Header))
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	fmt.Fprintln(w, strings.Join(rules, "\t"))
 
+	row := make([]string, len(columns))
 	for _, d := range devices {
-		status := "offline"
-		if d.IsRecent() {
-			status = "online"
-		} else if d.IsOnline() {
-			status = "seen"
-		}
-
-		hostname := d.Hostname
-		if len(hostname) > 25 {
-			hostname = hostname[:22] + "..."
-		}
-
-		vendor := scanner.ResolveVendor(d.Vendor, d.MAC)
-		if len(vendor) > 20 {
-			vendor = vendor[:17] + "..."
+		for i, c := range columns {
+			row[i] = c.Value(d)
+			if c.Width > 0 {
+				row[i] = truncate(row[i], c.Width)
+			}
 		}
-
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
-			d.IP, d.MAC, hostname, vendor, d.Label, d.Group, status)
+		fmt.Fprintln(w, strings.Join(row, "\t"))
 	}
 
 	return w.Flush()
 }
 
-func outputCSV(devices []*types.Device) error {
-	w := csv.NewWriter(os.Stdout)
-	defer w.Flush()
+func outputCSV(out io.Writer, columns []deviceColumn, devices []*types.Device) error {
+	w := csv.NewWriter(out)
 
-	// Header
-	if err := w.Write([]string{"IP", "MAC", "Hostname", "Vendor", "Label", "Notes", "Group", "First Seen", "Last Seen"}); err != nil {
+	row := make([]string, len(columns))
+	for i, c := range columns {
+		row[i] = c.Header
+	}
+	if err := w.Write(row); err != nil {
 		return err
 	}
 
 	for _, d := range devices {
-		if err := w.Write([]string{
-			d.IP,
-			d.MAC,
-			d.Hostname,
-			scanner.ResolveVendor(d.Vendor, d.MAC),
-			d.Label,
-			d.Notes,
-			d.Group,
-			d.FirstSeen.Format("2006-01-02 15:04:05"),
-			d.LastSeen.Format("2006-01-02 15:04:05"),
-		}); err != nil {
+		for i, c := range columns {
+			row[i] = c.Value(d)
+		}
+		if err := w.Write(row); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	w.Flush()
+	return w.Error()
+}
+
+// listedDevice is a device as the JSON output gives it: every stored field,
+// with the vendor resolved and the status worked out, so scripts do not have
+// to repeat either calculation.
+type listedDevice struct {
+	*types.Device
+	Vendor string `json:"vendor"`
+	Status string `json:"status"`
+}
+
+func outputJSON(out io.Writer, devices []*types.Device) error {
+	listed := make([]listedDevice, len(devices))
+	for i, d := range devices {
+		listed[i] = listedDevice{
+			Device: d,
+			Vendor: scanner.ResolveVendor(d.Vendor, d.MAC),
+			Status: deviceStatus(d),
+		}
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(listed)
+}
+
+// deviceRecord is a device as the YAML and XML output give it: the same
+// fields and names as the JSON output, spelled out since the stored device
+// only carries JSON tags.
+type deviceRecord struct {
+	IP           string          `yaml:"ip" xml:"ip"`
+	MAC          string          `yaml:"mac" xml:"mac"`
+	Hostname     string          `yaml:"hostname" xml:"hostname"`
+	Vendor       string          `yaml:"vendor" xml:"vendor"`
+	Label        string          `yaml:"label" xml:"label"`
+	Notes        string          `yaml:"notes" xml:"notes"`
+	Group        string          `yaml:"group" xml:"group"`
+	Type         string          `yaml:"type,omitempty" xml:"type,omitempty"`
+	Tags         []string        `yaml:"tags,omitempty" xml:"tags>tag,omitempty"`
+	FirstSeen    time.Time       `yaml:"first_seen" xml:"first_seen"`
+	LastSeen     time.Time       `yaml:"last_seen" xml:"last_seen"`
+	ResponseTime *float64        `yaml:"response_time,omitempty" xml:"response_time,omitempty"`
+	Wireless     *wirelessRecord `yaml:"wireless,omitempty" xml:"wireless,omitempty"`
+	Status       string          `yaml:"status" xml:"status"`
+}
+
+// wirelessRecord is the Wi-Fi part of a deviceRecord.
+type wirelessRecord struct {
+	SSID        string    `yaml:"ssid" xml:"ssid"`
+	Band        string    `yaml:"band,omitempty" xml:"band,omitempty"`
+	Channel     int       `yaml:"channel,omitempty" xml:"channel,omitempty"`
+	Signal      int       `yaml:"signal" xml:"signal"`
+	AccessPoint string    `yaml:"access_point,omitempty" xml:"access_point,omitempty"`
+	Seen        time.Time `yaml:"seen" xml:"seen"`
 }
 
-func outputJSON(devices []*types.Device) error {
-	// Simple JSON output
-	fmt.Println("[")
+func deviceRecords(devices []*types.Device) []deviceRecord {
+	records := make([]deviceRecord, len(devices))
 	for i, d := range devices {
-		comma := ","
-		if i == len(devices)-1 {
-			comma = ""
+		records[i] = deviceRecord{
+			IP:           d.IP,
+			MAC:          d.MAC,
+			Hostname:     d.Hostname,
+			Vendor:       scanner.ResolveVendor(d.Vendor, d.MAC),
+			Label:        d.Label,
+			Notes:        d.Notes,
+			Group:        d.Group,
+			Type:         d.Type,
+			Tags:         d.Tags,
+			FirstSeen:    d.FirstSeen,
+			LastSeen:     d.LastSeen,
+			ResponseTime: d.ResponseTime,
+			Status:       deviceStatus(d),
 		}
-		fmt.Printf("  {\"ip\": %q, \"mac\": %q, \"hostname\": %q, \"vendor\": %q, \"label\": %q, \"group\": %q}%s\n",
-			d.IP, d.MAC, d.Hostname, scanner.ResolveVendor(d.Vendor, d.MAC), d.Label, d.Group, comma)
+		if w := d.Wireless; w != nil {
+			records[i].Wireless = &wirelessRecord{
+				SSID: w.SSID, Band: w.Band, Channel: w.Channel,
+				Signal: w.Signal, AccessPoint: w.AccessPoint, Seen: w.Seen,
+			}
+		}
+	}
+	return records
+}
+
+func outputYAML(out io.Writer, devices []*types.Device) error {
+	enc := yaml.NewEncoder(out)
+	enc.SetIndent(2)
+	if err := enc.Encode(deviceRecords(devices)); err != nil {
+		return err
 	}
-	fmt.Println("]")
-	return nil
+	return enc.Close()
 }
 
-// ipToSortKey converts an IP to a sortable integer
-func ipToSortKey(ipStr string) int64 {
-	ip := net.ParseIP(ipStr)
-	if ip == nil {
-		return 0x7FFFFFFFFFFFFFFF // Sort invalid IPs last
+func outputXML(out io.Writer, devices []*types.Device) error {
+	doc := struct {
+		XMLName xml.Name       `xml:"devices"`
+		Devices []deviceRecord `xml:"device"`
+	}{Devices: deviceRecords(devices)}
+
+	if _, err := io.WriteString(out, xml.Header); err != nil {
+		return err
 	}
-	ip = ip.To4()
-	if ip == nil {
-		return 0x7FFFFFFFFFFFFFFF
+	enc := xml.NewEncoder(out)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
 	}
-	return int64(ip[0])<<24 | int64(ip[1])<<16 | int64(ip[2])<<8 | int64(ip[3])
+	_, err := io.WriteString(out, "\n")
+	return err
 }
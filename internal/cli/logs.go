@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var (
+	logsLines  int
+	logsTypes  []string
+	logsDevice string
+	logsFollow bool
+)
+
+// logsPollInterval is how often --follow checks the event log for new
+// entries. Events come from scans minutes apart, so there is no need to
+// watch the file more closely.
+const logsPollInterval = 2 * time.Second
+
+// logTypes maps the short names accepted by --type to event types.
+var logTypes = map[string]string{
+	"new":       types.EventDeviceNew,
+	"offline":   types.EventDeviceOffline,
+	"failed":    types.EventScanFailed,
+	"anomaly":   types.EventDeviceAnomaly,
+	"arrival":   types.EventPersonArrived,
+	"departure": types.EventPersonLeft,
+	"cert":      types.EventCertExpiring,
+	"intrusion": types.EventIntrusion,
+}
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Show the event log",
+	Long: `Print the most recent entries of the event log: devices joining and
+leaving the network, devices changing in ways that suggest spoofing, devices
+turning up where they are not allowed, people arriving home and leaving,
+certificates about to expire, and scans that failed. With -f, keep running and print new entries as scans record them,
+like tail -f.
+
+  orangutan logs -n 50
+  orangutan logs --type new --type offline -f
+  orangutan logs --device 192.168.1.20`,
+	Args: cobra.NoArgs,
+	RunE: runLogs,
+}
+
+func init() {
+	logsCmd.Flags().IntVarP(&logsLines, "lines", "n", 20, "Number of entries to show (0 for all)")
+	logsCmd.Flags().StringArrayVar(&logsTypes, "type", nil, "Only show this kind of event: new, offline, failed, anomaly, arrival, departure, cert or intrusion (repeatable)")
+	logsCmd.Flags().StringVar(&logsDevice, "device", "", "Only show events for this IP or MAC address")
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Keep printing new events as they happen")
+}
+
+func runLogs(cmd *cobra.Command, args []string) error {
+	wantTypes := make(map[string]bool)
+	for _, t := range logsTypes {
+		typ, ok := logTypes[strings.ToLower(t)]
+		if !ok {
+			return fmt.Errorf("unknown event type %q (use new, offline, failed, anomaly, arrival, departure, cert or intrusion)", t)
+		}
+		wantTypes[typ] = true
+	}
+
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	// Events name devices by IP. A MAC address stands for every address the
+	// device has been stored under; an address with no device, perhaps one
+	// since deleted, still matches its old events.
+	var wantIPs map[string]bool
+	if logsDevice != "" {
+		wantIPs = map[string]bool{logsDevice: true}
+		for _, d := range relatedDevices(store.GetDevices(), logsDevice) {
+			wantIPs[d.IP] = true
+		}
+	}
+
+	match := func(e types.Event) bool {
+		if len(wantTypes) > 0 && !wantTypes[e.Type] {
+			return false
+		}
+		return wantIPs == nil || wantIPs[e.IP]
+	}
+
+	// GetEvents returns newest first; collect the last logsLines matches and
+	// print them oldest first, as a log reads.
+	var shown []types.Event
+	for _, e := range store.GetEvents(0, false) {
+		if !match(e) {
+			continue
+		}
+		shown = append(shown, e)
+		if logsLines > 0 && len(shown) == logsLines {
+			break
+		}
+	}
+	var lastID int64
+	for i := len(shown) - 1; i >= 0; i-- {
+		printEvent(shown[i])
+	}
+	if events := store.GetEvents(1, false); len(events) > 0 {
+		lastID = events[0].ID
+	}
+
+	if !logsFollow {
+		if len(shown) == 0 {
+			fmt.Println("No events")
+		}
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(logsPollInterval):
+		}
+
+		if err := store.ReloadEvents(); err != nil {
+			return fmt.Errorf("failed to read the event log: %w", err)
+		}
+		events := store.GetEvents(0, false)
+		for i := len(events) - 1; i >= 0; i-- {
+			e := events[i]
+			if e.ID <= lastID {
+				continue
+			}
+			lastID = e.ID
+			if match(e) {
+				printEvent(e)
+			}
+		}
+	}
+}
+
+// printEvent writes an event as one line of the log.
+func printEvent(e types.Event) {
+	kind := e.Type
+	for short, typ := range logTypes {
+		if typ == e.Type {
+			kind = short
+		}
+	}
+	fmt.Printf("%s  %-7s  %s\n", e.Time.Format("2006-01-02 15:04:05"), kind, e.Message)
+}
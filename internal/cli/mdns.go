@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/291-Group/LAN-Orangutan/internal/mdns"
+)
+
+// startMDNS advertises the server on port with multicast DNS, when the
+// config asks for it and the server can be reached from the network. The
+// name is checked for clashes first, which takes a moment, so it runs in
+// the background and prints the address once it is claimed.
+func startMDNS(ctx context.Context, port int) {
+	if !cfg.Server.MDNS || cfg.IsLoopbackBind() {
+		return
+	}
+	machine, _ := os.Hostname()
+	machine, _, _ = strings.Cut(machine, ".")
+	instance := "LAN Orangutan"
+	if machine != "" {
+		instance += " on " + machine
+	}
+	go func() {
+		host, err := mdns.Advertise(ctx, mdns.Service{
+			Host:     cfg.Server.MDNSName,
+			Instance: instance,
+			Type:     "_http._tcp",
+			Port:     port,
+			TXT:      []string{"path=/"},
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Warn("not advertising the server with mDNS", "error", err)
+			}
+			return
+		}
+		if host != cfg.Server.MDNSName {
+			slog.Warn("mDNS name taken by another machine; using another", "wanted", cfg.Server.MDNSName+".local", "using", host+".local")
+		}
+		fmt.Printf("mDNS:           http://%s\n", net.JoinHostPort(host+".local", strconv.Itoa(port)))
+	}()
+}
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/metrics"
+	"github.com/291-Group/LAN-Orangutan/internal/storage"
+)
+
+var metricsOutput string
+
+var metricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "Write metrics for Prometheus's node_exporter",
+	Long: `Write device counts, whether each device is up, and scan times in the
+Prometheus text format, for node_exporter's textfile collector to pick up.
+Nothing listens for Prometheus to scrape; node_exporter serves the file.
+
+Run it from cron, writing into the collector's directory:
+
+  */5 * * * * orangutan metrics -o /var/lib/node_exporter/textfile_collector/lan_orangutan.prom
+
+or set textfile in the [metrics] section, and the server and monitor keep the
+file up to date themselves.`,
+	Args: cobra.NoArgs,
+	RunE: runMetrics,
+}
+
+func init() {
+	metricsCmd.Flags().StringVarP(&metricsOutput, "output", "o", "", "Write to this file instead of standard output")
+}
+
+func runMetrics(cmd *cobra.Command, args []string) error {
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+	if metricsOutput == "" {
+		return metrics.Write(os.Stdout, metricsInput(store))
+	}
+	absPath, err := filepath.Abs(metricsOutput)
+	if err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+	if err := metrics.WriteFile(absPath, metricsInput(store)); err != nil {
+		return fmt.Errorf("failed to write %s: %w", absPath, err)
+	}
+	return nil
+}
+
+// metricsInput gathers what the metrics are made from out of store.
+func metricsInput(store *storage.Storage) metrics.Input {
+	in := metrics.Input{Devices: store.GetDevices()}
+	for _, network := range store.ScannedNetworks() {
+		in.Scans = append(in.Scans, metrics.Scan{
+			Network:  network,
+			Last:     store.GetLastScan(network),
+			Duration: time.Duration(store.GetLastDuration(network) * float64(time.Second)),
+		})
+	}
+	return in
+}
+
+// startTextfile writes the metrics of store to the textfile in the [metrics]
+// section every textfile_interval seconds until ctx is done. It does nothing
+// when no textfile is set.
+func startTextfile(ctx context.Context, store *storage.Storage) {
+	path := cfg.Metrics.Textfile
+	if path == "" || cfg.Metrics.TextfileInterval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(cfg.Metrics.TextfileInterval) * time.Second)
+		defer ticker.Stop()
+		// Logged when writing starts failing and again when it recovers, not
+		// every interval in between.
+		failing := false
+		for {
+			err := metrics.WriteFile(path, metricsInput(store))
+			switch {
+			case err != nil && !failing:
+				slog.Warn("cannot write the metrics file", "file", path, "error", err)
+			case err == nil && failing:
+				slog.Info("writing the metrics file again", "file", path)
+			}
+			failing = err != nil
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,212 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/storage"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var (
+	monitorInterval int
+	monitorNotify   string
+)
+
+// monitorMaxNotifications is how many events of one round get a notification
+// each. Beyond that they are summed up in one, so the first scan of a busy
+// network does not bury the desktop.
+const monitorMaxNotifications = 3
+
+var monitorCmd = &cobra.Command{
+	Use:   "monitor [network|all]",
+	Short: "Scan in the background and notify about new and offline devices",
+	Long: `Scan a network at the configured interval, as the server does, and raise a
+desktop notification when a device joins or leaves. Nothing is drawn, so it
+can be left running in a spare terminal; press Ctrl+C to stop.
+
+Notifications use notify-send on Linux and the notification centre on macOS.
+Where neither is available, or with --notify bell, the terminal bell rings
+instead. The network argument works as it does for scan.
+
+With a broker set in the [mqtt] section, device events and snapshots are
+published to it as well, and with a textfile set in [metrics], metrics are
+written to it, as the server does. Alerts, the watchlist, the TLS
+certificate checks and the device fingerprints run as they do in the server
+too.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runMonitor,
+}
+
+func init() {
+	monitorCmd.Flags().IntVar(&monitorInterval, "interval", 0, "Seconds between scans of every network (default: each network's schedule or scan_interval)")
+	monitorCmd.Flags().StringVar(&monitorNotify, "notify", "desktop", "How to notify: desktop, bell or none")
+}
+
+func runMonitor(cmd *cobra.Command, args []string) error {
+	notify := strings.ToLower(monitorNotify)
+	switch notify {
+	case "desktop", "bell", "none":
+	default:
+		return fmt.Errorf("unknown --notify %q (use desktop, bell or none)", monitorNotify)
+	}
+	if notify == "desktop" && !desktopNotifications() {
+		fmt.Println("No desktop notifications on this system; ringing the terminal bell instead")
+		notify = "bell"
+	}
+
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	networks, err := resolveNetworks(args)
+	if err != nil {
+		return err
+	}
+
+	state, err := newWatchState(networks, monitorInterval)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s := cfg.NewScanner()
+	warnUnprivileged(s)
+	startMQTT(ctx, store)
+	startTextfile(ctx, store)
+	startAlerts(ctx, store)
+	startReports(ctx, store)
+	startWatch(ctx, store)
+	startCerts(ctx, store)
+	startFingerprints(ctx, store)
+
+	fmt.Printf("Monitoring %s. Press Ctrl+C to stop.\n", state.schedule(networks))
+	for {
+		// The storage records joins and departures as it merges each scan;
+		// everything newer than the last event before the round came from it.
+		lastID := latestEventID(store)
+		for _, e := range watchRound(ctx, store, s, networks, state) {
+			fmt.Fprintln(os.Stderr, e)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		announce(newDeviceEvents(store, lastID), notify)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Until(state.nextScan())):
+		}
+	}
+}
+
+// latestEventID returns the ID of the newest event, or 0 when there are none.
+func latestEventID(store *storage.Storage) int64 {
+	if events := store.GetEvents(1, false); len(events) > 0 {
+		return events[0].ID
+	}
+	return 0
+}
+
+// newDeviceEvents returns the joins and departures recorded since the event
+// with ID after, oldest first.
+func newDeviceEvents(store *storage.Storage, after int64) []types.Event {
+	var result []types.Event
+	for _, e := range store.GetEvents(0, false) {
+		if e.ID <= after {
+			break
+		}
+		if e.Type == types.EventDeviceNew || e.Type == types.EventDeviceOffline {
+			result = append([]types.Event{e}, result...)
+		}
+	}
+	return result
+}
+
+// announce prints events and notifies about them as notify says.
+func announce(events []types.Event, notify string) {
+	if len(events) == 0 {
+		return
+	}
+	for _, e := range events {
+		printEvent(e)
+	}
+
+	switch notify {
+	case "bell":
+		fmt.Print("\a")
+	case "desktop":
+		if len(events) > monitorMaxNotifications {
+			joined := 0
+			for _, e := range events {
+				if e.Type == types.EventDeviceNew {
+					joined++
+				}
+			}
+			sendDesktopNotification("LAN Orangutan",
+				fmt.Sprintf("%d devices joined and %d went offline", joined, len(events)-joined))
+			return
+		}
+		for _, e := range events {
+			title := "New device"
+			if e.Type == types.EventDeviceOffline {
+				title = "Device offline"
+			}
+			sendDesktopNotification(title, e.Message)
+		}
+	}
+}
+
+// desktopNotifications reports whether sendDesktopNotification can show
+// anything on this system.
+func desktopNotifications() bool {
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd":
+		_, err := exec.LookPath("notify-send")
+		return err == nil
+	case "darwin":
+		_, err := exec.LookPath("osascript")
+		return err == nil
+	default:
+		return false
+	}
+}
+
+// sendDesktopNotification shows a notification, ringing the terminal bell if
+// that fails: an alert that is missed is worse than one that is plain.
+func sendDesktopNotification(title, message string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(message), appleScriptString(title))
+		cmd = exec.CommandContext(ctx, "osascript", "-e", script)
+	} else {
+		cmd = exec.CommandContext(ctx, "notify-send", "--app-name=LAN Orangutan", title, message)
+	}
+	if err := cmd.Run(); err != nil {
+		fmt.Print("\a")
+	}
+}
+
+// appleScriptString quotes s as an AppleScript string literal. Device names
+// come from the network, so they must not be able to end the string early.
+func appleScriptString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
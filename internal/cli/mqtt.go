@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/mqtt"
+	"github.com/291-Group/LAN-Orangutan/internal/storage"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// mqttKeepAlive is how long the connection to the broker may sit idle.
+const mqttKeepAlive = 60 * time.Second
+
+// startMQTT publishes the events and snapshots of the devices in store to
+// the broker in the [mqtt] section until ctx is done. It does nothing when
+// no broker is set.
+func startMQTT(ctx context.Context, store *storage.Storage) {
+	if cfg.MQTT.Broker == "" {
+		return
+	}
+	clientID := cfg.MQTT.ClientID
+	if clientID == "" {
+		// Brokers drop a connection when another takes its client ID, so two
+		// machines publishing must not share the same default.
+		host, _ := os.Hostname()
+		clientID = "lan-orangutan-" + host
+	}
+	// The client does not do QoS 2; Validate reports asking for it.
+	var qos byte
+	if cfg.MQTT.QoS > 0 {
+		qos = 1
+	}
+	pub := mqtt.NewPublisher(mqtt.PublisherOptions{
+		Options: mqtt.Options{
+			Broker:    cfg.MQTT.Broker,
+			ClientID:  clientID,
+			Username:  cfg.MQTT.Username,
+			Password:  cfg.MQTT.Password,
+			KeepAlive: mqttKeepAlive,
+		},
+		TopicPrefix:      cfg.MQTT.TopicPrefix,
+		QoS:              qos,
+		Retain:           cfg.MQTT.Retain,
+		SnapshotInterval: time.Duration(cfg.MQTT.SnapshotInterval) * time.Second,
+		HomeAssistant:    cfg.MQTT.HomeAssistant,
+		DiscoveryPrefix:  cfg.MQTT.DiscoveryPrefix,
+	})
+	go pub.Run(ctx, func() []types.Device {
+		devices := store.GetDevices()
+		result := make([]types.Device, 0, len(devices))
+		for _, d := range devices {
+			result = append(result, *d)
+		}
+		return result
+	})
+}
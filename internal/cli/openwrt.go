@@ -0,0 +1,200 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/openwrt"
+	"github.com/291-Group/LAN-Orangutan/internal/scanner"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var (
+	openwrtWiFi     bool
+	openwrtNetworks []string
+	openwrtSort     string
+	openwrtGroup    string
+	openwrtDryRun   bool
+)
+
+var openwrtCmd = &cobra.Command{
+	Use:   "openwrt",
+	Short: "Read devices and their Wi-Fi from an OpenWrt router",
+	Long: `Read the DHCP leases, known hosts and Wi-Fi associations of the OpenWrt
+router in the [openwrt] section. With url set there, every scan asks it
+too, and records which SSID and band each device is on and how strongly
+the router hears it.`,
+}
+
+var openwrtClientsCmd = &cobra.Command{
+	Use:   "clients",
+	Short: "List the router's devices and how they are on Wi-Fi",
+	Long: `List the devices the router knows, with the SSID, band and channel of those
+on its Wi-Fi now and their signal in dBm, and the label each has in the
+inventory. Sort by signal to find the devices worst off.`,
+	Example: `  orangutan openwrt clients --wifi --sort signal`,
+	Args:    cobra.NoArgs,
+	RunE:    runOpenWrtClients,
+}
+
+var openwrtImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Add the router's devices to the inventory",
+	Long: `Add the devices the router knows to the inventory, with their Wi-Fi details.
+Devices on its Wi-Fi now are recorded as seen now. Those only known from a
+lease may have left since, so they are added without a time and do not
+show as online; use --wifi to leave them out.`,
+	Args: cobra.NoArgs,
+	RunE: runOpenWrtImport,
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{openwrtClientsCmd, openwrtImportCmd} {
+		cmd.Flags().BoolVar(&openwrtWiFi, "wifi", false, "Only devices on the router's Wi-Fi now")
+		cmd.Flags().StringSliceVar(&openwrtNetworks, "network", nil, "Only devices in this network (repeatable)")
+		openwrtCmd.AddCommand(cmd)
+	}
+	openwrtClientsCmd.Flags().StringVar(&openwrtSort, "sort", "ip", "Sort by ip or signal, weakest first")
+	openwrtImportCmd.Flags().StringVar(&openwrtGroup, "group", "", "Put the imported devices in this group")
+	_ = openwrtImportCmd.RegisterFlagCompletionFunc("group", completeGroups)
+	openwrtImportCmd.Flags().BoolVar(&openwrtDryRun, "dry-run", false, "Show what would change without saving")
+}
+
+// openwrtHosts returns the devices the router knows that the flags ask for.
+func openwrtHosts() ([]openwrt.Host, error) {
+	if cfg.OpenWrt.URL == "" {
+		return nil, errors.New("no router is set up; set url in the [openwrt] section of the config file")
+	}
+	var networks []*net.IPNet
+	for _, cidr := range openwrtNetworks {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("--network %s is not a network such as 192.168.1.0/24", cidr)
+		}
+		networks = append(networks, n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	all, err := openwrt.New(cfg.OpenWrt.Options()).Hosts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("asking the router: %w", err)
+	}
+
+	var hosts []openwrt.Host
+	for _, h := range all {
+		if openwrtWiFi && h.Wireless == nil {
+			continue
+		}
+		if len(networks) > 0 && !inNetworks(net.ParseIP(h.IP), networks) {
+			continue
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
+
+func runOpenWrtClients(cmd *cobra.Command, args []string) error {
+	if openwrtSort != "ip" && openwrtSort != "signal" {
+		return fmt.Errorf("--sort %s is not ip or signal", openwrtSort)
+	}
+	hosts, err := openwrtHosts()
+	if err != nil {
+		return err
+	}
+	if len(hosts) == 0 {
+		fmt.Println("No devices found")
+		return nil
+	}
+	if openwrtSort == "signal" {
+		// Weakest first; devices off Wi-Fi last, in address order.
+		slices.SortStableFunc(hosts, func(a, b openwrt.Host) int {
+			if a.Wireless == nil || b.Wireless == nil {
+				return boolCompare(a.Wireless == nil, b.Wireless == nil)
+			}
+			return a.Wireless.Signal - b.Wireless.Signal
+		})
+	}
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IP\tMAC\tHOSTNAME\tSSID\tBAND\tCHANNEL\tSIGNAL\tACCESS POINT\tLABEL")
+	fmt.Fprintln(w, "--\t---\t--------\t----\t----\t-------\t------\t------------\t-----")
+	for _, h := range hosts {
+		ssid, band, channel, signal, ap := "-", "-", "-", "-", "-"
+		if wl := h.Wireless; wl != nil {
+			ssid, band, ap = wl.SSID, dash(wl.Band), dash(wl.AccessPoint)
+			channel = fmt.Sprint(wl.Channel)
+			signal = fmt.Sprintf("%d dBm", wl.Signal)
+		}
+		label := ""
+		if stored := store.GetDevice(h.IP); stored != nil {
+			label = stored.Label
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", h.IP, dash(h.MAC), dash(h.Hostname),
+			ssid, band, channel, signal, ap, dash(label))
+	}
+	return w.Flush()
+}
+
+// boolCompare orders false before true.
+func boolCompare(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
+
+func runOpenWrtImport(cmd *cobra.Command, args []string) error {
+	hosts, err := openwrtHosts()
+	if err != nil {
+		return err
+	}
+	var devices []types.Device
+	for _, d := range openwrt.HostDevices(hosts, nil) {
+		d.Group = openwrtGroup
+		if d.MAC != "" {
+			d.Vendor = scanner.GetMACVendor(d.MAC)
+		}
+		devices = append(devices, d)
+	}
+	if len(devices) == 0 {
+		fmt.Println("No devices to import")
+		return nil
+	}
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	result, err := store.ImportDevices(devices, openwrtDryRun)
+	if err != nil {
+		return fmt.Errorf("failed to save devices: %w", err)
+	}
+
+	verb := "Imported"
+	if openwrtDryRun {
+		verb = "Would import"
+	}
+	fmt.Printf("%s %d devices: %d new, %d updated, %d unchanged\n",
+		verb, len(devices), result.Created, result.Updated, result.Unchanged)
+	if openwrtDryRun {
+		fmt.Println("Dry run, nothing saved")
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/storage"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+	"github.com/291-Group/LAN-Orangutan/internal/watch"
+)
+
+var pingCount int
+
+var pingCmd = &cobra.Command{
+	Use:   "ping <ip|mac|label>",
+	Short: "Ping a device by address or name",
+	Long: `Ping a device with the system ping command. The device can be given by IP
+or MAC address, or by the label or hostname it has in the device list, so
+'orangutan ping printer' works without looking the address up.
+
+A device that answers is marked as seen, with its average response time, as
+if a scan had found it. In remote mode the ping still runs from this machine,
+so nothing is recorded on the server.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPing,
+}
+
+var tracerouteCmd = &cobra.Command{
+	Use:   "traceroute <ip|mac|label>",
+	Short: "Trace the route to a device by address or name",
+	Long: `Trace the route to a device with the system traceroute command (tracert on
+Windows). The device is given as for 'orangutan ping'.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTraceroute,
+}
+
+func init() {
+	pingCmd.Flags().IntVarP(&pingCount, "count", "c", 4, "Number of pings to send")
+}
+
+func runPing(cmd *cobra.Command, args []string) error {
+	if pingCount < 1 {
+		return fmt.Errorf("--count must be at least 1")
+	}
+	target, d, err := resolveTarget(cmd, args[0])
+	if err != nil {
+		return err
+	}
+
+	countFlag := "-c"
+	if runtime.GOOS == "windows" {
+		countFlag = "-n"
+	}
+	if _, err := exec.LookPath("ping"); err != nil {
+		return fmt.Errorf("ping not found in PATH")
+	}
+
+	if d != nil && target != args[0] {
+		fmt.Printf("%s is %s\n", args[0], target)
+	}
+
+	// The output is shown as it arrives and kept to read the times from.
+	var out bytes.Buffer
+	ping := exec.Command("ping", countFlag, strconv.Itoa(pingCount), target)
+	ping.Stdout = io.MultiWriter(os.Stdout, &out)
+	ping.Stderr = os.Stderr
+	runErr := ping.Run()
+
+	var total float64
+	times := watch.ReplyTimes(out.String())
+	for _, ms := range times {
+		total += ms
+	}
+	if len(times) == 0 {
+		if runErr != nil {
+			return fmt.Errorf("no reply from %s", target)
+		}
+		// Answered, but in a format the times could not be read from.
+		return markSeen(cmd, d, nil)
+	}
+	avg := total / float64(len(times))
+	return markSeen(cmd, d, &avg)
+}
+
+// markSeen records that d answered, if it is in the local device list.
+func markSeen(cmd *cobra.Command, d *types.Device, responseTime *float64) error {
+	if d == nil || serverAddr != "" {
+		return nil
+	}
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+	if _, err := store.MarkSeen(d.IP, responseTime); err != nil {
+		return fmt.Errorf("failed to save devices: %w", err)
+	}
+	return nil
+}
+
+func runTraceroute(cmd *cobra.Command, args []string) error {
+	target, d, err := resolveTarget(cmd, args[0])
+	if err != nil {
+		return err
+	}
+
+	tool := "traceroute"
+	if runtime.GOOS == "windows" {
+		tool = "tracert"
+	}
+	if _, err := exec.LookPath(tool); err != nil {
+		return fmt.Errorf("%s not found in PATH", tool)
+	}
+
+	if d != nil && target != args[0] {
+		fmt.Printf("%s is %s\n", args[0], target)
+	}
+	trace := exec.Command(tool, target)
+	trace.Stdout = os.Stdout
+	trace.Stderr = os.Stderr
+	if err := trace.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w", tool, err)
+	}
+	return nil
+}
+
+// resolveTarget finds the address to reach for key: an IP or MAC address, or
+// a device's label or hostname, ignoring case. It also returns the device,
+// which is nil for an IP address that is not in the device list.
+func resolveTarget(cmd *cobra.Command, key string) (string, *types.Device, error) {
+	devices, err := loadDevices(cmd)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if ip := net.ParseIP(key); ip != nil {
+		return key, devices[key], nil
+	}
+	d, err := storage.Find(devices, key)
+	if err != nil {
+		return "", nil, err
+	}
+	return d.IP, d, nil
+}
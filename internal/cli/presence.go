@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var (
+	presenceFormat   string
+	presenceExitCode bool
+)
+
+var presenceCmd = &cobra.Command{
+	Use:   "presence [name...]",
+	Short: "Show who is home",
+	Long: `Print whether each person of the [person] sections is home, worked out from
+whether scans find their phones, watches and other devices, by MAC address.
+Give names to show only those people.
+
+With --exit-code the command exits with status 1 when anyone shown is away,
+for scripts that should act only when someone is home.
+
+  orangutan presence
+  orangutan presence alice --exit-code && echo "Alice is home"
+  orangutan presence --format json`,
+	RunE: runPresence,
+}
+
+func init() {
+	presenceCmd.Flags().StringVar(&presenceFormat, "format", "table", "Output format (table, json)")
+	presenceCmd.Flags().BoolVar(&presenceExitCode, "exit-code", false, "Exit with status 1 if anyone shown is away")
+}
+
+func runPresence(cmd *cobra.Command, args []string) error {
+	if presenceFormat != "table" && presenceFormat != "json" {
+		return fmt.Errorf("unknown format %q (use table or json)", presenceFormat)
+	}
+
+	var presence []types.Presence
+	c, err := remoteClient()
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		ctx, cancel := remoteContext()
+		defer cancel()
+		if presence, err = c.Presence(ctx); err != nil {
+			return err
+		}
+	} else {
+		store, err := openStore(cmd)
+		if err != nil {
+			return err
+		}
+		presence = store.GetPresence()
+	}
+	if len(presence) == 0 {
+		return fmt.Errorf("nobody is followed; add a [person] section with the MACs of their devices to the config file")
+	}
+
+	if len(args) > 0 {
+		var shown []types.Presence
+		for _, name := range args {
+			found := false
+			for _, p := range presence {
+				if strings.EqualFold(p.Name, name) {
+					shown = append(shown, p)
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("no [person] section for %q", name)
+			}
+		}
+		presence = shown
+	}
+
+	if presenceFormat == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(presence); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSTATUS\tFOR\tLAST DEVICE\tLAST SEEN")
+		for _, p := range presence {
+			status, since, device, seen := "away", "-", "-", "never"
+			if p.Home {
+				status = "home"
+			}
+			if !p.Since.IsZero() {
+				since = formatDuration(time.Since(p.Since))
+			}
+			if p.IP != "" {
+				device = fmt.Sprintf("%s (%s)", p.Device, p.IP)
+				seen = p.LastSeen.Local().Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", p.Name, status, since, device, seen)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if presenceExitCode {
+		for _, p := range presence {
+			if !p.Home {
+				return &foundError{}
+			}
+		}
+	}
+	return nil
+}
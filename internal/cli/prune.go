@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var (
+	pruneDays     int
+	pruneGroup    string
+	pruneTag      string
+	pruneLabelled bool
+	pruneDryRun   bool
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete devices that have not been seen for a while",
+	Long: `Delete stored devices not seen in the last N days, by default the
+retention_days setting. Narrow it down with --group or --tag, or use --days 0
+with a filter to delete every device matching it regardless of age.
+
+Devices with a label or notes are kept unless --include-labelled is given:
+they are ones someone took the trouble to name. Use --dry-run to see what
+would be deleted first.`,
+	Args: cobra.NoArgs,
+	RunE: runPrune,
+}
+
+func init() {
+	pruneCmd.Flags().IntVar(&pruneDays, "days", -1, "Delete devices not seen in this many days (default: retention_days from the config)")
+	pruneCmd.Flags().StringVar(&pruneGroup, "group", "", "Only delete devices in this group")
+	_ = pruneCmd.RegisterFlagCompletionFunc("group", completeGroups)
+	pruneCmd.Flags().StringVar(&pruneTag, "tag", "", "Only delete devices with this tag")
+	pruneCmd.Flags().BoolVar(&pruneLabelled, "include-labelled", false, "Also delete devices that have a label or notes")
+	pruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "List what would be deleted without deleting it")
+}
+
+func runPrune(cmd *cobra.Command, args []string) error {
+	days := pruneDays
+	if days < 0 {
+		days = cfg.Storage.RetentionDays
+	}
+	if days <= 0 && pruneGroup == "" && pruneTag == "" {
+		return fmt.Errorf("refusing to delete every device: give --days, --group or --tag")
+	}
+
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -days)
+	var victims []*types.Device
+	for _, d := range store.GetDevices() {
+		if days > 0 && d.LastSeen.After(cutoff) {
+			continue
+		}
+		if pruneGroup != "" && !strings.EqualFold(d.Group, pruneGroup) {
+			continue
+		}
+		if pruneTag != "" && !d.HasTag(pruneTag) {
+			continue
+		}
+		if !pruneLabelled && (d.Label != "" || d.Notes != "") {
+			continue
+		}
+		victims = append(victims, d)
+	}
+
+	if len(victims) == 0 {
+		fmt.Println("Nothing to prune")
+		return nil
+	}
+	sort.Slice(victims, func(i, j int) bool {
+		return types.LessIP(victims[i].IP, victims[j].IP)
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IP\tMAC\tNAME\tGROUP\tLAST SEEN")
+	fmt.Fprintln(w, "--\t---\t----\t-----\t---------")
+	ips := make([]string, len(victims))
+	for i, d := range victims {
+		ips[i] = d.IP
+		lastSeen := "never"
+		if !d.LastSeen.IsZero() {
+			lastSeen = d.LastSeen.Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			d.IP, dash(d.MAC), truncate(deviceDisplayName(d), 25), dash(d.Group), lastSeen)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Println()
+
+	if pruneDryRun {
+		fmt.Printf("Would delete %d devices (dry run, nothing deleted)\n", len(victims))
+		return nil
+	}
+
+	n, err := store.DeleteDevices(ips)
+	if err != nil {
+		return fmt.Errorf("failed to delete devices: %w", err)
+	}
+	fmt.Printf("Deleted %d devices\n", n)
+	return nil
+}
@@ -0,0 +1,212 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/client"
+	"github.com/291-Group/LAN-Orangutan/internal/storage"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// serverAddr is the server given with --server or ORANGUTAN_SERVER. When it
+// is set, commands that support it go through that server's API instead of
+// opening the data files.
+var serverAddr string
+
+// remoteTimeout bounds remote calls other than scans, which take as long as
+// the scan does.
+const remoteTimeout = 30 * time.Second
+
+// remoteClient returns a client for the server in serverAddr, or nil when
+// commands should work on the local data files. The API token comes from the
+// same place the server reads its own, so a config file shared between the
+// two needs nothing extra.
+func remoteClient() (*client.Client, error) {
+	if serverAddr == "" {
+		return nil, nil
+	}
+	return client.New(serverAddr, cfg.Server.APIToken)
+}
+
+// openStore opens the local data files for cmd. Commands with no remote mode
+// still call it in remote mode, which fails: quietly reading this machine's
+// files when the user asked about another would give wrong answers.
+func openStore(cmd *cobra.Command) (*storage.Storage, error) {
+	if serverAddr != "" {
+		return nil, fmt.Errorf("orangutan %s works on the local data files and cannot be used with --server", cmd.Name())
+	}
+
+	if err := prepareDataDir(cfg.Storage.DataDir); err != nil {
+		return nil, err
+	}
+	store, err := storage.New(cfg.DevicesFile(), cfg.StateFile())
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	store.SetApproval(cfg.Approval.Pending())
+	store.SetOfflineAfter(cfg.Offline.Grace())
+	store.SetPeople(cfg.People())
+	store.SetRestricted(cfg.RestrictedZones())
+	return store, nil
+}
+
+// remoteContext returns a context for a remote call that is not a scan.
+func remoteContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), remoteTimeout)
+}
+
+// loadDevices returns every stored device by IP, from the server in remote
+// mode and from the local data files otherwise.
+func loadDevices(cmd *cobra.Command) (map[string]*types.Device, error) {
+	c, err := remoteClient()
+	if err != nil {
+		return nil, err
+	}
+	if c != nil {
+		ctx, cancel := remoteContext()
+		defer cancel()
+		return c.Devices(ctx)
+	}
+
+	store, err := openStore(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetDevices(), nil
+}
+
+// remoteScanGrace is how much longer than the server's time limit a remote
+// scan of one network is waited for, to cover saving the results and the
+// round trip.
+const remoteScanGrace = time.Minute
+
+// runRemoteScan is scan in remote mode. The server, not this machine, picks
+// and scans the networks and stores what it finds.
+func runRemoteScan(c *client.Client, args []string) error {
+	cidr := ""
+	if len(args) > 0 {
+		cidr = args[0]
+	}
+	if cidr == "" {
+		ctx, cancel := remoteContext()
+		networks, err := c.Networks(ctx)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to list the server's networks: %w", err)
+		}
+		if len(networks) == 0 {
+			return fmt.Errorf("the server detected no networks")
+		}
+		// Skip Tailscale by default, as a local scan does
+		cidr = networks[0].CIDR
+		for _, n := range networks {
+			if !n.IsTailscale {
+				cidr = n.CIDR
+				break
+			}
+		}
+	}
+
+	if cidr == "all" {
+		// Networks are scanned one after another, each within the time
+		// limit, and how many there are is up to the server; that limit is
+		// what ends a scan that has stalled.
+		fmt.Printf("Scanning all networks on %s...\n", serverAddr)
+		result, err := c.ScanAll(context.Background(), scanTimeout)
+		if err != nil {
+			return err
+		}
+		for _, n := range result.Networks {
+			switch n.Status {
+			case "scanned":
+				fmt.Printf("%s: found %d devices (%.2fs)\n", n.Network, n.DeviceCount, n.Duration)
+			case "skipped":
+				fmt.Printf("Rate limited for %s\n", n.Network)
+			default:
+				fmt.Fprintf(os.Stderr, "Scan failed for %s: %s\n", n.Network, n.Error)
+			}
+		}
+		fmt.Printf("\nScanned %d of %d networks, %d devices found\n", result.ScannedCount, result.NetworkCount, result.DeviceCount)
+		return nil
+	}
+
+	// The server's own scan_timeout is not known here; the local config's is
+	// the best guess, and usually the same file.
+	limit := scanTimeout
+	if limit == 0 {
+		limit = cfg.Scanning.Timeout()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), limit+remoteScanGrace)
+	defer cancel()
+
+	fmt.Printf("Scanning %s on %s...\n", cidr, serverAddr)
+	result, err := c.Scan(ctx, cidr, scanTimeout)
+	if err != nil {
+		return fmt.Errorf("scan failed for %s: %w", cidr, err)
+	}
+	fmt.Printf("Found %d devices using %s (%.2fs)\n\n", result.DeviceCount, result.Scanner, result.Duration)
+	printScanDevices(os.Stdout, result.Devices)
+	return nil
+}
+
+// setRemote is set in remote mode. Details go through the device endpoint
+// and tags through the batch one, as the dashboard sends them, and so does
+// the grace period.
+func setRemote(c *client.Client, cmd *cobra.Command, key string) error {
+	ctx, cancel := remoteContext()
+	defer cancel()
+
+	devices, err := c.Devices(ctx)
+	if err != nil {
+		return err
+	}
+	device, err := findDevice(devices, key)
+	if err != nil {
+		return err
+	}
+
+	flags := cmd.Flags()
+	field := func(name, value string) *string {
+		if flags.Changed(name) {
+			return &value
+		}
+		return nil
+	}
+	label, group, notes, deviceType := field("label", setLabel), field("group", setGroup), field("notes", setNotes), field("type", setType)
+	if label != nil || group != nil || notes != nil || deviceType != nil {
+		if err := c.UpdateDevice(ctx, device.IP, label, notes, group, deviceType); err != nil {
+			return fmt.Errorf("failed to save device: %w", err)
+		}
+	}
+
+	ips := []string{device.IP}
+	for _, tag := range setAddTags {
+		if _, err := c.Batch(ctx, ips, "add_tag", tag); err != nil {
+			return fmt.Errorf("failed to add tag %q: %w", tag, err)
+		}
+	}
+	for _, tag := range setRemoveTags {
+		if _, err := c.Batch(ctx, ips, "remove_tag", tag); err != nil {
+			return fmt.Errorf("failed to remove tag %q: %w", tag, err)
+		}
+	}
+	if flags.Changed("offline-after") {
+		if _, err := c.Batch(ctx, ips, "offline_after", setOffline); err != nil {
+			return fmt.Errorf("failed to set the grace period: %w", err)
+		}
+	}
+	if flags.Changed("watch") {
+		if _, err := c.Batch(ctx, ips, "watch", strconv.FormatBool(setWatch)); err != nil {
+			return fmt.Errorf("failed to set the watchlist: %w", err)
+		}
+	}
+
+	fmt.Printf("Updated %s\n", device.IP)
+	return nil
+}
@@ -0,0 +1,252 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/alert"
+	"github.com/291-Group/LAN-Orangutan/internal/report"
+	"github.com/291-Group/LAN-Orangutan/internal/storage"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+	"github.com/291-Group/LAN-Orangutan/internal/uptime"
+)
+
+var (
+	reportDays   int
+	reportOutput string
+)
+
+// pdfTimeout bounds the conversion to PDF; a browser that never returns must
+// not hang a cron job.
+const pdfTimeout = 2 * time.Minute
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Write an HTML summary of the network for the last week",
+	Long: `Write a summary of the network as one self-contained HTML page: the
+inventory by group, devices that appeared or went offline, the devices online
+longest, those that kept dropping off or were online much less than usual,
+and how the scans went.
+
+The page has its styles inline and no scripts, so it can be attached to an
+email as it is. Give an output file ending in .pdf for a PDF instead, which
+needs wkhtmltopdf or Chrome/Chromium installed to make it.
+
+  orangutan report -o weekly.html
+  orangutan report --days 30 -o monthly.pdf`,
+	Args: cobra.NoArgs,
+	RunE: runReport,
+}
+
+func init() {
+	reportCmd.Flags().IntVar(&reportDays, "days", 7, "Cover the last N days")
+	reportCmd.Flags().StringVarP(&reportOutput, "output", "o", "", "Write to this file (.html or .pdf) instead of standard output")
+}
+
+func runReport(cmd *cobra.Command, args []string) error {
+	if reportDays < 1 {
+		return fmt.Errorf("--days must be at least 1")
+	}
+	pdf := strings.EqualFold(filepath.Ext(reportOutput), ".pdf")
+
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	r := buildReport(store, time.Now(), time.Duration(reportDays)*24*time.Hour)
+
+	if reportOutput == "" {
+		return writeReport(r, os.Stdout)
+	}
+
+	absPath, err := filepath.Abs(reportOutput)
+	if err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+	if pdf {
+		err = writeReportPDF(r, absPath)
+	} else {
+		err = writeReportFile(r, absPath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", absPath, err)
+	}
+	fmt.Printf("Wrote the report for the last %d days to %s\n", reportDays, absPath)
+	return nil
+}
+
+// buildReport builds the report of what store recorded over period up to
+// now.
+func buildReport(store *storage.Storage, now time.Time, period time.Duration) *report.Report {
+	in := report.Input{
+		Devices:   store.GetDevices(),
+		Sightings: make(map[string][]types.Sighting),
+		Events:    store.GetEvents(0, false),
+		Now:       now,
+		Period:    period,
+	}
+	for ip := range in.Devices {
+		in.Sightings[ip] = store.GetSightings(ip, now.Add(-max(period, uptime.MaxWindow)))
+	}
+	for _, network := range store.ScannedNetworks() {
+		in.Scans = append(in.Scans, report.Scan{
+			Network:  network,
+			Last:     store.GetLastScan(network),
+			Duration: time.Duration(store.GetLastDuration(network) * float64(time.Second)),
+		})
+	}
+	return report.Build(in)
+}
+
+// startReports writes the report to the reports directory on the schedule
+// [reports] sets, until ctx is done, each covering the time since the one
+// before. The first covers as long as the gap to the one after it. It does
+// nothing when there is no schedule.
+func startReports(ctx context.Context, store *storage.Storage) {
+	if cfg.Reports.Schedule == "" {
+		return
+	}
+	sched, err := alert.DigestSchedule(cfg.Reports.Schedule)
+	if err != nil {
+		slog.Warn("reports not written", "error", err)
+		return
+	}
+	dir, keep := cfg.ReportsDir(), cfg.Reports.Keep
+	go func() {
+		var last time.Time
+		for {
+			next := sched.Next(time.Now())
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Until(next)):
+			}
+			period := alert.DigestPeriod(sched, next)
+			if !last.IsZero() {
+				period = next.Sub(last)
+			}
+			last = next
+
+			path, err := saveReport(buildReport(store, next, period), dir, next, keep)
+			if err != nil {
+				slog.Warn("cannot write report", "dir", dir, "error", err)
+				continue
+			}
+			slog.Info("wrote report", "path", path)
+		}
+	}()
+}
+
+// saveReport writes r to dir, named for when it was made, and removes all but
+// the keep newest reports there when keep is not 0. It returns the path
+// written.
+func saveReport(r *report.Report, dir string, now time.Time, keep int) (string, error) {
+	// The reports say what is on the network, so they are kept as private
+	// as the data they come from.
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "report-"+now.Format("20060102-1504")+".html")
+	if err := writeReportFile(r, path); err != nil {
+		return "", err
+	}
+	if keep > 0 {
+		// The names sort by when they were written.
+		old, _ := filepath.Glob(filepath.Join(dir, "report-*.html"))
+		sort.Strings(old)
+		for len(old) > keep {
+			if err := os.Remove(old[0]); err != nil {
+				slog.Warn("cannot remove old report", "path", old[0], "error", err)
+			}
+			old = old[1:]
+		}
+	}
+	return path, nil
+}
+
+func writeReportFile(r *report.Report, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeReport(r, file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func writeReport(r *report.Report, out io.Writer) error {
+	w := bufio.NewWriter(out)
+	if err := r.WriteHTML(w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// writeReportPDF writes the report as HTML to a temporary file and has the
+// first converter installed print it to path. There is no PDF writer in the
+// standard library, and a browser lays the page out exactly as it would be
+// seen.
+func writeReportPDF(r *report.Report, path string) error {
+	converter, convArgs := pdfConverter(path)
+	if converter == "" {
+		return fmt.Errorf("making a PDF needs wkhtmltopdf, Chrome or Chromium; install one or write .html instead")
+	}
+
+	tmp, err := os.CreateTemp("", "orangutan-report-*.html")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := writeReport(r, tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pdfTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, converter, convArgs(tmp.Name())...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", filepath.Base(converter), err, strings.TrimSpace(string(out)))
+	}
+	// Chrome reports some failures only by not writing the file.
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("%s did not write the PDF: %s", filepath.Base(converter), strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// pdfConverter finds a program to turn HTML into a PDF at path, returning
+// it and the arguments to give it for an input file, or "" when none is
+// installed.
+func pdfConverter(path string) (string, func(input string) []string) {
+	if bin, err := exec.LookPath("wkhtmltopdf"); err == nil {
+		return bin, func(input string) []string {
+			return []string{"--quiet", "--enable-local-file-access", input, path}
+		}
+	}
+	for _, name := range []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome"} {
+		if bin, err := exec.LookPath(name); err == nil {
+			return bin, func(input string) []string {
+				return []string{"--headless", "--disable-gpu", "--no-pdf-header-footer", "--print-to-pdf=" + path, "file:///" + strings.TrimPrefix(filepath.ToSlash(input), "/")}
+			}
+		}
+	}
+	return "", nil
+}
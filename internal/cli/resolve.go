@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/scanner"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var (
+	resolveGroup   string
+	resolveOnline  bool
+	resolveMissing bool
+	resolveDryRun  bool
+)
+
+// resolveWorkers bounds how many reverse lookups run at once. Each can take
+// up to two seconds when a name server is slow, so doing them one by one
+// would take minutes on a busy network.
+const resolveWorkers = 16
+
+var resolveCmd = &cobra.Command{
+	Use:   "resolve [ip...]",
+	Short: "Look up hostnames and vendors again for stored devices",
+	Long: `Re-run hostname resolution and MAC vendor lookup for stored devices without
+scanning the network. Useful after fixing DNS or upgrading to a release with a
+newer vendor database.
+
+With no arguments every stored device is resolved; name addresses or use the
+filters to narrow it down. A lookup that finds nothing keeps the stored value.`,
+	RunE: runResolve,
+}
+
+func init() {
+	resolveCmd.Flags().StringVar(&resolveGroup, "group", "", "Only resolve devices in this group")
+	_ = resolveCmd.RegisterFlagCompletionFunc("group", completeGroups)
+	resolveCmd.Flags().BoolVar(&resolveOnline, "online", false, "Only resolve online devices")
+	resolveCmd.Flags().BoolVar(&resolveMissing, "missing", false, "Only resolve devices with no hostname or an unknown vendor")
+	resolveCmd.Flags().BoolVar(&resolveDryRun, "dry-run", false, "Show what would change without saving")
+}
+
+// resolveChange is one field a lookup changed.
+type resolveChange struct {
+	ip, field, old, new string
+}
+
+func runResolve(cmd *cobra.Command, args []string) error {
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	devices := store.GetDevices()
+	wanted := make(map[string]bool, len(args))
+	for _, ip := range args {
+		if _, ok := devices[ip]; !ok {
+			return fmt.Errorf("device not found: %s", ip)
+		}
+		wanted[ip] = true
+	}
+
+	var targets []types.Device
+	for ip, d := range devices {
+		if len(wanted) > 0 && !wanted[ip] {
+			continue
+		}
+		if resolveGroup != "" && !strings.EqualFold(d.Group, resolveGroup) {
+			continue
+		}
+		if resolveOnline && !d.IsOnline() {
+			continue
+		}
+		if resolveMissing && d.Hostname != "" && d.Vendor != "" && d.Vendor != "Unknown" {
+			continue
+		}
+		targets = append(targets, *d)
+	}
+	if len(targets) == 0 {
+		fmt.Println("No devices to resolve")
+		return nil
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return types.LessIP(targets[i].IP, targets[j].IP)
+	})
+
+	fmt.Printf("Resolving %d devices...\n", len(targets))
+	resolved := resolveDevices(targets)
+
+	var changes []resolveChange
+	updated := make(map[string]types.Device)
+	for i, d := range resolved {
+		old := targets[i]
+		if d.Hostname != old.Hostname {
+			changes = append(changes, resolveChange{d.IP, "hostname", old.Hostname, d.Hostname})
+		}
+		if d.Vendor != old.Vendor {
+			changes = append(changes, resolveChange{d.IP, "vendor", old.Vendor, d.Vendor})
+		}
+		if d.Hostname != old.Hostname || d.Vendor != old.Vendor {
+			updated[d.IP] = d
+		}
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("Nothing changed")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IP\tFIELD\tOLD\tNEW")
+	fmt.Fprintln(w, "--\t-----\t---\t---")
+	for _, c := range changes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.ip, c.field, dash(truncate(c.old, 30)), truncate(c.new, 30))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Println()
+
+	if resolveDryRun {
+		fmt.Printf("Would update %d of %d devices (dry run, nothing saved)\n", len(updated), len(targets))
+		return nil
+	}
+
+	ips := make([]string, 0, len(updated))
+	for ip := range updated {
+		ips = append(ips, ip)
+	}
+	// Only the looked-up fields are written back, so a label edited in the
+	// web UI while the lookups ran is not overwritten.
+	n, err := store.UpdateDevices(ips, func(d *types.Device) {
+		u := updated[d.IP]
+		d.Hostname = u.Hostname
+		d.Vendor = u.Vendor
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save devices: %w", err)
+	}
+	fmt.Printf("Updated %d of %d devices\n", n, len(targets))
+	return nil
+}
+
+// resolveDevices looks up the hostname and vendor of each device again and
+// returns the results in the same order. A lookup that comes back empty
+// keeps what was stored: a name server that is down for a moment should not
+// erase every hostname.
+func resolveDevices(devices []types.Device) []types.Device {
+	result := make([]types.Device, len(devices))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < resolveWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				d := devices[i]
+				if hostname := scanner.ReverseDNS(d.IP); hostname != "" {
+					d.Hostname = hostname
+				}
+				if d.MAC != "" {
+					if vendor := scanner.GetMACVendor(d.MAC); vendor != "Unknown" {
+						d.Vendor = vendor
+					}
+				}
+				result[i] = d
+			}
+		}()
+	}
+	for i := range devices {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return result
+}
@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	restrictedIntruders bool
+	restrictedFormat    string
+)
+
+var restrictedCmd = &cobra.Command{
+	Use:   "restricted [name...]",
+	Short: "List the devices on restricted networks and in restricted groups",
+	Long: `List the devices in each restricted zone, or in the zones named, and
+whether each is allowed there. A [restricted "name"] section in the config
+file makes a network, such as the VLAN of the cameras, or a group one only
+the devices it allows may be on:
+
+  [restricted "cameras"]
+  networks = 192.168.20.0/24
+  allowed = aa:bb:cc:00:00:01, aa:bb:cc:00:00:02
+
+A scan that finds a device entering a zone without being allowed there
+records an intrusion event at once, which alert rules can send on: a device
+new there, back after a scan missed it, or answering at an address with
+another MAC than before. --intruders lists only the devices not allowed.
+
+  orangutan restricted
+  orangutan restricted cameras --intruders`,
+	RunE: runRestricted,
+}
+
+func init() {
+	restrictedCmd.Flags().BoolVar(&restrictedIntruders, "intruders", false, "Only list devices not allowed where they are")
+	restrictedCmd.Flags().StringVar(&restrictedFormat, "format", "table", "Output format (table, json)")
+}
+
+// restrictedRow is a device in a restricted zone.
+type restrictedRow struct {
+	Zone    string `json:"zone"`
+	IP      string `json:"ip"`
+	MAC     string `json:"mac,omitempty"`
+	Name    string `json:"name"`
+	Online  bool   `json:"online"`
+	Allowed bool   `json:"allowed"`
+}
+
+func runRestricted(cmd *cobra.Command, args []string) error {
+	if restrictedFormat != "table" && restrictedFormat != "json" {
+		return fmt.Errorf("unknown format %q (use table or json)", restrictedFormat)
+	}
+	zones := cfg.RestrictedZones()
+	if len(zones) == 0 {
+		return fmt.Errorf("nothing is restricted; add a [restricted \"name\"] section to the config file")
+	}
+	wanted := make(map[string]bool)
+	for _, name := range args {
+		// Section names are read in lower case.
+		name = strings.ToLower(name)
+		if _, ok := cfg.Restricted[name]; !ok {
+			return fmt.Errorf("no restricted zone %q", name)
+		}
+		wanted[name] = true
+	}
+	devices, err := loadDevices(cmd)
+	if err != nil {
+		return err
+	}
+
+	var rows []restrictedRow
+	for _, zone := range zones {
+		if len(wanted) > 0 && !wanted[zone.Name] {
+			continue
+		}
+		for _, d := range devices {
+			if !zone.Covers(d) {
+				continue
+			}
+			allowed := zone.Allows(d)
+			if restrictedIntruders && allowed {
+				continue
+			}
+			rows = append(rows, restrictedRow{Zone: zone.Name, IP: d.IP, MAC: d.MAC, Name: deviceDisplayName(d), Online: d.IsOnline(), Allowed: allowed})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Zone != rows[j].Zone {
+			return rows[i].Zone < rows[j].Zone
+		}
+		return types.LessIP(rows[i].IP, rows[j].IP)
+	})
+
+	if restrictedFormat == "json" {
+		if rows == nil {
+			rows = []restrictedRow{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	}
+	if len(rows) == 0 {
+		if restrictedIntruders {
+			fmt.Println("Every device in a restricted zone is allowed there")
+		} else {
+			fmt.Println("No devices in the restricted zones")
+		}
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ZONE\tIP\tMAC\tNAME\tSTATUS\tALLOWED")
+	for _, r := range rows {
+		status := "offline"
+		if r.Online {
+			status = "online"
+		}
+		allowed := "no"
+		if r.Allowed {
+			allowed = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.Zone, r.IP, dash(r.MAC), truncate(r.Name, 24), status, allowed)
+	}
+	return w.Flush()
+}
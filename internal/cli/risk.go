@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/risk"
+)
+
+var (
+	riskMin    int
+	riskFormat string
+)
+
+var riskCmd = &cobra.Command{
+	Use:   "risk [ip|mac|label...]",
+	Short: "Score how exposed each device is, highest first",
+	Long: `Score each device, or the devices given, from 0 to 100 by what is
+known against it, and list them highest first with what the score is made
+of:
+
+  telnet open                  20
+  other management ports       5 each, up to 15 (SSH, SNMP, RDP, VNC...)
+  known vulnerabilities        40 critical, 25 high, 10 medium, 3 low,
+                               and 10 more if an exploit is public
+  a factory default login      40
+  maker unknown                10
+  not approved                 15
+
+Open ports, vulnerabilities and default logins come from the latest deep scan
+and default login check ('orangutan vulnscan', 'orangutan credcheck'), so a
+device never checked scores on the rest alone.
+
+  orangutan risk
+  orangutan risk --min 50
+  orangutan risk camera --format json`,
+	RunE: runRisk,
+}
+
+func init() {
+	riskCmd.Flags().IntVar(&riskMin, "min", 1, "Only list devices scoring at least this")
+	riskCmd.Flags().StringVar(&riskFormat, "format", "table", "Output format (table, json)")
+}
+
+func runRisk(cmd *cobra.Command, args []string) error {
+	if riskFormat != "table" && riskFormat != "json" {
+		return fmt.Errorf("unknown format %q (use table or json)", riskFormat)
+	}
+	devices, err := loadDevices(cmd)
+	if err != nil {
+		return err
+	}
+	wanted := make(map[string]bool)
+	for _, arg := range args {
+		ip, d, err := resolveTarget(cmd, arg)
+		if err != nil {
+			return err
+		}
+		if d == nil {
+			return fmt.Errorf("device not found: %s", arg)
+		}
+		wanted[ip] = true
+	}
+	// Devices asked for by name are listed whatever they score.
+	minScore := riskMin
+	if len(wanted) > 0 {
+		minScore = 0
+	}
+
+	var assessments []risk.Assessment
+	c, err := remoteClient()
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		ctx, cancel := remoteContext()
+		defer cancel()
+		if assessments, err = c.Risk(ctx, minScore); err != nil {
+			return err
+		}
+	} else {
+		store, err := openStore(cmd)
+		if err != nil {
+			return err
+		}
+		for _, a := range risk.AssessAll(store.GetDevices(), store.GetDeepScans()) {
+			if a.Score >= minScore {
+				assessments = append(assessments, a)
+			}
+		}
+	}
+	rows := make([]risk.Assessment, 0, len(assessments))
+	for _, a := range assessments {
+		if len(wanted) == 0 || wanted[a.IP] {
+			rows = append(rows, a)
+		}
+	}
+
+	if riskFormat == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	}
+	if len(rows) == 0 {
+		fmt.Println("No device scores any risk")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SCORE\tLEVEL\tIP\tNAME\tWHY")
+	for _, a := range rows {
+		name := a.IP
+		if d := devices[a.IP]; d != nil {
+			name = deviceDisplayName(d)
+		}
+		var why []string
+		for _, s := range a.Signals {
+			why = append(why, fmt.Sprintf("%s (+%d)", s.Detail, s.Points))
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", a.Score, a.Level, a.IP, truncate(name, 20), strings.Join(why, "; "))
+	}
+	return w.Flush()
+}
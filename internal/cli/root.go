@@ -2,17 +2,27 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/291-Group/LAN-Orangutan/internal/config"
+	"github.com/291-Group/LAN-Orangutan/internal/logging"
 )
 
 var (
-	cfgFile string
-	cfg     *config.Config
+	cfgFile     string
+	cfg         *config.Config
+	profileName string
+	dataDirFlag string
+
+	logVerbose bool
+	logQuiet   bool
+	logFormat  string
 )
 
 // rootCmd represents the base command
@@ -36,39 +46,155 @@ a web interface for viewing and managing discovered devices.`,
 	},
 }
 
+// Exit statuses. A command that finds what --fail-on-new, --fail-on-missing
+// and the like ask about exits with exitFound, which scripts can tell apart
+// from a command that could not do its job, as with grep and diff.
+const (
+	exitFound = 1
+	exitError = 2
+)
+
+// foundError ends a command that did its job but found what the user asked
+// it to fail on. Its reason, if any, says what, and the command exits with
+// exitFound.
+type foundError struct {
+	reason string
+}
+
+func (e *foundError) Error() string { return e.reason }
+
 // Execute adds all child commands and runs the CLI
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
+		var found *foundError
+		if errors.As(err, &found) {
+			if found.reason != "" {
+				fmt.Fprintln(os.Stderr, found.reason)
+			}
+			os.Exit(exitFound)
+		}
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		os.Exit(exitError)
+	}
+}
+
+// failOn returns a foundError when a scan or diff turned up new or missing
+// devices and the user asked to fail on them.
+func failOn(onNew, onMissing bool, newCount, missingCount int) error {
+	var reasons []string
+	if onNew && newCount > 0 {
+		reasons = append(reasons, fmt.Sprintf("new devices: %d", newCount))
+	}
+	if onMissing && missingCount > 0 {
+		reasons = append(reasons, fmt.Sprintf("missing devices: %d", missingCount))
 	}
+	if len(reasons) == 0 {
+		return nil
+	}
+	return &foundError{reason: strings.Join(reasons, ", ")}
 }
 
 func init() {
 	cobra.OnInitialize(initConfig)
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", config.GetDefaultConfigFile(), "config file path")
+	rootCmd.PersistentFlags().StringVar(&serverAddr, "server", os.Getenv("ORANGUTAN_SERVER"),
+		"use the API of the server at this address instead of local files (list, search, scan, set, group, export)")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", os.Getenv("ORANGUTAN_PROFILE"),
+		"use the settings of this [profile] section of the config, such as home or office")
+	rootCmd.PersistentFlags().StringVar(&dataDirFlag, "data-dir", "", "keep the device list and scan state in this directory instead of data_dir")
+	rootCmd.PersistentFlags().BoolVarP(&logVerbose, "verbose", "v", false, "log what scans and the server are doing, including tool output")
+	rootCmd.PersistentFlags().BoolVarP(&logQuiet, "quiet", "q", false, "log errors only")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", logging.FormatText, "log format: text or json")
+	rootCmd.MarkFlagsMutuallyExclusive("verbose", "quiet")
 
 	// Add subcommands
 	rootCmd.AddCommand(scanCmd)
+	rootCmd.AddCommand(watchCmd)
+	rootCmd.AddCommand(monitorCmd)
 	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(searchCmd)
+	rootCmd.AddCommand(resolveCmd)
+	rootCmd.AddCommand(pingCmd)
+	rootCmd.AddCommand(tracerouteCmd)
+	rootCmd.AddCommand(setCmd)
+	rootCmd.AddCommand(groupCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(uptimeCmd)
+	rootCmd.AddCommand(presenceCmd)
+	rootCmd.AddCommand(silenceCmd)
+	rootCmd.AddCommand(ackCmd)
+	rootCmd.AddCommand(vulnscanCmd)
+	rootCmd.AddCommand(findingsCmd)
+	rootCmd.AddCommand(cveCmd)
+	rootCmd.AddCommand(vulnerableCmd)
+	rootCmd.AddCommand(credcheckCmd)
+	rootCmd.AddCommand(riskCmd)
+	rootCmd.AddCommand(certsCmd)
+	rootCmd.AddCommand(fingerprintCmd)
+	rootCmd.AddCommand(restrictedCmd)
+	rootCmd.AddCommand(showCmd)
+	rootCmd.AddCommand(reportCmd)
+	rootCmd.AddCommand(metricsCmd)
+	rootCmd.AddCommand(notifyCmd)
+	rootCmd.AddCommand(approveCmd)
+	rootCmd.AddCommand(deleteCmd)
+	rootCmd.AddCommand(pruneCmd)
 	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(checkCmd)
 	rootCmd.AddCommand(networksCmd)
+	rootCmd.AddCommand(arpCmd)
+	rootCmd.AddCommand(tailscaleCmd)
+	rootCmd.AddCommand(firewallCmd)
 	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(inventoryCmd)
+	rootCmd.AddCommand(dnsCmd)
+	rootCmd.AddCommand(dockerCmd)
+	rootCmd.AddCommand(kubernetesCmd)
+	rootCmd.AddCommand(openwrtCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(logsCmd)
 	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(installServiceCmd)
 	rootCmd.AddCommand(versionCmd)
 }
 
 func initConfig() {
-	var err error
-	cfg, err = config.Load(cfgFile)
+	// Logging comes first, so that anything going wrong from here on is seen.
+	logger, err := logging.New(os.Stderr, logging.Level(logVerbose, logQuiet), logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitError)
+	}
+	slog.SetDefault(logger)
+
+	cfg, err = loadConfig()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
+}
 
-	// Environment variables override the file, so containers can be configured
-	// without mounting one.
-	cfg.ApplyEnv()
+// loadConfig reads the config file with the profile chosen by --profile
+// applied, then the environment variables, which override the file so that
+// containers can be configured without mounting one, and last --data-dir.
+func loadConfig() (*config.Config, error) {
+	conf, err := config.Load(cfgFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := conf.UseProfile(profileName); err != nil {
+		return nil, err
+	}
+	conf.ApplyEnv()
+	if dataDirFlag != "" {
+		conf.Storage.DataDir = dataDirFlag
+		conf.SetSource("storage.data_dir", "flag --data-dir")
+	}
+	return conf, nil
 }
@@ -1,10 +1,14 @@
 package cli
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -12,6 +16,14 @@ import (
 	"github.com/291-Group/LAN-Orangutan/internal/network"
 	"github.com/291-Group/LAN-Orangutan/internal/scanner"
 	"github.com/291-Group/LAN-Orangutan/internal/storage"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var (
+	scanTimeout       time.Duration
+	scanParallel      int
+	scanFailOnNew     bool
+	scanFailOnMissing bool
 )
 
 var scanCmd = &cobra.Command{
@@ -19,145 +31,320 @@ var scanCmd = &cobra.Command{
 	Short: "Scan network for devices",
 	Long: `Scan a network for devices using nmap or arp-scan.
 Specify a network CIDR (e.g., 192.168.1.0/24) or 'all' to scan all detected networks.
-If no argument is provided, scans the first detected network.`,
+If no argument is provided, scans the first detected network.
+
+Each network may take up to scan_timeout from the config, five minutes by
+default, before its scan is abandoned; --timeout changes that for this run.
+
+With 'all', --parallel scans several networks at once. Each network's
+results are printed together as its scan finishes, so the output of one
+does not break up another's.
+
+--fail-on-new exits with status 1 when the scan finds a device not seen
+before, and --fail-on-missing when a known device did not answer, for cron
+jobs that alert on the exit status alone. The first scan of an empty
+inventory finds nothing new: it is what later scans are compared with.
+Errors exit with status 2.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runScan,
 }
 
+func init() {
+	scanCmd.Flags().DurationVar(&scanTimeout, "timeout", 0, "How long each network's scan may take, e.g. 30s or 20m (default scan_timeout)")
+	scanCmd.Flags().IntVarP(&scanParallel, "parallel", "p", 1, "Scan up to N networks at once")
+	scanCmd.Flags().BoolVar(&scanFailOnNew, "fail-on-new", false, "Exit with status 1 if the scan finds new devices")
+	scanCmd.Flags().BoolVar(&scanFailOnMissing, "fail-on-missing", false, "Exit with status 1 if known devices went offline")
+}
+
 func runScan(cmd *cobra.Command, args []string) error {
+	c, err := remoteClient()
+	if err != nil {
+		return err
+	}
+	if scanTimeout < 0 {
+		return fmt.Errorf("--timeout must be more than zero")
+	}
+	if scanParallel < 1 {
+		return fmt.Errorf("--parallel must be at least 1")
+	}
+	if c != nil {
+		if scanParallel > 1 {
+			return fmt.Errorf("--parallel applies to local scans; a server scans its networks itself")
+		}
+		if !scanFailOnNew && !scanFailOnMissing {
+			return runRemoteScan(c, args)
+		}
+		ctx, cancel := remoteContext()
+		before, err := c.Events(ctx, 1)
+		cancel()
+		if err != nil {
+			return err
+		}
+		if err := runRemoteScan(c, args); err != nil {
+			return err
+		}
+		ctx, cancel = remoteContext()
+		defer cancel()
+		after, err := c.Events(ctx, 0)
+		if err != nil {
+			return err
+		}
+		return scanFailOn(after, lastEventID(before))
+	}
+	timeout := scanTimeout
+	if timeout == 0 {
+		timeout = cfg.Scanning.Timeout()
+	}
+
 	// Initialize storage
-	store, err := storage.New(cfg.DevicesFile(), cfg.StateFile())
+	store, err := openStore(cmd)
 	if err != nil {
-		return fmt.Errorf("failed to initialize storage: %w", err)
+		return err
+	}
+
+	s := cfg.NewScanner()
+
+	networks, err := resolveNetworks(args)
+	if err != nil {
+		return err
+	}
+	lastEvent := lastEventID(store.GetEvents(1, false))
+
+	if scanParallel == 1 || len(networks) == 1 {
+		for _, cidr := range networks {
+			scanNetwork(s, store, cidr, timeout, os.Stdout)
+		}
+		return scanFailOn(store.GetEvents(0, false), lastEvent)
+	}
+
+	// Each worker collects a network's output and prints it in one write
+	// once the scan is done; writes to a file are not interleaved, so the
+	// networks' results come out whole, in the order they finish.
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for w := 0; w < scanParallel && w < len(networks); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for cidr := range jobs {
+				var out bytes.Buffer
+				scanNetwork(s, store, cidr, timeout, &out)
+				os.Stdout.Write(out.Bytes())
+			}
+		}()
+	}
+	for _, cidr := range networks {
+		jobs <- cidr
+	}
+	close(jobs)
+	wg.Wait()
+	return scanFailOn(store.GetEvents(0, false), lastEvent)
+}
+
+// lastEventID returns the ID of the newest of events, which come newest
+// first, or 0 if there are none.
+func lastEventID(events []types.Event) int64 {
+	if len(events) == 0 {
+		return 0
+	}
+	return events[0].ID
+}
+
+// scanFailOn applies --fail-on-new and --fail-on-missing to the scan, going
+// by the new device and offline events recorded after the event with ID
+// since: the store decides what counts as new, and notifications agree.
+func scanFailOn(events []types.Event, since int64) error {
+	appeared, went := 0, 0
+	for _, e := range events {
+		if e.ID <= since {
+			continue
+		}
+		switch e.Type {
+		case types.EventDeviceNew:
+			appeared++
+		case types.EventDeviceOffline:
+			went++
+		}
+	}
+	return failOn(scanFailOnNew, scanFailOnMissing, appeared, went)
+}
+
+// warnUnprivileged says, once, when scans have to do without raw sockets
+// unasked: they still work but find less, with no MAC addresses or vendors
+// and no hosts that only answer ping. A single scan says so with its results
+// instead.
+func warnUnprivileged(s *scanner.Scanner) {
+	if s.Privilege() == scanner.Unprivileged && cfg.Scanning.Privileged == "auto" {
+		_, reason := scanner.ChoosePrivilege(cfg.Scanning.Privileged)
+		slog.Warn("scanning without raw sockets, so devices will have no MAC address or vendor; run with sudo, or see orangutan doctor",
+			"reason", reason)
+	}
+}
+
+// scanNetwork scans cidr and saves what it finds, writing the outcome to out
+// and failures to standard error.
+func scanNetwork(s *scanner.Scanner, store *storage.Storage, cidr string, timeout time.Duration, out io.Writer) {
+	// Check rate limit
+	lastScan := store.GetLastScan(cidr)
+	canScan, waitTime := s.CheckRateLimit(lastScan)
+	if !canScan {
+		fmt.Printf("Rate limited for %s, wait %.0f seconds\n", cidr, waitTime.Seconds())
+		return
+	}
+
+	// Printed straight away rather than to out, to show which scans are
+	// under way.
+	fmt.Printf("Scanning %s...\n", cidr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	result, err := s.ScanWith(ctx, cidr, cfg.ForNetwork(cidr).ScanOptions())
+	cancel()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error scanning %s: %v\n", cidr, err)
+		_ = store.RecordScanFailure(cidr, err.Error())
+		return
+	}
+
+	if !result.Success {
+		fmt.Fprintf(os.Stderr, "Scan failed for %s: %s\n", cidr, result.Error)
+		_ = store.RecordScanFailure(cidr, result.Error)
+		return
+	}
+
+	// Merge devices
+	if err := store.MergeScan(cidr, result.Devices); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving devices: %v\n", err)
+		return
+	}
+
+	// Update last scan time
+	if err := store.SetLastScan(cidr, time.Now()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error updating scan state: %v\n", err)
+	}
+	if err := exportScan(store, cidr, result); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing to InfluxDB: %v\n", err)
+	}
+	if err := sendZabbix(store, cidr, result); err != nil {
+		fmt.Fprintf(os.Stderr, "Error sending to Zabbix: %v\n", err)
+	}
+	if err := writeDnsmasq(store); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing the dnsmasq file: %v\n", err)
+	}
+
+	fmt.Fprintf(out, "Found %d devices on %s using %s (%.2fs)\n\n", result.DeviceCount, cidr, result.Scanner, result.Duration)
+
+	// Warn if no MAC addresses found (permission issue)
+	if !printScanDevices(out, result.Devices) && s.Privilege() == scanner.Unprivileged {
+		switch runtime.GOOS {
+		case "darwin":
+			fmt.Fprintln(out, "Note: Run with sudo to get MAC addresses and vendor info:")
+			fmt.Fprintln(out, "  sudo ./orangutan scan")
+		case "linux":
+			fmt.Fprintln(out, "Note: Run with sudo for MAC addresses and vendor info:")
+			fmt.Fprintln(out, "  sudo orangutan scan")
+		}
+		fmt.Fprintln(out)
+	}
+}
+
+// printScanDevices writes the devices a scan found to out. It reports whether any
+// had a MAC address, which they lack when the scanner ran without root.
+func printScanDevices(out io.Writer, devices []types.Device) bool {
+	if len(devices) == 0 {
+		return true
 	}
 
-	// Create scanner
-	s := scanner.New(cfg.Scanning.MinScanInterval)
+	fmt.Fprintf(out, "%-16s %-18s %-20s %s\n", "IP", "MAC", "HOSTNAME", "VENDOR")
+	fmt.Fprintf(out, "%-16s %-18s %-20s %s\n", "──────────────", "─────────────────", "───────────────────", "──────────────────────")
+	hasMac := false
+	for _, d := range devices {
+		hostname := d.Hostname
+		if hostname == "" {
+			hostname = "-"
+		}
+		vendor := d.Vendor
+		if vendor == "" {
+			vendor = "-"
+		}
+		mac := d.MAC
+		if mac == "" {
+			mac = "-"
+		} else {
+			hasMac = true
+		}
+		fmt.Fprintf(out, "%-16s %-18s %-20s %s\n", d.IP, mac, truncate(hostname, 20), truncate(vendor, 30))
+	}
+	fmt.Fprintln(out)
+	return hasMac
+}
 
-	// Determine networks to scan
+// resolveNetworks turns the optional network argument shared by scan and
+// watch into the CIDRs to scan: the first detected network when there is no
+// argument, every detected network for "all", or the CIDR given. Networks
+// whose sections disable them are left out unless given by name.
+func resolveNetworks(args []string) ([]string, error) {
 	var networks []string
+	disabled := 0
 
 	if len(args) == 0 || args[0] == "" {
 		// Scan first detected network
 		detected, err := network.DetectNetworks()
 		detected = network.WithConfigured(detected, cfg.Scanning.Networks)
 		if err != nil {
-			return fmt.Errorf("failed to detect networks: %w", err)
+			return nil, fmt.Errorf("failed to detect networks: %w", err)
 		}
 		if len(detected) == 0 {
-			return fmt.Errorf("no networks detected")
+			return nil, fmt.Errorf("no networks detected")
 		}
 		// Skip Tailscale by default
 		for _, n := range detected {
+			if !cfg.ForNetwork(n.CIDR).Enable {
+				disabled++
+				continue
+			}
 			if !n.IsTailscale {
 				networks = append(networks, n.CIDR)
 				break
 			}
 		}
 		if len(networks) == 0 {
-			networks = append(networks, detected[0].CIDR)
+			for _, n := range detected {
+				if cfg.ForNetwork(n.CIDR).Enable {
+					networks = append(networks, n.CIDR)
+					break
+				}
+			}
 		}
 	} else if args[0] == "all" {
 		// Scan all detected networks
 		detected, err := network.DetectNetworks()
 		detected = network.WithConfigured(detected, cfg.Scanning.Networks)
 		if err != nil {
-			return fmt.Errorf("failed to detect networks: %w", err)
+			return nil, fmt.Errorf("failed to detect networks: %w", err)
 		}
 		for _, n := range detected {
+			if !cfg.ForNetwork(n.CIDR).Enable {
+				disabled++
+				continue
+			}
 			networks = append(networks, n.CIDR)
 		}
 	} else {
 		// Scan specified network
 		if !network.ValidateCIDR(args[0]) {
-			return fmt.Errorf("invalid CIDR: %s", args[0])
+			return nil, fmt.Errorf("invalid CIDR: %s", args[0])
 		}
 		networks = append(networks, args[0])
 	}
 
-	if len(networks) == 0 {
-		return fmt.Errorf("no networks to scan")
+	if len(networks) == 0 && disabled > 0 {
+		return nil, fmt.Errorf("no networks to scan: the config disables every detected network")
 	}
-
-	// Scan each network
-	for _, cidr := range networks {
-		// Check rate limit
-		lastScan := store.GetLastScan(cidr)
-		canScan, waitTime := s.CheckRateLimit(lastScan)
-		if !canScan {
-			fmt.Printf("Rate limited for %s, wait %.0f seconds\n", cidr, waitTime.Seconds())
-			continue
-		}
-
-		fmt.Printf("Scanning %s...\n", cidr)
-
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-		result, err := s.Scan(ctx, cidr)
-		cancel()
-
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error scanning %s: %v\n", cidr, err)
-			continue
-		}
-
-		if !result.Success {
-			fmt.Fprintf(os.Stderr, "Scan failed for %s: %s\n", cidr, result.Error)
-			continue
-		}
-
-		// Merge devices
-		if err := store.MergeDevices(result.Devices); err != nil {
-			fmt.Fprintf(os.Stderr, "Error saving devices: %v\n", err)
-			continue
-		}
-
-		// Update last scan time
-		if err := store.SetLastScan(cidr, time.Now()); err != nil {
-			fmt.Fprintf(os.Stderr, "Error updating scan state: %v\n", err)
-		}
-
-		fmt.Printf("Found %d devices using %s (%.2fs)\n\n", result.DeviceCount, result.Scanner, result.Duration)
-
-		// Display found devices
-		if len(result.Devices) > 0 {
-			fmt.Printf("%-16s %-18s %-20s %s\n", "IP", "MAC", "HOSTNAME", "VENDOR")
-			fmt.Printf("%-16s %-18s %-20s %s\n", "──────────────", "─────────────────", "───────────────────", "──────────────────────")
-			hasMac := false
-			for _, d := range result.Devices {
-				hostname := d.Hostname
-				if hostname == "" {
-					hostname = "-"
-				}
-				vendor := d.Vendor
-				if vendor == "" {
-					vendor = "-"
-				}
-				mac := d.MAC
-				if mac == "" {
-					mac = "-"
-				} else {
-					hasMac = true
-				}
-				fmt.Printf("%-16s %-18s %-20s %s\n", d.IP, mac, truncate(hostname, 20), truncate(vendor, 30))
-			}
-			fmt.Println()
-
-			// Warn if no MAC addresses found (permission issue)
-			if !hasMac && os.Getuid() != 0 {
-				switch runtime.GOOS {
-				case "darwin":
-					fmt.Println("Note: Run with sudo to get MAC addresses and vendor info:")
-					fmt.Println("  sudo ./orangutan scan")
-				case "linux":
-					fmt.Println("Note: Run with sudo for MAC addresses and vendor info:")
-					fmt.Println("  sudo orangutan scan")
-				}
-				fmt.Println()
-			}
-		}
+	if len(networks) == 0 {
+		return nil, fmt.Errorf("no networks to scan")
 	}
-
-	return nil
+	return networks, nil
 }
 
 // truncate shortens a string to maxLen, adding "..." if truncated
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/query"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var searchFormat string
+
+var searchCmd = &cobra.Command{
+	Use:   "search <query>...",
+	Short: "Find devices matching a query",
+	Long: `List the devices matching a query, in the same language as the API's
+GET /api/devices?q=. Every term must match:
+
+  word            any field contains word
+  field:value     vendor, hostname, label, group, notes, type, mac or ip contains value
+  tag:name        the device has this tag
+  ip:10.0.0.0/8   the address is in the network
+  status:online   seen in the last hour (or status:offline)
+  status:pending  waiting to be approved (or status:approved)
+  status:watched  on the watchlist, pinged between scans
+  has:label       the field is set
+  last_seen<7d    seen less than 7 days ago; > for more (m, h, d or w)
+  first_seen>2026-01-31
+                  first seen after a date; < for before
+  -term           the term does not match
+
+  orangutan search 'vendor:raspberry last_seen<7d'
+  orangutan search -- -has:label status:online
+  orangutan search 'label:"living room"' --format json`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runSearch,
+}
+
+func init() {
+	searchCmd.Flags().StringVar(&searchFormat, "format", "table", "Output format (table, csv, json, yaml, xml)")
+}
+
+func runSearch(cmd *cobra.Command, args []string) error {
+	// A query can be one quoted argument or several; quotes around values
+	// with spaces must reach the parser, as in 'label:"living room"'.
+	q := strings.Join(args, " ")
+	// Parsed here in remote mode too, so a mistake is reported the same way
+	// without a round trip.
+	parsed, err := query.Parse(q)
+	if err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+
+	var devices map[string]*types.Device
+	c, err := remoteClient()
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		ctx, cancel := remoteContext()
+		defer cancel()
+		devices, err = c.SearchDevices(ctx, q)
+		if err != nil {
+			return err
+		}
+	} else {
+		store, err := openStore(cmd)
+		if err != nil {
+			return err
+		}
+		devices = parsed.Filter(store.GetDevices())
+	}
+
+	found := make([]*types.Device, 0, len(devices))
+	for _, d := range devices {
+		found = append(found, d)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return types.LessIP(found[i].IP, found[j].IP)
+	})
+
+	return outputDevices(os.Stdout, searchFormat, nil, found)
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
 	"strconv"
 	"strings"
 	"syscall"
@@ -17,7 +19,11 @@ import (
 
 	"github.com/291-Group/LAN-Orangutan/internal/api"
 	"github.com/291-Group/LAN-Orangutan/internal/auth"
+	"github.com/291-Group/LAN-Orangutan/internal/config"
+	"github.com/291-Group/LAN-Orangutan/internal/ldap"
 	"github.com/291-Group/LAN-Orangutan/internal/network"
+	"github.com/291-Group/LAN-Orangutan/internal/oidc"
+	"github.com/291-Group/LAN-Orangutan/internal/scanner"
 	"github.com/291-Group/LAN-Orangutan/internal/storage"
 	"github.com/291-Group/LAN-Orangutan/internal/web"
 )
@@ -26,6 +32,10 @@ var (
 	servePort          int
 	serveBind          string
 	serveAllowInsecure bool
+
+	// deniedPort is the port the server was refused and fell back from to
+	// unprivileged_port, or 0.
+	deniedPort int
 )
 
 var serveCmd = &cobra.Command{
@@ -47,23 +57,14 @@ func runServe(cmd *cobra.Command, args []string) error {
 	// problem, not a usage error. Report it without the flag listing.
 	cmd.SilenceUsage = true
 
-	// Use flags or config
+	applyServeFlags(cfg)
 	port := cfg.Server.Port
-	if servePort > 0 {
-		port = servePort
-	}
 	bind := cfg.Server.BindAddress
-	if serveBind != "" {
-		bind = serveBind
-	}
-
-	// Flags win over the config file and environment.
-	cfg.Server.BindAddress = bind
-	if serveAllowInsecure {
-		cfg.Server.AllowInsecure = true
-	}
 
 	// Initialize storage
+	if err := prepareDataDir(cfg.Storage.DataDir); err != nil {
+		return err
+	}
 	store, err := storage.New(cfg.DevicesFile(), cfg.StateFile())
 	if err != nil {
 		return fmt.Errorf("failed to initialize storage: %w", err)
@@ -75,6 +76,9 @@ func runServe(cmd *cobra.Command, args []string) error {
 	if password == "" {
 		password = auth.LoadHash(cfg.PasswordFile())
 		cfg.Server.Password = password
+		if password != "" {
+			cfg.SetSource("server.password", "setup "+cfg.PasswordFile())
+		}
 	}
 
 	authn, err := auth.New(password, cfg.SessionTTL())
@@ -82,12 +86,32 @@ func runServe(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to set up authentication: %w", err)
 	}
 	authn.SetSetupRequired(cfg.RequiresSetup())
+	authn.SetUsername(cfg.Server.Username)
+	authn.SetAPIToken(cfg.Server.APIToken)
+
+	// Users of a directory or an OpenID Connect provider sign in as well as,
+	// or instead of, with the password.
+	authn.SetRoles(cfg.Roles.Roles())
+	if cfg.LDAP.URL != "" {
+		directory, err := ldap.New(cfg.LDAP.Options())
+		if err != nil {
+			return fmt.Errorf("failed to set up LDAP: %w", err)
+		}
+		authn.SetDirectory(directory)
+	}
+	var sso *oidc.Provider
+	if cfg.OIDC.Issuer != "" {
+		sso = oidc.New(cfg.OIDC.Options())
+		authn.SetSingleSignOn(true)
+	}
 
 	// Create HTTP handler
 	mux := http.NewServeMux()
 
 	webHandler := web.NewHandler(store, cfg, authn, Version)
+	webHandler.SetSingleSignOn(sso)
 	apiHandler := api.NewHandler(store, cfg)
+	apiHandler.SetUserFor(func(r *http.Request) string { return authn.UserFor(r).Name })
 
 	// Protected routes.
 	mux.Handle("/api/", authn.Middleware(apiHandler))
@@ -96,8 +120,11 @@ func runServe(cmd *cobra.Command, args []string) error {
 	// Public routes. Static assets stay open so the login and setup pages can
 	// style themselves, and those forms must be reachable while signed out.
 	mux.Handle("/static/", webHandler.StaticHandler())
+	mux.HandleFunc("/sw.js", webHandler.ServiceWorker)
 	mux.HandleFunc(auth.LoginPath, webHandler.HandleLogin)
 	mux.HandleFunc(auth.LogoutPath, webHandler.HandleLogout)
+	mux.HandleFunc(auth.SingleSignOnPath, webHandler.HandleSingleSignOn)
+	mux.HandleFunc(auth.SingleSignOnCallbackPath, webHandler.HandleSingleSignOnCallback)
 	mux.HandleFunc(auth.SetupPath, webHandler.HandleSetup(func(hash string) error {
 		return auth.SaveHash(cfg.PasswordFile(), hash)
 	}))
@@ -111,12 +138,30 @@ func runServe(cmd *cobra.Command, args []string) error {
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  60 * time.Second,
+		// The server's own complaints, such as failed accepts or a handler
+		// writing its header twice, go to the shared log like everything else.
+		ErrorLog: slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
 	}
 
 	// Claim the port before announcing anything. Printing "Starting..." and the
 	// whole status banner first means a failure to bind reads as though the
 	// server came up and then died.
 	listener, err := net.Listen(listenNetwork(bind), addr)
+	// Ports below 1024 need root on Linux and macOS, and the default of 291
+	// is one of them. Rather than fail for everyone trying it out without
+	// sudo, fall back to a port anyone may have, and say so.
+	if err != nil && errors.Is(err, os.ErrPermission) && port < 1024 && cfg.Server.UnprivilegedPort > 0 {
+		fallback := cfg.Server.UnprivilegedPort
+		fmt.Fprintf(os.Stderr, "Port %d needs root; using port %d instead. Run with sudo for port %d, or set unprivileged_port = 0 to fail instead.\n",
+			port, fallback, port)
+		cfg.SetSource("server.port", fmt.Sprintf("unprivileged_port, as port %d needs root", port))
+		deniedPort = port
+		port = fallback
+		addr = net.JoinHostPort(bind, strconv.Itoa(port))
+		server.Addr = addr
+		cfg.Server.Port = port
+		listener, err = net.Listen(listenNetwork(bind), addr)
+	}
 	if err != nil {
 		if isAddrInUse(err) {
 			return fmt.Errorf("port %d is already in use. Stop whatever is using it, or choose another with --port", port)
@@ -124,6 +169,29 @@ func runServe(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("could not listen on %s: %w", addr, err)
 	}
 
+	ctx, stopPublishing := context.WithCancel(context.Background())
+	defer stopPublishing()
+	startMQTT(ctx, store)
+	startTextfile(ctx, store)
+	alerts := startAlerts(ctx, store)
+	startReports(ctx, store)
+	startWatch(ctx, store)
+	startCerts(ctx, store)
+	startFingerprints(ctx, store)
+	startTailnet(ctx, server)
+	startMDNS(ctx, port)
+
+	// SIGHUP reloads the config file, as daemons conventionally do, applying
+	// what can change without dropping connections or the scan in progress.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		current := cfg
+		for range hup {
+			current = reloadConfig(current, authn, webHandler, apiHandler, alerts)
+		}
+	}()
+
 	// Handle shutdown gracefully
 	done := make(chan bool, 1)
 	quit := make(chan os.Signal, 1)
@@ -132,6 +200,7 @@ func runServe(cmd *cobra.Command, args []string) error {
 	go func() {
 		<-quit
 		fmt.Println("\nShutting down server...")
+		stopPublishing()
 
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
@@ -147,7 +216,9 @@ func runServe(cmd *cobra.Command, args []string) error {
 
 	// Be explicit about who can reach this and whether it is protected, so
 	// nobody has to guess at their own exposure.
-	if cfg.IsLoopbackBind() {
+	if cfg.IsLoopbackBind() && cfg.Tailscale.Serve {
+		fmt.Println("Reachable from: this machine and the tailnet")
+	} else if cfg.IsLoopbackBind() {
 		fmt.Println("Reachable from: this machine only")
 	} else {
 		// Name the actual addresses. "0.0.0.0" tells the user nothing about
@@ -166,9 +237,13 @@ func runServe(cmd *cobra.Command, args []string) error {
 	switch {
 	case authn.NeedsSetup():
 		fmt.Println("Password:       not set yet, open the page above to create one")
+	case authn.SingleSignOn() && authn.PasswordLogin():
+		fmt.Println("Password:       required, or sign in with single sign-on")
+	case authn.SingleSignOn():
+		fmt.Println("Password:       sign in with single sign-on")
 	case authn.Enabled():
 		fmt.Println("Password:       required")
-	case cfg.IsLoopbackBind():
+	case cfg.IsLoopbackBind() && !cfg.Tailscale.Serve:
 		fmt.Println("Password:       not set (not needed while local only)")
 	default:
 		fmt.Println("Password:       NOT SET, and this server is exposed to the network")
@@ -196,6 +271,10 @@ func runServe(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if privilege, reason := scanner.ChoosePrivilege(cfg.Scanning.Privileged); privilege == scanner.Unprivileged {
+		fmt.Printf("Scans:          without raw sockets (%s), so no MAC addresses or vendors\n", reason)
+	}
+
 	fmt.Println("Press Ctrl+C to stop")
 
 	if err := server.Serve(listener); err != http.ErrServerClosed {
@@ -207,6 +286,110 @@ func runServe(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// applyServeFlags overrides c with the serve command's flags, which win over
+// the config file and environment.
+func applyServeFlags(c *config.Config) {
+	if servePort > 0 {
+		c.Server.Port = servePort
+		c.SetSource("server.port", "flag --port")
+	}
+	if serveBind != "" {
+		c.Server.BindAddress = serveBind
+		c.SetSource("server.bind_address", "flag --bind")
+	}
+	if serveAllowInsecure {
+		c.Server.AllowInsecure = true
+		c.SetSource("server.allow_insecure", "flag --allow-insecure")
+	}
+}
+
+// reloadConfig reads the config file again and hands the settings that can
+// change on a running server to the handlers and authenticator: scan
+// settings, networks, approval, theme, language, username, API token and
+// roles among them, and to the alerts: notifiers, alert rules, maintenance
+// windows and escalations. The address, data directory, password, session
+// length, directory, OpenID Connect provider, mDNS name, MQTT broker,
+// metrics file and tailnet node are fixed when the server starts, so changes
+// to them are logged and wait for a restart.
+//
+// It returns the config now in use, which is old when the file cannot be
+// read: a typo made while editing must not take a running server down.
+func reloadConfig(old *config.Config, authn *auth.Authenticator, webHandler *web.Handler, apiHandler *api.Handler, alerts *alerter) *config.Config {
+	next, err := loadConfig()
+	if err != nil {
+		slog.Error("config not reloaded", "file", cfgFile, "error", err)
+		return old
+	}
+	applyServeFlags(next)
+	// Still the port asked for, which the server was refused.
+	if deniedPort != 0 && next.Server.Port == deniedPort {
+		next.Server.Port = old.Server.Port
+	}
+
+	for _, s := range []struct {
+		name    string
+		changed bool
+	}{
+		{"port", next.Server.Port != old.Server.Port},
+		{"bind_address", next.Server.BindAddress != old.Server.BindAddress},
+		{"data_dir", next.Storage.DataDir != old.Storage.DataDir},
+		// At start the password may have come from the setup page instead.
+		{"password", next.Server.Password != "" && next.Server.Password != old.Server.Password},
+		{"session_hours", next.Server.SessionHours != old.Server.SessionHours},
+		{"ldap", next.LDAP != old.LDAP},
+		{"oidc", !reflect.DeepEqual(next.OIDC, old.OIDC)},
+		{"mdns", next.Server.MDNS != old.Server.MDNS || next.Server.MDNSName != old.Server.MDNSName},
+		{"mqtt", next.MQTT != old.MQTT},
+		{"metrics", next.Metrics != old.Metrics},
+		{"tailscale serve", next.Tailscale.Serve != old.Tailscale.Serve || next.Tailscale.Hostname != old.Tailscale.Hostname ||
+			next.Tailscale.AuthKey != old.Tailscale.AuthKey || next.Tailscale.HTTPS != old.Tailscale.HTTPS},
+	} {
+		if s.changed {
+			slog.Warn("config setting changed; restart the server to apply it", "setting", s.name)
+		}
+	}
+	next.Server.Port = old.Server.Port
+	next.Server.BindAddress = old.Server.BindAddress
+	next.Storage.DataDir = old.Storage.DataDir
+	next.Server.Password = old.Server.Password
+	next.Server.SessionHours = old.Server.SessionHours
+	next.LDAP = old.LDAP
+	next.OIDC = old.OIDC
+	next.Server.MDNS = old.Server.MDNS
+	next.Server.MDNSName = old.Server.MDNSName
+	next.MQTT = old.MQTT
+	next.Metrics = old.Metrics
+	next.Tailscale.Serve = old.Tailscale.Serve
+	next.Tailscale.Hostname = old.Tailscale.Hostname
+	next.Tailscale.AuthKey = old.Tailscale.AuthKey
+	next.Tailscale.HTTPS = old.Tailscale.HTTPS
+	for _, key := range []string{"server.port", "server.bind_address", "storage.data_dir", "server.password", "server.session_hours",
+		"server.mdns", "server.mdns_name",
+		"tailscale.serve", "tailscale.hostname", "tailscale.auth_key", "tailscale.https"} {
+		next.SetSource(key, old.Source(key))
+	}
+	for _, s := range old.Effective() {
+		if strings.HasPrefix(s.Key, "ldap.") || strings.HasPrefix(s.Key, "oidc.") ||
+			strings.HasPrefix(s.Key, "mqtt.") || strings.HasPrefix(s.Key, "metrics.") {
+			next.SetSource(s.Key, s.Source)
+		}
+	}
+
+	authn.SetUsername(next.Server.Username)
+	authn.SetAPIToken(next.Server.APIToken)
+	authn.SetSetupRequired(next.RequiresSetup())
+	authn.SetRoles(next.Roles.Roles())
+	webHandler.SetConfig(next)
+	apiHandler.SetConfig(next)
+	alerts.reload(next)
+
+	slog.Info("reloaded config", "file", cfgFile)
+	for _, problem := range next.Validate() {
+		slog.Warn("config problem", "problem", problem)
+	}
+	return next
+}
+
 // isAddrInUse reports whether err is the operating system refusing a port
 // because something else already holds it.
 func isAddrInUse(err error) bool {
@@ -0,0 +1,250 @@
+package cli
+
+import (
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/service"
+)
+
+var (
+	serviceUser      string
+	serviceScanEvery time.Duration
+	serviceNoServer  bool
+	servicePrint     bool
+)
+
+// systemdUnitDir is where install-service writes its units.
+const systemdUnitDir = "/etc/systemd/system"
+
+var installServiceCmd = &cobra.Command{
+	Use:   "install-service",
+	Short: "Install and start the server as a systemd service",
+	Long: `Write, enable and start a systemd unit that runs 'orangutan serve' with this
+binary, config file and data directory.
+
+The service runs as its own user, created if it does not exist, with just
+the capabilities nmap needs to find MAC addresses (CAP_NET_RAW and
+CAP_NET_ADMIN, and CAP_NET_BIND_SERVICE for a port below 1024) rather than
+as root. The data directory is handed to that user, and the config file made
+readable by its group. Use --user root to run as root instead.
+
+With --scan-every a timer is installed as well, scanning all networks at that
+interval. The scans go through the server's API, so the config file needs an
+api_token; with --no-server they run on their own instead.
+
+Run it again after changing options to update the installed units. Use
+--print to see the units without installing anything.`,
+	Args: cobra.NoArgs,
+	RunE: runInstallService,
+}
+
+func init() {
+	installServiceCmd.Flags().StringVar(&serviceUser, "user", "lan-orangutan", "User to run the service as")
+	installServiceCmd.Flags().DurationVar(&serviceScanEvery, "scan-every", 0, "Also scan all networks at this interval, e.g. 15m or 1h")
+	installServiceCmd.Flags().BoolVar(&serviceNoServer, "no-server", false, "Install only the scheduled scans")
+	installServiceCmd.Flags().BoolVar(&servicePrint, "print", false, "Print the units instead of installing them")
+}
+
+func runInstallService(cmd *cobra.Command, args []string) error {
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("install-service sets up systemd, which needs Linux; see docs/INSTALL.md for other systems")
+	}
+	if serviceNoServer && serviceScanEvery == 0 {
+		return fmt.Errorf("--no-server leaves nothing to install without --scan-every")
+	}
+
+	binary, err := os.Executable()
+	if err == nil {
+		binary, err = filepath.EvalSymlinks(binary)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find this program's path: %w", err)
+	}
+	configFile, err := filepath.Abs(cfgFile)
+	if err != nil {
+		return err
+	}
+	dataDir, err := filepath.Abs(cfg.Storage.DataDir)
+	if err != nil {
+		return err
+	}
+
+	opts := service.Options{
+		Binary:     binary,
+		ConfigFile: configFile,
+		DataDir:    dataDir,
+		Profile:    cfg.Profile(),
+		User:       serviceUser,
+		Group:      serviceUser,
+		Serve:      !serviceNoServer,
+		Port:       cfg.Server.Port,
+		ScanEvery:  serviceScanEvery,
+	}
+	if opts.Serve && opts.ScanEvery > 0 {
+		if cfg.Server.APIToken == "" {
+			return fmt.Errorf("scheduled scans go through the server and need its api_token; set one in %s, or use --no-server", configFile)
+		}
+		opts.Server = "http://" + localServerAddr()
+	}
+	// A user that already exists may have a primary group of another name.
+	if u, err := user.Lookup(serviceUser); err == nil {
+		if g, err := user.LookupGroupId(u.Gid); err == nil {
+			opts.Group = g.Name
+		}
+	}
+
+	units, err := service.Units(opts)
+	if err != nil {
+		return err
+	}
+	if servicePrint {
+		for _, u := range units {
+			fmt.Printf("# %s\n%s\n", filepath.Join(systemdUnitDir, u.Name), u.Content)
+		}
+		return nil
+	}
+
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("installing a service needs root; run it with sudo, or use --print to see the units")
+	}
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return fmt.Errorf("systemctl not found; install-service needs systemd")
+	}
+
+	if serviceUser != "root" {
+		created, err := ensureServiceUser(serviceUser, dataDir)
+		if err != nil {
+			return err
+		}
+		if created {
+			fmt.Printf("Created system user %s\n", serviceUser)
+		}
+	}
+	if err := prepareServiceFiles(serviceUser, dataDir, configFile); err != nil {
+		return err
+	}
+
+	for _, u := range units {
+		if err := os.WriteFile(filepath.Join(systemdUnitDir, u.Name), []byte(u.Content), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", u.Name, err)
+		}
+		fmt.Printf("Wrote %s\n", filepath.Join(systemdUnitDir, u.Name))
+	}
+
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	if opts.Serve {
+		// Restarted rather than just started, so a running server picks up
+		// the new unit.
+		if err := systemctl("enable", service.ServerUnitName); err != nil {
+			return err
+		}
+		if err := systemctl("restart", service.ServerUnitName); err != nil {
+			return err
+		}
+		fmt.Printf("Started %s; check it with: journalctl -u %s\n", service.ServerUnitName, service.ServerUnitName)
+	}
+	if opts.ScanEvery > 0 {
+		if err := systemctl("enable", "--now", service.ScanTimerName); err != nil {
+			return err
+		}
+		fmt.Printf("Scanning every %s; see when with: systemctl list-timers %s\n", opts.ScanEvery, service.ScanTimerName)
+	}
+	return nil
+}
+
+// localServerAddr returns the address a scan on this machine reaches the
+// configured server on.
+func localServerAddr() string {
+	host := cfg.Server.BindAddress
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(cfg.Server.Port))
+}
+
+// ensureServiceUser creates name as a system user with its own group and no
+// login, unless it exists already. It reports whether it created it.
+func ensureServiceUser(name, home string) (bool, error) {
+	if _, err := user.Lookup(name); err == nil {
+		return false, nil
+	}
+	if _, err := exec.LookPath("useradd"); err != nil {
+		return false, fmt.Errorf("user %s does not exist and useradd was not found to create it; create it yourself, or use --user root", name)
+	}
+	out, err := exec.Command("useradd", "--system", "--user-group", "--no-create-home",
+		"--home-dir", home, "--shell", "/usr/sbin/nologin", name).CombinedOutput()
+	if err != nil {
+		return false, fmt.Errorf("failed to create user %s: %v: %s", name, err, out)
+	}
+	return true, nil
+}
+
+// prepareServiceFiles hands the data directory to the service user, creating
+// it if needed, and lets the user's group read the config file. The config
+// file stays owned by root: it may hold the API token, and the service has no
+// reason to change it.
+func prepareServiceFiles(name, dataDir, configFile string) error {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return fmt.Errorf("failed to look up user %s: %w", name, err)
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return fmt.Errorf("user %s has a non-numeric uid %q", name, u.Uid)
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return fmt.Errorf("user %s has a non-numeric gid %q", name, u.Gid)
+	}
+
+	if err := os.MkdirAll(dataDir, 0o750); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	err = filepath.WalkDir(dataDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(path, uid, gid)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to give %s the data directory: %w", name, err)
+	}
+	if err := os.Chmod(dataDir, 0o750); err != nil {
+		return fmt.Errorf("failed to set data directory permissions: %w", err)
+	}
+
+	if _, err := os.Stat(configFile); os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.Chown(configFile, 0, gid); err != nil {
+		return fmt.Errorf("failed to let %s read the config file: %w", name, err)
+	}
+	if err := os.Chmod(configFile, 0o640); err != nil {
+		return fmt.Errorf("failed to let %s read the config file: %w", name, err)
+	}
+	return nil
+}
+
+// systemctl runs systemctl with args, showing its output.
+func systemctl(args ...string) error {
+	c := exec.Command("systemctl", args...)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("systemctl %s failed: %w", args[0], err)
+	}
+	return nil
+}
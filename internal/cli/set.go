@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/query"
+	"github.com/291-Group/LAN-Orangutan/internal/scanner"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var (
+	setLabel      string
+	setGroup      string
+	setNotes      string
+	setType       string
+	setAddTags    []string
+	setRemoveTags []string
+	setOffline    string
+	setWatch      bool
+)
+
+var setCmd = &cobra.Command{
+	Use:   "set <ip|mac>",
+	Short: "Set a device's label, group, notes, type, tags, grace period or watch",
+	Long: `Change the details stored for a device, identified by its IP or MAC address.
+Only the fields given are changed; pass an empty value to clear one:
+
+  orangutan set 192.168.1.20 --label "Living room TV" --group Media
+  orangutan set aa:bb:cc:dd:ee:ff --notes ""
+  orangutan set 192.168.1.20 --tag upstairs --untag office
+  orangutan set nas --offline-after 10m
+  orangutan set router --watch
+
+--offline-after is how long the device may be missing from scans before it is
+reported offline, in place of its group's or the default in [offline].
+
+--watch puts the device on the watchlist of those that should always be
+online: the server and monitor ping it between scans, as [watchlist] says, and
+report it offline as soon as it stops answering. --watch=false takes it off.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSet,
+}
+
+func init() {
+	setCmd.Flags().StringVar(&setLabel, "label", "", "Set the label")
+	setCmd.Flags().StringVar(&setGroup, "group", "", "Set the group")
+	_ = setCmd.RegisterFlagCompletionFunc("group", completeGroups)
+	setCmd.Flags().StringVar(&setNotes, "notes", "", "Set the notes")
+	setCmd.Flags().StringVar(&setType, "type", "", "Set the device type, or \"\" to detect it automatically")
+	setCmd.Flags().StringArrayVar(&setAddTags, "tag", nil, "Add a tag (repeatable)")
+	setCmd.Flags().StringArrayVar(&setRemoveTags, "untag", nil, "Remove a tag (repeatable)")
+	setCmd.Flags().StringVar(&setOffline, "offline-after", "", "Report the device offline once missing this long (30m, 2h, 1d), or \"\" for its group's")
+	setCmd.Flags().BoolVar(&setWatch, "watch", false, "Ping the device between scans and report it as soon as it stops answering")
+}
+
+func runSet(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	changed := false
+	for _, name := range []string{"label", "group", "notes", "type", "tag", "untag", "offline-after", "watch"} {
+		changed = changed || flags.Changed(name)
+	}
+	if !changed {
+		return fmt.Errorf("nothing to set: use --label, --group, --notes, --type, --tag, --untag, --offline-after or --watch")
+	}
+	if flags.Changed("type") && !scanner.ValidType(setType) {
+		return fmt.Errorf("unknown device type %q (known types: %s)", setType, strings.Join(scanner.DeviceTypes, ", "))
+	}
+	var offlineAfter time.Duration
+	if setOffline != "" {
+		var err error
+		if offlineAfter, err = query.ParseAge(setOffline); err != nil {
+			return fmt.Errorf("--offline-after: %w", err)
+		}
+	}
+
+	c, err := remoteClient()
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		return setRemote(c, cmd, args[0])
+	}
+
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	device, err := findDevice(store.GetDevices(), args[0])
+	if err != nil {
+		return err
+	}
+
+	_, err = store.UpdateDevices([]string{device.IP}, func(d *types.Device) {
+		if flags.Changed("label") {
+			d.Label = setLabel
+		}
+		if flags.Changed("group") {
+			d.Group = setGroup
+		}
+		if flags.Changed("notes") {
+			d.Notes = setNotes
+		}
+		if flags.Changed("type") {
+			d.Type = setType
+		}
+		for _, tag := range setAddTags {
+			d.AddTag(tag)
+		}
+		for _, tag := range setRemoveTags {
+			d.RemoveTag(tag)
+		}
+		if flags.Changed("offline-after") {
+			d.OfflineAfter = int(offlineAfter / time.Second)
+		}
+		if flags.Changed("watch") {
+			d.Watch = setWatch
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save device: %w", err)
+	}
+
+	fmt.Printf("Updated %s\n", device.IP)
+	return nil
+}
+
+// findDevice looks a device up by IP or MAC address. A MAC matches however it
+// is written, and must belong to only one device: the same hardware can be
+// stored under several addresses after DHCP hands it new leases.
+func findDevice(devices map[string]*types.Device, key string) (*types.Device, error) {
+	if d, ok := devices[key]; ok {
+		return d, nil
+	}
+
+	mac, err := net.ParseMAC(key)
+	if err != nil {
+		if net.ParseIP(key) != nil {
+			return nil, fmt.Errorf("device not found: %s", key)
+		}
+		return nil, fmt.Errorf("not an IP or MAC address: %s", key)
+	}
+
+	var matches []*types.Device
+	for _, d := range devices {
+		if hw, err := net.ParseMAC(d.MAC); err == nil && hw.String() == mac.String() {
+			matches = append(matches, d)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("device not found: %s", key)
+	case 1:
+		return matches[0], nil
+	}
+
+	ips := make([]string, len(matches))
+	for i, d := range matches {
+		ips[i] = d.IP
+	}
+	sort.Slice(ips, func(i, j int) bool { return types.LessIP(ips[i], ips[j]) })
+	return nil, fmt.Errorf("%s belongs to several devices (%s); use the IP address instead", key, strings.Join(ips, ", "))
+}
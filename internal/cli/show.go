@@ -0,0 +1,228 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/query"
+	"github.com/291-Group/LAN-Orangutan/internal/scanner"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var showDays int
+
+var showCmd = &cobra.Command{
+	Use:   "show <ip|mac|label>",
+	Short: "Show everything known about a device",
+	Long: `Print every detail of one device, untruncated, followed by when it was
+online and the events about it over the last few days.
+
+The device can be given by IP address, MAC address, label or hostname.
+For changes to its details over time, see 'orangutan history'.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runShow,
+}
+
+func init() {
+	showCmd.Flags().IntVar(&showDays, "days", 7, "Show sightings and events from the last N days")
+}
+
+func runShow(cmd *cobra.Command, args []string) error {
+	if showDays < 1 {
+		return fmt.Errorf("--days must be at least 1")
+	}
+	_, d, err := resolveTarget(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	if d == nil {
+		return fmt.Errorf("device not found: %s", args[0])
+	}
+	since := time.Now().AddDate(0, 0, -showDays)
+
+	var sightings []types.Sighting
+	var events []types.Event
+	var deepScan *types.DeepScan
+	c, err := remoteClient()
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		// The server keeps at most 30 days of sightings to hand out.
+		days := showDays
+		if days > 30 {
+			days = 30
+		}
+		ctx, cancel := remoteContext()
+		defer cancel()
+		if sightings, err = c.Sightings(ctx, d.IP, days); err != nil {
+			return err
+		}
+		if events, err = c.Events(ctx, 0); err != nil {
+			return err
+		}
+		scans, err := c.Findings(ctx)
+		if err != nil {
+			return err
+		}
+		if scan, ok := scans[d.IP]; ok {
+			deepScan = &scan
+		}
+	} else {
+		store, err := openStore(cmd)
+		if err != nil {
+			return err
+		}
+		sightings = store.GetSightings(d.IP, since)
+		events = store.GetEvents(0, false)
+		deepScan = store.GetDeepScan(d.IP)
+	}
+
+	printDevice(d)
+
+	fmt.Printf("\nSightings (last %d days)\n", showDays)
+	if len(sightings) == 0 {
+		fmt.Println("  None")
+	}
+	for _, sg := range sightings {
+		if sg.End.Equal(sg.Start) {
+			fmt.Printf("  %s  seen once\n", sg.Start.Format("Mon 2006-01-02 15:04"))
+			continue
+		}
+		end := sg.End.Format("15:04")
+		if sg.End.Format("2006-01-02") != sg.Start.Format("2006-01-02") {
+			end = sg.End.Format("Jan 2 15:04")
+		}
+		fmt.Printf("  %s - %-11s online for %s\n", sg.Start.Format("Mon 2006-01-02 15:04"), end, formatDuration(sg.End.Sub(sg.Start)))
+	}
+
+	fmt.Printf("\nEvents (last %d days)\n", showDays)
+	shown := 0
+	// Events come newest first; read them in the order they happened.
+	for i := len(events) - 1; i >= 0; i-- {
+		e := events[i]
+		if e.IP != d.IP || e.Time.Before(since) {
+			continue
+		}
+		fmt.Printf("  %s  %s\n", e.Time.Format("2006-01-02 15:04"), e.Message)
+		shown++
+	}
+	if shown == 0 {
+		fmt.Println("  None")
+	}
+
+	if deepScan != nil {
+		var ran []string
+		if !deepScan.Time.IsZero() {
+			ran = append(ran, "deep scan of "+deepScan.Time.Format("2006-01-02 15:04"))
+		}
+		if checked, ok := deepScan.Checked[types.CheckDefaultLogins]; ok {
+			ran = append(ran, "default logins tried "+checked.Format("2006-01-02 15:04"))
+		}
+		fmt.Printf("\nFindings (%s)\n", strings.Join(ran, ", "))
+		if len(deepScan.Findings) == 0 {
+			fmt.Println("  None")
+		}
+		for _, f := range deepScan.Findings {
+			text := findingText(f)
+			if f.ID != "" {
+				text = f.ID + ": " + text
+			}
+			if f.Port != 0 {
+				text = fmt.Sprintf("%d/%s %s", f.Port, f.Protocol, text)
+			}
+			fmt.Printf("  %-8s  %s\n", strings.ToUpper(f.Severity), text)
+		}
+	}
+	return nil
+}
+
+// wirelessSummary describes how a device is on Wi-Fi, as in "home, 5GHz
+// channel 36, -58 dBm at attic/phy1-ap0 (2m ago)", or "" for none.
+func wirelessSummary(w *types.Wireless) string {
+	if w == nil {
+		return ""
+	}
+	radio := strings.TrimSpace(w.Band)
+	if w.Channel != 0 {
+		radio = strings.TrimSpace(fmt.Sprintf("%s channel %d", radio, w.Channel))
+	}
+	s := w.SSID
+	if radio != "" {
+		s += ", " + radio
+	}
+	s += fmt.Sprintf(", %d dBm", w.Signal)
+	if w.AccessPoint != "" {
+		s += " at " + w.AccessPoint
+	}
+	if !w.Seen.IsZero() {
+		s += fmt.Sprintf(" (%s ago)", formatDuration(time.Since(w.Seen)))
+	}
+	return s
+}
+
+// printDevice writes every field of d, one to a line, leaving out those that
+// are empty.
+func printDevice(d *types.Device) {
+	fmt.Println(deviceDisplayName(d))
+
+	deviceType := d.Type
+	if deviceType == "" {
+		if detected := scanner.DetectType(d); detected != "" {
+			deviceType = detected + " (detected)"
+		}
+	}
+	var responseTime string
+	if d.ResponseTime != nil {
+		responseTime = strconv.FormatFloat(*d.ResponseTime, 'f', 2, 64) + " ms"
+	}
+	var lastSeen string
+	if !d.LastSeen.IsZero() {
+		lastSeen = fmt.Sprintf("%s (%s ago)", d.LastSeen.Format(timeFormat), formatDuration(time.Since(d.LastSeen)))
+	}
+	var firstSeen string
+	if !d.FirstSeen.IsZero() {
+		firstSeen = d.FirstSeen.Format(timeFormat)
+	}
+	var offlineAfter string
+	if d.OfflineAfter > 0 {
+		offlineAfter = query.FormatAge(time.Duration(d.OfflineAfter) * time.Second)
+	}
+	var watched string
+	if d.Watch {
+		watched = "yes, pinged between scans"
+	}
+
+	for _, f := range []struct{ name, value string }{
+		{"Address", d.IP},
+		{"MAC", d.MAC},
+		{"Hostname", d.Hostname},
+		{"Vendor", scanner.ResolveVendor(d.Vendor, d.MAC)},
+		{"Label", d.Label},
+		{"Group", d.Group},
+		{"Type", deviceType},
+		{"Tags", strings.Join(d.Tags, ", ")},
+		{"Status", deviceStatus(d)},
+		{"First seen", firstSeen},
+		{"Last seen", lastSeen},
+		{"Offline after", offlineAfter},
+		{"Watched", watched},
+		{"Response", responseTime},
+		{"Wi-Fi", wirelessSummary(d.Wireless)},
+	} {
+		if f.value != "" {
+			fmt.Printf("  %-15s%s\n", f.name+":", f.value)
+		}
+	}
+	if d.Notes != "" {
+		// Notes can run to several lines; keep them under the heading.
+		fmt.Println("  Notes:")
+		for _, line := range strings.Split(strings.TrimRight(d.Notes, "\n"), "\n") {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/client"
+	"github.com/291-Group/LAN-Orangutan/internal/query"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var (
+	silenceFor      string
+	silenceReason   string
+	silenceGroups   []string
+	silenceNetworks []string
+	silenceAll      bool
+	silenceBy       string
+	silenceListAll  bool
+)
+
+var silenceCmd = &cobra.Command{
+	Use:   "silence",
+	Short: "Mute alerts about devices for a while",
+	Long: `Stop alerts about some devices, groups or networks for a while, such as a
+bulb that keeps dropping off, without changing the alert rules. Events are
+still recorded, and a silence ends by itself when its time is up. Silences are
+kept once they end, with who added and removed them.
+
+For times that come round again, such as the lab network at weekends, use a
+[maintenance] section in the config file instead.`,
+}
+
+var silenceAddCmd = &cobra.Command{
+	Use:   "add [device...]",
+	Short: "Silence alerts about devices, groups or networks",
+	Long: `Silence alerts about the devices given, by address, MAC, label or hostname,
+the devices in the --group given, and the --network given, for the time
+--for says.
+
+  orangutan silence add "hall bulb" --for 7d --reason "flapping until replaced"
+  orangutan silence add --network 10.0.20.0/24 --for 2h --reason "rewiring the lab"
+  orangutan silence add --all --for 30m`,
+	RunE: runSilenceAdd,
+}
+
+var silenceListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the silences in force",
+	Args:  cobra.NoArgs,
+	RunE:  runSilenceList,
+}
+
+var silenceRemoveCmd = &cobra.Command{
+	Use:   "remove <id>",
+	Short: "End a silence early",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSilenceRemove,
+}
+
+func init() {
+	silenceCmd.AddCommand(silenceAddCmd)
+	silenceCmd.AddCommand(silenceListCmd)
+	silenceCmd.AddCommand(silenceRemoveCmd)
+
+	silenceAddCmd.Flags().StringVar(&silenceFor, "for", "", "How long, such as 2h or 7d (required)")
+	silenceAddCmd.Flags().StringVar(&silenceReason, "reason", "", "Why, for whoever wonders where the alerts went")
+	silenceAddCmd.Flags().StringArrayVar(&silenceGroups, "group", nil, "Silence the devices in this group (repeatable)")
+	silenceAddCmd.Flags().StringArrayVar(&silenceNetworks, "network", nil, "Silence this network, in CIDR notation (repeatable)")
+	silenceAddCmd.Flags().BoolVar(&silenceAll, "all", false, "Silence every alert")
+	silenceListCmd.Flags().BoolVar(&silenceListAll, "all", false, "Include silences that have ended")
+	for _, c := range []*cobra.Command{silenceAddCmd, silenceRemoveCmd} {
+		c.Flags().StringVar(&silenceBy, "by", currentUser(), "Who is asking, for the record")
+	}
+}
+
+// currentUser returns the name of the user running the command, or "" if it
+// cannot be told.
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+func runSilenceAdd(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 && len(silenceGroups) == 0 && len(silenceNetworks) == 0 && !silenceAll {
+		return fmt.Errorf("give devices, --group or --network, or --all to silence everything")
+	}
+	duration, err := query.ParseAge(silenceFor)
+	if err != nil || duration <= 0 {
+		return fmt.Errorf("--for is required, such as 2h or 7d")
+	}
+
+	var silence types.Silence
+	c, err := remoteClient()
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		ctx, cancel := remoteContext()
+		defer cancel()
+		silence, err = c.AddSilence(ctx, client.SilenceRequest{
+			Devices:  args,
+			Groups:   silenceGroups,
+			Networks: silenceNetworks,
+			All:      silenceAll,
+			Duration: silenceFor,
+			Reason:   silenceReason,
+			By:       silenceBy,
+		})
+	} else {
+		store, err := openStore(cmd)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		silence, err = store.AddSilence(types.Silence{
+			Devices:   args,
+			Groups:    silenceGroups,
+			Networks:  silenceNetworks,
+			Reason:    silenceReason,
+			CreatedBy: silenceBy,
+			Created:   now,
+			Expires:   now.Add(duration),
+		})
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Silenced %s until %s (silence %d)\n", silenceCovers(silence), silence.Expires.Local().Format("2006-01-02 15:04"), silence.ID)
+	return nil
+}
+
+func runSilenceList(cmd *cobra.Command, args []string) error {
+	var silences []types.Silence
+	c, err := remoteClient()
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		ctx, cancel := remoteContext()
+		defer cancel()
+		if silences, err = c.Silences(ctx, silenceListAll); err != nil {
+			return err
+		}
+	} else {
+		store, err := openStore(cmd)
+		if err != nil {
+			return err
+		}
+		silences = store.GetSilences(silenceListAll)
+	}
+
+	if len(silences) == 0 {
+		fmt.Println("No silences")
+		return nil
+	}
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCOVERS\tUNTIL\tBY\tREASON")
+	for _, s := range silences {
+		until := s.Expires.Local().Format("2006-01-02 15:04")
+		switch {
+		case !s.Removed.IsZero():
+			until = fmt.Sprintf("removed %s by %s", s.Removed.Local().Format("2006-01-02 15:04"), s.RemovedBy)
+		case !s.Active(now):
+			until += " (expired)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", s.ID, silenceCovers(s), until, s.CreatedBy, s.Reason)
+	}
+	return w.Flush()
+}
+
+func runSilenceRemove(cmd *cobra.Command, args []string) error {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("%q is not a silence ID; 'orangutan silence list' shows them", args[0])
+	}
+
+	c, err := remoteClient()
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		ctx, cancel := remoteContext()
+		defer cancel()
+		if err := c.RemoveSilence(ctx, id, silenceBy); err != nil {
+			return err
+		}
+	} else {
+		store, err := openStore(cmd)
+		if err != nil {
+			return err
+		}
+		ok, err := store.RemoveSilence(id, silenceBy)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("there is no silence %d in force", id)
+		}
+	}
+	fmt.Printf("Removed silence %d\n", id)
+	return nil
+}
+
+// silenceCovers describes what s covers, as in "hall bulb, group Lab".
+func silenceCovers(s types.Silence) string {
+	var parts []string
+	parts = append(parts, s.Devices...)
+	for _, g := range s.Groups {
+		parts = append(parts, "group "+g)
+	}
+	for _, n := range s.Networks {
+		parts = append(parts, "network "+n)
+	}
+	if len(parts) == 0 {
+		return "everything"
+	}
+	return strings.Join(parts, ", ")
+}
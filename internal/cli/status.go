@@ -9,6 +9,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/291-Group/LAN-Orangutan/internal/config"
 	"github.com/291-Group/LAN-Orangutan/internal/network"
 	"github.com/291-Group/LAN-Orangutan/internal/storage"
 )
@@ -28,12 +29,27 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	fmt.Printf("  Go Version: %s\n", runtime.Version())
 	fmt.Printf("  OS/Arch: %s/%s\n", runtime.GOOS, runtime.GOARCH)
 
+	// Paths, which depend on the user running this unless set explicitly
+	fmt.Println()
+	fmt.Println("Paths:")
+	if fileExists(cfgFile) {
+		fmt.Printf("  Config file: %s\n", cfgFile)
+	} else {
+		fmt.Printf("  Config file: %s (not found, using defaults)\n", cfgFile)
+	}
+	for _, f := range config.DropIns(cfgFile) {
+		fmt.Printf("  Drop-in: %s\n", f)
+	}
+	if cfg.Profile() != "" {
+		fmt.Printf("  Profile: %s\n", cfg.Profile())
+	}
+	fmt.Printf("  Data directory: %s\n", cfg.Storage.DataDir)
+
 	// Check tools
 	fmt.Println()
 	fmt.Println("Tools:")
 	checkTool("nmap")
 	checkTool("arp-scan")
-	checkTool("tailscale")
 
 	// Storage
 	fmt.Println()
@@ -44,7 +60,9 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	} else {
 		stats := store.GetStats()
 		fmt.Printf("  Devices: %d total (%d online, %d offline)\n", stats.Total, stats.Online, stats.Offline)
-		fmt.Printf("  Data directory: %s\n", cfg.Storage.DataDir)
+		if stats.Pending > 0 {
+			fmt.Printf("  Waiting for approval: %d (see 'orangutan approve')\n", stats.Pending)
+		}
 	}
 
 	// Networks
@@ -81,6 +99,9 @@ func runStatus(cmd *cobra.Command, args []string) error {
 		fmt.Println("  Installed but not running")
 	} else {
 		fmt.Printf("  Status: %s\n", ts.StatusLabel())
+		if ts.Version != "" {
+			fmt.Printf("  Version: %s\n", ts.Version)
+		}
 		// The IP and peer count are cached from the last session and go stale
 		// once Tailscale stops, so only report them while connected.
 		if ts.Connected {
@@ -122,8 +143,6 @@ func getToolVersion(name string) string {
 		cmd = exec.Command("nmap", "--version")
 	case "arp-scan":
 		cmd = exec.Command("arp-scan", "--version")
-	case "tailscale":
-		cmd = exec.Command("tailscale", "--version")
 	default:
 		return ""
 	}
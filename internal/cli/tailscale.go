@@ -0,0 +1,217 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/network"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var (
+	tailscalePeersOnline  bool
+	tailscaleImportOnline bool
+	tailscaleImportGroup  string
+	tailscaleImportDryRun bool
+)
+
+// tailscaleTag is added to every device imported from the tailnet, so they
+// can be found and filtered together whatever group they end up in.
+const tailscaleTag = "tailscale"
+
+var tailscaleCmd = &cobra.Command{
+	Use:   "tailscale",
+	Short: "Show the tailnet and import its peers",
+	Long: `Work with the Tailscale network this machine is on. Tailscale peers cannot
+be found by scanning, since each sits on its own /32, so they are read from
+Tailscale itself.`,
+}
+
+var tailscaleStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the Tailscale connection",
+	Args:  cobra.NoArgs,
+	RunE:  runTailscaleStatus,
+}
+
+var tailscalePeersCmd = &cobra.Command{
+	Use:   "peers",
+	Short: "List the nodes on the tailnet",
+	Long: `List every node on the tailnet, this machine first, with whether it is online
+and the label it has in the inventory.`,
+	Args: cobra.NoArgs,
+	RunE: runTailscalePeers,
+}
+
+var tailscaleImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Add the tailnet's nodes to the inventory",
+	Long: `Add each node on the tailnet to the inventory, labelled with its Tailscale
+name and tagged "tailscale". A label already set on a device is kept.
+
+Nodes that are offline are imported with the time Tailscale last saw them,
+so they do not show as online; use --online to leave them out.`,
+	Args: cobra.NoArgs,
+	RunE: runTailscaleImport,
+}
+
+func init() {
+	tailscalePeersCmd.Flags().BoolVar(&tailscalePeersOnline, "online", false, "Show only online nodes")
+
+	tailscaleImportCmd.Flags().BoolVar(&tailscaleImportOnline, "online", false, "Import only online nodes")
+	tailscaleImportCmd.Flags().StringVar(&tailscaleImportGroup, "group", "", "Put the imported devices in this group")
+	_ = tailscaleImportCmd.RegisterFlagCompletionFunc("group", completeGroups)
+	tailscaleImportCmd.Flags().BoolVar(&tailscaleImportDryRun, "dry-run", false, "Show what would change without saving")
+
+	tailscaleCmd.AddCommand(tailscaleStatusCmd)
+	tailscaleCmd.AddCommand(tailscalePeersCmd)
+	tailscaleCmd.AddCommand(tailscaleImportCmd)
+}
+
+func runTailscaleStatus(cmd *cobra.Command, args []string) error {
+	ts := network.GetTailscaleStatus()
+	fmt.Printf("Status:    %s\n", ts.StatusLabel())
+	if !ts.Running {
+		if ts.Error != "" {
+			fmt.Printf("Error:     %s\n", ts.Error)
+		}
+		return nil
+	}
+
+	fmt.Printf("Version:   %s\n", dash(ts.Version))
+	// As in the status command, the rest is cached from the last session and
+	// goes stale once Tailscale stops.
+	if !ts.Connected {
+		return nil
+	}
+	fmt.Printf("Tailnet:   %s\n", dash(ts.TailnetName))
+	fmt.Printf("Hostname:  %s\n", dash(ts.SelfHostname))
+	fmt.Printf("IP:        %s\n", dash(ts.SelfIP))
+	fmt.Printf("Peers:     %d\n", ts.PeerCount)
+	if ts.ExitNode != "" {
+		fmt.Printf("Exit node: %s\n", ts.ExitNode)
+	}
+	return nil
+}
+
+// tailnetPeers returns the nodes on the tailnet, or an error saying why
+// there are none to show.
+func tailnetPeers() ([]types.TailscalePeer, error) {
+	ts := network.GetTailscaleStatus()
+	if !ts.Connected {
+		if ts.Error != "" {
+			return nil, fmt.Errorf("tailscale is not connected: %s", ts.Error)
+		}
+		return nil, fmt.Errorf("tailscale is not connected (%s)", ts.StatusLabel())
+	}
+	return network.GetTailscalePeers(), nil
+}
+
+func runTailscalePeers(cmd *cobra.Command, args []string) error {
+	peers, err := tailnetPeers()
+	if err != nil {
+		return err
+	}
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tIP\tOS\tSTATUS\tLABEL")
+	fmt.Fprintln(w, "----\t--\t--\t------\t-----")
+	shown := 0
+	for _, p := range peers {
+		if tailscalePeersOnline && !p.Online {
+			continue
+		}
+		name := p.Name
+		if p.Self {
+			name += " (this machine)"
+		}
+		status := "online"
+		if !p.Online {
+			status = "offline"
+			// Online peers have no last seen time: they are being seen now.
+			if !p.LastSeen.IsZero() {
+				status = fmt.Sprintf("offline %s ago", formatDuration(now.Sub(p.LastSeen)))
+			}
+		}
+		label := ""
+		if d := store.GetDevice(p.IP); d != nil && p.IP != "" {
+			label = d.Label
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, dash(p.IP), dash(p.OS), status, dash(label))
+		shown++
+	}
+	if shown == 0 {
+		fmt.Println("No peers found")
+		return nil
+	}
+	return w.Flush()
+}
+
+func runTailscaleImport(cmd *cobra.Command, args []string) error {
+	peers, err := tailnetPeers()
+	if err != nil {
+		return err
+	}
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var devices []types.Device
+	for _, p := range peers {
+		if p.IP == "" || (tailscaleImportOnline && !p.Online) {
+			continue
+		}
+		seen := now
+		if !p.Online {
+			// A node Tailscale has never seen connected has nothing to go on;
+			// leaving the times unset keeps it from counting as online.
+			seen = p.LastSeen
+		}
+		d := types.Device{
+			IP:        p.IP,
+			Hostname:  p.Name,
+			Vendor:    p.OS,
+			Group:     tailscaleImportGroup,
+			Tags:      []string{tailscaleTag},
+			FirstSeen: seen,
+			LastSeen:  seen,
+		}
+		// An import replaces labels, and one chosen by hand says more than
+		// the Tailscale name does.
+		if stored := store.GetDevice(p.IP); stored == nil || stored.Label == "" {
+			d.Label = p.Name
+		}
+		devices = append(devices, d)
+	}
+	if len(devices) == 0 {
+		fmt.Println("No peers to import")
+		return nil
+	}
+
+	result, err := store.ImportDevices(devices, tailscaleImportDryRun)
+	if err != nil {
+		return fmt.Errorf("failed to save devices: %w", err)
+	}
+
+	verb := "Imported"
+	if tailscaleImportDryRun {
+		verb = "Would import"
+	}
+	fmt.Printf("%s %d peers: %d new, %d updated, %d unchanged\n",
+		verb, len(devices), result.Created, result.Updated, result.Unchanged)
+	if tailscaleImportDryRun {
+		fmt.Println("Dry run, nothing saved")
+	}
+	return nil
+}
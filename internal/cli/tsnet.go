@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/291-Group/LAN-Orangutan/internal/tailnet"
+)
+
+// startTailnet serves server on the tailnet as well, as a node of its own,
+// when [tailscale] serve is on. Joining can wait for someone to follow a
+// login URL, so it happens in the background rather than holding up the
+// LAN listener, and a failure is logged rather than stopping the server.
+func startTailnet(ctx context.Context, server *http.Server) {
+	if !cfg.Tailscale.Serve {
+		return
+	}
+	go func() {
+		node, err := tailnet.Listen(ctx, tailnet.Options{
+			Hostname: cfg.Tailscale.Hostname,
+			AuthKey:  cfg.Tailscale.AuthKey,
+			Dir:      filepath.Join(cfg.Storage.DataDir, "tsnet"),
+			HTTPS:    cfg.Tailscale.HTTPS,
+			Logf: func(format string, args ...any) {
+				fmt.Printf("Tailnet:        %s\n", strings.TrimSpace(fmt.Sprintf(format, args...)))
+			},
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("not serving on the tailnet", "error", err)
+			}
+			return
+		}
+		defer node.Close()
+		fmt.Printf("Tailnet:        %s\n", node.URL)
+		// Shutdown closes this listener along with the LAN one.
+		if err := server.Serve(node.Listener); !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("stopped serving on the tailnet", "error", err)
+		}
+	}()
+}
@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/query"
+	"github.com/291-Group/LAN-Orangutan/internal/uptime"
+)
+
+var (
+	uptimeWindow string
+	uptimeFormat string
+	uptimeBelow  float64
+)
+
+var uptimeCmd = &cobra.Command{
+	Use:   "uptime [query...]",
+	Short: "Show how much of the time devices were online",
+	Long: `Print how much of the last week, or of the --window given, each device was
+online, least available first, worked out from when scans found it. A device
+first seen within the window is measured from then. Time when no scan ran
+counts as offline, so scan often for figures that mean something.
+
+Give a search, as for 'orangutan search', to measure only the devices it
+finds.
+
+  orangutan uptime
+  orangutan uptime --window 30d printer
+  orangutan uptime --window 24h --below 95 group:Servers
+  orangutan uptime --format json`,
+	RunE: runUptime,
+}
+
+func init() {
+	uptimeCmd.Flags().StringVar(&uptimeWindow, "window", "7d", "How far back to look, such as 24h, 7d or 30d (at most 30d)")
+	uptimeCmd.Flags().StringVar(&uptimeFormat, "format", "table", "Output format (table, json)")
+	uptimeCmd.Flags().Float64Var(&uptimeBelow, "below", 0, "Only show devices online less than this percentage of the time")
+}
+
+func runUptime(cmd *cobra.Command, args []string) error {
+	window, err := uptime.ParseWindow(uptimeWindow)
+	if err != nil {
+		return err
+	}
+	if uptimeFormat != "table" && uptimeFormat != "json" {
+		return fmt.Errorf("unknown format %q (use table or json)", uptimeFormat)
+	}
+	q := strings.Join(args, " ")
+	parsed, err := query.Parse(q)
+	if err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+
+	var result []uptime.Availability
+	c, err := remoteClient()
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		ctx, cancel := remoteContext()
+		defer cancel()
+		if result, err = c.Uptime(ctx, uptimeWindow, q); err != nil {
+			return err
+		}
+	} else {
+		store, err := openStore(cmd)
+		if err != nil {
+			return err
+		}
+		result = uptime.ComputeAll(parsed.Filter(store.GetDevices()), store, window, time.Now())
+	}
+
+	if uptimeBelow > 0 {
+		kept := result[:0]
+		for _, a := range result {
+			if a.Percent < uptimeBelow {
+				kept = append(kept, a)
+			}
+		}
+		result = kept
+	}
+
+	if uptimeFormat == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+	if len(result) == 0 {
+		fmt.Println("No devices found")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IP\tNAME\tONLINE\tOF\tAVAILABILITY\tOUTAGES")
+	for _, a := range result {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.1f%%\t%d\n", a.IP, a.Name,
+			formatDuration(time.Duration(a.Online*float64(time.Second))),
+			formatDuration(time.Duration(a.Tracked*float64(time.Second))),
+			a.Percent, a.Outages)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,316 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/cve"
+	"github.com/291-Group/LAN-Orangutan/internal/scanner"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var (
+	vulnscanScripts []string
+	vulnscanPorts   string
+	vulnscanTimeout time.Duration
+
+	findingsSeverity string
+	findingsFormat   string
+)
+
+var vulnscanCmd = &cobra.Command{
+	Use:   "vulnscan <ip|mac|label>...",
+	Short: "Look for known vulnerabilities and default logins on devices",
+	Long: `Run nmap's NSE scripts against the devices given, by IP address, MAC
+address, label or hostname. Their services are found and identified, and
+what the scripts find wrong with them is kept as each device's findings, in
+place of those of its last deep scan.
+
+The scripts are those [vulnscan] gives, vulners and http-default-accounts
+unless it says otherwise: vulners looks the versions of the services up on
+vulners.com for known CVEs, and http-default-accounts tries the logins web
+interfaces ship with. --scripts runs others for this run, by name or by
+category, such as vuln or auth. With [cve] enabled, the services found are
+also looked up in NVD's database of known vulnerabilities.
+
+A deep scan sends far more than a sweep, and the scripts may try logins, so
+only run it against devices you look after. It is never run by itself.
+
+  orangutan vulnscan nas router
+  orangutan vulnscan camera --scripts vulners,vuln --ports 80,443,554
+  orangutan findings                 # What the deep scans found
+  orangutan vulnerable               # Which devices have known holes`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runVulnscan,
+}
+
+var findingsCmd = &cobra.Command{
+	Use:   "findings [ip|mac|label...]",
+	Short: "List what deep scans found wrong with devices",
+	Long: `List the findings of the latest deep scan of each device, or of the
+devices given, most severe first. 'orangutan vulnscan' runs deep scans.
+
+  orangutan findings
+  orangutan findings nas
+  orangutan findings --severity high --format json`,
+	RunE: runFindings,
+}
+
+func init() {
+	vulnscanCmd.Flags().StringSliceVar(&vulnscanScripts, "scripts", nil, "NSE scripts or categories to run (default scripts from [vulnscan])")
+	vulnscanCmd.Flags().StringVar(&vulnscanPorts, "ports", "", "Ports to look at, such as 22,80,8000-8100 (default ports from [vulnscan], or nmap's most common 1000)")
+	vulnscanCmd.Flags().DurationVar(&vulnscanTimeout, "timeout", 0, "How long the deep scan may take (default timeout from [vulnscan])")
+	findingsCmd.Flags().StringVar(&findingsSeverity, "severity", "", "Only list findings at least this severe: "+strings.Join(types.Severities, ", "))
+	findingsCmd.Flags().StringVar(&findingsFormat, "format", "table", "Output format (table, json)")
+}
+
+func runVulnscan(cmd *cobra.Command, args []string) error {
+	devices := make(map[string]*types.Device)
+	var ips []string
+	for _, arg := range args {
+		ip, d, err := resolveTarget(cmd, arg)
+		if err != nil {
+			return err
+		}
+		if d == nil {
+			return fmt.Errorf("device not found: %s", arg)
+		}
+		if devices[ip] == nil {
+			ips = append(ips, ip)
+		}
+		devices[ip] = d
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	c, err := remoteClient()
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		if len(vulnscanScripts) > 0 || vulnscanPorts != "" || vulnscanTimeout != 0 {
+			return fmt.Errorf("a server runs deep scans as its [vulnscan] says; --scripts, --ports and --timeout only work without --server")
+		}
+		status, err := c.StartDeepScan(ctx, ips)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Deep scan of %d device(s) started on the server; this can take several minutes\n", len(ips))
+		for status.Running {
+			select {
+			case <-ctx.Done():
+				fmt.Println("Stopped waiting; the deep scan goes on, and 'orangutan findings' shows what it finds")
+				return nil
+			case <-time.After(5 * time.Second):
+			}
+			if status, err = c.DeepScanStatus(ctx); err != nil {
+				return err
+			}
+		}
+		if status.Error != "" {
+			return fmt.Errorf("deep scan failed: %s", status.Error)
+		}
+		scans, err := c.Findings(ctx)
+		if err != nil {
+			return err
+		}
+		for _, ip := range ips {
+			if scan, ok := scans[ip]; ok {
+				printDeepScan(devices[ip], scan)
+			}
+		}
+		return nil
+	}
+
+	opts := scanner.DeepScanOptions{Scripts: cfg.VulnScan.Scripts, Ports: cfg.VulnScan.Ports}
+	if len(vulnscanScripts) > 0 {
+		opts.Scripts = vulnscanScripts
+	}
+	if vulnscanPorts != "" {
+		opts.Ports = vulnscanPorts
+	}
+	timeout := cfg.VulnScan.TimeoutDuration()
+	if vulnscanTimeout > 0 {
+		timeout = vulnscanTimeout
+	}
+	scanCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+	s := scanner.New(0)
+	privilege, _ := scanner.ChoosePrivilege(cfg.Scanning.Privileged)
+	s.SetPrivilege(privilege)
+	fmt.Printf("Deep scanning %d device(s); this can take several minutes\n", len(ips))
+	scans, err := s.DeepScan(scanCtx, ips, opts)
+	if err != nil {
+		return err
+	}
+	for _, scan := range scans {
+		if err := store.SetDeepScan(scan); err != nil {
+			return err
+		}
+	}
+	if cfg.CVE.Enable && len(scans) > 0 {
+		fmt.Println("Looking the services up in NVD")
+		if err := cve.New(cfg.CVEOptions()).Update(ctx, store, ips, false); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: CVE lookup failed: %v\n", err)
+		}
+	}
+	for _, scan := range scans {
+		printDeepScan(devices[scan.IP], *store.GetDeepScan(scan.IP))
+	}
+	return nil
+}
+
+// printDeepScan writes what a deep scan of d found.
+func printDeepScan(d *types.Device, scan types.DeepScan) {
+	fmt.Printf("\n%s (%s), %d open port(s)\n", deviceDisplayName(d), d.IP, len(scan.Services))
+	for _, s := range scan.Services {
+		fmt.Printf("  %s\n", s)
+	}
+	if len(scan.Findings) == 0 {
+		fmt.Println("  No findings")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, f := range scan.Findings {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", strings.ToUpper(f.Severity), f.ID, findingText(f))
+	}
+	w.Flush()
+}
+
+// findingText describes f on one line, with its score and whether it has a
+// known exploit.
+func findingText(f types.Finding) string {
+	var notes []string
+	if f.CVSS > 0 {
+		notes = append(notes, fmt.Sprintf("CVSS %.1f", f.CVSS))
+	}
+	if f.Exploit {
+		notes = append(notes, "exploit known")
+	}
+	if len(notes) == 0 {
+		return f.Title
+	}
+	return fmt.Sprintf("%s (%s)", f.Title, strings.Join(notes, ", "))
+}
+
+// findingRow is a finding as 'orangutan findings --format json' lists it,
+// with the device it is on.
+type findingRow struct {
+	IP      string    `json:"ip"`
+	Name    string    `json:"name"`
+	Scanned time.Time `json:"scanned"`
+	types.Finding
+}
+
+func runFindings(cmd *cobra.Command, args []string) error {
+	if findingsFormat != "table" && findingsFormat != "json" {
+		return fmt.Errorf("unknown format %q (use table or json)", findingsFormat)
+	}
+	minRank := 0
+	if findingsSeverity != "" {
+		if minRank = types.SeverityRank(findingsSeverity); minRank < 0 {
+			return fmt.Errorf("unknown severity %q (use %s)", findingsSeverity, strings.Join(types.Severities, ", "))
+		}
+	}
+
+	devices, err := loadDevices(cmd)
+	if err != nil {
+		return err
+	}
+	wanted := make(map[string]bool)
+	for _, arg := range args {
+		ip, d, err := resolveTarget(cmd, arg)
+		if err != nil {
+			return err
+		}
+		if d == nil {
+			return fmt.Errorf("device not found: %s", arg)
+		}
+		wanted[ip] = true
+	}
+
+	var scans map[string]types.DeepScan
+	c, err := remoteClient()
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		ctx, cancel := remoteContext()
+		defer cancel()
+		if scans, err = c.Findings(ctx); err != nil {
+			return err
+		}
+	} else {
+		store, err := openStore(cmd)
+		if err != nil {
+			return err
+		}
+		scans = store.GetDeepScans()
+	}
+
+	rows := make([]findingRow, 0)
+	for ip, scan := range scans {
+		if len(wanted) > 0 && !wanted[ip] {
+			continue
+		}
+		name := ip
+		if d := devices[ip]; d != nil {
+			name = deviceDisplayName(d)
+		}
+		for _, f := range scan.Findings {
+			if types.SeverityRank(f.Severity) >= minRank {
+				rows = append(rows, findingRow{IP: ip, Name: name, Scanned: scan.Time, Finding: f})
+			}
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if ra, rb := types.SeverityRank(a.Severity), types.SeverityRank(b.Severity); ra != rb {
+			return ra > rb
+		}
+		if a.CVSS != b.CVSS {
+			return a.CVSS > b.CVSS
+		}
+		return a.IP < b.IP
+	})
+
+	if findingsFormat == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	}
+	if len(rows) == 0 {
+		if len(scans) == 0 {
+			fmt.Println("No deep scans yet; 'orangutan vulnscan' runs one")
+		} else {
+			fmt.Println("No findings")
+		}
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SEVERITY\tIP\tNAME\tPORT\tID\tFINDING")
+	for _, r := range rows {
+		port := "-"
+		if r.Port != 0 {
+			port = fmt.Sprintf("%d/%s", r.Port, r.Protocol)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", strings.ToUpper(r.Severity), r.IP, truncate(r.Name, 20), port, r.ID, findingText(r.Finding))
+	}
+	return w.Flush()
+}
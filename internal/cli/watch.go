@@ -0,0 +1,418 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/291-Group/LAN-Orangutan/internal/scanner"
+	"github.com/291-Group/LAN-Orangutan/internal/schedule"
+	"github.com/291-Group/LAN-Orangutan/internal/storage"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var watchInterval int
+
+var watchCmd = &cobra.Command{
+	Use:   "watch [network|all]",
+	Short: "Scan continuously and show devices live",
+	Long: `Scan a network over and over at the configured interval and keep a table of
+its devices up to date in the terminal. Devices that appear, change or drop
+off since the previous scan are highlighted. Press Ctrl+C to stop.
+
+The network argument works as it does for scan. Each network is scanned on
+the schedule, or at the scan_interval, of its [network "..."] section if it
+has one, unless --interval sets one interval for all of them.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runWatch,
+}
+
+func init() {
+	watchCmd.Flags().IntVar(&watchInterval, "interval", 0, "Seconds between scans of every network (default: each network's schedule or scan_interval)")
+}
+
+// Changes a device can be highlighted with after a round of scanning.
+const (
+	watchNew     = "new"
+	watchChanged = "changed"
+	watchOffline = "offline"
+	watchBack    = "back"
+)
+
+// watchColors are the ANSI colours for each change: green for arrivals,
+// yellow for changes and red for departures.
+var watchColors = map[string]string{
+	watchNew:     "\033[32m",
+	watchBack:    "\033[32m",
+	watchChanged: "\033[33m",
+	watchOffline: "\033[31m",
+}
+
+// watchRow is one line of the watch table.
+type watchRow struct {
+	device types.Device
+	online bool
+	change string
+}
+
+// watchState is what the watch command remembers between rounds.
+type watchState struct {
+	// found holds what the latest successful scan of each network found.
+	// A network that is skipped or fails keeps its previous result, so its
+	// devices are not all reported offline because of one bad round.
+	found map[string][]types.Device
+
+	// online and offline are the devices shown in the table, by IP.
+	online  map[string]types.Device
+	offline map[string]types.Device
+
+	// every is how often to scan each network, unless cron holds a
+	// schedule for it, and next is when each is due.
+	every map[string]time.Duration
+	cron  map[string]*schedule.Schedule
+	next  map[string]time.Time
+
+	rounds int
+}
+
+// newWatchState returns the state for watching networks, scanning each of
+// them every flag seconds, or on its configured schedule or interval when
+// flag is 0.
+func newWatchState(networks []string, flag int) (*watchState, error) {
+	state := &watchState{
+		found:   make(map[string][]types.Device),
+		online:  make(map[string]types.Device),
+		offline: make(map[string]types.Device),
+		every:   make(map[string]time.Duration),
+		cron:    make(map[string]*schedule.Schedule),
+		next:    make(map[string]time.Time),
+	}
+	for _, cidr := range networks {
+		n := cfg.ForNetwork(cidr)
+		if n.Schedule != "" && flag == 0 {
+			sched, err := schedule.Parse(n.Schedule)
+			if err != nil {
+				return nil, fmt.Errorf("schedule of %s: %w", cidr, err)
+			}
+			if sched.Next(time.Now()).IsZero() {
+				return nil, fmt.Errorf("schedule of %s never comes round", cidr)
+			}
+			state.cron[cidr] = sched
+			continue
+		}
+
+		interval := n.ScanInterval
+		if flag > 0 {
+			interval = flag
+		}
+		// Scanning more often than the rate limit allows would only skip rounds.
+		if interval < cfg.Scanning.MinScanInterval {
+			interval = cfg.Scanning.MinScanInterval
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("scan interval of %s must be positive", cidr)
+		}
+		state.every[cidr] = time.Duration(interval) * time.Second
+	}
+	return state, nil
+}
+
+// after returns when the network cidr is next due, given that its scan
+// started at start.
+func (st *watchState) after(cidr string, start time.Time) time.Time {
+	if sched := st.cron[cidr]; sched != nil {
+		return sched.Next(start)
+	}
+	return start.Add(st.every[cidr])
+}
+
+// nextScan returns when the next network is due.
+func (st *watchState) nextScan() time.Time {
+	var first time.Time
+	for _, next := range st.next {
+		if first.IsZero() || next.Before(first) {
+			first = next
+		}
+	}
+	return first
+}
+
+// schedule describes how often networks are scanned, as in "10.0.0.0/24
+// every 5m0s".
+func (st *watchState) schedule(networks []string) string {
+	same := len(st.cron) == 0
+	for _, cidr := range networks {
+		same = same && st.every[cidr] == st.every[networks[0]]
+	}
+	if same {
+		return fmt.Sprintf("%s every %s", strings.Join(networks, ", "), st.every[networks[0]])
+	}
+	parts := make([]string, len(networks))
+	for i, cidr := range networks {
+		if st.cron[cidr] != nil {
+			parts[i] = fmt.Sprintf("%s on schedule %q", cidr, cfg.ForNetwork(cidr).Schedule)
+		} else {
+			parts[i] = fmt.Sprintf("%s every %s", cidr, st.every[cidr])
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func runWatch(cmd *cobra.Command, args []string) error {
+	store, err := openStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	networks, err := resolveNetworks(args)
+	if err != nil {
+		return err
+	}
+
+	state, err := newWatchState(networks, watchInterval)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s := cfg.NewScanner()
+	warnUnprivileged(s)
+	term := isTerminal(os.Stdout)
+
+	for {
+		if term {
+			fmt.Printf("Scanning %s...\n", strings.Join(networks, ", "))
+		}
+		errs := watchRound(ctx, store, s, networks, state)
+		if ctx.Err() != nil {
+			return nil
+		}
+		rows := state.update()
+
+		var buf bytes.Buffer
+		if term {
+			// Home the cursor and clear the screen so each round redraws in place.
+			buf.WriteString("\033[H\033[2J")
+		}
+		renderWatch(&buf, store, state.schedule(networks), state.nextScan(), rows, errs, term && useColor())
+		if _, err := os.Stdout.Write(buf.Bytes()); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Until(state.nextScan())):
+		}
+	}
+}
+
+// watchRound scans each network that is due once, saving what it finds and
+// recording it in state. It returns a message for each network that could
+// not be scanned.
+func watchRound(ctx context.Context, store *storage.Storage, s *scanner.Scanner, networks []string, state *watchState) []string {
+	var errs []string
+	for _, cidr := range networks {
+		start := time.Now()
+		if start.Before(state.next[cidr]) {
+			continue
+		}
+
+		// Another scan, from the web UI or a second terminal, may have just
+		// run; its devices are already stored, and this round keeps the
+		// previous result for the network.
+		if canScan, wait := s.CheckRateLimit(store.GetLastScan(cidr)); !canScan {
+			state.next[cidr] = start.Add(wait)
+			continue
+		}
+
+		scanCtx, cancel := context.WithTimeout(ctx, cfg.Scanning.Timeout())
+		result, err := s.ScanWith(scanCtx, cidr, cfg.ForNetwork(cidr).ScanOptions())
+		cancel()
+		if ctx.Err() != nil {
+			return errs
+		}
+		// Timed from the start, so a long scan does not push every later
+		// one back.
+		state.next[cidr] = state.after(cidr, start)
+
+		if err == nil && !result.Success {
+			err = fmt.Errorf("%s", result.Error)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("Error scanning %s: %v", cidr, err))
+			_ = store.RecordScanFailure(cidr, err.Error())
+			continue
+		}
+
+		if err := store.MergeScan(cidr, result.Devices); err != nil {
+			errs = append(errs, fmt.Sprintf("Error saving devices: %v", err))
+			continue
+		}
+		if err := store.SetLastScan(cidr, time.Now()); err != nil {
+			errs = append(errs, fmt.Sprintf("Error updating scan state: %v", err))
+		}
+		if err := exportScan(store, cidr, result); err != nil {
+			errs = append(errs, fmt.Sprintf("Error writing to InfluxDB: %v", err))
+		}
+		if err := sendZabbix(store, cidr, result); err != nil {
+			errs = append(errs, fmt.Sprintf("Error sending to Zabbix: %v", err))
+		}
+		if err := writeDnsmasq(store); err != nil {
+			errs = append(errs, fmt.Sprintf("Error writing the dnsmasq file: %v", err))
+		}
+		state.found[cidr] = result.Devices
+	}
+	return errs
+}
+
+// update compares the latest round with the one before it and returns the
+// rows to show: every device found now, then every device that has dropped
+// off since the watch began. Nothing is highlighted after the first round,
+// since everything would be new.
+func (st *watchState) update() []watchRow {
+	st.rounds++
+	first := st.rounds == 1
+
+	current := make(map[string]types.Device)
+	for _, devices := range st.found {
+		for _, d := range devices {
+			current[d.IP] = d
+		}
+	}
+
+	var rows []watchRow
+	for ip, d := range current {
+		row := watchRow{device: d, online: true}
+		if prev, ok := st.online[ip]; ok {
+			if (d.MAC != "" && prev.MAC != "" && d.MAC != prev.MAC) ||
+				(d.Hostname != "" && d.Hostname != prev.Hostname) {
+				row.change = watchChanged
+			}
+		} else if _, ok := st.offline[ip]; ok {
+			row.change = watchBack
+			delete(st.offline, ip)
+		} else if !first {
+			row.change = watchNew
+		}
+		rows = append(rows, row)
+	}
+
+	for ip, d := range st.online {
+		if _, ok := current[ip]; !ok {
+			st.offline[ip] = d
+			rows = append(rows, watchRow{device: d, change: watchOffline})
+		}
+	}
+	for ip, d := range st.offline {
+		if _, ok := st.online[ip]; !ok {
+			rows = append(rows, watchRow{device: d})
+		}
+	}
+	st.online = current
+
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].online != rows[j].online {
+			return rows[i].online
+		}
+		return types.LessIP(rows[i].device.IP, rows[j].device.IP)
+	})
+	return rows
+}
+
+// renderWatch writes the status lines and device table for one round.
+func renderWatch(buf *bytes.Buffer, store *storage.Storage, schedule string, next time.Time, rows []watchRow, errs []string, color bool) {
+	counts := make(map[string]int)
+	online := 0
+	for _, r := range rows {
+		counts[r.change]++
+		if r.online {
+			online++
+		}
+	}
+
+	now := time.Now()
+	fmt.Fprintf(buf, "Watching %s. Press Ctrl+C to stop.\n", schedule)
+	fmt.Fprintf(buf, "Scanned at %s: %d online, %d new, %d changed, %d offline. Next scan at %s.\n\n",
+		now.Format("15:04:05"), online, counts[watchNew]+counts[watchBack], counts[watchChanged], counts[watchOffline],
+		next.Format("15:04:05"))
+
+	if len(rows) == 0 {
+		buf.WriteString("No devices found\n")
+	} else {
+		// The table is laid out without colour first: tabwriter counts the
+		// bytes of escape sequences as width, which would skew the columns.
+		var table bytes.Buffer
+		w := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "IP\tMAC\tHOSTNAME\tVENDOR\tLABEL\tSTATUS\tCHANGE")
+		fmt.Fprintln(w, "--\t---\t--------\t------\t-----\t------\t------")
+		for _, r := range rows {
+			d := r.device
+			label := ""
+			if stored := store.GetDevice(d.IP); stored != nil {
+				label = stored.Label
+			}
+			status := "offline"
+			if r.online {
+				status = "online"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				d.IP, dash(d.MAC), dash(truncate(d.Hostname, 25)),
+				dash(truncate(scanner.ResolveVendor(d.Vendor, d.MAC), 20)),
+				truncate(label, 20), status, r.change)
+		}
+		_ = w.Flush()
+
+		lines := strings.SplitAfter(table.String(), "\n")
+		for i, line := range lines {
+			// The first two lines are the header.
+			code := ""
+			if color && i >= 2 && i-2 < len(rows) {
+				code = watchColors[rows[i-2].change]
+			}
+			if code == "" {
+				buf.WriteString(line)
+				continue
+			}
+			buf.WriteString(code + strings.TrimSuffix(line, "\n") + "\033[0m\n")
+		}
+	}
+
+	if len(errs) > 0 {
+		buf.WriteString("\n")
+		for _, e := range errs {
+			buf.WriteString(e + "\n")
+		}
+	}
+}
+
+// dash stands in for an empty table cell.
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// isTerminal reports whether f is an interactive terminal rather than a file
+// or pipe, where redrawing the screen would only litter the output.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// useColor honours the NO_COLOR convention (https://no-color.org).
+func useColor() bool {
+	return os.Getenv("NO_COLOR") == ""
+}
@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"context"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/storage"
+	"github.com/291-Group/LAN-Orangutan/internal/watch"
+)
+
+// startWatch pings the devices on the watchlist between scans, as
+// [watchlist] says, until ctx is done, so that one going quiet is alerted
+// about at once. It does nothing when the interval is 0.
+func startWatch(ctx context.Context, store *storage.Storage) {
+	if cfg.Watchlist.Interval <= 0 {
+		return
+	}
+	w := &watch.Watcher{
+		Interval: time.Duration(cfg.Watchlist.Interval) * time.Second,
+		Failures: cfg.Watchlist.Failures,
+	}
+	go w.Run(ctx, store)
+}
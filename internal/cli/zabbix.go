@@ -0,0 +1,19 @@
+package cli
+
+import (
+	"context"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/storage"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+	"github.com/291-Group/LAN-Orangutan/internal/zabbix"
+)
+
+// sendZabbix sends the scan of cidr, already merged into store, to the
+// Zabbix server in the [zabbix] section. It does nothing when none is set.
+func sendZabbix(store *storage.Storage, cidr string, result *types.ScanResult) error {
+	if cfg.Zabbix.Server == "" {
+		return nil
+	}
+	return zabbix.New(cfg.Zabbix.Options()).SendScan(context.Background(), cidr, result, store.GetDevices(), time.Now())
+}
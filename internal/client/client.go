@@ -0,0 +1,392 @@
+// Package client talks to a running LAN Orangutan server over its HTTP API.
+//
+// It backs the CLI's remote mode: once a server owns the data files, other
+// processes must go through it rather than write the files behind its back,
+// and a box elsewhere on the network can only be managed this way.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/config"
+	"github.com/291-Group/LAN-Orangutan/internal/cve"
+	"github.com/291-Group/LAN-Orangutan/internal/risk"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+	"github.com/291-Group/LAN-Orangutan/internal/uptime"
+)
+
+// Client calls the API of one server.
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// New creates a client for the server at address, which is a URL or a bare
+// host and port such as "nas.lan:291". token is the server's API token, or
+// empty for a server that needs no password.
+func New(address, token string) (*Client, error) {
+	address = strings.TrimSpace(address)
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid server address %q", address)
+	}
+
+	return &Client{
+		baseURL: strings.TrimSuffix(u.String(), "/"),
+		token:   strings.TrimSpace(token),
+		// Requests are bounded by their contexts instead: a scan can
+		// legitimately take minutes, a device list should not.
+		httpClient: &http.Client{},
+	}, nil
+}
+
+// ScanAllResult is the server's report on scanning every network.
+type ScanAllResult struct {
+	Success      bool                 `json:"success"`
+	Networks     []NetworkScanSummary `json:"networks"`
+	NetworkCount int                  `json:"network_count"`
+	ScannedCount int                  `json:"scanned_count"`
+	DeviceCount  int                  `json:"device_count"`
+}
+
+// NetworkScanSummary is the outcome of scanning one network as part of a
+// scan of all of them. Status is "scanned", "skipped" or "failed".
+type NetworkScanSummary struct {
+	Network     string  `json:"network"`
+	Status      string  `json:"status"`
+	DeviceCount int     `json:"device_count"`
+	Duration    float64 `json:"duration"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// Devices returns every stored device, keyed by IP address.
+func (c *Client) Devices(ctx context.Context) (map[string]*types.Device, error) {
+	var devices map[string]*types.Device
+	err := c.call(ctx, http.MethodGet, "devices", nil, nil, &devices)
+	return devices, err
+}
+
+// SearchDevices returns the devices matching q, a search in the language of
+// package query, keyed by IP address.
+func (c *Client) SearchDevices(ctx context.Context, q string) (map[string]*types.Device, error) {
+	var devices map[string]*types.Device
+	err := c.call(ctx, http.MethodGet, "devices", url.Values{"q": {q}}, nil, &devices)
+	return devices, err
+}
+
+// DevicesCSV returns the device list in the CSV format of `orangutan export`.
+func (c *Client) DevicesCSV(ctx context.Context) ([]byte, error) {
+	resp, err := c.send(ctx, http.MethodGet, "devices", url.Values{"format": {"csv"}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// Networks returns the networks the server can scan.
+func (c *Client) Networks(ctx context.Context) ([]types.Network, error) {
+	var networks []types.Network
+	err := c.call(ctx, http.MethodGet, "networks", nil, nil, &networks)
+	return networks, err
+}
+
+// Scan has the server scan one network and waits for the result. timeout is
+// how long the server may take, or zero for its configured scan_timeout.
+func (c *Client) Scan(ctx context.Context, cidr string, timeout time.Duration) (*types.ScanResult, error) {
+	var result types.ScanResult
+	if err := c.call(ctx, http.MethodGet, "scan", scanParams(cidr, timeout), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ScanAll has the server scan every network it knows of, giving each up to
+// timeout as Scan does.
+func (c *Client) ScanAll(ctx context.Context, timeout time.Duration) (*ScanAllResult, error) {
+	var result ScanAllResult
+	if err := c.call(ctx, http.MethodGet, "scan", scanParams("all", timeout), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// scanParams are the query parameters of a scan. A timeout of zero leaves
+// each network's time limit to the server's config.
+func scanParams(cidr string, timeout time.Duration) url.Values {
+	params := url.Values{"network": {cidr}}
+	if timeout > 0 {
+		params.Set("timeout", timeout.String())
+	}
+	return params
+}
+
+// Config returns every setting the server is running with and where each
+// came from.
+func (c *Client) Config(ctx context.Context) ([]config.Setting, error) {
+	var settings []config.Setting
+	err := c.call(ctx, http.MethodGet, "config", nil, nil, &settings)
+	return settings, err
+}
+
+// UpdateDevice changes the given details of the device at ip. A nil field is
+// left as it is.
+func (c *Client) UpdateDevice(ctx context.Context, ip string, label, notes, group, deviceType *string) error {
+	body := map[string]any{"ip": ip}
+	for name, v := range map[string]*string{"label": label, "notes": notes, "group": group, "type": deviceType} {
+		if v != nil {
+			body[name] = *v
+		}
+	}
+	return c.call(ctx, http.MethodPost, "device", nil, body, nil)
+}
+
+// Sightings returns when the device at ip was online over the last days
+// days, which the server allows up to 30.
+func (c *Client) Sightings(ctx context.Context, ip string, days int) ([]types.Sighting, error) {
+	var sightings []types.Sighting
+	params := url.Values{"ip": {ip}, "days": {strconv.Itoa(days)}}
+	err := c.call(ctx, http.MethodGet, "device/sightings", params, nil, &sightings)
+	return sightings, err
+}
+
+// Uptime returns how much of the window each device matching q was online,
+// least available first. An empty q means every device.
+func (c *Client) Uptime(ctx context.Context, window, q string) ([]uptime.Availability, error) {
+	var result []uptime.Availability
+	params := url.Values{"window": {window}}
+	if q != "" {
+		params.Set("q", q)
+	}
+	err := c.call(ctx, http.MethodGet, "uptime", params, nil, &result)
+	return result, err
+}
+
+// Presence returns whether each person the server follows is home.
+func (c *Client) Presence(ctx context.Context) ([]types.Presence, error) {
+	var result []types.Presence
+	err := c.call(ctx, http.MethodGet, "presence", nil, nil, &result)
+	return result, err
+}
+
+// SilenceRequest asks for a silence: what it covers, or All for everything,
+// for how long, such as 7d, and why. By says who asks, when the server does
+// not know from a sign in.
+type SilenceRequest struct {
+	Devices  []string `json:"devices,omitempty"`
+	Groups   []string `json:"groups,omitempty"`
+	Networks []string `json:"networks,omitempty"`
+	All      bool     `json:"all,omitempty"`
+	Duration string   `json:"duration"`
+	Reason   string   `json:"reason,omitempty"`
+	By       string   `json:"by,omitempty"`
+}
+
+// Silences returns the silences in force, or all of them when all is set,
+// newest first.
+func (c *Client) Silences(ctx context.Context, all bool) ([]types.Silence, error) {
+	var result []types.Silence
+	err := c.call(ctx, http.MethodGet, "silences", url.Values{"all": {strconv.FormatBool(all)}}, nil, &result)
+	return result, err
+}
+
+// AddSilence adds the silence req asks for and returns it.
+func (c *Client) AddSilence(ctx context.Context, req SilenceRequest) (types.Silence, error) {
+	var result types.Silence
+	err := c.call(ctx, http.MethodPost, "silences", nil, req, &result)
+	return result, err
+}
+
+// RemoveSilence ends the silence id early, as by asks.
+func (c *Client) RemoveSilence(ctx context.Context, id int64, by string) error {
+	params := url.Values{"id": {strconv.FormatInt(id, 10)}, "by": {by}}
+	return c.call(ctx, http.MethodDelete, "silences", params, nil, nil)
+}
+
+// Escalations returns the escalations still going, or all of them when all
+// is set, newest first.
+func (c *Client) Escalations(ctx context.Context, all bool) ([]types.Escalation, error) {
+	var result []types.Escalation
+	err := c.call(ctx, http.MethodGet, "escalations", url.Values{"all": {strconv.FormatBool(all)}}, nil, &result)
+	return result, err
+}
+
+// AcknowledgeEscalation stops the escalation id, as by asks.
+func (c *Client) AcknowledgeEscalation(ctx context.Context, id int64, by string) error {
+	body := map[string]any{"id": id, "by": by}
+	return c.call(ctx, http.MethodPost, "escalations/ack", nil, body, nil)
+}
+
+// DeepScanStatus is the state of the latest deep scan the server started.
+type DeepScanStatus struct {
+	Running  bool      `json:"running"`
+	IPs      []string  `json:"ips"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Error    string    `json:"error"`
+}
+
+// StartDeepScan has the server start a deep scan of the devices at ips with
+// the scripts of its config, and returns its state.
+func (c *Client) StartDeepScan(ctx context.Context, ips []string) (DeepScanStatus, error) {
+	var result DeepScanStatus
+	err := c.call(ctx, http.MethodPost, "vulnscan", nil, map[string]any{"ips": ips}, &result)
+	return result, err
+}
+
+// DeepScanStatus returns the state of the latest deep scan the server
+// started.
+func (c *Client) DeepScanStatus(ctx context.Context) (DeepScanStatus, error) {
+	var result DeepScanStatus
+	err := c.call(ctx, http.MethodGet, "vulnscan", nil, nil, &result)
+	return result, err
+}
+
+// Findings returns the latest deep scan of each device that has had one, by
+// IP.
+func (c *Client) Findings(ctx context.Context) (map[string]types.DeepScan, error) {
+	var result map[string]types.DeepScan
+	err := c.call(ctx, http.MethodGet, "findings", nil, nil, &result)
+	return result, err
+}
+
+// Vulnerable returns a summary of the findings of each device with any worse
+// than information, worst first.
+func (c *Client) Vulnerable(ctx context.Context) ([]cve.Exposure, error) {
+	var result []cve.Exposure
+	err := c.call(ctx, http.MethodGet, "vulnerable", nil, nil, &result)
+	return result, err
+}
+
+// Risk returns the risk score of each device scoring at least min, highest
+// first.
+func (c *Client) Risk(ctx context.Context, min int) ([]risk.Assessment, error) {
+	var result []risk.Assessment
+	err := c.call(ctx, http.MethodGet, "risk", url.Values{"min": {strconv.Itoa(min)}}, nil, &result)
+	return result, err
+}
+
+// Certs returns the TLS certificates devices served when last checked,
+// soonest to expire first.
+func (c *Client) Certs(ctx context.Context) ([]types.Certificate, error) {
+	var result []types.Certificate
+	err := c.call(ctx, http.MethodGet, "certs", nil, nil, &result)
+	return result, err
+}
+
+// Fingerprints returns the last fingerprint of each device, most recently
+// changed first.
+func (c *Client) Fingerprints(ctx context.Context) ([]types.Fingerprint, error) {
+	var result []types.Fingerprint
+	err := c.call(ctx, http.MethodGet, "fingerprints", nil, nil, &result)
+	return result, err
+}
+
+// Events returns the newest limit events, newest first, or all of them when
+// limit is 0.
+func (c *Client) Events(ctx context.Context, limit int) ([]types.Event, error) {
+	var result struct {
+		Events []types.Event `json:"events"`
+	}
+	err := c.call(ctx, http.MethodGet, "events", url.Values{"limit": {strconv.Itoa(limit)}}, nil, &result)
+	return result.Events, err
+}
+
+// Batch applies one of the batch actions (group, add_tag, remove_tag or
+// delete) to the devices at ips, and returns how many it applied to.
+func (c *Client) Batch(ctx context.Context, ips []string, action, value string) (int, error) {
+	var result struct {
+		Updated int `json:"updated"`
+	}
+	body := map[string]any{"ips": ips, "action": action, "value": value}
+	err := c.call(ctx, http.MethodPost, "devices/batch", nil, body, &result)
+	return result.Updated, err
+}
+
+// call makes a request and decodes the data of the JSON response into out,
+// unless out is nil.
+func (c *Client) call(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	resp, err := c.send(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("unexpected response from %s: %w", c.baseURL, err)
+	}
+	if out == nil || len(envelope.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(envelope.Data, out); err != nil {
+		return fmt.Errorf("unexpected response from %s: %w", c.baseURL, err)
+	}
+	return nil
+}
+
+// send makes a request and returns the response if it succeeded, or an error
+// carrying the server's explanation if it did not.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body any) (*http.Response, error) {
+	u := c.baseURL + "/api/" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not reach %s: %w", c.baseURL, err)
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	var failure types.APIResponse
+	_ = json.NewDecoder(resp.Body).Decode(&failure)
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized && c.token == "":
+		return nil, fmt.Errorf("%s requires a password: set api_token in the config, or ORANGUTAN_API_TOKEN, to the server's API token", c.baseURL)
+	case resp.StatusCode == http.StatusUnauthorized:
+		return nil, fmt.Errorf("%s rejected the API token", c.baseURL)
+	case failure.Error != "":
+		return nil, fmt.Errorf("server error: %s", failure.Error)
+	default:
+		return nil, fmt.Errorf("server error: %s", resp.Status)
+	}
+}
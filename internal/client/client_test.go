@@ -0,0 +1,119 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// fakeServer answers like the API: devices on GET /api/devices, where only a
+// q of label:router finds any, and a record of the last POST body on
+// /api/device.
+func fakeServer(t *testing.T, token string, posted *map[string]any) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(types.APIResponse{Error: "authentication required"})
+			return
+		}
+		switch r.URL.Path {
+		case "/api/devices":
+			devices := map[string]*types.Device{
+				"192.168.1.1": {IP: "192.168.1.1", Label: "Router"},
+			}
+			if q := r.URL.Query().Get("q"); q != "" && q != "label:router" {
+				devices = map[string]*types.Device{}
+			}
+			json.NewEncoder(w).Encode(types.APIResponse{Success: true, Data: devices})
+		case "/api/device":
+			if err := json.NewDecoder(r.Body).Decode(posted); err != nil {
+				t.Errorf("decoding request: %v", err)
+			}
+			json.NewEncoder(w).Encode(types.APIResponse{Success: true, Data: map[string]string{"message": "device updated"}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(types.APIResponse{Error: "endpoint not found"})
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDevices(t *testing.T) {
+	srv := fakeServer(t, "secret", nil)
+	c, err := New(srv.URL, "secret")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	devices, err := c.Devices(context.Background())
+	if err != nil {
+		t.Fatalf("Devices: %v", err)
+	}
+	if d := devices["192.168.1.1"]; d == nil || d.Label != "Router" {
+		t.Errorf("got %+v, want the router", devices)
+	}
+}
+
+func TestSearchDevicesSendsTheQuery(t *testing.T) {
+	srv := fakeServer(t, "", nil)
+	c, _ := New(srv.URL, "")
+
+	for q, want := range map[string]int{"label:router": 1, "label:printer": 0} {
+		devices, err := c.SearchDevices(context.Background(), q)
+		if err != nil {
+			t.Fatalf("SearchDevices(%q): %v", q, err)
+		}
+		if len(devices) != want {
+			t.Errorf("SearchDevices(%q) = %d devices, want %d", q, len(devices), want)
+		}
+	}
+}
+
+func TestUpdateDeviceSendsOnlyGivenFields(t *testing.T) {
+	var posted map[string]any
+	srv := fakeServer(t, "", &posted)
+	c, _ := New(srv.URL, "")
+
+	label := "Gateway"
+	if err := c.UpdateDevice(context.Background(), "192.168.1.1", &label, nil, nil, nil); err != nil {
+		t.Fatalf("UpdateDevice: %v", err)
+	}
+	if posted["ip"] != "192.168.1.1" || posted["label"] != "Gateway" {
+		t.Errorf("posted %v, want the IP and label", posted)
+	}
+	if _, ok := posted["notes"]; ok {
+		t.Errorf("posted %v, which would clear the notes", posted)
+	}
+}
+
+func TestErrorsExplainThemselves(t *testing.T) {
+	srv := fakeServer(t, "secret", nil)
+
+	c, _ := New(srv.URL, "")
+	if _, err := c.Devices(context.Background()); err == nil || !strings.Contains(err.Error(), "api_token") {
+		t.Errorf("missing token: got %v, want advice on setting api_token", err)
+	}
+
+	c, _ = New(srv.URL, "secret")
+	if _, err := c.Networks(context.Background()); err == nil || !strings.Contains(err.Error(), "endpoint not found") {
+		t.Errorf("server error: got %v, want the server's message", err)
+	}
+}
+
+func TestNewAcceptsBareHost(t *testing.T) {
+	c, err := New("nas.lan:291", "")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if c.baseURL != "http://nas.lan:291" {
+		t.Errorf("baseURL = %q, want http://nas.lan:291", c.baseURL)
+	}
+}
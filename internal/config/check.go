@@ -0,0 +1,535 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/adguard"
+	"github.com/291-Group/LAN-Orangutan/internal/alert"
+	"github.com/291-Group/LAN-Orangutan/internal/credcheck"
+	"github.com/291-Group/LAN-Orangutan/internal/cve"
+	"github.com/291-Group/LAN-Orangutan/internal/dnszone"
+	"github.com/291-Group/LAN-Orangutan/internal/firewall"
+	"github.com/291-Group/LAN-Orangutan/internal/influx"
+	"github.com/291-Group/LAN-Orangutan/internal/kube"
+	"github.com/291-Group/LAN-Orangutan/internal/ldap"
+	"github.com/291-Group/LAN-Orangutan/internal/mdns"
+	"github.com/291-Group/LAN-Orangutan/internal/mqtt"
+	"github.com/291-Group/LAN-Orangutan/internal/network"
+	"github.com/291-Group/LAN-Orangutan/internal/oidc"
+	"github.com/291-Group/LAN-Orangutan/internal/openwrt"
+	"github.com/291-Group/LAN-Orangutan/internal/pihole"
+	"github.com/291-Group/LAN-Orangutan/internal/query"
+	"github.com/291-Group/LAN-Orangutan/internal/scanner"
+	"github.com/291-Group/LAN-Orangutan/internal/schedule"
+	"github.com/291-Group/LAN-Orangutan/internal/tailnet"
+	"github.com/291-Group/LAN-Orangutan/internal/zabbix"
+)
+
+// Check reads the config file at path and describes each line that Load
+// skips because it cannot be understood, such as a misspelt key or a port
+// that is not a number. A file that does not exist has no problems, since the
+// defaults are used.
+func Check(path string) ([]string, error) {
+	_, problems, err := load(path)
+	return problems, err
+}
+
+// Validate describes the settings in c that are understood but cannot work,
+// such as a port out of range or intervals that contradict each other. It
+// takes the loaded config rather than a file so environment overrides are
+// checked too. Each problem starts with where the setting was made, in the
+// form Check uses, when it was not left at its default.
+func (c *Config) Validate() []string {
+	var problems []string
+	add := func(key, format string, args ...any) {
+		problems = append(problems, c.where(key)+fmt.Sprintf(format, args...))
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		add("server.port", "port %d is not between 1 and 65535", c.Server.Port)
+	}
+	if c.Server.UnprivilegedPort < 0 || c.Server.UnprivilegedPort > 65535 {
+		add("server.unprivileged_port", "unprivileged_port %d is not between 1 and 65535, or 0 for none", c.Server.UnprivilegedPort)
+	}
+	if c.Server.MDNS {
+		if err := mdns.ValidHost(c.Server.MDNSName); err != nil {
+			add("server.mdns_name", "mdns_name %v", err)
+		}
+	}
+	if c.LDAP.URL != "" {
+		if err := ldap.ValidURL(c.LDAP.URL); err != nil {
+			add("ldap.url", "url %v", err)
+		}
+		if c.LDAP.BaseDN == "" {
+			add("ldap.base_dn", "url is set but base_dn is not, so there is nowhere to look for users")
+		}
+		if c.LDAP.CAFile != "" {
+			if _, err := os.Stat(c.LDAP.CAFile); err != nil {
+				add("ldap.ca_file", "ca_file %v", err)
+			}
+		}
+	}
+	if c.OIDC.Issuer != "" {
+		if err := oidc.ValidIssuer(c.OIDC.Issuer); err != nil {
+			add("oidc.issuer", "issuer %v", err)
+		}
+		if c.OIDC.ClientID == "" {
+			add("oidc.client_id", "issuer is set but client_id is not")
+		}
+		if !slices.Contains(c.OIDC.Scopes, "openid") {
+			add("oidc.scopes", "scopes must include openid")
+		}
+	}
+	if (c.LDAP.URL != "" || c.OIDC.Issuer != "") && c.Roles.Default == "none" &&
+		len(c.Roles.Admin)+len(c.Roles.Editor)+len(c.Roles.Viewer) == 0 {
+		add("roles.default", "no group is given a role and default is none, so nobody can sign in with ldap or oidc")
+	}
+	if c.Server.SessionHours < 0 {
+		add("server.session_hours", "session_hours %d is negative; the default of a week is used", c.Server.SessionHours)
+	}
+	if c.Scanning.ScanInterval <= 0 {
+		add("scanning.scan_interval", "scan_interval %d must be more than 0 seconds", c.Scanning.ScanInterval)
+	}
+	if c.Scanning.MinScanInterval < 0 {
+		add("scanning.min_scan_interval", "min_scan_interval %d is negative", c.Scanning.MinScanInterval)
+	}
+	if c.Scanning.ScanInterval > 0 && c.Scanning.MinScanInterval > c.Scanning.ScanInterval {
+		add("scanning.min_scan_interval", "min_scan_interval %d is longer than scan_interval %d, so scans happen every %d seconds",
+			c.Scanning.MinScanInterval, c.Scanning.ScanInterval, c.Scanning.MinScanInterval)
+	}
+	if c.Scanning.ScanTimeout <= 0 {
+		add("scanning.scan_timeout", "scan_timeout %d must be more than 0 seconds", c.Scanning.ScanTimeout)
+	}
+	if c.Scanning.EnablePortScan {
+		if _, _, err := network.ParsePortRange(c.Scanning.PortScanRange); err != nil {
+			add("scanning.port_scan_range", "port_scan_range %q: %v", c.Scanning.PortScanRange, err)
+		}
+	}
+	if len(c.Scanning.Backends) == 0 {
+		add("scanning.backends", "backends is empty; the default of %s is used", strings.Join(scanner.DefaultBackends, ", "))
+	}
+	seen := make(map[string]bool)
+	for _, b := range c.Scanning.Backends {
+		switch {
+		case !scanner.ValidBackend(b):
+			add("scanning.backends", "backends: %q is not nmap or arp-scan", b)
+		case seen[b]:
+			add("scanning.backends", "backends: %s is listed twice", b)
+		}
+		seen[b] = true
+	}
+	switch c.Scanning.Privileged {
+	case "auto", "true", "yes", "1", "on", "false", "no", "0", "off":
+	default:
+		add("scanning.privileged", "privileged %q is not auto, true or false", c.Scanning.Privileged)
+	}
+	if c.Scanning.Schedule != "" {
+		if _, err := schedule.Parse(c.Scanning.Schedule); err != nil {
+			add("scanning.schedule", "schedule %v", err)
+		}
+	}
+	for _, cidr := range c.Scanning.Networks {
+		if !network.ValidateCIDR(cidr) {
+			add("scanning.networks", "networks: %q is not a CIDR such as 192.168.1.0/24", cidr)
+		}
+	}
+	cidrs := make([]string, 0, len(c.Network))
+	for cidr := range c.Network {
+		cidrs = append(cidrs, cidr)
+	}
+	sort.Strings(cidrs)
+	for _, cidr := range cidrs {
+		n := c.Network[cidr]
+		section := fmt.Sprintf("network %q", cidr)
+		if n.ScanInterval < 0 {
+			add(sourceKey(section, "scan_interval"), "[network %q] scan_interval %d is negative", cidr, n.ScanInterval)
+		}
+		if n.Schedule != "" {
+			if _, err := schedule.Parse(n.Schedule); err != nil {
+				add(sourceKey(section, "schedule"), "[network %q] schedule %v", cidr, err)
+			}
+		}
+		if n.Profile != "" && !scanner.ValidProfile(n.Profile) {
+			add(sourceKey(section, "profile"), "[network %q] profile %q is not quick, normal or thorough", cidr, n.Profile)
+		}
+		for _, e := range n.Exclude {
+			if net.ParseIP(e) == nil && !network.ValidateCIDR(e) {
+				add(sourceKey(section, "exclude"), "[network %q] exclude: %q is not an address or a CIDR", cidr, e)
+			}
+		}
+	}
+	if c.Storage.RetentionDays < 0 {
+		add("storage.retention_days", "retention_days %d is negative", c.Storage.RetentionDays)
+	}
+	if c.Storage.DataDir == "" {
+		add("storage.data_dir", "data_dir is empty")
+	}
+	if c.Reports.Schedule != "" {
+		if _, err := alert.DigestSchedule(c.Reports.Schedule); err != nil {
+			add("reports.schedule", "schedule is not daily, weekly or a schedule: %v", err)
+		}
+	}
+	if c.Reports.Keep < 0 {
+		add("reports.keep", "keep %d is negative", c.Reports.Keep)
+	}
+	if c.Watchlist.Interval < 0 {
+		add("watchlist.interval", "interval %d is negative", c.Watchlist.Interval)
+	}
+	if c.Watchlist.Failures < 1 {
+		add("watchlist.failures", "failures must be at least 1")
+	}
+	if len(c.VulnScan.Scripts) == 0 {
+		add("vulnscan.scripts", "scripts is empty; the default of %s is used", strings.Join(scanner.DefaultDeepScanScripts, ", "))
+	}
+	for _, script := range c.VulnScan.Scripts {
+		if !scanner.ValidScript(script) {
+			add("vulnscan.scripts", "%q is not the name of an NSE script or category", script)
+		}
+	}
+	if c.VulnScan.Ports != "" && !scanner.ValidPorts(c.VulnScan.Ports) {
+		add("vulnscan.ports", "ports %q is not a list of ports such as 22,80,8000-8100", c.VulnScan.Ports)
+	}
+	if c.VulnScan.Timeout <= 0 {
+		add("vulnscan.timeout", "timeout must be a number of seconds above 0")
+	}
+	if err := cve.ValidURL(c.CVE.URL); err != nil {
+		add("cve.url", "url %v", err)
+	}
+	if c.CVE.Cache <= 0 {
+		add("cve.cache", "cache must be a length of time such as 7d")
+	}
+	for _, t := range c.CredCheck.Types {
+		if t == "" || !scanner.ValidType(t) {
+			add("credcheck.types", "%q is not a device type; use %s", t, strings.Join(scanner.DeviceTypes, ", "))
+		}
+	}
+	for _, login := range c.CredCheck.Logins {
+		if _, err := credcheck.ParseLogin(login); err != nil {
+			add("credcheck.logins", "%v", err)
+		}
+	}
+	if c.CredCheck.Delay < 0 {
+		add("credcheck.delay", "delay %d is negative", c.CredCheck.Delay)
+	}
+	if c.Certs.Interval > 0 && c.Certs.Interval < time.Hour {
+		add("certs.interval", "interval %s is under an hour; certificates last months", query.FormatAge(c.Certs.Interval))
+	}
+	if c.Certs.Warn <= 0 {
+		add("certs.warn", "warn must be a length of time such as 14d")
+	}
+	if c.Fingerprint.Interval > 0 && c.Fingerprint.Interval < 5*time.Minute {
+		add("fingerprint.interval", "interval %s is under 5 minutes; each round waits for multicast DNS answers", query.FormatAge(c.Fingerprint.Interval))
+	}
+	switch c.UI.Theme {
+	case "auto", "light", "dark":
+	default:
+		add("ui.theme", "theme %q is not auto, light or dark", c.UI.Theme)
+	}
+	if c.MQTT.Broker != "" {
+		if _, _, err := mqtt.ParseBroker(c.MQTT.Broker); err != nil {
+			add("mqtt.broker", "%v", err)
+		}
+	}
+	if c.MQTT.QoS != 0 && c.MQTT.QoS != 1 {
+		add("mqtt.qos", "qos %d is not 0 or 1", c.MQTT.QoS)
+	}
+	if c.MQTT.TopicPrefix == "" || strings.ContainsAny(c.MQTT.TopicPrefix, "+#") {
+		add("mqtt.topic_prefix", "topic_prefix %q must be a topic without the wildcards + and #", c.MQTT.TopicPrefix)
+	}
+	if c.MQTT.HomeAssistant && (c.MQTT.DiscoveryPrefix == "" || strings.ContainsAny(c.MQTT.DiscoveryPrefix, "+#")) {
+		add("mqtt.discovery_prefix", "discovery_prefix %q must be a topic without the wildcards + and #", c.MQTT.DiscoveryPrefix)
+	}
+	if c.MQTT.SnapshotInterval < 0 {
+		add("mqtt.snapshot_interval", "snapshot_interval %d is negative", c.MQTT.SnapshotInterval)
+	}
+	if c.Metrics.Textfile != "" {
+		if !strings.HasSuffix(c.Metrics.Textfile, ".prom") {
+			add("metrics.textfile", "textfile %s does not end in .prom, so node_exporter will not read it", c.Metrics.Textfile)
+		}
+		if c.Metrics.TextfileInterval <= 0 {
+			add("metrics.textfile_interval", "textfile_interval %d must be more than 0 seconds", c.Metrics.TextfileInterval)
+		}
+	}
+	if c.InfluxDB.URL != "" {
+		if err := influx.ValidURL(c.InfluxDB.URL); err != nil {
+			add("influxdb.url", "url %v", err)
+		}
+		if c.InfluxDB.Bucket == "" {
+			add("influxdb.url", "url is set but bucket is not, so nothing can be written")
+		}
+	}
+	if c.Zabbix.Server != "" {
+		if err := zabbix.ValidServer(c.Zabbix.Server); err != nil {
+			add("zabbix.server", "server %v", err)
+		}
+		if c.Zabbix.Host == "" {
+			add("zabbix.host", "server is set but host is not, so the values have nowhere to go")
+		}
+	}
+	if c.Pihole.URL != "" {
+		if err := pihole.ValidURL(c.Pihole.URL); err != nil {
+			add("pihole.url", "url %v", err)
+		}
+	}
+	if c.AdGuard.URL != "" {
+		if err := adguard.ValidURL(c.AdGuard.URL); err != nil {
+			add("adguard.url", "url %v", err)
+		}
+	}
+	if c.OpenWrt.URL != "" {
+		if err := openwrt.ValidURL(c.OpenWrt.URL); err != nil {
+			add("openwrt.url", "url %v", err)
+		}
+	}
+	if c.Firewall.URL != "" {
+		if err := firewall.Valid(c.Firewall.Options()); err != nil {
+			key := "firewall.url"
+			for _, k := range []string{"type", "key", "secret"} {
+				if strings.HasPrefix(err.Error(), k+" ") {
+					key = "firewall." + k
+				}
+			}
+			add(key, "%v", err)
+		}
+		if c.Firewall.CAFile != "" {
+			if _, err := os.Stat(c.Firewall.CAFile); err != nil {
+				add("firewall.ca_file", "ca_file %v", err)
+			}
+		}
+	}
+	if c.Docker.Enable {
+		if _, err := os.Stat(c.Docker.Socket); err != nil {
+			add("docker.socket", "socket %v", err)
+		}
+	}
+	if c.Kubernetes.Enable {
+		if _, err := kube.New(c.Kubernetes.Kubeconfig, c.Kubernetes.Context); err != nil {
+			add("kubernetes.kubeconfig", "kubeconfig %v", err)
+		}
+	}
+	if err := dnszone.ValidDomain(c.DNS.Domain); err != nil {
+		add("dns.domain", "domain %v", err)
+	}
+	if c.DNS.DnsmasqFile != "" {
+		if _, err := os.Stat(filepath.Dir(c.DNS.DnsmasqFile)); err != nil {
+			add("dns.dnsmasq_file", "dnsmasq_file cannot be written: %v", err)
+		}
+	}
+	if c.Tailscale.Serve {
+		if !tailnet.Available {
+			add("tailscale.serve", "serve is on, but %v", tailnet.ErrNotBuilt)
+		}
+		if err := tailnet.ValidHostname(c.Tailscale.Hostname); err != nil {
+			add("tailscale.hostname", "hostname %v", err)
+		}
+	}
+	for _, name := range sortedKeys(c.Notify) {
+		section := fmt.Sprintf("notify %q", name)
+		n := c.Notify[name]
+		if _, err := alert.NewNotifier(n.Options()); err != nil {
+			// A setting that is missing is reported on the section's type.
+			key := "type"
+			for _, set := range []struct{ key, value string }{
+				{"url", n.URL}, {"priority", n.Priority}, {"body_template", n.BodyTemplate}, {"facility", n.Facility}, {"room", n.Room},
+			} {
+				if set.value != "" && strings.HasPrefix(err.Error(), set.key) {
+					key = set.key
+				}
+			}
+			add(sourceKey(section, key), "[%s] %v", section, err)
+		}
+		if n.Commands && n.Type != "telegram" {
+			add(sourceKey(section, "commands"), "[%s] commands only works with telegram", section)
+		}
+		if n.Priority != "" && !slices.Contains([]string{"ntfy", "gotify", "pushover"}, n.Type) {
+			add(sourceKey(section, "priority"), "[%s] priority only works with ntfy, gotify and pushover", section)
+		}
+		if n.Room != "" && n.Type != "matrix" {
+			add(sourceKey(section, "room"), "[%s] room only works with matrix", section)
+		}
+		if n.Community != "" && n.Type != "snmp" {
+			add(sourceKey(section, "community"), "[%s] community only works with snmp", section)
+		}
+		if n.Port < 0 || n.Port > 65535 {
+			add(sourceKey(section, "port"), "[%s] port %d is not between 1 and 65535", section, n.Port)
+		}
+		if n.Digest != "" {
+			if _, err := alert.DigestSchedule(n.Digest); err != nil {
+				add(sourceKey(section, "digest"), "[%s] digest is not daily, weekly or a schedule: %v", section, err)
+			}
+		}
+		if n.DigestTemplate != "" {
+			if _, err := alert.DigestTemplate(n.DigestTemplate); err != nil {
+				add(sourceKey(section, "digest_template"), "[%s] digest_template: %v", section, err)
+			}
+		}
+		if !n.Alerts && n.Digest == "" {
+			add(sourceKey(section, "alerts"), "[%s] alerts is false and there is no digest, so nothing is sent", section)
+		}
+	}
+	for _, name := range sortedKeys(c.Alert) {
+		a := c.Alert[name]
+		section := fmt.Sprintf("alert %q", name)
+		for _, e := range a.Events {
+			if _, ok := alert.EventType(e); !ok {
+				add(sourceKey(section, "events"), "[%s] events: %q is not %s", section, e, alert.EventNames())
+			}
+		}
+		for _, n := range a.Notify {
+			switch {
+			case c.Notify[n] == nil:
+				add(sourceKey(section, "notify"), "[%s] notify: there is no [notify %q] section", section, n)
+			case !c.Notify[n].Alerts:
+				add(sourceKey(section, "notify"), "[%s] notify: [notify %q] has alerts = false", section, n)
+			}
+		}
+		if len(a.Notify) == 0 && len(c.Notify) == 0 {
+			add(sourceKey(section, "notify"), "[%s] there are no [notify] sections to send the alert with", section)
+		}
+		if err := alert.ParseTemplate(a.Template); err != nil {
+			add(sourceKey(section, "template"), "[%s] template: %v", section, err)
+		}
+		if a.Escalate != "" {
+			if c.Escalation[a.Escalate] == nil {
+				add(sourceKey(section, "escalate"), "[%s] escalate: there is no [escalation %q] section", section, a.Escalate)
+			}
+			if len(a.Notify) > 0 {
+				add(sourceKey(section, "notify"), "[%s] notify is not used with escalate: the escalation's steps say who is told", section)
+			}
+		}
+	}
+	for _, name := range sortedKeys(c.Escalation) {
+		e := c.Escalation[name]
+		section := fmt.Sprintf("escalation %q", name)
+		if len(e.Steps) == 0 {
+			add(sourceKey(section, "steps"), "[%s] no steps set, so nobody is told", section)
+		}
+		for _, step := range e.Steps {
+			for _, n := range step.Notify {
+				switch {
+				case c.Notify[n] == nil:
+					add(sourceKey(section, "steps"), "[%s] steps: there is no [notify %q] section", section, n)
+				case !c.Notify[n].Alerts:
+					add(sourceKey(section, "steps"), "[%s] steps: [notify %q] has alerts = false", section, n)
+				}
+			}
+		}
+	}
+	for _, name := range sortedKeys(c.Maintenance) {
+		m := c.Maintenance[name]
+		section := fmt.Sprintf("maintenance %q", name)
+		if m.Schedule == "" {
+			add(sourceKey(section, "schedule"), "[%s] no schedule set, so it never opens", section)
+		} else if _, err := schedule.Parse(m.Schedule); err != nil {
+			add(sourceKey(section, "schedule"), "[%s] schedule %v", section, err)
+		}
+		if m.Duration <= 0 {
+			add(sourceKey(section, "duration"), "[%s] no duration set, so it closes as it opens", section)
+		}
+		for _, cidr := range m.Networks {
+			if !network.ValidateCIDR(cidr) {
+				add(sourceKey(section, "networks"), "[%s] networks: %q is not a CIDR such as 192.168.1.0/24", section, cidr)
+			}
+		}
+	}
+	for _, name := range sortedKeys(c.Person) {
+		p := c.Person[name]
+		section := fmt.Sprintf("person %q", name)
+		if len(p.MACs) == 0 {
+			add(sourceKey(section, "macs"), "[%s] macs is empty, so they are never home", section)
+		}
+		for _, mac := range p.MACs {
+			if _, err := net.ParseMAC(mac); err != nil {
+				add(sourceKey(section, "macs"), "[%s] macs: %q is not a MAC address", section, mac)
+			}
+		}
+	}
+	for _, name := range sortedKeys(c.Restricted) {
+		r := c.Restricted[name]
+		section := fmt.Sprintf("restricted %q", name)
+		if len(r.Networks) == 0 && len(r.Groups) == 0 {
+			add(sourceKey(section, "networks"), "[%s] neither networks nor groups is set, so it covers nothing", section)
+		}
+		for _, cidr := range r.Networks {
+			if !network.ValidateCIDR(cidr) {
+				add(sourceKey(section, "networks"), "[%s] networks: %q is not a CIDR such as 192.168.1.0/24", section, cidr)
+			}
+		}
+		for _, allowed := range r.Allowed {
+			if _, err := net.ParseMAC(allowed); err != nil && net.ParseIP(allowed) == nil {
+				add(sourceKey(section, "allowed"), "[%s] allowed: %q is neither a MAC nor an IP address", section, allowed)
+			}
+		}
+	}
+	return problems
+}
+
+// where returns the prefix naming where the setting key was made, such as
+// "line 12: " for the config file or "config.d/50-local.ini: line 3: " for a
+// drop-in, or "" for a default.
+func (c *Config) where(key string) string {
+	kind, at, ok := strings.Cut(c.Source(key), " ")
+	if !ok {
+		return ""
+	}
+	switch kind {
+	case "file", "drop-in":
+		i := strings.LastIndex(at, ":")
+		if i < 0 {
+			return ""
+		}
+		prefix := "line " + at[i+1:] + ": "
+		if kind == "drop-in" {
+			prefix = DropInDir + "/" + filepath.Base(at[:i]) + ": " + prefix
+		}
+		return prefix
+	case "env", "flag":
+		return at + ": "
+	default:
+		return c.Source(key) + ": "
+	}
+}
+
+// envNumbers and envBools are the environment variables ApplyEnv reads as
+// whole numbers and as true or false.
+var (
+	envNumbers = []string{"ORANGUTAN_PORT", "ORANGUTAN_SESSION_HOURS", "ORANGUTAN_SCAN_INTERVAL", "ORANGUTAN_SCAN_TIMEOUT"}
+	envBools   = []string{"ORANGUTAN_ALLOW_INSECURE"}
+)
+
+// CheckEnv describes each ORANGUTAN_ environment variable that ApplyEnv
+// skips or misreads because its value cannot be used, as Check does for the
+// lines of the config file.
+func CheckEnv() []string {
+	var problems []string
+	for _, name := range envNumbers {
+		if v := os.Getenv(name); v != "" {
+			if _, err := strconv.Atoi(v); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %q is not a whole number", name, v))
+			}
+		}
+	}
+	for _, name := range envBools {
+		if v := os.Getenv(name); v != "" {
+			if err := setBool(new(bool), v); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+			}
+		}
+	}
+	if path := os.Getenv("ORANGUTAN_PASSWORD_FILE"); path != "" {
+		if data, err := os.ReadFile(path); err != nil {
+			problems = append(problems, fmt.Sprintf("ORANGUTAN_PASSWORD_FILE: %v", err))
+		} else if strings.TrimSpace(string(data)) == "" {
+			problems = append(problems, fmt.Sprintf("ORANGUTAN_PASSWORD_FILE: %s is empty", path))
+		}
+	}
+	return problems
+}
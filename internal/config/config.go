@@ -3,16 +3,40 @@ package config
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/291-Group/LAN-Orangutan/internal/adguard"
+	"github.com/291-Group/LAN-Orangutan/internal/alert"
+	"github.com/291-Group/LAN-Orangutan/internal/auth"
+	"github.com/291-Group/LAN-Orangutan/internal/credcheck"
+	"github.com/291-Group/LAN-Orangutan/internal/cve"
+	"github.com/291-Group/LAN-Orangutan/internal/docker"
+	"github.com/291-Group/LAN-Orangutan/internal/fingerprint"
+	"github.com/291-Group/LAN-Orangutan/internal/firewall"
+	"github.com/291-Group/LAN-Orangutan/internal/influx"
+	"github.com/291-Group/LAN-Orangutan/internal/kube"
+	"github.com/291-Group/LAN-Orangutan/internal/ldap"
 	"github.com/291-Group/LAN-Orangutan/internal/network"
+	"github.com/291-Group/LAN-Orangutan/internal/oidc"
+	"github.com/291-Group/LAN-Orangutan/internal/openwrt"
+	"github.com/291-Group/LAN-Orangutan/internal/pihole"
+	"github.com/291-Group/LAN-Orangutan/internal/query"
+	"github.com/291-Group/LAN-Orangutan/internal/scanner"
+	"github.com/291-Group/LAN-Orangutan/internal/schedule"
+	"github.com/291-Group/LAN-Orangutan/internal/tlscert"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+	"github.com/291-Group/LAN-Orangutan/internal/zabbix"
 )
 
 // GetDefaultDataDir returns the appropriate default data directory for the current OS
@@ -40,20 +64,51 @@ func GetDefaultDataDir() string {
 
 	default:
 		// Linux and others
-		// Use /var/lib if running as root, otherwise use user's local share
+		// Use /var/lib if running as root, otherwise the user's data home
 		if os.Getuid() == 0 {
 			return "/var/lib/lan-orangutan"
 		}
-		home, err := os.UserHomeDir()
-		if err != nil {
+		dir := xdgDir("XDG_DATA_HOME", ".local", "share")
+		if dir == "" {
 			return "/tmp/lan-orangutan"
 		}
-		return filepath.Join(home, ".local", "share", "lan-orangutan")
+		return filepath.Join(dir, "lan-orangutan")
+	}
+}
+
+// xdgDir returns the base directory named by the XDG environment variable
+// env, or the directory under the home directory it defaults to. The XDG
+// spec says a relative path in the variable is to be ignored. It returns ""
+// when there is no home directory either.
+func xdgDir(env string, underHome ...string) string {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
 	}
+	return filepath.Join(append([]string{home}, underHome...)...)
 }
 
-// GetDefaultConfigFile returns the appropriate default config file path for the current OS
+// GetDefaultConfigFile returns the appropriate default config file path for the current OS.
+// That is config.ini unless there is none, and a config.yaml, config.yml or
+// config.toml in the same place instead.
 func GetDefaultConfigFile() string {
+	path := defaultINIFile()
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	for _, ext := range []string{".yaml", ".yml", ".toml"} {
+		other := strings.TrimSuffix(path, ".ini") + ext
+		if _, err := os.Stat(other); err == nil {
+			return other
+		}
+	}
+	return path
+}
+
+func defaultINIFile() string {
 	switch runtime.GOOS {
 	case "darwin":
 		home, err := os.UserHomeDir()
@@ -70,25 +125,83 @@ func GetDefaultConfigFile() string {
 		return filepath.Join(appData, "lan-orangutan", "config.ini")
 
 	default:
-		// Linux: use /etc if running as root, otherwise use user config
+		// Linux: use /etc if running as root, otherwise the user's config home
 		if os.Getuid() == 0 {
 			return "/etc/lan-orangutan/config.ini"
 		}
-		home, err := os.UserHomeDir()
-		if err != nil {
+		dir := xdgDir("XDG_CONFIG_HOME", ".config")
+		if dir == "" {
 			return "/tmp/lan-orangutan/config.ini"
 		}
-		return filepath.Join(home, ".config", "lan-orangutan", "config.ini")
+		return filepath.Join(dir, "lan-orangutan", "config.ini")
 	}
 }
 
 // Config holds all application configuration
 type Config struct {
-	Server    ServerConfig
-	Scanning  ScanningConfig
-	Storage   StorageConfig
-	Tailscale TailscaleConfig
-	UI        UIConfig
+	Server      ServerConfig
+	LDAP        LDAPConfig
+	OIDC        OIDCConfig
+	Roles       RolesConfig
+	Scanning    ScanningConfig
+	Storage     StorageConfig
+	Approval    ApprovalConfig
+	Offline     OfflineConfig
+	Reports     ReportsConfig
+	Watchlist   WatchlistConfig
+	VulnScan    VulnScanConfig
+	CVE         CVEConfig
+	CredCheck   CredCheckConfig
+	Certs       CertsConfig
+	Fingerprint FingerprintConfig
+	Tailscale   TailscaleConfig
+	UI          UIConfig
+	MQTT        MQTTConfig
+	Metrics     MetricsConfig
+	InfluxDB    InfluxDBConfig
+	Zabbix      ZabbixConfig
+	Pihole      PiholeConfig
+	AdGuard     AdGuardConfig
+	OpenWrt     OpenWrtConfig
+	Firewall    FirewallConfig
+	DNS         DNSConfig
+	Docker      DockerConfig
+	Kubernetes  KubernetesConfig
+
+	// Network holds the [network "192.168.1.0/24"] sections, keyed by CIDR
+	// in the form net.IPNet writes it. Use ForNetwork to read them.
+	Network map[string]*NetworkConfig
+
+	// Notify holds the [notify "name"] sections, which say where alerts can
+	// be sent, and Alert the [alert "name"] sections, which say what to
+	// alert about and with which of them. Both are keyed by name.
+	Notify map[string]*NotifyConfig
+	Alert  map[string]*AlertConfig
+	// Maintenance holds the [maintenance "name"] sections, the times when
+	// alerts about some or all devices are not sent, keyed by name.
+	Maintenance map[string]*MaintenanceConfig
+	// Escalation holds the [escalation "name"] sections, the policies alert
+	// rules can follow to tell more people while nobody acknowledges an
+	// alert, keyed by name.
+	Escalation map[string]*EscalationConfig
+
+	// Person holds the [person "name"] sections, which say whose devices
+	// mean someone is home, keyed by name. Use People to read them.
+	Person map[string]*PersonConfig
+
+	// Restricted holds the [restricted "name"] sections, the networks and
+	// groups only some devices may be on, keyed by name. Use RestrictedZones
+	// to read them.
+	Restricted map[string]*RestrictedConfig
+
+	// sources records where settings that are not defaults came from, by
+	// key, as Source reports them.
+	sources map[string]string
+
+	// profiles holds the settings of the [profile "name"] sections by name,
+	// and profile the one UseProfile applied.
+	profiles map[string][]profileSetting
+	profile  string
 }
 
 // ServerConfig holds web server settings
@@ -101,6 +214,14 @@ type ServerConfig struct {
 	// required. May be given as plaintext or as a bcrypt hash.
 	Password string
 
+	// Username, when set, must be entered alongside the password at login.
+	// Empty means the password alone is enough.
+	Username string
+
+	// APIToken lets scripts call the API with "Authorization: Bearer <token>"
+	// instead of signing in. Empty disables token access.
+	APIToken string
+
 	// SessionHours is how long a login stays valid.
 	SessionHours int
 
@@ -108,19 +229,122 @@ type ServerConfig struct {
 	// password. Off by default: doing so exposes the API, which can modify
 	// stored data, to everyone on the network.
 	AllowInsecure bool
+
+	// UnprivilegedPort is the port the server listens on instead when Port
+	// is below 1024 and the system will not let it have that: the default of
+	// 291 needs root on Linux and macOS. 0 means fail instead.
+	UnprivilegedPort int
+
+	// MDNS advertises the dashboard on the local network with multicast
+	// DNS, as MDNSName.local and as a web service browsers of services
+	// list. It is never advertised while bound to loopback.
+	MDNS     bool
+	MDNSName string
+}
+
+// LDAPConfig holds the settings for checking logins with an LDAP directory.
+type LDAPConfig struct {
+	// URL is the directory, ldap://host or ldaps://host. Empty means logins
+	// are not checked with one.
+	URL string
+	// BindDN and BindPassword are the account users are searched for as.
+	BindDN       string
+	BindPassword string
+	// BaseDN is where users are searched for.
+	BaseDN string
+	// UserAttribute holds the username, and GroupAttribute the groups of a
+	// user. GroupBaseDN, when set, is searched for groups with the user as a
+	// member instead.
+	UserAttribute  string
+	GroupAttribute string
+	GroupBaseDN    string
+	StartTLS       bool
+	TLSVerify      bool
+	CAFile         string
+}
+
+// OIDCConfig holds the settings for signing in with an OpenID Connect
+// provider.
+type OIDCConfig struct {
+	// Issuer is the provider's issuer URL. Empty means there is none.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends the browser back to, which
+	// must be registered with it. Empty means /login/sso/callback on the
+	// address the dashboard was opened at.
+	RedirectURL   string
+	Scopes        []string
+	UsernameClaim string
+	GroupsClaim   string
+	// Name is what the sign in button calls the provider.
+	Name string
+}
+
+// RolesConfig says which role users of the directory and the OpenID Connect
+// provider get from the groups they are in.
+type RolesConfig struct {
+	Admin  []string
+	Editor []string
+	Viewer []string
+	// Default is the role of users in none of those groups: none, viewer,
+	// editor or admin.
+	Default string
 }
 
 // ScanningConfig holds scanner settings
 type ScanningConfig struct {
 	ScanInterval    int
 	MinScanInterval int
-	EnablePortScan  bool
-	PortScanRange   string
+	// ScanTimeout is how many seconds the scan of one network may take
+	// before it is abandoned. Sweeping a /16 legitimately takes far longer
+	// than an ARP sweep of a home /24.
+	ScanTimeout    int
+	EnablePortScan bool
+	PortScanRange  string
 
 	// Networks are CIDRs the user has declared explicitly, for cases where
 	// automatic detection cannot see the right network. A container only sees
 	// Docker's private network, so without this it can never scan the LAN.
 	Networks []string
+
+	// Backends are the tools scans use, tried in order until one works:
+	// "nmap" and "arp-scan". One left out is never used.
+	Backends []string
+
+	// Privileged is whether scans use raw sockets: "true", "false", or
+	// "auto" to use them when the process can, which is as root or, on
+	// Linux, with an nmap given cap_net_raw.
+	Privileged string
+
+	// Schedule, when set, is the schedule of every network whose section
+	// sets neither its own schedule nor its own scan_interval, in place of
+	// ScanInterval.
+	Schedule string
+}
+
+// NetworkConfig holds the scan settings of one network, overriding those in
+// [scanning] for it.
+type NetworkConfig struct {
+	// ScanInterval is how many seconds watch and monitor leave between scans
+	// of the network. 0 means scan_interval.
+	ScanInterval int
+
+	// Schedule, when set, replaces ScanInterval with cron expressions saying
+	// when to scan, separated by semicolons, as package schedule reads them.
+	Schedule string
+
+	// Profile is how hard the scan looks for hosts: quick, normal or
+	// thorough. Empty means normal.
+	Profile string
+
+	// Exclude are addresses and CIDRs in the network that are never scanned.
+	Exclude []string
+
+	// Enable, when false, leaves the network out of scans of all networks,
+	// so a network that is detected but not wanted is not swept. Scanning
+	// it by name still works.
+	Enable bool
 }
 
 // StorageConfig holds data storage settings
@@ -130,15 +354,579 @@ type StorageConfig struct {
 	DataDir       string
 }
 
+// ApprovalConfig holds the settings for approving newly found devices.
+type ApprovalConfig struct {
+	// Enable has devices that scans find for the first time wait in a list
+	// of pending devices until someone approves them.
+	Enable bool
+	// Vendors approve a new device at once when its vendor contains one of
+	// them, and Groups when its group or one of its tags is one of them,
+	// ignoring case in both.
+	Vendors []string
+	Groups  []string
+}
+
+// Pending returns what the store asks of each new device, whether it waits
+// for approval, or nil when nothing has to be approved.
+func (a ApprovalConfig) Pending() func(*types.Device) bool {
+	if !a.Enable {
+		return nil
+	}
+	return func(d *types.Device) bool {
+		vendor := strings.ToLower(scanner.ResolveVendor(d.Vendor, d.MAC))
+		for _, v := range a.Vendors {
+			if v != "" && strings.Contains(vendor, strings.ToLower(v)) {
+				return false
+			}
+		}
+		for _, g := range a.Groups {
+			if strings.EqualFold(d.Group, g) || d.HasTag(g) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// OfflineConfig holds how long devices may be missing from scans before
+// they are reported offline.
+type OfflineConfig struct {
+	// After is the grace period of every device without one of its own or
+	// of its group's. 0 reports a device at the first scan that misses it.
+	After time.Duration
+	// Groups holds the grace periods of groups, keyed by group name in
+	// lower case.
+	Groups map[string]time.Duration
+}
+
+// Grace returns what the store asks of each device with no grace period of
+// its own, how long it may be missing, or nil when every device is reported
+// at once.
+func (o OfflineConfig) Grace() func(*types.Device) time.Duration {
+	if o.After == 0 && len(o.Groups) == 0 {
+		return nil
+	}
+	return func(d *types.Device) time.Duration {
+		if after, ok := o.Groups[strings.ToLower(d.Group)]; ok && d.Group != "" {
+			return after
+		}
+		return o.After
+	}
+}
+
+// FormatGroups writes Groups as the groups setting reads them, in order of
+// group.
+func (o OfflineConfig) FormatGroups() string {
+	names := make([]string, 0, len(o.Groups))
+	for name := range o.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	items := make([]string, len(names))
+	for i, name := range names {
+		items[i] = name + "=" + query.FormatAge(o.Groups[name])
+	}
+	return strings.Join(items, ", ")
+}
+
+// parseGroupAges reads group grace periods written as Mobile=2h, NAS=10m.
+func parseGroupAges(value string) (map[string]time.Duration, error) {
+	groups := make(map[string]time.Duration)
+	for _, item := range splitList(value) {
+		name, age, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%q is not a group and how long, such as Mobile=2h", item)
+		}
+		d, err := query.ParseAge(strings.TrimSpace(age))
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %v", name, err)
+		}
+		groups[strings.ToLower(name)] = d
+	}
+	return groups, nil
+}
+
+// ReportsConfig holds the settings for writing the report that
+// `orangutan report` writes on a schedule.
+type ReportsConfig struct {
+	// Schedule is daily, weekly or a cron expression; empty writes none.
+	// Each report covers the time since the one before.
+	Schedule string
+	// Dir is where reports are written; empty means a reports directory
+	// beside the data. Use Config.ReportsDir.
+	Dir string
+	// Keep is how many of the newest reports to keep; 0 keeps them all.
+	Keep int
+}
+
+// WatchlistConfig holds the settings for pinging the devices on the
+// watchlist, those expected always to be online, between scans.
+type WatchlistConfig struct {
+	// Interval is how many seconds apart the pings are; 0 sends none.
+	Interval int
+	// Failures is how many pings in a row a device must miss to be
+	// reported offline.
+	Failures int
+}
+
+// VulnScanConfig holds the settings of deep scans, which run nmap's NSE
+// scripts against chosen devices to find known vulnerabilities and logins
+// left at their defaults.
+type VulnScanConfig struct {
+	// Enable lets the dashboard and the API start deep scans. The command
+	// line may start them either way.
+	Enable bool
+	// Scripts are the NSE scripts and categories deep scans run.
+	Scripts []string
+	// Ports are the ports deep scans look at, as nmap's -p takes them;
+	// empty means nmap's thousand most common.
+	Ports string
+	// Timeout is how many seconds a deep scan may take.
+	Timeout int
+}
+
+// CVEConfig holds the settings of CVE lookups, which match the services deep
+// scans identify against NVD's database of known vulnerabilities.
+type CVEConfig struct {
+	// Enable looks the services of each deep scan up when it finishes.
+	Enable bool
+	// URL is NVD's CVE API, or a mirror of it.
+	URL string
+	// APIKey is an NVD API key, which NVD answers ten times as fast.
+	APIKey string
+	// Cache is how long what NVD said of a product is used before it is
+	// asked again.
+	Cache time.Duration
+}
+
+// CredCheckConfig holds the settings of the default login check, which tries
+// the logins devices ship with against their admin interfaces.
+type CredCheckConfig struct {
+	// Enable lets 'orangutan credcheck' run. It is off until turned on, as
+	// trying logins on devices is for their owner to decide.
+	Enable bool
+	// Types are the device types checked when no devices are given.
+	Types []string
+	// Logins, written as user:password, replace the built-in lists when
+	// set.
+	Logins []string
+	// Delay is how many seconds to wait between two tries at one device.
+	Delay int
+}
+
+// CertsConfig holds the settings of the TLS certificate check, which fetches
+// the certificates devices serve and reports those about to expire.
+type CertsConfig struct {
+	// Interval is how often the server checks them; 0 never does.
+	Interval time.Duration
+	// Ports are tried on every device, besides those a deep scan found
+	// speaking TLS.
+	Ports []int
+	// Warn is how long before a certificate expires it is reported.
+	Warn time.Duration
+}
+
+// FingerprintConfig holds the settings of device fingerprints, which report
+// a device that has started to look like another.
+type FingerprintConfig struct {
+	// Interval is how often the server takes them; 0 never does.
+	Interval time.Duration
+	// MDNS asks the network which multicast DNS services each device
+	// advertises, as part of its fingerprint.
+	MDNS bool
+}
+
 // TailscaleConfig holds Tailscale integration settings
 type TailscaleConfig struct {
 	Enable     bool
 	AutoDetect bool
+
+	// Serve puts the dashboard on the tailnet as a node of its own, so it
+	// can be reached from anywhere on the tailnet without opening a port on
+	// the LAN. Hostname is the node's name there, AuthKey joins it without
+	// an interactive login, and HTTPS serves it on 443 with a certificate
+	// from the tailnet rather than plain HTTP on 80.
+	Serve    bool
+	Hostname string
+	AuthKey  string
+	HTTPS    bool
+}
+
+// MQTTConfig holds the settings for publishing device events and snapshots
+// to an MQTT broker.
+type MQTTConfig struct {
+	// Broker is the broker's address, such as tcp://192.168.1.5:1883 or
+	// mqtts://broker.example.com. Empty means nothing is published.
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+
+	// TopicPrefix starts every topic published to.
+	TopicPrefix string
+	// QoS is the quality of service to publish at: 0 or 1.
+	QoS    int
+	Retain bool
+	// SnapshotInterval is how many seconds apart to publish the whole device
+	// list. 0 means only events are published.
+	SnapshotInterval int
+
+	// HomeAssistant publishes Home Assistant discovery messages under
+	// DiscoveryPrefix, so that every device shows up there as a device
+	// tracker without any YAML.
+	HomeAssistant   bool
+	DiscoveryPrefix string
+}
+
+// MetricsConfig holds the settings for writing metrics for Prometheus.
+type MetricsConfig struct {
+	// Textfile is the file the server and monitor write metrics to, in the
+	// directory of node_exporter's textfile collector. Empty means none.
+	Textfile string
+	// TextfileInterval is how many seconds apart the file is written.
+	TextfileInterval int
+}
+
+// InfluxDBConfig holds the settings for writing each scan to InfluxDB.
+type InfluxDBConfig struct {
+	// URL is the address of InfluxDB, such as http://localhost:8086. Empty
+	// means nothing is written.
+	URL    string
+	Org    string
+	Bucket string
+	Token  string
+}
+
+// ZabbixConfig holds the settings for sending each scan to Zabbix.
+type ZabbixConfig struct {
+	// Server is the Zabbix server or proxy, as host or host:port. Empty
+	// means nothing is sent.
+	Server string
+	// Host is the name of the host in Zabbix the values are sent to.
+	Host string
+	// Register makes each device found a host of its own, through Zabbix's
+	// auto-registration, instead of an item of Host.
+	Register bool
+	// Metadata is the host metadata devices register with, for
+	// auto-registration actions to match.
+	Metadata string
+}
+
+// NotifyConfig holds the settings of one notifier, which sends alerts to a
+// chat or push service.
+type NotifyConfig struct {
+	// Type is the service: slack, discord, telegram, matrix, email, ntfy,
+	// gotify, pushover, webhook, syslog, journald, snmp or grafana.
+	Type string
+	// URL is the webhook alerts are posted to, or for ntfy the topic, for
+	// Matrix the homeserver, for Gotify and Grafana the server and for
+	// syslog the collector.
+	URL string
+	// Channel, for Slack, is the channel to post to instead of the
+	// webhook's own.
+	Channel string
+	// Username is the name alerts are posted under, or for email the name
+	// to log in to the mail server with.
+	Username string
+	// Token and ChatID, for Telegram, are the bot's token and the chat
+	// alerts go to. Token is also the access token for Matrix and ntfy, the
+	// application's for Gotify and Pushover, and the service account's for
+	// Grafana.
+	Token  string
+	ChatID string
+	// User, for Pushover, is the user or group key alerts go to.
+	User string
+	// Room, for Matrix, is the room alerts go to: its ID, such as
+	// !abc123:example.org, or an alias, such as #lan:example.org.
+	Room string
+	// Priority, for ntfy, Gotify and Pushover, is min, low, default, high
+	// or urgent.
+	Priority string
+	// Secret, for a webhook, signs each post with HMAC-SHA256.
+	// BodyTemplate is a file with the Go template to write the body with
+	// instead of the built-in JSON.
+	Secret       string
+	BodyTemplate string
+	// Facility, for syslog, is the facility alerts are logged to, such as
+	// daemon or local0.
+	Facility string
+	// Community, for SNMP, is the community string traps are sent with.
+	Community string
+	// Commands, for Telegram, has the bot answer questions about the
+	// devices asked in its chat.
+	Commands bool
+
+	// Host, Port and Security say how to reach the mail server for email:
+	// Security is starttls, tls or none, and Port 0 means the usual port
+	// for it. Password goes with Username.
+	Host     string
+	Port     int
+	Security string
+	Password string
+	From     string
+	To       []string
+
+	// Alerts, when false, sends the notifier only digests.
+	Alerts bool
+	// Digest, when set, sends a summary of what happened on a schedule:
+	// daily, weekly or a cron expression. DigestTemplate is a file with the
+	// Go template to write it with instead of the built-in one.
+	Digest         string
+	DigestTemplate string
+}
+
+// AlertConfig holds one alert rule: which events to alert about, for which
+// devices, and how.
+type AlertConfig struct {
+	// Events are new, offline, scan_failed, scan_completed, anomaly,
+	// arrival, departure, cert_expiring and intrusion. Empty means new and
+	// offline.
+	Events []string
+	// Devices and Groups limit the rule to the devices named, by address,
+	// MAC, label or hostname, and to the devices in the groups named. Empty
+	// means every device.
+	Devices []string
+	Groups  []string
+	// Notify names the notifiers to send the alert with. Empty means all.
+	Notify []string
+	// Template is the text of the alert in Go's text/template syntax. Empty
+	// means the event's own message.
+	Template string
+	// Escalate names the [escalation] section whose steps send the alert
+	// instead of Notify.
+	Escalate string
+}
+
+// EscalationConfig holds one escalation policy: who to tell about an alert,
+// and who next while nobody has acknowledged it.
+type EscalationConfig struct {
+	// Steps are in the order they are sent, the first usually at once.
+	Steps []alert.Step
+}
+
+// Policies returns the escalation policies as the alert engine takes them.
+func (c *Config) Policies() []alert.Policy {
+	var policies []alert.Policy
+	for _, name := range sortedKeys(c.Escalation) {
+		policies = append(policies, alert.Policy{Name: name, Steps: c.Escalation[name].Steps})
+	}
+	return policies
+}
+
+// FormatSteps writes steps as an escalation section's steps setting does.
+func FormatSteps(steps []alert.Step) string {
+	var items []string
+	for _, step := range steps {
+		for _, name := range step.Notify {
+			if step.After > 0 {
+				name += " after " + query.FormatAge(step.After)
+			}
+			items = append(items, name)
+		}
+	}
+	return strings.Join(items, ", ")
+}
+
+// parseSteps parses the steps of an escalation policy, written as
+// "phone, email after 30m, boss after 2h".
+func parseSteps(value string) ([]alert.Step, error) {
+	var steps []alert.Step
+	for _, item := range splitList(value) {
+		fields := strings.Fields(item)
+		var after time.Duration
+		switch {
+		case len(fields) == 1:
+		case len(fields) == 3 && strings.EqualFold(fields[1], "after"):
+			d, err := query.ParseAge(fields[2])
+			if err != nil {
+				return nil, err
+			}
+			after = d
+		default:
+			return nil, fmt.Errorf("%q is not a notifier, or a notifier after a time such as \"email after 30m\"", item)
+		}
+		name := strings.ToLower(fields[0])
+		if n := len(steps); n > 0 && steps[n-1].After == after {
+			steps[n-1].Notify = append(steps[n-1].Notify, name)
+			continue
+		}
+		steps = append(steps, alert.Step{After: after, Notify: []string{name}})
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("no steps")
+	}
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].After < steps[j].After })
+	return steps, nil
+}
+
+// MaintenanceConfig holds one maintenance window: when it opens, for how
+// long, and what it covers.
+type MaintenanceConfig struct {
+	// Schedule is a cron expression saying when it opens, and Duration how
+	// long it stays open.
+	Schedule string
+	Duration time.Duration
+	// Devices, by address, MAC, label or hostname, Groups and Networks are
+	// what it covers. All empty covers every device and network.
+	Devices  []string
+	Groups   []string
+	Networks []string
+}
+
+// Windows returns the maintenance windows as the alert engine takes them,
+// leaving out those whose schedule cannot be read, which Validate reports.
+func (c *Config) Windows() []alert.Window {
+	var windows []alert.Window
+	for _, name := range sortedKeys(c.Maintenance) {
+		m := c.Maintenance[name]
+		sched, err := schedule.Parse(m.Schedule)
+		if err != nil || m.Duration <= 0 {
+			continue
+		}
+		windows = append(windows, alert.Window{Name: name, Schedule: sched, Duration: m.Duration, Devices: m.Devices, Groups: m.Groups, Networks: m.Networks})
+	}
+	return windows
+}
+
+// PersonConfig holds the devices of one person, whose presence says whether
+// they are home.
+type PersonConfig struct {
+	// Name is the name to show, which the section's name stands in for
+	// when empty: section names are read in lower case.
+	Name string
+	// MACs are the MAC addresses of their phone, watch and so on.
+	MACs []string
+	// AwayAfter is how long none of their devices may have been seen
+	// before they count as away.
+	AwayAfter time.Duration
+}
+
+// defaultAwayAfter rides out a phone asleep on Wi-Fi missing a scan or two.
+const defaultAwayAfter = 10 * time.Minute
+
+// People returns the people of the [person] sections as the store takes
+// them, in order of name.
+func (c *Config) People() []types.Person {
+	people := make([]types.Person, 0, len(c.Person))
+	for _, key := range sortedKeys(c.Person) {
+		p := c.Person[key]
+		name := p.Name
+		if name == "" {
+			name = key
+		}
+		people = append(people, types.Person{Name: name, MACs: p.MACs, AwayAfter: p.AwayAfter})
+	}
+	return people
+}
+
+// RestrictedConfig holds the settings of a restricted zone, a network or
+// group only some devices may be on, such as the VLAN of the cameras.
+type RestrictedConfig struct {
+	// Networks, in CIDR notation, and Groups are what it covers.
+	Networks []string
+	Groups   []string
+	// Allowed are the devices allowed there, by MAC, or by address for a
+	// network scanned from elsewhere, where no MACs are seen.
+	Allowed []string
+}
+
+// RestrictedZones returns the zones of the [restricted] sections as the
+// store takes them, in order of name.
+func (c *Config) RestrictedZones() []types.Restricted {
+	zones := make([]types.Restricted, 0, len(c.Restricted))
+	for _, name := range sortedKeys(c.Restricted) {
+		r := c.Restricted[name]
+		zones = append(zones, types.Restricted{Name: name, Networks: r.Networks, Groups: r.Groups, Allowed: r.Allowed})
+	}
+	return zones
+}
+
+// PiholeConfig holds the settings for asking a Pi-hole about the devices
+// on each network scanned.
+type PiholeConfig struct {
+	// URL is the address of the Pi-hole, such as http://pi.hole. Empty means
+	// it is not asked.
+	URL   string
+	Token string
+	// GroupTags tags devices with the Pi-hole groups their clients are in.
+	GroupTags bool
+}
+
+// AdGuardConfig holds the settings for asking AdGuard Home about the
+// devices on each network scanned.
+type AdGuardConfig struct {
+	// URL is the address of its web interface, such as
+	// http://192.168.1.2:3000. Empty means it is not asked.
+	URL      string
+	Username string
+	Password string
+	// ClientTags tags devices with the tags of their AdGuard Home clients.
+	ClientTags bool
+}
+
+// OpenWrtConfig holds the settings for asking an OpenWrt router about the
+// devices on each network scanned, and how they are on its Wi-Fi.
+type OpenWrtConfig struct {
+	// URL is the address of its web interface, such as http://192.168.1.1.
+	// Empty means it is not asked.
+	URL      string
+	Username string
+	Password string
+}
+
+// FirewallConfig holds the settings for reading the ARP table and DHCP
+// leases of an OPNsense or pfSense firewall.
+type FirewallConfig struct {
+	// Type is opnsense or pfsense.
+	Type string
+	// URL is the address of the firewall, such as https://192.168.1.1.
+	// Empty means there is none to ask.
+	URL    string
+	Key    string
+	Secret string
+	// CAFile names PEM certificates to trust for the firewall, and
+	// TLSVerify, when false, trusts whatever certificate it has.
+	CAFile    string
+	TLSVerify bool
+}
+
+// DockerConfig holds the settings for naming containers from the local
+// Docker engine.
+type DockerConfig struct {
+	// Enable has scans ask Docker about the containers on each network.
+	Enable bool
+	// Socket is the Unix socket the Docker daemon listens on.
+	Socket string
+}
+
+// KubernetesConfig holds the settings for telling the nodes of a
+// Kubernetes cluster apart among the devices.
+type KubernetesConfig struct {
+	// Enable has scans ask the cluster about its nodes.
+	Enable bool
+	// Kubeconfig is the kubeconfig file to read, and Context the context in
+	// it. Empty means as kubectl would.
+	Kubeconfig string
+	Context    string
+}
+
+// DNSConfig holds the settings for naming labelled devices in local DNS.
+type DNSConfig struct {
+	// Domain is the domain the names are in, such as lan or home.arpa.
+	Domain string
+	// DnsmasqFile is a dnsmasq include file to rewrite with the names after
+	// each scan, such as /etc/dnsmasq.d/orangutan.conf. Empty means none.
+	DnsmasqFile string
 }
 
 // UIConfig holds user interface settings
 type UIConfig struct {
 	Theme string
+
+	// Language is a language code such as "en" or "de", or "auto" to follow
+	// each browser's Accept-Language header.
+	Language string
 }
 
 // Default returns a Config with default values
@@ -150,48 +938,297 @@ func Default() *Config {
 			// a server or a Pi and opened from another machine. Safety comes
 			// from RequiresSetup: with no password set, the only thing a
 			// visitor can reach is the page that creates one.
-			BindAddress:  "0.0.0.0",
-			EnableAPI:    true,
-			SessionHours: 24 * 7,
+			BindAddress:      "0.0.0.0",
+			EnableAPI:        true,
+			SessionHours:     24 * 7,
+			UnprivilegedPort: 8291,
+			MDNS:             true,
+			MDNSName:         "orangutan",
 		},
 		Scanning: ScanningConfig{
 			ScanInterval:    300,
 			MinScanInterval: 30,
+			ScanTimeout:     300,
 			EnablePortScan:  false,
 			PortScanRange:   "1-1024",
+			Privileged:      "auto",
+			Backends:        append([]string(nil), scanner.DefaultBackends...),
 		},
 		Storage: StorageConfig{
 			MaxDevices:    1000,
 			RetentionDays: 90,
 			DataDir:       GetDefaultDataDir(),
 		},
+		Approval: ApprovalConfig{
+			Enable: true,
+		},
+		Watchlist: WatchlistConfig{
+			Interval: 30,
+			Failures: 2,
+		},
+		VulnScan: VulnScanConfig{
+			Scripts: append([]string(nil), scanner.DefaultDeepScanScripts...),
+			Timeout: 1800,
+		},
+		CVE: CVEConfig{
+			URL:   cve.DefaultURL,
+			Cache: cve.DefaultMaxAge,
+		},
+		CredCheck: CredCheckConfig{
+			Types: append([]string(nil), credcheck.DefaultTypes...),
+			Delay: int(credcheck.DefaultDelay / time.Second),
+		},
+		Certs: CertsConfig{
+			Interval: tlscert.DefaultInterval,
+			Ports:    append([]int(nil), tlscert.DefaultPorts...),
+			Warn:     tlscert.DefaultWarn,
+		},
+		Fingerprint: FingerprintConfig{
+			Interval: fingerprint.DefaultInterval,
+			MDNS:     true,
+		},
 		Tailscale: TailscaleConfig{
 			Enable:     true,
 			AutoDetect: true,
+			Hostname:   "orangutan",
+			HTTPS:      true,
 		},
 		UI: UIConfig{
-			Theme: "auto",
+			Theme:    "auto",
+			Language: "auto",
+		},
+		MQTT: MQTTConfig{
+			TopicPrefix:      "lan-orangutan",
+			SnapshotInterval: 300,
+			DiscoveryPrefix:  "homeassistant",
+		},
+		LDAP: LDAPConfig{
+			UserAttribute:  ldap.DefaultUserAttribute,
+			GroupAttribute: ldap.DefaultGroupAttribute,
+			TLSVerify:      true,
+		},
+		OIDC: OIDCConfig{
+			Scopes:        oidc.DefaultScopes,
+			UsernameClaim: oidc.DefaultUsernameClaim,
+			GroupsClaim:   oidc.DefaultGroupsClaim,
+		},
+		Roles: RolesConfig{
+			Default: "none",
+		},
+		Zabbix: ZabbixConfig{
+			Host:     "LAN Orangutan",
+			Metadata: "lan-orangutan",
+		},
+		Firewall: FirewallConfig{
+			TLSVerify: true,
+		},
+		Metrics: MetricsConfig{
+			TextfileInterval: 60,
+		},
+		DNS: DNSConfig{
+			Domain: "lan",
+		},
+		Docker: DockerConfig{
+			Socket: docker.DefaultSocket,
 		},
 	}
 }
 
-// Load reads configuration from an INI file
+// Load reads configuration from a config file: YAML for a path ending in
+// .yaml or .yml, TOML for .toml, and INI for anything else. The formats hold
+// the same sections and settings and mean the same by them.
+//
+// Settings that cannot be understood are skipped so that one typo does not
+// stop the app from starting; Check reports them.
 func Load(path string) (*Config, error) {
+	cfg, _, err := load(path)
+	return cfg, err
+}
+
+// Format returns the format Load reads the config file at path in, by its
+// extension: "yaml", "toml" or "ini".
+func Format(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".toml":
+		return "toml"
+	}
+	return "ini"
+}
+
+// entry is one line of a config file, in any of its formats: a section
+// heading, a setting, or a line that could not be read at all.
+type entry struct {
+	line    int
+	section string
+	// header marks the start of section rather than a setting in it.
+	header     bool
+	key, value string
+	// problem, when set, describes a line that could not be read.
+	problem string
+}
+
+// load reads the config file at path and then its drop-ins, returning the
+// settings along with a description of each line that could not be applied.
+func load(path string) (*Config, []string, error) {
 	cfg := Default()
+	problems, err := cfg.apply(path, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, dropIn := range DropIns(path) {
+		more, err := cfg.apply(dropIn, DropInDir+"/"+filepath.Base(dropIn))
+		if err != nil {
+			return nil, nil, err
+		}
+		problems = append(problems, more...)
+	}
+	return cfg, problems, nil
+}
+
+// DropInDir is the directory beside the config file whose files are read
+// after it, each overriding what came before.
+const DropInDir = "config.d"
 
-	file, err := os.Open(path)
+// DropIns returns the drop-in files of the config file at path in the order
+// they are read: those in DropInDir with an extension Load knows, sorted by
+// name, so 10-package.ini is overridden by 50-local.ini. Anything else there,
+// such as the .dpkg-old and .bak files packaging and editors leave behind, is
+// ignored.
+func DropIns(path string) []string {
+	dir := filepath.Join(filepath.Dir(path), DropInDir)
+	entries, err := os.ReadDir(dir) // Sorted by name
 	if err != nil {
-		if os.IsNotExist(err) {
-			return cfg, nil // Return defaults if file doesn't exist
+		return nil
+	}
+	var files []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".ini", ".yaml", ".yml", ".toml":
+			files = append(files, filepath.Join(dir, name))
 		}
-		return nil, fmt.Errorf("failed to open config: %w", err)
 	}
-	defer file.Close()
+	return files
+}
 
+// apply reads the config file at path into c. name is how its problems are
+// introduced, or empty for the main config file, whose lines need no
+// introduction; a main file that does not exist leaves the defaults.
+func (c *Config) apply(path, name string) ([]string, error) {
+	label, kind := "config", "file"
+	if name != "" {
+		label, kind = name, "drop-in"
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && name == "" {
+			return nil, nil // Return defaults if file doesn't exist
+		}
+		return nil, fmt.Errorf("failed to open %s: %w", label, err)
+	}
+
+	var entries []entry
+	switch Format(path) {
+	case "yaml":
+		entries, err = parseYAML(data)
+	case "toml":
+		entries = parseTOML(data)
+	default:
+		entries, err = parseINI(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", label, err)
+	}
+
+	var problems []string
+	add := func(format string, args ...any) {
+		if name != "" {
+			format = "%s: " + format
+			args = append([]any{name}, args...)
+		}
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	exposed := readableByOthers(path)
+	for _, e := range entries {
+		switch {
+		case e.problem != "":
+			add("line %d: %s", e.line, e.problem)
+		case e.header:
+			if cidr, ok := networkSection(e.section); ok {
+				if !network.ValidateCIDR(cidr) {
+					add("line %d: [%s]: %q is not a CIDR such as 192.168.1.0/24", e.line, e.section, cidr)
+				}
+			} else if name, ok := profileSection(e.section); ok {
+				if name == "" {
+					add("line %d: [%s]: a profile needs a name, such as [profile \"home\"]", e.line, e.section)
+				} else {
+					c.addProfile(name)
+				}
+			} else if name, kind, ok := alertingSection(e.section); ok {
+				if name == "" {
+					add("line %d: [%s]: a %s section needs a name, such as [%s \"team\"]", e.line, e.section, kind, kind)
+				}
+			} else if name, ok := personSection(e.section); ok {
+				if name == "" {
+					add("line %d: [%s]: a person section needs a name, such as [person \"alice\"]", e.line, e.section)
+				}
+			} else if name, ok := restrictedSection(e.section); ok {
+				if name == "" {
+					add("line %d: [%s]: a restricted section needs a name, such as [restricted \"cameras\"]", e.line, e.section)
+				}
+			} else if !knownSections[e.section] {
+				add("line %d: unknown section [%s]", e.line, e.section)
+			}
+		case isProfileSection(e.section):
+			name, _ := profileSection(e.section)
+			if name == "" {
+				continue // Reported with the section
+			}
+			if err := c.setProfileValue(name, e.key, e.value, fmt.Sprintf("%s %s:%d", kind, path, e.line)); err != nil {
+				add("line %d: %s: %v", e.line, e.key, err)
+			}
+		default:
+			value := e.value
+			if isSecretKey(e.section, e.key) && value != "" {
+				secret, literal, err := resolveSecret(value, filepath.Dir(path))
+				// Unlike a bad setting, this is not skipped: going on without
+				// the password would leave the dashboard to whoever reaches
+				// the setup page first.
+				if err != nil {
+					return nil, fmt.Errorf("failed to read %s: line %d: %s: %w", label, e.line, e.key, err)
+				}
+				// A bcrypt hash is made to be stored; the secret itself is not.
+				if literal && exposed && !strings.HasPrefix(value, "$2") {
+					add("line %d: %s is written out in a file other users can read; use file: or env: instead", e.line, e.key)
+				}
+				value = secret
+			}
+			// A key in an unknown section has already been reported with it.
+			if err := c.setValue(e.section, e.key, value); err != nil {
+				if knownSection(e.section) {
+					add("line %d: %s: %v", e.line, e.key, err)
+				}
+			} else {
+				c.SetSource(sourceKey(e.section, e.key), fmt.Sprintf("%s %s:%d", kind, path, e.line))
+			}
+		}
+	}
+	return problems, nil
+}
+
+// parseINI reads the INI format: [section] headings, key = value lines, and
+// comments starting with # or ;. Values are taken as they are, unquoted.
+func parseINI(data []byte) ([]entry, error) {
+	var entries []entry
 	var currentSection string
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
 
-	for scanner.Scan() {
+	for lineNo := 1; scanner.Scan(); lineNo++ {
 		line := strings.TrimSpace(scanner.Text())
 
 		// Skip empty lines and comments
@@ -202,93 +1239,943 @@ func Load(path string) (*Config, error) {
 		// Section header
 		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
 			currentSection = strings.ToLower(line[1 : len(line)-1])
+			entries = append(entries, entry{line: lineNo, section: currentSection, header: true})
 			continue
 		}
 
 		// Key-value pair
 		parts := strings.SplitN(line, "=", 2)
 		if len(parts) != 2 {
+			entries = append(entries, entry{line: lineNo, problem: fmt.Sprintf("expected key = value, got %q", line)})
 			continue
 		}
 
-		key := strings.TrimSpace(strings.ToLower(parts[0]))
-		value := strings.TrimSpace(parts[1])
+		entries = append(entries, entry{
+			line:    lineNo,
+			section: currentSection,
+			key:     strings.TrimSpace(strings.ToLower(parts[0])),
+			value:   strings.TrimSpace(parts[1]),
+		})
+	}
+	return entries, scanner.Err()
+}
 
-		cfg.setValue(currentSection, key, value)
+// knownSections are the sections setValue understands.
+var knownSections = map[string]bool{
+	"server": true, "ldap": true, "oidc": true, "roles": true,
+	"scanning": true, "storage": true, "approval": true, "offline": true, "reports": true, "watchlist": true, "vulnscan": true, "cve": true, "credcheck": true, "certs": true, "fingerprint": true, "tailscale": true, "ui": true,
+	"mqtt": true, "metrics": true, "influxdb": true, "zabbix": true, "pihole": true, "firewall": true,
+	"adguard": true, "openwrt": true, "dns": true, "docker": true, "kubernetes": true,
+}
+
+// knownSection reports whether setValue understands section, which is one
+// of knownSections or a network section naming a valid CIDR.
+func knownSection(section string) bool {
+	if cidr, ok := networkSection(section); ok {
+		return network.ValidateCIDR(cidr)
+	}
+	if name, _, ok := alertingSection(section); ok {
+		return name != ""
+	}
+	if name, ok := personSection(section); ok {
+		return name != ""
 	}
+	if name, ok := restrictedSection(section); ok {
+		return name != ""
+	}
+	return knownSections[section]
+}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("failed to read config: %w", err)
+// alertingSection returns the name and kind of a section such as
+// notify "team", alert "servers", maintenance "lab weekends" or
+// escalation "critical".
+func alertingSection(section string) (name, kind string, ok bool) {
+	for _, kind := range []string{"notify", "alert", "maintenance", "escalation"} {
+		if name, ok := namedSection(section, kind); ok {
+			return name, kind, true
+		}
 	}
+	return "", "", false
+}
+
+// personSection returns the name of a section such as person "alice".
+func personSection(section string) (name string, ok bool) {
+	return namedSection(section, "person")
+}
+
+// restrictedSection returns the name of a section such as
+// restricted "cameras".
+func restrictedSection(section string) (name string, ok bool) {
+	return namedSection(section, "restricted")
+}
+
+// networkSection returns the CIDR of a section such as network "10.0.0.0/8",
+// or network."10.0.0.0/8" as TOML writes it.
+func networkSection(section string) (cidr string, ok bool) {
+	return namedSection(section, "network")
+}
 
-	return cfg, nil
+// namedSection returns the name in quotes of a section such as
+// kind "name", or kind."name" as TOML writes it.
+func namedSection(section, kind string) (name string, ok bool) {
+	rest, found := strings.CutPrefix(section, kind)
+	if !found {
+		return "", false
+	}
+	rest = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest), "."))
+	if len(rest) < 2 || (rest[0] != '"' && rest[0] != '\'') || rest[len(rest)-1] != rest[0] {
+		return "", false
+	}
+	return strings.TrimSpace(rest[1 : len(rest)-1]), true
 }
 
-// setValue sets a configuration value based on section and key
-func (c *Config) setValue(section, key, value string) {
+// networkKey returns cidr in the form Config.Network is keyed by, so that
+// 192.168.1.1/24 in the config file means the same as 192.168.1.0/24.
+func networkKey(cidr string) string {
+	_, ipNet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+	if err != nil {
+		return cidr
+	}
+	return ipNet.String()
+}
+
+// errUnknownKey is returned by setValue for a key it does not recognise.
+var errUnknownKey = errors.New("unknown setting")
+
+// setValue sets a configuration value based on section and key. It returns
+// an error, leaving the setting alone, when the key is unknown or the value
+// cannot be used.
+func (c *Config) setValue(section, key, value string) error {
 	switch section {
 	case "server":
 		switch key {
 		case "port":
-			if v, err := strconv.Atoi(value); err == nil {
-				c.Server.Port = v
-			}
+			return setInt(&c.Server.Port, value)
 		case "bind_address":
 			c.Server.BindAddress = value
 		case "enable_api":
-			c.Server.EnableAPI = parseBool(value)
+			return setBool(&c.Server.EnableAPI, value)
 		case "password":
 			c.Server.Password = value
+		case "username":
+			c.Server.Username = value
+		case "api_token":
+			c.Server.APIToken = value
 		case "session_hours":
-			if v, err := strconv.Atoi(value); err == nil {
-				c.Server.SessionHours = v
-			}
+			return setInt(&c.Server.SessionHours, value)
 		case "allow_insecure":
-			c.Server.AllowInsecure = parseBool(value)
+			return setBool(&c.Server.AllowInsecure, value)
+		case "unprivileged_port":
+			return setInt(&c.Server.UnprivilegedPort, value)
+		case "mdns":
+			return setBool(&c.Server.MDNS, value)
+		case "mdns_name":
+			c.Server.MDNSName = value
+		default:
+			return errUnknownKey
+		}
+	case "ldap":
+		switch key {
+		case "url":
+			c.LDAP.URL = value
+		case "bind_dn":
+			c.LDAP.BindDN = value
+		case "bind_password":
+			c.LDAP.BindPassword = value
+		case "base_dn":
+			c.LDAP.BaseDN = value
+		case "user_attribute":
+			c.LDAP.UserAttribute = value
+		case "group_attribute":
+			c.LDAP.GroupAttribute = value
+		case "group_base_dn":
+			c.LDAP.GroupBaseDN = value
+		case "start_tls":
+			return setBool(&c.LDAP.StartTLS, value)
+		case "tls_verify":
+			return setBool(&c.LDAP.TLSVerify, value)
+		case "ca_file":
+			c.LDAP.CAFile = value
+		default:
+			return errUnknownKey
+		}
+	case "oidc":
+		switch key {
+		case "issuer":
+			c.OIDC.Issuer = value
+		case "client_id":
+			c.OIDC.ClientID = value
+		case "client_secret":
+			c.OIDC.ClientSecret = value
+		case "redirect_url":
+			c.OIDC.RedirectURL = value
+		case "scopes":
+			c.OIDC.Scopes = strings.Fields(strings.ReplaceAll(value, ",", " "))
+		case "username_claim":
+			c.OIDC.UsernameClaim = value
+		case "groups_claim":
+			c.OIDC.GroupsClaim = value
+		case "name":
+			c.OIDC.Name = value
+		default:
+			return errUnknownKey
+		}
+	case "roles":
+		switch key {
+		case "admin":
+			c.Roles.Admin = splitList(value)
+		case "editor":
+			c.Roles.Editor = splitList(value)
+		case "viewer":
+			c.Roles.Viewer = splitList(value)
+		case "default":
+			if _, err := auth.ParseRole(value); err != nil {
+				return err
+			}
+			c.Roles.Default = strings.ToLower(value)
+		default:
+			return errUnknownKey
 		}
 	case "scanning":
 		switch key {
 		case "scan_interval":
-			if v, err := strconv.Atoi(value); err == nil {
-				c.Scanning.ScanInterval = v
-			}
+			return setInt(&c.Scanning.ScanInterval, value)
 		case "min_scan_interval":
-			if v, err := strconv.Atoi(value); err == nil {
-				c.Scanning.MinScanInterval = v
-			}
+			return setInt(&c.Scanning.MinScanInterval, value)
+		case "scan_timeout":
+			return setInt(&c.Scanning.ScanTimeout, value)
 		case "enable_port_scan":
-			c.Scanning.EnablePortScan = parseBool(value)
+			return setBool(&c.Scanning.EnablePortScan, value)
 		case "port_scan_range":
 			c.Scanning.PortScanRange = value
 		case "networks":
 			c.Scanning.Networks = network.ParseNetworkList(value)
+		case "schedule":
+			c.Scanning.Schedule = value
+		case "privileged":
+			c.Scanning.Privileged = strings.ToLower(value)
+		case "backends":
+			c.Scanning.Backends = network.ParseNetworkList(strings.ToLower(value))
+		default:
+			return errUnknownKey
 		}
 	case "storage":
 		switch key {
 		case "max_devices":
-			if v, err := strconv.Atoi(value); err == nil {
-				c.Storage.MaxDevices = v
-			}
+			return setInt(&c.Storage.MaxDevices, value)
 		case "retention_days":
-			if v, err := strconv.Atoi(value); err == nil {
-				c.Storage.RetentionDays = v
-			}
+			return setInt(&c.Storage.RetentionDays, value)
 		case "data_dir":
 			c.Storage.DataDir = value
+		default:
+			return errUnknownKey
+		}
+	case "approval":
+		switch key {
+		case "enable":
+			return setBool(&c.Approval.Enable, value)
+		case "auto_approve_vendors":
+			c.Approval.Vendors = splitList(value)
+		case "auto_approve_groups":
+			c.Approval.Groups = splitList(value)
+		default:
+			return errUnknownKey
+		}
+	case "offline":
+		switch key {
+		case "after":
+			after, err := query.ParseAge(value)
+			if err != nil {
+				return err
+			}
+			c.Offline.After = after
+		case "groups":
+			groups, err := parseGroupAges(value)
+			if err != nil {
+				return err
+			}
+			c.Offline.Groups = groups
+		default:
+			return errUnknownKey
+		}
+	case "reports":
+		switch key {
+		case "schedule":
+			c.Reports.Schedule = value
+		case "dir":
+			c.Reports.Dir = value
+		case "keep":
+			return setInt(&c.Reports.Keep, value)
+		default:
+			return errUnknownKey
+		}
+	case "watchlist":
+		switch key {
+		case "interval":
+			return setInt(&c.Watchlist.Interval, value)
+		case "failures":
+			return setInt(&c.Watchlist.Failures, value)
+		default:
+			return errUnknownKey
+		}
+	case "vulnscan":
+		switch key {
+		case "enable":
+			return setBool(&c.VulnScan.Enable, value)
+		case "scripts":
+			c.VulnScan.Scripts = splitList(value)
+		case "ports":
+			c.VulnScan.Ports = strings.ReplaceAll(value, " ", "")
+		case "timeout":
+			return setInt(&c.VulnScan.Timeout, value)
+		default:
+			return errUnknownKey
+		}
+	case "cve":
+		switch key {
+		case "enable":
+			return setBool(&c.CVE.Enable, value)
+		case "url":
+			c.CVE.URL = strings.TrimRight(value, "/")
+		case "api_key":
+			c.CVE.APIKey = value
+		case "cache":
+			cache, err := query.ParseAge(value)
+			if err != nil {
+				return err
+			}
+			c.CVE.Cache = cache
+		default:
+			return errUnknownKey
+		}
+	case "credcheck":
+		switch key {
+		case "enable":
+			return setBool(&c.CredCheck.Enable, value)
+		case "types":
+			c.CredCheck.Types = splitList(value)
+		case "logins":
+			c.CredCheck.Logins = splitList(value)
+		case "delay":
+			return setInt(&c.CredCheck.Delay, value)
+		default:
+			return errUnknownKey
+		}
+	case "certs":
+		switch key {
+		case "interval", "warn":
+			d, err := query.ParseAge(value)
+			if err != nil {
+				return err
+			}
+			if key == "interval" {
+				c.Certs.Interval = d
+			} else {
+				c.Certs.Warn = d
+			}
+		case "ports":
+			ports, err := tlscert.ParsePorts(splitList(value))
+			if err != nil {
+				return err
+			}
+			c.Certs.Ports = ports
+		default:
+			return errUnknownKey
+		}
+	case "fingerprint":
+		switch key {
+		case "interval":
+			d, err := query.ParseAge(value)
+			if err != nil {
+				return err
+			}
+			c.Fingerprint.Interval = d
+		case "mdns":
+			return setBool(&c.Fingerprint.MDNS, value)
+		default:
+			return errUnknownKey
 		}
 	case "tailscale":
 		switch key {
 		case "enable":
-			c.Tailscale.Enable = parseBool(value)
+			return setBool(&c.Tailscale.Enable, value)
 		case "auto_detect":
-			c.Tailscale.AutoDetect = parseBool(value)
+			return setBool(&c.Tailscale.AutoDetect, value)
+		case "serve":
+			return setBool(&c.Tailscale.Serve, value)
+		case "hostname":
+			c.Tailscale.Hostname = value
+		case "auth_key":
+			c.Tailscale.AuthKey = value
+		case "https":
+			return setBool(&c.Tailscale.HTTPS, value)
+		default:
+			return errUnknownKey
 		}
 	case "ui":
 		switch key {
 		case "theme":
 			c.UI.Theme = value
+		case "language":
+			c.UI.Language = value
+		default:
+			return errUnknownKey
+		}
+	case "metrics":
+		switch key {
+		case "textfile":
+			c.Metrics.Textfile = value
+		case "textfile_interval":
+			return setInt(&c.Metrics.TextfileInterval, value)
+		default:
+			return errUnknownKey
+		}
+	case "influxdb":
+		switch key {
+		case "url":
+			c.InfluxDB.URL = value
+		case "org":
+			c.InfluxDB.Org = value
+		case "bucket":
+			c.InfluxDB.Bucket = value
+		case "token":
+			c.InfluxDB.Token = value
+		default:
+			return errUnknownKey
+		}
+	case "zabbix":
+		switch key {
+		case "server":
+			c.Zabbix.Server = value
+		case "host":
+			c.Zabbix.Host = value
+		case "register":
+			return setBool(&c.Zabbix.Register, value)
+		case "metadata":
+			c.Zabbix.Metadata = value
+		default:
+			return errUnknownKey
+		}
+	case "pihole":
+		switch key {
+		case "url":
+			c.Pihole.URL = value
+		case "token":
+			c.Pihole.Token = value
+		case "group_tags":
+			return setBool(&c.Pihole.GroupTags, value)
+		default:
+			return errUnknownKey
+		}
+	case "adguard":
+		switch key {
+		case "url":
+			c.AdGuard.URL = value
+		case "username":
+			c.AdGuard.Username = value
+		case "password":
+			c.AdGuard.Password = value
+		case "client_tags":
+			return setBool(&c.AdGuard.ClientTags, value)
+		default:
+			return errUnknownKey
+		}
+	case "openwrt":
+		switch key {
+		case "url":
+			c.OpenWrt.URL = value
+		case "username":
+			c.OpenWrt.Username = value
+		case "password":
+			c.OpenWrt.Password = value
+		default:
+			return errUnknownKey
+		}
+	case "firewall":
+		switch key {
+		case "type":
+			c.Firewall.Type = strings.ToLower(value)
+		case "url":
+			c.Firewall.URL = value
+		case "key":
+			c.Firewall.Key = value
+		case "secret":
+			c.Firewall.Secret = value
+		case "ca_file":
+			c.Firewall.CAFile = value
+		case "tls_verify":
+			return setBool(&c.Firewall.TLSVerify, value)
+		default:
+			return errUnknownKey
+		}
+	case "docker":
+		switch key {
+		case "enable":
+			return setBool(&c.Docker.Enable, value)
+		case "socket":
+			c.Docker.Socket = value
+		default:
+			return errUnknownKey
+		}
+	case "kubernetes":
+		switch key {
+		case "enable":
+			return setBool(&c.Kubernetes.Enable, value)
+		case "kubeconfig":
+			c.Kubernetes.Kubeconfig = value
+		case "context":
+			c.Kubernetes.Context = value
+		default:
+			return errUnknownKey
 		}
+	case "dns":
+		switch key {
+		case "domain":
+			c.DNS.Domain = strings.Trim(value, ".")
+		case "dnsmasq_file":
+			c.DNS.DnsmasqFile = value
+		default:
+			return errUnknownKey
+		}
+	case "mqtt":
+		switch key {
+		case "broker":
+			c.MQTT.Broker = value
+		case "client_id":
+			c.MQTT.ClientID = value
+		case "username":
+			c.MQTT.Username = value
+		case "password":
+			c.MQTT.Password = value
+		case "topic_prefix":
+			c.MQTT.TopicPrefix = value
+		case "qos":
+			return setInt(&c.MQTT.QoS, value)
+		case "retain":
+			return setBool(&c.MQTT.Retain, value)
+		case "snapshot_interval":
+			return setInt(&c.MQTT.SnapshotInterval, value)
+		case "homeassistant":
+			return setBool(&c.MQTT.HomeAssistant, value)
+		case "discovery_prefix":
+			c.MQTT.DiscoveryPrefix = value
+		default:
+			return errUnknownKey
+		}
+	default:
+		if cidr, ok := networkSection(section); ok && network.ValidateCIDR(cidr) {
+			return c.setNetworkValue(networkKey(cidr), key, value)
+		}
+		if name, kind, ok := alertingSection(section); ok && name != "" {
+			switch kind {
+			case "notify":
+				return c.setNotifyValue(name, key, value)
+			case "maintenance":
+				return c.setMaintenanceValue(name, key, value)
+			case "escalation":
+				return c.setEscalationValue(name, key, value)
+			}
+			return c.setAlertValue(name, key, value)
+		}
+		if name, ok := personSection(section); ok && name != "" {
+			return c.setPersonValue(name, key, value)
+		}
+		if name, ok := restrictedSection(section); ok && name != "" {
+			return c.setRestrictedValue(name, key, value)
+		}
+		return errUnknownKey
 	}
+	return nil
+}
+
+// setNetworkValue sets a value in the section of the network cidr.
+func (c *Config) setNetworkValue(cidr, key, value string) error {
+	n := c.Network[cidr]
+	if n == nil {
+		n = &NetworkConfig{Enable: true}
+	}
+	switch key {
+	case "scan_interval":
+		if err := setInt(&n.ScanInterval, value); err != nil {
+			return err
+		}
+	case "schedule":
+		n.Schedule = value
+	case "profile":
+		n.Profile = strings.ToLower(value)
+	case "exclude":
+		n.Exclude = network.ParseNetworkList(value)
+	case "enable":
+		if err := setBool(&n.Enable, value); err != nil {
+			return err
+		}
+	default:
+		return errUnknownKey
+	}
+	if c.Network == nil {
+		c.Network = make(map[string]*NetworkConfig)
+	}
+	c.Network[cidr] = n
+	return nil
+}
+
+// setNotifyValue sets a value in the section of the notifier name.
+func (c *Config) setNotifyValue(name, key, value string) error {
+	n := c.Notify[name]
+	if n == nil {
+		n = &NotifyConfig{Alerts: true}
+	}
+	switch key {
+	case "type":
+		n.Type = strings.ToLower(value)
+	case "url":
+		n.URL = value
+	case "channel":
+		n.Channel = value
+	case "username":
+		n.Username = value
+	case "token":
+		n.Token = value
+	case "chat_id":
+		n.ChatID = value
+	case "user":
+		n.User = value
+	case "room":
+		n.Room = value
+	case "priority":
+		n.Priority = strings.ToLower(value)
+	case "secret":
+		n.Secret = value
+	case "body_template":
+		n.BodyTemplate = value
+	case "facility":
+		n.Facility = strings.ToLower(value)
+	case "community":
+		n.Community = value
+	case "commands":
+		if err := setBool(&n.Commands, value); err != nil {
+			return err
+		}
+	case "host":
+		n.Host = value
+	case "port":
+		if err := setInt(&n.Port, value); err != nil {
+			return err
+		}
+	case "security":
+		n.Security = strings.ToLower(value)
+	case "password":
+		n.Password = value
+	case "from":
+		n.From = value
+	case "to":
+		n.To = splitList(value)
+	case "alerts":
+		if err := setBool(&n.Alerts, value); err != nil {
+			return err
+		}
+	case "digest":
+		n.Digest = value
+	case "digest_template":
+		n.DigestTemplate = value
+	default:
+		return errUnknownKey
+	}
+	if c.Notify == nil {
+		c.Notify = make(map[string]*NotifyConfig)
+	}
+	c.Notify[name] = n
+	return nil
+}
+
+// setAlertValue sets a value in the section of the alert rule name.
+func (c *Config) setAlertValue(name, key, value string) error {
+	a := c.Alert[name]
+	if a == nil {
+		a = &AlertConfig{}
+	}
+	switch key {
+	case "events":
+		a.Events = network.ParseNetworkList(strings.ToLower(value))
+	case "devices":
+		a.Devices = splitList(value)
+	case "groups":
+		a.Groups = splitList(value)
+	case "notify":
+		a.Notify = network.ParseNetworkList(strings.ToLower(value))
+	case "template":
+		a.Template = value
+	case "escalate":
+		a.Escalate = strings.ToLower(strings.TrimSpace(value))
+	default:
+		return errUnknownKey
+	}
+	if c.Alert == nil {
+		c.Alert = make(map[string]*AlertConfig)
+	}
+	c.Alert[name] = a
+	return nil
+}
+
+// setEscalationValue sets a value in the section of the escalation policy
+// name.
+func (c *Config) setEscalationValue(name, key, value string) error {
+	e := c.Escalation[name]
+	if e == nil {
+		e = &EscalationConfig{}
+	}
+	switch key {
+	case "steps":
+		steps, err := parseSteps(value)
+		if err != nil {
+			return err
+		}
+		e.Steps = steps
+	default:
+		return errUnknownKey
+	}
+	if c.Escalation == nil {
+		c.Escalation = make(map[string]*EscalationConfig)
+	}
+	c.Escalation[name] = e
+	return nil
+}
+
+// setMaintenanceValue sets a value in the section of the maintenance window
+// name.
+func (c *Config) setMaintenanceValue(name, key, value string) error {
+	m := c.Maintenance[name]
+	if m == nil {
+		m = &MaintenanceConfig{}
+	}
+	switch key {
+	case "schedule":
+		m.Schedule = value
+	case "duration":
+		d, err := query.ParseAge(value)
+		if err != nil {
+			return err
+		}
+		m.Duration = d
+	case "devices":
+		m.Devices = splitList(value)
+	case "groups":
+		m.Groups = splitList(value)
+	case "networks":
+		m.Networks = network.ParseNetworkList(value)
+	default:
+		return errUnknownKey
+	}
+	if c.Maintenance == nil {
+		c.Maintenance = make(map[string]*MaintenanceConfig)
+	}
+	c.Maintenance[name] = m
+	return nil
+}
+
+// setPersonValue sets a value in the section of the person name.
+func (c *Config) setPersonValue(name, key, value string) error {
+	p := c.Person[name]
+	if p == nil {
+		p = &PersonConfig{AwayAfter: defaultAwayAfter}
+	}
+	switch key {
+	case "name":
+		p.Name = value
+	case "macs":
+		p.MACs = network.ParseNetworkList(strings.ToLower(value))
+	case "away_after":
+		after, err := query.ParseAge(value)
+		if err != nil {
+			return err
+		}
+		p.AwayAfter = after
+	default:
+		return errUnknownKey
+	}
+	if c.Person == nil {
+		c.Person = make(map[string]*PersonConfig)
+	}
+	c.Person[name] = p
+	return nil
+}
+
+// setRestrictedValue sets a value in the section of the restricted zone
+// name.
+func (c *Config) setRestrictedValue(name, key, value string) error {
+	r := c.Restricted[name]
+	if r == nil {
+		r = &RestrictedConfig{}
+	}
+	switch key {
+	case "networks":
+		r.Networks = network.ParseNetworkList(value)
+	case "groups":
+		r.Groups = splitList(value)
+	case "allowed":
+		r.Allowed = splitList(strings.ToLower(value))
+	default:
+		return errUnknownKey
+	}
+	if c.Restricted == nil {
+		c.Restricted = make(map[string]*RestrictedConfig)
+	}
+	c.Restricted[name] = r
+	return nil
+}
+
+// ForNetwork returns the scan settings of the network cidr: those of its
+// network section, with anything the section leaves out taken from
+// [scanning].
+func (c *Config) ForNetwork(cidr string) NetworkConfig {
+	result := NetworkConfig{Enable: true}
+	if n := c.Network[networkKey(cidr)]; n != nil {
+		result = *n
+	}
+	if result.ScanInterval == 0 {
+		if result.Schedule == "" {
+			result.Schedule = c.Scanning.Schedule
+		}
+		result.ScanInterval = c.Scanning.ScanInterval
+	}
+	if result.Profile == "" {
+		result.Profile = "normal"
+	}
+	return result
+}
+
+// ScanOptions returns the settings of n the scanner applies itself.
+func (n NetworkConfig) ScanOptions() scanner.Options {
+	return scanner.Options{Profile: n.Profile, Exclude: n.Exclude}
+}
+
+// Options returns the settings of c the LDAP client uses.
+func (c LDAPConfig) Options() ldap.Options {
+	return ldap.Options{
+		URL:            c.URL,
+		BindDN:         c.BindDN,
+		BindPassword:   c.BindPassword,
+		BaseDN:         c.BaseDN,
+		UserAttribute:  c.UserAttribute,
+		GroupAttribute: c.GroupAttribute,
+		GroupBaseDN:    c.GroupBaseDN,
+		StartTLS:       c.StartTLS,
+		TLSVerify:      c.TLSVerify,
+		CAFile:         c.CAFile,
+	}
+}
+
+// Options returns the settings of c the OpenID Connect client uses.
+func (c OIDCConfig) Options() oidc.Options {
+	return oidc.Options{
+		Issuer:        c.Issuer,
+		ClientID:      c.ClientID,
+		ClientSecret:  c.ClientSecret,
+		Scopes:        c.Scopes,
+		UsernameClaim: c.UsernameClaim,
+		GroupsClaim:   c.GroupsClaim,
+	}
+}
+
+// Roles returns the role mapping as the authenticator takes it.
+func (c RolesConfig) Roles() auth.Roles {
+	def, _ := auth.ParseRole(c.Default)
+	return auth.Roles{Admin: c.Admin, Editor: c.Editor, Viewer: c.Viewer, Default: def}
+}
+
+// Options returns the settings of c the InfluxDB client uses.
+func (c InfluxDBConfig) Options() influx.Options {
+	return influx.Options{URL: c.URL, Org: c.Org, Bucket: c.Bucket, Token: c.Token}
+}
+
+// Options returns the settings of c the Zabbix client uses.
+func (c ZabbixConfig) Options() zabbix.Options {
+	return zabbix.Options{Server: c.Server, Host: c.Host, Register: c.Register, Metadata: c.Metadata}
+}
+
+// Options returns the settings of n the notifier uses.
+func (n NotifyConfig) Options() alert.NotifierOptions {
+	return alert.NotifierOptions{
+		Type:     n.Type,
+		URL:      n.URL,
+		Channel:  n.Channel,
+		Username: n.Username,
+		Token:    n.Token,
+		ChatID:   n.ChatID,
+		User:     n.User,
+		Room:     n.Room,
+		Priority: n.Priority,
+		Host:     n.Host,
+		Port:     n.Port,
+		Security: n.Security,
+		Password: n.Password,
+		From:     n.From,
+		To:       n.To,
+
+		Secret:       n.Secret,
+		BodyTemplate: n.BodyTemplate,
+		Facility:     n.Facility,
+		Community:    n.Community,
+	}
+}
+
+// Rule returns the alert rule name as the alert engine takes it.
+func (a AlertConfig) Rule(name string) alert.Rule {
+	return alert.Rule{Name: name, Events: a.Events, Devices: a.Devices, Groups: a.Groups, Notify: a.Notify, Template: a.Template, Escalate: a.Escalate}
+}
+
+// splitList splits a comma separated list. Unlike ParseNetworkList it keeps
+// spaces, which device labels and group names may have.
+func splitList(s string) []string {
+	var out []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Options returns the settings of c the Pi-hole client uses.
+func (c PiholeConfig) Options() pihole.Options {
+	return pihole.Options{URL: c.URL, Token: c.Token, GroupTags: c.GroupTags}
+}
+
+// Options returns the settings of c the AdGuard Home client uses.
+func (c AdGuardConfig) Options() adguard.Options {
+	return adguard.Options{URL: c.URL, Username: c.Username, Password: c.Password, ClientTags: c.ClientTags}
+}
+
+// Options returns the settings of c the OpenWrt client uses.
+func (c OpenWrtConfig) Options() openwrt.Options {
+	return openwrt.Options{URL: c.URL, Username: c.Username, Password: c.Password}
+}
+
+// Options returns the settings of c the firewall client uses.
+func (c FirewallConfig) Options() firewall.Options {
+	return firewall.Options{Type: c.Type, URL: c.URL, Key: c.Key, Secret: c.Secret, CAFile: c.CAFile, TLSVerify: c.TLSVerify}
+}
+
+// setInt stores value in dst if it is a whole number.
+func setInt(dst *int, value string) error {
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%q is not a whole number", value)
+	}
+	*dst = v
+	return nil
+}
+
+// setBool stores value in dst. Anything parseBool does not take as true has
+// always been false, so only values that are neither spelling are an error;
+// they are still stored as false, as they always have been.
+func setBool(dst *bool, value string) error {
+	*dst = parseBool(value)
+	switch strings.ToLower(value) {
+	case "true", "yes", "1", "on", "false", "no", "0", "off":
+		return nil
+	}
+	return fmt.Errorf("%q is not true or false", value)
 }
 
 // ApplyEnv overlays settings from environment variables onto c.
@@ -300,13 +2187,16 @@ func (c *Config) ApplyEnv() {
 	if v := os.Getenv("ORANGUTAN_PORT"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil {
 			c.Server.Port = n
+			c.SetSource("server.port", "env ORANGUTAN_PORT")
 		}
 	}
 	if v := os.Getenv("ORANGUTAN_BIND_ADDRESS"); v != "" {
 		c.Server.BindAddress = v
+		c.SetSource("server.bind_address", "env ORANGUTAN_BIND_ADDRESS")
 	}
 	if v := os.Getenv("ORANGUTAN_PASSWORD"); v != "" {
 		c.Server.Password = v
+		c.SetSource("server.password", "env ORANGUTAN_PASSWORD")
 	}
 	// A password file keeps the secret out of the process environment, which
 	// is how container secrets are normally delivered.
@@ -314,31 +2204,66 @@ func (c *Config) ApplyEnv() {
 		if data, err := os.ReadFile(v); err == nil {
 			if pw := strings.TrimSpace(string(data)); pw != "" {
 				c.Server.Password = pw
+				c.SetSource("server.password", "env ORANGUTAN_PASSWORD_FILE")
 			}
 		}
 	}
+	if v := os.Getenv("ORANGUTAN_USERNAME"); v != "" {
+		c.Server.Username = v
+		c.SetSource("server.username", "env ORANGUTAN_USERNAME")
+	}
+	if v := os.Getenv("ORANGUTAN_API_TOKEN"); v != "" {
+		c.Server.APIToken = v
+		c.SetSource("server.api_token", "env ORANGUTAN_API_TOKEN")
+	}
 	if v := os.Getenv("ORANGUTAN_SESSION_HOURS"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil {
 			c.Server.SessionHours = n
+			c.SetSource("server.session_hours", "env ORANGUTAN_SESSION_HOURS")
 		}
 	}
 	if v := os.Getenv("ORANGUTAN_ALLOW_INSECURE"); v != "" {
 		c.Server.AllowInsecure = parseBool(v)
+		c.SetSource("server.allow_insecure", "env ORANGUTAN_ALLOW_INSECURE")
 	}
 	if v := os.Getenv("ORANGUTAN_DATA_DIR"); v != "" {
 		c.Storage.DataDir = v
+		c.SetSource("storage.data_dir", "env ORANGUTAN_DATA_DIR")
 	}
 	if v := os.Getenv("ORANGUTAN_NETWORKS"); v != "" {
 		c.Scanning.Networks = network.ParseNetworkList(v)
+		c.SetSource("scanning.networks", "env ORANGUTAN_NETWORKS")
 	}
 	if v := os.Getenv("ORANGUTAN_SCAN_INTERVAL"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil {
 			c.Scanning.ScanInterval = n
+			c.SetSource("scanning.scan_interval", "env ORANGUTAN_SCAN_INTERVAL")
+		}
+	}
+	if v := os.Getenv("ORANGUTAN_SCAN_TIMEOUT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.Scanning.ScanTimeout = n
+			c.SetSource("scanning.scan_timeout", "env ORANGUTAN_SCAN_TIMEOUT")
 		}
 	}
 	if v := os.Getenv("ORANGUTAN_THEME"); v != "" {
 		c.UI.Theme = v
+		c.SetSource("ui.theme", "env ORANGUTAN_THEME")
 	}
+	if v := os.Getenv("ORANGUTAN_LANGUAGE"); v != "" {
+		c.UI.Language = v
+		c.SetSource("ui.language", "env ORANGUTAN_LANGUAGE")
+	}
+}
+
+// Timeout returns ScanTimeout as a duration.
+func (s ScanningConfig) Timeout() time.Duration {
+	return time.Duration(s.ScanTimeout) * time.Second
+}
+
+// TimeoutDuration returns Timeout as a duration.
+func (v VulnScanConfig) TimeoutDuration() time.Duration {
+	return time.Duration(v.Timeout) * time.Second
 }
 
 // IsLoopbackBind reports whether the configured bind address only accepts
@@ -373,15 +2298,17 @@ func isLoopbackAddress(addr string) bool {
 // Setup is required whenever the server can be reached from the network and no
 // password exists yet. Bound to loopback the dashboard is already private, so
 // a password would be friction with no benefit, and an operator who has some
-// other protection in front can opt out entirely.
+// other protection in front can opt out entirely. Served on the tailnet it is
+// no longer private, however it is bound on the LAN. Nor is there a password
+// to create when users sign in with a directory or OpenID Connect provider.
 func (c *Config) RequiresSetup() bool {
 	if c.Server.AllowInsecure {
 		return false
 	}
-	if c.IsLoopbackBind() {
+	if c.IsLoopbackBind() && !c.Tailscale.Serve {
 		return false
 	}
-	return c.Server.Password == ""
+	return c.Server.Password == "" && c.LDAP.URL == "" && c.OIDC.Issuer == ""
 }
 
 // PasswordFile returns the path where a password created through the setup page
@@ -406,6 +2333,67 @@ func (c *Config) DevicesFile() string {
 	return filepath.Join(c.Storage.DataDir, "devices.json")
 }
 
+// ReportsDir returns the directory scheduled reports are written to.
+func (c *Config) ReportsDir() string {
+	if c.Reports.Dir != "" {
+		return c.Reports.Dir
+	}
+	return filepath.Join(c.Storage.DataDir, "reports")
+}
+
+// CVEOptions returns the options of CVE lookups, which keep what NVD said
+// in the data directory.
+func (c *Config) CVEOptions() cve.Options {
+	return cve.Options{
+		URL:       c.CVE.URL,
+		APIKey:    c.CVE.APIKey,
+		CacheFile: filepath.Join(c.Storage.DataDir, "cve_cache.json"),
+		MaxAge:    c.CVE.Cache,
+	}
+}
+
+// CredCheckOptions returns the options of the default login check. Logins
+// that do not parse are left out; Validate reports them.
+func (c *Config) CredCheckOptions() credcheck.Options {
+	opts := credcheck.Options{Delay: time.Duration(c.CredCheck.Delay) * time.Second}
+	for _, s := range c.CredCheck.Logins {
+		if login, err := credcheck.ParseLogin(s); err == nil {
+			opts.Logins = append(opts.Logins, login)
+		}
+	}
+	return opts
+}
+
+// NewScanner returns a scanner with the settings of [scanning], raw sockets
+// if it can have them, and the configured sources asked for the devices
+// they know: Pi-hole, AdGuard Home, OpenWrt, Docker and Kubernetes.
+func (c *Config) NewScanner() *scanner.Scanner {
+	s := scanner.New(c.Scanning.MinScanInterval)
+	privilege, _ := scanner.ChoosePrivilege(c.Scanning.Privileged)
+	s.SetPrivilege(privilege)
+	s.SetBackends(c.Scanning.Backends)
+	if c.Pihole.URL != "" {
+		s.AddSource(pihole.New(c.Pihole.Options()))
+	}
+	if c.AdGuard.URL != "" {
+		s.AddSource(adguard.New(c.AdGuard.Options()))
+	}
+	if c.OpenWrt.URL != "" {
+		s.AddSource(openwrt.New(c.OpenWrt.Options()))
+	}
+	if c.Docker.Enable {
+		s.AddSource(docker.New(c.Docker.Socket))
+	}
+	if c.Kubernetes.Enable {
+		if k, err := kube.New(c.Kubernetes.Kubeconfig, c.Kubernetes.Context); err != nil {
+			slog.Warn("not asking Kubernetes about its nodes", "error", err)
+		} else {
+			s.AddSource(k)
+		}
+	}
+	return s
+}
+
 // StateFile returns the full path to the scan state file
 func (c *Config) StateFile() string {
 	return filepath.Join(c.Storage.DataDir, "scan_state.json")
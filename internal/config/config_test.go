@@ -3,14 +3,27 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/alert"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
 )
 
 // writeConfig writes body to a temporary config file and returns its path.
 func writeConfig(t *testing.T, body string) string {
 	t.Helper()
-	path := filepath.Join(t.TempDir(), "config.ini")
+	return writeConfigAs(t, "config.ini", body)
+}
+
+// writeConfigAs writes body to a temporary config file with the given name,
+// whose extension chooses the format.
+func writeConfigAs(t *testing.T, name, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
 	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
 		t.Fatalf("writing config: %v", err)
 	}
@@ -47,6 +60,22 @@ func TestMissingFileFallsBackToDefaults(t *testing.T) {
 	}
 }
 
+func TestXDGDirs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the home directory comes from USERPROFILE")
+	}
+	t.Setenv("HOME", "/home/ape")
+	t.Setenv("XDG_DATA_HOME", "/srv/data")
+	if got := xdgDir("XDG_DATA_HOME", ".local", "share"); got != "/srv/data" {
+		t.Errorf("with XDG_DATA_HOME set: %q", got)
+	}
+	// The spec says a relative path is invalid and to be ignored.
+	t.Setenv("XDG_DATA_HOME", "data")
+	if got := xdgDir("XDG_DATA_HOME", ".local", "share"); got != filepath.Join("/home/ape", ".local", "share") {
+		t.Errorf("with a relative XDG_DATA_HOME: %q", got)
+	}
+}
+
 func TestLoadServerSettings(t *testing.T) {
 	path := writeConfig(t, `
 [server]
@@ -79,6 +108,24 @@ allow_insecure = true
 	}
 }
 
+func TestLoadScanTimeout(t *testing.T) {
+	path := writeConfig(t, `
+[scanning]
+scan_timeout = 1800
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := cfg.Scanning.Timeout(); got != 30*time.Minute {
+		t.Errorf("Timeout = %v, want 30m", got)
+	}
+	if got := Default().Scanning.Timeout(); got != 5*time.Minute {
+		t.Errorf("default Timeout = %v, want 5m", got)
+	}
+}
+
 func TestIsLoopbackBind(t *testing.T) {
 	tests := []struct {
 		addr string
@@ -146,6 +193,18 @@ func TestNoSetupWhenLoopbackOnly(t *testing.T) {
 	}
 }
 
+func TestRequiresSetupWhenServedOnTheTailnet(t *testing.T) {
+	// Loopback keeps the LAN out, but every machine on the tailnet can still
+	// reach the node tsnet adds.
+	cfg := Default()
+	cfg.Server.BindAddress = "127.0.0.1"
+	cfg.Tailscale.Serve = true
+
+	if !cfg.RequiresSetup() {
+		t.Fatal("a dashboard served on the tailnet with no password must require setup")
+	}
+}
+
 func TestNoSetupWhenExplicitlyOptedOut(t *testing.T) {
 	cfg := Default()
 	cfg.Server.BindAddress = "0.0.0.0"
@@ -256,6 +315,648 @@ func TestMissingPasswordFileIsIgnored(t *testing.T) {
 	}
 }
 
+func TestDropInsOverrideInNameOrder(t *testing.T) {
+	path := writeConfig(t, "[server]\nport = 8080\nusername = admin\n")
+	dir := filepath.Join(filepath.Dir(path), DropInDir)
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, body := range map[string]string{
+		"50-local.ini":     "[server]\nport = 9090\n",
+		"10-package.toml":  "[server]\nport = 7070\nbind_address = \"127.0.0.1\"\n",
+		"20-typo.yaml":     "server:\n  prot: 1\n",
+		"50-local.ini.bak": "[server]\nport = 1\n",
+		".60-hidden.ini":   "[server]\nport = 2\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var names []string
+	for _, f := range DropIns(path) {
+		names = append(names, filepath.Base(f))
+	}
+	if got := strings.Join(names, " "); got != "10-package.toml 20-typo.yaml 50-local.ini" {
+		t.Errorf("DropIns = %s", got)
+	}
+
+	cfg, problems, err := load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Server.Port != 9090 || cfg.Server.BindAddress != "127.0.0.1" || cfg.Server.Username != "admin" {
+		t.Errorf("port %d, bind_address %q, username %q", cfg.Server.Port, cfg.Server.BindAddress, cfg.Server.Username)
+	}
+	want := "config.d/20-typo.yaml: line 2: prot: unknown setting"
+	if len(problems) != 1 || problems[0] != want {
+		t.Errorf("problems = %q, want %q", problems, want)
+	}
+}
+
+func TestDropInsApplyWithoutAConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.ini")
+	dir := filepath.Join(filepath.Dir(path), DropInDir)
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "port.ini"), []byte("[server]\nport = 8080\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Server.Port != 8080 {
+		t.Errorf("port = %d, want the drop-in's", cfg.Server.Port)
+	}
+}
+
+func TestEffectiveSources(t *testing.T) {
+	path := writeConfig(t, `[server]
+port = 8080
+
+[network "10.0.0.1/8"]
+profile = quick
+`)
+	dir := filepath.Join(filepath.Dir(path), DropInDir)
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	dropIn := filepath.Join(dir, "theme.ini")
+	if err := os.WriteFile(dropIn, []byte("[ui]\ntheme = dark\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ORANGUTAN_BIND_ADDRESS", "127.0.0.1")
+	t.Setenv("ORANGUTAN_API_TOKEN", "t0ken")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	cfg.ApplyEnv()
+	cfg.Server.SessionHours = 1
+	cfg.SetSource("server.session_hours", "flag --hours")
+
+	got := make(map[string]Setting)
+	for _, s := range cfg.Effective() {
+		got[s.Key] = s
+	}
+	for key, want := range map[string]Setting{
+		"server.port":                        {Value: "8080", Source: "file " + path + ":2"},
+		"ui.theme":                           {Value: "dark", Source: "drop-in " + dropIn + ":2"},
+		"server.bind_address":                {Value: "127.0.0.1", Source: "env ORANGUTAN_BIND_ADDRESS"},
+		"server.api_token":                   {Value: "(set)", Source: "env ORANGUTAN_API_TOKEN"},
+		"server.session_hours":               {Value: "1", Source: "flag --hours"},
+		"scanning.scan_timeout":              {Value: "300", Source: "default"},
+		`network "10.0.0.0/8".profile`:       {Value: "quick", Source: "file " + path + ":5"},
+		`network "10.0.0.0/8".scan_interval`: {Value: "300", Source: "same as scanning.scan_interval"},
+	} {
+		want.Key = key
+		if got[key] != want {
+			t.Errorf("%s = %+v, want %+v", key, got[key], want)
+		}
+	}
+}
+
+func TestBackends(t *testing.T) {
+	if got := Default().Scanning.Backends; !reflect.DeepEqual(got, []string{"nmap", "arp-scan"}) {
+		t.Errorf("default backends = %q", got)
+	}
+	cfg, err := Load(writeConfig(t, "[scanning]\nbackends = ARP-scan, nmap\n"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := cfg.Scanning.Backends; !reflect.DeepEqual(got, []string{"arp-scan", "nmap"}) {
+		t.Errorf("backends = %q, want arp-scan then nmap", got)
+	}
+
+	cfg.Scanning.Backends = []string{"nmap", "masscan", "nmap"}
+	want := []string{
+		`line 2: backends: "masscan" is not nmap or arp-scan`,
+		"line 2: backends: nmap is listed twice",
+	}
+	if got := cfg.Validate(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate = %q, want %q", got, want)
+	}
+	cfg.Scanning.Backends = nil
+	if got := cfg.Validate(); len(got) != 1 || !strings.Contains(got[0], "backends is empty") {
+		t.Errorf("Validate = %q, want backends reported empty", got)
+	}
+}
+
+func TestValidatePrivilegeSettings(t *testing.T) {
+	cfg := Default()
+	cfg.Scanning.Privileged = "sometimes"
+	cfg.Server.UnprivilegedPort = 70000
+	want := []string{
+		"unprivileged_port 70000 is not between 1 and 65535, or 0 for none",
+		`privileged "sometimes" is not auto, true or false`,
+	}
+	if got := cfg.Validate(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate = %q, want %q", got, want)
+	}
+	cfg.Scanning.Privileged = "false"
+	cfg.Server.UnprivilegedPort = 0
+	if got := cfg.Validate(); len(got) != 0 {
+		t.Errorf("Validate = %q, want no problems", got)
+	}
+}
+
+func TestMQTTSettings(t *testing.T) {
+	t.Setenv("MQTT_PASSWORD", "s3cret")
+	cfg, err := Load(writeConfig(t, `[mqtt]
+broker = mqtts://broker.lan
+username = orangutan
+password = env:MQTT_PASSWORD
+qos = 2
+topic_prefix = home/#
+`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.MQTT.Broker != "mqtts://broker.lan" || cfg.MQTT.Password != "s3cret" || cfg.MQTT.SnapshotInterval != 300 {
+		t.Errorf("MQTT = %+v", cfg.MQTT)
+	}
+	want := []string{
+		"line 5: qos 2 is not 0 or 1",
+		`line 6: topic_prefix "home/#" must be a topic without the wildcards + and #`,
+	}
+	if got := cfg.Validate(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate = %q, want %q", got, want)
+	}
+}
+
+func TestOfflineGracePeriods(t *testing.T) {
+	path := writeConfig(t, `[offline]
+after = 15m
+groups = Mobile=2h, NAS=0
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	grace := cfg.Offline.Grace()
+	for group, want := range map[string]time.Duration{"mobile": 2 * time.Hour, "NAS": 0, "": 15 * time.Minute, "Media": 15 * time.Minute} {
+		if got := grace(&types.Device{Group: group}); got != want {
+			t.Errorf("grace of group %q = %v; want %v", group, got, want)
+		}
+	}
+	if got := cfg.Offline.FormatGroups(); got != "mobile=2h, nas=0" {
+		t.Errorf("FormatGroups = %q", got)
+	}
+	if Default().Offline.Grace() != nil {
+		t.Error("by default every device should be reported offline at once")
+	}
+
+	problems, err := Check(writeConfig(t, "[offline]\nafter = soon\ngroups = Mobile\n"))
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if len(problems) != 2 {
+		t.Errorf("Check = %q; want both settings reported", problems)
+	}
+}
+
+func TestReportsSection(t *testing.T) {
+	cfg, err := Load(writeConfig(t, "[storage]\ndata_dir = /srv/orangutan\n\n[reports]\nschedule = weekly\nkeep = 12\n"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Reports.Schedule != "weekly" || cfg.Reports.Keep != 12 {
+		t.Errorf("Reports = %+v", cfg.Reports)
+	}
+	if got := cfg.ReportsDir(); got != filepath.Join("/srv/orangutan", "reports") {
+		t.Errorf("ReportsDir = %q; want it under the data directory", got)
+	}
+
+	cfg.Reports.Schedule, cfg.Reports.Keep = "fortnightly", -1
+	if got := cfg.Validate(); len(got) != 2 {
+		t.Errorf("Validate = %q; want both settings reported", got)
+	}
+}
+
+func TestMaintenanceSections(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `[maintenance "lab weekends"]
+schedule = 0 0 * * sat
+duration = 48h
+networks = 10.0.20.0/24
+
+[maintenance "patching"]
+schedule = every tuesday
+groups = Servers
+`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	windows := cfg.Windows()
+	if len(windows) != 1 || windows[0].Name != "lab weekends" || windows[0].Duration != 48*time.Hour || !reflect.DeepEqual(windows[0].Networks, []string{"10.0.20.0/24"}) {
+		t.Errorf("Windows = %+v; want only the lab's", windows)
+	}
+	if got := cfg.Validate(); len(got) != 2 {
+		t.Errorf("Validate = %q; want the patching window's schedule and duration reported", got)
+	}
+}
+
+func TestEscalationSections(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `[notify "phone"]
+type = ntfy
+url = https://ntfy.sh/lan
+
+[notify "email"]
+type = email
+host = mail.example.com
+from = orangutan@example.com
+to = ops@example.com
+
+[escalation "critical"]
+steps = Phone, email after 30m, boss after 2h
+
+[alert "servers"]
+events = offline
+escalate = Critical
+`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := []alert.Policy{{Name: "critical", Steps: []alert.Step{
+		{Notify: []string{"phone"}},
+		{After: 30 * time.Minute, Notify: []string{"email"}},
+		{After: 2 * time.Hour, Notify: []string{"boss"}},
+	}}}
+	if got := cfg.Policies(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Policies = %+v; want %+v", got, want)
+	}
+	if got := FormatSteps(want[0].Steps); got != "phone, email after 30m, boss after 2h" {
+		t.Errorf("FormatSteps = %q", got)
+	}
+	if got := cfg.Alert["servers"].Rule("servers").Escalate; got != "critical" {
+		t.Errorf("Escalate = %q; want critical", got)
+	}
+	errs := cfg.Validate()
+	if len(errs) != 1 || !strings.Contains(errs[0], `no [notify "boss"] section`) {
+		t.Errorf("Validate = %q; want the missing boss notifier reported", errs)
+	}
+
+	problems, err := Check(writeConfig(t, "[escalation \"broken\"]\nsteps = phone in 5m\n"))
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if len(problems) != 1 || !strings.Contains(problems[0], "email after 30m") {
+		t.Errorf("Check = %q; want the step reported", problems)
+	}
+}
+
+func TestPersonSections(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `[person "alice"]
+name = Alice
+macs = AA:BB:CC:00:00:01, aa:bb:cc:00:00:02
+
+[person "bob"]
+macs = aa:bb:cc:00:00:03
+away_after = 1h
+
+[person "carol"]
+macs = phone
+`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := []types.Person{
+		{Name: "Alice", MACs: []string{"aa:bb:cc:00:00:01", "aa:bb:cc:00:00:02"}, AwayAfter: 10 * time.Minute},
+		{Name: "bob", MACs: []string{"aa:bb:cc:00:00:03"}, AwayAfter: time.Hour},
+		{Name: "carol", MACs: []string{"phone"}, AwayAfter: 10 * time.Minute},
+	}
+	if got := cfg.People(); !reflect.DeepEqual(got, want) {
+		t.Errorf("People = %+v; want %+v", got, want)
+	}
+	if got := cfg.Validate(); len(got) != 1 || !strings.Contains(got[0], `"phone" is not a MAC address`) {
+		t.Errorf("Validate = %q; want carol's MAC reported", got)
+	}
+}
+
+func TestRestrictedSections(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `[restricted "cameras"]
+networks = 192.168.20.0/24
+allowed = AA:BB:CC:00:00:01, 192.168.20.9
+
+[restricted "iot"]
+groups = IoT, Smart home
+
+[restricted "empty"]
+allowed = front door
+`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	zones := cfg.RestrictedZones()
+	if len(zones) != 3 || zones[0].Name != "cameras" || zones[1].Name != "empty" || zones[2].Name != "iot" {
+		t.Fatalf("RestrictedZones = %+v; want the three in order of name", zones)
+	}
+	if got := zones[0]; !reflect.DeepEqual(got.Networks, []string{"192.168.20.0/24"}) || !reflect.DeepEqual(got.Allowed, []string{"aa:bb:cc:00:00:01", "192.168.20.9"}) {
+		t.Errorf("cameras = %+v", got)
+	}
+	if got := zones[2]; !reflect.DeepEqual(got.Groups, []string{"IoT", "Smart home"}) || len(got.Allowed) != 0 {
+		t.Errorf("iot = %+v", got)
+	}
+	got := cfg.Validate()
+	if len(got) != 2 || !strings.Contains(got[0], "covers nothing") || !strings.Contains(got[1], `"front door" is neither a MAC nor an IP address`) {
+		t.Errorf("Validate = %q; want the empty zone reported", got)
+	}
+}
+
+func TestAlertSections(t *testing.T) {
+	t.Setenv("SLACK_WEBHOOK", "https://hooks.slack.com/services/T0/B0/x")
+	cfg, err := Load(writeConfig(t, `[notify "Team"]
+type = slack
+url = env:SLACK_WEBHOOK
+channel = #network
+
+[notify "gaming"]
+type = teams
+
+[alert "servers"]
+events = offline, reboot
+devices = nas, Living Room TV
+notify = team, pager
+template = {{.Name}} is {{.Event
+
+[notify "phone"]
+type = telegram
+token = env:SLACK_WEBHOOK
+commands = yes
+
+[notify "mail"]
+type = email
+host = smtp.example.com
+from = LAN Orangutan <orangutan@example.com>
+to = me@example.com, you@example.com
+alerts = false
+digest = fortnightly
+
+[notify "push"]
+type = pushover
+token = abc
+user = def
+priority = loud
+`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	team := cfg.Notify["team"]
+	if team == nil || team.Type != "slack" || team.URL != "https://hooks.slack.com/services/T0/B0/x" || team.Channel != "#network" {
+		t.Errorf("Notify[team] = %+v", team)
+	}
+	servers := cfg.Alert["servers"]
+	if servers == nil || !reflect.DeepEqual(servers.Devices, []string{"nas", "Living Room TV"}) {
+		t.Fatalf("Alert[servers] = %+v", servers)
+	}
+	want := []string{
+		`line 7: [notify "gaming"] type "teams" is not slack, discord, telegram, matrix, email, ntfy, gotify, pushover, webhook, syslog, journald, snmp or grafana`,
+		`line 26: [notify "mail"] digest is not daily, weekly or a schedule: "fortnightly": expected 5 fields (minute hour day month weekday), got 1`,
+		`line 16: [notify "phone"] no chat_id set`,
+		`line 32: [notify "push"] priority "loud" is not min, low, default, high or urgent`,
+		`line 10: [alert "servers"] events: "reboot" is not new, offline, scan_failed, scan_completed, anomaly, arrival, departure, cert_expiring or intrusion`,
+		`line 12: [alert "servers"] notify: there is no [notify "pager"] section`,
+		`line 13: [alert "servers"] template: template: alert:1: unclosed action`,
+	}
+	if got := cfg.Validate(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate = %q, want %q", got, want)
+	}
+	if mail := cfg.Notify["mail"]; mail == nil || mail.Alerts || len(mail.To) != 2 || mail.From != "LAN Orangutan <orangutan@example.com>" {
+		t.Errorf("Notify[mail] = %+v", mail)
+	}
+	for _, s := range cfg.Effective() {
+		if s.Key == `notify "team".url` && s.Value != "(set)" {
+			t.Errorf("notify url shown as %q", s.Value)
+		}
+	}
+}
+
+func TestAlertSectionsInYAMLAndTOML(t *testing.T) {
+	for name, body := range map[string]string{
+		"config.yaml": "notify:\n  team:\n    type: discord\n    url: https://discord.com/api/webhooks/1/x\nalert:\n  new:\n    events: [new]\n",
+		"config.toml": "[notify.\"team\"]\ntype = \"discord\"\nurl = \"https://discord.com/api/webhooks/1/x\"\n[alert.\"new\"]\nevents = [\"new\"]\n",
+	} {
+		cfg, err := Load(writeConfigAs(t, name, body))
+		if err != nil {
+			t.Fatalf("%s: Load: %v", name, err)
+		}
+		if n := cfg.Notify["team"]; n == nil || n.Type != "discord" {
+			t.Errorf("%s: Notify[team] = %+v", name, n)
+		}
+		if a := cfg.Alert["new"]; a == nil || !reflect.DeepEqual(a.Events, []string{"new"}) {
+			t.Errorf("%s: Alert[new] = %+v", name, a)
+		}
+		if problems := cfg.Validate(); len(problems) != 0 {
+			t.Errorf("%s: Validate = %q", name, problems)
+		}
+	}
+}
+
+func TestValidateSaysWhereSettingsWereMade(t *testing.T) {
+	path := writeConfig(t, `[server]
+port = 70000
+`)
+	dir := filepath.Join(filepath.Dir(path), DropInDir)
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "50-local.ini"), []byte("[ui]\n\ntheme = purple\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ORANGUTAN_SCAN_TIMEOUT", "-1")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	cfg.ApplyEnv()
+	want := []string{
+		"line 2: port 70000 is not between 1 and 65535",
+		"ORANGUTAN_SCAN_TIMEOUT: scan_timeout -1 must be more than 0 seconds",
+		`config.d/50-local.ini: line 3: theme "purple" is not auto, light or dark`,
+	}
+	if got := cfg.Validate(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate = %q, want %q", got, want)
+	}
+}
+
+func TestCheckEnv(t *testing.T) {
+	t.Setenv("ORANGUTAN_PORT", "80a")
+	t.Setenv("ORANGUTAN_SCAN_INTERVAL", "60")
+	t.Setenv("ORANGUTAN_ALLOW_INSECURE", "perhaps")
+	t.Setenv("ORANGUTAN_PASSWORD_FILE", filepath.Join(t.TempDir(), "missing"))
+
+	got := CheckEnv()
+	if len(got) != 3 {
+		t.Fatalf("CheckEnv = %q, want 3 problems", got)
+	}
+	for i, want := range []string{`ORANGUTAN_PORT: "80a" is not a whole number`, `ORANGUTAN_ALLOW_INSECURE: "perhaps"`, "ORANGUTAN_PASSWORD_FILE:"} {
+		if !strings.HasPrefix(got[i], want) {
+			t.Errorf("problem %d = %q, want it to start with %s", i, got[i], want)
+		}
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	path := writeConfig(t, `[storage]
+data_dir = /srv/orangutan
+
+[scanning]
+networks = 192.168.1.0/24
+scan_interval = 600
+
+[profile "home"]
+schedule = */15 * * * *
+
+[profile "Office"]
+networks = 10.20.0.0/16, 10.21.0.0/16
+scan_interval = 60
+data_dir = /srv/office
+`)
+	for _, tt := range []struct {
+		profile  string
+		networks []string
+		interval int
+		schedule string
+		dataDir  string
+	}{
+		{"", []string{"192.168.1.0/24"}, 600, "", "/srv/orangutan"},
+		{"home", []string{"192.168.1.0/24"}, 600, "*/15 * * * *", filepath.Join("/srv/orangutan", "profiles", "home")},
+		{"office", []string{"10.20.0.0/16", "10.21.0.0/16"}, 60, "", "/srv/office"},
+	} {
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		if err := cfg.UseProfile(tt.profile); err != nil {
+			t.Fatalf("UseProfile(%q): %v", tt.profile, err)
+		}
+		if cfg.Profile() != tt.profile {
+			t.Errorf("Profile() = %q, want %q", cfg.Profile(), tt.profile)
+		}
+		s := cfg.Scanning
+		if !reflect.DeepEqual(s.Networks, tt.networks) || s.ScanInterval != tt.interval || s.Schedule != tt.schedule || cfg.Storage.DataDir != tt.dataDir {
+			t.Errorf("profile %q: scanning %+v, data_dir %s", tt.profile, s, cfg.Storage.DataDir)
+		}
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := cfg.Profiles(); !reflect.DeepEqual(got, []string{"home", "office"}) {
+		t.Errorf("Profiles = %q", got)
+	}
+	if err := cfg.UseProfile("travel"); err == nil || !strings.Contains(err.Error(), "home, office") {
+		t.Errorf("UseProfile of a missing profile = %v, want an error naming the others", err)
+	}
+}
+
+func TestProfilesInYAMLAndTOML(t *testing.T) {
+	for name, body := range map[string]string{
+		"config.yaml": "profile:\n  travel:\n    networks: [172.16.0.0/12]\n",
+		"config.toml": "[profile.travel]\nnetworks = [\"172.16.0.0/12\"]\n",
+	} {
+		cfg, err := Load(writeConfigAs(t, name, body))
+		if err != nil {
+			t.Fatalf("%s: Load: %v", name, err)
+		}
+		if err := cfg.UseProfile("travel"); err != nil {
+			t.Fatalf("%s: UseProfile: %v", name, err)
+		}
+		if !reflect.DeepEqual(cfg.Scanning.Networks, []string{"172.16.0.0/12"}) {
+			t.Errorf("%s: networks = %q", name, cfg.Scanning.Networks)
+		}
+	}
+}
+
+func TestCheckReportsProfileProblems(t *testing.T) {
+	path := writeConfig(t, `[profile ""]
+networks = 10.0.0.0/8
+
+[profile "home"]
+scan_interval = often
+theme = dark
+`)
+	problems, err := Check(path)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	want := []string{
+		`line 1: [profile ""]: a profile needs a name, such as [profile "home"]`,
+		`line 5: scan_interval: "often" is not a whole number`,
+		`line 6: theme: unknown setting`,
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("Check = %q, want %q", problems, want)
+	}
+}
+
+func TestSecretReferences(t *testing.T) {
+	path := writeConfig(t, `[server]
+password = file:password
+api_token = env:TEST_ORANGUTAN_TOKEN
+`)
+	if err := os.WriteFile(filepath.Join(filepath.Dir(path), "password"), []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_ORANGUTAN_TOKEN", "from-env")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Server.Password != "from-file" || cfg.Server.APIToken != "from-env" {
+		t.Errorf("password %q, api_token %q; want the referenced secrets", cfg.Server.Password, cfg.Server.APIToken)
+	}
+}
+
+func TestUnreadableSecretFailsLoad(t *testing.T) {
+	// Carrying on without the password would hand the setup page to
+	// whoever gets there first.
+	dir := t.TempDir()
+	open := filepath.Join(dir, "open")
+	if err := os.WriteFile(open, []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		"password = file:" + filepath.Join(dir, "missing"): "cannot read secret file",
+		"api_token = env:TEST_ORANGUTAN_UNSET":             "is not set",
+	}
+	if runtime.GOOS != "windows" {
+		cases["password = file:"+open] = "chmod 600"
+	}
+	for line, want := range cases {
+		_, err := Load(writeConfig(t, "[server]\n"+line+"\n"))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: Load error = %v, want one mentioning %q", line, err, want)
+		}
+	}
+}
+
+func TestCheckWarnsOfSecretsOthersCanRead(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no Unix permissions")
+	}
+	path := writeConfig(t, "[server]\npassword = hunter2\n")
+	if problems, _ := Check(path); len(problems) != 0 {
+		t.Errorf("a private file: Check = %q, want no problems", problems)
+	}
+	if err := os.Chmod(path, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	problems, err := Check(path)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	want := "line 2: password is written out in a file other users can read; use file: or env: instead"
+	if len(problems) != 1 || problems[0] != want {
+		t.Errorf("Check = %q, want %q", problems, want)
+	}
+}
+
 func TestSessionTTL(t *testing.T) {
 	cfg := Default()
 	if got := cfg.SessionTTL(); got != 7*24*time.Hour {
@@ -295,3 +996,310 @@ port = 4242
 		t.Errorf("port = %d, want 4242", cfg.Server.Port)
 	}
 }
+
+func TestCheckReportsLinesLoadSkips(t *testing.T) {
+	path := writeConfig(t, `
+[server]
+port = eighty
+prot = 8080
+allow_insecure = maybe
+enable_api = off
+just some text
+
+[colours]
+accent = orange
+`)
+
+	problems, err := Check(path)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	want := []string{
+		`line 3: port: "eighty" is not a whole number`,
+		`line 4: prot: unknown setting`,
+		`line 5: allow_insecure: "maybe" is not true or false`,
+		`line 7: expected key = value, got "just some text"`,
+		`line 9: unknown section [colours]`,
+	}
+	if strings.Join(problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Check =\n%s\nwant\n%s", strings.Join(problems, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Load still starts with whatever it could understand.
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Server.Port != Default().Server.Port || cfg.Server.EnableAPI {
+		t.Errorf("port = %d, enable_api = %v; want the default port and the API off", cfg.Server.Port, cfg.Server.EnableAPI)
+	}
+}
+
+func TestCheckMissingFileHasNoProblems(t *testing.T) {
+	problems, err := Check(filepath.Join(t.TempDir(), "does-not-exist.ini"))
+	if err != nil || len(problems) != 0 {
+		t.Errorf("Check = %v, %v; want no problems", problems, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if problems := Default().Validate(); len(problems) != 0 {
+		t.Errorf("the defaults should be valid, got %v", problems)
+	}
+
+	cfg := Default()
+	cfg.Server.Port = 70000
+	cfg.Scanning.ScanInterval = 60
+	cfg.Scanning.MinScanInterval = 120
+	cfg.Scanning.Networks = []string{"10.0.0.0/8", "10.0.0.0"}
+	cfg.UI.Theme = "purple"
+	problems := cfg.Validate()
+	if len(problems) != 4 {
+		t.Fatalf("Validate = %q, want 4 problems", problems)
+	}
+	for i, want := range []string{"port 70000", "min_scan_interval 120", `"10.0.0.0"`, `theme "purple"`} {
+		if !strings.Contains(problems[i], want) {
+			t.Errorf("problem %d = %q, want it to mention %s", i, problems[i], want)
+		}
+	}
+}
+
+// sameSettings is one config in each format.
+var sameSettings = map[string]string{
+	"config.ini": `
+[server]
+port = 8080
+password = hunter2
+enable_api = off
+
+[scanning]
+networks = 192.168.10.0/24, 10.0.5.0/24
+
+[network "10.0.5.0/24"]
+profile = quick
+exclude = 10.0.5.1, 10.0.5.128/25
+`,
+	"config.yaml": `
+server:
+  port: 8080
+  password: "hunter2"
+  enable_api: off
+scanning:
+  networks:
+    - 192.168.10.0/24
+    - 10.0.5.0/24
+tailscale:
+network:
+  10.0.5.0/24:
+    profile: quick
+    exclude: [10.0.5.1, 10.0.5.128/25]
+`,
+	"config.toml": `
+[server]
+port = 8_080
+password = "hunter2" # a comment
+enable_api = false
+
+[scanning]
+networks = [
+  "192.168.10.0/24",
+  '10.0.5.0/24',
+]
+
+[network."10.0.5.0/24"]
+profile = "quick"
+exclude = ["10.0.5.1", "10.0.5.128/25"]
+`,
+}
+
+func TestFormatsMeanTheSame(t *testing.T) {
+	for name, body := range sameSettings {
+		cfg, err := Load(writeConfigAs(t, name, body))
+		if err != nil {
+			t.Errorf("%s: Load: %v", name, err)
+			continue
+		}
+		if cfg.Server.Port != 8080 || cfg.Server.Password != "hunter2" || cfg.Server.EnableAPI {
+			t.Errorf("%s: got port %d, password %q, enable_api %v", name, cfg.Server.Port, cfg.Server.Password, cfg.Server.EnableAPI)
+		}
+		if got := strings.Join(cfg.Scanning.Networks, " "); got != "192.168.10.0/24 10.0.5.0/24" {
+			t.Errorf("%s: networks = %q", name, got)
+		}
+		if cfg.Scanning.ScanInterval != Default().Scanning.ScanInterval {
+			t.Errorf("%s: scan_interval = %d, want the default", name, cfg.Scanning.ScanInterval)
+		}
+		n := cfg.ForNetwork("10.0.5.0/24")
+		if n.Profile != "quick" || strings.Join(n.Exclude, " ") != "10.0.5.1 10.0.5.128/25" {
+			t.Errorf("%s: network section = %+v", name, n)
+		}
+	}
+}
+
+func TestNetworkSections(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `
+[scanning]
+scan_interval = 600
+
+[network "192.168.1.0/24"]
+scan_interval = 60
+profile = Thorough
+schedule = */15 7-22 * * *; 0 23,0-6 * * *
+
+[network "10.0.0.1/8"]
+enable = false
+`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	lan := cfg.ForNetwork("192.168.1.0/24")
+	if lan.ScanInterval != 60 || lan.Profile != "thorough" || !lan.Enable || lan.Schedule != "*/15 7-22 * * *; 0 23,0-6 * * *" {
+		t.Errorf("192.168.1.0/24 = %+v", lan)
+	}
+	// The section names a host in the network, which means the network.
+	vpn := cfg.ForNetwork("10.0.0.0/8")
+	if vpn.Enable || vpn.ScanInterval != 600 || vpn.Profile != "normal" {
+		t.Errorf("10.0.0.0/8 = %+v, want disabled with the [scanning] settings", vpn)
+	}
+	other := cfg.ForNetwork("172.16.0.0/12")
+	if !other.Enable || other.ScanInterval != 600 || other.Profile != "normal" {
+		t.Errorf("a network without a section = %+v, want the [scanning] settings", other)
+	}
+}
+
+func TestScanningScheduleIsTheDefault(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `
+[scanning]
+schedule = @hourly
+
+[network "192.168.1.0/24"]
+scan_interval = 60
+
+[network "10.0.0.0/8"]
+profile = quick
+`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for cidr, want := range map[string]string{
+		"192.168.1.0/24": "", // Its own interval wins
+		"10.0.0.0/8":     "@hourly",
+		"172.16.0.0/12":  "@hourly",
+	} {
+		if got := cfg.ForNetwork(cidr).Schedule; got != want {
+			t.Errorf("schedule of %s = %q, want %q", cidr, got, want)
+		}
+	}
+}
+
+func TestCheckReportsNetworkSectionProblems(t *testing.T) {
+	path := writeConfig(t, `[network "192.168.1.0/33"]
+profile = quick
+
+[network "192.168.1.0/24"]
+scan_interval = often
+profil = quick
+
+[networks]
+`)
+	problems, err := Check(path)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	want := []string{
+		`line 1: [network "192.168.1.0/33"]: "192.168.1.0/33" is not a CIDR such as 192.168.1.0/24`,
+		`line 5: scan_interval: "often" is not a whole number`,
+		`line 6: profil: unknown setting`,
+		`line 8: unknown section [networks]`,
+	}
+	if strings.Join(problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Check =\n%s\nwant\n%s", strings.Join(problems, "\n"), strings.Join(want, "\n"))
+	}
+
+	cfg, err := Load(writeConfig(t, `[network "192.168.1.0/24"]
+scan_interval = -5
+profile = slow
+schedule = every 15 minutes
+exclude = 192.168.1.7, printer
+`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	got := strings.Join(cfg.Validate(), "\n")
+	for _, want := range []string{
+		`[network "192.168.1.0/24"] scan_interval -5 is negative`,
+		`[network "192.168.1.0/24"] schedule "every 15 minutes": expected 5 fields (minute hour day month weekday), got 3`,
+		`[network "192.168.1.0/24"] profile "slow" is not quick, normal or thorough`,
+		`[network "192.168.1.0/24"] exclude: "printer" is not an address or a CIDR`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Validate =\n%s\nwant it to include %s", got, want)
+		}
+	}
+}
+
+func TestCheckReportsYAMLProblems(t *testing.T) {
+	path := writeConfigAs(t, "config.yml", `server:
+  port: eighty
+  prot: 8080
+  allow_insecure: maybe
+  bind_address: {host: 0.0.0.0}
+colours:
+  accent: orange
+ui: dark
+`)
+
+	problems, err := Check(path)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	want := []string{
+		`line 2: port: "eighty" is not a whole number`,
+		`line 3: prot: unknown setting`,
+		`line 4: allow_insecure: "maybe" is not true or false`,
+		`line 5: bind_address: expected a value`,
+		`line 6: unknown section [colours]`,
+		`line 8: ui: expected its settings under it`,
+	}
+	if strings.Join(problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Check =\n%s\nwant\n%s", strings.Join(problems, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestYAMLSyntaxErrorIsAnError(t *testing.T) {
+	// Nothing after the error could be read, the password included, so the
+	// file must not be taken as empty.
+	path := writeConfigAs(t, "config.yaml", "server:\n  port: 8080\n password: [\n")
+	if _, err := Load(path); err == nil {
+		t.Error("Load of broken YAML succeeded")
+	}
+}
+
+func TestCheckReportsTOMLProblems(t *testing.T) {
+	path := writeConfigAs(t, "config.toml", `[server]
+port = "eighty"
+bind_address = 0.0.0.0
+prot = 8080
+just some text
+
+[[colours]]
+[colours]
+accent = "orange"
+`)
+
+	problems, err := Check(path)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	want := []string{
+		`line 2: port: "eighty" is not a whole number`,
+		`line 3: bind_address: 0.0.0.0 is not a TOML value; put text in quotes`,
+		`line 4: prot: unknown setting`,
+		`line 5: expected key = value, got "just some text"`,
+		`line 7: expected [section], got "[[colours]]"`,
+		`line 8: unknown section [colours]`,
+	}
+	if strings.Join(problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Check =\n%s\nwant\n%s", strings.Join(problems, "\n"), strings.Join(want, "\n"))
+	}
+}
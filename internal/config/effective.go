@@ -0,0 +1,320 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/291-Group/LAN-Orangutan/internal/query"
+	"github.com/291-Group/LAN-Orangutan/internal/tlscert"
+)
+
+// Setting is one setting in effect, with where its value came from.
+type Setting struct {
+	// Key is the section and name of the setting, as in "server.port".
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	// Source is "default", or where the value was set: "file PATH:LINE",
+	// "drop-in PATH:LINE", "env NAME", "flag --NAME" or "profile NAME".
+	Source string `json:"source"`
+}
+
+// SetSource records where the setting key, such as "server.port", got its
+// value, for settings changed outside Load and ApplyEnv.
+func (c *Config) SetSource(key, source string) {
+	if c.sources == nil {
+		c.sources = make(map[string]string)
+	}
+	c.sources[key] = source
+}
+
+// Source returns where the setting key got its value: "default" unless
+// SetSource, Load or ApplyEnv has recorded otherwise.
+func (c *Config) Source(key string) string {
+	if source, ok := c.sources[key]; ok {
+		return source
+	}
+	return "default"
+}
+
+// sourceKey returns the key a setting's source is recorded under. Network
+// sections are named by CIDR as Config.Network keys them, so that every way
+// of writing one network lands in the same place.
+func sourceKey(section, key string) string {
+	if cidr, ok := networkSection(section); ok {
+		section = fmt.Sprintf("network %q", networkKey(cidr))
+	} else if name, kind, ok := alertingSection(section); ok {
+		section = fmt.Sprintf("%s %q", kind, name)
+	} else if name, ok := personSection(section); ok {
+		section = fmt.Sprintf("person %q", name)
+	} else if name, ok := restrictedSection(section); ok {
+		section = fmt.Sprintf("restricted %q", name)
+	}
+	return section + "." + key
+}
+
+// Effective lists every setting with its value and source, in the order
+// config.example.ini has them. Secrets are not shown, only whether they are
+// set.
+func (c *Config) Effective() []Setting {
+	var settings []Setting
+	add := func(key, value string) {
+		settings = append(settings, Setting{Key: key, Value: value, Source: c.Source(key)})
+	}
+	itoa := strconv.Itoa
+	btoa := strconv.FormatBool
+	secret := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return "(set)"
+	}
+
+	add("server.port", itoa(c.Server.Port))
+	add("server.bind_address", c.Server.BindAddress)
+	add("server.enable_api", btoa(c.Server.EnableAPI))
+	add("server.password", secret(c.Server.Password))
+	add("server.username", c.Server.Username)
+	add("server.api_token", secret(c.Server.APIToken))
+	add("server.session_hours", itoa(c.Server.SessionHours))
+	add("server.allow_insecure", btoa(c.Server.AllowInsecure))
+	add("server.unprivileged_port", itoa(c.Server.UnprivilegedPort))
+	add("server.mdns", btoa(c.Server.MDNS))
+	add("server.mdns_name", c.Server.MDNSName)
+
+	add("ldap.url", c.LDAP.URL)
+	add("ldap.bind_dn", c.LDAP.BindDN)
+	add("ldap.bind_password", secret(c.LDAP.BindPassword))
+	add("ldap.base_dn", c.LDAP.BaseDN)
+	add("ldap.user_attribute", c.LDAP.UserAttribute)
+	add("ldap.group_attribute", c.LDAP.GroupAttribute)
+	add("ldap.group_base_dn", c.LDAP.GroupBaseDN)
+	add("ldap.start_tls", btoa(c.LDAP.StartTLS))
+	add("ldap.tls_verify", btoa(c.LDAP.TLSVerify))
+	add("ldap.ca_file", c.LDAP.CAFile)
+
+	add("oidc.issuer", c.OIDC.Issuer)
+	add("oidc.client_id", c.OIDC.ClientID)
+	add("oidc.client_secret", secret(c.OIDC.ClientSecret))
+	add("oidc.redirect_url", c.OIDC.RedirectURL)
+	add("oidc.scopes", strings.Join(c.OIDC.Scopes, " "))
+	add("oidc.username_claim", c.OIDC.UsernameClaim)
+	add("oidc.groups_claim", c.OIDC.GroupsClaim)
+	add("oidc.name", c.OIDC.Name)
+
+	add("roles.admin", strings.Join(c.Roles.Admin, ", "))
+	add("roles.editor", strings.Join(c.Roles.Editor, ", "))
+	add("roles.viewer", strings.Join(c.Roles.Viewer, ", "))
+	add("roles.default", c.Roles.Default)
+
+	add("scanning.scan_interval", itoa(c.Scanning.ScanInterval))
+	add("scanning.min_scan_interval", itoa(c.Scanning.MinScanInterval))
+	add("scanning.scan_timeout", itoa(c.Scanning.ScanTimeout))
+	add("scanning.enable_port_scan", btoa(c.Scanning.EnablePortScan))
+	add("scanning.port_scan_range", c.Scanning.PortScanRange)
+	add("scanning.networks", strings.Join(c.Scanning.Networks, ", "))
+	add("scanning.schedule", c.Scanning.Schedule)
+	add("scanning.privileged", c.Scanning.Privileged)
+	add("scanning.backends", strings.Join(c.Scanning.Backends, ", "))
+
+	cidrs := make([]string, 0, len(c.Network))
+	for cidr := range c.Network {
+		cidrs = append(cidrs, cidr)
+	}
+	sort.Strings(cidrs)
+	for _, cidr := range cidrs {
+		n := c.ForNetwork(cidr)
+		section := fmt.Sprintf("network %q.", cidr)
+		add(section+"scan_interval", itoa(n.ScanInterval))
+		if _, ok := c.sources[section+"scan_interval"]; !ok {
+			settings[len(settings)-1].Source = "same as scanning.scan_interval"
+		}
+		add(section+"schedule", n.Schedule)
+		if _, ok := c.sources[section+"schedule"]; !ok && n.Schedule != "" {
+			settings[len(settings)-1].Source = "same as scanning.schedule"
+		}
+		add(section+"profile", n.Profile)
+		add(section+"exclude", strings.Join(n.Exclude, ", "))
+		add(section+"enable", btoa(n.Enable))
+	}
+
+	add("storage.max_devices", itoa(c.Storage.MaxDevices))
+	add("storage.retention_days", itoa(c.Storage.RetentionDays))
+	add("storage.data_dir", c.Storage.DataDir)
+
+	add("approval.enable", btoa(c.Approval.Enable))
+	add("approval.auto_approve_vendors", strings.Join(c.Approval.Vendors, ", "))
+	add("approval.auto_approve_groups", strings.Join(c.Approval.Groups, ", "))
+
+	add("offline.after", query.FormatAge(c.Offline.After))
+	add("offline.groups", c.Offline.FormatGroups())
+
+	add("reports.schedule", c.Reports.Schedule)
+	add("reports.dir", c.ReportsDir())
+	add("reports.keep", itoa(c.Reports.Keep))
+
+	add("watchlist.interval", itoa(c.Watchlist.Interval))
+	add("watchlist.failures", itoa(c.Watchlist.Failures))
+
+	add("vulnscan.enable", btoa(c.VulnScan.Enable))
+	add("vulnscan.scripts", strings.Join(c.VulnScan.Scripts, ", "))
+	add("vulnscan.ports", c.VulnScan.Ports)
+	add("vulnscan.timeout", itoa(c.VulnScan.Timeout))
+
+	add("cve.enable", btoa(c.CVE.Enable))
+	add("cve.url", c.CVE.URL)
+	add("cve.api_key", secret(c.CVE.APIKey))
+	add("cve.cache", query.FormatAge(c.CVE.Cache))
+
+	add("credcheck.enable", btoa(c.CredCheck.Enable))
+	add("credcheck.types", strings.Join(c.CredCheck.Types, ", "))
+	add("credcheck.logins", secret(strings.Join(c.CredCheck.Logins, ", ")))
+	add("credcheck.delay", itoa(c.CredCheck.Delay))
+
+	add("certs.interval", query.FormatAge(c.Certs.Interval))
+	add("certs.ports", tlscert.FormatPorts(c.Certs.Ports))
+	add("certs.warn", query.FormatAge(c.Certs.Warn))
+
+	add("fingerprint.interval", query.FormatAge(c.Fingerprint.Interval))
+	add("fingerprint.mdns", btoa(c.Fingerprint.MDNS))
+
+	add("tailscale.enable", btoa(c.Tailscale.Enable))
+	add("tailscale.auto_detect", btoa(c.Tailscale.AutoDetect))
+	add("tailscale.serve", btoa(c.Tailscale.Serve))
+	add("tailscale.hostname", c.Tailscale.Hostname)
+	add("tailscale.auth_key", secret(c.Tailscale.AuthKey))
+	add("tailscale.https", btoa(c.Tailscale.HTTPS))
+
+	add("ui.theme", c.UI.Theme)
+	add("ui.language", c.UI.Language)
+
+	add("mqtt.broker", c.MQTT.Broker)
+	add("mqtt.client_id", c.MQTT.ClientID)
+	add("mqtt.username", c.MQTT.Username)
+	add("mqtt.password", secret(c.MQTT.Password))
+	add("mqtt.topic_prefix", c.MQTT.TopicPrefix)
+	add("mqtt.qos", itoa(c.MQTT.QoS))
+	add("mqtt.retain", btoa(c.MQTT.Retain))
+	add("mqtt.snapshot_interval", itoa(c.MQTT.SnapshotInterval))
+	add("mqtt.homeassistant", btoa(c.MQTT.HomeAssistant))
+	add("mqtt.discovery_prefix", c.MQTT.DiscoveryPrefix)
+
+	add("metrics.textfile", c.Metrics.Textfile)
+	add("metrics.textfile_interval", itoa(c.Metrics.TextfileInterval))
+
+	add("influxdb.url", c.InfluxDB.URL)
+	add("influxdb.org", c.InfluxDB.Org)
+	add("influxdb.bucket", c.InfluxDB.Bucket)
+	add("influxdb.token", secret(c.InfluxDB.Token))
+
+	add("zabbix.server", c.Zabbix.Server)
+	add("zabbix.host", c.Zabbix.Host)
+	add("zabbix.register", btoa(c.Zabbix.Register))
+	add("zabbix.metadata", c.Zabbix.Metadata)
+
+	add("pihole.url", c.Pihole.URL)
+	add("pihole.token", secret(c.Pihole.Token))
+	add("pihole.group_tags", btoa(c.Pihole.GroupTags))
+
+	add("adguard.url", c.AdGuard.URL)
+	add("adguard.username", c.AdGuard.Username)
+	add("adguard.password", secret(c.AdGuard.Password))
+	add("adguard.client_tags", btoa(c.AdGuard.ClientTags))
+
+	add("openwrt.url", c.OpenWrt.URL)
+	add("openwrt.username", c.OpenWrt.Username)
+	add("openwrt.password", secret(c.OpenWrt.Password))
+
+	add("firewall.type", c.Firewall.Type)
+	add("firewall.url", c.Firewall.URL)
+	add("firewall.key", secret(c.Firewall.Key))
+	add("firewall.secret", secret(c.Firewall.Secret))
+	add("firewall.ca_file", c.Firewall.CAFile)
+	add("firewall.tls_verify", btoa(c.Firewall.TLSVerify))
+
+	add("docker.enable", btoa(c.Docker.Enable))
+	add("docker.socket", c.Docker.Socket)
+
+	add("kubernetes.enable", btoa(c.Kubernetes.Enable))
+	add("kubernetes.kubeconfig", c.Kubernetes.Kubeconfig)
+	add("kubernetes.context", c.Kubernetes.Context)
+
+	add("dns.domain", c.DNS.Domain)
+	add("dns.dnsmasq_file", c.DNS.DnsmasqFile)
+
+	for _, name := range sortedKeys(c.Notify) {
+		n := c.Notify[name]
+		section := fmt.Sprintf("notify %q.", name)
+		add(section+"type", n.Type)
+		add(section+"url", secret(n.URL))
+		add(section+"channel", n.Channel)
+		add(section+"username", n.Username)
+		add(section+"token", secret(n.Token))
+		add(section+"chat_id", n.ChatID)
+		add(section+"user", n.User)
+		add(section+"room", n.Room)
+		add(section+"priority", n.Priority)
+		add(section+"secret", secret(n.Secret))
+		add(section+"body_template", n.BodyTemplate)
+		add(section+"facility", n.Facility)
+		add(section+"community", secret(n.Community))
+		add(section+"commands", btoa(n.Commands))
+		add(section+"host", n.Host)
+		add(section+"port", itoa(n.Port))
+		add(section+"security", n.Security)
+		add(section+"password", secret(n.Password))
+		add(section+"from", n.From)
+		add(section+"to", strings.Join(n.To, ", "))
+		add(section+"alerts", btoa(n.Alerts))
+		add(section+"digest", n.Digest)
+		add(section+"digest_template", n.DigestTemplate)
+	}
+	for _, name := range sortedKeys(c.Alert) {
+		a := c.Alert[name]
+		section := fmt.Sprintf("alert %q.", name)
+		add(section+"events", strings.Join(a.Events, ", "))
+		add(section+"devices", strings.Join(a.Devices, ", "))
+		add(section+"groups", strings.Join(a.Groups, ", "))
+		add(section+"notify", strings.Join(a.Notify, ", "))
+		add(section+"template", a.Template)
+		add(section+"escalate", a.Escalate)
+	}
+	for _, name := range sortedKeys(c.Escalation) {
+		add(fmt.Sprintf("escalation %q.steps", name), FormatSteps(c.Escalation[name].Steps))
+	}
+	for _, name := range sortedKeys(c.Maintenance) {
+		m := c.Maintenance[name]
+		section := fmt.Sprintf("maintenance %q.", name)
+		add(section+"schedule", m.Schedule)
+		add(section+"duration", query.FormatAge(m.Duration))
+		add(section+"devices", strings.Join(m.Devices, ", "))
+		add(section+"groups", strings.Join(m.Groups, ", "))
+		add(section+"networks", strings.Join(m.Networks, ", "))
+	}
+	for _, name := range sortedKeys(c.Person) {
+		p := c.Person[name]
+		section := fmt.Sprintf("person %q.", name)
+		add(section+"name", p.Name)
+		add(section+"macs", strings.Join(p.MACs, ", "))
+		add(section+"away_after", query.FormatAge(p.AwayAfter))
+	}
+	for _, name := range sortedKeys(c.Restricted) {
+		r := c.Restricted[name]
+		section := fmt.Sprintf("restricted %q.", name)
+		add(section+"networks", strings.Join(r.Networks, ", "))
+		add(section+"groups", strings.Join(r.Groups, ", "))
+		add(section+"allowed", strings.Join(r.Allowed, ", "))
+	}
+	return settings
+}
+
+// sortedKeys returns the keys of m, sorted.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
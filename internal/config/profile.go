@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// profileSections are the sections a profile may change settings of: which
+// networks to scan, when and how, and where to keep what is found. A laptop
+// that moves between home, office and travel networks needs those to change
+// with it, while the server, UI and Tailscale settings stay the same.
+var profileSections = []string{"scanning", "storage"}
+
+// profileSetting is a setting of a [profile "name"] section, kept until
+// UseProfile applies it.
+type profileSetting struct {
+	section, key, value string
+	// source is where the setting was made, as Source reports it.
+	source string
+}
+
+// profileSection returns the name of a section such as profile "home", or
+// profile.home or profile."home" as TOML writes it.
+func profileSection(section string) (name string, ok bool) {
+	if name, ok := namedSection(section, "profile"); ok {
+		return name, true
+	}
+	name, ok = strings.CutPrefix(section, "profile.")
+	if !ok || strings.ContainsAny(name, `"' `) {
+		return "", false
+	}
+	return name, true
+}
+
+// addProfile records that the profile name exists, even if it sets nothing.
+func (c *Config) addProfile(name string) {
+	if c.profiles == nil {
+		c.profiles = make(map[string][]profileSetting)
+	}
+	if _, ok := c.profiles[name]; !ok {
+		c.profiles[name] = nil
+	}
+}
+
+// setProfileValue records key = value in the profile name, to be applied by
+// UseProfile. The value is checked now, so that a mistake in a profile is
+// reported with its line whether or not the profile is in use.
+func (c *Config) setProfileValue(name, key, value, source string) error {
+	for _, section := range profileSections {
+		err := Default().setValue(section, key, value)
+		if err == errUnknownKey {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		c.addProfile(name)
+		c.profiles[name] = append(c.profiles[name], profileSetting{section, key, value, source})
+		return nil
+	}
+	return errUnknownKey
+}
+
+// Profiles returns the names of the profiles in the config, sorted.
+func (c *Config) Profiles() []string {
+	names := make([]string, 0, len(c.profiles))
+	for name := range c.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Profile returns the name of the profile in use, or "" for none.
+func (c *Config) Profile() string {
+	return c.profile
+}
+
+// UseProfile applies the settings of the profile name over those of
+// [scanning] and [storage]. "" means no profile, leaving c as it is.
+//
+// A profile that does not set data_dir keeps its devices in a directory of
+// its own under the data directory, so that a laptop carried from home to the
+// office does not report the office's devices as new at home and the home's
+// as missing at the office.
+func (c *Config) UseProfile(name string) error {
+	if name == "" {
+		return nil
+	}
+	name = strings.ToLower(name)
+	settings, ok := c.profiles[name]
+	if !ok {
+		if len(c.profiles) == 0 {
+			return fmt.Errorf("profile %q: the config has no profile sections", name)
+		}
+		return fmt.Errorf("profile %q is not in the config, which has %s", name, strings.Join(c.Profiles(), ", "))
+	}
+
+	ownDataDir := false
+	for _, s := range settings {
+		// Checked by setProfileValue when the file was read.
+		_ = c.setValue(s.section, s.key, s.value)
+		c.SetSource(s.section+"."+s.key, s.source)
+		if s.section == "storage" && s.key == "data_dir" {
+			ownDataDir = true
+		}
+	}
+	if !ownDataDir {
+		c.Storage.DataDir = filepath.Join(c.Storage.DataDir, "profiles", name)
+		c.SetSource("storage.data_dir", "profile "+name)
+	}
+	c.profile = name
+	return nil
+}
+
+// isProfileSection reports whether section is a profile section.
+func isProfileSection(section string) bool {
+	_, ok := profileSection(section)
+	return ok
+}
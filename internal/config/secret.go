@@ -0,0 +1,109 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// secretKeys are the settings that hold credentials. Their values may be
+// references instead of the secret itself:
+//
+//	password = file:/etc/lan-orangutan/password
+//	api_token = env:ORANGUTAN_TOKEN
+//
+// so that the config file can be shared, backed up or left readable without
+// giving the credentials away.
+var secretKeys = map[string]bool{
+	"server.password":    true,
+	"server.api_token":   true,
+	"ldap.bind_password": true,
+	"oidc.client_secret": true,
+	"mqtt.password":      true,
+	"influxdb.token":     true,
+	"tailscale.auth_key": true,
+	"pihole.token":       true,
+	"adguard.password":   true,
+	"openwrt.password":   true,
+	"firewall.key":       true,
+	"firewall.secret":    true,
+}
+
+// notifySecretKeys are the settings of [notify "name"] sections that hold
+// credentials. A webhook's URL is one: whoever has it can post.
+var notifySecretKeys = map[string]bool{
+	"url":       true,
+	"token":     true,
+	"password":  true,
+	"secret":    true,
+	"community": true,
+}
+
+// isSecretKey reports whether key in section holds credentials.
+func isSecretKey(section, key string) bool {
+	if _, kind, ok := alertingSection(section); ok && kind == "notify" {
+		return notifySecretKeys[key]
+	}
+	return secretKeys[section+"."+key]
+}
+
+// resolveSecret returns the secret value refers to: the contents of a file
+// for file:PATH, with a relative PATH taken from dir, the config file's
+// directory; an environment variable for env:NAME; or value itself. literal
+// reports that the secret was written out in the config file.
+func resolveSecret(value, dir string) (secret string, literal bool, err error) {
+	if path, ok := strings.CutPrefix(value, "file:"); ok {
+		path = strings.TrimSpace(path)
+		if path != "" && !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		secret, err := readSecretFile(path)
+		return secret, false, err
+	}
+	if name, ok := strings.CutPrefix(value, "env:"); ok {
+		name = strings.TrimSpace(name)
+		secret := os.Getenv(name)
+		if secret == "" {
+			return "", false, fmt.Errorf("environment variable %q is not set", name)
+		}
+		return secret, false, nil
+	}
+	return value, true, nil
+}
+
+// readSecretFile reads a secret from path, which only its owner may read.
+func readSecretFile(path string) (string, error) {
+	if path == "" {
+		return "", errors.New("file: needs the path of the file holding the secret")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read secret file: %w", err)
+	}
+	// Windows does not keep Unix permissions; its ACLs are left to the user.
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		return "", fmt.Errorf("secret file %s is open to other users; run chmod 600 %s", path, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read secret file: %w", err)
+	}
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return "", fmt.Errorf("secret file %s is empty", path)
+	}
+	return secret, nil
+}
+
+// readableByOthers reports whether users other than the owner of the file
+// at path may read it. Always false on Windows, as readSecretFile explains.
+func readableByOthers(path string) bool {
+	if runtime.GOOS == "windows" {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().Perm()&0o044 != 0
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML reads the part of TOML a config file needs: [section] tables,
+// key = value lines, and values that are strings, numbers, booleans or
+// arrays of those, which may run over several lines:
+//
+//	[scanning]
+//	scan_interval = 300
+//	networks = ["192.168.10.0/24", "10.0.5.0/24"]
+//
+//	[network."192.168.10.0/24"]
+//	profile = "quick"
+//
+// An array is joined with commas, which is how the INI format writes one.
+// Anything else TOML allows, such as inline tables, is reported as a problem
+// with its line like any other setting that cannot be read.
+func parseTOML(data []byte) []entry {
+	var entries []entry
+	var currentSection string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(stripTOMLComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			name := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, "["), "]"))
+			if !strings.HasSuffix(line, "]") || strings.HasPrefix(name, "[") {
+				entries = append(entries, entry{line: lineNo, problem: fmt.Sprintf("expected [section], got %q", line)})
+				continue
+			}
+			// A quoted name is taken whole; a dotted one such as
+			// network."10.0.0.0/8" keeps its quotes for networkSection.
+			if len(name) >= 2 && (name[0] == '"' || name[0] == '\'') && name[len(name)-1] == name[0] {
+				name = name[1 : len(name)-1]
+			}
+			currentSection = strings.ToLower(name)
+			entries = append(entries, entry{line: lineNo, section: currentSection, header: true})
+			continue
+		}
+
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			entries = append(entries, entry{line: lineNo, problem: fmt.Sprintf("expected key = value, got %q", line)})
+			continue
+		}
+		e := entry{
+			line:    lineNo,
+			section: currentSection,
+			key:     strings.ToLower(strings.Trim(strings.TrimSpace(key), `"'`)),
+		}
+		raw = strings.TrimSpace(raw)
+
+		// An array may go on over the following lines until it is closed.
+		for strings.HasPrefix(raw, "[") && !tomlArrayClosed(raw) && scanner.Scan() {
+			lineNo++
+			raw += " " + strings.TrimSpace(stripTOMLComment(scanner.Text()))
+		}
+
+		value, err := tomlValue(raw)
+		if err != nil {
+			e.problem = fmt.Sprintf("%s: %v", e.key, err)
+		}
+		e.value = value
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// tomlValue returns a value as the INI format would write it.
+func tomlValue(raw string) (string, error) {
+	switch {
+	case raw == "":
+		return "", fmt.Errorf("missing value")
+	case strings.HasPrefix(raw, `"""`), strings.HasPrefix(raw, "'''"):
+		return "", fmt.Errorf("multi-line strings are not supported")
+	case raw[0] == '"':
+		v, err := strconv.Unquote(raw)
+		if err != nil {
+			return "", fmt.Errorf("%s is not a string in double quotes", raw)
+		}
+		return v, nil
+	case raw[0] == '\'':
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") || strings.Contains(raw[1:len(raw)-1], "'") {
+			return "", fmt.Errorf("%s is not a string in single quotes", raw)
+		}
+		return raw[1 : len(raw)-1], nil
+	case raw[0] == '[':
+		if !tomlArrayClosed(raw) || !strings.HasSuffix(raw, "]") {
+			return "", fmt.Errorf("array is not closed with ]")
+		}
+		var items []string
+		for _, item := range splitTOMLArray(raw[1 : len(raw)-1]) {
+			if strings.HasPrefix(item, "[") {
+				return "", fmt.Errorf("arrays of arrays are not supported")
+			}
+			v, err := tomlValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, v)
+		}
+		return strings.Join(items, ", "), nil
+	case raw == "true", raw == "false":
+		return raw, nil
+	}
+	// Numbers may be split up with underscores, as in 1_000.
+	number := strings.ReplaceAll(raw, "_", "")
+	if _, err := strconv.ParseInt(number, 0, 64); err == nil {
+		return number, nil
+	}
+	if _, err := strconv.ParseFloat(number, 64); err == nil {
+		return number, nil
+	}
+	return "", fmt.Errorf("%s is not a TOML value; put text in quotes", raw)
+}
+
+// stripTOMLComment removes a # comment from line, leaving a # inside a
+// string alone.
+func stripTOMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++ // The escaped character cannot end the string.
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// tomlArrayClosed reports whether every [ in raw outside a string has been
+// matched by a ].
+func tomlArrayClosed(raw string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+// splitTOMLArray splits the inside of an array at its top-level commas,
+// dropping the empty item a trailing comma leaves.
+func splitTOMLArray(s string) []string {
+	var items []string
+	depth, start := 0, 0
+	var quote byte
+	add := func(item string) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == ',' && depth == 0:
+			add(s[start:i])
+			start = i + 1
+		}
+	}
+	add(s[start:])
+	return items
+}
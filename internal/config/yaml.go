@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseYAML reads the YAML format, where each section is a mapping of its
+// settings:
+//
+//	server:
+//	  port: 291
+//	scanning:
+//	  networks: [192.168.10.0/24, 10.0.5.0/24]
+//	network:
+//	  192.168.10.0/24:
+//	    profile: quick
+//	profile:
+//	  office:
+//	    data_dir: /srv/orangutan/office
+//
+// The network sections go under network:, each keyed by its CIDR, and the
+// profile, notify and alert sections under profile:, notify: and alert:,
+// each keyed by its name. A list is joined
+// with commas, which is how the INI format writes one.
+//
+// Unlike a bad line in the other formats, a syntax error is returned rather
+// than skipped: YAML cannot be read past one, so skipping it would silently
+// drop every setting in the file, the password and API token among them.
+func parseYAML(data []byte) ([]entry, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil // An empty file, or only comments
+	}
+	root := doc.Content[0]
+	if isYAMLNull(root) {
+		return nil, nil
+	}
+	if root.Kind != yaml.MappingNode {
+		return []entry{{line: root.Line, problem: "expected sections such as server:"}}, nil
+	}
+
+	var entries []entry
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		name, body := root.Content[i], root.Content[i+1]
+		section := strings.ToLower(name.Value)
+		switch section {
+		case "network":
+			entries = append(entries, yamlNamed(section, "networks such as 192.168.1.0/24:", body)...)
+			continue
+		case "profile":
+			entries = append(entries, yamlNamed(section, "profiles such as home:", body)...)
+			continue
+		case "notify", "alert":
+			entries = append(entries, yamlNamed(section, "names such as team:", body)...)
+			continue
+		}
+		entries = append(entries, entry{line: name.Line, section: section, header: true})
+		entries = append(entries, yamlSection(section, body)...)
+	}
+	return entries, nil
+}
+
+// yamlNamed reads the network, profile, notify or alert mapping, which holds
+// a section for each, keyed by its CIDR or name. expected describes what
+// belongs under it.
+func yamlNamed(kind, expected string, body *yaml.Node) []entry {
+	if isYAMLNull(body) {
+		return nil
+	}
+	if body.Kind != yaml.MappingNode {
+		return []entry{{line: body.Line, problem: fmt.Sprintf("%s: expected %s under it", kind, expected)}}
+	}
+	var entries []entry
+	for i := 0; i+1 < len(body.Content); i += 2 {
+		name, settings := body.Content[i], body.Content[i+1]
+		section := fmt.Sprintf("%s %q", kind, strings.ToLower(name.Value))
+		entries = append(entries, entry{line: name.Line, section: section, header: true})
+		entries = append(entries, yamlSection(section, settings)...)
+	}
+	return entries
+}
+
+// yamlSection reads the settings of one section from its mapping.
+func yamlSection(section string, body *yaml.Node) []entry {
+	if isYAMLNull(body) {
+		return nil
+	}
+	if body.Kind != yaml.MappingNode {
+		if knownSection(section) {
+			return []entry{{line: body.Line, problem: fmt.Sprintf("%s: expected its settings under it", section)}}
+		}
+		return nil
+	}
+	var entries []entry
+	for j := 0; j+1 < len(body.Content); j += 2 {
+		key, value := body.Content[j], body.Content[j+1]
+		e := entry{line: key.Line, section: section, key: strings.ToLower(key.Value)}
+		v, err := yamlValue(value)
+		if err != nil {
+			e.problem = fmt.Sprintf("%s: %v", e.key, err)
+		}
+		e.value = v
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// yamlValue returns a setting's value as the INI format would write it.
+func yamlValue(n *yaml.Node) (string, error) {
+	switch {
+	case isYAMLNull(n):
+		return "", nil
+	case n.Kind == yaml.ScalarNode:
+		return n.Value, nil
+	case n.Kind == yaml.SequenceNode:
+		items := make([]string, len(n.Content))
+		for i, item := range n.Content {
+			if item.Kind != yaml.ScalarNode {
+				return "", fmt.Errorf("expected a list of values")
+			}
+			items[i] = item.Value
+		}
+		return strings.Join(items, ", "), nil
+	}
+	return "", fmt.Errorf("expected a value")
+}
+
+// isYAMLNull reports whether n is empty, as a key with nothing after it is.
+func isYAMLNull(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && n.Tag == "!!null"
+}
@@ -0,0 +1,288 @@
+// Package credcheck tries the logins devices ship with against the admin
+// interfaces of the routers, cameras and printers on the network, and
+// reports those that still let them in.
+//
+// It is careful about it. Only a handful of logins are tried, one at a time
+// with a pause between them, and it stops at the first that works, so that
+// a device that locks out repeated failures is unlikely to. Web interfaces
+// are tried only when they ask for HTTP basic authentication, which says
+// plainly whether a login worked; login forms differ from maker to maker
+// and are left alone. An SSH login that works is closed at once, without
+// running anything.
+package credcheck
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// DefaultTypes are the device types checked unless the config says
+// otherwise: those most often left with the logins they shipped with.
+var DefaultTypes = []string{"router", "camera", "printer"}
+
+// DefaultDelay is the pause between two tries at one device.
+const DefaultDelay = 2 * time.Second
+
+// dialTimeout bounds connecting to each port and each login, so that a
+// device that is not listening does not hold the check up.
+const dialTimeout = 5 * time.Second
+
+// Login is a user name and password to try.
+type Login struct {
+	User     string
+	Password string
+}
+
+// String writes the login as findings name it, as in "admin/admin".
+func (l Login) String() string {
+	if l.Password == "" {
+		return l.User + " with no password"
+	}
+	return l.User + "/" + l.Password
+}
+
+// ParseLogin reads a login written as user:password; the password may be
+// empty.
+func ParseLogin(s string) (Login, error) {
+	user, password, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok || user == "" {
+		return Login{}, fmt.Errorf("%q is not a login written as user:password", s)
+	}
+	return Login{User: user, Password: password}, nil
+}
+
+// DefaultLogins are the logins tried on each type of device: the factory
+// defaults of the makers most often found. Kept short on purpose; this is
+// a check for the obvious, not a password guesser.
+var DefaultLogins = map[string][]Login{
+	"router": {
+		{"admin", "admin"}, {"admin", "password"}, {"admin", ""}, {"admin", "1234"}, {"root", "admin"},
+	},
+	"camera": {
+		{"admin", "admin"}, {"admin", "12345"}, {"admin", ""}, {"root", "pass"}, {"admin", "123456"},
+	},
+	"printer": {
+		{"admin", ""}, {"admin", "admin"}, {"admin", "1234"}, {"root", ""},
+	},
+}
+
+// fallbackLogins are tried on devices of a type DefaultLogins does not
+// know, when the config checks more types.
+var fallbackLogins = []Login{{"admin", "admin"}, {"admin", "password"}, {"admin", ""}, {"root", "root"}}
+
+// target is a port an admin interface may listen on, and how to log in.
+type target struct {
+	port int
+	// kind is http, https or ssh.
+	kind string
+}
+
+// defaultTargets are the ports tried, web interfaces first.
+var defaultTargets = []target{
+	{80, "http"}, {443, "https"}, {8080, "http"}, {8443, "https"}, {22, "ssh"},
+}
+
+// Options say what to try.
+type Options struct {
+	// Logins replace DefaultLogins for every type when set.
+	Logins []Login
+	// Delay is the pause between two tries at one device; DefaultDelay
+	// if 0.
+	Delay time.Duration
+}
+
+// Checker tries default logins on devices.
+type Checker struct {
+	opts    Options
+	targets []target
+}
+
+// New returns a checker trying what opts say.
+func New(opts Options) *Checker {
+	if opts.Delay <= 0 {
+		opts.Delay = DefaultDelay
+	}
+	return &Checker{opts: opts, targets: defaultTargets}
+}
+
+// logins returns the logins to try on a device of type deviceType.
+func (c *Checker) logins(deviceType string) []Login {
+	if len(c.opts.Logins) > 0 {
+		return c.opts.Logins
+	}
+	if logins, ok := DefaultLogins[deviceType]; ok {
+		return logins
+	}
+	return fallbackLogins
+}
+
+// Check tries the logins for a device of type deviceType on each admin
+// interface of the device at ip, and returns a finding for each interface
+// that let one in. An interface that is not there, or that cannot be tried
+// safely, is skipped.
+func (c *Checker) Check(ctx context.Context, ip, deviceType string) ([]types.Finding, error) {
+	logins := c.logins(deviceType)
+	var findings []types.Finding
+	first := true
+	for _, t := range c.targets {
+		addr := net.JoinHostPort(ip, strconv.Itoa(t.port))
+		conn, err := (&net.Dialer{Timeout: dialTimeout}).DialContext(ctx, "tcp", addr)
+		if err != nil {
+			if ctx.Err() != nil {
+				return findings, ctx.Err()
+			}
+			continue
+		}
+		conn.Close()
+
+		var try func(context.Context, string, Login) (bool, error)
+		what := "SSH"
+		switch t.kind {
+		case "http", "https":
+			if !asksBasicAuth(ctx, t.kind, addr) {
+				continue
+			}
+			try = tryHTTP(t.kind)
+			what = "Web interface"
+		case "ssh":
+			try = trySSH
+		}
+
+		for _, login := range logins {
+			if !first {
+				select {
+				case <-ctx.Done():
+					return findings, ctx.Err()
+				case <-time.After(c.opts.Delay):
+				}
+			}
+			first = false
+			ok, err := try(ctx, addr, login)
+			if err != nil {
+				// The interface went away, stopped answering or gave an answer
+				// that says nothing; leave it be.
+				break
+			}
+			if ok {
+				findings = append(findings, types.Finding{
+					Port:     t.port,
+					Protocol: "tcp",
+					Check:    types.CheckDefaultLogins,
+					Title:    fmt.Sprintf("%s accepts the login %s", what, login),
+					Severity: types.SeverityHigh,
+				})
+				break
+			}
+		}
+	}
+	return findings, nil
+}
+
+// httpClient sends the requests to web interfaces. Devices serve their own
+// certificates, so they are not verified, and redirects are not followed: a
+// login that works is told by the answer to the request it was sent with.
+var httpClient = &http.Client{
+	Timeout:   dialTimeout,
+	Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// asksBasicAuth reports whether the web interface at addr answers its front
+// page with a request for HTTP basic authentication.
+func asksBasicAuth(ctx context.Context, scheme, addr string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, scheme+"://"+addr+"/", nil)
+	if err != nil {
+		return false
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+	for _, challenge := range resp.Header.Values("WWW-Authenticate") {
+		if strings.HasPrefix(strings.ToLower(challenge), "basic") {
+			return true
+		}
+	}
+	return false
+}
+
+// tryHTTP returns a function that logs in to a web interface with basic
+// authentication.
+func tryHTTP(scheme string) func(context.Context, string, Login) (bool, error) {
+	return func(ctx context.Context, addr string, login Login) (bool, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, scheme+"://"+addr+"/", nil)
+		if err != nil {
+			return false, err
+		}
+		req.SetBasicAuth(login.User, login.Password)
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return false, err
+		}
+		resp.Body.Close()
+		// Only a page, or a redirect into the interface, means it let the
+		// login in; any other answer says nothing either way.
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			return false, nil
+		case resp.StatusCode < 200 || resp.StatusCode >= 400:
+			return false, fmt.Errorf("%s answered %s", addr, resp.Status)
+		}
+		return true, nil
+	}
+}
+
+// trySSH logs in to an SSH server with a password, and closes the
+// connection as soon as it is in.
+func trySSH(ctx context.Context, addr string, login Login) (bool, error) {
+	conn, err := (&net.Dialer{Timeout: dialTimeout}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	config := &ssh.ClientConfig{
+		User: login.User,
+		Auth: []ssh.AuthMethod{
+			ssh.Password(login.Password),
+			// Some devices ask through keyboard-interactive instead.
+			ssh.KeyboardInteractive(func(_, _ string, questions []string, _ []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = login.Password
+				}
+				return answers, nil
+			}),
+		},
+		// Only a factory default is sent, so whose key it is does not
+		// matter.
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         dialTimeout,
+	}
+	client, _, _, err := ssh.NewClientConn(conn, addr, config)
+	if err == nil {
+		client.Close()
+		return true, nil
+	}
+	// The login was refused, as opposed to the connection failing.
+	if strings.Contains(err.Error(), "unable to authenticate") {
+		return false, nil
+	}
+	return false, err
+}
@@ -0,0 +1,186 @@
+package credcheck
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// port returns the port of a listener on 127.0.0.1.
+func port(t *testing.T, addr string) int {
+	t.Helper()
+	_, p, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, _ := strconv.Atoi(p)
+	return n
+}
+
+// basicAuthServer asks for basic authentication and lets user:password in,
+// counting the tries.
+func basicAuthServer(t *testing.T, user, password string, tries *int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		if ok {
+			*tries++
+		}
+		if !ok || u != user || p != password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="router"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("status"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// sshServer lets user:password in over SSH, and closes each connection
+// after the login.
+func sshServer(t *testing.T, user, password string) net.Listener {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, p []byte) (*ssh.Permissions, error) {
+			if c.User() == user && string(p) == password {
+				return nil, nil
+			}
+			return nil, ssh.ErrNoAuth
+		},
+	}
+	config.AddHostKey(signer)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if sc, _, _, err := ssh.NewServerConn(conn, config); err == nil {
+					sc.Close()
+				}
+			}()
+		}
+	}()
+	return ln
+}
+
+func TestCheck(t *testing.T) {
+	var routerTries, safeTries int
+	router := basicAuthServer(t, "admin", "password", &routerTries)
+	safe := basicAuthServer(t, "admin", "long-and-random", &safeTries)
+	form := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<form method="post"><input name="password"></form>`))
+	}))
+	defer form.Close()
+	ssh := sshServer(t, "root", "")
+
+	// A port nothing listens on.
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+
+	c := New(Options{Delay: time.Millisecond})
+	c.targets = []target{
+		{port(t, router.Listener.Addr().String()), "http"},
+		{port(t, safe.Listener.Addr().String()), "http"},
+		{port(t, form.Listener.Addr().String()), "http"},
+		{port(t, closed.Addr().String()), "http"},
+		{port(t, ssh.Addr().String()), "ssh"},
+	}
+	findings, err := c.Check(context.Background(), "127.0.0.1", "printer")
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	// admin/password is not among the printer logins.
+	if routerTries != len(DefaultLogins["printer"]) || safeTries != len(DefaultLogins["printer"]) {
+		t.Errorf("tries = %d and %d; want each printer login once on each", routerTries, safeTries)
+	}
+	if len(findings) != 1 || findings[0].Title != "SSH accepts the login root with no password" || findings[0].Check != types.CheckDefaultLogins || findings[0].Severity != types.SeverityHigh {
+		t.Fatalf("findings = %+v; want the SSH login", findings)
+	}
+
+	routerTries, safeTries = 0, 0
+	findings, err = c.Check(context.Background(), "127.0.0.1", "router")
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if len(findings) != 1 || !strings.Contains(findings[0].Title, "Web interface accepts the login admin/password") {
+		t.Fatalf("findings = %+v; want the router's web login", findings)
+	}
+	if routerTries != 2 {
+		t.Errorf("router tried %d times; want it left alone after the login that worked", routerTries)
+	}
+}
+
+func TestTryHTTP(t *testing.T) {
+	for status, want := range map[int]bool{
+		http.StatusOK:                  true,
+		http.StatusFound:               true,
+		http.StatusUnauthorized:        false,
+		http.StatusNotFound:            false,
+		http.StatusTooManyRequests:     false,
+		http.StatusInternalServerError: false,
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if status == http.StatusFound {
+				w.Header().Set("Location", "/index.html")
+			}
+			w.WriteHeader(status)
+		}))
+		accepted, err := tryHTTP("http")(context.Background(), srv.Listener.Addr().String(), Login{User: "admin", Password: "admin"})
+		srv.Close()
+		if accepted != want {
+			t.Errorf("status %d: accepted = %v, %v; want %v", status, accepted, err, want)
+		}
+		if !want && status != http.StatusUnauthorized && err == nil {
+			t.Errorf("status %d: no error; want the answer reported as telling nothing", status)
+		}
+	}
+}
+
+func TestParseLogin(t *testing.T) {
+	for s, want := range map[string]Login{
+		"admin:admin": {"admin", "admin"},
+		" root: ":     {"root", ""},
+		"admin:":      {"admin", ""},
+		"user:pa:ss":  {"user", "pa:ss"},
+	} {
+		if got, err := ParseLogin(s); err != nil || got != want {
+			t.Errorf("ParseLogin(%q) = %+v, %v; want %+v", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"admin", ":secret", ""} {
+		if _, err := ParseLogin(s); err == nil {
+			t.Errorf("ParseLogin(%q) accepted it", s)
+		}
+	}
+}
@@ -0,0 +1,583 @@
+// Package cve looks the services deep scans identify up in the National
+// Vulnerability Database, so that a device running a version with known
+// holes is said to, with how bad each is, even when the NSE scripts that ran
+// did not look. What NVD says of each product is kept in a cache file and
+// asked again only once it is older than the cache allows: the database
+// changes by the day, not the minute, and answers slowly to those without an
+// API key.
+//
+// It also sums up the findings of each device, from every check, for the
+// vulnerable devices view.
+package cve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// DefaultURL is NVD's CVE API.
+const DefaultURL = "https://services.nvd.nist.gov/rest/json/cves/2.0"
+
+// DefaultMaxAge is how long what NVD said of a product is trusted when
+// Options give no MaxAge.
+const DefaultMaxAge = 7 * 24 * time.Hour
+
+// Check is what findings from NVD give as their check.
+const Check = "nvd"
+
+// requestTimeout bounds each request. NVD is slow with long answers, such as
+// the hundreds of CVEs of an old Linux kernel.
+const requestTimeout = time.Minute
+
+// pageSize is how many CVEs are asked for at a time, the most NVD gives.
+const pageSize = 2000
+
+// NVD allows five requests in thirty seconds without an API key and fifty
+// with one; requests are spaced out to stay within that.
+const (
+	requestGap        = 6 * time.Second
+	requestGapWithKey = 600 * time.Millisecond
+)
+
+// titleLength caps the title of a finding, taken from the start of the
+// CVE's description.
+const titleLength = 100
+
+// Options say where to ask and where to keep the answers.
+type Options struct {
+	// URL is the CVE API; DefaultURL if empty.
+	URL string
+	// APIKey is an NVD API key, which lets lookups go ten times faster.
+	APIKey string
+	// CacheFile keeps what NVD said between runs. Empty keeps it in memory.
+	CacheFile string
+	// MaxAge is how long a product's CVEs are trusted before they are asked
+	// again; DefaultMaxAge if 0.
+	MaxAge time.Duration
+}
+
+// CVE is a known vulnerability, as much of it as findings need.
+type CVE struct {
+	ID          string  `json:"id"`
+	Description string  `json:"description"`
+	CVSS        float64 `json:"cvss,omitempty"`
+	// Exploit is set when NVD links to an exploit for it.
+	Exploit bool `json:"exploit,omitempty"`
+}
+
+// cacheEntry is what NVD said of one product, and when.
+type cacheEntry struct {
+	Fetched time.Time `json:"fetched"`
+	CVEs    []CVE     `json:"cves"`
+}
+
+// Client looks products up in NVD.
+type Client struct {
+	opts Options
+	http *http.Client
+	// now is the clock, replaced in tests.
+	now func() time.Time
+	// gap is how long to wait between requests, 0 in tests.
+	gap time.Duration
+
+	mu      sync.Mutex
+	cache   map[string]cacheEntry
+	loaded  bool
+	lastAsk time.Time
+}
+
+// New returns a client asking the NVD opts describe.
+func New(opts Options) *Client {
+	if opts.URL == "" {
+		opts.URL = DefaultURL
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = DefaultMaxAge
+	}
+	gap := requestGap
+	if opts.APIKey != "" {
+		gap = requestGapWithKey
+	}
+	return &Client{opts: opts, http: &http.Client{Timeout: requestTimeout}, now: time.Now, gap: gap}
+}
+
+// ValidURL checks that u is an http or https URL the CVE API can be reached
+// at.
+func ValidURL(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("%q is not an http or https URL", u)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("%q has no host", u)
+	}
+	return nil
+}
+
+// CPEName turns a CPE as nmap gives it, in the URI form of CPE 2.2 such as
+// "cpe:/a:openbsd:openssh:7.4", into the CPE 2.3 name NVD looks up, as in
+// "cpe:2.3:a:openbsd:openssh:7.4:*:*:*:*:*:*:*". It returns "" for one
+// without a version: every CVE the product ever had would match it.
+func CPEName(cpe string) string {
+	rest, ok := strings.CutPrefix(strings.ToLower(strings.TrimSpace(cpe)), "cpe:/")
+	if !ok {
+		return ""
+	}
+	parts := strings.Split(rest, ":")
+	if len(parts) < 4 || parts[1] == "" || parts[2] == "" || parts[3] == "" {
+		return ""
+	}
+	switch parts[0] {
+	case "a", "o", "h":
+	default:
+		return ""
+	}
+	// Part, vendor, product, version, update and edition; language and
+	// the packed extended attributes of the URI form are left as any.
+	if len(parts) > 6 {
+		parts = parts[:6]
+	}
+	name := []string{"cpe", "2.3"}
+	for i, p := range parts {
+		if i > 0 {
+			if decoded, err := url.PathUnescape(p); err == nil {
+				p = decoded
+			}
+		}
+		if p == "" {
+			p = "*"
+		} else if i > 0 {
+			p = quoteCPE(p)
+		}
+		name = append(name, p)
+	}
+	for len(name) < 13 {
+		name = append(name, "*")
+	}
+	return strings.Join(name, ":")
+}
+
+// quoteCPE escapes what the formatted string binding of CPE 2.3 needs
+// escaped: anything but letters, digits and _ - and the dot.
+func quoteCPE(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+		default:
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Lookup returns the CVEs NVD gives for the product cpeName names, from the
+// cache while it is fresh enough. refresh asks NVD again regardless.
+func (c *Client) Lookup(ctx context.Context, cpeName string, refresh bool) ([]CVE, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.loadLocked(); err != nil {
+		return nil, err
+	}
+	if entry, ok := c.cache[cpeName]; ok && !refresh && c.now().Sub(entry.Fetched) < c.opts.MaxAge {
+		return entry.CVEs, nil
+	}
+	cves, err := c.fetchLocked(ctx, cpeName)
+	if err != nil {
+		return nil, err
+	}
+	c.cache[cpeName] = cacheEntry{Fetched: c.now(), CVEs: cves}
+	if err := c.saveLocked(); err != nil {
+		return nil, err
+	}
+	return cves, nil
+}
+
+// Findings looks up the products of the services scan found and returns a
+// finding for each CVE of theirs, save those the scan's own checks already
+// reported. Services whose product nmap could not tell the version of are
+// skipped.
+func (c *Client) Findings(ctx context.Context, scan types.DeepScan, refresh bool) ([]types.Finding, error) {
+	seen := make(map[string]bool)
+	for _, f := range scan.Findings {
+		if f.Check != Check && f.ID != "" {
+			seen[f.ID] = true
+		}
+	}
+	asked := make(map[string]bool)
+	var findings []types.Finding
+	for _, s := range scan.Services {
+		for _, cpe := range s.CPE {
+			name := CPEName(cpe)
+			if name == "" {
+				continue
+			}
+			// Several services may run the same product; NVD is asked
+			// about it once.
+			cves, err := c.Lookup(ctx, name, refresh && !asked[name])
+			if err != nil {
+				return nil, err
+			}
+			asked[name] = true
+			for _, v := range cves {
+				if seen[v.ID] {
+					continue
+				}
+				seen[v.ID] = true
+				// A CVE NVD has yet to score is still a known hole.
+				severity := types.SeverityMedium
+				if v.CVSS > 0 {
+					severity = types.CVSSSeverity(v.CVSS)
+				}
+				findings = append(findings, types.Finding{
+					Port:     s.Port,
+					Protocol: s.Protocol,
+					Check:    Check,
+					ID:       v.ID,
+					Title:    title(v, s),
+					Severity: severity,
+					CVSS:     v.CVSS,
+					Exploit:  v.Exploit,
+				})
+			}
+		}
+	}
+	return findings, nil
+}
+
+// Store is where deep scans come from and what lookups find goes; the
+// storage is one.
+type Store interface {
+	GetDeepScan(ip string) *types.DeepScan
+	SetFindings(ip, check string, findings []types.Finding) (bool, error)
+}
+
+// Update looks up the services of the latest deep scan of each device at
+// ips and records the CVEs found among its findings, in place of those an
+// earlier lookup found. Devices no deep scan found services on are skipped.
+func (c *Client) Update(ctx context.Context, store Store, ips []string, refresh bool) error {
+	for _, ip := range ips {
+		scan := store.GetDeepScan(ip)
+		if scan == nil || len(scan.Services) == 0 {
+			continue
+		}
+		findings, err := c.Findings(ctx, *scan, refresh)
+		if err != nil {
+			return fmt.Errorf("%s: %w", ip, err)
+		}
+		if _, err := store.SetFindings(ip, Check, findings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// title names a CVE of service s by the first sentence of its description,
+// or by the service when it has none.
+func title(v CVE, s types.Service) string {
+	text := strings.TrimSpace(v.Description)
+	if i := strings.Index(text, ". "); i >= 0 {
+		text = text[:i]
+	}
+	text = strings.TrimSuffix(text, ".")
+	if text == "" {
+		text = "Known vulnerability"
+		if what := strings.TrimSpace(s.Product + " " + s.Version); what != "" {
+			text += " in " + what
+		}
+		return text
+	}
+	if utf8.RuneCountInString(text) > titleLength {
+		text = string([]rune(text)[:titleLength-1]) + "…"
+	}
+	return text
+}
+
+// nvdResponse is the part of an answer of NVD's CVE API that is read.
+type nvdResponse struct {
+	StartIndex      int `json:"startIndex"`
+	TotalResults    int `json:"totalResults"`
+	Vulnerabilities []struct {
+		CVE struct {
+			ID           string `json:"id"`
+			Descriptions []struct {
+				Lang  string `json:"lang"`
+				Value string `json:"value"`
+			} `json:"descriptions"`
+			Metrics struct {
+				V31 []nvdMetric `json:"cvssMetricV31"`
+				V30 []nvdMetric `json:"cvssMetricV30"`
+				V2  []nvdMetric `json:"cvssMetricV2"`
+			} `json:"metrics"`
+			References []struct {
+				Tags []string `json:"tags"`
+			} `json:"references"`
+		} `json:"cve"`
+	} `json:"vulnerabilities"`
+}
+
+// nvdMetric is one CVSS scoring of a CVE. NVD's own is of type Primary;
+// others come from the vendor or whoever reported it.
+type nvdMetric struct {
+	Type     string `json:"type"`
+	CVSSData struct {
+		BaseScore float64 `json:"baseScore"`
+	} `json:"cvssData"`
+}
+
+// score returns the score of the newest CVSS version a CVE was scored in,
+// NVD's own where there are several.
+func score(versions ...[]nvdMetric) float64 {
+	for _, metrics := range versions {
+		if len(metrics) == 0 {
+			continue
+		}
+		for _, m := range metrics {
+			if m.Type == "Primary" {
+				return m.CVSSData.BaseScore
+			}
+		}
+		return metrics[0].CVSSData.BaseScore
+	}
+	return 0
+}
+
+// fetchLocked asks NVD for every CVE of cpeName, a page at a time. The
+// caller must hold c.mu.
+func (c *Client) fetchLocked(ctx context.Context, cpeName string) ([]CVE, error) {
+	cves := make([]CVE, 0)
+	for start := 0; ; {
+		page, err := c.getLocked(ctx, cpeName, start)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range page.Vulnerabilities {
+			cve := CVE{ID: v.CVE.ID, CVSS: score(v.CVE.Metrics.V31, v.CVE.Metrics.V30, v.CVE.Metrics.V2)}
+			for _, d := range v.CVE.Descriptions {
+				if d.Lang == "en" {
+					cve.Description = d.Value
+					break
+				}
+			}
+			for _, ref := range v.CVE.References {
+				for _, tag := range ref.Tags {
+					if tag == "Exploit" {
+						cve.Exploit = true
+					}
+				}
+			}
+			cves = append(cves, cve)
+		}
+		start += len(page.Vulnerabilities)
+		if len(page.Vulnerabilities) == 0 || start >= page.TotalResults {
+			return cves, nil
+		}
+	}
+}
+
+// getLocked reads one page of the CVEs of cpeName, waiting first for as long
+// as NVD asks between requests. The caller must hold c.mu.
+func (c *Client) getLocked(ctx context.Context, cpeName string, start int) (*nvdResponse, error) {
+	if wait := c.gap - c.now().Sub(c.lastAsk); wait > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	c.lastAsk = c.now()
+
+	q := url.Values{}
+	q.Set("cpeName", cpeName)
+	q.Set("startIndex", strconv.Itoa(start))
+	q.Set("resultsPerPage", strconv.Itoa(pageSize))
+	// isVulnerable leaves out CVEs the product is only part of the setting
+	// of, and takes no value: NVD refuses "isVulnerable=".
+	query := q.Encode() + "&isVulnerable"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.opts.URL+"?"+query, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.opts.APIKey != "" {
+		req.Header.Set("apiKey", c.opts.APIKey)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot reach NVD: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		// NVD answers 404 for a CPE name it does not know, which has no
+		// CVEs as far as it is concerned.
+		return &nvdResponse{}, nil
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests:
+		return nil, errors.New("NVD refused the request; it allows few requests without an API key, so try again later or set api_key in [cve]")
+	case resp.StatusCode != http.StatusOK:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return nil, fmt.Errorf("NVD answered %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var page nvdResponse
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("cannot read NVD's answer: %w", err)
+	}
+	return &page, nil
+}
+
+// loadLocked reads the cache file the first time it is needed. A missing
+// file is an empty cache. The caller must hold c.mu.
+func (c *Client) loadLocked() error {
+	if c.loaded {
+		return nil
+	}
+	c.cache = make(map[string]cacheEntry)
+	c.loaded = true
+	if c.opts.CacheFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(c.opts.CacheFile)
+	if os.IsNotExist(err) || (err == nil && len(data) == 0) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &c.cache); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", c.opts.CacheFile, err)
+	}
+	if c.cache == nil {
+		c.cache = make(map[string]cacheEntry)
+	}
+	return nil
+}
+
+// saveLocked writes the cache file atomically, so that a lookup cut short
+// leaves the one before. The caller must hold c.mu.
+func (c *Client) saveLocked() error {
+	if c.opts.CacheFile == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(c.cache, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal CVE cache: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.opts.CacheFile), ".cve-cache-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.opts.CacheFile)
+}
+
+// Exposure sums up what the latest deep scan of a device found wrong with
+// it, from every check.
+type Exposure struct {
+	IP string `json:"ip"`
+	// Scanned is when the latest deep scan or check of it ran.
+	Scanned time.Time `json:"scanned"`
+	// Severity is that of its worst finding, and CVSS the highest score.
+	Severity string  `json:"severity"`
+	CVSS     float64 `json:"cvss,omitempty"`
+	// Counts are how many findings it has of each severity above info.
+	Counts map[string]int `json:"counts"`
+	// CVEs are the CVEs among them, worst first.
+	CVEs []string `json:"cves,omitempty"`
+	// Exploit is set when an exploit is known for any of them.
+	Exploit bool `json:"exploit,omitempty"`
+}
+
+// Findings returns how many findings e counts.
+func (e Exposure) Findings() int {
+	n := 0
+	for _, count := range e.Counts {
+		n += count
+	}
+	return n
+}
+
+// Exposures sums up the deep scans of devices by IP, leaving out those
+// that found nothing worse than information, worst first.
+func Exposures(scans map[string]types.DeepScan) []Exposure {
+	result := make([]Exposure, 0)
+	for ip, scan := range scans {
+		e := Exposure{IP: ip, Scanned: scan.Time, Counts: make(map[string]int)}
+		for _, t := range scan.Checked {
+			if t.After(e.Scanned) {
+				e.Scanned = t
+			}
+		}
+		worst := -1
+		for _, f := range scan.Findings {
+			rank := types.SeverityRank(f.Severity)
+			if rank <= 0 {
+				continue
+			}
+			e.Counts[strings.ToLower(f.Severity)]++
+			if rank > worst {
+				worst = rank
+				e.Severity = strings.ToLower(f.Severity)
+			}
+			if f.CVSS > e.CVSS {
+				e.CVSS = f.CVSS
+			}
+			if strings.HasPrefix(f.ID, "CVE-") && !contains(e.CVEs, f.ID) {
+				e.CVEs = append(e.CVEs, f.ID)
+			}
+			e.Exploit = e.Exploit || f.Exploit
+		}
+		if worst > 0 {
+			result = append(result, e)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if ra, rb := types.SeverityRank(a.Severity), types.SeverityRank(b.Severity); ra != rb {
+			return ra > rb
+		}
+		if a.CVSS != b.CVSS {
+			return a.CVSS > b.CVSS
+		}
+		if a.Findings() != b.Findings() {
+			return a.Findings() > b.Findings()
+		}
+		return a.IP < b.IP
+	})
+	return result
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
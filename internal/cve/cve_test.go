@@ -0,0 +1,194 @@
+package cve
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+const opensshName = "cpe:2.3:a:openbsd:openssh:7.4:*:*:*:*:*:*:*"
+
+// fakeNVD answers like NVD's CVE API, one CVE a page, and counts the
+// requests it gets.
+func fakeNVD(t *testing.T, requests *int) *httptest.Server {
+	t.Helper()
+	cves := map[string][]map[string]any{
+		opensshName: {
+			{
+				"id":           "CVE-2016-10012",
+				"descriptions": []map[string]any{{"lang": "es", "value": "Hola"}, {"lang": "en", "value": "The shared memory manager in sshd in OpenSSH before 7.4 does not ensure that a bounds check is enforced. More detail."}},
+				"metrics": map[string]any{
+					"cvssMetricV31": []map[string]any{
+						{"type": "Secondary", "cvssData": map[string]any{"baseScore": 5.0}},
+						{"type": "Primary", "cvssData": map[string]any{"baseScore": 7.8}},
+					},
+					"cvssMetricV2": []map[string]any{{"type": "Primary", "cvssData": map[string]any{"baseScore": 7.2}}},
+				},
+			},
+			{
+				"id":           "CVE-2018-15473",
+				"descriptions": []map[string]any{{"lang": "en", "value": "OpenSSH through 7.7 is prone to a user enumeration vulnerability."}},
+				"metrics":      map[string]any{"cvssMetricV2": []map[string]any{{"type": "Primary", "cvssData": map[string]any{"baseScore": 5.0}}}},
+				"references":   []map[string]any{{"tags": []string{"Exploit", "Third Party Advisory"}}},
+			},
+			{
+				"id":           "CVE-2023-99999",
+				"descriptions": []map[string]any{{"lang": "en", "value": ""}},
+			},
+		},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		if _, ok := r.URL.Query()["isVulnerable"]; !ok {
+			t.Errorf("request %s without isVulnerable", r.URL)
+		}
+		list, ok := cves[r.URL.Query().Get("cpeName")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		start, _ := strconv.Atoi(r.URL.Query().Get("startIndex"))
+		var page []map[string]any
+		if start < len(list) {
+			page = append(page, map[string]any{"cve": list[start]})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"startIndex": start, "totalResults": len(list), "vulnerabilities": page})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCPEName(t *testing.T) {
+	tests := []struct{ cpe, want string }{
+		{"cpe:/a:openbsd:openssh:7.4", opensshName},
+		{"cpe:/a:openbsd:openssh:7.4p1:debian", "cpe:2.3:a:openbsd:openssh:7.4p1:debian:*:*:*:*:*:*"},
+		{"cpe:/o:linux:linux_kernel:2.6.32", "cpe:2.3:o:linux:linux_kernel:2.6.32:*:*:*:*:*:*:*"},
+		{"cpe:/a:igor_sysoev:nginx:1.18.0:::~~~~x64~", "cpe:2.3:a:igor_sysoev:nginx:1.18.0:*:*:*:*:*:*:*"},
+		{"cpe:/a:apache:http_server:2.4%2b", "cpe:2.3:a:apache:http_server:2.4\\+:*:*:*:*:*:*:*"},
+		{"cpe:/o:linux:linux_kernel", ""},
+		{"cpe:/x:vendor:product:1", ""},
+		{"openssh 7.4", ""},
+	}
+	for _, tt := range tests {
+		if got := CPEName(tt.cpe); got != tt.want {
+			t.Errorf("CPEName(%q) = %q; want %q", tt.cpe, got, tt.want)
+		}
+	}
+}
+
+func TestFindings(t *testing.T) {
+	var requests int
+	srv := fakeNVD(t, &requests)
+	cache := filepath.Join(t.TempDir(), "cve_cache.json")
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	c := New(Options{URL: srv.URL, CacheFile: cache, MaxAge: 24 * time.Hour})
+	c.now = func() time.Time { return now }
+	c.gap = 0
+
+	scan := types.DeepScan{
+		IP: "192.168.1.10",
+		Services: []types.Service{
+			{Port: 22, Protocol: "tcp", Name: "ssh", Product: "OpenSSH", Version: "7.4", CPE: []string{"cpe:/a:openbsd:openssh:7.4", "cpe:/o:linux:linux_kernel"}},
+			{Port: 2222, Protocol: "tcp", Name: "ssh", Product: "OpenSSH", Version: "7.4", CPE: []string{"cpe:/a:openbsd:openssh:7.4"}},
+			{Port: 80, Protocol: "tcp", Name: "http", Product: "lighttpd", Version: "1.4", CPE: []string{"cpe:/a:lighttpd:lighttpd:1.4"}},
+		},
+		Findings: []types.Finding{{Port: 22, Protocol: "tcp", Check: "vulners", ID: "CVE-2016-10012", Severity: types.SeverityHigh, CVSS: 7.2}},
+	}
+	findings, err := c.Findings(context.Background(), scan, false)
+	if err != nil {
+		t.Fatalf("Findings: %v", err)
+	}
+	if len(findings) != 2 {
+		t.Fatalf("Findings = %+v; want the two CVEs vulners did not report", findings)
+	}
+	f := findings[0]
+	if f.ID != "CVE-2018-15473" || f.Port != 22 || f.Check != Check || f.CVSS != 5.0 || f.Severity != types.SeverityMedium || !f.Exploit {
+		t.Errorf("first finding = %+v", f)
+	}
+	if f.Title != "OpenSSH through 7.7 is prone to a user enumeration vulnerability" {
+		t.Errorf("title = %q", f.Title)
+	}
+	if f := findings[1]; f.ID != "CVE-2023-99999" || f.Title != "Known vulnerability in OpenSSH 7.4" || f.Severity != types.SeverityMedium {
+		t.Errorf("unscored finding = %+v", f)
+	}
+	// Three pages of OpenSSH and a 404 for lighttpd.
+	if requests != 4 {
+		t.Errorf("NVD asked %d times; want 4", requests)
+	}
+
+	// A new client reads the cache rather than asking again, until it is
+	// too old.
+	c = New(Options{URL: srv.URL, CacheFile: cache, MaxAge: 24 * time.Hour})
+	c.now = func() time.Time { return now.Add(time.Hour) }
+	c.gap = 0
+	cves, err := c.Lookup(context.Background(), opensshName, false)
+	if err != nil || len(cves) != 3 || requests != 4 {
+		t.Fatalf("Lookup from cache = %d CVEs, %v, after %d requests; want 3 without asking", len(cves), err, requests)
+	}
+	if cves[0].CVSS != 7.8 {
+		t.Errorf("CVSS = %v; want NVD's own v3.1 score", cves[0].CVSS)
+	}
+	c.now = func() time.Time { return now.Add(25 * time.Hour) }
+	if _, err := c.Lookup(context.Background(), opensshName, false); err != nil || requests != 7 {
+		t.Errorf("stale Lookup: %v after %d requests; want NVD asked again", err, requests)
+	}
+	if _, err := c.Lookup(context.Background(), opensshName, true); err != nil || requests != 10 {
+		t.Errorf("refreshed Lookup: %v after %d requests; want NVD asked again", err, requests)
+	}
+}
+
+func TestRefusedWithoutKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+	c := New(Options{URL: srv.URL})
+	c.gap = 0
+	_, err := c.Lookup(context.Background(), opensshName, false)
+	if err == nil || !strings.Contains(err.Error(), "api_key") {
+		t.Errorf("Lookup = %v; want a hint to set an API key", err)
+	}
+}
+
+func TestExposures(t *testing.T) {
+	now := time.Now()
+	scans := map[string]types.DeepScan{
+		"192.168.1.10": {IP: "192.168.1.10", Time: now, Findings: []types.Finding{
+			{ID: "CVE-2016-10012", Severity: types.SeverityHigh, CVSS: 7.8},
+			{ID: "CVE-2018-15473", Severity: types.SeverityMedium, CVSS: 5.0, Exploit: true},
+			{Title: "Banner", Severity: types.SeverityInfo},
+		}},
+		"192.168.1.20": {IP: "192.168.1.20", Time: now, Findings: []types.Finding{
+			{Title: "Accepts admin/admin", Severity: types.SeverityHigh},
+		}},
+		"192.168.1.30": {IP: "192.168.1.30", Time: now, Findings: []types.Finding{
+			{ID: "CVE-2021-44228", Severity: types.SeverityCritical, CVSS: 10},
+		}},
+		"192.168.1.40": {IP: "192.168.1.40", Time: now, Findings: []types.Finding{
+			{Title: "Banner", Severity: types.SeverityInfo},
+		}},
+	}
+	got := Exposures(scans)
+	var ips []string
+	for _, e := range got {
+		ips = append(ips, e.IP)
+	}
+	if strings.Join(ips, " ") != "192.168.1.30 192.168.1.10 192.168.1.20" {
+		t.Fatalf("Exposures order = %v", ips)
+	}
+	e := got[1]
+	if e.Severity != types.SeverityHigh || e.CVSS != 7.8 || e.Findings() != 2 || e.Counts[types.SeverityMedium] != 1 || !e.Exploit {
+		t.Errorf("exposure = %+v", e)
+	}
+	if strings.Join(e.CVEs, " ") != "CVE-2016-10012 CVE-2018-15473" {
+		t.Errorf("CVEs = %v", e.CVEs)
+	}
+}
@@ -0,0 +1,204 @@
+// Package dnszone turns the labelled devices of the inventory into local
+// DNS: /etc/hosts lines, BIND zone records or dnsmasq settings, naming each
+// device after its label, so that "Living room TV" answers as
+// living-room-tv.lan.
+//
+// Only labels are used. Hostnames come from the devices themselves, or from
+// DNS already, and change when they please; a label is given on purpose and
+// stays until someone changes it.
+package dnszone
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// Formats are the formats records can be written in.
+var Formats = []string{"hosts", "bind", "dnsmasq"}
+
+// Record names a device's address.
+type Record struct {
+	// Name is the label made into a DNS name, without the domain.
+	Name string
+	Addr netip.Addr
+	// Label is the label it was made from.
+	Label string
+}
+
+// Clash is a device left out because its label makes a name another device
+// has already.
+type Clash struct {
+	Name  string
+	Label string
+	IP    string
+	// Owner is the address of the device that has the name.
+	Owner string
+}
+
+// Records returns a record for each labelled device, in address order.
+// When labels make the same name, the device with the lowest address gets it
+// and the others are returned as clashes, so that a name never moves
+// between devices just because one was seen later.
+func Records(devices map[string]*types.Device) ([]Record, []Clash) {
+	var records []Record
+	for _, d := range devices {
+		addr, err := netip.ParseAddr(d.IP)
+		if err != nil || d.Label == "" {
+			continue
+		}
+		if name := Name(d.Label); name != "" {
+			records = append(records, Record{Name: name, Addr: addr.Unmap(), Label: d.Label})
+		}
+	}
+	slices.SortFunc(records, func(a, b Record) int { return a.Addr.Compare(b.Addr) })
+
+	owners := make(map[string]netip.Addr)
+	var kept []Record
+	var clashes []Clash
+	for _, r := range records {
+		if owner, taken := owners[r.Name]; taken {
+			clashes = append(clashes, Clash{Name: r.Name, Label: r.Label, IP: r.Addr.String(), Owner: owner.String()})
+			continue
+		}
+		owners[r.Name] = r.Addr
+		kept = append(kept, r)
+	}
+	return kept, clashes
+}
+
+// Name makes label into a DNS name: lower case letters, digits and dashes,
+// with apostrophes dropped and anything else as a dash, no dash at either
+// end, and at most 63 characters. It returns "" when nothing is left, as for a label of only
+// punctuation.
+func Name(label string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(label) {
+		if r == '\'' || r == '’' {
+			continue
+		}
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+		} else {
+			dash = true
+		}
+	}
+	name := b.String()
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], "-")
+	}
+	return name
+}
+
+// ValidDomain checks that domain can be put after the names, such as lan or
+// home.arpa.
+func ValidDomain(domain string) error {
+	if domain == "" {
+		return errors.New("is empty")
+	}
+	if len(domain) > 253-64 {
+		return fmt.Errorf("%q is too long", domain)
+	}
+	for _, part := range strings.Split(domain, ".") {
+		if part == "" || len(part) > 63 || part[0] == '-' || part[len(part)-1] == '-' {
+			return fmt.Errorf("%q is not a domain such as lan or home.arpa", domain)
+		}
+		for _, r := range part {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return fmt.Errorf("%q is not a domain such as lan or home.arpa", domain)
+			}
+		}
+	}
+	return nil
+}
+
+// header says where the file came from, in the comment syntax of all three
+// formats.
+const header = "Written by LAN Orangutan from the labels of the devices. Changes here are overwritten.\n"
+
+// Write writes records to w in format, one of Formats, with their names in
+// domain.
+func Write(w io.Writer, format, domain string, records []Record) error {
+	bw := bufio.NewWriter(w)
+	domain = strings.ToLower(strings.Trim(domain, "."))
+	switch format {
+	case "hosts":
+		// The full name first, as the one reverse lookups of the file give.
+		fmt.Fprint(bw, "# "+header)
+		for _, r := range records {
+			fmt.Fprintf(bw, "%s\t%s.%s %s\n", r.Addr, r.Name, domain, r.Name)
+		}
+	case "bind":
+		// Relative names, so the records can be included in the zone of
+		// domain whatever its origin is written as.
+		fmt.Fprint(bw, "; "+header)
+		fmt.Fprintf(bw, "; $INCLUDE this in the zone of %s.\n", domain)
+		for _, r := range records {
+			rtype := "A"
+			if r.Addr.Is6() {
+				rtype = "AAAA"
+			}
+			fmt.Fprintf(bw, "%s\tIN\t%s\t%s\n", r.Name, rtype, r.Addr)
+		}
+	case "dnsmasq":
+		// host-record answers the reverse lookup too, which address= does
+		// not.
+		fmt.Fprint(bw, "# "+header)
+		for _, r := range records {
+			fmt.Fprintf(bw, "host-record=%s.%s,%s,%s\n", r.Name, domain, r.Name, r.Addr)
+		}
+	default:
+		return fmt.Errorf("unknown format %q (use %s)", format, strings.Join(Formats, ", "))
+	}
+	return bw.Flush()
+}
+
+// WriteFile writes records to the file at path as Write does, and reports
+// whether the file changed. An unchanged file is not touched, so that
+// whatever watches it, such as a unit that restarts dnsmasq, is left alone
+// when a scan changed no names. A changed file is replaced in one go, so
+// the DNS server never reads it half written.
+func WriteFile(path, format, domain string, records []Record) (bool, error) {
+	var buf bytes.Buffer
+	if err := Write(&buf, format, domain, records); err != nil {
+		return false, err
+	}
+	if old, err := os.ReadFile(path); err == nil && bytes.Equal(old, buf.Bytes()) {
+		return false, nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".lan-orangutan-*.tmp")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+	// The DNS server runs as its own user, and the names are not secret.
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,99 @@
+package dnszone
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func TestName(t *testing.T) {
+	for label, want := range map[string]string{
+		"Living room TV":        "living-room-tv",
+		"  NAS (backup) ":       "nas-backup",
+		"Kid's iPad":            "kids-ipad",
+		"printer_2":             "printer-2",
+		"---":                   "",
+		"Wohnzimmer Fernseh":    "wohnzimmer-fernseh",
+		strings.Repeat("a", 70): strings.Repeat("a", 63),
+	} {
+		if got := Name(label); got != want {
+			t.Errorf("Name(%q) = %q, want %q", label, got, want)
+		}
+	}
+}
+
+func TestRecordsKeepsTheLowestAddressOfAClash(t *testing.T) {
+	devices := map[string]*types.Device{
+		"192.168.1.20": {IP: "192.168.1.20", Label: "NAS"},
+		"192.168.1.3":  {IP: "192.168.1.3", Label: "nas"},
+		"192.168.1.4":  {IP: "192.168.1.4", Label: "Printer"},
+		"192.168.1.5":  {IP: "192.168.1.5", Hostname: "phone"},
+		"fd00::1":      {IP: "fd00::1", Label: "Router"},
+	}
+	records, clashes := Records(devices)
+	var names []string
+	for _, r := range records {
+		names = append(names, r.Name+"="+r.Addr.String())
+	}
+	if got := strings.Join(names, " "); got != "nas=192.168.1.3 printer=192.168.1.4 router=fd00::1" {
+		t.Errorf("records = %s", got)
+	}
+	if len(clashes) != 1 || clashes[0].IP != "192.168.1.20" || clashes[0].Owner != "192.168.1.3" {
+		t.Errorf("clashes = %+v", clashes)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	records, _ := Records(map[string]*types.Device{
+		"192.168.1.3": {IP: "192.168.1.3", Label: "NAS"},
+		"fd00::1":     {IP: "fd00::1", Label: "Router"},
+	})
+	for format, want := range map[string][]string{
+		"hosts":   {"192.168.1.3\tnas.home.arpa nas\n", "fd00::1\trouter.home.arpa router\n"},
+		"bind":    {"nas\tIN\tA\t192.168.1.3\n", "router\tIN\tAAAA\tfd00::1\n"},
+		"dnsmasq": {"host-record=nas.home.arpa,nas,192.168.1.3\n", "host-record=router.home.arpa,router,fd00::1\n"},
+	} {
+		var buf bytes.Buffer
+		if err := Write(&buf, format, "home.arpa.", records); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		for _, line := range want {
+			if !strings.Contains(buf.String(), line) {
+				t.Errorf("%s lacks %q:\n%s", format, line, buf.String())
+			}
+		}
+	}
+	if err := Write(&bytes.Buffer{}, "tinydns", "lan", records); err == nil {
+		t.Error("an unknown format was written")
+	}
+}
+
+func TestWriteFileOnlyWritesChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orangutan.conf")
+	records, _ := Records(map[string]*types.Device{"192.168.1.3": {IP: "192.168.1.3", Label: "NAS"}})
+	if changed, err := WriteFile(path, "dnsmasq", "lan", records); err != nil || !changed {
+		t.Fatalf("first write: changed %v, %v", changed, err)
+	}
+	if changed, err := WriteFile(path, "dnsmasq", "lan", records); err != nil || changed {
+		t.Errorf("same records: changed %v, %v", changed, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(data), "host-record=nas.lan,nas,192.168.1.3\n") {
+		t.Errorf("file = %q, %v", data, err)
+	}
+}
+
+func TestValidDomain(t *testing.T) {
+	for domain, ok := range map[string]bool{
+		"lan": true, "home.arpa": true, "my-home.example": true,
+		"": false, "-lan": false, "a..b": false, "la n": false,
+	} {
+		if err := ValidDomain(domain); (err == nil) != ok {
+			t.Errorf("ValidDomain(%q) = %v", domain, err)
+		}
+	}
+}
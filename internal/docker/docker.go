@@ -0,0 +1,229 @@
+// Package docker reads the containers running on the local Docker engine
+// and the addresses they have on its networks, so that a device at
+// 172.17.0.5, or at a macvlan address on the LAN, is named after the
+// container rather than left unknown.
+//
+// It speaks the Engine API over the daemon's Unix socket, which gives
+// whoever can open it control of the host: run LAN Orangutan in the docker
+// group only on a machine where that is acceptable.
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// requestTimeout bounds each request, so that a daemon that has hung does
+// not hold up the scan waiting on it.
+const requestTimeout = 10 * time.Second
+
+// DefaultSocket is where the Docker daemon listens unless told otherwise.
+const DefaultSocket = "/var/run/docker.sock"
+
+// NetworkTagPrefix starts the tag given to a container for each Docker
+// network it is on, as in "docker:frontend".
+const NetworkTagPrefix = "docker:"
+
+// Vendor is what containers are given as their vendor: the MAC addresses
+// Docker makes up are locally administered, so no vendor is on record for
+// them.
+const Vendor = "Docker"
+
+// Container is a running container.
+type Container struct {
+	// Name is the container's name, without the leading slash.
+	Name  string
+	ID    string
+	Image string
+	// Project and Service are the Compose project and service it belongs
+	// to, if any.
+	Project string
+	Service string
+	// Endpoints are its addresses on each network, in the order of their
+	// network's names. Containers on the host's network have none.
+	Endpoints []Endpoint
+}
+
+// Endpoint is a container's place on one network.
+type Endpoint struct {
+	Network string
+	// Driver is the network's driver: bridge, macvlan, ipvlan, overlay.
+	Driver string
+	IP     string
+	IPv6   string
+	MAC    string
+}
+
+// Client asks a Docker daemon about its containers.
+type Client struct {
+	socket string
+	http   *http.Client
+	// now is the clock, replaced in tests.
+	now func() time.Time
+}
+
+// New returns a client for the daemon listening on socket, or on
+// DefaultSocket when it is empty.
+func New(socket string) *Client {
+	if socket == "" {
+		socket = DefaultSocket
+	}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+	return &Client{socket: socket, http: &http.Client{Timeout: requestTimeout, Transport: transport}, now: time.Now}
+}
+
+// The parts of the Engine API's replies that are used.
+type (
+	containerReply struct {
+		ID              string            `json:"Id"`
+		Names           []string          `json:"Names"`
+		Image           string            `json:"Image"`
+		Labels          map[string]string `json:"Labels"`
+		NetworkSettings struct {
+			Networks map[string]struct {
+				NetworkID         string `json:"NetworkID"`
+				IPAddress         string `json:"IPAddress"`
+				GlobalIPv6Address string `json:"GlobalIPv6Address"`
+				MacAddress        string `json:"MacAddress"`
+			} `json:"Networks"`
+		} `json:"NetworkSettings"`
+	}
+	networkReply struct {
+		ID     string `json:"Id"`
+		Name   string `json:"Name"`
+		Driver string `json:"Driver"`
+	}
+)
+
+// Containers returns the running containers, by name.
+func (c *Client) Containers(ctx context.Context) ([]Container, error) {
+	var networks []networkReply
+	if err := c.get(ctx, "/networks", &networks); err != nil {
+		return nil, err
+	}
+	drivers := make(map[string]string, len(networks))
+	for _, n := range networks {
+		drivers[n.ID] = n.Driver
+	}
+	var replies []containerReply
+	if err := c.get(ctx, "/containers/json", &replies); err != nil {
+		return nil, err
+	}
+
+	containers := make([]Container, 0, len(replies))
+	for _, r := range replies {
+		ct := Container{
+			ID:      r.ID[:min(len(r.ID), 12)],
+			Image:   r.Image,
+			Project: r.Labels["com.docker.compose.project"],
+			Service: r.Labels["com.docker.compose.service"],
+		}
+		if len(r.Names) > 0 {
+			ct.Name = strings.TrimPrefix(r.Names[0], "/")
+		}
+		for name, n := range r.NetworkSettings.Networks {
+			if n.IPAddress == "" && n.GlobalIPv6Address == "" {
+				continue
+			}
+			ct.Endpoints = append(ct.Endpoints, Endpoint{
+				Network: name,
+				Driver:  drivers[n.NetworkID],
+				IP:      n.IPAddress,
+				IPv6:    n.GlobalIPv6Address,
+				MAC:     strings.ToUpper(n.MacAddress),
+			})
+		}
+		slices.SortFunc(ct.Endpoints, func(a, b Endpoint) int { return strings.Compare(a.Network, b.Network) })
+		containers = append(containers, ct)
+	}
+	slices.SortFunc(containers, func(a, b Container) int { return strings.Compare(a.Name, b.Name) })
+	return containers, nil
+}
+
+// Name implements scanner.Source.
+func (c *Client) Name() string { return "docker" }
+
+// Devices returns the containers with an address in network as devices,
+// named after the container and tagged with its Docker network. Running
+// containers are there now, so all have LastSeen set.
+func (c *Client) Devices(ctx context.Context, network *net.IPNet) ([]types.Device, error) {
+	containers, err := c.Containers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ContainerDevices(containers, network, c.now()), nil
+}
+
+// ContainerDevices returns the endpoints of containers in network, or all
+// of them when network is nil, as devices seen at now.
+func ContainerDevices(containers []Container, network *net.IPNet, now time.Time) []types.Device {
+	var devices []types.Device
+	for _, ct := range containers {
+		for _, e := range ct.Endpoints {
+			for _, ip := range []string{e.IP, e.IPv6} {
+				addr, err := netip.ParseAddr(ip)
+				if err != nil || network != nil && !network.Contains(addr.AsSlice()) {
+					continue
+				}
+				devices = append(devices, types.Device{
+					IP:        addr.String(),
+					MAC:       e.MAC,
+					Hostname:  ct.Name,
+					Vendor:    Vendor,
+					FirstSeen: now,
+					LastSeen:  now,
+					Tags:      []string{NetworkTagPrefix + e.Network},
+				})
+			}
+		}
+	}
+	return devices
+}
+
+// get fetches path from the Engine API and decodes the JSON answer into
+// reply.
+func (c *Client) get(ctx context.Context, path string, reply any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker"+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		var nerr *net.OpError
+		if errors.As(err, &nerr) {
+			return fmt.Errorf("cannot reach the Docker daemon at %s: %w", c.socket, nerr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var e struct {
+			Message string `json:"message"`
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(body, &e) == nil && e.Message != "" {
+			return fmt.Errorf("Docker answered %s: %s", resp.Status, e.Message)
+		}
+		return fmt.Errorf("Docker answered %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(reply); err != nil {
+		return fmt.Errorf("reading Docker's answer to %s: %w", path, err)
+	}
+	return nil
+}
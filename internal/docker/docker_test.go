@@ -0,0 +1,104 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeDocker answers like the Engine API on a Unix socket, and returns the
+// socket's path.
+func fakeDocker(t *testing.T) string {
+	t.Helper()
+	replies := map[string]any{
+		"/networks": []map[string]any{
+			{"Id": "n1", "Name": "bridge", "Driver": "bridge"},
+			{"Id": "n2", "Name": "lan", "Driver": "macvlan"},
+		},
+		"/containers/json": []map[string]any{
+			{
+				"Id": "0123456789abcdef", "Names": []string{"/web"}, "Image": "nginx:1.27",
+				"Labels": map[string]string{"com.docker.compose.project": "site", "com.docker.compose.service": "web"},
+				"NetworkSettings": map[string]any{"Networks": map[string]any{
+					"bridge": map[string]any{"NetworkID": "n1", "IPAddress": "172.17.0.5", "MacAddress": "02:42:ac:11:00:05"},
+					"lan":    map[string]any{"NetworkID": "n2", "IPAddress": "192.168.1.60", "MacAddress": "02:42:c0:a8:01:3c"},
+				}},
+			},
+			{
+				"Id": "fedcba9876543210", "Names": []string{"/pihole"}, "Image": "pihole/pihole",
+				"NetworkSettings": map[string]any{"Networks": map[string]any{
+					"host": map[string]any{"NetworkID": "n3"},
+				}},
+			},
+		},
+	}
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("no Unix sockets: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reply, ok := replies[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"message": "page not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(reply)
+	}))
+	srv.Listener = ln
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return socket
+}
+
+func TestContainers(t *testing.T) {
+	c := New(fakeDocker(t))
+	containers, err := c.Containers(context.Background())
+	if err != nil {
+		t.Fatalf("Containers: %v", err)
+	}
+	if len(containers) != 2 || containers[0].Name != "pihole" || containers[1].Name != "web" {
+		t.Fatalf("containers = %+v", containers)
+	}
+	if len(containers[0].Endpoints) != 0 {
+		t.Errorf("a container on the host's network has endpoints: %+v", containers[0].Endpoints)
+	}
+	web := containers[1]
+	if web.ID != "0123456789ab" || web.Project != "site" || web.Service != "web" {
+		t.Errorf("web = %+v", web)
+	}
+	if len(web.Endpoints) != 2 || web.Endpoints[1] != (Endpoint{Network: "lan", Driver: "macvlan", IP: "192.168.1.60", MAC: "02:42:C0:A8:01:3C"}) {
+		t.Errorf("endpoints = %+v", web.Endpoints)
+	}
+}
+
+func TestDevicesInANetwork(t *testing.T) {
+	c := New(fakeDocker(t))
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	_, network, _ := net.ParseCIDR("192.168.1.0/24")
+	devices, err := c.Devices(context.Background(), network)
+	if err != nil {
+		t.Fatalf("Devices: %v", err)
+	}
+	if len(devices) != 1 {
+		t.Fatalf("devices = %+v", devices)
+	}
+	d := devices[0]
+	if d.IP != "192.168.1.60" || d.Hostname != "web" || d.Vendor != Vendor || !d.LastSeen.Equal(now) || !d.HasTag("docker:lan") {
+		t.Errorf("device = %+v", d)
+	}
+}
+
+func TestNoDaemon(t *testing.T) {
+	c := New(filepath.Join(t.TempDir(), "missing.sock"))
+	if _, err := c.Containers(context.Background()); err == nil {
+		t.Error("no error without a daemon")
+	}
+}
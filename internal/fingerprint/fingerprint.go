@@ -0,0 +1,295 @@
+// Package fingerprint keeps what each device looks like from the network,
+// its vendor, open ports, hostname and the services it advertises with
+// multicast DNS, and reports a device whose fingerprint changes too much at
+// once to be the same device. That is how a device swapped for another, or
+// taken over, shows behind an address and MAC that stayed the same.
+//
+// One part changing is normal: a hostname is renamed, a deep scan finds a
+// service turned on. Each part weighs according to how rarely a device
+// changes it on its own, and only enough weight together is reported.
+package fingerprint
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+const (
+	// DefaultInterval is how often fingerprints are taken.
+	DefaultInterval = time.Hour
+	// DefaultBrowse is how long answers to the multicast DNS question are
+	// waited for.
+	DefaultBrowse = 3 * time.Second
+)
+
+// Weights of the parts that changed, and the weight that is reported: a
+// new vendor or open ports on their own, or a new hostname and services
+// together.
+const (
+	vendorWeight    = 3
+	portsWeight     = 3
+	hostnameWeight  = 2
+	servicesWeight  = 2
+	reportThreshold = 3
+)
+
+// Store is where the devices come from and the fingerprints go; the storage
+// is one.
+type Store interface {
+	GetDevices() map[string]*types.Device
+	GetDeepScans() map[string]types.DeepScan
+	GetFingerprints() map[string]types.Fingerprint
+	SetFingerprint(ip string, fp types.Fingerprint, changes []string) (bool, error)
+}
+
+// Watcher takes the fingerprints of the devices.
+type Watcher struct {
+	// Interval is how long Run waits between rounds.
+	Interval time.Duration
+	// Browse returns the multicast DNS service types advertised on the
+	// network by address, as mdns.Browse does; nil leaves services out.
+	Browse func(ctx context.Context) (map[string][]string, error)
+}
+
+// Run takes the fingerprints of every online device every Interval until
+// ctx is done.
+func (w *Watcher) Run(ctx context.Context, store Store) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		if n, changed, err := w.CheckAll(ctx, store, nil); err != nil {
+			slog.Warn("cannot record fingerprints", "error", err)
+		} else {
+			slog.Debug("took fingerprints", "devices", n, "changed", changed)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckAll takes the fingerprints of the devices at ips, or of every online
+// device when ips is empty, compares each with the last and records it. It
+// returns how many devices it fingerprinted and how many of them changed
+// enough to be reported.
+func (w *Watcher) CheckAll(ctx context.Context, store Store, ips []string) (int, int, error) {
+	devices := store.GetDevices()
+	if len(ips) == 0 {
+		for ip, d := range devices {
+			if d.IsOnline() {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	var services map[string][]string
+	if w.Browse != nil {
+		var err error
+		if services, err = w.Browse(ctx); err != nil {
+			if ctx.Err() != nil {
+				return 0, 0, ctx.Err()
+			}
+			slog.Warn("cannot browse multicast DNS services", "error", err)
+		}
+	}
+	scans := store.GetDeepScans()
+	last := store.GetFingerprints()
+
+	now := time.Now()
+	n, changed := 0, 0
+	for _, ip := range ips {
+		d, ok := devices[ip]
+		if !ok {
+			continue
+		}
+		var scan *types.DeepScan
+		if s, ok := scans[ip]; ok {
+			scan = &s
+		}
+		fp := Take(d, scan, services[ip], now)
+		var changes []string
+		if old, ok := last[ip]; ok {
+			fp, changes = Compare(old, fp)
+		}
+		if _, err := store.SetFingerprint(ip, fp, changes); err != nil {
+			return n, changed, err
+		}
+		n++
+		if len(changes) > 0 {
+			changed++
+		}
+	}
+	return n, changed, nil
+}
+
+// Take returns the fingerprint of d at now, with scan its latest deep scan,
+// or nil if it was never deep scanned, and services the multicast DNS
+// service types it advertises.
+func Take(d *types.Device, scan *types.DeepScan, services []string, now time.Time) types.Fingerprint {
+	fp := types.Fingerprint{
+		Vendor:   d.Vendor,
+		Hostname: HostnamePattern(d.Hostname),
+		Services: slices.Clone(services),
+		Taken:    now,
+	}
+	sort.Strings(fp.Services)
+	if scan != nil {
+		fp.Scanned = scan.Time
+		for _, s := range scan.Services {
+			if s.Protocol == "tcp" && !slices.Contains(fp.Ports, s.Port) {
+				fp.Ports = append(fp.Ports, s.Port)
+			}
+		}
+		sort.Ints(fp.Ports)
+	}
+	return fp
+}
+
+// Compare compares fp, just taken, with old, the device's fingerprint
+// before. It returns the fingerprint to keep, which is fp with the parts it
+// does not know taken from old, and what changed, described for the event
+// log, if it changed enough to be reported. A part known in only one of
+// them is learnt or lost rather than changed, and counts for nothing.
+func Compare(old, fp types.Fingerprint) (types.Fingerprint, []string) {
+	fp.Changed = old.Changed
+	if fp.Vendor == "" {
+		fp.Vendor = old.Vendor
+	}
+	if fp.Scanned.IsZero() {
+		fp.Ports, fp.Scanned = old.Ports, old.Scanned
+	}
+	if fp.Hostname == "" {
+		fp.Hostname = old.Hostname
+	}
+	if len(fp.Services) == 0 {
+		fp.Services = old.Services
+	}
+
+	weight := 0
+	var changes []string
+	// Scanners word vendors differently, as "Apple" or "Apple, Inc.", so
+	// only a vendor that shares nothing with the last one counts.
+	if old.Vendor != "" && fp.Vendor != "" {
+		was, is := strings.ToLower(old.Vendor), strings.ToLower(fp.Vendor)
+		if !strings.Contains(was, is) && !strings.Contains(is, was) {
+			weight += vendorWeight
+			changes = append(changes, fmt.Sprintf("vendor %s, was %s", fp.Vendor, old.Vendor))
+		}
+	}
+	// A service turned on or off is a change of one device; most of its
+	// ports being different is another device.
+	if !old.Scanned.IsZero() && fp.Scanned.After(old.Scanned) && similarity(old.Ports, fp.Ports) < 0.5 {
+		weight += portsWeight
+		changes = append(changes, fmt.Sprintf("open ports %s, were %s", FormatPorts(fp.Ports), FormatPorts(old.Ports)))
+	}
+	if old.Hostname != "" && fp.Hostname != "" && old.Hostname != fp.Hostname {
+		weight += hostnameWeight
+		changes = append(changes, fmt.Sprintf("hostname %s, was %s", fp.Hostname, old.Hostname))
+	}
+	if len(old.Services) > 0 && len(fp.Services) > 0 && similarity(old.Services, fp.Services) < 0.5 {
+		weight += servicesWeight
+		changes = append(changes, fmt.Sprintf("services %s, were %s", strings.Join(fp.Services, " "), strings.Join(old.Services, " ")))
+	}
+	if weight < reportThreshold {
+		return fp, nil
+	}
+	fp.Changed = fp.Taken
+	return fp, changes
+}
+
+// similarity is how much of a and b they share, from 0 for nothing to 1 for
+// all of it. Two empty sets are the same.
+func similarity[T comparable](a, b []T) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	both, either := 0, len(b)
+	for _, x := range a {
+		if slices.Contains(b, x) {
+			both++
+		} else {
+			either++
+		}
+	}
+	return float64(both) / float64(either)
+}
+
+// FormatPorts lists the open ports of a fingerprint, as in "22 80 443", or
+// "none".
+func FormatPorts(ports []int) string {
+	if len(ports) == 0 {
+		return "none"
+	}
+	list := make([]string, len(ports))
+	for i, p := range ports {
+		list[i] = fmt.Sprint(p)
+	}
+	return strings.Join(list, " ")
+}
+
+// HostnamePattern returns hostname, in lower case and without its domain,
+// with the numbers and serials in it made wildcards, as "esp-*" for
+// "ESP-3A4F21.lan", so that a device renumbered, or one of a row of the same
+// model, keeps its pattern.
+func HostnamePattern(hostname string) string {
+	name, _, _ := strings.Cut(strings.ToLower(hostname), ".")
+	var b strings.Builder
+	token := func(t string) {
+		switch {
+		case t == "":
+		case isSerial(t):
+			b.WriteString("*")
+		default:
+			// Runs of digits, as in "nas01" or "iphone13".
+			digits := false
+			for _, r := range t {
+				if unicode.IsDigit(r) {
+					if !digits {
+						b.WriteString("*")
+					}
+					digits = true
+					continue
+				}
+				digits = false
+				b.WriteRune(r)
+			}
+		}
+	}
+	start := 0
+	for i, r := range name {
+		if r == '-' || r == '_' {
+			token(name[start:i])
+			b.WriteRune(r)
+			start = i + 1
+		}
+	}
+	token(name[start:])
+	return b.String()
+}
+
+// isSerial reports whether a part of a hostname is a serial number or the
+// end of a MAC, as in "3a4f21": hexadecimal digits, some of them numbers.
+func isSerial(t string) bool {
+	if len(t) < 4 {
+		return false
+	}
+	digit := false
+	for _, r := range t {
+		switch {
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < 'a' || r > 'f':
+			return false
+		}
+	}
+	return digit
+}
@@ -0,0 +1,116 @@
+package fingerprint
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// fakeStore holds devices and records the fingerprints it is given.
+type fakeStore struct {
+	devices      map[string]*types.Device
+	scans        map[string]types.DeepScan
+	fingerprints map[string]types.Fingerprint
+	changes      map[string][]string
+}
+
+func (s *fakeStore) GetDevices() map[string]*types.Device          { return s.devices }
+func (s *fakeStore) GetDeepScans() map[string]types.DeepScan       { return s.scans }
+func (s *fakeStore) GetFingerprints() map[string]types.Fingerprint { return s.fingerprints }
+func (s *fakeStore) SetFingerprint(ip string, fp types.Fingerprint, changes []string) (bool, error) {
+	s.fingerprints[ip] = fp
+	s.changes[ip] = changes
+	return true, nil
+}
+
+func TestHostnamePattern(t *testing.T) {
+	for _, tt := range []struct{ in, want string }{
+		{"", ""},
+		{"nas", "nas"},
+		{"NAS01.lan", "nas*"},
+		{"ESP-3A4F21", "esp-*"},
+		{"johns-iphone-2", "johns-iphone-*"},
+		{"android-5f2a9c3d1e6b", "android-*"},
+		{"living_room_tv", "living_room_tv"},
+		{"cafe-printer", "cafe-printer"},
+	} {
+		if got := HostnamePattern(tt.in); got != tt.want {
+			t.Errorf("HostnamePattern(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	day := 24 * time.Hour
+	now := time.Now()
+	old := types.Fingerprint{
+		Vendor:   "Synology Incorporated",
+		Ports:    []int{22, 443, 5000, 5001},
+		Scanned:  now.Add(-7 * day),
+		Hostname: "nas",
+		Services: []string{"_afpovertcp._tcp", "_smb._tcp"},
+		Taken:    now.Add(-day),
+	}
+	for _, tt := range []struct {
+		name string
+		fp   types.Fingerprint
+		want int
+	}{
+		{"the same", types.Fingerprint{Vendor: "Synology", Hostname: "nas", Services: []string{"_smb._tcp", "_afpovertcp._tcp"}}, 0},
+		{"nothing known", types.Fingerprint{}, 0},
+		{"a service turned on", types.Fingerprint{Ports: []int{22, 80, 443, 5000, 5001}, Scanned: now}, 0},
+		{"renamed", types.Fingerprint{Hostname: "backup"}, 0},
+		{"another vendor", types.Fingerprint{Vendor: "Espressif"}, 1},
+		{"other ports", types.Fingerprint{Ports: []int{80, 1883}, Scanned: now}, 1},
+		{"the same deep scan", types.Fingerprint{Ports: []int{80}, Scanned: old.Scanned}, 0},
+		{"renamed and other services", types.Fingerprint{Hostname: "esp-*", Services: []string{"_hap._tcp"}}, 2},
+	} {
+		tt.fp.Taken = now
+		fp, changes := Compare(old, tt.fp)
+		if len(changes) != tt.want {
+			t.Errorf("%s: changes = %q, want %d", tt.name, changes, tt.want)
+		}
+		if fp.Vendor == "" || fp.Hostname == "" || len(fp.Ports) == 0 || len(fp.Services) == 0 {
+			t.Errorf("%s: kept %+v, want what it does not know carried over", tt.name, fp)
+		}
+		if reported := fp.Changed.Equal(now); reported != (tt.want > 0) {
+			t.Errorf("%s: Changed = %v", tt.name, fp.Changed)
+		}
+	}
+}
+
+func TestCheckAll(t *testing.T) {
+	now := time.Now()
+	store := &fakeStore{
+		devices: map[string]*types.Device{
+			"192.168.1.5":  {IP: "192.168.1.5", Vendor: "Espressif", Hostname: "ESP-3A4F21", LastSeen: now},
+			"192.168.1.6":  {IP: "192.168.1.6", Vendor: "HP", LastSeen: now},
+			"192.168.1.99": {IP: "192.168.1.99", Vendor: "Apple", LastSeen: now.Add(-48 * time.Hour)},
+		},
+		scans: map[string]types.DeepScan{
+			"192.168.1.6": {Time: now, Services: []types.Service{{Port: 631, Protocol: "tcp"}, {Port: 9100, Protocol: "tcp"}, {Port: 161, Protocol: "udp"}}},
+		},
+		fingerprints: map[string]types.Fingerprint{
+			"192.168.1.5": {Vendor: "Synology", Hostname: "nas", Taken: now.Add(-time.Hour)},
+		},
+		changes: make(map[string][]string),
+	}
+	w := &Watcher{Browse: func(ctx context.Context) (map[string][]string, error) {
+		return map[string][]string{"192.168.1.6": {"_ipp._tcp", "_http._tcp"}}, nil
+	}}
+	n, changed, err := w.CheckAll(context.Background(), store, nil)
+	if err != nil || n != 2 || changed != 1 {
+		t.Fatalf("CheckAll = %d, %d, %v; want the two online devices, one changed", n, changed, err)
+	}
+	if got := store.changes["192.168.1.5"]; strings.Join(got, "; ") != "vendor Espressif, was Synology; hostname esp-*, was nas" {
+		t.Errorf("changes = %q", got)
+	}
+	printer := store.fingerprints["192.168.1.6"]
+	if !slices.Equal(printer.Ports, []int{631, 9100}) || !slices.Equal(printer.Services, []string{"_http._tcp", "_ipp._tcp"}) || len(store.changes["192.168.1.6"]) != 0 {
+		t.Errorf("printer = %+v, want its TCP ports and services", printer)
+	}
+}
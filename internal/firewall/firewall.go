@@ -0,0 +1,222 @@
+// Package firewall reads the ARP table and DHCP leases of an OPNsense or
+// pfSense firewall, which sees every VLAN it routes, including those the
+// machine running LAN Orangutan cannot reach to scan.
+//
+// OPNsense is asked through its own API, with an API key and secret.
+// pfSense has no API of its own, so it is asked through the REST API
+// package (pfSense-pkg-RESTAPI, v2), with an API key.
+package firewall
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// requestTimeout bounds each request to the firewall.
+const requestTimeout = 20 * time.Second
+
+// Types are the firewalls that can be asked.
+var Types = []string{"opnsense", "pfsense"}
+
+// Options say which firewall to ask and how.
+type Options struct {
+	// Type is "opnsense" or "pfsense".
+	Type string
+	// URL is the address of the firewall's web interface, such as
+	// https://192.168.1.1.
+	URL string
+	// Key is the API key. For OPNsense, Secret is the secret that goes
+	// with it.
+	Key    string
+	Secret string
+	// CAFile names a PEM file of certificates to trust for the firewall, as
+	// it usually has a certificate of its own making.
+	CAFile string
+	// TLSVerify checks the firewall's certificate. Off, anyone who can get
+	// between here and the firewall can read the API key.
+	TLSVerify bool
+}
+
+// Device is a device the firewall knows of.
+type Device struct {
+	IP       string
+	MAC      string
+	Hostname string
+	Vendor   string
+	// Interface is the description of the interface it was seen on, which
+	// names the VLAN, such as "IOT"; or the interface's name when it has no
+	// description.
+	Interface string
+	// Active is true for a device in the ARP table now. A device only
+	// known from a DHCP lease may have left since.
+	Active bool
+}
+
+// Client asks a firewall about its devices.
+type Client struct {
+	opts Options
+	http *http.Client
+}
+
+// New returns a client asking the firewall opts describe.
+func New(opts Options) (*Client, error) {
+	if err := Valid(opts); err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: !opts.TLSVerify}
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_file: %s holds no PEM certificates", opts.CAFile)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+	return &Client{opts: opts, http: &http.Client{Timeout: requestTimeout, Transport: transport}}, nil
+}
+
+// Valid checks opts, naming the setting at fault first.
+func Valid(opts Options) error {
+	switch opts.Type {
+	case "opnsense", "pfsense":
+	default:
+		return fmt.Errorf("type %q is not %s", opts.Type, strings.Join(Types, " or "))
+	}
+	parsed, err := url.Parse(opts.URL)
+	if err != nil {
+		return fmt.Errorf("url %v", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("url %q is not an http or https URL", opts.URL)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("url %q has no host", opts.URL)
+	}
+	if opts.Key == "" {
+		return errors.New("key is not set")
+	}
+	if opts.Type == "opnsense" && opts.Secret == "" {
+		return errors.New("secret is not set, and OPNsense needs it with the key")
+	}
+	return nil
+}
+
+// Devices returns the devices in the firewall's ARP table and DHCP leases,
+// one for each address, in address order.
+func (c *Client) Devices(ctx context.Context) ([]Device, error) {
+	var arp, leases []Device
+	var err error
+	if c.opts.Type == "pfsense" {
+		arp, leases, err = c.pfsense(ctx)
+	} else {
+		arp, leases, err = c.opnsense(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return merge(arp, leases), nil
+}
+
+// merge combines the devices of the ARP table with those of the leases by
+// address. The table says what is there now; a lease fills in the name.
+func merge(arp, leases []Device) []Device {
+	byIP := make(map[string]*Device)
+	add := func(d Device) {
+		if net.ParseIP(d.IP) == nil {
+			return
+		}
+		existing, ok := byIP[d.IP]
+		if !ok {
+			byIP[d.IP] = &d
+			return
+		}
+		existing.Active = existing.Active || d.Active
+		if existing.MAC == "" {
+			existing.MAC = d.MAC
+		}
+		if existing.Hostname == "" {
+			existing.Hostname = d.Hostname
+		}
+		if existing.Vendor == "" {
+			existing.Vendor = d.Vendor
+		}
+		if existing.Interface == "" {
+			existing.Interface = d.Interface
+		}
+	}
+	for _, d := range arp {
+		d.Active = true
+		add(d)
+	}
+	for _, d := range leases {
+		add(d)
+	}
+
+	devices := make([]Device, 0, len(byIP))
+	for _, d := range byIP {
+		devices = append(devices, *d)
+	}
+	slices.SortFunc(devices, func(a, b Device) int {
+		x, _ := netip.ParseAddr(a.IP)
+		y, _ := netip.ParseAddr(b.IP)
+		return x.Unmap().Compare(y.Unmap())
+	})
+	return devices
+}
+
+// errNotFound is what get returns for a 404, which for an optional part of
+// the API means it is not installed.
+var errNotFound = errors.New("not found")
+
+// get fetches path from the API and decodes the JSON answer into reply.
+func (c *Client) get(ctx context.Context, path string, reply any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(c.opts.URL, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.opts.Type == "pfsense" {
+		req.Header.Set("X-API-Key", c.opts.Key)
+	} else {
+		req.SetBasicAuth(c.opts.Key, c.opts.Secret)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			return uerr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return errNotFound
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("the firewall refused the API key for %s (%s)", path, resp.Status)
+	case resp.StatusCode/100 != 2:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("the firewall answered %s to %s: %s", resp.Status, path, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(reply); err != nil {
+		return fmt.Errorf("reading the firewall's answer to %s: %w", path, err)
+	}
+	return nil
+}
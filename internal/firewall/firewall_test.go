@@ -0,0 +1,117 @@
+package firewall
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeFirewall answers the paths in replies as JSON when asked with the
+// right credentials, and 404 for anything else.
+func fakeFirewall(t *testing.T, authorized func(*http.Request) bool, replies map[string]any) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		reply, ok := replies[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(reply)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOPNsense(t *testing.T) {
+	srv := fakeFirewall(t, func(r *http.Request) bool {
+		key, secret, ok := r.BasicAuth()
+		return ok && key == "k" && secret == "s"
+	}, map[string]any{
+		"/api/diagnostics/interface/getArp": []map[string]any{
+			{"ip": "10.0.20.5", "mac": "aa:bb:cc:00:20:05", "manufacturer": "Espressif", "intf": "igb0_vlan20", "intf_description": "IOT"},
+			{"ip": "10.0.20.6", "mac": "aa:bb:cc:00:20:06", "intf": "igb0_vlan20", "expired": true},
+			{"ip": "192.168.1.1", "mac": "aa:bb:cc:00:01:01", "intf": "igb0"},
+		},
+		"/api/kea/leases4/search": map[string]any{"rows": []map[string]any{
+			{"address": "10.0.20.5", "hwaddr": "aa:bb:cc:00:20:05", "hostname": "plug", "if_descr": "IOT"},
+			{"address": "10.0.20.7", "hwaddr": "aa:bb:cc:00:20:07", "hostname": "bulb", "if_descr": "IOT"},
+		}},
+	})
+	c, err := New(Options{Type: "opnsense", URL: srv.URL, Key: "k", Secret: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	devices, err := c.Devices(context.Background())
+	if err != nil {
+		t.Fatalf("Devices: %v", err)
+	}
+	want := []Device{
+		{IP: "10.0.20.5", MAC: "aa:bb:cc:00:20:05", Hostname: "plug", Vendor: "Espressif", Interface: "IOT", Active: true},
+		{IP: "10.0.20.7", MAC: "aa:bb:cc:00:20:07", Hostname: "bulb", Interface: "IOT"},
+		{IP: "192.168.1.1", MAC: "aa:bb:cc:00:01:01", Interface: "igb0", Active: true},
+	}
+	if len(devices) != len(want) {
+		t.Fatalf("devices = %+v", devices)
+	}
+	for i := range want {
+		if devices[i] != want[i] {
+			t.Errorf("device %d = %+v, want %+v", i, devices[i], want[i])
+		}
+	}
+}
+
+func TestPfSense(t *testing.T) {
+	srv := fakeFirewall(t, func(r *http.Request) bool { return r.Header.Get("X-API-Key") == "k" }, map[string]any{
+		"/api/v2/interfaces": map[string]any{"data": []map[string]any{
+			{"id": "lan", "if": "igb0", "descr": "LAN"},
+			{"id": "opt1", "if": "igb0.30", "descr": "CAMERAS"},
+		}},
+		"/api/v2/diagnostics/arp_table": map[string]any{"data": []map[string]any{
+			{"ip_address": "10.0.30.4", "mac_address": "aa:bb:cc:00:30:04", "hostname": "?", "interface": "igb0.30"},
+		}},
+		"/api/v2/status/dhcp_server/leases": map[string]any{"data": []map[string]any{
+			{"ip": "10.0.30.4", "mac": "aa:bb:cc:00:30:04", "hostname": "doorbell", "if": "opt1", "active_status": "active"},
+			{"ip": "10.0.30.9", "mac": "aa:bb:cc:00:30:09", "hostname": "old", "if": "opt1", "active_status": "expired"},
+		}},
+	})
+	c, _ := New(Options{Type: "pfsense", URL: srv.URL, Key: "k"})
+	devices, err := c.Devices(context.Background())
+	if err != nil {
+		t.Fatalf("Devices: %v", err)
+	}
+	want := Device{IP: "10.0.30.4", MAC: "aa:bb:cc:00:30:04", Hostname: "doorbell", Interface: "CAMERAS", Active: true}
+	if len(devices) != 1 || devices[0] != want {
+		t.Errorf("devices = %+v, want %+v", devices, want)
+	}
+
+	bad, _ := New(Options{Type: "pfsense", URL: srv.URL, Key: "wrong"})
+	if _, err := bad.Devices(context.Background()); err == nil || !strings.Contains(err.Error(), "refused the API key") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestValid(t *testing.T) {
+	good := Options{Type: "opnsense", URL: "https://192.168.1.1", Key: "k", Secret: "s"}
+	for name, change := range map[string]func(*Options){
+		"type":      func(o *Options) { o.Type = "ipfire" },
+		"url":       func(o *Options) { o.URL = "192.168.1.1" },
+		"no key":    func(o *Options) { o.Key = "" },
+		"no secret": func(o *Options) { o.Secret = "" },
+	} {
+		opts := good
+		change(&opts)
+		if Valid(opts) == nil {
+			t.Errorf("%s: Valid accepted %+v", name, opts)
+		}
+	}
+	if err := Valid(good); err != nil {
+		t.Errorf("Valid: %v", err)
+	}
+}
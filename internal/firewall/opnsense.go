@@ -0,0 +1,92 @@
+package firewall
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// opnsenseLeases are the paths of the lease lists of OPNsense's DHCP
+// servers: Kea, dnsmasq and the older ISC server. Any of them may be
+// missing, as each is a plugin or a later addition.
+var opnsenseLeases = []string{
+	"/api/kea/leases4/search",
+	"/api/dnsmasq/leases/search",
+	"/api/dhcpv4/leases/searchLease",
+}
+
+// opnsenseARP is an entry of OPNsense's ARP table.
+type opnsenseARP struct {
+	IP           string `json:"ip"`
+	MAC          string `json:"mac"`
+	Hostname     string `json:"hostname"`
+	Manufacturer string `json:"manufacturer"`
+	Intf         string `json:"intf"`
+	IntfDescr    string `json:"intf_description"`
+	Expired      bool   `json:"expired"`
+}
+
+// opnsenseLease is a row of one of OPNsense's lease lists, which name the
+// same things differently.
+type opnsenseLease struct {
+	Address  string `json:"address"`
+	MAC      string `json:"mac"`
+	HWAddr   string `json:"hwaddr"`
+	Hostname string `json:"hostname"`
+	Man      string `json:"man"`
+	If       string `json:"if"`
+	IfDescr  string `json:"if_descr"`
+}
+
+// opnsense asks an OPNsense firewall for its ARP table and DHCP leases.
+func (c *Client) opnsense(ctx context.Context) (arp, leases []Device, err error) {
+	var table []opnsenseARP
+	if err := c.get(ctx, "/api/diagnostics/interface/getArp", &table); err != nil {
+		return nil, nil, err
+	}
+	for _, e := range table {
+		if e.Expired {
+			continue
+		}
+		arp = append(arp, Device{
+			IP:        e.IP,
+			MAC:       e.MAC,
+			Hostname:  e.Hostname,
+			Vendor:    e.Manufacturer,
+			Interface: firstOf(e.IntfDescr, e.Intf),
+		})
+	}
+
+	for _, path := range opnsenseLeases {
+		var reply struct {
+			Rows []opnsenseLease `json:"rows"`
+		}
+		err := c.get(ctx, path, &reply)
+		if errors.Is(err, errNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, l := range reply.Rows {
+			leases = append(leases, Device{
+				IP:        l.Address,
+				MAC:       firstOf(l.MAC, l.HWAddr),
+				Hostname:  l.Hostname,
+				Vendor:    l.Man,
+				Interface: firstOf(l.IfDescr, l.If),
+			})
+		}
+	}
+	return arp, leases, nil
+}
+
+// firstOf returns the first of values that is not empty once trimmed.
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
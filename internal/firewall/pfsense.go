@@ -0,0 +1,102 @@
+package firewall
+
+import (
+	"context"
+	"errors"
+)
+
+// pfsenseInterface is an interface as the REST API lists it: id is the
+// name pfSense gives it in its config, such as "opt1", and If the
+// operating system's, such as "igb0.20".
+type pfsenseInterface struct {
+	ID    string `json:"id"`
+	If    string `json:"if"`
+	Descr string `json:"descr"`
+}
+
+// pfsenseARP is an entry of pfSense's ARP table.
+type pfsenseARP struct {
+	IP        string `json:"ip_address"`
+	MAC       string `json:"mac_address"`
+	Hostname  string `json:"hostname"`
+	Interface string `json:"interface"`
+}
+
+// pfsenseLease is one of the DHCP server's leases.
+type pfsenseLease struct {
+	IP           string `json:"ip"`
+	MAC          string `json:"mac"`
+	Hostname     string `json:"hostname"`
+	If           string `json:"if"`
+	ActiveStatus string `json:"active_status"`
+}
+
+// pfsense asks a pfSense firewall for its ARP table and DHCP leases,
+// through the REST API package.
+func (c *Client) pfsense(ctx context.Context) (arp, leases []Device, err error) {
+	// The table and the leases name interfaces in two different ways, and
+	// neither by the description that names the VLAN.
+	var interfaces struct {
+		Data []pfsenseInterface `json:"data"`
+	}
+	if err := c.get(ctx, "/api/v2/interfaces", &interfaces); err != nil {
+		if errors.Is(err, errNotFound) {
+			return nil, nil, errors.New("the firewall has no REST API; install the pfSense-pkg-RESTAPI package, version 2")
+		}
+		return nil, nil, err
+	}
+	descr := make(map[string]string)
+	for _, i := range interfaces.Data {
+		name := firstOf(i.Descr, i.ID)
+		descr[i.ID] = name
+		descr[i.If] = name
+	}
+	describe := func(name string) string {
+		if d, ok := descr[name]; ok {
+			return d
+		}
+		return name
+	}
+
+	var table struct {
+		Data []pfsenseARP `json:"data"`
+	}
+	if err := c.get(ctx, "/api/v2/diagnostics/arp_table", &table); err != nil {
+		return nil, nil, err
+	}
+	for _, e := range table.Data {
+		arp = append(arp, Device{
+			IP:        e.IP,
+			MAC:       e.MAC,
+			Hostname:  unknownName(e.Hostname),
+			Interface: describe(e.Interface),
+		})
+	}
+
+	var reply struct {
+		Data []pfsenseLease `json:"data"`
+	}
+	if err := c.get(ctx, "/api/v2/status/dhcp_server/leases", &reply); err != nil {
+		return nil, nil, err
+	}
+	for _, l := range reply.Data {
+		if l.ActiveStatus != "" && l.ActiveStatus != "active" {
+			continue
+		}
+		leases = append(leases, Device{
+			IP:        l.IP,
+			MAC:       l.MAC,
+			Hostname:  l.Hostname,
+			Interface: describe(l.If),
+		})
+	}
+	return arp, leases, nil
+}
+
+// unknownName returns name unless it is the "?" pfSense shows for none.
+func unknownName(name string) string {
+	if name == "?" {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,209 @@
+// Package i18n translates the web interface.
+//
+// Messages live in JSON catalogs under locales/, one file per language, keyed
+// by a dotted message ID. English is the reference: any message missing from
+// another catalog falls back to it, so a partial translation is still usable
+// and never shows a raw key.
+//
+// Placeholders are written {0}, {1} and so on rather than as fmt verbs, so a
+// translator can reorder them freely. Plurals use two IDs, "<id>.one" and
+// "<id>.other", chosen by count; that covers the languages shipped so far, and
+// a language needing more forms can add them here when it arrives.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Fallback is the language every catalog is checked against, and the one used
+// when nothing better matches.
+const Fallback = "en"
+
+//go:embed locales/*.json
+var localeFS embed.FS
+
+var (
+	loadOnce sync.Once
+	catalogs map[string]map[string]string
+	loadErr  error
+)
+
+// load reads every embedded catalog on first use.
+func load() {
+	catalogs = make(map[string]map[string]string)
+
+	entries, err := fs.ReadDir(localeFS, "locales")
+	if err != nil {
+		loadErr = err
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".json" {
+			continue
+		}
+		data, err := localeFS.ReadFile("locales/" + e.Name())
+		if err != nil {
+			loadErr = err
+			return
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			loadErr = fmt.Errorf("locale %s: %w", e.Name(), err)
+			return
+		}
+		catalogs[strings.TrimSuffix(e.Name(), ".json")] = messages
+	}
+}
+
+// Err reports a problem reading the built-in catalogs. It exists for tests:
+// a catalog that fails to parse would otherwise only show up as English
+// everywhere.
+func Err() error {
+	loadOnce.Do(load)
+	return loadErr
+}
+
+// Languages returns the codes of every shipped language, sorted.
+func Languages() []string {
+	loadOnce.Do(load)
+
+	out := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		out = append(out, lang)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Messages returns the complete catalog for lang, with English filling any
+// gaps. If prefix is not empty only IDs starting with it are included, which
+// is how the browser script is given just the messages it needs.
+func Messages(lang, prefix string) map[string]string {
+	loadOnce.Do(load)
+
+	out := make(map[string]string)
+	for id, msg := range catalogs[Fallback] {
+		if strings.HasPrefix(id, prefix) {
+			out[id] = msg
+		}
+	}
+	for id, msg := range catalogs[lang] {
+		if strings.HasPrefix(id, prefix) && msg != "" {
+			out[id] = msg
+		}
+	}
+	return out
+}
+
+// T returns the message id in lang with its placeholders filled from args.
+//
+// An ID missing from every catalog is returned as is, which makes a typo in a
+// template obvious on the page instead of rendering nothing.
+func T(lang, id string, args ...any) string {
+	loadOnce.Do(load)
+
+	msg, ok := catalogs[lang][id]
+	if !ok || msg == "" {
+		msg, ok = catalogs[Fallback][id]
+	}
+	if !ok {
+		return id
+	}
+	return format(msg, args)
+}
+
+// N returns the singular or plural form of id for count. The count is
+// available to the message as {0}, followed by any further args.
+func N(lang, id string, count int, args ...any) string {
+	form := ".other"
+	if count == 1 {
+		form = ".one"
+	}
+	return T(lang, id+form, append([]any{count}, args...)...)
+}
+
+// format replaces {0}, {1}, ... in msg with args.
+func format(msg string, args []any) string {
+	if len(args) == 0 || !strings.Contains(msg, "{") {
+		return msg
+	}
+	pairs := make([]string, 0, 2*len(args))
+	for i, a := range args {
+		pairs = append(pairs, "{"+strconv.Itoa(i)+"}", fmt.Sprint(a))
+	}
+	return strings.NewReplacer(pairs...).Replace(msg)
+}
+
+// Negotiate picks the language to show.
+//
+// A configured language wins when it is one that ships. Otherwise ("auto", or
+// anything unrecognised) the browser's Accept-Language header is consulted in
+// order of preference, matching either the exact tag or its base language, so
+// "pt-BR" is served by "pt" if that is all there is.
+func Negotiate(configured, acceptLanguage string) string {
+	loadOnce.Do(load)
+
+	if c := strings.ToLower(strings.TrimSpace(configured)); c != "" && c != "auto" {
+		if _, ok := catalogs[c]; ok {
+			return c
+		}
+	}
+
+	for _, tag := range parseAcceptLanguage(acceptLanguage) {
+		if _, ok := catalogs[tag]; ok {
+			return tag
+		}
+		if base, _, found := strings.Cut(tag, "-"); found {
+			if _, ok := catalogs[base]; ok {
+				return base
+			}
+		}
+	}
+	return Fallback
+}
+
+// parseAcceptLanguage returns the language tags in an Accept-Language header,
+// lowercased and ordered from most to least preferred.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		tag, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" || q <= 0 {
+			continue
+		}
+		tags = append(tags, weighted{tag, q})
+	}
+
+	// Stable, so equally weighted tags keep the order the browser sent.
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	out := make([]string, len(tags))
+	for i, t := range tags {
+		out[i] = t.tag
+	}
+	return out
+}
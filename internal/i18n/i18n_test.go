@@ -0,0 +1,125 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+)
+
+// withCatalog installs an extra catalog for the duration of a test, standing
+// in for a community translation.
+func withCatalog(t *testing.T, lang string, messages map[string]string) {
+	t.Helper()
+	loadOnce.Do(load)
+	catalogs[lang] = messages
+	t.Cleanup(func() { delete(catalogs, lang) })
+}
+
+func TestCatalogsLoad(t *testing.T) {
+	if err := Err(); err != nil {
+		t.Fatalf("loading catalogs: %v", err)
+	}
+	if len(catalogs[Fallback]) == 0 {
+		t.Fatalf("the %s catalog is empty", Fallback)
+	}
+}
+
+// Every shipped catalog must use only IDs English has, since an ID nothing
+// else knows about is a typo that silently never shows.
+func TestCatalogsOnlyUseKnownIDs(t *testing.T) {
+	for _, lang := range Languages() {
+		for id := range catalogs[lang] {
+			if _, ok := catalogs[Fallback][id]; !ok {
+				t.Errorf("%s: %q is not in the %s catalog", lang, id, Fallback)
+			}
+		}
+	}
+}
+
+func TestEveryCatalogNamesItself(t *testing.T) {
+	for _, lang := range Languages() {
+		if catalogs[lang]["language.name"] == "" {
+			t.Errorf("%s: language.name is missing", lang)
+		}
+	}
+}
+
+func TestPluralsComeInPairs(t *testing.T) {
+	en := catalogs[Fallback]
+	for id := range en {
+		if base, ok := strings.CutSuffix(id, ".one"); ok {
+			if _, ok := en[base+".other"]; !ok {
+				t.Errorf("%q has no .other form", base)
+			}
+		}
+	}
+}
+
+func TestTFillsPlaceholders(t *testing.T) {
+	withCatalog(t, "xx", map[string]string{"greet": "{1}, {0}!"})
+
+	if got := T("xx", "greet", "world", "hello"); got != "hello, world!" {
+		t.Errorf("T = %q, want placeholders filled in the translator's order", got)
+	}
+}
+
+func TestTFallsBackToEnglish(t *testing.T) {
+	withCatalog(t, "xx", map[string]string{"nav.settings": ""})
+
+	if got := T("xx", "nav.settings"); got != "Settings" {
+		t.Errorf("T = %q, want the English text for an untranslated message", got)
+	}
+	if got := T("xx", "no.such.message"); got != "no.such.message" {
+		t.Errorf("T = %q, want an unknown ID returned as is", got)
+	}
+}
+
+func TestNChoosesForm(t *testing.T) {
+	if got := N(Fallback, "devices.showing", 1); got != "Showing 1 device" {
+		t.Errorf("N(1) = %q", got)
+	}
+	if got := N(Fallback, "devices.showing", 3); got != "Showing 3 devices" {
+		t.Errorf("N(3) = %q", got)
+	}
+}
+
+func TestMessagesFiltersByPrefix(t *testing.T) {
+	withCatalog(t, "xx", map[string]string{"js.copied": "Kopiert!"})
+
+	m := Messages("xx", "js.")
+	if m["js.copied"] != "Kopiert!" {
+		t.Errorf("js.copied = %q, want the translation", m["js.copied"])
+	}
+	if m["js.copy_failed"] == "" {
+		t.Error("untranslated messages should be filled from English")
+	}
+	for id := range m {
+		if !strings.HasPrefix(id, "js.") {
+			t.Errorf("%q does not have the requested prefix", id)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	withCatalog(t, "de", map[string]string{"language.name": "Deutsch"})
+	withCatalog(t, "pt-br", map[string]string{"language.name": "Português (Brasil)"})
+
+	tests := []struct {
+		configured, header, want string
+	}{
+		{"auto", "", "en"},
+		{"auto", "de-DE,de;q=0.9,en;q=0.8", "de"},
+		{"auto", "fr, en;q=0.5", "en"},
+		{"auto", "en;q=0.3, de;q=0.7", "de"},
+		{"auto", "pt-BR", "pt-br"},
+		{"auto", "de;q=0", "en"},
+		{"de", "en", "de"},
+		{"DE", "", "de"},
+		{"klingon", "de", "de"},
+		{"", "de", "de"},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.configured, tt.header); got != tt.want {
+			t.Errorf("Negotiate(%q, %q) = %q, want %q", tt.configured, tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,499 @@
+// Package importer reads device lists from CSV and JSON files, whether saved
+// by LAN Orangutan's own exports or by a spreadsheet or another scanner, and
+// the exports of Fing, Angry IP Scanner and NetBox, for moving over from
+// them with the names given to devices there.
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// timeLayouts are the timestamp formats accepted for first and last seen:
+// the one the exports write, and RFC 3339 as used in the JSON files.
+var timeLayouts = []string{"2006-01-02 15:04:05", time.RFC3339, "2006-01-02"}
+
+// columns maps a CSV header, lowercased with everything but letters
+// removed, to the field it fills. Several spellings are accepted because
+// every tool names its columns a little differently; "name" is the label,
+// since that is what other scanners call the name a user gives a device.
+var columns = map[string]string{
+	"ip":           "ip",
+	"ipaddress":    "ip",
+	"address":      "ip",
+	"mac":          "mac",
+	"macaddress":   "mac",
+	"hostname":     "hostname",
+	"host":         "hostname",
+	"vendor":       "vendor",
+	"manufacturer": "vendor",
+	"label":        "label",
+	"name":         "label",
+	"notes":        "notes",
+	"note":         "notes",
+	"comment":      "notes",
+	"comments":     "notes",
+	"group":        "group",
+	"type":         "type",
+	"tags":         "tags",
+	"firstseen":    "first_seen",
+	"lastseen":     "last_seen",
+}
+
+// Formats are the formats Parse reads.
+var Formats = []string{"csv", "json", "fing", "angryip", "netbox-csv"}
+
+// Parse reads devices from data. format is one of Formats, or "" to tell
+// csv from json by the content. Every device has an IP or MAC address; a record with
+// neither, or with an address that does not parse, fails the whole import so
+// that a bad file is fixed rather than half loaded.
+func Parse(data []byte, format string) ([]types.Device, error) {
+	if format == "" {
+		format = "csv"
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+			format = "json"
+		}
+	}
+
+	switch format {
+	case "csv":
+		return parseCSV(data, plainCSV)
+	case "json":
+		return parseJSON(data)
+	case "fing":
+		return parseCSV(data, fingCSV)
+	case "angryip":
+		return parseCSV(data, angryIPCSV)
+	case "netbox-csv":
+		return parseCSV(data, netboxCSV)
+	default:
+		last := len(Formats) - 1
+		return nil, fmt.Errorf("unknown format %q (use %s or %s)", format, strings.Join(Formats[:last], ", "), Formats[last])
+	}
+}
+
+// csvDialect is how one tool writes its CSV files.
+type csvDialect struct {
+	// columns maps headers, as headerKey reduces them, to fields.
+	columns map[string]string
+	// preamble is true for files with lines of their own before the
+	// header, which is then the first line naming an address column.
+	preamble bool
+	// headerless, when set, gives the fields of a file with no header,
+	// told by its first value being an IP address.
+	headerless []string
+	// clean, when set, tidies the value of field before it is used.
+	clean func(field, value string) string
+}
+
+var plainCSV = csvDialect{columns: columns}
+
+func parseCSV(data []byte, dialect csvDialect) ([]types.Device, error) {
+	// Spreadsheets often save with a byte order mark, which would otherwise
+	// end up in the first header.
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = delimiter(data)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	r.LazyQuotes = dialect.preamble
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	// header is the index of the header line; records after it are
+	// devices. fields says which field each column fills.
+	header := -1
+	var fields []string
+	if dialect.headerless != nil && net.ParseIP(strings.TrimSpace(records[0][0])) != nil {
+		fields = dialect.headerless
+	} else {
+		for n, record := range records {
+			fields = make([]string, len(record))
+			known := false
+			for i, h := range record {
+				fields[i] = dialect.columns[headerKey(h)]
+				known = known || fields[i] == "ip" || fields[i] == "mac"
+			}
+			if known {
+				header = n
+				break
+			}
+			if !dialect.preamble {
+				return nil, fmt.Errorf("the CSV header has no IP or MAC address column")
+			}
+		}
+		if header < 0 {
+			return nil, fmt.Errorf("the file has no header line with an IP or MAC address column")
+		}
+	}
+
+	var devices []types.Device
+	for n := header + 1; n < len(records); n++ {
+		values := make(map[string]string)
+		for i, v := range records[n] {
+			if i >= len(fields) || fields[i] == "" {
+				continue
+			}
+			v = strings.TrimSpace(v)
+			if dialect.clean != nil {
+				v = dialect.clean(fields[i], v)
+			}
+			// A field given by two columns takes the first that says
+			// something.
+			if values[fields[i]] == "" {
+				values[fields[i]] = v
+			}
+		}
+		if isBlank(values) {
+			continue
+		}
+
+		d, err := deviceFrom(values)
+		if err != nil {
+			// Line numbers count from 1, as a spreadsheet would.
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
+
+// delimiter returns the character separating the values of the CSV file
+// data: a semicolon when the first line has more of them than commas, as in
+// files saved by spreadsheets in locales with a decimal comma, or else a
+// comma.
+func delimiter(data []byte) rune {
+	first, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(first, []byte(";")) > bytes.Count(first, []byte(",")) {
+		return ';'
+	}
+	return ','
+}
+
+// fingCSV is the export of the Fing app and Fingbox, or the CSV of the
+// Fing command line tool, which has no header. Fing calls the name a user
+// gives a device its name, and the one the device gives itself its host
+// name.
+var fingCSV = csvDialect{
+	columns: map[string]string{
+		"ip":          "ip",
+		"ipaddress":   "ip",
+		"mac":         "mac",
+		"macaddress":  "mac",
+		"hwaddress":   "mac",
+		"name":        "label",
+		"hostname":    "hostname",
+		"vendor":      "vendor",
+		"brand":       "vendor",
+		"hwvendor":    "vendor",
+		"make":        "vendor",
+		"type":        "type",
+		"devicetype":  "type",
+		"notes":       "notes",
+		"note":        "notes",
+		"location":    "group",
+		"firstseen":   "first_seen",
+		"lastseen":    "last_seen",
+		"lastchanged": "last_seen",
+	},
+	headerless: []string{"ip", "", "", "hostname", "mac", "vendor"},
+	clean: func(field, value string) string {
+		if field == "type" {
+			return guessType(value)
+		}
+		return value
+	},
+}
+
+// typeWords maps words in the device types of other tools to ours, tried
+// in order so that "Smart TV" is a TV rather than something smart. Types
+// with none of them are left for the user to set.
+var typeWords = []struct{ word, typ string }{
+	{"router", "router"}, {"gateway", "router"}, {"access point", "router"},
+	{"modem", "router"}, {"switch", "router"}, {"wifi", "router"},
+	{"mesh", "router"}, {"firewall", "router"},
+	{"tablet", "tablet"}, {"ipad", "tablet"},
+	{"laptop", "laptop"}, {"notebook", "laptop"},
+	{"phone", "phone"}, {"mobile", "phone"},
+	{"tv", "tv"}, {"television", "tv"}, {"media player", "tv"},
+	{"streaming", "tv"}, {"chromecast", "tv"},
+	{"speaker", "speaker"}, {"voice", "speaker"},
+	{"printer", "printer"}, {"scanner", "printer"},
+	{"camera", "camera"}, {"doorbell", "camera"},
+	{"console", "console"}, {"game", "console"},
+	{"nas", "nas"}, {"storage", "nas"},
+	{"raspberry", "pi"},
+	{"virtual", "vm"},
+	{"server", "server"},
+	{"desktop", "desktop"}, {"computer", "desktop"}, {"workstation", "desktop"},
+	{"thermostat", "iot"}, {"light", "iot"}, {"plug", "iot"}, {"sensor", "iot"},
+	{"smart", "iot"}, {"appliance", "iot"}, {"iot", "iot"},
+}
+
+// fingType returns our type for a Fing device type, or "" if there is none.
+func guessType(value string) string {
+	words := strings.Join(strings.Fields(strings.ToLower(strings.NewReplacer("_", " ", "-", " ").Replace(value))), " ")
+	for _, ft := range typeWords {
+		for _, w := range strings.Fields(words) {
+			if w == ft.word {
+				return ft.typ
+			}
+		}
+		if strings.Contains(ft.word, " ") && strings.Contains(words, ft.word) {
+			return ft.typ
+		}
+	}
+	return ""
+}
+
+// angryIPCSV is a CSV export of Angry IP Scanner, which starts with lines
+// describing the scan and marks values not fetched as [n/a] or [n/s].
+// Comments are what the user wrote about a host, so they become its label.
+var angryIPCSV = csvDialect{
+	columns: map[string]string{
+		"ip":            "ip",
+		"hostname":      "hostname",
+		"macaddress":    "mac",
+		"mac":           "mac",
+		"macvendor":     "vendor",
+		"comments":      "label",
+		"comment":       "label",
+		"netbiosinfo":   "notes",
+		"webdetect":     "notes",
+		"mactimestamp":  "",
+		"ping":          "",
+		"ports":         "",
+		"filteredports": "",
+	},
+	preamble: true,
+	clean: func(field, value string) string {
+		switch value {
+		case "[n/a]", "[n/s]", "[n/r]":
+			return ""
+		}
+		return value
+	},
+}
+
+// netboxCSV is the CSV export of NetBox's IP addresses, whose addresses
+// carry a prefix length, or of its devices, which have a primary address.
+// NetBox's tenants are the nearest it has to groups.
+var netboxCSV = csvDialect{
+	columns: map[string]string{
+		"address":      "ip",
+		"ipaddress":    "ip",
+		"primaryip":    "ip",
+		"primaryipv":   "ip",
+		"dnsname":      "hostname",
+		"device":       "label",
+		"name":         "label",
+		"description":  "notes",
+		"comments":     "notes",
+		"tenant":       "group",
+		"manufacturer": "vendor",
+		"macaddress":   "mac",
+		"role":         "type",
+		"devicerole":   "type",
+		"tags":         "tags",
+		"created":      "first_seen",
+		"lastupdated":  "",
+	},
+	clean: func(field, value string) string {
+		switch field {
+		case "ip":
+			// Interfaces as well as prefixes carry a length; drop it.
+			if ip, _, err := net.ParseCIDR(value); err == nil {
+				return ip.String()
+			}
+		case "type":
+			return guessType(value)
+		case "first_seen":
+			// NetBox writes times with a zone and fractions of a second.
+			if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+				return t.Format(time.RFC3339)
+			}
+		}
+		return value
+	},
+}
+
+// jsonDevice is a device as it appears in any of the JSON files this reads.
+// Tags may be a list, as the API writes them, or a single string, as the
+// browser export does.
+type jsonDevice struct {
+	IP        string          `json:"ip"`
+	MAC       string          `json:"mac"`
+	Hostname  string          `json:"hostname"`
+	Vendor    string          `json:"vendor"`
+	Label     string          `json:"label"`
+	Notes     string          `json:"notes"`
+	Group     string          `json:"group"`
+	Type      string          `json:"type"`
+	Tags      json.RawMessage `json:"tags"`
+	FirstSeen string          `json:"first_seen"`
+	LastSeen  string          `json:"last_seen"`
+}
+
+// parseJSON accepts a list of devices, an object of devices keyed by
+// address as in devices.json, or either wrapped in an API response.
+func parseJSON(data []byte) ([]types.Device, error) {
+	var wrapper struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &wrapper); err == nil && len(wrapper.Data) > 0 {
+		data = wrapper.Data
+	}
+
+	var list []jsonDevice
+	if err := json.Unmarshal(data, &list); err != nil {
+		var byIP map[string]jsonDevice
+		if err := json.Unmarshal(data, &byIP); err != nil {
+			return nil, fmt.Errorf("invalid JSON: expected a list of devices or an object keyed by address")
+		}
+		keys := make([]string, 0, len(byIP))
+		for ip := range byIP {
+			keys = append(keys, ip)
+		}
+		sort.Strings(keys)
+		for _, ip := range keys {
+			jd := byIP[ip]
+			if jd.IP == "" {
+				jd.IP = ip
+			}
+			list = append(list, jd)
+		}
+	}
+
+	var devices []types.Device
+	for i, jd := range list {
+		values := map[string]string{
+			"ip":         jd.IP,
+			"mac":        jd.MAC,
+			"hostname":   jd.Hostname,
+			"vendor":     jd.Vendor,
+			"label":      jd.Label,
+			"notes":      jd.Notes,
+			"group":      jd.Group,
+			"type":       jd.Type,
+			"first_seen": jd.FirstSeen,
+			"last_seen":  jd.LastSeen,
+		}
+		if len(jd.Tags) > 0 {
+			var tags []string
+			var s string
+			if err := json.Unmarshal(jd.Tags, &tags); err == nil {
+				values["tags"] = strings.Join(tags, ";")
+			} else if err := json.Unmarshal(jd.Tags, &s); err == nil {
+				values["tags"] = s
+			}
+		}
+		if isBlank(values) {
+			continue
+		}
+
+		d, err := deviceFrom(values)
+		if err != nil {
+			return nil, fmt.Errorf("device %d: %w", i+1, err)
+		}
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
+
+// deviceFrom builds a device from the values of one record, keyed by field.
+func deviceFrom(values map[string]string) (types.Device, error) {
+	d := types.Device{
+		IP:       values["ip"],
+		Hostname: values["hostname"],
+		Vendor:   values["vendor"],
+		Label:    values["label"],
+		Notes:    values["notes"],
+		Group:    values["group"],
+		Type:     values["type"],
+	}
+
+	if d.IP == "" && values["mac"] == "" {
+		return d, fmt.Errorf("no IP or MAC address")
+	}
+	if d.IP != "" && net.ParseIP(d.IP) == nil {
+		return d, fmt.Errorf("invalid IP address %q", d.IP)
+	}
+	if mac := values["mac"]; mac != "" {
+		hw, err := net.ParseMAC(mac)
+		if err != nil {
+			return d, fmt.Errorf("invalid MAC address %q", mac)
+		}
+		// Scanners report MACs in upper case; match them.
+		d.MAC = strings.ToUpper(hw.String())
+	}
+
+	// Exports separate tags with semicolons, so commas are left alone
+	// unless there is nothing else to split on.
+	sep := ";"
+	if !strings.Contains(values["tags"], ";") {
+		sep = ","
+	}
+	for _, tag := range strings.Split(values["tags"], sep) {
+		d.AddTag(tag)
+	}
+
+	var err error
+	if d.FirstSeen, err = parseTime(values["first_seen"]); err != nil {
+		return d, err
+	}
+	if d.LastSeen, err = parseTime(values["last_seen"]); err != nil {
+		return d, err
+	}
+	return d, nil
+}
+
+// parseTime reads a timestamp in any of timeLayouts. An empty string is the
+// zero time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// headerKey reduces a CSV header to lowercase letters, so "IP Address",
+// "ip_address" and "IPAddress" all look the same.
+func headerKey(h string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(h) {
+		if r >= 'a' && r <= 'z' {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// isBlank reports whether a record has no values at all, like the empty
+// lines spreadsheets leave at the end of a sheet.
+func isBlank(values map[string]string) bool {
+	for _, v := range values {
+		if v != "" {
+			return false
+		}
+	}
+	return true
+}
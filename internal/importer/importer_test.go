@@ -0,0 +1,194 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCLIExport(t *testing.T) {
+	data := `IP Address,MAC Address,Hostname,Vendor,Label,Notes,Group,First Seen,Last Seen,Status
+192.168.1.1,aa:bb:cc:dd:ee:01,router.lan,Netgear,Router,"Upstairs, by the stairs",Network,2026-01-02 03:04:05,2026-01-03 03:04:05,online
+192.168.1.2,,,,,,,2026-01-02 03:04:05,2026-01-02 03:04:05,offline
+`
+	got, err := Parse([]byte(data), "")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d devices, want 2", len(got))
+	}
+
+	d := got[0]
+	if d.IP != "192.168.1.1" || d.MAC != "AA:BB:CC:DD:EE:01" || d.Label != "Router" ||
+		d.Group != "Network" || d.Notes != "Upstairs, by the stairs" || d.Hostname != "router.lan" {
+		t.Errorf("first device parsed wrongly: %+v", d)
+	}
+	if d.FirstSeen.IsZero() || !d.LastSeen.After(d.FirstSeen) {
+		t.Errorf("seen times parsed wrongly: first %v, last %v", d.FirstSeen, d.LastSeen)
+	}
+}
+
+func TestParseBrowserExport(t *testing.T) {
+	data := `"IP","Hostname","MAC","Vendor","Label","Group","Tags","Status"
+"192.168.1.5","tv.lan","AA:BB:CC:DD:EE:05","","TV","Media","upstairs; streaming","online"`
+
+	got, err := Parse([]byte(data), "csv")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d devices, want 1", len(got))
+	}
+	if tags := strings.Join(got[0].Tags, ","); tags != "upstairs,streaming" {
+		t.Errorf("tags = %q, want upstairs,streaming", tags)
+	}
+}
+
+func TestParseSpreadsheetHeaders(t *testing.T) {
+	data := "\xef\xbb\xbfip_address,Name,Comment\n10.0.0.4,NAS,Backups\n,,\n"
+
+	got, err := Parse([]byte(data), "csv")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(got) != 1 || got[0].IP != "10.0.0.4" || got[0].Label != "NAS" || got[0].Notes != "Backups" {
+		t.Errorf("got %+v, want one device labelled NAS with notes", got)
+	}
+}
+
+func TestParseJSONShapes(t *testing.T) {
+	tests := []struct {
+		name, data string
+	}{
+		{"list", `[{"ip":"192.168.1.1","label":"Router","tags":["a","b"]}]`},
+		{"browser export", `[{"ip":"192.168.1.1","label":"Router","tags":"a; b"}]`},
+		{"devices.json", `{"192.168.1.1":{"label":"Router","tags":["a","b"]}}`},
+		{"api response", `{"success":true,"data":{"192.168.1.1":{"ip":"192.168.1.1","label":"Router","tags":["a","b"]}}}`},
+	}
+	for _, tt := range tests {
+		got, err := Parse([]byte(tt.data), "")
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(got) != 1 || got[0].IP != "192.168.1.1" || got[0].Label != "Router" || len(got[0].Tags) != 2 {
+			t.Errorf("%s: got %+v", tt.name, got)
+		}
+	}
+}
+
+func TestParseRejectsBadRecords(t *testing.T) {
+	tests := []struct {
+		name, data, want string
+	}{
+		{"no address column", "Label,Group\nTV,Media\n", "no IP or MAC"},
+		{"no address", "IP,MAC,Label\n,,TV\n", "line 2"},
+		{"bad IP", "IP,Label\n192.168.1.300,TV\n", "invalid IP"},
+		{"bad MAC", `[{"mac":"nope"}]`, "invalid MAC"},
+		{"bad time", "IP,Last Seen\n192.168.1.1,yesterday\n", "invalid time"},
+	}
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.data), "")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want one mentioning %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestParseFing(t *testing.T) {
+	data := `IP Address;HW Address;Name;HostName;Vendor;Type;Notes
+192.168.1.1;aa:bb:cc:00:00:01;Main router;router.lan;Netgear;Router;
+192.168.1.9;aa:bb:cc:00:00:09;Living room;;LG;Smart TV;Wall mounted
+192.168.1.12;;Thing;;;Unknown;
+`
+	got, err := Parse([]byte(data), "fing")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d devices, want 3", len(got))
+	}
+	if d := got[0]; d.Label != "Main router" || d.Hostname != "router.lan" || d.MAC != "AA:BB:CC:00:00:01" || d.Type != "router" {
+		t.Errorf("router parsed wrongly: %+v", d)
+	}
+	if d := got[1]; d.Type != "tv" || d.Notes != "Wall mounted" || d.Vendor != "LG" {
+		t.Errorf("TV parsed wrongly: %+v", d)
+	}
+	if got[2].Type != "" {
+		t.Errorf("unknown Fing type became %q", got[2].Type)
+	}
+
+	// The command line tool writes no header.
+	got, err = Parse([]byte("192.168.1.5;up;1700000000;phone.lan;aa:bb:cc:00:00:05;Apple\n"), "fing")
+	if err != nil || len(got) != 1 || got[0].Hostname != "phone.lan" || got[0].Vendor != "Apple" {
+		t.Errorf("Parse of Fing CLI output = %+v, %v", got, err)
+	}
+}
+
+func TestParseAngryIP(t *testing.T) {
+	data := `Generated by Angry IP Scanner 3.9.1
+http://angryip.org/
+
+Scanned 192.168.1.1 - 192.168.1.254
+Feeders: IP Range
+
+IP,Ping,Hostname,Ports [0+],MAC Address,MAC Vendor,Comments
+192.168.1.1,2 ms,router.lan,[n/s],AA:BB:CC:00:00:01,Netgear,Main router
+192.168.1.3,4 ms,[n/a],[n/s],[n/a],[n/a],
+`
+	got, err := Parse([]byte(data), "angryip")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d devices, want 2", len(got))
+	}
+	if d := got[0]; d.Label != "Main router" || d.Vendor != "Netgear" || d.Hostname != "router.lan" {
+		t.Errorf("router parsed wrongly: %+v", d)
+	}
+	if d := got[1]; d.Hostname != "" || d.MAC != "" || d.Vendor != "" {
+		t.Errorf("values not fetched were kept: %+v", d)
+	}
+}
+
+func TestParseNetBox(t *testing.T) {
+	data := `Address,VRF,Status,Role,Tenant,DNS Name,Description,Tags
+192.168.1.10/24,,Active,,Home,nas.lan,Synology in the cupboard,"storage,backup"
+fd00::10/64,,Active,,Home,nas.lan,,
+`
+	got, err := Parse([]byte(data), "netbox-csv")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d devices, want 2", len(got))
+	}
+	d := got[0]
+	if d.IP != "192.168.1.10" || d.Hostname != "nas.lan" || d.Group != "Home" || d.Notes != "Synology in the cupboard" {
+		t.Errorf("address parsed wrongly: %+v", d)
+	}
+	if len(d.Tags) != 2 || d.Tags[1] != "backup" {
+		t.Errorf("Tags = %v, want storage and backup", d.Tags)
+	}
+	if got[1].IP != "fd00::10" {
+		t.Errorf("IPv6 address = %q", got[1].IP)
+	}
+}
+
+func TestGuessType(t *testing.T) {
+	for in, want := range map[string]string{
+		"Mobile Phone": "phone", "Access Point": "router", "Game Console": "console",
+		"Smart TV": "tv", "SMART_PLUG": "iot", "Raspberry Pi": "pi", "Gadget": "",
+	} {
+		if got := guessType(in); got != want {
+			t.Errorf("guessType(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseUnknownFormat(t *testing.T) {
+	_, err := Parse([]byte("ip\n"), "xml")
+	if err == nil || !strings.Contains(err.Error(), "fing, angryip or netbox-csv") {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+// Package influx sends the outcome of each scan to InfluxDB: whether each
+// device of the network was up and how quickly it answered, and how long
+// the scan took, for homelabs whose graphs already live there.
+//
+// Points are written with the v2 write API, which InfluxDB 1.8 and later
+// also serve, in line protocol.
+package influx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// writeTimeout bounds a write, so that an InfluxDB that has gone away does
+// not hold up the next scan.
+const writeTimeout = 10 * time.Second
+
+// Measurements the points are written to.
+const (
+	DeviceMeasurement = "lan_orangutan_device"
+	ScanMeasurement   = "lan_orangutan_scan"
+)
+
+// Options say where to write.
+type Options struct {
+	// URL is the address of InfluxDB, such as http://localhost:8086.
+	URL    string
+	Org    string
+	Bucket string
+	// Token authorizes the writes. For InfluxDB 1.8 it is
+	// "username:password".
+	Token string
+}
+
+// Client writes scans to InfluxDB.
+type Client struct {
+	opts Options
+	http *http.Client
+}
+
+// New returns a client writing with opts.
+func New(opts Options) *Client {
+	return &Client{opts: opts, http: &http.Client{Timeout: writeTimeout}}
+}
+
+// ValidURL checks that u is an http or https URL InfluxDB can be reached at.
+func ValidURL(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("%q is not an http or https URL", u)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("%q has no host", u)
+	}
+	return nil
+}
+
+// WriteScan writes the points for a scan of the network cidr that found
+// result's devices, out of all the devices known.
+func (c *Client) WriteScan(ctx context.Context, cidr string, result *types.ScanResult, known map[string]*types.Device, now time.Time) error {
+	return c.write(ctx, Lines(cidr, result, known, now))
+}
+
+func (c *Client) write(ctx context.Context, body []byte) error {
+	endpoint := strings.TrimSuffix(c.opts.URL, "/") + "/api/v2/write?" + url.Values{
+		"org":       {c.opts.Org},
+		"bucket":    {c.opts.Bucket},
+		"precision": {"s"},
+	}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if c.opts.Token != "" {
+		req.Header.Set("Authorization", "Token "+c.opts.Token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	return fmt.Errorf("InfluxDB answered %s%s", resp.Status, reason(resp.Body))
+}
+
+// reason returns the message of an error InfluxDB sent as JSON, as ": " and
+// the message, or "" when there is none.
+func reason(body io.Reader) string {
+	var e struct {
+		Message string `json:"message"`
+		Error   string `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(body, 4096))
+	if json.Unmarshal(data, &e) != nil {
+		return ""
+	}
+	if e.Message != "" {
+		return ": " + e.Message
+	}
+	if e.Error != "" {
+		return ": " + e.Error
+	}
+	return ""
+}
+
+// Lines returns the points for a scan of cidr in line protocol: one for
+// each device in the network, up when the scan found it and down when it
+// did not, and one for the scan.
+func Lines(cidr string, result *types.ScanResult, known map[string]*types.Device, now time.Time) []byte {
+	_, network, _ := net.ParseCIDR(cidr)
+	found := make(map[string]types.Device, len(result.Devices))
+	for _, d := range result.Devices {
+		found[d.IP] = d
+	}
+
+	var ips []string
+	for ip := range found {
+		ips = append(ips, ip)
+	}
+	for ip := range known {
+		if _, ok := found[ip]; !ok && network != nil && network.Contains(net.ParseIP(ip)) {
+			ips = append(ips, ip)
+		}
+	}
+	sort.Strings(ips)
+
+	var buf bytes.Buffer
+	stamp := strconv.FormatInt(now.Unix(), 10)
+	for _, ip := range ips {
+		d, up := found[ip]
+		if k := known[ip]; k != nil {
+			// The inventory has the label and group the scan does not.
+			responseTime := d.ResponseTime
+			d = *k
+			d.ResponseTime = responseTime
+		}
+		buf.WriteString(DeviceMeasurement)
+		tags(&buf, "ip", ip, "mac", d.MAC, "name", name(&d), "group", d.Group, "network", cidr)
+		if up {
+			buf.WriteString(" up=1i")
+			if d.ResponseTime != nil {
+				buf.WriteString(",response_ms=" + strconv.FormatFloat(*d.ResponseTime, 'f', -1, 64))
+			}
+		} else {
+			buf.WriteString(" up=0i")
+		}
+		buf.WriteString(" " + stamp + "\n")
+	}
+
+	buf.WriteString(ScanMeasurement)
+	tags(&buf, "network", cidr, "scanner", result.Scanner)
+	fmt.Fprintf(&buf, " devices=%di,duration_seconds=%s %s\n",
+		result.DeviceCount, strconv.FormatFloat(result.Duration, 'f', -1, 64), stamp)
+	return buf.Bytes()
+}
+
+// tags writes pairs of tag keys and values, leaving out empty values, which
+// line protocol does not allow.
+func tags(buf *bytes.Buffer, pairs ...string) {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] == "" {
+			continue
+		}
+		buf.WriteString("," + pairs[i] + "=" + escape(pairs[i+1]))
+	}
+}
+
+// escape escapes a tag value for line protocol.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, "=", `\=`, " ", `\ `, "\n", " ").Replace(s)
+}
+
+// name is how a device is named in its tags: its label if it has one, then
+// its hostname, then its address.
+func name(d *types.Device) string {
+	switch {
+	case d.Label != "":
+		return d.Label
+	case d.Hostname != "":
+		return d.Hostname
+	default:
+		return d.IP
+	}
+}
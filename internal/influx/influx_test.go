@@ -0,0 +1,77 @@
+package influx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func TestLines(t *testing.T) {
+	rt := 3.25
+	now := time.Unix(1700000000, 0)
+	result := &types.ScanResult{
+		Devices:     []types.Device{{IP: "192.168.1.2", MAC: "AA:BB:CC:00:11:22", Hostname: "tv", ResponseTime: &rt}},
+		DeviceCount: 1,
+		Scanner:     "nmap",
+		Duration:    2.5,
+	}
+	known := map[string]*types.Device{
+		"192.168.1.2": {IP: "192.168.1.2", MAC: "AA:BB:CC:00:11:22", Hostname: "tv", Label: "Living room, TV", Group: "media"},
+		"192.168.1.3": {IP: "192.168.1.3", Hostname: "phone"},
+		"10.0.0.2":    {IP: "10.0.0.2", Hostname: "elsewhere"},
+	}
+	want := `lan_orangutan_device,ip=192.168.1.2,mac=AA:BB:CC:00:11:22,name=Living\ room\,\ TV,group=media,network=192.168.1.0/24 up=1i,response_ms=3.25 1700000000
+lan_orangutan_device,ip=192.168.1.3,name=phone,network=192.168.1.0/24 up=0i 1700000000
+lan_orangutan_scan,network=192.168.1.0/24,scanner=nmap devices=1i,duration_seconds=2.5 1700000000
+`
+	if got := string(Lines("192.168.1.0/24", result, known, now)); got != want {
+		t.Errorf("Lines =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteScan(t *testing.T) {
+	var gotURL, gotAuth, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURL = r.URL.String()
+		gotAuth = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	c := New(Options{URL: server.URL + "/", Org: "home", Bucket: "lan", Token: "t0ken"})
+	result := &types.ScanResult{Scanner: "nmap"}
+	if err := c.WriteScan(context.Background(), "10.0.0.0/24", result, nil, time.Now()); err != nil {
+		t.Fatalf("WriteScan: %v", err)
+	}
+	if gotURL != "/api/v2/write?bucket=lan&org=home&precision=s" {
+		t.Errorf("wrote to %s", gotURL)
+	}
+	if gotAuth != "Token t0ken" {
+		t.Errorf("Authorization = %q", gotAuth)
+	}
+	if !strings.HasPrefix(gotBody, "lan_orangutan_scan,network=10.0.0.0/24,scanner=nmap ") {
+		t.Errorf("body = %q", gotBody)
+	}
+}
+
+func TestWriteScanReportsTheError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"code":"not found","message":"bucket \"lan\" not found"}`)
+	}))
+	defer server.Close()
+
+	c := New(Options{URL: server.URL, Bucket: "lan"})
+	err := c.WriteScan(context.Background(), "10.0.0.0/24", &types.ScanResult{}, nil, time.Now())
+	if err == nil || err.Error() != `InfluxDB answered 404 Not Found: bucket "lan" not found` {
+		t.Errorf("WriteScan = %v", err)
+	}
+}
@@ -0,0 +1,368 @@
+// Package kube reads the nodes of a Kubernetes cluster, such as a homelab
+// k3s cluster on the same LAN, so that the machines a scan finds can be
+// told apart as cluster nodes, and the pod networks behind them are known.
+//
+// It reads a kubeconfig file as kubectl does and asks the API server
+// directly. Clusters reached through an exec or auth provider plugin, as
+// the big clouds' are, are not supported: give it a kubeconfig with a
+// client certificate or a token, as k3s writes one.
+package kube
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// requestTimeout bounds each request to the API server.
+const requestTimeout = 15 * time.Second
+
+// Tags given to nodes.
+const (
+	NodeTag         = "k8s:node"
+	ControlPlaneTag = "k8s:control-plane"
+)
+
+// Node is a node of the cluster.
+type Node struct {
+	Name string
+	// InternalIP and ExternalIP are the addresses the node reports.
+	InternalIP string
+	ExternalIP string
+	// ControlPlane is true for nodes that run the control plane.
+	ControlPlane bool
+	Ready        bool
+	// PodCIDRs are the networks the node's pods get addresses in.
+	PodCIDRs []string
+	Kubelet  string
+	OS       string
+}
+
+// Client asks a cluster's API server about its nodes.
+type Client struct {
+	server string
+	token  string
+	http   *http.Client
+	// now is the clock, replaced in tests.
+	now func() time.Time
+}
+
+// DefaultKubeconfig returns the file kubectl reads: the first file of
+// $KUBECONFIG, or else ~/.kube/config.
+func DefaultKubeconfig() string {
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		return filepath.SplitList(env)[0]
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".kube", "config")
+}
+
+// kubeconfig is the part of a kubeconfig file that is used.
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Contexts       []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Clusters []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string    `yaml:"token"`
+			TokenFile             string    `yaml:"tokenFile"`
+			ClientCertificate     string    `yaml:"client-certificate"`
+			ClientCertificateData string    `yaml:"client-certificate-data"`
+			ClientKey             string    `yaml:"client-key"`
+			ClientKeyData         string    `yaml:"client-key-data"`
+			Exec                  yaml.Node `yaml:"exec"`
+			AuthProvider          yaml.Node `yaml:"auth-provider"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+}
+
+// New returns a client for the cluster of context in the kubeconfig file at
+// path, or of its current context when context is empty.
+func New(path, context string) (*Client, error) {
+	if path == "" {
+		path = DefaultKubeconfig()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var kc kubeconfig
+	if err := yaml.Unmarshal(data, &kc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	// Relative file names in a kubeconfig are relative to it.
+	dir := filepath.Dir(path)
+	file := func(name string) string {
+		if name == "" || filepath.IsAbs(name) {
+			return name
+		}
+		return filepath.Join(dir, name)
+	}
+
+	if context == "" {
+		context = kc.CurrentContext
+	}
+	var cluster, user string
+	found := false
+	for _, c := range kc.Contexts {
+		if c.Name == context {
+			cluster, user, found = c.Context.Cluster, c.Context.User, true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%s has no context %q", path, context)
+	}
+
+	tlsConfig := &tls.Config{}
+	c := &Client{now: time.Now}
+	found = false
+	for _, cl := range kc.Clusters {
+		if cl.Name != cluster {
+			continue
+		}
+		found = true
+		c.server = strings.TrimSuffix(cl.Cluster.Server, "/")
+		tlsConfig.InsecureSkipVerify = cl.Cluster.InsecureSkipTLSVerify
+		ca, err := pemData(cl.Cluster.CertificateAuthorityData, file(cl.Cluster.CertificateAuthority))
+		if err != nil {
+			return nil, fmt.Errorf("certificate authority: %w", err)
+		}
+		if ca != nil {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(ca) {
+				return nil, errors.New("the certificate authority holds no PEM certificates")
+			}
+			tlsConfig.RootCAs = pool
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%s has no cluster %q", path, cluster)
+	}
+	if u, err := url.Parse(c.server); err != nil || u.Scheme != "https" && u.Scheme != "http" || u.Host == "" {
+		return nil, fmt.Errorf("the cluster's server %q is not an https URL", c.server)
+	}
+
+	for _, u := range kc.Users {
+		if u.Name != user {
+			continue
+		}
+		if !u.User.Exec.IsZero() || !u.User.AuthProvider.IsZero() {
+			return nil, fmt.Errorf("user %q logs in with a plugin, which is not supported; use a token or client certificate", u.Name)
+		}
+		c.token = u.User.Token
+		if c.token == "" && u.User.TokenFile != "" {
+			token, err := os.ReadFile(file(u.User.TokenFile))
+			if err != nil {
+				return nil, err
+			}
+			c.token = strings.TrimSpace(string(token))
+		}
+		cert, err := pemData(u.User.ClientCertificateData, file(u.User.ClientCertificate))
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %w", err)
+		}
+		key, err := pemData(u.User.ClientKeyData, file(u.User.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("client key: %w", err)
+		}
+		if cert != nil && key != nil {
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return nil, fmt.Errorf("client certificate: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{pair}
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	c.http = &http.Client{Timeout: requestTimeout, Transport: transport}
+	return c, nil
+}
+
+// pemData returns the base64 data of a kubeconfig, or else the file it
+// names, or nil when both are empty.
+func pemData(data, file string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if file != "" {
+		return os.ReadFile(file)
+	}
+	return nil, nil
+}
+
+// nodeList is the part of the API's list of nodes that is used.
+type nodeList struct {
+	Items []struct {
+		Metadata struct {
+			Name   string            `json:"name"`
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+		Spec struct {
+			PodCIDR  string   `json:"podCIDR"`
+			PodCIDRs []string `json:"podCIDRs"`
+		} `json:"spec"`
+		Status struct {
+			Addresses []struct {
+				Type    string `json:"type"`
+				Address string `json:"address"`
+			} `json:"addresses"`
+			Conditions []struct {
+				Type   string `json:"type"`
+				Status string `json:"status"`
+			} `json:"conditions"`
+			NodeInfo struct {
+				KubeletVersion string `json:"kubeletVersion"`
+				OSImage        string `json:"osImage"`
+			} `json:"nodeInfo"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// Nodes returns the nodes of the cluster, by name.
+func (c *Client) Nodes(ctx context.Context) ([]Node, error) {
+	var list nodeList
+	if err := c.get(ctx, "/api/v1/nodes", &list); err != nil {
+		return nil, err
+	}
+	nodes := make([]Node, 0, len(list.Items))
+	for _, item := range list.Items {
+		n := Node{
+			Name:     item.Metadata.Name,
+			PodCIDRs: item.Spec.PodCIDRs,
+			Kubelet:  item.Status.NodeInfo.KubeletVersion,
+			OS:       item.Status.NodeInfo.OSImage,
+		}
+		if len(n.PodCIDRs) == 0 && item.Spec.PodCIDR != "" {
+			n.PodCIDRs = []string{item.Spec.PodCIDR}
+		}
+		// Older clusters, and k3s until recently, label it master.
+		for _, role := range []string{"control-plane", "master"} {
+			if _, ok := item.Metadata.Labels["node-role.kubernetes.io/"+role]; ok {
+				n.ControlPlane = true
+			}
+		}
+		for _, a := range item.Status.Addresses {
+			switch {
+			case a.Type == "InternalIP" && n.InternalIP == "":
+				n.InternalIP = a.Address
+			case a.Type == "ExternalIP" && n.ExternalIP == "":
+				n.ExternalIP = a.Address
+			}
+		}
+		for _, cond := range item.Status.Conditions {
+			if cond.Type == "Ready" {
+				n.Ready = cond.Status == "True"
+			}
+		}
+		nodes = append(nodes, n)
+	}
+	slices.SortFunc(nodes, func(a, b Node) int { return strings.Compare(a.Name, b.Name) })
+	return nodes, nil
+}
+
+// Name implements scanner.Source.
+func (c *Client) Name() string { return "kubernetes" }
+
+// Devices returns the nodes with an address in network as devices, named
+// after the node and tagged as nodes. Ready nodes have LastSeen set.
+func (c *Client) Devices(ctx context.Context, network *net.IPNet) ([]types.Device, error) {
+	nodes, err := c.Nodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NodeDevices(nodes, network, c.now()), nil
+}
+
+// NodeDevices returns nodes as devices, at each address they have in
+// network, or at every address when network is nil. Ready nodes are seen
+// at now.
+func NodeDevices(nodes []Node, network *net.IPNet, now time.Time) []types.Device {
+	var devices []types.Device
+	for _, n := range nodes {
+		for _, addr := range []string{n.InternalIP, n.ExternalIP} {
+			ip := net.ParseIP(addr)
+			if ip == nil || network != nil && !network.Contains(ip) {
+				continue
+			}
+			d := types.Device{IP: addr, Hostname: n.Name, Tags: []string{NodeTag}}
+			if n.ControlPlane {
+				d.AddTag(ControlPlaneTag)
+			}
+			if n.Ready {
+				d.FirstSeen, d.LastSeen = now, now
+			}
+			devices = append(devices, d)
+		}
+	}
+	return devices
+}
+
+// get fetches path from the API server and decodes the JSON answer into
+// reply.
+func (c *Client) get(ctx context.Context, path string, reply any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.server+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		// The API server explains itself in a Status object.
+		var status struct {
+			Message string `json:"message"`
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(body, &status) == nil && status.Message != "" {
+			return fmt.Errorf("the API server answered %s: %s", resp.Status, status.Message)
+		}
+		return fmt.Errorf("the API server answered %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(reply); err != nil {
+		return fmt.Errorf("reading the API server's answer to %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package kube
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeCluster answers the nodes of a three node k3s cluster to the token
+// "t0ken", and returns a kubeconfig file for it.
+func fakeCluster(t *testing.T) string {
+	t.Helper()
+	nodes := map[string]any{"items": []map[string]any{
+		{
+			"metadata": map[string]any{"name": "pi-1", "labels": map[string]string{"node-role.kubernetes.io/control-plane": "true"}},
+			"spec":     map[string]any{"podCIDR": "10.42.0.0/24", "podCIDRs": []string{"10.42.0.0/24"}},
+			"status": map[string]any{
+				"addresses":  []map[string]string{{"type": "InternalIP", "address": "192.168.1.31"}, {"type": "Hostname", "address": "pi-1"}},
+				"conditions": []map[string]string{{"type": "Ready", "status": "True"}},
+				"nodeInfo":   map[string]string{"kubeletVersion": "v1.31.4+k3s1", "osImage": "Debian GNU/Linux 12 (bookworm)"},
+			},
+		},
+		{
+			"metadata": map[string]any{"name": "pi-2"},
+			"spec":     map[string]any{"podCIDR": "10.42.1.0/24"},
+			"status": map[string]any{
+				"addresses":  []map[string]string{{"type": "InternalIP", "address": "192.168.1.32"}},
+				"conditions": []map[string]string{{"type": "Ready", "status": "Unknown"}},
+			},
+		},
+		{
+			"metadata": map[string]any{"name": "cloud"},
+			"status": map[string]any{
+				"addresses": []map[string]string{{"type": "InternalIP", "address": "10.8.0.2"}},
+			},
+		},
+	}}
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]any{"kind": "Status", "message": "Unauthorized"})
+			return
+		}
+		if r.URL.Path != "/api/v1/nodes" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(nodes)
+	}))
+	t.Cleanup(srv.Close)
+
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte("t0ken\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config := `apiVersion: v1
+kind: Config
+current-context: homelab
+contexts:
+- name: homelab
+  context: {cluster: k3s, user: admin}
+- name: cloud
+  context: {cluster: k3s, user: sso}
+clusters:
+- name: k3s
+  cluster:
+    server: ` + srv.URL + `
+    certificate-authority-data: ` + base64.StdEncoding.EncodeToString(ca) + `
+users:
+- name: admin
+  user:
+    tokenFile: token
+- name: sso
+  user:
+    exec:
+      command: kubelogin
+`
+	path := filepath.Join(dir, "config")
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNodes(t *testing.T) {
+	c, err := New(fakeCluster(t), "")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	nodes, err := c.Nodes(context.Background())
+	if err != nil {
+		t.Fatalf("Nodes: %v", err)
+	}
+	if len(nodes) != 3 || nodes[0].Name != "cloud" || nodes[1].Name != "pi-1" {
+		t.Fatalf("nodes = %+v", nodes)
+	}
+	pi1, pi2 := nodes[1], nodes[2]
+	if !pi1.ControlPlane || !pi1.Ready || pi1.InternalIP != "192.168.1.31" || pi1.Kubelet != "v1.31.4+k3s1" || strings.Join(pi1.PodCIDRs, ",") != "10.42.0.0/24" {
+		t.Errorf("pi-1 = %+v", pi1)
+	}
+	if pi2.ControlPlane || pi2.Ready || strings.Join(pi2.PodCIDRs, ",") != "10.42.1.0/24" {
+		t.Errorf("pi-2 = %+v", pi2)
+	}
+}
+
+func TestDevicesInANetwork(t *testing.T) {
+	c, err := New(fakeCluster(t), "homelab")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	_, network, _ := net.ParseCIDR("192.168.1.0/24")
+	devices, err := c.Devices(context.Background(), network)
+	if err != nil {
+		t.Fatalf("Devices: %v", err)
+	}
+	if len(devices) != 2 {
+		t.Fatalf("devices = %+v", devices)
+	}
+	if d := devices[0]; d.Hostname != "pi-1" || !d.HasTag(NodeTag) || !d.HasTag(ControlPlaneTag) || !d.LastSeen.Equal(now) {
+		t.Errorf("pi-1 = %+v", d)
+	}
+	// A node that is not ready only names what the scan found.
+	if d := devices[1]; d.Hostname != "pi-2" || d.HasTag(ControlPlaneTag) || !d.LastSeen.IsZero() {
+		t.Errorf("pi-2 = %+v", d)
+	}
+}
+
+func TestNewRejectsPlugins(t *testing.T) {
+	_, err := New(fakeCluster(t), "cloud")
+	if err == nil || !strings.Contains(err.Error(), "plugin") {
+		t.Errorf("New = %v, want an error about the plugin", err)
+	}
+	if _, err := New(fakeCluster(t), "nowhere"); err == nil {
+		t.Error("a missing context was accepted")
+	}
+}
@@ -0,0 +1,185 @@
+package ldap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The BER tags LDAP uses, with their class and constructed bits.
+const (
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagEnumerated  = 0x0a
+	tagSequence    = 0x30
+	tagSet         = 0x31
+
+	tagBindRequest     = 0x60
+	tagBindResponse    = 0x61
+	tagUnbindRequest   = 0x42
+	tagSearchRequest   = 0x63
+	tagSearchEntry     = 0x64
+	tagSearchDone      = 0x65
+	tagSearchReference = 0x73
+	tagExtendedRequest = 0x77
+	tagExtendedReply   = 0x78
+
+	// tagSimpleAuth is the password of a simple bind, and tagRequestName
+	// the name of an extended operation.
+	tagSimpleAuth  = 0x80
+	tagRequestName = 0x80
+	// tagEqualityMatch is the filter attribute=value.
+	tagEqualityMatch = 0xa3
+)
+
+// maxElementBytes bounds a single element read from the server, so that a
+// server sending nonsense cannot have it allocate without end.
+const maxElementBytes = 1 << 20
+
+// element is one BER element: a tag and its contents, which for a
+// constructed element are the elements it holds.
+type element struct {
+	tag      byte
+	value    []byte
+	children []element
+}
+
+// constructed reports whether tag holds other elements.
+func constructed(tag byte) bool { return tag&0x20 != 0 }
+
+// encode returns the bytes of an element tagged tag holding value.
+func encode(tag byte, value []byte) []byte {
+	out := []byte{tag}
+	switch n := len(value); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n <= 0xff:
+		out = append(out, 0x81, byte(n))
+	case n <= 0xffff:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	default:
+		out = append(out, 0x83, byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(out, value...)
+}
+
+// sequence returns an element tagged tag holding parts, one after another.
+func sequence(tag byte, parts ...[]byte) []byte {
+	var value []byte
+	for _, p := range parts {
+		value = append(value, p...)
+	}
+	return encode(tag, value)
+}
+
+// integer returns an element tagged tag holding n, which is not negative.
+func integer(tag byte, n int) []byte {
+	var value []byte
+	for {
+		value = append([]byte{byte(n)}, value...)
+		n >>= 8
+		if n == 0 {
+			break
+		}
+	}
+	if value[0]&0x80 != 0 {
+		value = append([]byte{0}, value...)
+	}
+	return encode(tag, value)
+}
+
+func octetString(s string) []byte { return encode(tagOctetString, []byte(s)) }
+
+func boolean(b bool) []byte {
+	if b {
+		return encode(tagBoolean, []byte{0xff})
+	}
+	return encode(tagBoolean, []byte{0})
+}
+
+// readElement reads one element from r.
+func readElement(r *bufio.Reader) (element, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return element{}, err
+	}
+	first, err := r.ReadByte()
+	if err != nil {
+		return element{}, err
+	}
+	length := int(first)
+	if first&0x80 != 0 {
+		n := int(first & 0x7f)
+		if n == 0 || n > 3 {
+			return element{}, fmt.Errorf("unsupported BER length of %d bytes", n)
+		}
+		length = 0
+		for range n {
+			b, err := r.ReadByte()
+			if err != nil {
+				return element{}, err
+			}
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxElementBytes {
+		return element{}, fmt.Errorf("BER element of %d bytes is too long", length)
+	}
+	value := make([]byte, length)
+	if _, err := io.ReadFull(r, value); err != nil {
+		return element{}, err
+	}
+	return parseElement(tag, value)
+}
+
+// parseElement returns the element tagged tag with contents value.
+func parseElement(tag byte, value []byte) (element, error) {
+	e := element{tag: tag, value: value}
+	if !constructed(tag) {
+		return e, nil
+	}
+	for rest := value; len(rest) > 0; {
+		child, n, err := splitElement(rest)
+		if err != nil {
+			return element{}, err
+		}
+		e.children = append(e.children, child)
+		rest = rest[n:]
+	}
+	return e, nil
+}
+
+// splitElement returns the first element of b and how many bytes it took.
+func splitElement(b []byte) (element, int, error) {
+	if len(b) < 2 {
+		return element{}, 0, errors.New("truncated BER element")
+	}
+	tag, length, header := b[0], int(b[1]), 2
+	if b[1]&0x80 != 0 {
+		n := int(b[1] & 0x7f)
+		if n == 0 || n > 3 || len(b) < 2+n {
+			return element{}, 0, errors.New("bad BER length")
+		}
+		length = 0
+		for _, c := range b[2 : 2+n] {
+			length = length<<8 | int(c)
+		}
+		header += n
+	}
+	if len(b) < header+length {
+		return element{}, 0, errors.New("truncated BER element")
+	}
+	e, err := parseElement(tag, b[header:header+length])
+	return e, header + length, err
+}
+
+// int returns the value of an integer or enumerated element.
+func (e element) int() int {
+	n := 0
+	for _, b := range e.value {
+		n = n<<8 | int(b)
+	}
+	return n
+}
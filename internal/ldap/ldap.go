@@ -0,0 +1,421 @@
+// Package ldap checks usernames and passwords with an LDAP directory, such
+// as OpenLDAP, lldap, FreeIPA or Active Directory, and reads the groups each
+// user is in, so that the web UI can sign them in with the accounts they
+// already have.
+//
+// It binds as a service account, searches for the user by name, and binds
+// again as that user with the password given. The groups come from the
+// user's memberOf attribute or, for a directory without one, from searching
+// for the groups that list the user as a member. Only as much of the
+// protocol as that needs is spoken.
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// requestTimeout bounds a login when the caller sets no deadline, so that a
+// directory that has gone away does not leave the login form hanging.
+const requestTimeout = 10 * time.Second
+
+// Defaults for the attributes users are found and grouped by.
+const (
+	DefaultUserAttribute  = "uid"
+	DefaultGroupAttribute = "memberOf"
+)
+
+// Result codes the directory answers with.
+const (
+	resultSuccess            = 0
+	resultSizeLimitExceeded  = 4
+	resultInvalidCredentials = 49
+)
+
+// startTLSName is the name of the extended operation that turns on TLS.
+const startTLSName = "1.3.6.1.4.1.1466.20037"
+
+// Options say which directory to ask and how.
+type Options struct {
+	// URL is the directory's address, ldap://host[:port] or
+	// ldaps://host[:port].
+	URL string
+	// BindDN and BindPassword are the service account users are searched
+	// for as. An empty BindDN searches anonymously.
+	BindDN       string
+	BindPassword string
+	// BaseDN is where users are searched for, such as
+	// ou=people,dc=example,dc=com.
+	BaseDN string
+	// UserAttribute holds the username: uid, or sAMAccountName on Active
+	// Directory.
+	UserAttribute string
+	// GroupAttribute is the attribute of a user naming their groups.
+	GroupAttribute string
+	// GroupBaseDN, when set, is searched for groups with the user as a
+	// member, for directories that do not keep memberOf.
+	GroupBaseDN string
+	// StartTLS upgrades an ldap:// connection to TLS before binding.
+	StartTLS bool
+	// TLSVerify checks the directory's certificate. CAFile names a PEM file
+	// of certificates to trust for it, as well as the system's.
+	TLSVerify bool
+	CAFile    string
+}
+
+// Directory checks logins with an LDAP directory.
+type Directory struct {
+	opts Options
+	tls  *tls.Config
+}
+
+// New returns a directory for opts. It fails when CAFile cannot be read.
+func New(opts Options) (*Directory, error) {
+	if err := ValidURL(opts.URL); err != nil {
+		return nil, err
+	}
+	if opts.UserAttribute == "" {
+		opts.UserAttribute = DefaultUserAttribute
+	}
+	if opts.GroupAttribute == "" {
+		opts.GroupAttribute = DefaultGroupAttribute
+	}
+	u, _ := url.Parse(opts.URL)
+	config := &tls.Config{ServerName: u.Hostname(), InsecureSkipVerify: !opts.TLSVerify}
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("ca_file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_file: %s holds no PEM certificates", opts.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	return &Directory{opts: opts, tls: config}, nil
+}
+
+// ValidURL checks that u is an ldap or ldaps URL with a host.
+func ValidURL(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "ldap" && parsed.Scheme != "ldaps" {
+		return fmt.Errorf("%q is not an ldap:// or ldaps:// URL", u)
+	}
+	if parsed.Hostname() == "" {
+		return fmt.Errorf("%q has no host", u)
+	}
+	return nil
+}
+
+// Authenticate checks username and password with the directory and returns
+// the groups the user is in. A wrong username or password gives ok false
+// and no error.
+func (d *Directory) Authenticate(ctx context.Context, username, password string) (groups []string, ok bool, err error) {
+	// An empty password would be an unauthenticated bind, which directories
+	// answer with success.
+	if username == "" || password == "" {
+		return nil, false, nil
+	}
+	if _, has := ctx.Deadline(); !has {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+		defer cancel()
+	}
+
+	c, err := d.dial(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer c.close()
+
+	if err := c.bindService(d.opts); err != nil {
+		return nil, false, err
+	}
+	users, err := c.search(d.opts.BaseDN, d.opts.UserAttribute, username, 2, d.opts.GroupAttribute)
+	if err != nil {
+		return nil, false, fmt.Errorf("search for the user: %w", err)
+	}
+	switch len(users) {
+	case 0:
+		return nil, false, nil
+	case 1:
+	default:
+		return nil, false, fmt.Errorf("more than one entry under %s has %s=%s", d.opts.BaseDN, d.opts.UserAttribute, username)
+	}
+	user := users[0]
+
+	code, message, err := c.bind(user.dn, password)
+	switch {
+	case err != nil:
+		return nil, false, err
+	case code == resultInvalidCredentials:
+		return nil, false, nil
+	case code != resultSuccess:
+		return nil, false, &resultError{op: "bind as " + user.dn, code: code, message: message}
+	}
+
+	groups = user.attrs[strings.ToLower(d.opts.GroupAttribute)]
+	if d.opts.GroupBaseDN != "" {
+		// The user may not be allowed to read the groups; the service
+		// account is.
+		if err := c.bindService(d.opts); err != nil {
+			return nil, false, err
+		}
+		found, err := c.search(d.opts.GroupBaseDN, "member", user.dn, 0)
+		if err != nil {
+			return nil, false, fmt.Errorf("search for groups: %w", err)
+		}
+		for _, g := range found {
+			groups = append(groups, g.dn)
+		}
+	}
+	return groups, true, nil
+}
+
+// resultError is a request the directory refused.
+type resultError struct {
+	op      string
+	code    int
+	message string
+}
+
+func (e *resultError) Error() string {
+	if e.message == "" {
+		return fmt.Sprintf("%s: LDAP result %d", e.op, e.code)
+	}
+	return fmt.Sprintf("%s: LDAP result %d: %s", e.op, e.code, e.message)
+}
+
+// entry is an entry a search found, with its attributes keyed in lower
+// case.
+type entry struct {
+	dn    string
+	attrs map[string][]string
+}
+
+// conn is a connection to the directory.
+type conn struct {
+	c  net.Conn
+	r  *bufio.Reader
+	id int
+}
+
+// dial connects to the directory, over TLS for ldaps:// or with StartTLS.
+func (d *Directory) dial(ctx context.Context) (*conn, error) {
+	u, _ := url.Parse(d.opts.URL)
+	port := u.Port()
+	if port == "" {
+		port = "389"
+		if u.Scheme == "ldaps" {
+			port = "636"
+		}
+	}
+	addr := net.JoinHostPort(u.Hostname(), port)
+
+	var dialer net.Dialer
+	nc, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		nc.SetDeadline(deadline)
+	}
+	if u.Scheme == "ldaps" {
+		nc = tls.Client(nc, d.tls)
+	}
+	c := &conn{c: nc, r: bufio.NewReader(nc)}
+
+	if u.Scheme == "ldap" && d.opts.StartTLS {
+		reply, err := c.roundTrip(sequence(tagExtendedRequest, encode(tagRequestName, []byte(startTLSName))), tagExtendedReply)
+		if err == nil {
+			err = resultOf("StartTLS", reply)
+		}
+		if err != nil {
+			nc.Close()
+			return nil, err
+		}
+		nc = tls.Client(nc, d.tls)
+		c.c, c.r = nc, bufio.NewReader(nc)
+	}
+	return c, nil
+}
+
+// close says goodbye and closes the connection.
+func (c *conn) close() {
+	c.id++
+	c.c.Write(sequence(tagSequence, integer(tagInteger, c.id), encode(tagUnbindRequest, nil)))
+	c.c.Close()
+}
+
+// send writes a request holding op and returns its message ID.
+func (c *conn) send(op []byte) (int, error) {
+	c.id++
+	_, err := c.c.Write(sequence(tagSequence, integer(tagInteger, c.id), op))
+	return c.id, err
+}
+
+// receive reads the next reply to request id and returns its operation.
+func (c *conn) receive(id int) (element, error) {
+	for {
+		msg, err := readElement(c.r)
+		if err != nil {
+			return element{}, err
+		}
+		if msg.tag != tagSequence || len(msg.children) < 2 {
+			return element{}, errors.New("malformed LDAP message")
+		}
+		// Message ID 0 is an unsolicited notice, such as the server
+		// disconnecting.
+		switch got := msg.children[0].int(); got {
+		case id:
+			return msg.children[1], nil
+		case 0:
+			return element{}, errors.New("the directory closed the connection")
+		}
+	}
+}
+
+// roundTrip sends op and returns the reply, which must be tagged want.
+func (c *conn) roundTrip(op []byte, want byte) (element, error) {
+	id, err := c.send(op)
+	if err != nil {
+		return element{}, err
+	}
+	reply, err := c.receive(id)
+	if err != nil {
+		return element{}, err
+	}
+	if reply.tag != want {
+		return element{}, fmt.Errorf("unexpected LDAP reply 0x%02x", reply.tag)
+	}
+	return reply, nil
+}
+
+// bind signs in as dn with password and returns the directory's result.
+func (c *conn) bind(dn, password string) (code int, message string, err error) {
+	reply, err := c.roundTrip(sequence(tagBindRequest,
+		integer(tagInteger, 3),
+		octetString(dn),
+		encode(tagSimpleAuth, []byte(password)),
+	), tagBindResponse)
+	if err != nil {
+		return 0, "", err
+	}
+	code, message = result(reply)
+	return code, message, nil
+}
+
+// bindService signs in as the service account of opts, if there is one.
+func (c *conn) bindService(opts Options) error {
+	if opts.BindDN == "" {
+		return nil
+	}
+	code, message, err := c.bind(opts.BindDN, opts.BindPassword)
+	if err != nil {
+		return err
+	}
+	if code != resultSuccess {
+		return &resultError{op: "bind as " + opts.BindDN, code: code, message: message}
+	}
+	return nil
+}
+
+// search returns the entries under base with attr equal to value, and
+// their attributes attrs. limit bounds how many are returned; 0 is no limit.
+func (c *conn) search(base, attr, value string, limit int, attrs ...string) ([]entry, error) {
+	var wanted [][]byte
+	for _, a := range attrs {
+		wanted = append(wanted, octetString(a))
+	}
+	if len(wanted) == 0 {
+		// No attributes at all, only the DN.
+		wanted = append(wanted, octetString("1.1"))
+	}
+	id, err := c.send(sequence(tagSearchRequest,
+		octetString(base),
+		integer(tagEnumerated, 2), // the whole subtree
+		integer(tagEnumerated, 0), // never dereference aliases
+		integer(tagInteger, limit),
+		integer(tagInteger, int(requestTimeout/time.Second)),
+		boolean(false),
+		sequence(tagEqualityMatch, octetString(attr), octetString(value)),
+		sequence(tagSequence, wanted...),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []entry
+	for {
+		reply, err := c.receive(id)
+		if err != nil {
+			return nil, err
+		}
+		switch reply.tag {
+		case tagSearchEntry:
+			if e, ok := parseEntry(reply); ok {
+				entries = append(entries, e)
+			}
+		case tagSearchReference:
+			// Referrals to other directories are not followed.
+		case tagSearchDone:
+			if code, _ := result(reply); code == resultSizeLimitExceeded {
+				return entries, nil
+			}
+			return entries, resultOf("search", reply)
+		default:
+			return nil, fmt.Errorf("unexpected LDAP reply 0x%02x", reply.tag)
+		}
+	}
+}
+
+// parseEntry reads a search result entry.
+func parseEntry(reply element) (entry, bool) {
+	if len(reply.children) < 2 {
+		return entry{}, false
+	}
+	e := entry{dn: string(reply.children[0].value), attrs: map[string][]string{}}
+	for _, attr := range reply.children[1].children {
+		if len(attr.children) < 2 {
+			continue
+		}
+		name := strings.ToLower(string(attr.children[0].value))
+		for _, v := range attr.children[1].children {
+			e.attrs[name] = append(e.attrs[name], string(v.value))
+		}
+	}
+	return e, true
+}
+
+// result returns the result code and diagnostic message of an LDAPResult.
+func result(reply element) (code int, message string) {
+	if len(reply.children) < 3 {
+		return -1, "malformed result"
+	}
+	return reply.children[0].int(), string(reply.children[2].value)
+}
+
+// resultOf returns an error for a result that is not success.
+func resultOf(op string, reply element) error {
+	code, message := result(reply)
+	if code == resultSuccess {
+		return nil
+	}
+	return &resultError{op: op, code: code, message: message}
+}
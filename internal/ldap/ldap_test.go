@@ -0,0 +1,187 @@
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeUser is an entry of fakeDirectory.
+type fakeUser struct {
+	dn, uid, password string
+	memberOf          []string
+}
+
+const serviceDN, servicePassword = "cn=orangutan,dc=example,dc=com", "service-secret"
+
+// fakeDirectory answers binds and equality searches like an LDAP server
+// holding users and groups, each group a DN and the DNs of its members.
+func fakeDirectory(t *testing.T, users []fakeUser, groups map[string][]string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFake(nc, users, groups)
+		}
+	}()
+	return "ldap://" + ln.Addr().String()
+}
+
+func serveFake(nc net.Conn, users []fakeUser, groups map[string][]string) {
+	defer nc.Close()
+	r := bufio.NewReader(nc)
+	reply := func(id int, op []byte) {
+		nc.Write(sequence(tagSequence, integer(tagInteger, id), op))
+	}
+	ldapResult := func(tag byte, code int) []byte {
+		return sequence(tag, integer(tagEnumerated, code), octetString(""), octetString(""))
+	}
+	bound := ""
+	for {
+		msg, err := readElement(r)
+		if err != nil {
+			return
+		}
+		id, op := msg.children[0].int(), msg.children[1]
+		switch op.tag {
+		case tagBindRequest:
+			dn, password := string(op.children[1].value), string(op.children[2].value)
+			code := resultInvalidCredentials
+			if dn == serviceDN && password == servicePassword {
+				code = resultSuccess
+			}
+			for _, u := range users {
+				if u.dn == dn && u.password == password {
+					code = resultSuccess
+				}
+			}
+			if code == resultSuccess {
+				bound = dn
+			}
+			reply(id, ldapResult(tagBindResponse, code))
+		case tagSearchRequest:
+			if bound == "" {
+				reply(id, ldapResult(tagSearchDone, 50))
+				continue
+			}
+			base := string(op.children[0].value)
+			filter := op.children[6]
+			attr, value := string(filter.children[0].value), string(filter.children[1].value)
+			switch {
+			case attr == "uid":
+				for _, u := range users {
+					if u.uid != value || !strings.HasSuffix(u.dn, base) {
+						continue
+					}
+					var vals [][]byte
+					for _, g := range u.memberOf {
+						vals = append(vals, octetString(g))
+					}
+					reply(id, sequence(tagSearchEntry, octetString(u.dn), sequence(tagSequence,
+						sequence(tagSequence, octetString("memberOf"), sequence(tagSet, vals...)))))
+				}
+			case attr == "member" && bound == serviceDN:
+				for dn, members := range groups {
+					if slices.Contains(members, value) {
+						reply(id, sequence(tagSearchEntry, octetString(dn), sequence(tagSequence)))
+					}
+				}
+			}
+			reply(id, ldapResult(tagSearchDone, resultSuccess))
+		case tagUnbindRequest:
+			return
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	addr := fakeDirectory(t, []fakeUser{
+		{dn: "uid=alice,ou=people,dc=example,dc=com", uid: "alice", password: "wonderland",
+			memberOf: []string{"cn=admins,ou=groups,dc=example,dc=com"}},
+		{dn: "uid=bob,ou=people,dc=example,dc=com", uid: "bob", password: "builder"},
+	}, map[string][]string{
+		"cn=staff,ou=groups,dc=example,dc=com": {"uid=bob,ou=people,dc=example,dc=com"},
+	})
+	d, err := New(Options{
+		URL: addr, BindDN: serviceDN, BindPassword: servicePassword,
+		BaseDN: "ou=people,dc=example,dc=com", GroupBaseDN: "ou=groups,dc=example,dc=com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		username, password string
+		wantOK             bool
+		wantGroups         []string
+	}{
+		{"alice", "wonderland", true, []string{"cn=admins,ou=groups,dc=example,dc=com"}},
+		{"bob", "builder", true, []string{"cn=staff,ou=groups,dc=example,dc=com"}},
+		{"alice", "wrong", false, nil},
+		{"alice", "", false, nil},
+		{"carol", "anything", false, nil},
+	} {
+		groups, ok, err := d.Authenticate(context.Background(), tt.username, tt.password)
+		if err != nil {
+			t.Errorf("Authenticate(%q) error: %v", tt.username, err)
+			continue
+		}
+		if ok != tt.wantOK || !slices.Equal(groups, tt.wantGroups) {
+			t.Errorf("Authenticate(%q, %q) = %v, %v; want %v, %v", tt.username, tt.password, groups, ok, tt.wantGroups, tt.wantOK)
+		}
+	}
+}
+
+func TestAuthenticateServiceAccountRefused(t *testing.T) {
+	addr := fakeDirectory(t, []fakeUser{{dn: "uid=alice,dc=example,dc=com", uid: "alice", password: "wonderland"}}, nil)
+	d, err := New(Options{URL: addr, BindDN: serviceDN, BindPassword: "stale", BaseDN: "dc=example,dc=com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A directory that cannot be searched is an error, not a wrong password.
+	_, ok, err := d.Authenticate(context.Background(), "alice", "wonderland")
+	if err == nil || ok {
+		t.Errorf("Authenticate = %v, %v; want an error", ok, err)
+	}
+}
+
+func TestValidURL(t *testing.T) {
+	for u, valid := range map[string]bool{
+		"ldap://dc.example.com":       true,
+		"ldaps://dc.example.com:3269": true,
+		"http://dc.example.com":       false,
+		"ldap://":                     false,
+	} {
+		if err := ValidURL(u); (err == nil) != valid {
+			t.Errorf("ValidURL(%q) = %v; want valid %v", u, err, valid)
+		}
+	}
+}
+
+func TestBERLengths(t *testing.T) {
+	for _, n := range []int{0, 127, 128, 255, 256, 70000} {
+		b := encode(tagOctetString, make([]byte, n))
+		e, used, err := splitElement(b)
+		if err != nil || used != len(b) || len(e.value) != n {
+			t.Errorf("length %d: got %d bytes of %d, %v", n, len(e.value), used, err)
+		}
+	}
+	for _, n := range []int{0, 1, 127, 128, 255, 256, 65536} {
+		e, _, err := splitElement(integer(tagInteger, n))
+		if err != nil || e.int() != n {
+			t.Errorf("integer %d read back as %d, %v", n, e.int(), err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Package logging builds the logger the rest of the program writes its
+// diagnostics to.
+//
+// Packages log through log/slog's default logger, which the command line
+// replaces with one from New according to --verbose, --quiet and
+// --log-format. Logs go to standard error, apart from the command output on
+// standard output, so a scan piped into another program stays clean while its
+// warnings still reach the terminal.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// Formats New accepts.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Level returns the level --verbose and --quiet ask for: debug messages when
+// verbose, errors only when quiet, and otherwise information and warnings.
+func Level(verbose, quiet bool) slog.Level {
+	switch {
+	case verbose:
+		return slog.LevelDebug
+	case quiet:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// New returns a logger writing records of at least level to w, as text or as
+// one JSON object per line.
+//
+// Text leaves out the time. It is read by someone watching a command run, or
+// by journald, which stamps each line itself; JSON keeps it for log
+// collectors that do not.
+func New(w io.Writer, level slog.Level, format string) (*slog.Logger, error) {
+	switch format {
+	case FormatText, "":
+		return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
+			Level: level,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.TimeKey && len(groups) == 0 {
+					return slog.Attr{}
+				}
+				return a
+			},
+		})), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q (use %s or %s)", format, FormatText, FormatJSON)
+}
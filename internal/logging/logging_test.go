@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLevel(t *testing.T) {
+	for _, tt := range []struct {
+		verbose, quiet bool
+		want           slog.Level
+	}{
+		{false, false, slog.LevelInfo},
+		{true, false, slog.LevelDebug},
+		{false, true, slog.LevelError},
+	} {
+		if got := Level(tt.verbose, tt.quiet); got != tt.want {
+			t.Errorf("Level(%v, %v) = %v, want %v", tt.verbose, tt.quiet, got, tt.want)
+		}
+	}
+}
+
+func TestTextLeavesOutTime(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, slog.LevelInfo, FormatText)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Warn("nmap failed", "network", "192.168.1.0/24")
+
+	got := buf.String()
+	if strings.Contains(got, "time=") {
+		t.Errorf("text log has a time: %q", got)
+	}
+	if !strings.Contains(got, `level=WARN msg="nmap failed" network=192.168.1.0/24`) {
+		t.Errorf("text log = %q", got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, slog.LevelInfo, FormatJSON)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Error("scan failed", "network", "10.0.0.0/24")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("log line is not JSON: %v: %q", err, buf.String())
+	}
+	if record["msg"] != "scan failed" || record["network"] != "10.0.0.0/24" || record["time"] == nil {
+		t.Errorf("JSON record = %v", record)
+	}
+}
+
+func TestLevelFiltersRecords(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, slog.LevelError, FormatText)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Warn("hidden")
+	logger.Debug("hidden")
+	if buf.Len() != 0 {
+		t.Errorf("quiet logger wrote %q", buf.String())
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, slog.LevelInfo, "xml"); err == nil {
+		t.Error("New accepted the format xml")
+	}
+}
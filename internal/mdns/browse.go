@@ -0,0 +1,98 @@
+package mdns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// servicesName is the name DNS service discovery lists every service type
+// advertised on the network under.
+const servicesName = "_services._dns-sd._udp.local."
+
+// Browse asks the network which services are advertised on it, and returns
+// the service types each address answered with, such as "_ipp._tcp", by
+// address and sorted. It listens for answers for wait, or until ctx is done.
+//
+// The question is a one-shot query from a port of its own, which responders
+// answer directly, so that nothing need listen on port 5353 beside a
+// responder already there. It asks twice, as a question sent once over UDP
+// may be lost.
+func Browse(ctx context.Context, wait time.Duration) (map[string][]string, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.SetReadDeadline(time.Now()) })
+	defer stop()
+	if err := conn.SetReadDeadline(time.Now().Add(wait)); err != nil {
+		return nil, err
+	}
+
+	query := (&message{questions: []question{{name: servicesName, typ: typePTR}}}).pack()
+	if _, err := conn.WriteToUDP(query, group); err != nil {
+		return nil, err
+	}
+	again := time.AfterFunc(wait/2, func() { conn.WriteToUDP(query, group) })
+	defer again.Stop()
+
+	found := make(map[string]map[string]bool)
+	buf := make([]byte, 9000)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				break
+			}
+			return nil, err
+		}
+		for _, typ := range serviceTypes(buf[:n]) {
+			ip := src.IP.String()
+			if found[ip] == nil {
+				found[ip] = make(map[string]bool)
+			}
+			found[ip][typ] = true
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]string, len(found))
+	for ip, seen := range found {
+		for typ := range seen {
+			result[ip] = append(result[ip], typ)
+		}
+		sort.Strings(result[ip])
+	}
+	return result, nil
+}
+
+// serviceTypes returns the service types the answer b lists, such as
+// "_ipp._tcp", in lower case and without .local.
+func serviceTypes(b []byte) []string {
+	m, err := parse(b)
+	if err != nil || !m.response {
+		return nil
+	}
+	var result []string
+	for _, rr := range append(m.answers, m.additional...) {
+		if rr.typ != typePTR || !strings.EqualFold(rr.name, servicesName) {
+			continue
+		}
+		name, _, err := readName(b, rr.at)
+		if err != nil {
+			continue
+		}
+		name = strings.ToLower(name)
+		if strings.HasSuffix(name, ".local.") {
+			result = append(result, strings.TrimSuffix(name, ".local."))
+		}
+	}
+	return result
+}
@@ -0,0 +1,408 @@
+// Package mdns advertises the web server on the local network with
+// multicast DNS and DNS service discovery, so that it can be opened as
+// http://orangutan.local:291 and is listed by browsers of services, such as
+// Safari's Bonjour bookmarks or avahi-browse, without anyone knowing its
+// address.
+//
+// It answers for one host name and one service, and only over IPv4. Each
+// interface gets a socket of its own and answers the queries from its own
+// networks with its own addresses, so that a machine on two networks is
+// reached at the right address from each.
+//
+// Browse asks the other way round, which services the devices on the
+// network advertise.
+package mdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// group is where multicast DNS queries and answers go.
+var group = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// TTLs of the records: two minutes for those naming an address, which may
+// change, and the 75 minutes RFC 6762 suggests for the rest.
+const (
+	hostTTL    = 120
+	serviceTTL = 4500
+)
+
+// maxLabel is the longest a label of a name may be.
+const maxLabel = 63
+
+// Service is what is advertised.
+type Service struct {
+	// Host is the name the machine answers to, without .local.
+	Host string
+	// Instance is the name browsers list the service under, such as
+	// "LAN Orangutan on nas".
+	Instance string
+	// Type is the service type, such as _http._tcp.
+	Type string
+	Port int
+	// TXT holds the key=value strings of the service's TXT record.
+	TXT []string
+}
+
+// ValidHost checks that host can be the name the machine answers to: one
+// label of letters, digits and hyphens.
+func ValidHost(host string) error {
+	if host == "" || len(host) > maxLabel {
+		return fmt.Errorf("%q must be 1 to %d characters", host, maxLabel)
+	}
+	if strings.HasPrefix(host, "-") || strings.HasSuffix(host, "-") {
+		return fmt.Errorf("%q must not start or end with a hyphen", host)
+	}
+	for _, r := range host {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+			return fmt.Errorf("%q may only hold letters, digits and hyphens", host)
+		}
+	}
+	return nil
+}
+
+// Responder answers for a service until its context is done.
+type Responder struct {
+	svc     Service
+	conns   []*ifaceConn
+	packets chan packet
+}
+
+// ifaceConn is the socket of one interface and the networks on it.
+type ifaceConn struct {
+	conn *net.UDPConn
+	nets []*net.IPNet
+}
+
+// packet is a message received on one of the sockets.
+type packet struct {
+	c    *ifaceConn
+	data []byte
+	src  *net.UDPAddr
+}
+
+// Advertise starts answering for svc on every interface that can multicast,
+// and goes on until ctx is done, when it says goodbye so that browsers drop
+// the service at once. Before answering it checks that nothing else on the
+// network has the host name, and takes the next free one of host-2, host-3
+// and so on if something does. It returns the host name taken.
+func Advertise(ctx context.Context, svc Service) (string, error) {
+	// Labels are split at dots, and an instance name is one label.
+	svc.Instance = truncate(strings.ReplaceAll(svc.Instance, ".", " "))
+	svc.Host = truncate(svc.Host)
+
+	r := &Responder{svc: svc, packets: make(chan packet, 16)}
+	if err := r.listen(); err != nil {
+		return "", err
+	}
+	for _, c := range r.conns {
+		go r.read(c)
+	}
+
+	host := svc.Host
+	for n := 2; r.taken(ctx, host); n++ {
+		if n > 10 {
+			r.close()
+			return "", fmt.Errorf("%s.local and the next names after it are taken", svc.Host)
+		}
+		host = fmt.Sprintf("%s-%d", svc.Host, n)
+	}
+	if ctx.Err() != nil {
+		r.close()
+		return "", ctx.Err()
+	}
+	r.svc.Host = host
+
+	go r.serve(ctx)
+	return host, nil
+}
+
+// listen opens a socket on every interface that is up, can multicast and
+// has an IPv4 address.
+func (r *Responder) listen() error {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i := range ifaces {
+		iface := ifaces[i]
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagMulticast == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		var nets []*net.IPNet
+		for _, a := range addrs {
+			if n, ok := a.(*net.IPNet); ok {
+				nets = append(nets, n)
+			}
+		}
+		if !hasIPv4(nets) {
+			continue
+		}
+		conn, err := net.ListenMulticastUDP("udp4", &iface, group)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", iface.Name, err))
+			continue
+		}
+		r.conns = append(r.conns, &ifaceConn{conn: conn, nets: nets})
+	}
+	if len(r.conns) == 0 {
+		if len(errs) > 0 {
+			return errors.Join(errs...)
+		}
+		return errors.New("no network interface can multicast")
+	}
+	return nil
+}
+
+func hasIPv4(nets []*net.IPNet) bool {
+	for _, n := range nets {
+		if n.IP.To4() != nil && !n.IP.IsLoopback() {
+			return true
+		}
+	}
+	return false
+}
+
+// read passes the messages arriving on c to r.packets until c is closed.
+func (r *Responder) read(c *ifaceConn) {
+	buf := make([]byte, 9000)
+	for {
+		n, src, err := c.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		data := make([]byte, n)
+		copy(data, buf[:n])
+		select {
+		case r.packets <- packet{c: c, data: data, src: src}:
+		default:
+			// Busy; the asker asks again.
+		}
+	}
+}
+
+func (r *Responder) close() {
+	for _, c := range r.conns {
+		c.conn.Close()
+	}
+}
+
+// taken probes for host, as RFC 6762 asks before using a name: three
+// queries a quarter of a second apart, and the name is taken if anything
+// answers for it.
+func (r *Responder) taken(ctx context.Context, host string) bool {
+	name := host + ".local."
+	for i := 0; i < 3; i++ {
+		for _, c := range r.conns {
+			probe := &message{
+				questions: []question{{name: name, typ: typeANY, unicast: true}},
+				authority: hostRecords(name, c.nets, hostTTL),
+			}
+			c.conn.WriteToUDP(probe.pack(), group)
+		}
+		timer := time.NewTimer(250 * time.Millisecond)
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return false
+			case <-timer.C:
+				break wait
+			case p := <-r.packets:
+				m, err := parse(p.data)
+				if err != nil || !m.response {
+					continue
+				}
+				for _, rr := range m.answers {
+					if strings.EqualFold(rr.name, name) {
+						timer.Stop()
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// serve announces the service, answers queries until ctx is done, and then
+// says goodbye.
+func (r *Responder) serve(ctx context.Context) {
+	defer r.close()
+	r.announce(serviceTTL)
+	// RFC 6762 asks for the announcement to be repeated after a second.
+	again := time.After(time.Second)
+	for {
+		select {
+		case <-ctx.Done():
+			r.announce(0)
+			return
+		case <-again:
+			r.announce(serviceTTL)
+		case p := <-r.packets:
+			r.answer(p)
+		}
+	}
+}
+
+// announce sends every record on every interface, with the TTL ttl for the
+// service records; 0 says goodbye.
+func (r *Responder) announce(ttl uint32) {
+	for _, c := range r.conns {
+		m := &message{response: true}
+		for _, rr := range r.records(c.nets) {
+			if ttl == 0 || rr.ttl > ttl {
+				rr.ttl = ttl
+			}
+			m.answers = append(m.answers, rr)
+		}
+		c.conn.WriteToUDP(m.pack(), group)
+	}
+}
+
+// answer replies to a query arriving on p.c from one of its networks.
+// Queries from other networks are left to the socket of theirs, which hears
+// them too.
+func (r *Responder) answer(p packet) {
+	if !inNets(p.src.IP, p.c.nets) {
+		return
+	}
+	q, err := parse(p.data)
+	if err != nil || q.response {
+		return
+	}
+	reply := r.reply(q, p.c.nets)
+	if reply == nil {
+		return
+	}
+
+	// A one-shot query, from a port other than 5353, is answered directly
+	// and as a unicast DNS answer, as RFC 6762 asks; so is one asking for
+	// a unicast answer. Others go to the group.
+	legacy := p.src.Port != group.Port
+	unicast := legacy
+	for _, question := range q.questions {
+		unicast = unicast || question.unicast
+	}
+	if legacy {
+		reply.id = q.id
+		reply.questions = q.questions
+	}
+	to := group
+	if unicast {
+		to = p.src
+	}
+	p.c.conn.WriteToUDP(reply.pack(), to)
+}
+
+func inNets(ip net.IP, nets []*net.IPNet) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// reply returns the answer to q for the interface with the networks nets,
+// or nil when it asks about nothing this answers for.
+func (r *Responder) reply(q *message, nets []*net.IPNet) *message {
+	records := r.records(nets)
+	m := &message{response: true}
+	seen := make(map[string]bool)
+	add := func(to *[]record, rr record) {
+		key := fmt.Sprintf("%s %d %x", strings.ToLower(rr.name), rr.typ, rr.data)
+		if !seen[key] {
+			seen[key] = true
+			*to = append(*to, rr)
+		}
+	}
+	for _, question := range q.questions {
+		for _, rr := range records {
+			if !strings.EqualFold(rr.name, question.name) || (question.typ != typeANY && question.typ != rr.typ) {
+				continue
+			}
+			add(&m.answers, rr)
+			// What the asker will want next: the service's details and
+			// the host's address.
+			var extra []uint16
+			switch {
+			case rr.typ == typePTR && strings.EqualFold(rr.name, r.svc.Type+".local."):
+				extra = []uint16{typeSRV, typeTXT, typeA}
+			case rr.typ == typeSRV:
+				extra = []uint16{typeA}
+			}
+			for _, typ := range extra {
+				for _, more := range records {
+					if more.typ == typ && (more.typ == typeA || strings.EqualFold(more.name, r.instanceName())) {
+						add(&m.additional, more)
+					}
+				}
+			}
+		}
+	}
+	if len(m.answers) == 0 {
+		return nil
+	}
+	return m
+}
+
+// instanceName is the full name of the service instance.
+func (r *Responder) instanceName() string {
+	return r.svc.Instance + "." + r.svc.Type + ".local."
+}
+
+// records are the records of the host and service on the interface with the
+// networks nets.
+func (r *Responder) records(nets []*net.IPNet) []record {
+	host := r.svc.Host + ".local."
+	typ := r.svc.Type + ".local."
+	instance := r.instanceName()
+	records := hostRecords(host, nets, hostTTL)
+	return append(records,
+		record{name: "_services._dns-sd._udp.local.", typ: typePTR, ttl: serviceTTL, data: encodeName(typ)},
+		record{name: typ, typ: typePTR, ttl: serviceTTL, data: encodeName(instance)},
+		record{name: instance, typ: typeSRV, unique: true, ttl: hostTTL, data: srvData(r.svc.Port, host)},
+		record{name: instance, typ: typeTXT, unique: true, ttl: serviceTTL, data: txtData(r.svc.TXT)},
+	)
+}
+
+// hostRecords are the address records of name for the addresses in nets,
+// leaving out link-local IPv6 addresses, which need a zone to be of use.
+func hostRecords(name string, nets []*net.IPNet, ttl uint32) []record {
+	var records []record
+	for _, n := range nets {
+		switch {
+		case n.IP.IsLoopback():
+		case n.IP.To4() != nil:
+			records = append(records, record{name: name, typ: typeA, unique: true, ttl: ttl, data: n.IP.To4()})
+		case !n.IP.IsLinkLocalUnicast():
+			records = append(records, record{name: name, typ: typeAAAA, unique: true, ttl: ttl, data: n.IP.To16()})
+		}
+	}
+	return records
+}
+
+// truncate shortens s to fit in a label, without splitting a character.
+func truncate(s string) string {
+	for len(s) > maxLabel {
+		_, size := utf8.DecodeLastRuneInString(s)
+		s = s[:len(s)-size]
+	}
+	return s
+}
@@ -0,0 +1,164 @@
+package mdns
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+)
+
+func testResponder() *Responder {
+	return &Responder{svc: Service{
+		Host:     "orangutan",
+		Instance: "LAN Orangutan on nas",
+		Type:     "_http._tcp",
+		Port:     291,
+		TXT:      []string{"path=/"},
+	}}
+}
+
+func testNets() []*net.IPNet {
+	_, v4, _ := net.ParseCIDR("192.168.1.0/24")
+	v4.IP = net.ParseIP("192.168.1.10")
+	_, ula, _ := net.ParseCIDR("fd00::/64")
+	ula.IP = net.ParseIP("fd00::10")
+	_, linkLocal, _ := net.ParseCIDR("fe80::/64")
+	linkLocal.IP = net.ParseIP("fe80::10")
+	return []*net.IPNet{v4, ula, linkLocal}
+}
+
+// ask returns the reply to a query for name and typ, packed and parsed
+// again as a browser would read it.
+func ask(t *testing.T, name string, typ uint16) *message {
+	t.Helper()
+	q, err := parse((&message{questions: []question{{name: name, typ: typ}}}).pack())
+	if err != nil {
+		t.Fatalf("parse query: %v", err)
+	}
+	reply := testResponder().reply(q, testNets())
+	if reply == nil {
+		return nil
+	}
+	m, err := parse(reply.pack())
+	if err != nil {
+		t.Fatalf("parse reply: %v", err)
+	}
+	return m
+}
+
+func TestReplyToHostQuery(t *testing.T) {
+	m := ask(t, "Orangutan.local.", typeA)
+	if m == nil || !m.response || len(m.answers) != 1 {
+		t.Fatalf("reply = %+v", m)
+	}
+	if rr := m.answers[0]; !net.IP(rr.data).Equal(net.ParseIP("192.168.1.10")) || !rr.unique || rr.ttl != hostTTL {
+		t.Errorf("answer = %+v", rr)
+	}
+	// The link-local address needs a zone, so only the ULA is given.
+	if m := ask(t, "orangutan.local.", typeAAAA); m == nil || len(m.answers) != 1 || !net.IP(m.answers[0].data).Equal(net.ParseIP("fd00::10")) {
+		t.Errorf("AAAA reply = %+v", m)
+	}
+	if m := ask(t, "printer.local.", typeA); m != nil {
+		t.Errorf("answered for another name: %+v", m)
+	}
+}
+
+func TestReplyToBrowse(t *testing.T) {
+	m := ask(t, "_http._tcp.local.", typePTR)
+	if m == nil || len(m.answers) != 1 {
+		t.Fatalf("reply = %+v", m)
+	}
+	instance, _, err := readName(m.answers[0].data, 0)
+	if err != nil || instance != "LAN Orangutan on nas._http._tcp.local." {
+		t.Errorf("instance = %q, %v", instance, err)
+	}
+
+	var srv, txt, a bool
+	for _, rr := range m.additional {
+		switch rr.typ {
+		case typeSRV:
+			target, _, _ := readName(rr.data, 6)
+			srv = binary.BigEndian.Uint16(rr.data[4:]) == 291 && target == "orangutan.local."
+		case typeTXT:
+			txt = string(rr.data) == "\x06path=/"
+		case typeA:
+			a = true
+		}
+	}
+	if !srv || !txt || !a {
+		t.Errorf("additional = %+v, want the SRV, TXT and A records", m.additional)
+	}
+
+	if m := ask(t, "_services._dns-sd._udp.local.", typePTR); m == nil || len(m.answers) != 1 || len(m.additional) != 0 {
+		t.Errorf("service type enumeration = %+v", m)
+	}
+}
+
+func TestReadCompressedName(t *testing.T) {
+	// "local" at 12, then "orangutan" pointing back at it.
+	b := make([]byte, 12)
+	b = append(b, 5, 'l', 'o', 'c', 'a', 'l', 0)
+	b = append(b, 9, 'o', 'r', 'a', 'n', 'g', 'u', 't', 'a', 'n', 0xC0, 12)
+	name, next, err := readName(b, 19)
+	if err != nil || name != "orangutan.local." || next != len(b) {
+		t.Errorf("readName = %q, %d, %v", name, next, err)
+	}
+
+	loop := append(make([]byte, 12), 0xC0, 12)
+	if _, _, err := readName(loop, 12); err == nil {
+		t.Error("a compression loop was read")
+	}
+	if _, err := parse([]byte{0, 0, 0, 0, 0, 1}); err == nil {
+		t.Error("a short message was read")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	long := strings.Repeat("ü", 40)
+	got := truncate(long)
+	if len(got) > maxLabel || !strings.HasPrefix(long, got) || len(got)%2 != 0 {
+		t.Errorf("truncate = %q (%d bytes)", got, len(got))
+	}
+	if got := truncate("orangutan"); got != "orangutan" {
+		t.Errorf("truncate = %q", got)
+	}
+}
+
+func TestServiceTypes(t *testing.T) {
+	// An answer as a printer sends it, with the second service type's name
+	// compressed to point back into the first.
+	m := &message{response: true, answers: []record{
+		{name: servicesName, typ: typePTR, data: encodeName("_ipp._tcp.local.")},
+		{name: servicesName, typ: typePTR},
+		{name: "printer.local.", typ: typeA, data: []byte{192, 168, 1, 20}},
+	}}
+	b := m.pack()
+	// The first record's data starts after the header, its name, and its
+	// type, class, TTL and length; ._tcp.local. is 5 bytes into it.
+	first := 12 + len(encodeName(servicesName)) + 10
+	pointer := binary.BigEndian.AppendUint16([]byte{4, '_', 'p', 'd', 'l'}, 0xC000|uint16(first+5))
+	b = packWithData(t, b, 1, pointer)
+
+	got := serviceTypes(b)
+	if strings.Join(got, " ") != "_ipp._tcp _pdl._tcp" {
+		t.Errorf("serviceTypes = %q", got)
+	}
+	if got := serviceTypes((&message{questions: []question{{name: servicesName, typ: typePTR}}}).pack()); got != nil {
+		t.Errorf("serviceTypes of a query = %q", got)
+	}
+}
+
+// packWithData returns the message b with the data of its answer i, which
+// must be empty, replaced by data.
+func packWithData(t *testing.T, b []byte, i int, data []byte) []byte {
+	t.Helper()
+	m, err := parse(b)
+	if err != nil || len(m.answers[i].data) != 0 {
+		t.Fatalf("parse = %+v, %v", m, err)
+	}
+	at := m.answers[i].at
+	out := append([]byte(nil), b[:at-2]...)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(data)))
+	out = append(out, data...)
+	return append(out, b[at:]...)
+}
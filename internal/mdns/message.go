@@ -0,0 +1,228 @@
+package mdns
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// Record types.
+const (
+	typeA    = 1
+	typePTR  = 12
+	typeTXT  = 16
+	typeAAAA = 28
+	typeSRV  = 33
+	typeANY  = 255
+)
+
+const (
+	classIN = 1
+	// topBit of a question's class asks for a unicast answer, and of a
+	// record's class says it replaces what caches hold for its name.
+	topBit = 0x8000
+	// flagResponse marks a message as an answer.
+	flagResponse = 0x8400
+)
+
+// message is a DNS message, of which only what multicast DNS uses is kept.
+type message struct {
+	id         uint16
+	response   bool
+	questions  []question
+	answers    []record
+	authority  []record
+	additional []record
+}
+
+type question struct {
+	name    string
+	typ     uint16
+	unicast bool
+}
+
+// record is a resource record, with its data in wire format. at is where
+// the data starts in a parsed message, for reading compressed names in it.
+type record struct {
+	name   string
+	typ    uint16
+	unique bool
+	ttl    uint32
+	data   []byte
+	at     int
+}
+
+// pack returns m in wire format. Names are written out in full: the
+// messages are small enough without compression.
+func (m *message) pack() []byte {
+	b := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(b[0:], m.id)
+	if m.response {
+		binary.BigEndian.PutUint16(b[2:], flagResponse)
+	}
+	binary.BigEndian.PutUint16(b[4:], uint16(len(m.questions)))
+	binary.BigEndian.PutUint16(b[6:], uint16(len(m.answers)))
+	binary.BigEndian.PutUint16(b[8:], uint16(len(m.authority)))
+	binary.BigEndian.PutUint16(b[10:], uint16(len(m.additional)))
+	for _, q := range m.questions {
+		b = append(b, encodeName(q.name)...)
+		class := uint16(classIN)
+		if q.unicast {
+			class |= topBit
+		}
+		b = binary.BigEndian.AppendUint16(b, q.typ)
+		b = binary.BigEndian.AppendUint16(b, class)
+	}
+	for _, section := range [][]record{m.answers, m.authority, m.additional} {
+		for _, rr := range section {
+			b = append(b, encodeName(rr.name)...)
+			class := uint16(classIN)
+			if rr.unique {
+				class |= topBit
+			}
+			b = binary.BigEndian.AppendUint16(b, rr.typ)
+			b = binary.BigEndian.AppendUint16(b, class)
+			b = binary.BigEndian.AppendUint32(b, rr.ttl)
+			b = binary.BigEndian.AppendUint16(b, uint16(len(rr.data)))
+			b = append(b, rr.data...)
+		}
+	}
+	return b
+}
+
+var errTruncated = errors.New("message is cut short")
+
+// parse reads a message in wire format. The data of records is kept as it
+// is, so names in it may still be compressed.
+func parse(b []byte) (*message, error) {
+	if len(b) < 12 {
+		return nil, errTruncated
+	}
+	m := &message{
+		id:       binary.BigEndian.Uint16(b[0:]),
+		response: binary.BigEndian.Uint16(b[2:])&0x8000 != 0,
+	}
+	counts := [4]int{}
+	for i := range counts {
+		counts[i] = int(binary.BigEndian.Uint16(b[4+2*i:]))
+	}
+	off := 12
+	for i := 0; i < counts[0]; i++ {
+		name, next, err := readName(b, off)
+		if err != nil {
+			return nil, err
+		}
+		if next+4 > len(b) {
+			return nil, errTruncated
+		}
+		class := binary.BigEndian.Uint16(b[next+2:])
+		m.questions = append(m.questions, question{
+			name:    name,
+			typ:     binary.BigEndian.Uint16(b[next:]),
+			unicast: class&topBit != 0,
+		})
+		off = next + 4
+	}
+	for s, section := range []*[]record{&m.answers, &m.authority, &m.additional} {
+		for i := 0; i < counts[s+1]; i++ {
+			name, next, err := readName(b, off)
+			if err != nil {
+				return nil, err
+			}
+			if next+10 > len(b) {
+				return nil, errTruncated
+			}
+			size := int(binary.BigEndian.Uint16(b[next+8:]))
+			if next+10+size > len(b) {
+				return nil, errTruncated
+			}
+			*section = append(*section, record{
+				name:   name,
+				typ:    binary.BigEndian.Uint16(b[next:]),
+				unique: binary.BigEndian.Uint16(b[next+2:])&topBit != 0,
+				ttl:    binary.BigEndian.Uint32(b[next+4:]),
+				data:   b[next+10 : next+10+size],
+				at:     next + 10,
+			})
+			off = next + 10 + size
+		}
+	}
+	return m, nil
+}
+
+// readName reads the name at off in b, following compression pointers, and
+// returns it with a trailing dot and the offset after it.
+func readName(b []byte, off int) (string, int, error) {
+	var labels []string
+	next := -1
+	for jumps := 0; ; {
+		if off >= len(b) {
+			return "", 0, errTruncated
+		}
+		n := int(b[off])
+		switch {
+		case n == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.Join(labels, ".") + ".", next, nil
+		case n&0xC0 == 0xC0:
+			if off+1 >= len(b) {
+				return "", 0, errTruncated
+			}
+			if jumps++; jumps > 16 {
+				return "", 0, errors.New("name has a compression loop")
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(b[off:]) & 0x3FFF)
+		case n > maxLabel:
+			return "", 0, errors.New("label is too long")
+		default:
+			if off+1+n > len(b) {
+				return "", 0, errTruncated
+			}
+			labels = append(labels, string(b[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
+
+// encodeName returns name, with or without its trailing dot, in wire
+// format.
+func encodeName(name string) []byte {
+	var b []byte
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+// srvData is the data of an SRV record for port on target.
+func srvData(port int, target string) []byte {
+	b := make([]byte, 6)
+	binary.BigEndian.PutUint16(b[4:], uint16(port))
+	return append(b, encodeName(target)...)
+}
+
+// txtData is the data of a TXT record holding strings. With none it holds
+// one empty string, as a TXT record may not be empty.
+func txtData(strs []string) []byte {
+	var b []byte
+	for _, s := range strs {
+		if len(s) > 255 {
+			s = s[:255]
+		}
+		b = append(b, byte(len(s)))
+		b = append(b, s...)
+	}
+	if len(b) == 0 {
+		b = []byte{0}
+	}
+	return b
+}
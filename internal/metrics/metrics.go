@@ -0,0 +1,180 @@
+// Package metrics writes the state of the network in the Prometheus text
+// format, for node_exporter's textfile collector to serve: how many devices
+// there are and are online, whether each device is up, and how the scans of
+// each network went.
+//
+// A file picked up by node_exporter, rather than an endpoint of its own,
+// lets Prometheus see the network without anything new listening on the
+// machine.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// Input is what the metrics are made from.
+type Input struct {
+	Devices map[string]*types.Device
+	Scans   []Scan
+}
+
+// Scan is what is known of a network's scans.
+type Scan struct {
+	Network  string
+	Last     time.Time
+	Duration time.Duration
+}
+
+// metric writes one metric family: its help, its type and its samples.
+type metric struct {
+	w    *bufio.Writer
+	name string
+}
+
+func (m metric) header(help, kind string) metric {
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", m.name, help, m.name, kind)
+	return m
+}
+
+func (m metric) sample(value float64, labels ...string) {
+	m.w.WriteString(m.name)
+	if len(labels) > 0 {
+		m.w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				m.w.WriteByte(',')
+			}
+			fmt.Fprintf(m.w, "%s=\"%s\"", labels[i], escape(labels[i+1]))
+		}
+		m.w.WriteByte('}')
+	}
+	m.w.WriteByte(' ')
+	m.w.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	m.w.WriteByte('\n')
+}
+
+// escape escapes a label value as the text format needs.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// Write writes the metrics for in to w.
+func Write(w io.Writer, in Input) error {
+	bw := bufio.NewWriter(w)
+
+	ips := make([]string, 0, len(in.Devices))
+	online := 0
+	groups := make(map[string]int)
+	for ip, d := range in.Devices {
+		ips = append(ips, ip)
+		if d.IsOnline() {
+			online++
+		}
+		if d.Group != "" {
+			groups[d.Group]++
+		}
+	}
+	sort.Strings(ips)
+
+	devices := metric{bw, "lan_orangutan_devices"}.header("Devices in the inventory, by whether they were seen in the last hour.", "gauge")
+	devices.sample(float64(online), "state", "online")
+	devices.sample(float64(len(in.Devices)-online), "state", "offline")
+
+	byGroup := metric{bw, "lan_orangutan_group_devices"}.header("Devices in each group.", "gauge")
+	names := make([]string, 0, len(groups))
+	for g := range groups {
+		names = append(names, g)
+	}
+	sort.Strings(names)
+	for _, g := range names {
+		byGroup.sample(float64(groups[g]), "group", g)
+	}
+
+	up := metric{bw, "lan_orangutan_device_up"}.header("Whether the device was seen in the last hour.", "gauge")
+	for _, ip := range ips {
+		d := in.Devices[ip]
+		up.sample(boolValue(d.IsOnline()), "ip", d.IP, "mac", d.MAC, "name", name(d), "group", d.Group)
+	}
+	lastSeen := metric{bw, "lan_orangutan_device_last_seen_timestamp_seconds"}.header("When the device was last seen, as a Unix time.", "gauge")
+	for _, ip := range ips {
+		lastSeen.sample(unix(in.Devices[ip].LastSeen), "ip", ip)
+	}
+	response := metric{bw, "lan_orangutan_device_response_seconds"}.header("How long the device took to answer when last seen.", "gauge")
+	for _, ip := range ips {
+		if rt := in.Devices[ip].ResponseTime; rt != nil {
+			response.sample(*rt/1000, "ip", ip)
+		}
+	}
+
+	scans := append([]Scan(nil), in.Scans...)
+	sort.Slice(scans, func(i, j int) bool { return scans[i].Network < scans[j].Network })
+	last := metric{bw, "lan_orangutan_scan_last_timestamp_seconds"}.header("When the network was last scanned, as a Unix time.", "gauge")
+	for _, s := range scans {
+		last.sample(unix(s.Last), "network", s.Network)
+	}
+	duration := metric{bw, "lan_orangutan_scan_duration_seconds"}.header("How long the last scan of the network took.", "gauge")
+	for _, s := range scans {
+		duration.sample(s.Duration.Seconds(), "network", s.Network)
+	}
+	return bw.Flush()
+}
+
+// WriteFile writes the metrics for in to the file at path. The file is
+// replaced in one go, so that node_exporter never reads it half written; the
+// file being written does not end in .prom, so it is not read at all.
+func WriteFile(path string, in Input) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".lan-orangutan-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := Write(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// node_exporter often runs as another user, and metrics are not secret.
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// name is how a device is named in its labels: its label if it has one,
+// then its hostname, then its address.
+func name(d *types.Device) string {
+	switch {
+	case d.Label != "":
+		return d.Label
+	case d.Hostname != "":
+		return d.Hostname
+	default:
+		return d.IP
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func unix(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return float64(t.Unix())
+}
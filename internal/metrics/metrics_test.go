@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func TestWrite(t *testing.T) {
+	now := time.Now()
+	rt := 12.5
+	in := Input{
+		Devices: map[string]*types.Device{
+			"192.168.1.2": {IP: "192.168.1.2", MAC: "AA:BB:CC:00:11:22", Label: `Living "room" TV`, Group: "media", LastSeen: now, ResponseTime: &rt},
+			"192.168.1.3": {IP: "192.168.1.3", Hostname: "phone", LastSeen: now.Add(-2 * time.Hour)},
+		},
+		Scans: []Scan{{Network: "192.168.1.0/24", Last: time.Unix(1700000000, 0), Duration: 2500 * time.Millisecond}},
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, in); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"# TYPE lan_orangutan_devices gauge\n",
+		`lan_orangutan_devices{state="online"} 1` + "\n",
+		`lan_orangutan_devices{state="offline"} 1` + "\n",
+		`lan_orangutan_group_devices{group="media"} 1` + "\n",
+		`lan_orangutan_device_up{ip="192.168.1.2",mac="AA:BB:CC:00:11:22",name="Living \"room\" TV",group="media"} 1` + "\n",
+		`lan_orangutan_device_up{ip="192.168.1.3",mac="",name="phone",group=""} 0` + "\n",
+		`lan_orangutan_device_response_seconds{ip="192.168.1.2"} 0.0125` + "\n",
+		`lan_orangutan_scan_last_timestamp_seconds{network="192.168.1.0/24"} 1.7e+09` + "\n",
+		`lan_orangutan_scan_duration_seconds{network="192.168.1.0/24"} 2.5` + "\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("metrics lack %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, `lan_orangutan_device_response_seconds{ip="192.168.1.3"}`) {
+		t.Error("a device with no response time has one")
+	}
+}
+
+func TestWriteFileReplacesTheFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lan_orangutan.prom")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(path, Input{}); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.HasPrefix(string(data), "# HELP lan_orangutan_devices") {
+		t.Errorf("file = %q, %v", data, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("the directory holds %d files, want only the metrics", len(entries))
+	}
+}
+
+// TestDashboardMetrics checks that the Grafana dashboard only asks for
+// metrics Write writes.
+func TestDashboardMetrics(t *testing.T) {
+	data, err := os.ReadFile("../../docs/grafana/lan-orangutan.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, Input{}); err != nil {
+		t.Fatal(err)
+	}
+	written := buf.String()
+	for _, name := range regexp.MustCompile(`lan_orangutan_[a-z_]+`).FindAllString(string(data), -1) {
+		if !strings.Contains(written, "# TYPE "+name+" ") {
+			t.Errorf("the dashboard asks for %s, which is not written", name)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+// Package mqtt publishes device events and snapshots of the device list to
+// an MQTT broker, for home automation systems such as Home Assistant and
+// Node-RED to act on.
+//
+// The client speaks just enough of MQTT 3.1.1 to publish: it connects,
+// publishes at QoS 0 or 1, and keeps the connection alive. It never
+// subscribes.
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Packet types, as the high nibble of a packet's first byte.
+const (
+	packetConnect    = 1
+	packetConnack    = 2
+	packetPublish    = 3
+	packetPuback     = 4
+	packetPingreq    = 12
+	packetPingresp   = 13
+	packetDisconnect = 14
+)
+
+// replyTimeout is how long to wait for the broker to acknowledge a
+// connection, a publish or a ping.
+const replyTimeout = 10 * time.Second
+
+// connackErrors are the reasons a broker gives for refusing a connection.
+var connackErrors = map[byte]string{
+	1: "the broker does not speak MQTT 3.1.1",
+	2: "the broker rejected the client ID",
+	3: "the broker is unavailable",
+	4: "the broker rejected the username or password",
+	5: "the broker refused the connection as not authorized",
+}
+
+// Options say how to connect to the broker.
+type Options struct {
+	// Broker is the address of the broker: host:port, or a URL such as
+	// tcp://host:1883 or, for TLS, mqtts://host:8883.
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	// KeepAlive is how long the connection may stay idle before the
+	// broker drops it. The client pings within it.
+	KeepAlive time.Duration
+	// Will, when set, is published by the broker for the client when the
+	// connection is lost without a DISCONNECT.
+	Will *Message
+}
+
+// Client is a connection to an MQTT broker. It is not safe for concurrent
+// use.
+type Client struct {
+	conn      net.Conn
+	r         *bufio.Reader
+	nextID    uint16
+	keepAlive time.Duration
+	lastSent  time.Time
+}
+
+// ParseBroker returns the address to dial for broker and whether to use
+// TLS. Without a port, 1883 is used, or 8883 for TLS.
+func ParseBroker(broker string) (addr string, useTLS bool, err error) {
+	broker = strings.TrimSpace(broker)
+	if broker == "" {
+		return "", false, errors.New("no broker set")
+	}
+	if strings.Contains(broker, "://") {
+		u, err := url.Parse(broker)
+		if err != nil {
+			return "", false, fmt.Errorf("broker %q is not a URL: %w", broker, err)
+		}
+		switch u.Scheme {
+		case "tcp", "mqtt":
+		case "ssl", "tls", "mqtts":
+			useTLS = true
+		default:
+			return "", false, fmt.Errorf("broker %q: scheme %s is not tcp, mqtt, ssl, tls or mqtts", broker, u.Scheme)
+		}
+		if u.Hostname() == "" {
+			return "", false, fmt.Errorf("broker %q has no host", broker)
+		}
+		broker = u.Host
+	}
+	if _, _, err := net.SplitHostPort(broker); err != nil {
+		port := "1883"
+		if useTLS {
+			port = "8883"
+		}
+		broker = net.JoinHostPort(strings.Trim(broker, "[]"), port)
+	}
+	return broker, useTLS, nil
+}
+
+// Dial connects to the broker and waits for it to accept the connection.
+func Dial(ctx context.Context, opts Options) (*Client, error) {
+	addr, useTLS, err := ParseBroker(opts.Broker)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: replyTimeout}
+	var conn net.Conn
+	if useTLS {
+		host, _, _ := net.SplitHostPort(addr)
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{conn: conn, r: bufio.NewReader(conn), keepAlive: opts.KeepAlive}
+	if err := c.connect(opts); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// connect sends CONNECT and reads the broker's CONNACK.
+func (c *Client) connect(opts Options) error {
+	var flags byte = 0x02 // clean session: nothing is subscribed to resume
+	var payload []byte
+	payload = appendString(payload, opts.ClientID)
+	if w := opts.Will; w != nil {
+		flags |= 0x04
+		if w.Retain {
+			flags |= 0x20
+		}
+		payload = appendString(payload, w.Topic)
+		payload = appendString(payload, string(w.Payload))
+	}
+	if opts.Username != "" {
+		flags |= 0x80
+		payload = appendString(payload, opts.Username)
+	}
+	if opts.Password != "" {
+		flags |= 0x40
+		payload = appendString(payload, opts.Password)
+	}
+
+	var body []byte
+	body = appendString(body, "MQTT")
+	body = append(body, 4, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(c.keepAlive/time.Second))
+	body = append(body, payload...)
+	if err := c.send(packetConnect<<4, body); err != nil {
+		return err
+	}
+
+	kind, reply, err := c.read()
+	if err != nil {
+		return fmt.Errorf("no reply from the broker: %w", err)
+	}
+	if kind != packetConnack || len(reply) != 2 {
+		return fmt.Errorf("the broker answered with packet type %d instead of CONNACK", kind)
+	}
+	if code := reply[1]; code != 0 {
+		if reason, ok := connackErrors[code]; ok {
+			return errors.New(reason)
+		}
+		return fmt.Errorf("the broker refused the connection with code %d", code)
+	}
+	return nil
+}
+
+// Publish sends payload to topic. At QoS 1 it waits for the broker to
+// acknowledge it; at QoS 0 it does not wait.
+func (c *Client) Publish(topic string, payload []byte, qos byte, retain bool) error {
+	header := byte(packetPublish<<4) | qos<<1
+	if retain {
+		header |= 0x01
+	}
+	body := appendString(nil, topic)
+	var id uint16
+	if qos > 0 {
+		c.nextID++
+		if c.nextID == 0 {
+			c.nextID = 1
+		}
+		id = c.nextID
+		body = binary.BigEndian.AppendUint16(body, id)
+	}
+	body = append(body, payload...)
+	if err := c.send(header, body); err != nil {
+		return err
+	}
+	if qos == 0 {
+		return nil
+	}
+	for {
+		kind, reply, err := c.read()
+		if err != nil {
+			return fmt.Errorf("no acknowledgement from the broker: %w", err)
+		}
+		if kind == packetPuback && len(reply) == 2 && binary.BigEndian.Uint16(reply) == id {
+			return nil
+		}
+	}
+}
+
+// KeepAlive pings the broker if nothing has been sent for half the keep
+// alive period, so that an idle connection is not dropped.
+func (c *Client) KeepAlive() error {
+	if c.keepAlive <= 0 || time.Since(c.lastSent) < c.keepAlive/2 {
+		return nil
+	}
+	if err := c.send(packetPingreq<<4, nil); err != nil {
+		return err
+	}
+	for {
+		kind, _, err := c.read()
+		if err != nil {
+			return fmt.Errorf("no reply to ping: %w", err)
+		}
+		if kind == packetPingresp {
+			return nil
+		}
+	}
+}
+
+// Close disconnects from the broker.
+func (c *Client) Close() error {
+	c.send(packetDisconnect<<4, nil)
+	return c.conn.Close()
+}
+
+// send writes a packet with the first byte header.
+func (c *Client) send(header byte, body []byte) error {
+	packet := append([]byte{header}, remainingLength(len(body))...)
+	packet = append(packet, body...)
+	c.conn.SetWriteDeadline(time.Now().Add(replyTimeout))
+	if _, err := c.conn.Write(packet); err != nil {
+		return err
+	}
+	c.lastSent = time.Now()
+	return nil
+}
+
+// read reads a packet, returning its type and what follows its fixed header.
+func (c *Client) read() (kind byte, body []byte, err error) {
+	c.conn.SetReadDeadline(time.Now().Add(replyTimeout))
+	header, err := c.r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, err := readRemainingLength(c.r)
+	if err != nil {
+		return 0, nil, err
+	}
+	body = make([]byte, length)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return 0, nil, err
+	}
+	return header >> 4, body, nil
+}
+
+// appendString appends s as MQTT writes strings: its length in two bytes,
+// then its bytes.
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// remainingLength encodes n as the variable length integer that ends a
+// fixed header.
+func remainingLength(n int) []byte {
+	var b []byte
+	for {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if n == 0 {
+			return b
+		}
+	}
+}
+
+// readRemainingLength reads the variable length integer remainingLength
+// writes.
+func readRemainingLength(r io.ByteReader) (int, error) {
+	n, multiplier := 0, 1
+	for i := 0; i < 4; i++ {
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		n += int(digit&0x7f) * multiplier
+		if digit&0x80 == 0 {
+			return n, nil
+		}
+		multiplier *= 128
+	}
+	return 0, errors.New("malformed packet length")
+}
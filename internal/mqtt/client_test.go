@@ -0,0 +1,157 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// packet is a packet received by the fake broker.
+type packet struct {
+	header byte
+	body   []byte
+}
+
+// fakeBroker accepts one connection on a local port and answers it, sending
+// what it receives to the returned channel.
+func fakeBroker(t *testing.T, connack byte) (string, <-chan packet) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan packet, 16)
+	go func() {
+		defer close(received)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			header, err := r.ReadByte()
+			if err != nil {
+				return
+			}
+			length, err := readRemainingLength(r)
+			if err != nil {
+				return
+			}
+			body := make([]byte, length)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return
+			}
+			received <- packet{header, body}
+
+			switch header >> 4 {
+			case packetConnect:
+				conn.Write([]byte{packetConnack << 4, 2, 0, connack})
+			case packetPublish:
+				if qos := header >> 1 & 3; qos == 1 {
+					topicLen := int(binary.BigEndian.Uint16(body))
+					id := body[2+topicLen : 4+topicLen]
+					conn.Write(append([]byte{packetPuback << 4, 2}, id...))
+				}
+			case packetPingreq:
+				conn.Write([]byte{packetPingresp << 4, 0})
+			}
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func TestConnectAndPublish(t *testing.T) {
+	addr, received := fakeBroker(t, 0)
+	c, err := Dial(context.Background(), Options{
+		Broker:    "tcp://" + addr,
+		ClientID:  "test",
+		Username:  "user",
+		Password:  "secret",
+		KeepAlive: time.Minute,
+		Will:      &Message{Topic: "s", Payload: []byte("off"), Retain: true},
+	})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	connect := <-received
+	wantConnect := []byte{0, 4, 'M', 'Q', 'T', 'T', 4, 0xe6, 0, 60, 0, 4, 't', 'e', 's', 't',
+		0, 1, 's', 0, 3, 'o', 'f', 'f',
+		0, 4, 'u', 's', 'e', 'r', 0, 6, 's', 'e', 'c', 'r', 'e', 't'}
+	if connect.header != 0x10 || string(connect.body) != string(wantConnect) {
+		t.Errorf("CONNECT = %#x %v, want 0x10 %v", connect.header, connect.body, wantConnect)
+	}
+
+	if err := c.Publish("a/b", []byte("hi"), 1, true); err != nil {
+		t.Fatalf("Publish at QoS 1: %v", err)
+	}
+	publish := <-received
+	if publish.header != 0x33 || string(publish.body) != "\x00\x03a/b\x00\x01hi" {
+		t.Errorf("PUBLISH = %#x %q", publish.header, publish.body)
+	}
+
+	if err := c.Publish("a/b", []byte("hi"), 0, false); err != nil {
+		t.Fatalf("Publish at QoS 0: %v", err)
+	}
+	publish = <-received
+	if publish.header != 0x30 || string(publish.body) != "\x00\x03a/bhi" {
+		t.Errorf("PUBLISH = %#x %q", publish.header, publish.body)
+	}
+
+	c.Close()
+	if disconnect := <-received; disconnect.header != 0xe0 {
+		t.Errorf("closing sent %#x, want DISCONNECT", disconnect.header)
+	}
+}
+
+func TestDialReportsRefusal(t *testing.T) {
+	addr, _ := fakeBroker(t, 4)
+	_, err := Dial(context.Background(), Options{Broker: addr, ClientID: "test"})
+	if err == nil || err.Error() != "the broker rejected the username or password" {
+		t.Errorf("Dial = %v, want the username or password rejected", err)
+	}
+}
+
+func TestParseBroker(t *testing.T) {
+	for _, tt := range []struct {
+		broker  string
+		addr    string
+		useTLS  bool
+		wantErr bool
+	}{
+		{"192.168.1.5", "192.168.1.5:1883", false, false},
+		{"broker.lan:1884", "broker.lan:1884", false, false},
+		{"tcp://broker.lan", "broker.lan:1883", false, false},
+		{"mqtt://broker.lan:1885", "broker.lan:1885", false, false},
+		{"mqtts://broker.example.com", "broker.example.com:8883", true, false},
+		{"ssl://[fd00::5]", "[fd00::5]:8883", true, false},
+		{"fd00::5", "[fd00::5]:1883", false, false},
+		{"http://broker.lan", "", false, true},
+		{"tcp://", "", false, true},
+		{"", "", false, true},
+	} {
+		addr, useTLS, err := ParseBroker(tt.broker)
+		if (err != nil) != tt.wantErr || addr != tt.addr || useTLS != tt.useTLS {
+			t.Errorf("ParseBroker(%q) = %q, %v, %v; want %q, %v, error %v",
+				tt.broker, addr, useTLS, err, tt.addr, tt.useTLS, tt.wantErr)
+		}
+	}
+}
+
+func TestRemainingLength(t *testing.T) {
+	for _, n := range []int{0, 127, 128, 16383, 16384, 2097151, 2097152} {
+		encoded := remainingLength(n)
+		got, err := readRemainingLength(bytes.NewReader(encoded))
+		if err != nil || got != n {
+			t.Errorf("remainingLength(%d) = %v, read back as %d, %v", n, encoded, got, err)
+		}
+	}
+}
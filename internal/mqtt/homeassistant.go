@@ -0,0 +1,147 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// haNode groups the discovery topics of every device, as Home Assistant's
+// node_id, and starts each device's unique ID.
+const haNode = "lan_orangutan"
+
+// Tracker states, as Home Assistant's device trackers name them.
+const (
+	haHome    = "home"
+	haNotHome = "not_home"
+)
+
+// haConfig is the discovery payload of a device tracker.
+type haConfig struct {
+	Name                string   `json:"name"`
+	UniqueID            string   `json:"unique_id"`
+	StateTopic          string   `json:"state_topic"`
+	JSONAttributesTopic string   `json:"json_attributes_topic"`
+	AvailabilityTopic   string   `json:"availability_topic"`
+	PayloadHome         string   `json:"payload_home"`
+	PayloadNotHome      string   `json:"payload_not_home"`
+	SourceType          string   `json:"source_type"`
+	Device              haDevice `json:"device"`
+}
+
+// haDevice is the device in Home Assistant's device registry that a tracker
+// belongs to.
+type haDevice struct {
+	Identifiers  []string    `json:"identifiers"`
+	Name         string      `json:"name"`
+	Manufacturer string      `json:"manufacturer,omitempty"`
+	Connections  [][2]string `json:"connections,omitempty"`
+}
+
+// haAttributes are the details shown on a tracker, named as Home Assistant
+// names them for trackers fed by a router.
+type haAttributes struct {
+	IP       string `json:"ip"`
+	MAC      string `json:"mac,omitempty"`
+	HostName string `json:"host_name,omitempty"`
+	Vendor   string `json:"vendor,omitempty"`
+	Group    string `json:"group,omitempty"`
+	LastSeen string `json:"last_seen"`
+}
+
+// haObjectID returns the part of the discovery topic and unique ID naming
+// the device at ip. Devices are known by address, as the inventory keys them.
+func haObjectID(ip string) string {
+	return strings.NewReplacer(".", "_", ":", "_").Replace(ip)
+}
+
+func (p *Publisher) haConfigTopic(d types.Device) string {
+	return p.opts.DiscoveryPrefix + "/device_tracker/" + haNode + "/" + haObjectID(d.IP) + "/config"
+}
+
+func (p *Publisher) haTopic(d types.Device, kind string) string {
+	return p.opts.TopicPrefix + "/tracker/" + haObjectID(d.IP) + "/" + kind
+}
+
+// discovery returns what Home Assistant needs to add the device: its
+// discovery config, its details and whether it is home. Every message is
+// retained whatever the retain setting, so that Home Assistant finds the
+// devices and their states again when it restarts.
+func (p *Publisher) discovery(d types.Device) []Message {
+	return []Message{p.trackerConfig(d), p.trackerAttributes(d), p.trackerState(d)}
+}
+
+// trackerConfig returns the discovery config of the device, which also
+// updates its name when its label or hostname changes.
+func (p *Publisher) trackerConfig(d types.Device) Message {
+	id := haNode + "_" + haObjectID(d.IP)
+	config := haConfig{
+		Name:                displayName(d),
+		UniqueID:            id,
+		StateTopic:          p.haTopic(d, "state"),
+		JSONAttributesTopic: p.haTopic(d, "attributes"),
+		AvailabilityTopic:   p.statusTopic(),
+		PayloadHome:         haHome,
+		PayloadNotHome:      haNotHome,
+		SourceType:          "router",
+		Device: haDevice{
+			Identifiers:  []string{id},
+			Name:         displayName(d),
+			Manufacturer: d.Vendor,
+		},
+	}
+	if d.MAC != "" {
+		config.Device.Connections = [][2]string{{"mac", strings.ToLower(d.MAC)}}
+	}
+	data, _ := json.Marshal(config)
+	return Message{Topic: p.haConfigTopic(d), Payload: data, Retain: true}
+}
+
+// trackerAttributes returns the details shown on the device's tracker.
+func (p *Publisher) trackerAttributes(d types.Device) Message {
+	data, _ := json.Marshal(haAttributes{
+		IP:       d.IP,
+		MAC:      d.MAC,
+		HostName: d.Hostname,
+		Vendor:   d.Vendor,
+		Group:    d.Group,
+		LastSeen: d.LastSeen.UTC().Format(time.RFC3339),
+	})
+	return Message{Topic: p.haTopic(d, "attributes"), Payload: data, Retain: true}
+}
+
+// trackerState returns whether the device is home, which is whether it is
+// online.
+func (p *Publisher) trackerState(d types.Device) Message {
+	state := haNotHome
+	if d.IsOnline() {
+		state = haHome
+	}
+	return Message{Topic: p.haTopic(d, "state"), Payload: []byte(state), Retain: true}
+}
+
+// forget returns what removes the device from Home Assistant: an empty
+// discovery config, and empty messages to clear its retained details and
+// state from the broker.
+func (p *Publisher) forget(d types.Device) []Message {
+	return []Message{
+		{Topic: p.haConfigTopic(d), Retain: true},
+		{Topic: p.haTopic(d, "attributes"), Retain: true},
+		{Topic: p.haTopic(d, "state"), Retain: true},
+	}
+}
+
+// displayName is how a device is named in Home Assistant: its label if it
+// has one, then its hostname, then its address, as the event log names it.
+func displayName(d types.Device) string {
+	switch {
+	case d.Label != "":
+		return d.Label
+	case d.Hostname != "":
+		return d.Hostname
+	default:
+		return d.IP
+	}
+}
@@ -0,0 +1,69 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func TestHomeAssistantDiscovery(t *testing.T) {
+	now := time.Now()
+	p := NewPublisher(PublisherOptions{TopicPrefix: "lan-orangutan", HomeAssistant: true, DiscoveryPrefix: "homeassistant"})
+	nas := types.Device{IP: "192.168.1.4", MAC: "AA:BB:CC:00:11:22", Hostname: "nas", Vendor: "Synology", LastSeen: now}
+
+	announced := p.announce([]types.Device{nas})
+	topics := []string{
+		"lan-orangutan/status",
+		"homeassistant/device_tracker/lan_orangutan/192_168_1_4/config",
+		"lan-orangutan/tracker/192_168_1_4/attributes",
+		"lan-orangutan/tracker/192_168_1_4/state",
+	}
+	if len(announced) != len(topics) {
+		t.Fatalf("announce returned %d messages, want %d", len(announced), len(topics))
+	}
+	for i, topic := range topics {
+		if announced[i].Topic != topic || !announced[i].Retain {
+			t.Errorf("message %d went to %s, retained %v; want %s, retained", i, announced[i].Topic, announced[i].Retain, topic)
+		}
+	}
+	if got := string(announced[3].Payload); got != "home" {
+		t.Errorf("state = %q, want home", got)
+	}
+
+	var config haConfig
+	if err := json.Unmarshal(announced[1].Payload, &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Name != "nas" || config.UniqueID != "lan_orangutan_192_168_1_4" ||
+		config.StateTopic != topics[3] || config.AvailabilityTopic != topics[0] ||
+		config.Device.Manufacturer != "Synology" || len(config.Device.Connections) != 1 ||
+		config.Device.Connections[0] != [2]string{"mac", "aa:bb:cc:00:11:22"} {
+		t.Errorf("discovery config = %+v", config)
+	}
+
+	p.Observe([]types.Device{nas}, now)
+	nas.LastSeen = now.Add(-2 * time.Hour)
+	got := p.Observe([]types.Device{nas}, now)
+	if len(got) != 3 || got[1].Topic != topics[3] || string(got[1].Payload) != "not_home" {
+		t.Errorf("going offline published %+v, want the state not_home", got)
+	}
+
+	got = p.Observe(nil, now)
+	if len(got) != 3 || got[0].Topic != topics[1] || len(got[0].Payload) != 0 || !got[0].Retain {
+		t.Errorf("deleting the device published %+v, want an empty retained config", got)
+	}
+}
+
+func TestHomeAssistantOff(t *testing.T) {
+	now := time.Now()
+	p := NewPublisher(PublisherOptions{TopicPrefix: "lan-orangutan"})
+	if got := p.announce([]types.Device{{IP: "10.0.0.2", LastSeen: now}}); len(got) != 1 {
+		t.Errorf("announce without Home Assistant returned %d messages, want only the status", len(got))
+	}
+	p.Observe([]types.Device{{IP: "10.0.0.2", LastSeen: now}}, now)
+	if got := p.Observe(nil, now); len(got) != 0 {
+		t.Errorf("deleting a device without Home Assistant published %d messages", len(got))
+	}
+}
@@ -0,0 +1,335 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// Event types, the last part of the topic each is published to.
+const (
+	EventNew     = "new"
+	EventOnline  = "online"
+	EventOffline = "offline"
+	EventChanged = "changed"
+)
+
+// pollInterval is how often the publisher looks at the devices. Devices go
+// offline by not being seen for an hour rather than at a scan, so looking
+// only after scans would report them late or never.
+const pollInterval = 10 * time.Second
+
+// PublisherOptions say where and how the publisher publishes.
+type PublisherOptions struct {
+	Options
+
+	// TopicPrefix starts every topic: events go to PREFIX/events/TYPE and
+	// snapshots to PREFIX/devices.
+	TopicPrefix string
+	QoS         byte
+	Retain      bool
+	// SnapshotInterval is how often to publish the whole device list. 0
+	// means never.
+	SnapshotInterval time.Duration
+
+	// HomeAssistant publishes discovery messages under DiscoveryPrefix, so
+	// that each device appears in Home Assistant as a device tracker.
+	HomeAssistant   bool
+	DiscoveryPrefix string
+}
+
+// Event is the payload of an event message.
+type Event struct {
+	Type   string       `json:"type"`
+	Time   time.Time    `json:"time"`
+	Device types.Device `json:"device"`
+	// Changes are the details that changed, for a changed event.
+	Changes []types.Change `json:"changes,omitempty"`
+}
+
+// Snapshot is the payload of a snapshot message.
+type Snapshot struct {
+	Time    time.Time        `json:"time"`
+	Total   int              `json:"total"`
+	Online  int              `json:"online"`
+	Devices []SnapshotDevice `json:"devices"`
+}
+
+// SnapshotDevice is a device in a snapshot.
+type SnapshotDevice struct {
+	types.Device
+	Online bool `json:"online"`
+}
+
+// Message is a payload to publish and the topic to publish it to.
+type Message struct {
+	Topic   string
+	Payload []byte
+	// Retain asks the broker to keep the message for later subscribers.
+	Retain bool
+}
+
+// Publisher publishes what happens to the devices to a broker.
+type Publisher struct {
+	opts   PublisherOptions
+	client *Client
+
+	// known holds the devices as last observed, by IP, and whether each was
+	// online then. It is nil until the first observation.
+	known map[string]knownDevice
+
+	lastSnapshot time.Time
+	// unreachable is set while the broker cannot be reached, so that an
+	// outage is logged once rather than every poll.
+	unreachable bool
+}
+
+type knownDevice struct {
+	device types.Device
+	online bool
+}
+
+// NewPublisher returns a publisher with opts. It connects when it first has
+// something to publish.
+func NewPublisher(opts PublisherOptions) *Publisher {
+	opts.TopicPrefix = strings.TrimSuffix(opts.TopicPrefix, "/")
+	opts.DiscoveryPrefix = strings.TrimSuffix(opts.DiscoveryPrefix, "/")
+	p := &Publisher{opts: opts}
+	// The broker says offline for us when the connection drops, so that
+	// subscribers can tell a quiet network from a dead publisher.
+	p.opts.Will = &Message{Topic: p.statusTopic(), Payload: []byte("offline"), Retain: true}
+	return p
+}
+
+// statusTopic is where "online" is published on connecting, and "offline" by
+// the broker when the connection is lost.
+func (p *Publisher) statusTopic() string {
+	return p.opts.TopicPrefix + "/status"
+}
+
+// Run publishes events and snapshots of the devices devices returns until
+// ctx is done.
+func (p *Publisher) Run(ctx context.Context, devices func() []types.Device) {
+	defer func() {
+		if p.client != nil {
+			// The will is only for a connection that is lost; one closed on
+			// purpose says offline itself.
+			p.client.Publish(p.statusTopic(), []byte("offline"), p.opts.QoS, true)
+			p.client.Close()
+		}
+	}()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		p.round(ctx, devices())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// round publishes what has happened to devices since the last round, and a
+// snapshot when one is due. Messages that cannot be published are dropped:
+// the next snapshot brings subscribers up to date.
+func (p *Publisher) round(ctx context.Context, devices []types.Device) {
+	now := time.Now()
+	messages := p.Observe(devices, now)
+	fresh := p.client == nil
+	if err := p.connect(ctx); err != nil {
+		return
+	}
+	if fresh {
+		messages = append(p.announce(devices), messages...)
+	}
+	snapshot := p.opts.SnapshotInterval > 0 && now.Sub(p.lastSnapshot) >= p.opts.SnapshotInterval
+	if snapshot {
+		messages = append(messages, p.Snapshot(devices, now))
+	}
+
+	for _, m := range messages {
+		if err := p.client.Publish(m.Topic, m.Payload, p.opts.QoS, m.Retain); err != nil {
+			p.disconnect(err)
+			return
+		}
+	}
+	if snapshot {
+		p.lastSnapshot = now
+	}
+	if len(messages) == 0 {
+		if err := p.client.KeepAlive(); err != nil {
+			p.disconnect(err)
+		}
+	}
+}
+
+// announce returns what to publish on connecting: that the publisher is
+// online and, for Home Assistant, every device. Retained discovery messages
+// would survive a reconnect, but not a broker restarted without persistence.
+func (p *Publisher) announce(devices []types.Device) []Message {
+	messages := []Message{{Topic: p.statusTopic(), Payload: []byte("online"), Retain: true}}
+	if p.opts.HomeAssistant {
+		for _, d := range sortedByIP(devices) {
+			messages = append(messages, p.discovery(d)...)
+		}
+	}
+	return messages
+}
+
+// connect connects to the broker unless already connected.
+func (p *Publisher) connect(ctx context.Context) error {
+	if p.client != nil {
+		return nil
+	}
+	client, err := Dial(ctx, p.opts.Options)
+	if err != nil {
+		if !p.unreachable {
+			slog.Warn("cannot reach the MQTT broker; retrying", "broker", p.opts.Broker, "error", err)
+			p.unreachable = true
+		}
+		return err
+	}
+	slog.Info("connected to the MQTT broker", "broker", p.opts.Broker)
+	p.client = client
+	p.unreachable = false
+	// Whatever was missed while disconnected is in a fresh snapshot.
+	p.lastSnapshot = time.Time{}
+	return nil
+}
+
+// disconnect drops the connection after err, to connect afresh next round.
+func (p *Publisher) disconnect(err error) {
+	slog.Warn("lost the MQTT broker; reconnecting", "broker", p.opts.Broker, "error", err)
+	p.client.conn.Close()
+	p.client = nil
+}
+
+// Observe compares devices with those of the last observation and returns
+// an event message for each device that is new, has come online, gone
+// offline or changed, along with what Home Assistant needs to know of it.
+// The first observation returns none, since everything would be new.
+func (p *Publisher) Observe(devices []types.Device, now time.Time) []Message {
+	first := p.known == nil
+	current := make(map[string]knownDevice, len(devices))
+	for _, d := range devices {
+		current[d.IP] = knownDevice{device: d, online: d.IsOnline()}
+	}
+	previous := p.known
+	p.known = current
+	if first {
+		return nil
+	}
+
+	var messages []Message
+	for _, ip := range sortedKeys(current) {
+		d := current[ip]
+		before, ok := previous[ip]
+		switch {
+		case !ok:
+			messages = append(messages, p.event(EventNew, d.device, nil, now))
+			if p.opts.HomeAssistant {
+				messages = append(messages, p.discovery(d.device)...)
+			}
+			continue
+		case d.online && !before.online:
+			messages = append(messages, p.event(EventOnline, d.device, nil, now))
+			if p.opts.HomeAssistant {
+				messages = append(messages, p.trackerState(d.device), p.trackerAttributes(d.device))
+			}
+		case !d.online && before.online:
+			messages = append(messages, p.event(EventOffline, d.device, nil, now))
+			if p.opts.HomeAssistant {
+				messages = append(messages, p.trackerState(d.device), p.trackerAttributes(d.device))
+			}
+		}
+		if changes := changes(&before.device, &d.device, now); len(changes) > 0 {
+			messages = append(messages, p.event(EventChanged, d.device, changes, now))
+			if p.opts.HomeAssistant {
+				messages = append(messages, p.trackerConfig(d.device), p.trackerAttributes(d.device))
+			}
+		}
+	}
+
+	// A device deleted from the inventory leaves Home Assistant too.
+	if p.opts.HomeAssistant {
+		for _, ip := range sortedKeys(previous) {
+			if _, ok := current[ip]; !ok {
+				messages = append(messages, p.forget(previous[ip].device)...)
+			}
+		}
+	}
+	return messages
+}
+
+// Snapshot returns a message with every device and whether it is online.
+func (p *Publisher) Snapshot(devices []types.Device, now time.Time) Message {
+	s := Snapshot{Time: now, Total: len(devices), Devices: make([]SnapshotDevice, 0, len(devices))}
+	for _, d := range devices {
+		online := d.IsOnline()
+		if online {
+			s.Online++
+		}
+		s.Devices = append(s.Devices, SnapshotDevice{Device: d, Online: online})
+	}
+	sort.Slice(s.Devices, func(i, j int) bool { return s.Devices[i].IP < s.Devices[j].IP })
+	return p.message("devices", s)
+}
+
+func sortedKeys(devices map[string]knownDevice) []string {
+	ips := make([]string, 0, len(devices))
+	for ip := range devices {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+func sortedByIP(devices []types.Device) []types.Device {
+	sorted := append([]types.Device(nil), devices...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].IP < sorted[j].IP })
+	return sorted
+}
+
+func (p *Publisher) event(kind string, d types.Device, changes []types.Change, now time.Time) Message {
+	return p.message("events/"+kind, Event{Type: kind, Time: now, Device: d, Changes: changes})
+}
+
+func (p *Publisher) message(topic string, payload any) Message {
+	// The payloads are plain structs, which always marshal.
+	data, _ := json.Marshal(payload)
+	return Message{Topic: p.opts.TopicPrefix + "/" + topic, Payload: data, Retain: p.opts.Retain}
+}
+
+// changes returns the details that differ between before and after, by the
+// rules the storage keeps a device's history by.
+func changes(before, after *types.Device, now time.Time) []types.Change {
+	fields := []struct{ name, old, new string }{
+		{"mac", before.MAC, after.MAC},
+		{"hostname", before.Hostname, after.Hostname},
+		{"label", before.Label, after.Label},
+		{"group", before.Group, after.Group},
+		{"notes", before.Notes, after.Notes},
+		{"type", before.Type, after.Type},
+		{"tags", strings.Join(before.Tags, ", "), strings.Join(after.Tags, ", ")},
+	}
+	var result []types.Change
+	for _, f := range fields {
+		if f.old == f.new {
+			continue
+		}
+		// A name learnt for the first time, or lost to a lookup that
+		// failed, is not news.
+		scanned := f.name == "mac" || f.name == "hostname"
+		if scanned && (f.old == "" || f.new == "" || strings.EqualFold(f.old, f.new)) {
+			continue
+		}
+		result = append(result, types.Change{Time: now, Field: f.name, Old: f.old, New: f.new})
+	}
+	return result
+}
@@ -0,0 +1,87 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func TestObserve(t *testing.T) {
+	now := time.Now()
+	long := now.Add(-2 * time.Hour)
+	p := NewPublisher(PublisherOptions{TopicPrefix: "home/lan/"})
+
+	first := []types.Device{
+		{IP: "192.168.1.2", Hostname: "printer", LastSeen: now},
+		{IP: "192.168.1.3", Hostname: "phone", LastSeen: long},
+		{IP: "192.168.1.4", Hostname: "nas", LastSeen: now},
+	}
+	if got := p.Observe(first, now); len(got) != 0 {
+		t.Fatalf("first observation published %d events, want none", len(got))
+	}
+
+	second := []types.Device{
+		{IP: "192.168.1.2", Hostname: "printer", LastSeen: long},
+		{IP: "192.168.1.3", Hostname: "phone", LastSeen: now},
+		{IP: "192.168.1.4", Hostname: "nas", Label: "Backups", LastSeen: now},
+		{IP: "192.168.1.5", LastSeen: now},
+	}
+	got := p.Observe(second, now)
+	want := []struct{ topic, ip string }{
+		{"home/lan/events/offline", "192.168.1.2"},
+		{"home/lan/events/online", "192.168.1.3"},
+		{"home/lan/events/changed", "192.168.1.4"},
+		{"home/lan/events/new", "192.168.1.5"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Observe returned %d messages, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		var e Event
+		if err := json.Unmarshal(got[i].Payload, &e); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		if got[i].Topic != w.topic || e.Device.IP != w.ip {
+			t.Errorf("message %d went to %s for %s, want %s for %s", i, got[i].Topic, e.Device.IP, w.topic, w.ip)
+		}
+		if w.topic == "home/lan/events/changed" {
+			if len(e.Changes) != 1 || e.Changes[0].Field != "label" || e.Changes[0].New != "Backups" {
+				t.Errorf("changes = %+v, want the label set to Backups", e.Changes)
+			}
+		}
+	}
+
+	if got := p.Observe(second, now); len(got) != 0 {
+		t.Errorf("observing the same devices again published %d events", len(got))
+	}
+}
+
+func TestObserveIgnoresALostHostname(t *testing.T) {
+	now := time.Now()
+	p := NewPublisher(PublisherOptions{TopicPrefix: "lan-orangutan"})
+	p.Observe([]types.Device{{IP: "10.0.0.2", Hostname: "tv", LastSeen: now}}, now)
+	if got := p.Observe([]types.Device{{IP: "10.0.0.2", LastSeen: now}}, now); len(got) != 0 {
+		t.Errorf("a hostname lost to a failed lookup published %d events", len(got))
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	now := time.Now()
+	p := NewPublisher(PublisherOptions{TopicPrefix: "lan-orangutan"})
+	m := p.Snapshot([]types.Device{
+		{IP: "10.0.0.3", LastSeen: now.Add(-2 * time.Hour)},
+		{IP: "10.0.0.2", LastSeen: now},
+	}, now)
+	if m.Topic != "lan-orangutan/devices" {
+		t.Errorf("snapshot went to %s", m.Topic)
+	}
+	var s Snapshot
+	if err := json.Unmarshal(m.Payload, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Total != 2 || s.Online != 1 || s.Devices[0].IP != "10.0.0.2" || !s.Devices[0].Online || s.Devices[1].Online {
+		t.Errorf("snapshot = %+v", s)
+	}
+}
@@ -0,0 +1,186 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// Neighbor is an entry in the operating system's ARP (IPv4) or neighbour
+// discovery (IPv6) table: an address on the local link the kernel has
+// recently exchanged packets with, and the hardware address it answered from.
+type Neighbor struct {
+	IP        string
+	MAC       string
+	Interface string
+	// State is the kernel's view of the entry, such as "reachable" or
+	// "stale", where the platform reports one.
+	State string
+}
+
+// Neighbors reads the neighbour table. It needs no privileges: the kernel
+// already keeps the table for its own use, so this is a cheap check of what
+// is on the network without sending a single probe.
+//
+// Entries with no hardware address, for hosts that never answered, are left
+// out.
+func Neighbors() ([]Neighbor, error) {
+	switch runtime.GOOS {
+	case "linux":
+		// `ip` reports state and IPv6 neighbours; /proc/net/arp is there
+		// even in containers without iproute2.
+		if output, err := runCommand("ip", "-j", "neigh", "show"); err == nil {
+			if neighbors, err := parseIPNeigh(output); err == nil {
+				return neighbors, nil
+			}
+		}
+		data, err := os.ReadFile("/proc/net/arp")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ARP table: %w", err)
+		}
+		return parseProcARP(string(data)), nil
+	case "windows":
+		output, err := runCommand("arp", "-a")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ARP table: %w", err)
+		}
+		return parseWindowsARP(string(output)), nil
+	default:
+		output, err := runCommand("arp", "-an")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ARP table: %w", err)
+		}
+		return parseBSDARP(string(output)), nil
+	}
+}
+
+// normaliseNeighborMAC puts a hardware address in the lowercase,
+// colon-separated form, whichever separator the platform used. It returns ""
+// for a missing or all-zero address, which is how incomplete entries appear.
+func normaliseNeighborMAC(mac string) string {
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		// BSD arp drops leading zeros ("0:1a:2b:3:4:5"); pad them back.
+		parts := strings.Split(mac, ":")
+		if len(parts) != 6 {
+			return ""
+		}
+		for i, p := range parts {
+			if len(p) == 1 {
+				parts[i] = "0" + p
+			}
+		}
+		if hw, err = net.ParseMAC(strings.Join(parts, ":")); err != nil {
+			return ""
+		}
+	}
+	for _, b := range hw {
+		if b != 0 {
+			return hw.String()
+		}
+	}
+	return ""
+}
+
+// parseIPNeigh parses the JSON output of `ip -j neigh show`.
+func parseIPNeigh(output []byte) ([]Neighbor, error) {
+	var entries []struct {
+		Dst    string   `json:"dst"`
+		Dev    string   `json:"dev"`
+		LLAddr string   `json:"lladdr"`
+		State  []string `json:"state"`
+	}
+	if err := json.Unmarshal(output, &entries); err != nil {
+		return nil, err
+	}
+
+	var neighbors []Neighbor
+	for _, e := range entries {
+		mac := normaliseNeighborMAC(e.LLAddr)
+		if mac == "" || net.ParseIP(e.Dst) == nil {
+			continue
+		}
+		n := Neighbor{IP: e.Dst, MAC: mac, Interface: e.Dev}
+		if len(e.State) > 0 {
+			n.State = strings.ToLower(e.State[0])
+		}
+		neighbors = append(neighbors, n)
+	}
+	return neighbors, nil
+}
+
+// parseProcARP parses Linux's /proc/net/arp:
+//
+//	IP address       HW type     Flags       HW address            Mask     Device
+//	192.168.1.1      0x1         0x2         aa:bb:cc:dd:ee:ff     *        eth0
+func parseProcARP(data string) []Neighbor {
+	var neighbors []Neighbor
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 || net.ParseIP(fields[0]) == nil {
+			continue
+		}
+		mac := normaliseNeighborMAC(fields[3])
+		if mac == "" {
+			continue
+		}
+		neighbors = append(neighbors, Neighbor{IP: fields[0], MAC: mac, Interface: fields[5]})
+	}
+	return neighbors
+}
+
+// parseBSDARP parses the output of `arp -an` on macOS and the BSDs:
+//
+//	? (192.168.1.1) at aa:bb:cc:dd:ee:ff on en0 ifscope [ethernet]
+//	? (192.168.1.7) at (incomplete) on en0 ifscope [ethernet]
+func parseBSDARP(output string) []Neighbor {
+	var neighbors []Neighbor
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[2] != "at" {
+			continue
+		}
+		ip := strings.Trim(fields[1], "()")
+		mac := normaliseNeighborMAC(fields[3])
+		if net.ParseIP(ip) == nil || mac == "" {
+			continue
+		}
+		n := Neighbor{IP: ip, MAC: mac}
+		if len(fields) >= 6 && fields[4] == "on" {
+			n.Interface = fields[5]
+		}
+		neighbors = append(neighbors, n)
+	}
+	return neighbors
+}
+
+// parseWindowsARP parses the output of `arp -a` on Windows, which groups
+// entries under the address of the interface they were learnt on:
+//
+//	Interface: 192.168.1.10 --- 0xb
+//	  Internet Address      Physical Address      Type
+//	  192.168.1.1           aa-bb-cc-dd-ee-ff     dynamic
+func parseWindowsARP(output string) []Neighbor {
+	var neighbors []Neighbor
+	iface := ""
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "Interface:" {
+			iface = fields[1]
+			continue
+		}
+		if len(fields) < 3 || net.ParseIP(fields[0]) == nil {
+			continue
+		}
+		mac := normaliseNeighborMAC(fields[1])
+		// Broadcast and multicast entries are static mappings, not hosts.
+		if mac == "" || mac == "ff:ff:ff:ff:ff:ff" || strings.HasPrefix(mac, "01:00:5e") {
+			continue
+		}
+		neighbors = append(neighbors, Neighbor{IP: fields[0], MAC: mac, Interface: iface, State: fields[2]})
+	}
+	return neighbors
+}
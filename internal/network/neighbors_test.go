@@ -0,0 +1,63 @@
+package network
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseIPNeigh(t *testing.T) {
+	output := []byte(`[
+		{"dst":"192.168.1.1","dev":"eth0","lladdr":"AA:BB:CC:DD:EE:01","state":["REACHABLE"]},
+		{"dst":"192.168.1.7","dev":"eth0","state":["FAILED"]},
+		{"dst":"fe80::1","dev":"eth0","lladdr":"aa:bb:cc:dd:ee:02","router":null,"state":["STALE"]}
+	]`)
+
+	got, err := parseIPNeigh(output)
+	if err != nil {
+		t.Fatalf("parseIPNeigh: %v", err)
+	}
+	want := []Neighbor{
+		{IP: "192.168.1.1", MAC: "aa:bb:cc:dd:ee:01", Interface: "eth0", State: "reachable"},
+		{IP: "fe80::1", MAC: "aa:bb:cc:dd:ee:02", Interface: "eth0", State: "stale"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseProcARP(t *testing.T) {
+	data := `IP address       HW type     Flags       HW address            Mask     Device
+192.168.1.1      0x1         0x2         aa:bb:cc:dd:ee:01     *        wlan0
+192.168.1.7      0x1         0x0         00:00:00:00:00:00     *        wlan0
+`
+	want := []Neighbor{{IP: "192.168.1.1", MAC: "aa:bb:cc:dd:ee:01", Interface: "wlan0"}}
+	if got := parseProcARP(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseBSDARP(t *testing.T) {
+	output := `? (192.168.1.1) at aa:bb:cc:d:e:1 on en0 ifscope [ethernet]
+? (192.168.1.7) at (incomplete) on en0 ifscope [ethernet]
+router.lan (192.168.1.254) at 0:1a:2b:3c:4d:5e on en0 ifscope permanent [ethernet]
+`
+	want := []Neighbor{
+		{IP: "192.168.1.1", MAC: "aa:bb:cc:0d:0e:01", Interface: "en0"},
+		{IP: "192.168.1.254", MAC: "00:1a:2b:3c:4d:5e", Interface: "en0"},
+	}
+	if got := parseBSDARP(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseWindowsARP(t *testing.T) {
+	output := "\r\nInterface: 192.168.1.10 --- 0xb\r\n" +
+		"  Internet Address      Physical Address      Type\r\n" +
+		"  192.168.1.1           aa-bb-cc-dd-ee-01     dynamic   \r\n" +
+		"  192.168.1.255         ff-ff-ff-ff-ff-ff     static    \r\n" +
+		"  224.0.0.22            01-00-5e-00-00-16     static    \r\n"
+	want := []Neighbor{{IP: "192.168.1.1", MAC: "aa:bb:cc:dd:ee:01", Interface: "192.168.1.10", State: "dynamic"}}
+	if got := parseWindowsARP(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -1,16 +1,25 @@
 package network
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net"
-	"os/exec"
+	"net/http"
+	"os"
 	"runtime"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/291-Group/LAN-Orangutan/internal/types"
 )
 
-// tailscaleStatusJSON represents the JSON output from `tailscale status --json`
+// tailscaleStatusJSON is Tailscale's status, as the LocalAPI and `tailscale
+// status --json` give it.
 type tailscaleStatusJSON struct {
 	Version        string `json:"Version"`
 	BackendState   string `json:"BackendState"`
@@ -18,10 +27,12 @@ type tailscaleStatusJSON struct {
 		Name string `json:"Name"`
 	} `json:"CurrentTailnet"`
 	Self struct {
-		DNSName      string   `json:"DNSName"`
-		HostName     string   `json:"HostName"`
-		OS           string   `json:"OS"`
-		TailscaleIPs []string `json:"TailscaleIPs"`
+		DNSName       string   `json:"DNSName"`
+		HostName      string   `json:"HostName"`
+		OS            string   `json:"OS"`
+		TailscaleIPs  []string `json:"TailscaleIPs"`
+		Tags          []string `json:"Tags"`
+		PrimaryRoutes []string `json:"PrimaryRoutes"`
 	} `json:"Self"`
 	Peer           map[string]tailscalePeerJSON `json:"Peer"`
 	ExitNodeStatus struct {
@@ -29,13 +40,19 @@ type tailscaleStatusJSON struct {
 	} `json:"ExitNodeStatus"`
 }
 
-// tailscalePeerJSON represents one peer in `tailscale status --json`
+// tailscalePeerJSON is one peer in Tailscale's status.
 type tailscalePeerJSON struct {
-	TailscaleIPs []string `json:"TailscaleIPs"`
-	HostName     string   `json:"HostName"`
-	DNSName      string   `json:"DNSName"`
-	OS           string   `json:"OS"`
-	Online       bool     `json:"Online"`
+	TailscaleIPs []string  `json:"TailscaleIPs"`
+	HostName     string    `json:"HostName"`
+	DNSName      string    `json:"DNSName"`
+	OS           string    `json:"OS"`
+	Online       bool      `json:"Online"`
+	LastSeen     time.Time `json:"LastSeen"`
+	ExitNode     bool      `json:"ExitNode"`
+	// Tags are the ACL tags the peer is owned by, such as tag:server.
+	Tags []string `json:"Tags"`
+	// PrimaryRoutes are the subnets the peer routes to the tailnet.
+	PrimaryRoutes []string `json:"PrimaryRoutes"`
 }
 
 // shortName returns the peer's friendly name, preferring the hostname and
@@ -73,6 +90,139 @@ func (p tailscalePeerJSON) toDevice() types.Device {
 	}
 }
 
+// toPeer converts a peer into the form shown on the Tailscale page.
+func (p tailscalePeerJSON) toPeer() types.TailscalePeer {
+	return types.TailscalePeer{
+		Name:     p.shortName(),
+		DNSName:  strings.TrimSuffix(p.DNSName, "."),
+		IP:       p.ipv4(),
+		IPs:      p.TailscaleIPs,
+		OS:       p.OS,
+		Online:   p.Online,
+		LastSeen: p.LastSeen,
+		ExitNode: p.ExitNode,
+		Tags:     p.Tags,
+		Routes:   p.PrimaryRoutes,
+	}
+}
+
+// errTailscaleUnavailable is returned when there is no Tailscale to ask.
+var errTailscaleUnavailable = errors.New("tailscale is not installed")
+
+// tailscaleApp is the command inside the macOS app, which keeps its LocalAPI
+// behind a password only the app and its command know.
+const tailscaleApp = "/Applications/Tailscale.app/Contents/MacOS/Tailscale"
+
+// readTailscaleStatus asks tailscaled for its status over its LocalAPI, as
+// the tailscale CLI does. On a Mac with no socket to ask, as with the app,
+// it runs the app's command instead.
+func readTailscaleStatus() (*tailscaleStatusJSON, error) {
+	status, err := localAPIStatus()
+	if errors.Is(err, errTailscaleUnavailable) && runtime.GOOS == "darwin" {
+		return appStatus()
+	}
+	return status, err
+}
+
+// appStatus runs the macOS app's command for Tailscale's status.
+func appStatus() (*tailscaleStatusJSON, error) {
+	if _, err := os.Stat(tailscaleApp); err != nil {
+		return nil, errTailscaleUnavailable
+	}
+	output, err := runCommand(tailscaleApp, "status", "--json")
+	if err != nil {
+		return nil, err
+	}
+	var status tailscaleStatusJSON
+	if err := json.Unmarshal(output, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// localAPIStatus asks tailscaled for its status over its LocalAPI, giving
+// up after commandTimeout.
+func localAPIStatus() (*tailscaleStatusJSON, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	conn, err := dialTailscaled(ctx)
+	if err != nil {
+		if errors.Is(err, errTailscaleUnavailable) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("cannot reach tailscaled: %w", err)
+	}
+	defer conn.Close()
+	// Closing the connection is what stops a wedged daemon holding things
+	// up, as a Windows pipe has no deadlines to set.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	// The request is written and the answer read in turn, rather than by
+	// an http.Client, which reads and writes at once: a Windows pipe
+	// opened for neither blocks one on the other.
+	req, err := http.NewRequest(http.MethodGet, "http://local-tailscaled.sock/localapi/v0/status", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Close = true
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("cannot ask tailscaled: %w", err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read tailscaled's answer: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		reply, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("tailscaled answered %s: %s", resp.Status, strings.TrimSpace(string(reply)))
+	}
+	var status tailscaleStatusJSON
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("cannot read tailscaled's answer: %w", err)
+	}
+	return &status, nil
+}
+
+// GetTailscalePeers returns every node on the tailnet, online or not, with
+// this machine first and the rest sorted by name.
+//
+// Unlike GetTailscaleDevices this includes offline peers, since the point of
+// listing them is to see the whole tailnet. It returns nothing while Tailscale
+// is stopped or logged out, when the list is only what it saw last time.
+func GetTailscalePeers() []types.TailscalePeer {
+	tsStatus, err := readTailscaleStatus()
+	if err != nil || tsStatus.BackendState != "Running" {
+		return nil
+	}
+	return tsStatus.peers()
+}
+
+// peers lists the tailnet from a decoded status, as GetTailscalePeers does.
+func (s *tailscaleStatusJSON) peers() []types.TailscalePeer {
+	self := tailscalePeerJSON{
+		TailscaleIPs:  s.Self.TailscaleIPs,
+		HostName:      s.Self.HostName,
+		DNSName:       s.Self.DNSName,
+		OS:            s.Self.OS,
+		Online:        true,
+		Tags:          s.Self.Tags,
+		PrimaryRoutes: s.Self.PrimaryRoutes,
+	}.toPeer()
+	self.Self = true
+
+	others := make([]types.TailscalePeer, 0, len(s.Peer))
+	for _, p := range s.Peer {
+		others = append(others, p.toPeer())
+	}
+	sort.Slice(others, func(i, j int) bool {
+		return strings.ToLower(others[i].Name) < strings.ToLower(others[j].Name)
+	})
+
+	return append([]types.TailscalePeer{self}, others...)
+}
+
 // GetTailscaleDevices returns the devices currently reachable over Tailscale,
 // including this machine.
 //
@@ -83,21 +233,11 @@ func (p tailscalePeerJSON) toDevice() types.Device {
 // Only peers that are currently online are returned. Offline peers are known to
 // Tailscale but reporting them as discovered would mark them as seen just now.
 func GetTailscaleDevices() []types.Device {
-	tailscaleBin := findTailscaleBinary()
-	if tailscaleBin == "" {
-		return nil
-	}
-
-	output, err := runCommand(tailscaleBin, "status", "--json")
+	tsStatus, err := readTailscaleStatus()
 	if err != nil {
 		return nil
 	}
 
-	var tsStatus tailscaleStatusJSON
-	if err := json.Unmarshal(output, &tsStatus); err != nil {
-		return nil
-	}
-
 	// A stopped or logged-out Tailscale still lists the peers it saw last time,
 	// none of which are reachable now.
 	if tsStatus.BackendState != "Running" {
@@ -128,64 +268,18 @@ func GetTailscaleDevices() []types.Device {
 	return devices
 }
 
-// findTailscaleBinary finds the tailscale CLI binary path
-func findTailscaleBinary() string {
-	// First check if it's in PATH
-	if path, err := exec.LookPath("tailscale"); err == nil {
-		return path
-	}
-
-	// Check platform-specific locations
-	switch runtime.GOOS {
-	case "darwin":
-		// macOS: Tailscale app installs CLI here
-		paths := []string{
-			"/Applications/Tailscale.app/Contents/MacOS/Tailscale",
-			"/usr/local/bin/tailscale",
-		}
-		for _, p := range paths {
-			if _, err := exec.LookPath(p); err == nil {
-				return p
-			}
-		}
-	case "windows":
-		paths := []string{
-			`C:\Program Files\Tailscale\tailscale.exe`,
-			`C:\Program Files (x86)\Tailscale\tailscale.exe`,
-		}
-		for _, p := range paths {
-			if _, err := exec.LookPath(p); err == nil {
-				return p
-			}
-		}
-	}
-
-	return ""
-}
-
 // GetTailscaleStatus returns the current Tailscale status
 func GetTailscaleStatus() types.TailscaleStatus {
 	status := types.TailscaleStatus{}
 
-	// Check if tailscale is installed
-	tailscaleBin := findTailscaleBinary()
-	if tailscaleBin == "" {
-		status.Installed = false
+	tsStatus, err := readTailscaleStatus()
+	if errors.Is(err, errTailscaleUnavailable) {
 		return status
 	}
 	status.Installed = true
-
-	// Get tailscale status
-	output, err := runCommand(tailscaleBin, "status", "--json")
 	if err != nil {
 		// Tailscale is installed but not running, not connected, or wedged.
-		status.Running = false
-		return status
-	}
-
-	var tsStatus tailscaleStatusJSON
-	if err := json.Unmarshal(output, &tsStatus); err != nil {
-		status.Running = false
+		status.Error = err.Error()
 		return status
 	}
 
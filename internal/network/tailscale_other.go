@@ -0,0 +1,33 @@
+//go:build !windows
+
+package network
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+)
+
+// tailscaledSockets are where tailscaled serves its LocalAPI: on Linux and
+// the BSDs, and for the open source daemon on macOS. The macOS app serves it
+// elsewhere, behind a password, and is asked through its command instead.
+var tailscaledSockets = []string{
+	"/var/run/tailscale/tailscaled.sock",
+	"/run/tailscale/tailscaled.sock",
+	"/var/run/tailscaled.socket",
+}
+
+// dialTailscaled connects to the LocalAPI socket, returning
+// errTailscaleUnavailable when there is none.
+func dialTailscaled(ctx context.Context) (io.ReadWriteCloser, error) {
+	for _, path := range tailscaledSockets {
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		return (&net.Dialer{}).DialContext(ctx, "unix", path)
+	}
+	return nil, errTailscaleUnavailable
+}
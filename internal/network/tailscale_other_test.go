@@ -0,0 +1,59 @@
+//go:build !windows
+
+package network
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeTailscaled serves handler as the LocalAPI on a socket of its own, in
+// place of the real ones.
+func fakeTailscaled(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tailscaled.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("no unix sockets: %v", err)
+	}
+	server := &http.Server{Handler: handler}
+	go server.Serve(ln)
+	t.Cleanup(func() { server.Close() })
+
+	saved := tailscaledSockets
+	tailscaledSockets = []string{filepath.Join(t.TempDir(), "missing.sock"), path}
+	t.Cleanup(func() { tailscaledSockets = saved })
+}
+
+func TestLocalAPIStatus(t *testing.T) {
+	fakeTailscaled(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "local-tailscaled.sock" || r.URL.Path != "/localapi/v0/status" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, statusFixture)
+	})
+
+	status := GetTailscaleStatus()
+	if !status.Installed || !status.Connected || status.Version != "1.76.1" || status.PeerCount != 2 {
+		t.Errorf("GetTailscaleStatus = %+v", status)
+	}
+	if devices := GetTailscaleDevices(); len(devices) != 2 {
+		t.Errorf("GetTailscaleDevices = %+v, want this machine and the online peer", devices)
+	}
+}
+
+func TestLocalAPIErrors(t *testing.T) {
+	fakeTailscaled(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "tailscaled is shutting down", http.StatusServiceUnavailable)
+	})
+
+	status := GetTailscaleStatus()
+	if !status.Installed || status.Running || !strings.Contains(status.Error, "tailscaled is shutting down") {
+		t.Errorf("GetTailscaleStatus = %+v, want the daemon's answer as the error", status)
+	}
+}
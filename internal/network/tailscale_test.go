@@ -0,0 +1,78 @@
+package network
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// statusFixture is trimmed from real `tailscale status --json` output.
+const statusFixture = `{
+  "Version": "1.76.1",
+  "BackendState": "Running",
+  "Self": {
+    "DNSName": "desk.tail1234.ts.net.",
+    "HostName": "desk",
+    "OS": "linux",
+    "TailscaleIPs": ["100.64.0.1", "fd7a:115c:a1e0::1"]
+  },
+  "Peer": {
+    "nodekey:b": {
+      "DNSName": "phone.tail1234.ts.net.",
+      "HostName": "phone",
+      "OS": "iOS",
+      "TailscaleIPs": ["fd7a:115c:a1e0::3", "100.64.0.3"],
+      "Online": false,
+      "LastSeen": "2024-05-01T10:00:00Z"
+    },
+    "nodekey:a": {
+      "DNSName": "Exit.tail1234.ts.net.",
+      "HostName": "",
+      "OS": "linux",
+      "TailscaleIPs": ["100.64.0.2"],
+      "Online": true,
+      "ExitNode": true,
+      "Tags": ["tag:exit"],
+      "PrimaryRoutes": ["192.168.1.0/24"]
+    }
+  }
+}`
+
+func TestTailscalePeers(t *testing.T) {
+	var status tailscaleStatusJSON
+	if err := json.Unmarshal([]byte(statusFixture), &status); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	peers := status.peers()
+	if len(peers) != 3 {
+		t.Fatalf("got %d peers, want 3 including this machine", len(peers))
+	}
+
+	if !peers[0].Self || peers[0].Name != "desk" || !peers[0].Online {
+		t.Errorf("first peer = %+v, want this machine, online", peers[0])
+	}
+
+	// Sorted by name, case-insensitively; a peer without a hostname is named
+	// from its DNS name.
+	if peers[1].Name != "Exit" || !peers[1].ExitNode || !peers[1].Online {
+		t.Errorf("second peer = %+v, want the online exit node", peers[1])
+	}
+	if !reflect.DeepEqual(peers[1].Tags, []string{"tag:exit"}) || !reflect.DeepEqual(peers[1].Routes, []string{"192.168.1.0/24"}) {
+		t.Errorf("exit node tags, routes = %v, %v", peers[1].Tags, peers[1].Routes)
+	}
+
+	phone := peers[2]
+	if phone.Name != "phone" || phone.Online {
+		t.Errorf("third peer = %+v, want the offline phone", phone)
+	}
+	if phone.IP != "100.64.0.3" {
+		t.Errorf("phone IP = %q, want its IPv4 address even when listed second", phone.IP)
+	}
+	if phone.LastSeen.IsZero() {
+		t.Error("an offline peer should carry when it was last seen")
+	}
+	if phone.DNSName != "phone.tail1234.ts.net" {
+		t.Errorf("DNS name = %q, want it without the trailing dot", phone.DNSName)
+	}
+}
@@ -0,0 +1,22 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// tailscaledPipe is the named pipe tailscaled serves its LocalAPI on.
+const tailscaledPipe = `\\.\pipe\ProtectedPrefix\Administrators\Tailscale\tailscaled`
+
+// dialTailscaled connects to the LocalAPI pipe, returning
+// errTailscaleUnavailable when there is none.
+func dialTailscaled(ctx context.Context) (io.ReadWriteCloser, error) {
+	f, err := os.OpenFile(tailscaledPipe, os.O_RDWR, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errTailscaleUnavailable
+	}
+	return f, err
+}
@@ -0,0 +1,80 @@
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// wolPort is the port Wake-on-LAN packets are conventionally sent to. Network
+// cards listen for the pattern on any port, but some routers only forward 9.
+const wolPort = 9
+
+// MagicPacket builds the Wake-on-LAN packet for mac: six 0xFF bytes followed
+// by the hardware address repeated sixteen times.
+func MagicPacket(mac string) ([]byte, error) {
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAC address %q: %w", mac, err)
+	}
+	if len(hw) != 6 {
+		return nil, fmt.Errorf("invalid MAC address %q: Wake-on-LAN needs a 6 byte address", mac)
+	}
+
+	packet := bytes.Repeat([]byte{0xFF}, 6)
+	packet = append(packet, bytes.Repeat(hw, 16)...)
+	return packet, nil
+}
+
+// Wake sends a Wake-on-LAN packet for mac towards the device that was last
+// seen at ip.
+//
+// A sleeping device has no address to send to, so the packet goes to the
+// broadcast address of the local network containing ip. The limited broadcast
+// address 255.255.255.255 is the fallback, but it only leaves through the
+// default interface, which is the wrong one on a machine with several.
+func Wake(mac, ip string, networks []types.Network) error {
+	packet, err := MagicPacket(mac)
+	if err != nil {
+		return err
+	}
+
+	target := broadcastFor(ip, networks)
+	conn, err := net.Dial("udp4", net.JoinHostPort(target, fmt.Sprint(wolPort)))
+	if err != nil {
+		return fmt.Errorf("failed to reach %s: %w", target, err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(packet); err != nil {
+		return fmt.Errorf("failed to send wake packet: %w", err)
+	}
+	return nil
+}
+
+// broadcastFor returns the directed broadcast address of whichever network
+// contains ip, or the limited broadcast address if none does.
+func broadcastFor(ip string, networks []types.Network) string {
+	addr := net.ParseIP(ip).To4()
+	if addr == nil {
+		return "255.255.255.255"
+	}
+	for _, n := range networks {
+		_, ipNet, err := net.ParseCIDR(n.CIDR)
+		if err != nil || !ipNet.Contains(addr) {
+			continue
+		}
+		base, mask := ipNet.IP.To4(), net.IP(ipNet.Mask).To4()
+		if base == nil || mask == nil {
+			continue
+		}
+		bcast := make(net.IP, 4)
+		for i := range bcast {
+			bcast[i] = base[i] | ^mask[i]
+		}
+		return bcast.String()
+	}
+	return "255.255.255.255"
+}
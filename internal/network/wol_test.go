@@ -0,0 +1,54 @@
+package network
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func TestMagicPacket(t *testing.T) {
+	packet, err := MagicPacket("aa-bb-cc-dd-ee-ff")
+	if err != nil {
+		t.Fatalf("MagicPacket: %v", err)
+	}
+	if len(packet) != 102 {
+		t.Fatalf("packet is %d bytes, want 102", len(packet))
+	}
+	if !bytes.Equal(packet[:6], bytes.Repeat([]byte{0xFF}, 6)) {
+		t.Error("packet should start with six 0xFF bytes")
+	}
+	mac := []byte{0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF}
+	for i := 0; i < 16; i++ {
+		if got := packet[6+i*6 : 12+i*6]; !bytes.Equal(got, mac) {
+			t.Fatalf("repetition %d = % x, want % x", i, got, mac)
+		}
+	}
+}
+
+func TestMagicPacketRejectsBadMAC(t *testing.T) {
+	for _, mac := range []string{"", "not-a-mac", "00:00:5e:10:00:00:00:01"} {
+		if _, err := MagicPacket(mac); err == nil {
+			t.Errorf("MagicPacket(%q) should fail", mac)
+		}
+	}
+}
+
+func TestBroadcastFor(t *testing.T) {
+	networks := []types.Network{
+		{CIDR: "10.0.0.0/8"},
+		{CIDR: "192.168.1.0/24"},
+	}
+
+	tests := []struct{ ip, want string }{
+		{"192.168.1.40", "192.168.1.255"},
+		{"10.20.30.40", "10.255.255.255"},
+		{"172.16.0.5", "255.255.255.255"},
+		{"not an ip", "255.255.255.255"},
+	}
+	for _, tt := range tests {
+		if got := broadcastFor(tt.ip, networks); got != tt.want {
+			t.Errorf("broadcastFor(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
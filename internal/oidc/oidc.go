@@ -0,0 +1,575 @@
+// Package oidc signs users in to the web UI with an OpenID Connect provider,
+// such as Authelia, Keycloak, Authentik or Zitadel, so that a business that
+// already has one needs no separate password for LAN Orangutan.
+//
+// It uses the authorization code flow with PKCE: Begin sends the browser to
+// the provider, which sends it back with a code that Finish exchanges for an
+// ID token. The token's signature is checked with the provider's published
+// keys before anything in it is believed.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	_ "crypto/sha512" // for RS384, RS512 and ES384
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestTimeout bounds each request to the provider.
+const requestTimeout = 10 * time.Second
+
+// signInTimeout is how long a user has at the provider to sign in.
+const signInTimeout = 10 * time.Minute
+
+// keysRefetchInterval is how often the provider's keys may be fetched again
+// for a token signed with a key not seen before, as after it rotates them.
+const keysRefetchInterval = time.Minute
+
+// clockSkew is how far the provider's clock may be from this one.
+const clockSkew = 2 * time.Minute
+
+// Defaults for what is asked of the provider and which claims are read.
+var DefaultScopes = []string{"openid", "profile", "email", "groups"}
+
+const (
+	DefaultUsernameClaim = "preferred_username"
+	DefaultGroupsClaim   = "groups"
+)
+
+// Options say which provider to sign in with.
+type Options struct {
+	// Issuer is the provider's issuer URL, such as
+	// https://auth.example.com or https://sso.example.com/realms/office.
+	// Its configuration is read from /.well-known/openid-configuration.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// UsernameClaim and GroupsClaim name the claims holding the username
+	// and the groups the user is in.
+	UsernameClaim string
+	GroupsClaim   string
+}
+
+// Identity is who the provider signed in.
+type Identity struct {
+	Subject  string
+	Username string
+	Groups   []string
+}
+
+// metadata is the part of the provider's configuration that is used.
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// pending is a sign in the browser has been sent to the provider for.
+type pending struct {
+	nonce       string
+	verifier    string
+	redirectURL string
+	expires     time.Time
+}
+
+// Provider signs users in with an OpenID Connect provider.
+type Provider struct {
+	opts Options
+	http *http.Client
+	// now is the clock, replaced in tests.
+	now func() time.Time
+
+	mu          sync.Mutex
+	meta        *metadata
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+	pending     map[string]pending
+}
+
+// New returns a provider for opts. Its configuration is fetched when it is
+// first needed, so that a provider that is down when the server starts does
+// not stop it starting.
+func New(opts Options) *Provider {
+	opts.Issuer = strings.TrimSuffix(opts.Issuer, "/")
+	if len(opts.Scopes) == 0 {
+		opts.Scopes = DefaultScopes
+	}
+	if opts.UsernameClaim == "" {
+		opts.UsernameClaim = DefaultUsernameClaim
+	}
+	if opts.GroupsClaim == "" {
+		opts.GroupsClaim = DefaultGroupsClaim
+	}
+	return &Provider{
+		opts:    opts,
+		http:    &http.Client{Timeout: requestTimeout},
+		now:     time.Now,
+		pending: map[string]pending{},
+	}
+}
+
+// ValidIssuer checks that u is an http or https URL a provider can be found
+// at.
+func ValidIssuer(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("%q is not an http or https URL", u)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("%q has no host", u)
+	}
+	return nil
+}
+
+// Begin starts a sign in and returns the URL to send the browser to, and
+// the state the provider will send back with it, which Finish needs.
+// redirectURL is where the provider sends the browser afterwards.
+func (p *Provider) Begin(ctx context.Context, redirectURL string) (authURL, state string, err error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	state, nonce, verifier := randomString(), randomString(), randomString()
+	challenge := sha256.Sum256([]byte(verifier))
+
+	p.mu.Lock()
+	now := p.now()
+	for s, pend := range p.pending {
+		if now.After(pend.expires) {
+			delete(p.pending, s)
+		}
+	}
+	p.pending[state] = pending{nonce: nonce, verifier: verifier, redirectURL: redirectURL, expires: now.Add(signInTimeout)}
+	p.mu.Unlock()
+
+	u, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", "", fmt.Errorf("authorization endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.opts.ClientID)
+	q.Set("redirect_uri", redirectURL)
+	q.Set("scope", strings.Join(p.opts.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), state, nil
+}
+
+// Finish completes the sign in begun with state, exchanging the code the
+// provider sent the browser back with for the identity of the user.
+func (p *Provider) Finish(ctx context.Context, state, code string) (Identity, error) {
+	p.mu.Lock()
+	pend, ok := p.pending[state]
+	delete(p.pending, state)
+	p.mu.Unlock()
+	if !ok || p.now().After(pend.expires) {
+		return Identity{}, errors.New("the sign in was not started here, or took too long")
+	}
+	if code == "" {
+		return Identity{}, errors.New("the provider sent no code")
+	}
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {pend.redirectURL},
+		"code_verifier": {pend.verifier},
+	}
+	if p.opts.ClientSecret == "" {
+		form.Set("client_id", p.opts.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.opts.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.opts.ClientID), url.QueryEscape(p.opts.ClientSecret))
+	}
+	var tokens struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+	}
+	if err := p.do(req, &tokens); err != nil {
+		return Identity{}, fmt.Errorf("exchange the code: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return Identity{}, errors.New("the provider sent no ID token; is the openid scope allowed?")
+	}
+
+	claims, err := p.verify(ctx, tokens.IDToken, meta)
+	if err != nil {
+		return Identity{}, fmt.Errorf("ID token: %w", err)
+	}
+	if nonce, _ := claims["nonce"].(string); nonce != pend.nonce {
+		return Identity{}, errors.New("ID token: nonce does not match")
+	}
+
+	// Some providers, Authelia among them, give the groups only from the
+	// userinfo endpoint.
+	if _, has := claims[p.opts.GroupsClaim]; !has && meta.UserinfoEndpoint != "" && tokens.AccessToken != "" {
+		if info, err := p.userinfo(ctx, meta.UserinfoEndpoint, tokens.AccessToken); err == nil && info["sub"] == claims["sub"] {
+			for k, v := range info {
+				if _, has := claims[k]; !has {
+					claims[k] = v
+				}
+			}
+		}
+	}
+	return p.identity(claims), nil
+}
+
+// identity reads who the user is from claims.
+func (p *Provider) identity(claims map[string]any) Identity {
+	id := Identity{}
+	id.Subject, _ = claims["sub"].(string)
+	for _, claim := range []string{p.opts.UsernameClaim, "preferred_username", "email"} {
+		if name, _ := claims[claim].(string); name != "" {
+			id.Username = name
+			break
+		}
+	}
+	if id.Username == "" {
+		id.Username = id.Subject
+	}
+	switch groups := claims[p.opts.GroupsClaim].(type) {
+	case []any:
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				id.Groups = append(id.Groups, s)
+			}
+		}
+	case string:
+		id.Groups = strings.Fields(strings.ReplaceAll(groups, ",", " "))
+	}
+	return id
+}
+
+// metadata returns the provider's configuration, fetching it the first time.
+func (p *Provider) metadata(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	meta := p.meta
+	p.mu.Unlock()
+	if meta != nil {
+		return meta, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.opts.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	meta = &metadata{}
+	if err := p.do(req, meta); err != nil {
+		return nil, fmt.Errorf("read the provider's configuration: %w", err)
+	}
+	if strings.TrimSuffix(meta.Issuer, "/") != p.opts.Issuer {
+		return nil, fmt.Errorf("the provider says its issuer is %q, not %q", meta.Issuer, p.opts.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("the provider's configuration lacks an authorization endpoint, token endpoint or jwks_uri")
+	}
+
+	p.mu.Lock()
+	p.meta = meta
+	p.mu.Unlock()
+	return meta, nil
+}
+
+// userinfo returns the claims the userinfo endpoint gives for accessToken.
+func (p *Provider) userinfo(ctx context.Context, endpoint, accessToken string) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	var info map[string]any
+	err = p.do(req, &info)
+	return info, err
+}
+
+// do sends req and decodes the JSON reply into v.
+func (p *Provider) do(req *http.Request, v any) error {
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var problem struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		if json.Unmarshal(body, &problem) == nil && problem.Error != "" {
+			return fmt.Errorf("%s: %s %s", resp.Status, problem.Error, problem.Description)
+		}
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// verify checks the signature, issuer, audience and lifetime of an ID token
+// and returns its claims.
+func (p *Provider) verify(ctx context.Context, token string, meta *metadata) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("not a JWT")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("signature: %w", err)
+	}
+	key, err := p.key(ctx, meta, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("claims: %w", err)
+	}
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != p.opts.Issuer {
+		return nil, fmt.Errorf("issued by %q, not %q", iss, p.opts.Issuer)
+	}
+	if !hasAudience(claims["aud"], p.opts.ClientID) {
+		return nil, fmt.Errorf("not issued to client %q", p.opts.ClientID)
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New("no expiry")
+	}
+	if now := p.now(); now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return nil, errors.New("expired")
+	}
+	return claims, nil
+}
+
+// hasAudience reports whether aud, a string or list of them, includes
+// clientID.
+func hasAudience(aud any, clientID string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == clientID
+	case []any:
+		for _, a := range aud {
+			if a == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// key returns the provider's key kid, fetching the keys again when it is
+// not among those already known.
+func (p *Provider) key(ctx context.Context, meta *metadata, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	keys, fetched := p.keys, p.keysFetched
+	p.mu.Unlock()
+	if key, ok := lookupKey(keys, kid); ok {
+		return key, nil
+	}
+	if keys != nil && p.now().Sub(fetched) < keysRefetchInterval {
+		return nil, fmt.Errorf("signed with unknown key %q", kid)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, meta.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.do(req, &set); err != nil {
+		return nil, fmt.Errorf("read the provider's keys: %w", err)
+	}
+	keys = map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+
+	p.mu.Lock()
+	p.keys, p.keysFetched = keys, p.now()
+	p.mu.Unlock()
+	if key, ok := lookupKey(keys, kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("signed with unknown key %q", kid)
+}
+
+// lookupKey returns key kid, or the only key when the token names none.
+func lookupKey(keys map[string]crypto.PublicKey, kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+	key, ok := keys[kid]
+	return key, ok
+}
+
+// jwk is a JSON web key, of the kinds providers sign ID tokens with.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	b64 := base64.RawURLEncoding.DecodeString
+	switch k.Kty {
+	case "RSA":
+		n, err := b64(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31 {
+			return nil, errors.New("RSA exponent too large")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := b64(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return ecdsa.ParseUncompressedPublicKey(curve, append(append([]byte{4}, x...), y...))
+	case "OKP":
+		x, err := b64(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if k.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// checkSignature checks signature over signed with key, by algorithm alg.
+func checkSignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512":
+		hash = crypto.SHA512
+	case "EdDSA":
+	default:
+		return fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	var digest []byte
+	if hash != 0 {
+		h := hash.New()
+		h.Write(signed)
+		digest = h.Sum(nil)
+	}
+
+	valid := false
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		valid = strings.HasPrefix(alg, "RS") && rsa.VerifyPKCS1v15(key, hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if strings.HasPrefix(alg, "ES") && len(signature) == 2*size {
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			valid = ecdsa.Verify(key, digest, r, s)
+		}
+	case ed25519.PublicKey:
+		valid = alg == "EdDSA" && ed25519.Verify(key, signed, signature)
+	}
+	if !valid {
+		return errors.New("bad signature")
+	}
+	return nil
+}
+
+// randomString returns 32 random bytes, base64 encoded for a URL.
+func randomString() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,202 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeProvider is an OpenID Connect provider that signs in whoever asks,
+// with the claims in claims, and takes what it is sent from the client.
+type fakeProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]any
+	// userinfo, when set, is what the userinfo endpoint answers.
+	userinfo map[string]any
+
+	// challenge and nonce are those of the last authorization request.
+	challenge, nonce string
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeProvider{t: t, key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 f.server.URL,
+			"authorization_endpoint": f.server.URL + "/authorize",
+			"token_endpoint":         f.server.URL + "/token",
+			"userinfo_endpoint":      f.server.URL + "/userinfo",
+			"jwks_uri":               f.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		b64 := base64.RawURLEncoding.EncodeToString
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "use": "sig",
+			"n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if id != "orangutan" || secret != "s3cret" || r.FormValue("code") != "the-code" ||
+			base64.RawURLEncoding.EncodeToString(sum[:]) != f.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		claims := map[string]any{
+			"iss": f.server.URL, "aud": "orangutan", "sub": "u-1", "nonce": f.nonce,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+		for k, v := range f.claims {
+			claims[k] = v
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "at", "id_token": f.sign(claims)})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if f.userinfo == nil || r.Header.Get("Authorization") != "Bearer at" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(f.userinfo)
+	})
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// sign returns claims as an ID token signed with the provider's key.
+func (f *fakeProvider) sign(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, f.key, crypto.SHA256, digest[:])
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// signIn begins a sign in with p, plays the browser at the provider, and
+// finishes it.
+func (f *fakeProvider) signIn(p *Provider) (Identity, error) {
+	authURL, state, err := p.Begin(context.Background(), "https://orangutan.example/login/sso/callback")
+	if err != nil {
+		return Identity{}, err
+	}
+	u, _ := url.Parse(authURL)
+	q := u.Query()
+	if q.Get("state") != state || q.Get("code_challenge_method") != "S256" || q.Get("client_id") != "orangutan" {
+		f.t.Fatalf("authorization URL %s", authURL)
+	}
+	f.challenge, f.nonce = q.Get("code_challenge"), q.Get("nonce")
+	return p.Finish(context.Background(), state, "the-code")
+}
+
+func newProvider(f *fakeProvider) *Provider {
+	return New(Options{Issuer: f.server.URL + "/", ClientID: "orangutan", ClientSecret: "s3cret"})
+}
+
+func TestSignIn(t *testing.T) {
+	f := newFakeProvider(t)
+	f.claims = map[string]any{"preferred_username": "alice", "groups": []string{"admins", "staff"}}
+	id, err := f.signIn(newProvider(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Subject != "u-1" || id.Username != "alice" || !slices.Equal(id.Groups, []string{"admins", "staff"}) {
+		t.Errorf("identity = %+v", id)
+	}
+}
+
+func TestSignInGroupsFromUserinfo(t *testing.T) {
+	f := newFakeProvider(t)
+	f.claims = map[string]any{"email": "bob@example.com"}
+	f.userinfo = map[string]any{"sub": "u-1", "groups": []string{"staff"}}
+	id, err := f.signIn(newProvider(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Username != "bob@example.com" || !slices.Equal(id.Groups, []string{"staff"}) {
+		t.Errorf("identity = %+v", id)
+	}
+}
+
+func TestSignInRejectsBadTokens(t *testing.T) {
+	for name, claims := range map[string]map[string]any{
+		"wrong audience": {"aud": "someone-else"},
+		"wrong issuer":   {"iss": "https://evil.example"},
+		"expired":        {"exp": time.Now().Add(-time.Hour).Unix()},
+		"wrong nonce":    {"nonce": "replayed"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			f := newFakeProvider(t)
+			f.claims = claims
+			if id, err := f.signIn(newProvider(f)); err == nil {
+				t.Errorf("signed in as %+v", id)
+			}
+		})
+	}
+}
+
+func TestSignInRejectsForgedSignature(t *testing.T) {
+	f := newFakeProvider(t)
+	p := newProvider(f)
+	meta, err := p.metadata(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sign with another key, as someone without the provider's would. The
+	// provider still publishes its own.
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.key = other
+	token := f.sign(map[string]any{"iss": f.server.URL, "aud": "orangutan", "exp": time.Now().Add(time.Hour).Unix()})
+	if _, err := p.verify(context.Background(), token, meta); err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Errorf("verify = %v; want a bad signature", err)
+	}
+}
+
+func TestFinishUnknownState(t *testing.T) {
+	f := newFakeProvider(t)
+	if _, err := newProvider(f).Finish(context.Background(), "never-begun", "the-code"); err == nil {
+		t.Error("Finish accepted a state it never issued")
+	}
+}
+
+func TestValidIssuer(t *testing.T) {
+	for u, valid := range map[string]bool{
+		"https://auth.example.com":              true,
+		"https://sso.example.com/realms/office": true,
+		"ftp://auth.example.com":                false,
+		"auth.example.com":                      false,
+	} {
+		if err := ValidIssuer(u); (err == nil) != valid {
+			t.Errorf("ValidIssuer(%q) = %v; want valid %v", u, err, valid)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+// Package openwrt asks an OpenWrt router about the devices on its network:
+// the leases of its DHCP server, the names it knows, and which devices are
+// associated with each of its Wi-Fi networks, on what band and how strongly
+// it hears them. The last is what a scan cannot see, and what answers "why
+// is the TV's Wi-Fi so bad".
+//
+// It speaks ubus over JSON-RPC at /ubus, as the LuCI web interface does,
+// which needs the uhttpd-mod-ubus and rpcd-mod-luci packages that LuCI
+// installs.
+package openwrt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// requestTimeout bounds each request, so that a router that has gone away
+// does not hold up the scan waiting on it.
+const requestTimeout = 10 * time.Second
+
+// DefaultUsername is who logs in unless told otherwise.
+const DefaultUsername = "root"
+
+// noSession is the session ubus calls are made in before logging in.
+const noSession = "00000000000000000000000000000000"
+
+// Options say which router to ask.
+type Options struct {
+	// URL is the address of its web interface, such as http://192.168.1.1.
+	URL      string
+	Username string
+	Password string
+}
+
+// Host is a device the router knows of.
+type Host struct {
+	IP       string
+	MAC      string
+	Hostname string
+	// Wireless is set for a device associated with one of the router's
+	// access points now.
+	Wireless *types.Wireless
+}
+
+// Client asks an OpenWrt router about the devices it knows.
+type Client struct {
+	opts Options
+	http *http.Client
+	// now is the clock, replaced in tests.
+	now func() time.Time
+}
+
+// New returns a client asking the router opts describe.
+func New(opts Options) *Client {
+	if opts.Username == "" {
+		opts.Username = DefaultUsername
+	}
+	return &Client{opts: opts, http: &http.Client{Timeout: requestTimeout}, now: time.Now}
+}
+
+// ValidURL checks that u is an http or https URL the router can be reached
+// at.
+func ValidURL(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("%q is not an http or https URL", u)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("%q has no host", u)
+	}
+	return nil
+}
+
+// Band returns the Wi-Fi band of frequency, in MHz.
+func Band(frequency int) string {
+	switch {
+	case frequency <= 0:
+		return ""
+	case frequency < 3000:
+		return "2.4GHz"
+	case frequency < 5925:
+		return "5GHz"
+	case frequency < 7200:
+		return "6GHz"
+	default:
+		return "60GHz"
+	}
+}
+
+// The parts of ubus's replies that are used.
+type (
+	loginReply struct {
+		Session string `json:"ubus_rpc_session"`
+	}
+	boardReply struct {
+		Hostname string `json:"hostname"`
+	}
+	leasesReply struct {
+		Leases []struct {
+			Hostname string `json:"hostname"`
+			MAC      string `json:"macaddr"`
+			IP       string `json:"ipaddr"`
+		} `json:"dhcp_leases"`
+	}
+	// hintsReply is keyed by MAC address.
+	hintsReply map[string]struct {
+		Name    string   `json:"name"`
+		IPAddrs []string `json:"ipaddrs"`
+	}
+	devicesReply struct {
+		Devices []string `json:"devices"`
+	}
+	infoReply struct {
+		SSID      string `json:"ssid"`
+		Mode      string `json:"mode"`
+		Channel   int    `json:"channel"`
+		Frequency int    `json:"frequency"`
+	}
+	assocReply struct {
+		Results []struct {
+			MAC    string `json:"mac"`
+			Signal int    `json:"signal"`
+		} `json:"results"`
+	}
+)
+
+// Hosts returns the devices the router knows, one for each address, in
+// address order. Devices associated with its Wi-Fi but with no address
+// known are left out.
+func (c *Client) Hosts(ctx context.Context) ([]Host, error) {
+	session, err := c.login(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.logout(session)
+
+	var leases leasesReply
+	if err := c.call(ctx, session, "luci-rpc", "getDHCPLeases", nil, &leases); err != nil {
+		if errors.Is(err, errNotFound) {
+			return nil, fmt.Errorf("%w; install rpcd-mod-luci, or LuCI, which brings it", err)
+		}
+		return nil, err
+	}
+	var hints hintsReply
+	if err := c.call(ctx, session, "luci-rpc", "getHostHints", nil, &hints); err != nil {
+		return nil, err
+	}
+	stations, err := c.stations(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+
+	byIP := make(map[string]*Host)
+	add := func(ip, mac, name string) {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil || !addr.Is4() {
+			return
+		}
+		h, ok := byIP[addr.String()]
+		if !ok {
+			h = &Host{IP: addr.String()}
+			byIP[h.IP] = h
+		}
+		if h.MAC == "" {
+			h.MAC = strings.ToUpper(mac)
+		}
+		if h.Hostname == "" {
+			h.Hostname = name
+		}
+	}
+	for _, l := range leases.Leases {
+		add(l.IP, l.MAC, l.Hostname)
+	}
+	for mac, hint := range hints {
+		for _, ip := range hint.IPAddrs {
+			add(ip, mac, hint.Name)
+		}
+	}
+	for _, h := range byIP {
+		if w, ok := stations[h.MAC]; ok {
+			h.Wireless = &w
+		}
+	}
+
+	hosts := make([]Host, 0, len(byIP))
+	for _, h := range byIP {
+		hosts = append(hosts, *h)
+	}
+	slices.SortFunc(hosts, func(a, b Host) int {
+		x, _ := netip.ParseAddr(a.IP)
+		y, _ := netip.ParseAddr(b.IP)
+		return x.Compare(y)
+	})
+	return hosts, nil
+}
+
+// stations returns how each device associated with one of the router's
+// access points is connected, by MAC address in capitals.
+func (c *Client) stations(ctx context.Context, session string) (map[string]types.Wireless, error) {
+	router := c.routerName(ctx, session)
+	var radios devicesReply
+	if err := c.call(ctx, session, "iwinfo", "devices", nil, &radios); err != nil {
+		// A router without Wi-Fi has no iwinfo to ask.
+		if errors.Is(err, errNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	now := c.now()
+	stations := make(map[string]types.Wireless)
+	for _, dev := range radios.Devices {
+		var info infoReply
+		if err := c.call(ctx, session, "iwinfo", "info", map[string]string{"device": dev}, &info); err != nil {
+			return nil, err
+		}
+		// Only access points have stations of their own; a radio in client
+		// or mesh mode is connected to something else.
+		if info.Mode != "Master" {
+			continue
+		}
+		var assoc assocReply
+		if err := c.call(ctx, session, "iwinfo", "assoclist", map[string]string{"device": dev}, &assoc); err != nil {
+			return nil, err
+		}
+		for _, s := range assoc.Results {
+			mac := strings.ToUpper(s.MAC)
+			// A device roaming between two radios is on the one hearing it
+			// better.
+			if prev, ok := stations[mac]; ok && prev.Signal >= s.Signal {
+				continue
+			}
+			stations[mac] = types.Wireless{
+				SSID:        info.SSID,
+				Band:        Band(info.Frequency),
+				Channel:     info.Channel,
+				Signal:      s.Signal,
+				AccessPoint: router + "/" + dev,
+				Seen:        now,
+			}
+		}
+	}
+	return stations, nil
+}
+
+// routerName returns the router's hostname, or the host of its URL when it
+// will not say.
+func (c *Client) routerName(ctx context.Context, session string) string {
+	var board boardReply
+	if err := c.call(ctx, session, "system", "board", nil, &board); err == nil && board.Hostname != "" {
+		return board.Hostname
+	}
+	if u, err := url.Parse(c.opts.URL); err == nil {
+		return u.Hostname()
+	}
+	return c.opts.URL
+}
+
+// Name implements scanner.Source.
+func (c *Client) Name() string { return "openwrt" }
+
+// Devices returns the hosts in network as devices. Those associated with
+// the router's Wi-Fi now have LastSeen set; a lease alone does not count,
+// as it outlasts the visit by hours.
+func (c *Client) Devices(ctx context.Context, network *net.IPNet) ([]types.Device, error) {
+	hosts, err := c.Hosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return HostDevices(hosts, network), nil
+}
+
+// HostDevices returns the hosts in network, or all of them when network is
+// nil, as devices.
+func HostDevices(hosts []Host, network *net.IPNet) []types.Device {
+	var devices []types.Device
+	for _, h := range hosts {
+		if network != nil && !network.Contains(net.ParseIP(h.IP)) {
+			continue
+		}
+		d := types.Device{IP: h.IP, MAC: h.MAC, Hostname: h.Hostname, Wireless: h.Wireless}
+		if h.Wireless != nil {
+			d.FirstSeen, d.LastSeen = h.Wireless.Seen, h.Wireless.Seen
+		}
+		devices = append(devices, d)
+	}
+	return devices
+}
+
+// ubus status codes that say more than "failed".
+const (
+	statusNotFound         = 4
+	statusPermissionDenied = 6
+)
+
+// login starts a session with the router and returns its ID.
+func (c *Client) login(ctx context.Context) (string, error) {
+	var reply loginReply
+	err := c.call(ctx, noSession, "session", "login",
+		map[string]string{"username": c.opts.Username, "password": c.opts.Password}, &reply)
+	if errors.Is(err, errPermissionDenied) {
+		return "", errors.New("the router refused the username and password")
+	}
+	if err != nil {
+		return "", err
+	}
+	return reply.Session, nil
+}
+
+// logout ends session, so that sessions do not pile up on the router until
+// they time out. It is best effort: the caller has what it came for.
+func (c *Client) logout(session string) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	_ = c.call(ctx, session, "session", "destroy", map[string]string{"ubus_rpc_session": session}, nil)
+}
+
+// Errors call returns for ubus statuses that callers tell apart.
+var (
+	// errPermissionDenied is for a session that may not make the call.
+	errPermissionDenied = errors.New("permission denied")
+	// errNotFound is for an object or method the router does not have.
+	errNotFound = errors.New("not found")
+)
+
+// call calls method of object over ubus in session, and decodes the answer
+// into reply unless it is nil.
+func (c *Client) call(ctx context.Context, session, object, method string, args any, reply any) error {
+	if args == nil {
+		args = struct{}{}
+	}
+	body, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "call",
+		"params":  []any{session, object, method, args},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.opts.URL, "/")+"/ubus", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errors.New("the router has no /ubus; install uhttpd-mod-ubus, or LuCI, which brings it")
+	}
+	if resp.StatusCode/100 != 2 {
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("the router answered %s: %s", resp.Status, strings.TrimSpace(string(text)))
+	}
+
+	var answer struct {
+		Result []json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return fmt.Errorf("reading the router's answer to %s %s: %w", object, method, err)
+	}
+	if answer.Error != nil {
+		// -32002 is an expired session or one with no access at all.
+		if answer.Error.Code == -32002 {
+			return fmt.Errorf("%s %s: %w", object, method, errPermissionDenied)
+		}
+		return fmt.Errorf("%s %s: %s", object, method, answer.Error.Message)
+	}
+	if len(answer.Result) == 0 {
+		return fmt.Errorf("%s %s: empty answer", object, method)
+	}
+	var status int
+	if err := json.Unmarshal(answer.Result[0], &status); err != nil {
+		return fmt.Errorf("reading the router's answer to %s %s: %w", object, method, err)
+	}
+	switch status {
+	case 0:
+	case statusPermissionDenied:
+		return fmt.Errorf("%s %s: %w", object, method, errPermissionDenied)
+	case statusNotFound:
+		return fmt.Errorf("%s %s: %w", object, method, errNotFound)
+	default:
+		return fmt.Errorf("%s %s: ubus status %d", object, method, status)
+	}
+	if reply == nil || len(answer.Result) < 2 {
+		return nil
+	}
+	if err := json.Unmarshal(answer.Result[1], reply); err != nil {
+		return fmt.Errorf("reading the router's answer to %s %s: %w", object, method, err)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package openwrt
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// fakeRouter answers ubus calls like OpenWrt for the login root:s3cret,
+// with two radios serving the same SSID, and counts the sessions still
+// open.
+func fakeRouter(t *testing.T) (*httptest.Server, *int) {
+	t.Helper()
+	open := 0
+	replies := map[string]any{
+		"system board": map[string]any{"hostname": "attic"},
+		"luci-rpc getDHCPLeases": map[string]any{"dhcp_leases": []map[string]any{
+			{"hostname": "phone", "macaddr": "aa:bb:cc:00:00:05", "ipaddr": "192.168.1.5"},
+			{"hostname": "laptop", "macaddr": "aa:bb:cc:00:00:06", "ipaddr": "192.168.1.6"},
+		}},
+		"luci-rpc getHostHints": map[string]any{
+			"AA:BB:CC:00:00:05": map[string]any{"name": "phone.lan", "ipaddrs": []string{"192.168.1.5"}, "ip6addrs": []string{"fd00::5"}},
+			"AA:BB:CC:00:00:07": map[string]any{"name": "nas", "ipaddrs": []string{"192.168.1.7"}},
+		},
+		"iwinfo devices": map[string]any{"devices": []string{"phy0-ap0", "phy1-ap0", "phy1-sta0"}},
+	}
+	radios := map[string]map[string]any{
+		"phy0-ap0":  {"ssid": "home", "mode": "Master", "channel": 6, "frequency": 2437},
+		"phy1-ap0":  {"ssid": "home", "mode": "Master", "channel": 36, "frequency": 5180},
+		"phy1-sta0": {"ssid": "upstream", "mode": "Client", "channel": 36, "frequency": 5180},
+	}
+	assoc := map[string][]map[string]any{
+		"phy0-ap0": {{"mac": "aa:bb:cc:00:00:05", "signal": -71}, {"mac": "aa:bb:cc:00:00:08", "signal": -60}},
+		"phy1-ap0": {{"mac": "aa:bb:cc:00:00:05", "signal": -58}},
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ubus" || r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			Params []json.RawMessage `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var session, object, method string
+		var args map[string]string
+		json.Unmarshal(req.Params[0], &session)
+		json.Unmarshal(req.Params[1], &object)
+		json.Unmarshal(req.Params[2], &method)
+		json.Unmarshal(req.Params[3], &args)
+		answer := func(result ...any) {
+			json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": 1, "result": result})
+		}
+
+		switch {
+		case object == "session" && method == "login":
+			if args["username"] != "root" || args["password"] != "s3cret" {
+				answer(6)
+				return
+			}
+			open++
+			answer(0, map[string]any{"ubus_rpc_session": "sid1"})
+			return
+		case session != "sid1":
+			json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": 1, "error": map[string]any{"code": -32002, "message": "Access denied"}})
+			return
+		case object == "session" && method == "destroy":
+			open--
+			answer(0)
+			return
+		case object == "iwinfo" && method == "info":
+			answer(0, radios[args["device"]])
+			return
+		case object == "iwinfo" && method == "assoclist":
+			answer(0, map[string]any{"results": assoc[args["device"]]})
+			return
+		}
+		reply, ok := replies[object+" "+method]
+		if !ok {
+			answer(4)
+			return
+		}
+		answer(0, reply)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &open
+}
+
+func TestHosts(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	srv, open := fakeRouter(t)
+	c := New(Options{URL: srv.URL + "/", Password: "s3cret"})
+	c.now = func() time.Time { return now }
+
+	hosts, err := c.Hosts(context.Background())
+	if err != nil {
+		t.Fatalf("Hosts: %v", err)
+	}
+	if *open != 0 {
+		t.Errorf("%d sessions left open", *open)
+	}
+	if len(hosts) != 3 || hosts[0].IP != "192.168.1.5" || hosts[1].IP != "192.168.1.6" || hosts[2].IP != "192.168.1.7" {
+		t.Fatalf("hosts = %+v", hosts)
+	}
+	phone := hosts[0]
+	if phone.MAC != "AA:BB:CC:00:00:05" || phone.Hostname != "phone" {
+		t.Errorf("phone = %+v", phone)
+	}
+	// Heard on both radios, it is on the one hearing it better.
+	want := types.Wireless{SSID: "home", Band: "5GHz", Channel: 36, Signal: -58, AccessPoint: "attic/phy1-ap0", Seen: now}
+	if phone.Wireless == nil || *phone.Wireless != want {
+		t.Errorf("phone wireless = %+v, want %+v", phone.Wireless, want)
+	}
+	if hosts[1].Wireless != nil || hosts[2].Wireless != nil || hosts[2].Hostname != "nas" {
+		t.Errorf("hosts = %+v", hosts[1:])
+	}
+}
+
+func TestDevices(t *testing.T) {
+	srv, _ := fakeRouter(t)
+	_, network, _ := net.ParseCIDR("192.168.1.0/28")
+	devices, err := New(Options{URL: srv.URL, Password: "s3cret"}).Devices(context.Background(), network)
+	if err != nil {
+		t.Fatalf("Devices: %v", err)
+	}
+	if len(devices) != 3 {
+		t.Fatalf("devices = %+v", devices)
+	}
+	for _, d := range devices {
+		// Only being on Wi-Fi now counts as seen, not a lease.
+		if seen := !d.LastSeen.IsZero(); seen != (d.IP == "192.168.1.5") {
+			t.Errorf("%s seen = %v", d.IP, seen)
+		}
+	}
+	_, elsewhere, _ := net.ParseCIDR("10.0.0.0/8")
+	if devices := HostDevices([]Host{{IP: "192.168.1.5"}}, elsewhere); len(devices) != 0 {
+		t.Errorf("devices outside the network = %+v", devices)
+	}
+}
+
+func TestWrongPassword(t *testing.T) {
+	srv, _ := fakeRouter(t)
+	_, err := New(Options{URL: srv.URL, Password: "guess"}).Hosts(context.Background())
+	if err == nil || err.Error() != "the router refused the username and password" {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestBand(t *testing.T) {
+	for freq, want := range map[int]string{0: "", 2412: "2.4GHz", 5180: "5GHz", 5885: "5GHz", 5955: "6GHz", 60480: "60GHz"} {
+		if got := Band(freq); got != want {
+			t.Errorf("Band(%d) = %q, want %q", freq, got, want)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+// Package pihole reads what a Pi-hole knows about the devices on the
+// network: the names and MAC addresses from its DHCP leases and network
+// table, when each last sent a DNS query, and which of its groups each
+// client is in. Scans use it to name devices that reverse DNS could not and
+// to find devices that ignored the sweep.
+//
+// It speaks the REST API of Pi-hole v6.
+package pihole
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// requestTimeout bounds each request, so that a Pi-hole that has gone away
+// does not hold up the scan waiting on it.
+const requestTimeout = 10 * time.Second
+
+// activeWindow is how recently a device must have sent Pi-hole a query to
+// count as present. A lease is no help here: it outlives the device's
+// visit by up to a day.
+const activeWindow = 10 * time.Minute
+
+// GroupTagPrefix starts the tag given to a device for each Pi-hole group its
+// client is in, as in "pihole:kids".
+const GroupTagPrefix = "pihole:"
+
+// Options say which Pi-hole to ask.
+type Options struct {
+	// URL is the address of the Pi-hole's web interface, such as
+	// http://pi.hole.
+	URL string
+	// Token is an app password made in the Pi-hole's settings for API
+	// access, or its web interface password. Empty means it has none.
+	Token string
+	// GroupTags tags each device with the groups its client is in, other
+	// than the Default group every client is in.
+	GroupTags bool
+}
+
+// Client asks a Pi-hole about the devices it knows.
+type Client struct {
+	opts Options
+	http *http.Client
+	// now is the clock, replaced in tests.
+	now func() time.Time
+}
+
+// New returns a client asking the Pi-hole opts describe.
+func New(opts Options) *Client {
+	return &Client{opts: opts, http: &http.Client{Timeout: requestTimeout}, now: time.Now}
+}
+
+// ValidURL checks that u is an http or https URL a Pi-hole can be reached
+// at.
+func ValidURL(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("%q is not an http or https URL", u)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("%q has no host", u)
+	}
+	return nil
+}
+
+// Name implements scanner.Source.
+func (c *Client) Name() string { return "pihole" }
+
+// Devices returns the devices in network that the Pi-hole knows, named
+// from its DHCP leases and network table. Those that sent a query in the
+// last few minutes have LastSeen set.
+func (c *Client) Devices(ctx context.Context, network *net.IPNet) ([]types.Device, error) {
+	sid, err := c.login(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Pi-hole allows only so many sessions at once, so give this one back
+	// rather than wait for it to expire.
+	defer c.logout(sid)
+
+	var leases leasesReply
+	if err := c.get(ctx, sid, "/api/dhcp/leases", &leases); err != nil {
+		return nil, err
+	}
+	var table networkReply
+	if err := c.get(ctx, sid, "/api/network/devices?max_devices=1000&max_addresses=8", &table); err != nil {
+		return nil, err
+	}
+	var groups map[string][]string
+	if c.opts.GroupTags {
+		if groups, err = c.clientGroups(ctx, sid); err != nil {
+			return nil, err
+		}
+	}
+	return merge(network, leases, table, groups, c.now()), nil
+}
+
+// The parts of the Pi-hole API's replies that are used.
+type (
+	authReply struct {
+		Session struct {
+			Valid bool   `json:"valid"`
+			SID   string `json:"sid"`
+		} `json:"session"`
+	}
+	leasesReply struct {
+		Leases []struct {
+			IP     string `json:"ip"`
+			Name   string `json:"name"`
+			HWAddr string `json:"hwaddr"`
+		} `json:"leases"`
+	}
+	networkReply struct {
+		Devices []struct {
+			HWAddr    string `json:"hwaddr"`
+			MACVendor string `json:"macVendor"`
+			LastQuery int64  `json:"lastQuery"`
+			IPs       []struct {
+				IP       string `json:"ip"`
+				Name     string `json:"name"`
+				LastSeen int64  `json:"lastSeen"`
+			} `json:"ips"`
+		} `json:"devices"`
+	}
+	clientsReply struct {
+		Clients []struct {
+			Client string `json:"client"`
+			Groups []int  `json:"groups"`
+		} `json:"clients"`
+	}
+	groupsReply struct {
+		Groups []struct {
+			ID   int    `json:"id"`
+			Name string `json:"name"`
+		} `json:"groups"`
+	}
+)
+
+// defaultGroup is the ID of the group every client is in.
+const defaultGroup = 0
+
+// merge returns the devices in network from a Pi-hole's leases and network
+// table, tagged with the groups of their clients, which are keyed by IP
+// address or upper-case MAC address.
+func merge(network *net.IPNet, leases leasesReply, table networkReply, groups map[string][]string, now time.Time) []types.Device {
+	var devices []types.Device
+	byIP := make(map[string]int)
+	at := func(ip string) *types.Device {
+		if i, ok := byIP[ip]; ok {
+			return &devices[i]
+		}
+		parsed := net.ParseIP(ip)
+		if parsed == nil || !network.Contains(parsed) {
+			return nil
+		}
+		byIP[ip] = len(devices)
+		devices = append(devices, types.Device{IP: ip})
+		return &devices[len(devices)-1]
+	}
+
+	for _, l := range leases.Leases {
+		d := at(l.IP)
+		if d == nil {
+			continue
+		}
+		d.Hostname = hostname(l.Name)
+		if mac(l.HWAddr) != "" {
+			d.MAC = mac(l.HWAddr)
+		}
+	}
+	for _, nd := range table.Devices {
+		for _, a := range nd.IPs {
+			d := at(a.IP)
+			if d == nil {
+				continue
+			}
+			if d.Hostname == "" {
+				d.Hostname = hostname(a.Name)
+			}
+			if d.MAC == "" {
+				d.MAC = mac(nd.HWAddr)
+			}
+			if d.Vendor == "" {
+				d.Vendor = nd.MACVendor
+			}
+			// Several addresses share a device's lastQuery, and only the
+			// one it asked from lately is still its own.
+			last := time.Unix(min(nd.LastQuery, a.LastSeen), 0)
+			if nd.LastQuery > 0 && a.LastSeen > 0 && now.Sub(last) < activeWindow && last.After(d.LastSeen) {
+				d.LastSeen = last
+			}
+		}
+	}
+
+	for i := range devices {
+		d := &devices[i]
+		for _, name := range slices.Concat(groups[d.IP], groups[strings.ToUpper(d.MAC)]) {
+			d.AddTag(GroupTagPrefix + name)
+		}
+	}
+	return devices
+}
+
+// clientGroups returns the names of the groups each client is in, other
+// than the Default group, keyed by the client's IP address or upper-case
+// MAC address. Clients given as a hostname or a subnet are left out.
+func (c *Client) clientGroups(ctx context.Context, sid string) (map[string][]string, error) {
+	var clients clientsReply
+	if err := c.get(ctx, sid, "/api/clients", &clients); err != nil {
+		return nil, err
+	}
+	var groups groupsReply
+	if err := c.get(ctx, sid, "/api/groups", &groups); err != nil {
+		return nil, err
+	}
+	names := make(map[int]string, len(groups.Groups))
+	for _, g := range groups.Groups {
+		names[g.ID] = g.Name
+	}
+
+	byClient := make(map[string][]string)
+	for _, cl := range clients.Clients {
+		key := cl.Client
+		if net.ParseIP(key) == nil {
+			if mac(key) == "" {
+				continue
+			}
+			key = strings.ToUpper(key)
+		}
+		for _, id := range cl.Groups {
+			if name := names[id]; id != defaultGroup && name != "" {
+				byClient[key] = append(byClient[key], name)
+			}
+		}
+	}
+	return byClient, nil
+}
+
+// hostname returns name unless it is Pi-hole's placeholder for none.
+func hostname(name string) string {
+	if name == "*" {
+		return ""
+	}
+	return name
+}
+
+// mac returns hwaddr if it is a MAC address. Pi-hole's network table also
+// holds devices it only knows by address, as "ip-192.168.1.5".
+func mac(hwaddr string) string {
+	if _, err := net.ParseMAC(hwaddr); err != nil {
+		return ""
+	}
+	return hwaddr
+}
+
+// login starts a session and returns its ID, which is empty when the
+// Pi-hole has no password.
+func (c *Client) login(ctx context.Context) (string, error) {
+	body, _ := json.Marshal(map[string]string{"password": c.opts.Token})
+	var reply authReply
+	if err := c.do(ctx, http.MethodPost, "/api/auth", "", bytes.NewReader(body), &reply); err != nil {
+		return "", err
+	}
+	if !reply.Session.Valid {
+		return "", errors.New("Pi-hole refused the password")
+	}
+	return reply.Session.SID, nil
+}
+
+// logout ends the session sid. Failing to is not worth reporting: the
+// session expires anyway.
+func (c *Client) logout(sid string) {
+	if sid == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	_ = c.do(ctx, http.MethodDelete, "/api/auth", sid, nil, nil)
+}
+
+func (c *Client) get(ctx context.Context, sid, path string, reply any) error {
+	return c.do(ctx, http.MethodGet, path, sid, nil, reply)
+}
+
+// do makes a request of the API in session sid and decodes the answer into
+// reply, unless that is nil.
+func (c *Client) do(ctx context.Context, method, path, sid string, body io.Reader, reply any) error {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.opts.URL, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if sid != "" {
+		req.Header.Set("X-FTL-SID", sid)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("Pi-hole answered %s%s", resp.Status, reason(resp.Body))
+	}
+	if reply == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(reply); err != nil {
+		return fmt.Errorf("reading Pi-hole's answer to %s: %w", path, err)
+	}
+	return nil
+}
+
+// reason returns the message of an error Pi-hole sent, as ": " and the
+// message, or "" when there is none.
+func reason(body io.Reader) string {
+	var e struct {
+		Error struct {
+			Message string `json:"message"`
+			Hint    string `json:"hint"`
+		} `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(body, 4096))
+	if json.Unmarshal(data, &e) != nil || e.Error.Message == "" {
+		return ""
+	}
+	if e.Error.Hint != "" {
+		return ": " + e.Error.Message + " (" + e.Error.Hint + ")"
+	}
+	return ": " + e.Error.Message
+}
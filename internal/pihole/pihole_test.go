@@ -0,0 +1,125 @@
+package pihole
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakePihole answers like Pi-hole v6 for the password "s3cret", and counts
+// the sessions still open.
+func fakePihole(t *testing.T, now time.Time) (*httptest.Server, *int) {
+	t.Helper()
+	open := 0
+	recent, stale := now.Add(-2*time.Minute).Unix(), now.Add(-time.Hour).Unix()
+	replies := map[string]any{
+		"/api/dhcp/leases": map[string]any{"leases": []map[string]any{
+			{"ip": "192.168.1.5", "name": "phone", "hwaddr": "aa:bb:cc:00:00:05"},
+			{"ip": "192.168.1.6", "name": "*", "hwaddr": "aa:bb:cc:00:00:06"},
+			{"ip": "10.0.0.9", "name": "elsewhere", "hwaddr": "aa:bb:cc:00:00:09"},
+		}},
+		"/api/network/devices": map[string]any{"devices": []map[string]any{
+			{"hwaddr": "aa:bb:cc:00:00:05", "macVendor": "Apple", "lastQuery": recent, "ips": []map[string]any{
+				{"ip": "192.168.1.5", "name": "phone.lan", "lastSeen": recent},
+				{"ip": "192.168.1.50", "name": "", "lastSeen": stale},
+			}},
+			{"hwaddr": "ip-192.168.1.7", "lastQuery": stale, "ips": []map[string]any{
+				{"ip": "192.168.1.7", "name": "tv.lan", "lastSeen": stale},
+			}},
+		}},
+		"/api/clients": map[string]any{"clients": []map[string]any{
+			{"client": "192.168.1.5", "groups": []int{0, 2}},
+			{"client": "AA:BB:CC:00:00:06", "groups": []int{3}},
+			{"client": "laptop.lan", "groups": []int{2}},
+		}},
+		"/api/groups": map[string]any{"groups": []map[string]any{
+			{"id": 0, "name": "Default"}, {"id": 2, "name": "kids"}, {"id": 3, "name": "iot"},
+		}},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/auth" {
+			switch r.Method {
+			case http.MethodPost:
+				var body struct{ Password string }
+				json.NewDecoder(r.Body).Decode(&body)
+				if body.Password != "s3cret" {
+					w.WriteHeader(http.StatusUnauthorized)
+					json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"key": "unauthorized", "message": "Unauthorized"}})
+					return
+				}
+				open++
+				json.NewEncoder(w).Encode(map[string]any{"session": map[string]any{"valid": true, "sid": "sid1"}})
+			case http.MethodDelete:
+				if r.Header.Get("X-FTL-SID") == "sid1" {
+					open--
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}
+			return
+		}
+		if r.Header.Get("X-FTL-SID") != "sid1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		reply, ok := replies[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(reply)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &open
+}
+
+func TestDevices(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	srv, open := fakePihole(t, now)
+	c := New(Options{URL: srv.URL + "/", Token: "s3cret", GroupTags: true})
+	_, network, _ := net.ParseCIDR("192.168.1.0/24")
+
+	devices, err := c.Devices(context.Background(), network)
+	if err != nil {
+		t.Fatalf("Devices: %v", err)
+	}
+	if *open != 0 {
+		t.Errorf("%d sessions left open", *open)
+	}
+	got := make(map[string]string)
+	for _, d := range devices {
+		got[d.IP] = d.Hostname
+	}
+	if len(devices) != 4 || got["192.168.1.5"] != "phone" || got["192.168.1.6"] != "" || got["192.168.1.7"] != "tv.lan" {
+		t.Fatalf("devices = %+v", devices)
+	}
+	phone := devices[0]
+	if phone.MAC != "aa:bb:cc:00:00:05" || phone.Vendor != "Apple" || !phone.LastSeen.Equal(now.Add(-2*time.Minute)) {
+		t.Errorf("phone = %+v", phone)
+	}
+	if !slices.Equal(phone.Tags, []string{"pihole:kids"}) || !slices.Equal(devices[1].Tags, []string{"pihole:iot"}) {
+		t.Errorf("tags = %v, %v", phone.Tags, devices[1].Tags)
+	}
+	for _, d := range devices[1:] {
+		// Stale, or only known from a lease.
+		if !d.LastSeen.IsZero() {
+			t.Errorf("%s counts as active", d.IP)
+		}
+	}
+	if devices[3].MAC != "" {
+		t.Errorf("MAC = %q, want none for a device known by address only", devices[3].MAC)
+	}
+}
+
+func TestDevicesWrongPassword(t *testing.T) {
+	srv, _ := fakePihole(t, time.Now())
+	_, network, _ := net.ParseCIDR("192.168.1.0/24")
+	_, err := New(Options{URL: srv.URL, Token: "guess"}).Devices(context.Background(), network)
+	if err == nil || err.Error() != "Pi-hole answered 401 Unauthorized: Unauthorized" {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,338 @@
+// Package query parses and evaluates device searches such as
+//
+//	vendor:raspberry last_seen<7d -has:label
+//
+// The same language is accepted by `orangutan search` and by the API's
+// GET /api/devices?q=, so a search worked out in one can be pasted into the
+// other.
+//
+// A query is a list of terms separated by spaces, all of which must match:
+//
+//	word            any text field contains word
+//	field:value     the field contains value (tag: must match a whole tag)
+//	ip:10.0.0.0/8   the address is in the network
+//	status:online   seen in the last hour; status:offline for the rest
+//	status:pending  waiting to be approved; status:approved for the rest
+//	status:watched  on the watchlist, pinged between scans
+//	has:field       the field is not empty
+//	field<age       first_seen or last_seen is less than age ago (30m, 12h, 7d, 2w)
+//	field>age       ... more than age ago
+//	field>date      ... after a date such as 2026-01-31, and < for before
+//	-term           the term does not match
+//
+// Matching ignores case, and values containing spaces are quoted:
+// label:"living room".
+package query
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/291-Group/LAN-Orangutan/internal/scanner"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// Query is a parsed search. The zero Query matches every device.
+type Query struct {
+	terms []term
+}
+
+// term is one condition of a query.
+type term struct {
+	negate bool
+	match  func(d *types.Device, now time.Time) bool
+}
+
+// textFields gets the text fields a query can name, under each of their
+// names. Vendor is resolved from the MAC address as it is for display, so a
+// search finds what the user sees.
+var textFields = map[string]func(d *types.Device) string{
+	"ip":       func(d *types.Device) string { return d.IP },
+	"mac":      func(d *types.Device) string { return d.MAC },
+	"hostname": func(d *types.Device) string { return d.Hostname },
+	"host":     func(d *types.Device) string { return d.Hostname },
+	"vendor":   func(d *types.Device) string { return scanner.ResolveVendor(d.Vendor, d.MAC) },
+	"label":    func(d *types.Device) string { return d.Label },
+	"name":     func(d *types.Device) string { return d.Label },
+	"group":    func(d *types.Device) string { return d.Group },
+	"notes":    func(d *types.Device) string { return d.Notes },
+	"type":     func(d *types.Device) string { return d.Type },
+	"tag":      func(d *types.Device) string { return strings.Join(d.Tags, " ") },
+}
+
+// wordFields are the fields a bare word is looked for in.
+var wordFields = []string{"ip", "mac", "hostname", "vendor", "label", "group", "notes", "type", "tag"}
+
+// timeFields gets the times a query can compare.
+var timeFields = map[string]func(d *types.Device) time.Time{
+	"last_seen":  func(d *types.Device) time.Time { return d.LastSeen },
+	"first_seen": func(d *types.Device) time.Time { return d.FirstSeen },
+}
+
+// onlineWindow is how recently a device must have been seen to count as
+// online, as types.Device.IsOnline has it.
+const onlineWindow = time.Hour
+
+// Parse parses a query. Its errors say which term is wrong and why, since
+// they are shown to whoever typed it.
+func Parse(s string) (*Query, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Query{}
+	for _, tok := range tokens {
+		t, err := parseTerm(tok)
+		if err != nil {
+			return nil, err
+		}
+		q.terms = append(q.terms, t)
+	}
+	return q, nil
+}
+
+// Match reports whether d matches every term of the query.
+func (q *Query) Match(d *types.Device) bool {
+	return q.MatchAt(d, time.Now())
+}
+
+// MatchAt is Match with ages measured from now.
+func (q *Query) MatchAt(d *types.Device, now time.Time) bool {
+	for _, t := range q.terms {
+		if t.match(d, now) == t.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns the devices of a map keyed by IP that match the query,
+// keyed the same way.
+func (q *Query) Filter(devices map[string]*types.Device) map[string]*types.Device {
+	now := time.Now()
+	result := make(map[string]*types.Device)
+	for ip, d := range devices {
+		if q.MatchAt(d, now) {
+			result[ip] = d
+		}
+	}
+	return result
+}
+
+// tokenize splits a query at spaces outside double quotes, removing the
+// quotes.
+func tokenize(s string) ([]string, error) {
+	var tokens []string
+	var cur strings.Builder
+	inQuote, started := false, false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			started = true
+		case unicode.IsSpace(r) && !inQuote:
+			if started {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+				started = false
+			}
+		default:
+			cur.WriteRune(r)
+			started = true
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("unclosed quote in %q", s)
+	}
+	if started {
+		tokens = append(tokens, cur.String())
+	}
+	return tokens, nil
+}
+
+func parseTerm(tok string) (term, error) {
+	t := term{}
+	if strings.HasPrefix(tok, "-") && len(tok) > 1 {
+		t.negate = true
+		tok = tok[1:]
+	}
+
+	// MAC and IPv6 addresses contain colons but are words, not fields.
+	_, macErr := net.ParseMAC(tok)
+	i := strings.IndexAny(tok, ":<>")
+	if i <= 0 || macErr == nil || net.ParseIP(tok) != nil {
+		t.match = matchWord(tok)
+		return t, nil
+	}
+	field, op, value := strings.ToLower(tok[:i]), tok[i], tok[i+1:]
+	if value == "" {
+		return t, fmt.Errorf("%s: missing value", tok)
+	}
+
+	var err error
+	switch {
+	case op != ':':
+		t.match, err = matchTime(field, op, value)
+	case field == "has":
+		t.match, err = matchHas(value)
+	case field == "status":
+		t.match, err = matchStatus(value)
+	case field == "ip" && strings.Contains(value, "/"):
+		t.match, err = matchNetwork(value)
+	case field == "tag":
+		t.match = func(d *types.Device, _ time.Time) bool { return d.HasTag(value) }
+	default:
+		get, ok := textFields[field]
+		if !ok {
+			return t, fmt.Errorf("%s: unknown field %q", tok, field)
+		}
+		t.match = func(d *types.Device, _ time.Time) bool { return containsFold(get(d), value) }
+	}
+	if err != nil {
+		return t, fmt.Errorf("%s: %w", tok, err)
+	}
+	return t, nil
+}
+
+func matchWord(word string) func(*types.Device, time.Time) bool {
+	return func(d *types.Device, _ time.Time) bool {
+		for _, f := range wordFields {
+			if containsFold(textFields[f](d), word) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func matchHas(field string) (func(*types.Device, time.Time) bool, error) {
+	field = strings.ToLower(field)
+	if get, ok := textFields[field]; ok {
+		return func(d *types.Device, _ time.Time) bool { return get(d) != "" }, nil
+	}
+	if get, ok := timeFields[field]; ok {
+		return func(d *types.Device, _ time.Time) bool { return !get(d).IsZero() }, nil
+	}
+	return nil, fmt.Errorf("unknown field %q", field)
+}
+
+func matchStatus(value string) (func(*types.Device, time.Time) bool, error) {
+	switch strings.ToLower(value) {
+	case "online":
+		return func(d *types.Device, now time.Time) bool { return now.Sub(d.LastSeen) < onlineWindow }, nil
+	case "offline":
+		return func(d *types.Device, now time.Time) bool { return now.Sub(d.LastSeen) >= onlineWindow }, nil
+	case "pending":
+		return func(d *types.Device, _ time.Time) bool { return d.Pending }, nil
+	case "approved":
+		return func(d *types.Device, _ time.Time) bool { return !d.Pending }, nil
+	case "watched":
+		return func(d *types.Device, _ time.Time) bool { return d.Watch }, nil
+	}
+	return nil, fmt.Errorf("status must be online, offline, pending, approved or watched")
+}
+
+func matchNetwork(cidr string) (func(*types.Device, time.Time) bool, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid network %q", cidr)
+	}
+	return func(d *types.Device, _ time.Time) bool {
+		ip := net.ParseIP(d.IP)
+		return ip != nil && ipNet.Contains(ip)
+	}, nil
+}
+
+// matchTime compares a time field with an age or a date. An age reads as
+// "seen less than 7d ago" for <, so with ages the comparison is the other
+// way round from comparing the times themselves.
+func matchTime(field string, op byte, value string) (func(*types.Device, time.Time) bool, error) {
+	get, ok := timeFields[field]
+	if !ok {
+		return nil, fmt.Errorf("only last_seen and first_seen can be compared with < and >")
+	}
+
+	if age, err := ParseAge(value); err == nil {
+		return func(d *types.Device, now time.Time) bool {
+			t := get(d)
+			if t.IsZero() {
+				return false
+			}
+			if op == '<' {
+				return now.Sub(t) < age
+			}
+			return now.Sub(t) > age
+		}, nil
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither an age such as 7d nor a date such as 2026-01-31", value)
+	}
+	return func(d *types.Device, _ time.Time) bool {
+		t := get(d)
+		if t.IsZero() {
+			return false
+		}
+		if op == '<' {
+			return t.Before(date)
+		}
+		// After the date means from the end of that day.
+		return !t.Before(date.AddDate(0, 0, 1))
+	}, nil
+}
+
+// ParseAge parses an age such as 30m, 12h, 7d or 2w, or 0. Days and weeks
+// are not units time.ParseDuration knows.
+func ParseAge(s string) (time.Duration, error) {
+	if s == "0" {
+		return 0, nil
+	}
+	if len(s) < 2 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	unit := map[byte]time.Duration{
+		'm': time.Minute,
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+	}[s[len(s)-1]]
+	if unit == 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// FormatAge writes d as ParseAge reads it, in the largest unit that divides
+// it exactly. Anything under a minute is written as 0.
+func FormatAge(d time.Duration) string {
+	if d < time.Minute {
+		return "0"
+	}
+	for _, u := range []struct {
+		suffix string
+		unit   time.Duration
+	}{
+		{"w", 7 * 24 * time.Hour},
+		{"d", 24 * time.Hour},
+		{"h", time.Hour},
+	} {
+		if d%u.unit == 0 {
+			return strconv.FormatInt(int64(d/u.unit), 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(int64(d/time.Minute), 10) + "m"
+}
+
+func containsFold(s, sub string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(sub))
+}
@@ -0,0 +1,134 @@
+package query
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var now = time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+
+var devices = []*types.Device{
+	{IP: "192.168.1.1", MAC: "AA:BB:CC:00:00:01", Hostname: "router", Vendor: "Ubiquiti", Group: "Network",
+		FirstSeen: now.AddDate(0, -6, 0), LastSeen: now.Add(-2 * time.Minute)},
+	{IP: "192.168.1.20", Hostname: "pihole", Vendor: "Raspberry Pi Trading", Label: "Pi hole", Tags: []string{"dns", "upstairs"},
+		FirstSeen: now.AddDate(0, 0, -3), LastSeen: now.Add(-3 * time.Hour), Pending: true},
+	{IP: "192.168.1.30", Vendor: "Raspberry Pi Trading", Label: "Living room display", Notes: "kiosk",
+		FirstSeen: now.AddDate(0, 0, -20), LastSeen: now.AddDate(0, 0, -10)},
+	{IP: "10.0.0.5", Hostname: "nas", Type: "nas", Group: "Servers",
+		FirstSeen: now.AddDate(-1, 0, 0), LastSeen: now.Add(-10 * time.Minute), Watch: true},
+}
+
+// search returns the IPs of the devices q matches, sorted.
+func search(t *testing.T, q string) string {
+	t.Helper()
+	parsed, err := Parse(q)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", q, err)
+	}
+	var ips []string
+	for _, d := range devices {
+		if parsed.MatchAt(d, now) {
+			ips = append(ips, d.IP)
+		}
+	}
+	sort.Strings(ips)
+	return strings.Join(ips, " ")
+}
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{"", "10.0.0.5 192.168.1.1 192.168.1.20 192.168.1.30"},
+		{"raspberry", "192.168.1.20 192.168.1.30"},
+		{"vendor:raspberry last_seen<7d", "192.168.1.20"},
+		{"vendor:RASPBERRY last_seen>7d", "192.168.1.30"},
+		{`label:"living room"`, "192.168.1.30"},
+		{"-has:label", "10.0.0.5 192.168.1.1"},
+		{"ip:192.168.1.0/24 -vendor:raspberry", "192.168.1.1"},
+		{"ip:192.168.1.2", "192.168.1.20"},
+		{"status:online", "10.0.0.5 192.168.1.1"},
+		{"status:offline", "192.168.1.20 192.168.1.30"},
+		{"status:pending", "192.168.1.20"},
+		{"-status:pending", "10.0.0.5 192.168.1.1 192.168.1.30"},
+		{"status:approved", "10.0.0.5 192.168.1.1 192.168.1.30"},
+		{"status:watched", "10.0.0.5"},
+		{"tag:dns", "192.168.1.20"},
+		{"tag:dn", ""},
+		{"group:servers", "10.0.0.5"},
+		{"type:nas", "10.0.0.5"},
+		{"kiosk", "192.168.1.30"},
+		{"first_seen<7d", "192.168.1.20"},
+		{"first_seen>2026-03-01", "192.168.1.20"},
+		{"first_seen<2025-06-01", "10.0.0.5"},
+		{"aa:bb:cc:00:00:01", "192.168.1.1"},
+		{"name:pi host:pihole", "192.168.1.20"},
+	}
+	for _, tt := range tests {
+		if got := search(t, tt.query); got != tt.want {
+			t.Errorf("%q matched [%s], want [%s]", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, q := range []string{
+		"colour:red",
+		"vendor:",
+		"status:away",
+		"has:colour",
+		"ip:10.0.0.0/33",
+		"vendor<7d",
+		"last_seen<soon",
+		`label:"unclosed`,
+	} {
+		if _, err := Parse(q); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", q)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	q, err := Parse("group:network")
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := make(map[string]*types.Device)
+	for _, d := range devices {
+		all[d.IP] = d
+	}
+	got := q.Filter(all)
+	if len(got) != 1 || got["192.168.1.1"] == nil {
+		t.Errorf("Filter = %v, want just the router", got)
+	}
+}
+
+func TestAges(t *testing.T) {
+	for s, d := range map[string]time.Duration{
+		"0":   0,
+		"45m": 45 * time.Minute,
+		"2h":  2 * time.Hour,
+		"3d":  72 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+	} {
+		got, err := ParseAge(s)
+		if err != nil || got != d {
+			t.Errorf("ParseAge(%q) = %v, %v; want %v", s, got, err, d)
+		}
+		if back := FormatAge(d); back != s {
+			t.Errorf("FormatAge(%v) = %q; want %q", d, back, s)
+		}
+	}
+	if got := FormatAge(90 * time.Minute); got != "90m" {
+		t.Errorf("FormatAge(90m) = %q", got)
+	}
+	for _, s := range []string{"", "5", "2y", "-1h", "1.5h"} {
+		if _, err := ParseAge(s); err == nil {
+			t.Errorf("ParseAge(%q) succeeded", s)
+		}
+	}
+}
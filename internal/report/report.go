@@ -0,0 +1,311 @@
+// Package report builds the weekly summary `orangutan report` writes: the
+// inventory by group, devices that came and went, which devices were online
+// most, which kept dropping off and which were online much less than usual,
+// and how the scans went.
+//
+// The report is one HTML file with its styles inline and no scripts, so it
+// can be attached to an email or opened from a file share and look the same
+// anywhere.
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/scanner"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+	"github.com/291-Group/LAN-Orangutan/internal/uptime"
+)
+
+// highlightRows caps the uptime highlight lists; they point out devices
+// worth a look rather than ranking them all.
+const highlightRows = 5
+
+// onlineFor is how recently a device must have been seen to count as online,
+// as everywhere else.
+const onlineFor = time.Hour
+
+// Input is what a report is built from.
+type Input struct {
+	Devices map[string]*types.Device
+	// Sightings are each device's stretches of time online, by IP address,
+	// over the period and the history before it that is kept, which says
+	// how available each device usually is.
+	Sightings map[string][]types.Sighting
+	Events    []types.Event
+	Scans     []Scan
+	// Now is when the report is made, and Period how far back it looks.
+	Now    time.Time
+	Period time.Duration
+}
+
+// Scan is what is known of a network's scans.
+type Scan struct {
+	Network  string
+	Last     time.Time
+	Duration time.Duration
+}
+
+// Report is a built report, ready to write out.
+type Report struct {
+	Now    time.Time
+	Since  time.Time
+	Days   int
+	Total  int
+	Online int
+
+	Groups   []Group
+	New      []Device
+	Gone     []Device
+	Longest  []Uptime
+	Flakiest []Uptime
+	Outliers []uptime.Outlier
+	Scans    []ScanSummary
+}
+
+// Group is one heading of the inventory. An empty Name holds the devices in
+// no group.
+type Group struct {
+	Name    string
+	Online  int
+	Devices []Device
+}
+
+// Device is a device as the report shows it.
+type Device struct {
+	IP        string
+	Name      string
+	MAC       string
+	Vendor    string
+	Online    bool
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// Uptime is how much of the period a device was online, and in how many
+// separate stretches.
+type Uptime struct {
+	Device
+	Online    time.Duration
+	Percent   int
+	Stretches int
+}
+
+// ScanSummary sums up one network's scans over the period.
+type ScanSummary struct {
+	Scan
+	Devices  int
+	Failures int
+	// LastError is why the latest failed scan failed.
+	LastError string
+}
+
+// Build works out the report for in.
+func Build(in Input) *Report {
+	since := in.Now.Add(-in.Period)
+	r := &Report{
+		Now:   in.Now,
+		Since: since,
+		Days:  int(in.Period.Round(24*time.Hour) / (24 * time.Hour)),
+		Total: len(in.Devices),
+	}
+
+	ips := make([]string, 0, len(in.Devices))
+	for ip := range in.Devices {
+		ips = append(ips, ip)
+	}
+	sort.Slice(ips, func(i, j int) bool { return types.LessIP(ips[i], ips[j]) })
+
+	groups := make(map[string]int)
+	var uptimes []Uptime
+	for _, ip := range ips {
+		d := in.Devices[ip]
+		dev := newDevice(d, in.Now)
+		if dev.Online {
+			r.Online++
+		}
+
+		i, ok := groups[d.Group]
+		if !ok {
+			i = len(r.Groups)
+			groups[d.Group] = i
+			r.Groups = append(r.Groups, Group{Name: d.Group})
+		}
+		r.Groups[i].Devices = append(r.Groups[i].Devices, dev)
+		if dev.Online {
+			r.Groups[i].Online++
+		}
+
+		if !d.FirstSeen.Before(since) {
+			r.New = append(r.New, dev)
+		}
+		if !dev.Online && !d.LastSeen.Before(since) {
+			r.Gone = append(r.Gone, dev)
+		}
+		if u, ok := onlineTime(dev, in.Sightings[ip], since, in.Now); ok {
+			uptimes = append(uptimes, u)
+		}
+	}
+	// Named groups in order, then the devices in none.
+	sort.SliceStable(r.Groups, func(i, j int) bool {
+		a, b := r.Groups[i].Name, r.Groups[j].Name
+		switch {
+		case a == "":
+			return false
+		case b == "":
+			return true
+		}
+		return strings.ToLower(a) < strings.ToLower(b)
+	})
+	sort.SliceStable(r.New, func(i, j int) bool { return r.New[i].FirstSeen.After(r.New[j].FirstSeen) })
+	sort.SliceStable(r.Gone, func(i, j int) bool { return r.Gone[i].LastSeen.After(r.Gone[j].LastSeen) })
+
+	r.Longest = append([]Uptime(nil), uptimes...)
+	sort.SliceStable(r.Longest, func(i, j int) bool { return r.Longest[i].Online > r.Longest[j].Online })
+	r.Longest = head(r.Longest)
+	// A device that came back after dropping off has more than one stretch;
+	// those with the most are the ones losing their connection.
+	for _, u := range uptimes {
+		if u.Stretches > 1 {
+			r.Flakiest = append(r.Flakiest, u)
+		}
+	}
+	sort.SliceStable(r.Flakiest, func(i, j int) bool { return r.Flakiest[i].Stretches > r.Flakiest[j].Stretches })
+	r.Flakiest = head(r.Flakiest)
+	r.Outliers = uptime.Outliers(in.Devices, sightings(in.Sightings), in.Period, in.Now)
+
+	r.Scans = summarizeScans(in, since)
+	return r
+}
+
+func newDevice(d *types.Device, now time.Time) Device {
+	name := d.Label
+	if name == "" {
+		name = d.Hostname
+	}
+	return Device{
+		IP:        d.IP,
+		Name:      name,
+		MAC:       d.MAC,
+		Vendor:    scanner.ResolveVendor(d.Vendor, d.MAC),
+		Online:    now.Sub(d.LastSeen) < onlineFor,
+		FirstSeen: d.FirstSeen,
+		LastSeen:  d.LastSeen,
+	}
+}
+
+// onlineTime adds up the time a device was online within since and now. It
+// reports false for a device not seen at all in that time.
+func onlineTime(dev Device, sightings []types.Sighting, since, now time.Time) (Uptime, bool) {
+	u := Uptime{Device: dev}
+	for _, sg := range sightings {
+		if sg.End.Before(since) || sg.Start.After(now) {
+			continue
+		}
+		start, end := sg.Start, sg.End
+		if start.Before(since) {
+			start = since
+		}
+		if end.After(now) {
+			end = now
+		}
+		u.Online += end.Sub(start)
+		u.Stretches++
+	}
+	if u.Stretches == 0 {
+		return u, false
+	}
+	if period := now.Sub(since); period > 0 {
+		u.Percent = int(100 * u.Online / period)
+	}
+	return u, true
+}
+
+// sightings is Input.Sightings as package uptime asks for them.
+type sightings map[string][]types.Sighting
+
+func (s sightings) GetSightings(ip string, since time.Time) []types.Sighting { return s[ip] }
+
+func head(u []Uptime) []Uptime {
+	if len(u) > highlightRows {
+		return u[:highlightRows]
+	}
+	return u
+}
+
+// summarizeScans pairs each network's scan state with its failures over the
+// period and the devices found on it.
+func summarizeScans(in Input, since time.Time) []ScanSummary {
+	summaries := make([]ScanSummary, len(in.Scans))
+	index := make(map[string]int, len(in.Scans))
+	for i, sc := range in.Scans {
+		summaries[i] = ScanSummary{Scan: sc}
+		index[sc.Network] = i
+		if _, ipNet, err := net.ParseCIDR(sc.Network); err == nil {
+			for _, d := range in.Devices {
+				if ip := net.ParseIP(d.IP); ip != nil && ipNet.Contains(ip) {
+					summaries[i].Devices++
+				}
+			}
+		}
+	}
+	// Events come newest first, so the first failure seen is the latest.
+	for _, e := range in.Events {
+		if e.Type != types.EventScanFailed || e.Time.Before(since) {
+			continue
+		}
+		i, ok := index[e.Network]
+		if !ok {
+			i = len(summaries)
+			index[e.Network] = i
+			summaries = append(summaries, ScanSummary{Scan: Scan{Network: e.Network}})
+		}
+		if summaries[i].Failures == 0 {
+			summaries[i].LastError = e.Detail
+		}
+		summaries[i].Failures++
+	}
+	return summaries
+}
+
+//go:embed report.html
+var reportHTML string
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return t.Format("Mon 2 Jan 15:04")
+	},
+	"duration": duration,
+	"seconds":  func(d time.Duration) string { return d.Round(100 * time.Millisecond).String() },
+}).Parse(reportHTML))
+
+// duration writes d to the nearest minute in the largest units that fit,
+// such as "3d 4h" or "45m".
+func duration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
+
+// WriteHTML writes the report as a standalone HTML page.
+func (r *Report) WriteHTML(w io.Writer) error {
+	return reportTemplate.Execute(w, r)
+}
@@ -0,0 +1,178 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var now = time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)
+
+const week = 7 * 24 * time.Hour
+
+func device(ip, group string, firstSeen, lastSeen time.Time) *types.Device {
+	return &types.Device{IP: ip, Group: group, FirstSeen: firstSeen, LastSeen: lastSeen}
+}
+
+func TestBuildGroupsDevices(t *testing.T) {
+	old := now.Add(-30 * 24 * time.Hour)
+	r := Build(Input{
+		Devices: map[string]*types.Device{
+			"192.168.1.10": device("192.168.1.10", "servers", old, now),
+			"192.168.1.2":  device("192.168.1.2", "servers", old, now.Add(-2*time.Hour)),
+			"192.168.1.3":  device("192.168.1.3", "", old, now),
+			"192.168.1.4":  device("192.168.1.4", "Printers", old, now),
+		},
+		Now:    now,
+		Period: week,
+	})
+
+	if r.Total != 4 || r.Online != 3 {
+		t.Errorf("got %d devices, %d online; want 4, 3", r.Total, r.Online)
+	}
+	var names []string
+	for _, g := range r.Groups {
+		names = append(names, g.Name)
+	}
+	if got := strings.Join(names, ","); got != "Printers,servers," {
+		t.Fatalf("groups in order %q, want named groups then the unnamed one", got)
+	}
+	servers := r.Groups[1]
+	if servers.Online != 1 || len(servers.Devices) != 2 {
+		t.Errorf("servers has %d of %d online, want 1 of 2", servers.Online, len(servers.Devices))
+	}
+	if servers.Devices[0].IP != "192.168.1.2" {
+		t.Errorf("devices not in address order: %+v", servers.Devices)
+	}
+}
+
+func TestBuildNewAndGone(t *testing.T) {
+	old := now.Add(-30 * 24 * time.Hour)
+	r := Build(Input{
+		Devices: map[string]*types.Device{
+			"192.168.1.1": device("192.168.1.1", "", now.Add(-24*time.Hour), now),
+			"192.168.1.2": device("192.168.1.2", "", old, now.Add(-48*time.Hour)),
+			"192.168.1.3": device("192.168.1.3", "", old, now.Add(-20*24*time.Hour)),
+			"192.168.1.4": device("192.168.1.4", "", old, now),
+		},
+		Now:    now,
+		Period: week,
+	})
+
+	if len(r.New) != 1 || r.New[0].IP != "192.168.1.1" {
+		t.Errorf("new = %+v, want only 192.168.1.1", r.New)
+	}
+	// 192.168.1.3 went offline before the period began.
+	if len(r.Gone) != 1 || r.Gone[0].IP != "192.168.1.2" {
+		t.Errorf("gone = %+v, want only 192.168.1.2", r.Gone)
+	}
+}
+
+func TestBuildUptime(t *testing.T) {
+	old := now.Add(-30 * 24 * time.Hour)
+	r := Build(Input{
+		Devices: map[string]*types.Device{
+			"192.168.1.1": device("192.168.1.1", "", old, now),
+			"192.168.1.2": device("192.168.1.2", "", old, now),
+			"192.168.1.3": device("192.168.1.3", "", old, now.Add(-10*24*time.Hour)),
+		},
+		Sightings: map[string][]types.Sighting{
+			// Online since before the period: only the period counts.
+			"192.168.1.1": {{Start: old, End: now}},
+			"192.168.1.2": {
+				{Start: now.Add(-72 * time.Hour), End: now.Add(-70 * time.Hour)},
+				{Start: now.Add(-24 * time.Hour), End: now.Add(-23 * time.Hour)},
+				{Start: now.Add(-time.Hour), End: now},
+			},
+			"192.168.1.3": {{Start: old, End: now.Add(-10 * 24 * time.Hour)}},
+		},
+		Now:    now,
+		Period: week,
+	})
+
+	if len(r.Longest) != 2 {
+		t.Fatalf("got %d devices online longest, want the 2 seen this period: %+v", len(r.Longest), r.Longest)
+	}
+	if u := r.Longest[0]; u.IP != "192.168.1.1" || u.Online != week || u.Percent != 100 {
+		t.Errorf("longest = %+v, want 192.168.1.1 online the whole week", u)
+	}
+	if u := r.Longest[1]; u.Online != 4*time.Hour || u.Stretches != 3 {
+		t.Errorf("second = %+v, want 4h online in 3 stretches", u)
+	}
+	if len(r.Flakiest) != 1 || r.Flakiest[0].IP != "192.168.1.2" {
+		t.Errorf("flakiest = %+v, want only 192.168.1.2", r.Flakiest)
+	}
+	// On until ten days ago, and not at all this week
+	if len(r.Outliers) != 1 || r.Outliers[0].IP != "192.168.1.3" || r.Outliers[0].Percent != 0 {
+		t.Errorf("outliers = %+v, want only 192.168.1.3", r.Outliers)
+	}
+}
+
+func TestBuildScans(t *testing.T) {
+	r := Build(Input{
+		Devices: map[string]*types.Device{
+			"192.168.1.1": device("192.168.1.1", "", now, now),
+			"192.168.1.2": device("192.168.1.2", "", now, now),
+			"10.0.0.1":    device("10.0.0.1", "", now, now),
+		},
+		// Newest first, as they are stored.
+		Events: []types.Event{
+			{Type: types.EventScanFailed, Network: "192.168.1.0/24", Detail: "timed out", Time: now.Add(-time.Hour)},
+			{Type: types.EventScanFailed, Network: "192.168.1.0/24", Detail: "nmap not found", Time: now.Add(-2 * time.Hour)},
+			{Type: types.EventScanFailed, Network: "192.168.1.0/24", Detail: "too old", Time: now.Add(-30 * 24 * time.Hour)},
+			{Type: types.EventScanFailed, Network: "172.16.0.0/24", Detail: "no route", Time: now.Add(-time.Hour)},
+		},
+		Scans:  []Scan{{Network: "192.168.1.0/24", Last: now, Duration: 3 * time.Second}},
+		Now:    now,
+		Period: week,
+	})
+
+	if len(r.Scans) != 2 {
+		t.Fatalf("got %d networks, want 2: %+v", len(r.Scans), r.Scans)
+	}
+	if s := r.Scans[0]; s.Devices != 2 || s.Failures != 2 || s.LastError != "timed out" {
+		t.Errorf("192.168.1.0/24 = %+v, want 2 devices, 2 failures, last timed out", s)
+	}
+	// A network that only ever failed is still listed.
+	if s := r.Scans[1]; s.Network != "172.16.0.0/24" || s.Failures != 1 || !s.Last.IsZero() {
+		t.Errorf("172.16.0.0/24 = %+v, want 1 failure and no scan", s)
+	}
+}
+
+func TestWriteHTMLEscapes(t *testing.T) {
+	d := device("192.168.1.1", "<lab>", now, now)
+	d.Label = `<script>alert("x")</script>`
+	r := Build(Input{
+		Devices: map[string]*types.Device{d.IP: d},
+		Now:     now,
+		Period:  week,
+	})
+
+	var buf bytes.Buffer
+	if err := r.WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "<script>") || strings.Contains(out, "<lab>") {
+		t.Error("device details are not escaped")
+	}
+	if !strings.Contains(out, "&lt;lab&gt;") {
+		t.Error("group heading missing")
+	}
+}
+
+func TestDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		45 * time.Minute:                           "45m",
+		2*time.Hour + 5*time.Minute:                "2h 5m",
+		3*24*time.Hour + 4*time.Hour + time.Minute: "3d 4h",
+		20 * time.Second:                           "0m",
+	} {
+		if got := duration(d); got != want {
+			t.Errorf("duration(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+// Package risk scores how exposed each device is, from 0 for nothing known
+// against it to 100, so that the devices most in need of attention can be
+// put first. The score adds up points for what is known: management ports
+// open to the network, telnet above all, known vulnerabilities, logins left
+// at their factory defaults, a maker that cannot be identified and a device
+// nobody has approved.
+//
+// What a device listens on is known only from its latest deep scan, so a
+// device never deep scanned scores on the rest alone. The score is a way to
+// order devices, not a measure of anything; each signal says what it is
+// worth, so a reader can see why a device scored as it did.
+package risk
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/291-Group/LAN-Orangutan/internal/scanner"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// Max is the highest score; points beyond it are not counted.
+const Max = 100
+
+// Signals that add to a device's score.
+const (
+	SignalManagementPorts = "management_ports"
+	SignalTelnet          = "telnet"
+	SignalVulnerabilities = "vulnerabilities"
+	SignalDefaultLogins   = "default_logins"
+	SignalUnknownVendor   = "unknown_vendor"
+	SignalUnapproved      = "unapproved"
+)
+
+// Points for each signal. A working default login is as good as an open
+// door, and a critical CVE with a known exploit nearly so; an unknown maker
+// only says the device is worth a look.
+const (
+	managementPortPoints = 5
+	managementPortMax    = 15
+	telnetPoints         = 20
+	defaultLoginPoints   = 40
+	exploitPoints        = 10
+	unknownVendorPoints  = 10
+	unapprovedPoints     = 15
+)
+
+// vulnerabilityPoints are the points for the worst vulnerability found, by
+// its severity.
+var vulnerabilityPoints = map[string]int{
+	types.SeverityCritical: 40,
+	types.SeverityHigh:     25,
+	types.SeverityMedium:   10,
+	types.SeverityLow:      3,
+}
+
+// managementPorts are the ports of services for running a device rather
+// than using it, by name. Web interfaces are left out: nearly everything
+// has one.
+var managementPorts = map[int]string{
+	22:   "SSH",
+	161:  "SNMP",
+	623:  "IPMI",
+	2375: "Docker API",
+	3389: "RDP",
+	5900: "VNC",
+	5985: "WinRM",
+	5986: "WinRM",
+	8291: "Winbox",
+}
+
+// Levels a score falls in, for display.
+const (
+	LevelHigh   = "high"
+	LevelMedium = "medium"
+	LevelLow    = "low"
+	LevelNone   = "none"
+)
+
+// Signal is one thing that adds to a device's score.
+type Signal struct {
+	Name   string `json:"signal"`
+	Points int    `json:"points"`
+	// Detail says what was found, as in "telnet open on port 23".
+	Detail string `json:"detail"`
+}
+
+// Assessment is a device's score and what it is made of.
+type Assessment struct {
+	IP      string   `json:"ip"`
+	Score   int      `json:"score"`
+	Level   string   `json:"level"`
+	Signals []Signal `json:"signals"`
+}
+
+// Level returns the level a score falls in: high from 50, medium from 20,
+// low above 0.
+func Level(score int) string {
+	switch {
+	case score >= 50:
+		return LevelHigh
+	case score >= 20:
+		return LevelMedium
+	case score > 0:
+		return LevelLow
+	}
+	return LevelNone
+}
+
+// Assess scores d from what is known of it, including scan, its latest deep
+// scan, which is nil when it has had none.
+func Assess(d *types.Device, scan *types.DeepScan) Assessment {
+	a := Assessment{IP: d.IP, Signals: make([]Signal, 0)}
+	add := func(name string, points int, detail string) {
+		a.Signals = append(a.Signals, Signal{Name: name, Points: points, Detail: detail})
+		a.Score += points
+	}
+
+	if scan != nil {
+		var telnet, mgmt []string
+		for _, s := range scan.Services {
+			if s.Port == 23 || strings.EqualFold(s.Name, "telnet") {
+				telnet = append(telnet, strconv.Itoa(s.Port))
+			} else if name, ok := managementPorts[s.Port]; ok {
+				mgmt = append(mgmt, name+" on "+strconv.Itoa(s.Port))
+			}
+		}
+		if len(telnet) > 0 {
+			add(SignalTelnet, telnetPoints, "telnet open on port "+strings.Join(telnet, ", "))
+		}
+		if len(mgmt) > 0 {
+			add(SignalManagementPorts, min(len(mgmt)*managementPortPoints, managementPortMax), "management ports open: "+strings.Join(mgmt, ", "))
+		}
+
+		var worst types.Finding
+		found, exploit, logins := 0, false, 0
+		for _, f := range scan.Findings {
+			if f.Check == types.CheckDefaultLogins {
+				logins++
+				continue
+			}
+			if vulnerabilityPoints[strings.ToLower(f.Severity)] == 0 {
+				continue
+			}
+			if found == 0 || types.SeverityRank(f.Severity) > types.SeverityRank(worst.Severity) {
+				worst = f
+			}
+			found++
+			exploit = exploit || f.Exploit
+		}
+		if found > 0 {
+			detail := fmt.Sprintf("%d known vulnerabilities, the worst %s", found, strings.ToLower(worst.Severity))
+			if found == 1 {
+				detail = "1 known vulnerability, " + strings.ToLower(worst.Severity)
+			}
+			if worst.ID != "" {
+				detail += " (" + worst.ID + ")"
+			}
+			points := vulnerabilityPoints[strings.ToLower(worst.Severity)]
+			if exploit {
+				points += exploitPoints
+				detail += ", with a public exploit"
+			}
+			add(SignalVulnerabilities, points, detail)
+		}
+		if logins > 0 {
+			add(SignalDefaultLogins, defaultLoginPoints, "accepts a factory default login")
+		}
+	}
+
+	// A randomised address has no maker to find by design, which says
+	// nothing about the device.
+	if d.MAC != "" && !scanner.IsLocallyAdministered(d.MAC) {
+		if vendor := scanner.ResolveVendor(d.Vendor, d.MAC); vendor == "" || vendor == "Unknown" {
+			add(SignalUnknownVendor, unknownVendorPoints, "maker unknown")
+		}
+	}
+	if d.Pending {
+		add(SignalUnapproved, unapprovedPoints, "not approved")
+	}
+
+	a.Score = min(a.Score, Max)
+	a.Level = Level(a.Score)
+	return a
+}
+
+// AssessAll scores every device, with the deep scans by IP, highest first.
+func AssessAll(devices map[string]*types.Device, scans map[string]types.DeepScan) []Assessment {
+	result := make([]Assessment, 0, len(devices))
+	for ip, d := range devices {
+		var scan *types.DeepScan
+		if s, ok := scans[ip]; ok {
+			scan = &s
+		}
+		result = append(result, Assess(d, scan))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].IP < result[j].IP
+	})
+	return result
+}
@@ -0,0 +1,74 @@
+package risk
+
+import (
+	"testing"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func TestAssess(t *testing.T) {
+	camera := &types.Device{IP: "192.168.1.20", MAC: "00:00:00:AB:CD:EF", Pending: true}
+	scan := &types.DeepScan{
+		IP: camera.IP,
+		Services: []types.Service{
+			{Port: 22, Protocol: "tcp", Name: "ssh"},
+			{Port: 23, Protocol: "tcp", Name: "telnet"},
+			{Port: 80, Protocol: "tcp", Name: "http"},
+			{Port: 3389, Protocol: "tcp", Name: "ms-wbt-server"},
+		},
+		Findings: []types.Finding{
+			{Check: "vulners", ID: "CVE-2018-15473", Severity: types.SeverityMedium, CVSS: 5.3, Exploit: true},
+			{Check: "nvd", ID: "CVE-2016-10012", Severity: types.SeverityHigh, CVSS: 7.8},
+			{Check: "banner", Severity: types.SeverityInfo},
+		},
+	}
+	a := Assess(camera, scan)
+	want := map[string]int{
+		SignalTelnet:          telnetPoints,
+		SignalManagementPorts: 2 * managementPortPoints,
+		SignalVulnerabilities: 25 + exploitPoints,
+		SignalUnapproved:      unapprovedPoints,
+	}
+	got := make(map[string]int)
+	for _, s := range a.Signals {
+		got[s.Name] = s.Points
+	}
+	for name, points := range want {
+		if got[name] != points {
+			t.Errorf("%s = %d points; want %d (signals %+v)", name, got[name], points, a.Signals)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("signals = %+v; want only %v", a.Signals, want)
+	}
+	if a.Score != 80 || a.Level != LevelHigh {
+		t.Errorf("score = %d %s; want 80 high", a.Score, a.Level)
+	}
+
+	// A default login on top goes past the top of the scale.
+	scan.Findings = append(scan.Findings, types.Finding{Check: types.CheckDefaultLogins, Severity: types.SeverityHigh})
+	if a := Assess(camera, scan); a.Score != Max {
+		t.Errorf("score = %d; want it capped at %d", a.Score, Max)
+	}
+
+	// A phone with a randomised address, approved and never deep scanned.
+	phone := &types.Device{IP: "192.168.1.30", MAC: "DA:A1:19:00:00:01"}
+	if a := Assess(phone, nil); a.Score != 0 || a.Level != LevelNone || len(a.Signals) != 0 {
+		t.Errorf("phone = %+v; want nothing against it", a)
+	}
+}
+
+func TestAssessAll(t *testing.T) {
+	devices := map[string]*types.Device{
+		"192.168.1.2": {IP: "192.168.1.2"},
+		"192.168.1.3": {IP: "192.168.1.3", Pending: true},
+		"192.168.1.4": {IP: "192.168.1.4"},
+	}
+	scans := map[string]types.DeepScan{
+		"192.168.1.4": {IP: "192.168.1.4", Findings: []types.Finding{{Check: "nvd", Severity: types.SeverityCritical}}},
+	}
+	got := AssessAll(devices, scans)
+	if len(got) != 3 || got[0].IP != "192.168.1.4" || got[1].IP != "192.168.1.3" || got[2].Score != 0 {
+		t.Errorf("AssessAll = %+v; want the critical CVE first, then the unapproved device", got)
+	}
+}
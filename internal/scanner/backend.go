@@ -0,0 +1,78 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// backend is a tool that finds the devices on a network.
+type backend func(s *Scanner, ctx context.Context, cidr string, opts Options) ([]types.Device, string, error)
+
+// backends are the tools a scan can use, by the name the config gives them.
+var backends = map[string]backend{
+	"nmap": (*Scanner).scanWithNmap,
+	"arp-scan": func(s *Scanner, ctx context.Context, cidr string, _ Options) ([]types.Device, string, error) {
+		return s.scanWithArpScan(ctx, cidr)
+	},
+}
+
+// DefaultBackends is the order backends are tried in unless the config says
+// otherwise: nmap, which reaches routed networks and finds hostnames, and
+// arp-scan when nmap is missing or fails.
+var DefaultBackends = []string{"nmap", "arp-scan"}
+
+// ValidBackend reports whether name names a scan backend.
+func ValidBackend(name string) bool {
+	_, ok := backends[name]
+	return ok
+}
+
+// SetBackends sets the backends scans try, in order, each only if those
+// before it failed. Leaving one out disables it. None means
+// DefaultBackends.
+func (s *Scanner) SetBackends(names []string) {
+	s.backends = names
+}
+
+// scanWithBackends scans cidr with each backend in turn until one succeeds.
+// The error, when all fail, gives each one's reason.
+func (s *Scanner) scanWithBackends(ctx context.Context, cidr string, opts Options) ([]types.Device, string, error) {
+	names := s.backends
+	if len(names) == 0 {
+		names = DefaultBackends
+	}
+
+	var failures []string
+	for i, name := range names {
+		scan, ok := backends[name]
+		if !ok {
+			failures = append(failures, fmt.Sprintf("unknown scan backend %q", name))
+			continue
+		}
+		// arp-scan has no way to go without raw sockets.
+		if name == "arp-scan" && s.privilege == Unprivileged {
+			failures = append(failures, "arp-scan needs raw sockets")
+			continue
+		}
+
+		devices, scanner, err := scan(s, ctx, cidr, opts)
+		if err == nil {
+			return devices, scanner, nil
+		}
+		if ctx.Err() != nil {
+			return nil, "", err
+		}
+		failures = append(failures, err.Error())
+		if i+1 < len(names) {
+			slog.Warn("scan failed, trying the next backend", "network", cidr, "backend", name, "next", names[i+1], "error", err)
+		} else {
+			slog.Warn("scan failed", "network", cidr, "backend", name, "error", err)
+		}
+	}
+	return nil, "", errors.New(strings.Join(failures, "; "))
+}
@@ -0,0 +1,356 @@
+package scanner
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// DeepScanOptions say what a deep scan looks for.
+type DeepScanOptions struct {
+	// Scripts are the NSE scripts and script categories to run, such as
+	// vulners or auth.
+	Scripts []string
+	// Ports are the ports to look at, as nmap's -p takes them; empty means
+	// nmap's thousand most common.
+	Ports string
+}
+
+// DefaultDeepScanScripts are the scripts a deep scan runs unless the config
+// says otherwise: vulners, which looks the versions of the services found up
+// in the vulners.com database, and http-default-accounts, which tries the
+// logins web interfaces ship with.
+var DefaultDeepScanScripts = []string{"vulners", "http-default-accounts"}
+
+// scriptNameRe matches a script, category or glob nmap's --script takes, and
+// nothing that could be read as a path or an expression.
+var scriptNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.*-]*$`)
+
+// ValidScript reports whether name may be given to a deep scan as a script.
+func ValidScript(name string) bool {
+	return scriptNameRe.MatchString(name) && !strings.Contains(name, "..")
+}
+
+// portsRe matches the port lists nmap's -p takes, such as "22,80,8000-8100"
+// or "T:1-1024,U:53".
+var portsRe = regexp.MustCompile(`^(?:[TU]:)?\d+(?:-\d+)?(?:,(?:[TU]:)?\d+(?:-\d+)?)*$`)
+
+// ValidPorts reports whether ports is a port list a deep scan can be given.
+func ValidPorts(ports string) bool {
+	return portsRe.MatchString(ports)
+}
+
+// nmapDeepRun is the root element of the XML output of a deep scan.
+type nmapDeepRun struct {
+	XMLName xml.Name       `xml:"nmaprun"`
+	Hosts   []nmapDeepHost `xml:"host"`
+}
+
+// nmapDeepHost is a host of a deep scan, with what was found on it.
+type nmapDeepHost struct {
+	Addresses   []nmapAddress `xml:"address"`
+	Ports       []nmapPort    `xml:"ports>port"`
+	HostScripts []nseScript   `xml:"hostscript>script"`
+}
+
+// nmapPort is a port of a deep scan, with the service behind it.
+type nmapPort struct {
+	Protocol string      `xml:"protocol,attr"`
+	PortID   int         `xml:"portid,attr"`
+	State    nmapStatus  `xml:"state"`
+	Service  nmapService `xml:"service"`
+	Scripts  []nseScript `xml:"script"`
+}
+
+// nmapService is what nmap's version detection made of a service.
+type nmapService struct {
+	Name    string   `xml:"name,attr"`
+	Product string   `xml:"product,attr"`
+	Version string   `xml:"version,attr"`
+	CPE     []string `xml:"cpe"`
+}
+
+// nseScript is the output of an NSE script: its text, and the same again as
+// tables and elements for the scripts that structure it.
+type nseScript struct {
+	ID     string     `xml:"id,attr"`
+	Output string     `xml:"output,attr"`
+	Tables []nseTable `xml:"table"`
+	Elems  []nseElem  `xml:"elem"`
+}
+
+// nseTable is a table of structured script output.
+type nseTable struct {
+	Key    string     `xml:"key,attr"`
+	Tables []nseTable `xml:"table"`
+	Elems  []nseElem  `xml:"elem"`
+}
+
+// nseElem is a value of structured script output.
+type nseElem struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// elem returns the value of the element of t called key, or "".
+func (t nseTable) elem(key string) string {
+	for _, e := range t.Elems {
+		if e.Key == key {
+			return strings.TrimSpace(e.Value)
+		}
+	}
+	return ""
+}
+
+// DeepScan runs the NSE scripts of opts with nmap against the addresses ips,
+// looking at the services each one runs, and returns what was found on each.
+// The devices are taken to be up, so one that ignores ping is still looked
+// at. A deep scan sends far more than a sweep does, and the scripts may try
+// logins, so it is only ever run on devices someone has chosen.
+func (s *Scanner) DeepScan(ctx context.Context, ips []string, opts DeepScanOptions) ([]types.DeepScan, error) {
+	if len(ips) == 0 {
+		return nil, nil
+	}
+	if len(opts.Scripts) == 0 {
+		opts.Scripts = DefaultDeepScanScripts
+	}
+	for _, script := range opts.Scripts {
+		if !ValidScript(script) {
+			return nil, fmt.Errorf("%q is not an NSE script or category", script)
+		}
+	}
+	if opts.Ports != "" && !ValidPorts(opts.Ports) {
+		return nil, fmt.Errorf("%q is not a list of ports", opts.Ports)
+	}
+	if _, err := exec.LookPath("nmap"); err != nil {
+		return nil, fmt.Errorf("nmap not found")
+	}
+
+	scripts := strings.Join(opts.Scripts, ",")
+	args := s.privilegeArgs([]string{"-Pn", "-sV", "--script", scripts})
+	if opts.Ports != "" {
+		args = append(args, "-p", opts.Ports)
+	}
+	args = append(args, "-oX", "-")
+	args = append(args, ips...)
+	cmd := exec.CommandContext(ctx, "nmap", args...)
+	slog.Debug("running nmap", "args", cmd.Args[1:])
+	output, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("deep scan stopped: %w", ctx.Err())
+		}
+		return nil, fmt.Errorf("nmap failed: %w%s", err, stderrOf(err))
+	}
+	return parseDeepScan(output, scripts, time.Now())
+}
+
+// parseDeepScan reads the XML output of a deep scan that ran scripts.
+func parseDeepScan(output []byte, scripts string, now time.Time) ([]types.DeepScan, error) {
+	var run nmapDeepRun
+	if err := xml.Unmarshal(output, &run); err != nil {
+		slog.Debug("unreadable nmap output", "output", string(output))
+		return nil, fmt.Errorf("failed to parse nmap output: %w", err)
+	}
+
+	var scans []types.DeepScan
+	for _, host := range run.Hosts {
+		scan := types.DeepScan{Time: now, Scripts: scripts, Services: []types.Service{}, Findings: []types.Finding{}}
+		for _, addr := range host.Addresses {
+			if addr.AddrType == "ipv4" || (addr.AddrType == "ipv6" && scan.IP == "") {
+				scan.IP = addr.Addr
+			}
+		}
+		if scan.IP == "" {
+			continue
+		}
+		for _, port := range host.Ports {
+			if port.State.State != "open" {
+				continue
+			}
+			service := types.Service{
+				Port:     port.PortID,
+				Protocol: port.Protocol,
+				Name:     port.Service.Name,
+				Product:  port.Service.Product,
+				Version:  port.Service.Version,
+				CPE:      port.Service.CPE,
+			}
+			scan.Services = append(scan.Services, service)
+			what := strings.TrimSpace(service.Product + " " + service.Version)
+			if what == "" {
+				what = service.Name
+			}
+			for _, script := range port.Scripts {
+				for _, f := range scriptFindings(script, what) {
+					f.Port, f.Protocol = port.PortID, port.Protocol
+					scan.Findings = append(scan.Findings, f)
+				}
+			}
+		}
+		for _, script := range host.HostScripts {
+			scan.Findings = append(scan.Findings, scriptFindings(script, "")...)
+		}
+		types.SortFindings(scan.Findings)
+		scans = append(scans, scan)
+	}
+	return scans, nil
+}
+
+// scriptFindings returns what the output of script says is wrong with the
+// service it ran against, named service, or with the device when service is
+// "". Scripts that structure their output are read for vulnerabilities and
+// working logins; anything else a script says is kept as information.
+func scriptFindings(script nseScript, service string) []types.Finding {
+	if script.ID == "vulners" {
+		return vulnersFindings(script, service)
+	}
+
+	var findings []types.Finding
+	reported := false
+	// parent is what the logins in tables are for, when a table above
+	// them says.
+	var walk func(tables []nseTable, parent string)
+	walk = func(tables []nseTable, parent string) {
+		for _, t := range tables {
+			if state := t.elem("state"); state != "" && t.elem("title") != "" {
+				// The vulns library, which the scripts of the vuln category
+				// use, has a table for each vulnerability it checked.
+				reported = true
+				if f, ok := vulnFinding(script.ID, t, state); ok {
+					findings = append(findings, f)
+				}
+				continue
+			}
+			if user := t.elem("username"); user != "" && !strings.Contains(strings.ToLower(t.elem("state")), "invalid") {
+				// Logins found by the *-default-accounts scripts and the
+				// brute library.
+				reported = true
+				title := fmt.Sprintf("Accepts the login %s/%s", user, t.elem("password"))
+				if product := loginProduct(parent); product != "" {
+					title = fmt.Sprintf("%s accepts the login %s/%s", product, user, t.elem("password"))
+				}
+				findings = append(findings, types.Finding{Check: script.ID, Title: title, Severity: types.SeverityHigh})
+				continue
+			}
+			product := parent
+			if loginProduct(t.Key) != "" {
+				product = t.Key
+			}
+			walk(t.Tables, product)
+		}
+	}
+	walk(script.Tables, "")
+
+	if reported {
+		return findings
+	}
+	title := firstLine(script.Output)
+	if title == "" {
+		return nil
+	}
+	if service != "" && !strings.Contains(title, service) {
+		title = service + ": " + title
+	}
+	return []types.Finding{{Check: script.ID, Title: title, Severity: types.SeverityInfo}}
+}
+
+// loginProduct returns what a table of logins found is for, as its key
+// says, or "" when the key is only a heading.
+func loginProduct(key string) string {
+	switch strings.ToLower(key) {
+	case "", "accounts", "credentials", "statistics":
+		return ""
+	}
+	return key
+}
+
+// vulnersFindings reads the output of the vulners script, which lists the
+// CVEs of each product it recognised with their CVSS scores.
+func vulnersFindings(script nseScript, service string) []types.Finding {
+	var findings []types.Finding
+	seen := make(map[string]bool)
+	for _, product := range script.Tables {
+		for _, entry := range product.Tables {
+			id := entry.elem("id")
+			// Exploits are listed beside the CVEs they exploit, which have
+			// is_exploit set.
+			if !strings.EqualFold(entry.elem("type"), "cve") || id == "" || seen[id] {
+				continue
+			}
+			seen[id] = true
+			cvss, _ := strconv.ParseFloat(entry.elem("cvss"), 64)
+			title := "Known vulnerability"
+			if service != "" {
+				title += " in " + service
+			}
+			findings = append(findings, types.Finding{
+				Check:    script.ID,
+				ID:       id,
+				Title:    title,
+				Severity: types.CVSSSeverity(cvss),
+				CVSS:     cvss,
+				Exploit:  entry.elem("is_exploit") == "true",
+			})
+		}
+	}
+	return findings
+}
+
+// vulnFinding reads a vulnerability table of the vulns library, whose state
+// says whether it was found. It returns false for one that was not.
+func vulnFinding(check string, t nseTable, state string) (types.Finding, bool) {
+	state = strings.ToUpper(state)
+	if !strings.Contains(state, "VULNERABLE") || strings.Contains(state, "NOT VULNERABLE") {
+		return types.Finding{}, false
+	}
+	f := types.Finding{Check: check, Title: t.elem("title"), Severity: types.SeverityMedium}
+	if strings.HasPrefix(t.Key, "CVE-") {
+		f.ID = t.Key
+	}
+	for _, sub := range t.Tables {
+		switch sub.Key {
+		case "ids":
+			for _, e := range sub.Elems {
+				// Such as CVE:CVE-2017-0143.
+				if _, id, ok := strings.Cut(strings.TrimSpace(e.Value), ":"); ok && f.ID == "" {
+					f.ID = id
+				}
+			}
+		case "scores":
+			for _, e := range sub.Elems {
+				if score, err := strconv.ParseFloat(strings.TrimSpace(e.Value), 64); err == nil && score > f.CVSS {
+					f.CVSS = score
+				}
+			}
+		}
+	}
+	switch risk := strings.ToLower(t.elem("risk_factor")); {
+	case types.SeverityRank(risk) >= 0:
+		f.Severity = risk
+	case f.CVSS > 0:
+		f.Severity = types.CVSSSeverity(f.CVSS)
+	}
+	if strings.Contains(state, "EXPLOITABLE") {
+		f.Exploit = true
+	}
+	return f, true
+}
+
+// firstLine returns the first line of script output with anything in it.
+func firstLine(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
@@ -0,0 +1,145 @@
+package scanner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// deepScanXML is the output of a deep scan of a NAS, trimmed to what is read.
+const deepScanXML = `<?xml version="1.0"?>
+<nmaprun>
+<host><status state="up"/>
+<address addr="192.168.1.10" addrtype="ipv4"/>
+<address addr="00:11:32:AA:BB:CC" addrtype="mac" vendor="Synology"/>
+<ports>
+<port protocol="tcp" portid="22"><state state="open"/>
+<service name="ssh" product="OpenSSH" version="7.4"><cpe>cpe:/a:openbsd:openssh:7.4</cpe></service>
+<script id="vulners" output="...">
+<table key="cpe:/a:openbsd:openssh:7.4">
+<table><elem key="is_exploit">false</elem><elem key="cvss">5.3</elem><elem key="id">CVE-2018-15919</elem><elem key="type">cve</elem></table>
+<table><elem key="is_exploit">true</elem><elem key="cvss">7.8</elem><elem key="id">CVE-2016-10012</elem><elem key="type">cve</elem></table>
+<table><elem key="is_exploit">true</elem><elem key="cvss">7.8</elem><elem key="id">EDB-ID:40888</elem><elem key="type">exploitdb</elem></table>
+</table>
+</script>
+</port>
+<port protocol="tcp" portid="25"><state state="closed"/><service name="smtp"/></port>
+<port protocol="tcp" portid="8080"><state state="open"/>
+<service name="http" product="Apache Tomcat" version="9.0.1"/>
+<script id="http-default-accounts" output="...">
+<table key="Apache Tomcat">
+<elem key="cpe">cpe:/a:apache:tomcat</elem>
+<table key="credentials"><table><elem key="username">tomcat</elem><elem key="password">tomcat</elem></table></table>
+</table>
+</script>
+</port>
+<port protocol="tcp" portid="21"><state state="open"/>
+<service name="ftp" product="vsftpd" version="3.0.3"/>
+<script id="ftp-anon" output="Anonymous FTP login allowed (FTP code 230)&#xa;drwxr-xr-x 2 0 0 4096 pub"/>
+</port>
+</ports>
+<hostscript>
+<script id="smb-vuln-ms17-010" output="...">
+<table key="CVE-2017-0143">
+<elem key="title">Remote Code Execution vulnerability in Microsoft SMBv1 servers (ms17-010)</elem>
+<elem key="state">VULNERABLE</elem>
+<table key="ids"><elem>CVE:CVE-2017-0143</elem></table>
+<elem key="risk_factor">HIGH</elem>
+</table>
+</script>
+<script id="smb-vuln-ms10-054" output="...">
+<table key="CVE-2010-2550">
+<elem key="title">SMB Remote Memory Corruption Vulnerability</elem>
+<elem key="state">NOT VULNERABLE</elem>
+</table>
+</script>
+</hostscript>
+</host>
+</nmaprun>`
+
+func TestParseDeepScan(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	scans, err := parseDeepScan([]byte(deepScanXML), "vulners,http-default-accounts", now)
+	if err != nil {
+		t.Fatalf("parseDeepScan: %v", err)
+	}
+	if len(scans) != 1 || scans[0].IP != "192.168.1.10" || !scans[0].Time.Equal(now) {
+		t.Fatalf("scans = %+v, want the NAS", scans)
+	}
+	scan := scans[0]
+	if len(scan.Services) != 3 || scan.Services[0].String() != "22/tcp OpenSSH 7.4" || scan.Services[0].CPE[0] != "cpe:/a:openbsd:openssh:7.4" {
+		t.Errorf("services = %+v, want the three open ports", scan.Services)
+	}
+
+	var got []string
+	for _, f := range scan.Findings {
+		got = append(got, strings.Join([]string{f.Severity, f.Check, f.ID, f.Title}, "|"))
+	}
+	want := []string{
+		"high|vulners|CVE-2016-10012|Known vulnerability in OpenSSH 7.4",
+		"high|smb-vuln-ms17-010|CVE-2017-0143|Remote Code Execution vulnerability in Microsoft SMBv1 servers (ms17-010)",
+		"high|http-default-accounts||Apache Tomcat accepts the login tomcat/tomcat",
+		"medium|vulners|CVE-2018-15919|Known vulnerability in OpenSSH 7.4",
+		"info|ftp-anon||vsftpd 3.0.3: Anonymous FTP login allowed (FTP code 230)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("findings:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if f := scan.Findings[0]; !f.Exploit || f.CVSS != 7.8 || f.Port != 22 || f.Protocol != "tcp" {
+		t.Errorf("first finding = %+v, want CVE-2016-10012 on 22/tcp with its exploit", f)
+	}
+	if f := scan.Findings[1]; f.Port != 0 {
+		t.Errorf("host script finding has port %d, want none", f.Port)
+	}
+}
+
+func TestDeepScanArgs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script in place of nmap")
+	}
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	script := "#!/bin/sh\necho \"$@\" > " + argsFile + "\necho '<nmaprun></nmaprun>'\n"
+	if err := os.WriteFile(filepath.Join(dir, "nmap"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	s := New(0)
+	s.SetPrivilege(Unprivileged)
+	if _, err := s.DeepScan(context.Background(), []string{"192.0.2.1", "192.0.2.2"}, DeepScanOptions{Ports: "22,80,8000-8100"}); err != nil {
+		t.Fatalf("DeepScan: %v", err)
+	}
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--unprivileged -Pn -sV --script vulners,http-default-accounts -p 22,80,8000-8100 -oX - 192.0.2.1 192.0.2.2"
+	if got := strings.TrimSpace(string(args)); got != want {
+		t.Errorf("nmap args = %q, want %q", got, want)
+	}
+
+	for _, opts := range []DeepScanOptions{
+		{Scripts: []string{"/tmp/evil.nse"}},
+		{Scripts: []string{"vuln and not dos"}},
+		{Ports: "22; rm -rf /"},
+	} {
+		if _, err := s.DeepScan(context.Background(), []string{"192.0.2.1"}, opts); err == nil {
+			t.Errorf("DeepScan accepted %+v", opts)
+		}
+	}
+}
+
+func TestCVSSSeverity(t *testing.T) {
+	for score, want := range map[float64]string{9.8: types.SeverityCritical, 7: types.SeverityHigh, 5.3: types.SeverityMedium, 2.1: types.SeverityLow, 0: types.SeverityInfo} {
+		if got := types.CVSSSeverity(score); got != want {
+			t.Errorf("CVSSSeverity(%v) = %q, want %q", score, got, want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package scanner
+
+import (
+	"strings"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// DeviceTypes lists every device type the UI has an icon for, in the order
+// they are offered when overriding one by hand.
+var DeviceTypes = []string{
+	"router", "server", "desktop", "laptop", "phone", "tablet", "tv",
+	"speaker", "printer", "camera", "console", "nas", "pi", "vm", "iot",
+}
+
+// typeRule maps a fragment of a hostname or vendor name to a device type.
+type typeRule struct {
+	match string
+	typ   string
+}
+
+// hostnameRules are checked before vendorRules, since a hostname is usually
+// chosen by a person or the device itself and says what the thing is, where a
+// vendor only says who made the network chip. An Apple MAC could be a phone,
+// a laptop or a TV box; "Sarahs-iPhone" can only be one of them.
+//
+// Order matters: the first match wins. Broad fragments such as "server" come
+// late, so "nas-backup-server" is still recognised as a NAS.
+var hostnameRules = []typeRule{
+	{"iphone", "phone"}, {"android", "phone"}, {"galaxy", "phone"},
+	{"pixel", "phone"}, {"oneplus", "phone"}, {"phone", "phone"},
+	{"ipad", "tablet"}, {"tablet", "tablet"}, {"kindle", "tablet"},
+	{"macbook", "laptop"}, {"laptop", "laptop"}, {"thinkpad", "laptop"},
+	{"notebook", "laptop"},
+	{"imac", "desktop"}, {"mac-mini", "desktop"}, {"macmini", "desktop"},
+	{"desktop", "desktop"}, {"workstation", "desktop"},
+	{"appletv", "tv"}, {"apple-tv", "tv"}, {"roku", "tv"}, {"chromecast", "tv"},
+	{"firetv", "tv"}, {"fire-tv", "tv"}, {"bravia", "tv"}, {"shield", "tv"},
+	{"tv", "tv"},
+	{"sonos", "speaker"}, {"homepod", "speaker"}, {"echo", "speaker"},
+	{"google-home", "speaker"}, {"nest-audio", "speaker"},
+	{"printer", "printer"}, {"laserjet", "printer"}, {"officejet", "printer"},
+	{"deskjet", "printer"}, {"epson", "printer"}, {"brother", "printer"},
+	{"camera", "camera"}, {"ipcam", "camera"}, {"webcam", "camera"},
+	{"doorbell", "camera"},
+	{"xbox", "console"}, {"playstation", "console"}, {"ps4", "console"},
+	{"ps5", "console"}, {"nintendo", "console"},
+	{"synology", "nas"}, {"diskstation", "nas"}, {"qnap", "nas"},
+	{"truenas", "nas"}, {"nas", "nas"},
+	{"raspberrypi", "pi"}, {"raspberry", "pi"},
+	{"router", "router"}, {"gateway", "router"}, {"firewall", "router"},
+	{"pfsense", "router"}, {"opnsense", "router"}, {"unifi", "router"},
+	{"access-point", "router"}, {"fritz", "router"},
+	{"server", "server"}, {"srv", "server"}, {"proxmox", "server"},
+	{"esxi", "server"}, {"docker", "server"},
+	{"shelly", "iot"}, {"tasmota", "iot"}, {"esp32", "iot"}, {"esp8266", "iot"},
+	{"esp-", "iot"}, {"tuya", "iot"}, {"hue-bridge", "iot"}, {"plug", "iot"},
+	{"thermostat", "iot"},
+}
+
+// vendorRules guess from the manufacturer of the network interface, for the
+// many devices that announce no hostname at all.
+var vendorRules = []typeRule{
+	{"raspberry pi", "pi"},
+	{"synology", "nas"}, {"qnap", "nas"},
+	{"vmware", "vm"}, {"pcs systemtechnik", "vm"}, {"qemu", "vm"},
+	{"xensource", "vm"}, {"parallels", "vm"},
+	{"ubiquiti", "router"}, {"mikrotik", "router"}, {"netgear", "router"},
+	{"tp-link", "router"}, {"cisco", "router"}, {"juniper", "router"},
+	{"avm", "router"}, {"zyxel", "router"}, {"arris", "router"},
+	{"hikvision", "camera"}, {"dahua", "camera"}, {"axis comm", "camera"},
+	{"reolink", "camera"}, {"wyze", "camera"},
+	{"sonos", "speaker"},
+	{"roku", "tv"}, {"lg electronics", "tv"}, {"vizio", "tv"}, {"tcl", "tv"},
+	{"seiko epson", "printer"}, {"brother", "printer"}, {"canon", "printer"},
+	{"lexmark", "printer"}, {"xerox", "printer"}, {"kyocera", "printer"},
+	{"nintendo", "console"}, {"sony interactive", "console"},
+	{"espressif", "iot"}, {"tuya", "iot"}, {"shelly", "iot"},
+	{"signify", "iot"}, {"philips lighting", "iot"}, {"nest labs", "iot"},
+	{"ecobee", "iot"},
+}
+
+// groupTypes covers devices the user has already sorted into a group, which
+// is a better hint than any guess when nothing more specific matched.
+var groupTypes = map[string]string{
+	"Server":  "server",
+	"Desktop": "desktop",
+	"Laptop":  "laptop",
+	"Mobile":  "phone",
+	"IoT":     "iot",
+	"Network": "router",
+	"Pi":      "pi",
+}
+
+// DetectType guesses what kind of device d is from its hostname, vendor and
+// group. It returns "" when there is nothing to go on.
+func DetectType(d *types.Device) string {
+	host := strings.ToLower(d.Hostname)
+	if host != "" {
+		for _, r := range hostnameRules {
+			if strings.Contains(host, r.match) {
+				return r.typ
+			}
+		}
+	}
+
+	vendor := strings.ToLower(ResolveVendor(d.Vendor, d.MAC))
+	if vendor != "" && vendor != "unknown" {
+		for _, r := range vendorRules {
+			if strings.Contains(vendor, r.match) {
+				return r.typ
+			}
+		}
+	}
+
+	return groupTypes[d.Group]
+}
+
+// ValidType reports whether t is a known device type, or "" to clear a manual
+// choice and go back to detection.
+func ValidType(t string) bool {
+	if t == "" {
+		return true
+	}
+	for _, known := range DeviceTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
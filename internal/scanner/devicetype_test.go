@@ -0,0 +1,43 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func TestDetectType(t *testing.T) {
+	tests := []struct {
+		name   string
+		device types.Device
+		want   string
+	}{
+		{"hostname", types.Device{Hostname: "Sams-iPhone.lan"}, "phone"},
+		{"hostname beats vendor", types.Device{Hostname: "macbook-pro", Vendor: "Raspberry Pi Trading Ltd"}, "laptop"},
+		{"specific before broad", types.Device{Hostname: "nas-backup-server"}, "nas"},
+		{"vendor", types.Device{Vendor: "Synology Incorporated"}, "nas"},
+		{"vendor from MAC", types.Device{MAC: "B8:27:EB:12:34:56"}, "pi"},
+		{"group", types.Device{Group: "Mobile"}, "phone"},
+		{"nothing to go on", types.Device{Hostname: "host-17"}, ""},
+	}
+
+	for _, tt := range tests {
+		if got := DetectType(&tt.device); got != tt.want {
+			t.Errorf("%s: DetectType = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidType(t *testing.T) {
+	for _, typ := range DeviceTypes {
+		if !ValidType(typ) {
+			t.Errorf("ValidType(%q) = false for a listed type", typ)
+		}
+	}
+	if !ValidType("") {
+		t.Error("an empty type should be accepted, to go back to detection")
+	}
+	if ValidType("toaster") {
+		t.Error("an unknown type should be rejected")
+	}
+}
@@ -0,0 +1,100 @@
+package scanner
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Privilege is whether scans may send the raw ARP and ICMP packets that
+// find MAC addresses, vendors and hosts that ignore TCP.
+type Privilege int
+
+const (
+	// NmapDecides leaves nmap to work out for itself what it may do, as it
+	// does right when run as root.
+	NmapDecides Privilege = iota
+	// Privileged tells nmap it may use raw sockets although it is not root,
+	// as it may when it has been given the cap_net_raw capability.
+	Privileged
+	// Unprivileged keeps scans to TCP connections, which any user may make.
+	// Probes that need raw sockets are left out rather than failing the scan,
+	// and there is no falling back to arp-scan, which needs them too.
+	Unprivileged
+)
+
+// rawProbes are the nmap host discovery options that need raw sockets: ICMP
+// echo and timestamp, UDP, SCTP and IP protocol pings. TCP pings become
+// connection attempts when unprivileged.
+var rawProbes = []string{"-PE", "-PP", "-PM", "-PU", "-PY", "-PO"}
+
+// ChoosePrivilege returns how to scan given the privileged setting, "true",
+// "false" or "auto", with the reason. Auto looks at whether the process is
+// root and, on Linux, whether nmap has been given cap_net_raw.
+func ChoosePrivilege(setting string) (Privilege, string) {
+	switch strings.ToLower(setting) {
+	case "true", "yes", "1", "on":
+		return Privileged, "privileged is set in the config"
+	case "false", "no", "0", "off":
+		return Unprivileged, "privileged is off in the config"
+	}
+	if runtime.GOOS == "windows" {
+		return NmapDecides, "not checked on Windows"
+	}
+	if os.Geteuid() == 0 {
+		return NmapDecides, "running as root"
+	}
+	if runtime.GOOS == "linux" && nmapHasRawCapability() {
+		return Privileged, "nmap has the cap_net_raw capability"
+	}
+	return Unprivileged, "not running as root"
+}
+
+// nmapHasRawCapability reports whether the nmap on the PATH has been given
+// cap_net_raw with setcap.
+func nmapHasRawCapability() bool {
+	nmap, err := exec.LookPath("nmap")
+	if err != nil {
+		return false
+	}
+	out, err := exec.Command("getcap", nmap).Output()
+	return err == nil && strings.Contains(string(out), "cap_net_raw")
+}
+
+// SetPrivilege sets how the scanner's scans reach the network.
+func (s *Scanner) SetPrivilege(p Privilege) {
+	s.privilege = p
+}
+
+// Privilege returns how the scanner's scans reach the network.
+func (s *Scanner) Privilege() Privilege {
+	return s.privilege
+}
+
+// privilegeArgs returns a copy of the nmap arguments args adapted to the
+// scanner's privilege.
+func (s *Scanner) privilegeArgs(args []string) []string {
+	switch s.privilege {
+	case Privileged:
+		return append([]string{"--privileged"}, args...)
+	case Unprivileged:
+		kept := []string{"--unprivileged"}
+		for _, arg := range args {
+			if !isRawProbe(arg) {
+				kept = append(kept, arg)
+			}
+		}
+		return kept
+	}
+	return append([]string{}, args...)
+}
+
+func isRawProbe(arg string) bool {
+	for _, p := range rawProbes {
+		if strings.HasPrefix(arg, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -4,7 +4,9 @@ package scanner
 import (
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net"
 	"os/exec"
 	"runtime"
@@ -18,6 +20,11 @@ import (
 // Scanner performs network scans
 type Scanner struct {
 	minInterval time.Duration
+	privilege   Privilege
+	// backends are the names of the backends to try, in order.
+	backends []string
+	// sources are asked about each network after it is swept.
+	sources []Source
 }
 
 // New creates a new Scanner
@@ -69,13 +76,51 @@ type nmapTimes struct {
 	SRTT string `xml:"srtt,attr"`
 }
 
+// Options tailor the scan of one network.
+type Options struct {
+	// Profile is how hard nmap looks for hosts: "quick", "normal" or
+	// "thorough". Empty means normal.
+	Profile string
+
+	// Exclude are addresses and CIDRs to leave alone, such as a printer that
+	// wakes up for every ping. They are neither probed, where the scanning
+	// tool allows it, nor reported.
+	Exclude []string
+}
+
+// profileArgs are the nmap host discovery options of each profile.
+var profileArgs = map[string][]string{
+	"quick":  {"-sn", "-T4", "--max-retries", "1"},
+	"normal": {"-sn"},
+	// Probes that get past hosts which ignore ping, such as Windows machines
+	// with their default firewall.
+	"thorough": {"-sn", "-PE", "-PP", "-PS21,22,23,25,80,443,445,3389,8080", "-PA80,443", "-PU53,161"},
+}
+
+// ValidProfile reports whether p names a scan profile.
+func ValidProfile(p string) bool {
+	_, ok := profileArgs[p]
+	return ok
+}
+
 // Scan performs a network scan on the given CIDR
 func (s *Scanner) Scan(ctx context.Context, cidr string) (*types.ScanResult, error) {
+	return s.ScanWith(ctx, cidr, Options{})
+}
+
+// ScanWith scans the given CIDR as opts say.
+func (s *Scanner) ScanWith(ctx context.Context, cidr string, opts Options) (*types.ScanResult, error) {
 	// Validate CIDR
 	_, _, err := net.ParseCIDR(cidr)
 	if err != nil {
 		return nil, fmt.Errorf("invalid CIDR: %w", err)
 	}
+	if opts.Profile == "" {
+		opts.Profile = "normal"
+	}
+	if !ValidProfile(opts.Profile) {
+		return nil, fmt.Errorf("unknown scan profile %q", opts.Profile)
+	}
 
 	startTime := time.Now()
 
@@ -83,25 +128,32 @@ func (s *Scanner) Scan(ctx context.Context, cidr string) (*types.ScanResult, err
 	// and a scan could only ever find this machine. Tailscale already knows the
 	// whole tailnet, so ask it for the peers instead.
 	if network.IsTailscaleNetwork(cidr) {
-		return s.scanTailscale(cidr, startTime), nil
+		return withoutExcluded(s.scanTailscale(cidr, startTime), opts.Exclude), nil
 	}
 
-	// Try nmap first
-	devices, scanner, err := s.scanWithNmap(ctx, cidr)
+	devices, scanner, err := s.scanWithBackends(ctx, cidr, opts)
+	if err != nil && ctx.Err() != nil {
+		// Out of time, or cancelled: the next backend would get no further,
+		// and its failure would hide why.
+		return &types.ScanResult{
+			Success:   false,
+			Error:     scanStopped(ctx, startTime),
+			Network:   cidr,
+			Timestamp: time.Now(),
+		}, nil
+	}
 	if err != nil {
-		// Fallback to arp-scan
-		devices, scanner, err = s.scanWithArpScan(ctx, cidr)
-		if err != nil {
-			return &types.ScanResult{
-				Success:   false,
-				Error:     err.Error(),
-				Network:   cidr,
-				Timestamp: time.Now(),
-			}, nil
-		}
+		return &types.ScanResult{
+			Success:   false,
+			Error:     err.Error(),
+			Network:   cidr,
+			Timestamp: time.Now(),
+		}, nil
 	}
 
+	devices = filterExcluded(s.withSources(ctx, cidr, devices), opts.Exclude)
 	duration := time.Since(startTime).Seconds()
+	slog.Debug("scan finished", "network", cidr, "scanner", scanner, "devices", len(devices), "seconds", duration)
 
 	return &types.ScanResult{
 		Success:     true,
@@ -138,22 +190,29 @@ func (s *Scanner) scanTailscale(cidr string, startTime time.Time) *types.ScanRes
 }
 
 // scanWithNmap performs a scan using nmap
-func (s *Scanner) scanWithNmap(ctx context.Context, cidr string) ([]types.Device, string, error) {
+func (s *Scanner) scanWithNmap(ctx context.Context, cidr string, opts Options) ([]types.Device, string, error) {
 	// Check if nmap is available
 	if _, err := exec.LookPath("nmap"); err != nil {
 		return nil, "", fmt.Errorf("nmap not found")
 	}
 
 	// Run nmap with ping scan and XML output
-	cmd := exec.CommandContext(ctx, "nmap", "-sn", "-oX", "-", cidr)
+	args := s.privilegeArgs(profileArgs[opts.Profile])
+	if len(opts.Exclude) > 0 {
+		args = append(args, "--exclude", strings.Join(opts.Exclude, ","))
+	}
+	args = append(args, "-oX", "-", cidr)
+	cmd := exec.CommandContext(ctx, "nmap", args...)
+	slog.Debug("running nmap", "args", cmd.Args[1:])
 	output, err := cmd.Output()
 	if err != nil {
-		return nil, "", fmt.Errorf("nmap failed: %w", err)
+		return nil, "", fmt.Errorf("nmap failed: %w%s", err, stderrOf(err))
 	}
 
 	// Parse XML output
 	var result nmapRun
 	if err := xml.Unmarshal(output, &result); err != nil {
+		slog.Debug("unreadable nmap output", "output", string(output))
 		return nil, "", fmt.Errorf("failed to parse nmap output: %w", err)
 	}
 
@@ -197,13 +256,15 @@ func (s *Scanner) scanWithNmap(ctx context.Context, cidr string) ([]types.Device
 
 		// Try reverse DNS if no hostname
 		if device.Hostname == "" {
-			device.Hostname = reverseDNS(device.IP)
+			device.Hostname = ReverseDNS(device.IP)
 		}
 
 		// Parse response time
 		if host.Times.SRTT != "" {
 			if srtt, err := parseResponseTime(host.Times.SRTT); err == nil {
 				device.ResponseTime = &srtt
+			} else {
+				slog.Debug("ignoring unreadable nmap response time", "ip", device.IP, "srtt", host.Times.SRTT)
 			}
 		}
 
@@ -229,9 +290,10 @@ func (s *Scanner) scanWithArpScan(ctx context.Context, cidr string) ([]types.Dev
 		args = append(args, "-I", iface)
 	}
 	cmd := exec.CommandContext(ctx, "arp-scan", args...)
+	slog.Debug("running arp-scan", "args", args)
 	output, err := cmd.Output()
 	if err != nil {
-		return nil, "", fmt.Errorf("arp-scan failed: %w", err)
+		return nil, "", fmt.Errorf("arp-scan failed: %w%s", err, stderrOf(err))
 	}
 
 	// Parse output (format: IP\tMAC\tVendor)
@@ -253,6 +315,7 @@ func (s *Scanner) scanWithArpScan(ctx context.Context, cidr string) ([]types.Dev
 
 		// Validate IP
 		if net.ParseIP(ip) == nil {
+			slog.Debug("skipping arp-scan line", "line", line)
 			continue
 		}
 
@@ -270,7 +333,7 @@ func (s *Scanner) scanWithArpScan(ctx context.Context, cidr string) ([]types.Dev
 		}
 
 		// Try reverse DNS
-		device.Hostname = reverseDNS(ip)
+		device.Hostname = ReverseDNS(ip)
 
 		devices = append(devices, device)
 	}
@@ -278,8 +341,81 @@ func (s *Scanner) scanWithArpScan(ctx context.Context, cidr string) ([]types.Dev
 	return devices, "arp-scan", nil
 }
 
-// reverseDNS performs a reverse DNS lookup
-func reverseDNS(ip string) string {
+// withoutExcluded drops the excluded devices from a successful result.
+func withoutExcluded(result *types.ScanResult, exclude []string) *types.ScanResult {
+	if result.Success {
+		result.Devices = filterExcluded(result.Devices, exclude)
+		result.DeviceCount = len(result.Devices)
+	}
+	return result
+}
+
+// filterExcluded returns the devices whose addresses are not in exclude.
+// arp-scan cannot be told to skip addresses, so this is what keeps excluded
+// devices out of the results whichever tool ran.
+func filterExcluded(devices []types.Device, exclude []string) []types.Device {
+	if len(exclude) == 0 {
+		return devices
+	}
+	kept := devices[:0]
+	for _, d := range devices {
+		if !excluded(d.IP, exclude) {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
+
+// excluded reports whether ip is one of the addresses, or inside one of the
+// CIDRs, in exclude. Entries that are neither are ignored.
+func excluded(ip string, exclude []string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, e := range exclude {
+		if strings.Contains(e, "/") {
+			if _, ipNet, err := net.ParseCIDR(e); err == nil && ipNet.Contains(addr) {
+				return true
+			}
+		} else if other := net.ParseIP(e); other != nil && other.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanStopped describes why a scan stopped early.
+func scanStopped(ctx context.Context, startTime time.Time) string {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		// The time allowed, rather than taken: a tool slow to die after being
+		// killed would otherwise make the limit look longer than it is.
+		limit := time.Since(startTime)
+		if deadline, ok := ctx.Deadline(); ok {
+			limit = deadline.Sub(startTime)
+		}
+		return fmt.Sprintf("scan timed out after %s; raise scan_timeout for networks this large", limit.Round(time.Second))
+	}
+	return "scan cancelled"
+}
+
+// stderrOf returns what a failed command wrote to standard error, as a
+// suffix for its error. The exit status alone rarely says what went wrong.
+func stderrOf(err error) string {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return ""
+	}
+	msg := strings.TrimSpace(string(exitErr.Stderr))
+	if msg == "" {
+		return ""
+	}
+	return ": " + msg
+}
+
+// ReverseDNS looks up the hostname for ip, giving up after two seconds. It
+// returns "" when the address has no name.
+func ReverseDNS(ip string) string {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
@@ -0,0 +1,158 @@
+package scanner
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScanReportsTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script in place of nmap")
+	}
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep not found")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\nexec " + sleep + " 5\n"
+	if err := os.WriteFile(filepath.Join(dir, "nmap"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	result, err := New(0).Scan(ctx, "192.0.2.0/24")
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if result.Success || !strings.Contains(result.Error, "timed out") {
+		t.Errorf("result = %+v, want a timeout rather than arp-scan's failure", result)
+	}
+}
+
+func TestBackendOrder(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts in place of nmap and arp-scan")
+	}
+	dir := t.TempDir()
+	ran := filepath.Join(dir, "ran")
+	for name, script := range map[string]string{
+		"nmap":     "#!/bin/sh\necho nmap >> " + ran + "\nexit 1\n",
+		"arp-scan": "#!/bin/sh\necho arp-scan >> " + ran + "\nprintf '192.0.2.9\\t00:11:22:33:44:55\\tAcme\\n'\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+
+	for _, tt := range []struct {
+		backends []string
+		success  bool
+		ran      string
+	}{
+		{[]string{"arp-scan", "nmap"}, true, "arp-scan"},
+		{[]string{"nmap", "arp-scan"}, true, "nmap arp-scan"},
+		{[]string{"nmap"}, false, "nmap"},
+	} {
+		os.Remove(ran)
+		s := New(0)
+		s.SetBackends(tt.backends)
+		result, err := s.ScanWith(context.Background(), "192.0.2.0/24", Options{})
+		if err != nil {
+			t.Fatalf("ScanWith: %v", err)
+		}
+		if result.Success != tt.success {
+			t.Errorf("backends %v: success = %v (%s), want %v", tt.backends, result.Success, result.Error, tt.success)
+		}
+		if tt.success && (result.Scanner != "arp-scan" || result.DeviceCount != 1) {
+			t.Errorf("backends %v: result = %+v, want one device from arp-scan", tt.backends, result)
+		}
+		out, _ := os.ReadFile(ran)
+		if got := strings.Join(strings.Fields(string(out)), " "); got != tt.ran {
+			t.Errorf("backends %v ran %q, want %q", tt.backends, got, tt.ran)
+		}
+	}
+}
+
+func TestPrivilegeArgs(t *testing.T) {
+	thorough := profileArgs["thorough"]
+	for _, tt := range []struct {
+		privilege Privilege
+		want      string
+	}{
+		{NmapDecides, strings.Join(thorough, " ")},
+		{Privileged, "--privileged " + strings.Join(thorough, " ")},
+		// ICMP and UDP pings need raw sockets; TCP pings become connections.
+		{Unprivileged, "--unprivileged -sn -PS21,22,23,25,80,443,445,3389,8080 -PA80,443"},
+	} {
+		s := New(0)
+		s.SetPrivilege(tt.privilege)
+		if got := strings.Join(s.privilegeArgs(thorough), " "); got != tt.want {
+			t.Errorf("privilege %d: args = %q, want %q", tt.privilege, got, tt.want)
+		}
+	}
+	if got := strings.Join(profileArgs["thorough"], " "); got != strings.Join(thorough, " ") {
+		t.Errorf("privilegeArgs changed the profile to %q", got)
+	}
+}
+
+func TestChoosePrivilegeFollowsTheConfig(t *testing.T) {
+	if p, _ := ChoosePrivilege("false"); p != Unprivileged {
+		t.Errorf("privileged = false gave %d, want Unprivileged", p)
+	}
+	if p, _ := ChoosePrivilege("true"); p != Privileged {
+		t.Errorf("privileged = true gave %d, want Privileged", p)
+	}
+}
+
+func TestScanWithProfileAndExclusions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script in place of nmap")
+	}
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	script := `#!/bin/sh
+echo "$@" > ` + argsFile + `
+echo '<nmaprun>'
+for ip in 192.0.2.1 192.0.2.7 192.0.2.130; do
+	echo "<host><status state=\"up\"/><address addr=\"$ip\" addrtype=\"ipv4\"/></host>"
+done
+echo '</nmaprun>'
+`
+	if err := os.WriteFile(filepath.Join(dir, "nmap"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	opts := Options{Profile: "quick", Exclude: []string{"192.0.2.7", "192.0.2.128/25"}}
+	result, err := New(0).ScanWith(context.Background(), "192.0.2.0/24", opts)
+	if err != nil {
+		t.Fatalf("ScanWith: %v", err)
+	}
+	// The fake nmap ignores --exclude, as arp-scan would, so the results must
+	// be filtered too.
+	if len(result.Devices) != 1 || result.Devices[0].IP != "192.0.2.1" || result.DeviceCount != 1 {
+		t.Errorf("devices = %+v, want only 192.0.2.1", result.Devices)
+	}
+
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "-sn -T4 --max-retries 1 --exclude 192.0.2.7,192.0.2.128/25 -oX - 192.0.2.0/24"
+	if got := strings.TrimSpace(string(args)); got != want {
+		t.Errorf("nmap args = %q, want %q", got, want)
+	}
+
+	if _, err := New(0).ScanWith(context.Background(), "192.0.2.0/24", Options{Profile: "fast"}); err == nil {
+		t.Error("ScanWith accepted an unknown profile")
+	}
+}
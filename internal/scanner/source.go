@@ -0,0 +1,108 @@
+package scanner
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// Source is something other than the network itself that knows the devices
+// on it, such as a DNS server that hands out DHCP leases. A scan asks each
+// source after sweeping the network, to name the devices it found and to
+// add those it missed.
+type Source interface {
+	// Name says what the source is, for the log.
+	Name() string
+	// Devices returns the devices the source knows of in network. Those
+	// with LastSeen set were active lately, and are added to the scan's
+	// results when it did not find them; the rest only fill in what it did.
+	Devices(ctx context.Context, network *net.IPNet) ([]types.Device, error)
+}
+
+// AddSource has scans ask source about the devices on each network.
+func (s *Scanner) AddSource(source Source) {
+	s.sources = append(s.sources, source)
+}
+
+// withSources returns the devices a scan of cidr found, filled in and added
+// to from each source. A source that fails is logged and left out, as the
+// scan itself still stands.
+func (s *Scanner) withSources(ctx context.Context, cidr string, devices []types.Device) []types.Device {
+	if len(s.sources) == 0 {
+		return devices
+	}
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return devices
+	}
+	for _, source := range s.sources {
+		known, err := source.Devices(ctx, network)
+		if err != nil {
+			slog.Warn("device source failed", "source", source.Name(), "network", cidr, "error", err)
+			continue
+		}
+		devices = mergeSourced(devices, known)
+	}
+	return devices
+}
+
+// mergeSourced fills in the devices a scan found from those a source knows,
+// matching them by IP address or else MAC address, and adds the source's
+// lately active devices that the scan did not find. Only what the scan left
+// empty is filled in, tags are added, and a source's Wi-Fi details, which
+// the scan cannot know, are taken as they are.
+func mergeSourced(devices, known []types.Device) []types.Device {
+	byIP := make(map[string]int, len(devices))
+	byMAC := make(map[string]int, len(devices))
+	index := func(i int) {
+		byIP[devices[i].IP] = i
+		if devices[i].MAC != "" {
+			byMAC[strings.ToUpper(devices[i].MAC)] = i
+		}
+	}
+	for i := range devices {
+		index(i)
+	}
+
+	for _, k := range known {
+		i, ok := byIP[k.IP]
+		if !ok && k.MAC != "" {
+			i, ok = byMAC[strings.ToUpper(k.MAC)]
+		}
+		if !ok {
+			if k.IP == "" || k.LastSeen.IsZero() {
+				continue
+			}
+			// It did not answer the scan, so there is no time to give.
+			k.ResponseTime = nil
+			if k.Vendor == "" && k.MAC != "" {
+				k.Vendor = GetMACVendor(k.MAC)
+			}
+			devices = append(devices, k)
+			index(len(devices) - 1)
+			continue
+		}
+
+		d := &devices[i]
+		if d.Hostname == "" {
+			d.Hostname = k.Hostname
+		}
+		if d.MAC == "" && k.MAC != "" {
+			d.MAC = k.MAC
+			byMAC[strings.ToUpper(k.MAC)] = i
+		}
+		if (d.Vendor == "" || d.Vendor == "Unknown") && k.Vendor != "" {
+			d.Vendor = k.Vendor
+		}
+		for _, tag := range k.Tags {
+			d.AddTag(tag)
+		}
+		if k.Wireless != nil {
+			d.Wireless = k.Wireless
+		}
+	}
+	return devices
+}
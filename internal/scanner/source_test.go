@@ -0,0 +1,37 @@
+package scanner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func TestMergeSourced(t *testing.T) {
+	rt := 1.5
+	scanned := []types.Device{
+		{IP: "192.168.1.5", ResponseTime: &rt},
+		{IP: "192.168.1.6", MAC: "AA:BB:CC:00:00:06", Hostname: "nas.lan", Vendor: "Synology"},
+	}
+	known := []types.Device{
+		{IP: "192.168.1.5", MAC: "aa:bb:cc:00:00:05", Hostname: "phone", Vendor: "Apple", Tags: []string{"pihole:kids"}},
+		// Matched by MAC after DHCP moved it; the scan's own details win.
+		{IP: "192.168.1.60", MAC: "aa:bb:cc:00:00:06", Hostname: "old-name", Vendor: "Other"},
+		{IP: "192.168.1.7", Hostname: "tv", LastSeen: time.Now()},
+		{IP: "192.168.1.8", Hostname: "gone"},
+	}
+
+	got := mergeSourced(scanned, known)
+	if len(got) != 3 {
+		t.Fatalf("devices = %+v, want the scanned two and the active tv", got)
+	}
+	if d := got[0]; d.Hostname != "phone" || d.MAC != "aa:bb:cc:00:00:05" || d.Vendor != "Apple" || !d.HasTag("pihole:kids") || d.ResponseTime != &rt {
+		t.Errorf("phone = %+v", d)
+	}
+	if d := got[1]; d.IP != "192.168.1.6" || d.Hostname != "nas.lan" || d.Vendor != "Synology" {
+		t.Errorf("nas = %+v", d)
+	}
+	if d := got[2]; d.IP != "192.168.1.7" || d.Hostname != "tv" || d.ResponseTime != nil {
+		t.Errorf("tv = %+v", d)
+	}
+}
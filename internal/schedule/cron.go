@@ -0,0 +1,254 @@
+// Package schedule reads cron expressions, which say when a network is
+// scanned where a fixed interval is too blunt: often during the day, say, and
+// hourly at night.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a set of cron expressions. A time is in it when it is in any
+// of them, so two expressions can give the day and the night different
+// rhythms:
+//
+//	*/15 7-22 * * *; 0 23,0-6 * * *
+type Schedule struct {
+	specs []spec
+}
+
+// spec is one cron expression, as the set of values each field allows.
+type spec struct {
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny record a * in the day fields. As in cron, when both
+	// are restricted a day matching either is enough.
+	domAny, dowAny bool
+}
+
+// field describes one of the five fields of an expression.
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Sunday is both 0 and 7, as in most crons.
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// macros are the shorthands cron accepts for common expressions.
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse reads one or more cron expressions separated by semicolons. Each has
+// the five fields minute, hour, day of month, month and day of week, with *,
+// lists, ranges and steps as in cron, or is a macro such as @hourly.
+//
+// Expressions may also be separated by a comma and a space, which is how a
+// list in a YAML or TOML config file reaches the parser; a comma inside a
+// field is never followed by a space.
+func Parse(s string) (*Schedule, error) {
+	var sched Schedule
+	for _, part := range splitSpecs(s) {
+		sp, err := parseSpec(part)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", part, err)
+		}
+		sched.specs = append(sched.specs, sp)
+	}
+	if len(sched.specs) == 0 {
+		return nil, fmt.Errorf("no cron expression")
+	}
+	return &sched, nil
+}
+
+// splitSpecs splits s at semicolons and at commas followed by spaces.
+func splitSpecs(s string) []string {
+	var parts []string
+	for _, chunk := range strings.Split(s, ";") {
+		for {
+			i := strings.Index(chunk, ", ")
+			if i < 0 {
+				break
+			}
+			parts = append(parts, chunk[:i])
+			chunk = chunk[i+1:]
+		}
+		parts = append(parts, chunk)
+	}
+	var specs []string
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			specs = append(specs, p)
+		}
+	}
+	return specs
+}
+
+func parseSpec(s string) (spec, error) {
+	if expanded, ok := macros[strings.ToLower(s)]; ok {
+		s = expanded
+	}
+	fields := strings.Fields(s)
+	if len(fields) != 5 {
+		return spec{}, fmt.Errorf("expected 5 fields (minute hour day month weekday), got %d", len(fields))
+	}
+
+	var sp spec
+	var err error
+	if sp.minute, err = minuteField.parse(fields[0]); err != nil {
+		return spec{}, err
+	}
+	if sp.hour, err = hourField.parse(fields[1]); err != nil {
+		return spec{}, err
+	}
+	if sp.dom, err = domField.parse(fields[2]); err != nil {
+		return spec{}, err
+	}
+	if sp.month, err = monthField.parse(fields[3]); err != nil {
+		return spec{}, err
+	}
+	if sp.dow, err = dowField.parse(fields[4]); err != nil {
+		return spec{}, err
+	}
+	if sp.dow&(1<<7) != 0 {
+		sp.dow |= 1 // Sunday
+	}
+	sp.domAny = strings.HasPrefix(fields[2], "*")
+	sp.dowAny = strings.HasPrefix(fields[4], "*")
+	return sp, nil
+}
+
+// parse returns the values s allows as a bit set.
+func (f field) parse(s string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(s, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("%s: %q is not a step", f.name, stepStr)
+			}
+			step = n
+		}
+
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(a); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(b); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("%s: range %s runs backwards", f.name, rng)
+			}
+		default:
+			v, err := f.value(rng)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			// As in cron, 5/10 means from 5 to the end in steps of 10.
+			if !hasStep {
+				hi = v
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// value reads a single number or name in the field.
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s: %q is not between %d and %d", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next returns the first whole minute after t that is in the schedule, in
+// t's location, or the zero time if there is none within five years, as for
+// the 30th of February.
+func (s *Schedule) Next(t time.Time) time.Time {
+	var next time.Time
+	for _, sp := range s.specs {
+		if n := sp.next(t); !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+	}
+	return next
+}
+
+func (sp spec) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if sp.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !sp.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if sp.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if sp.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches applies cron's rule for the two day fields: when both are
+// restricted, a day matching either is enough.
+func (sp spec) dayMatches(t time.Time) bool {
+	dom := sp.dom&(1<<uint(t.Day())) != 0
+	dow := sp.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case sp.domAny && sp.dowAny:
+		return true
+	case sp.domAny:
+		return dow
+	case sp.dowAny:
+		return dom
+	}
+	return dom || dow
+}
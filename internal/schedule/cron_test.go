@@ -0,0 +1,80 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	// A Wednesday.
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.March, day, hour, minute, 0, 0, time.UTC)
+	}
+	dayAndNight := "*/15 7-22 * * *; 0 23,0-6 * * *"
+
+	for _, tc := range []struct {
+		spec     string
+		from     time.Time
+		want     time.Time
+		describe string
+	}{
+		{dayAndNight, at(4, 9, 7), at(4, 9, 15), "next quarter hour by day"},
+		{dayAndNight, at(4, 22, 50), at(4, 23, 0), "on the hour at night"},
+		{dayAndNight, at(4, 23, 0), at(5, 0, 0), "strictly after the given time"},
+		{dayAndNight, at(5, 6, 30), at(5, 7, 0), "back to quarter hours in the morning"},
+		{"30 8 * * mon-fri", at(6, 9, 0), at(9, 8, 30), "Friday after the time goes to Monday"},
+		{"0 0 1,15 * *", at(4, 12, 0), at(15, 0, 0), "days of the month"},
+		// Both day fields restricted: either will do, as in cron.
+		{"0 12 1 * sun", at(4, 13, 0), at(8, 12, 0), "day of month or weekday"},
+		{"0 12 * * 7", at(4, 13, 0), at(8, 12, 0), "7 is Sunday"},
+		{"5/20 * * * *", at(4, 10, 30), at(4, 10, 45), "a step from a start"},
+		{"@hourly", at(4, 10, 30), at(4, 11, 0), "a macro"},
+		{"0 0 1 jan *", at(4, 0, 0), time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC), "into next year"},
+	} {
+		s, err := Parse(tc.spec)
+		if err != nil {
+			t.Errorf("%s: Parse(%q): %v", tc.describe, tc.spec, err)
+			continue
+		}
+		if got := s.Next(tc.from); !got.Equal(tc.want) {
+			t.Errorf("%s: Next(%s) = %s, want %s", tc.describe, tc.from, got, tc.want)
+		}
+	}
+}
+
+func TestNextNeverComes(t *testing.T) {
+	s, err := Parse("0 0 30 feb *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := s.Next(time.Now()); !next.IsZero() {
+		t.Errorf("Next = %s, want the zero time", next)
+	}
+}
+
+func TestYAMLListIsSplit(t *testing.T) {
+	// How a config file list reaches Parse.
+	s, err := Parse("*/15 7-22 * * *, 0 23,0-6 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.specs) != 2 {
+		t.Errorf("got %d expressions, want 2", len(s.specs))
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 5-2 * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+		"every 15 minutes",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) succeeded", spec)
+		}
+	}
+}
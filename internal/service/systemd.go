@@ -0,0 +1,199 @@
+// Package service writes the systemd units `orangutan install-service`
+// installs: one running the server, and a service and timer running
+// scheduled scans.
+//
+// The units are generated rather than shipped as files so that they name the
+// binary, config file and data directory actually in use, and grant a
+// service user just the capabilities scanning needs instead of running it as
+// root.
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Names of the units, as installed in /etc/systemd/system.
+const (
+	ServerUnitName = "lan-orangutan.service"
+	ScanUnitName   = "lan-orangutan-scan.service"
+	ScanTimerName  = "lan-orangutan-scan.timer"
+)
+
+// Options describe the units to generate.
+type Options struct {
+	// Binary, ConfigFile and DataDir are absolute paths.
+	Binary     string
+	ConfigFile string
+	DataDir    string
+	// Profile is the config profile the services use, or "" for none.
+	Profile string
+	// User and Group the services run as. Anything but root is given the
+	// capabilities scanning needs.
+	User  string
+	Group string
+	// Serve installs the server unit, listening on Port.
+	Serve bool
+	Port  int
+	// ScanEvery installs the scan timer when more than zero.
+	ScanEvery time.Duration
+	// Server is the address scheduled scans reach the server on. They go
+	// through it when it runs, since a scan writing the data files under a
+	// running server would be overwritten by it.
+	Server string
+}
+
+// Unit is a unit file.
+type Unit struct {
+	Name    string
+	Content string
+}
+
+// Units returns the unit files for o, the server's first.
+func Units(o Options) ([]Unit, error) {
+	if err := o.check(); err != nil {
+		return nil, err
+	}
+
+	var units []Unit
+	if o.Serve {
+		units = append(units, Unit{ServerUnitName, serverUnit(o)})
+	}
+	if o.ScanEvery > 0 {
+		units = append(units, Unit{ScanUnitName, scanUnit(o)}, Unit{ScanTimerName, scanTimer(o)})
+	}
+	if len(units) == 0 {
+		return nil, fmt.Errorf("nothing to install: neither the server nor scheduled scans")
+	}
+	return units, nil
+}
+
+// check rejects values a unit file cannot hold. Paths are written unquoted, and
+// ReadWritePaths splits at spaces.
+func (o Options) check() error {
+	for _, v := range []struct{ name, value string }{
+		{"binary", o.Binary},
+		{"config file", o.ConfigFile},
+		{"data directory", o.DataDir},
+	} {
+		if !strings.HasPrefix(v.value, "/") {
+			return fmt.Errorf("%s %q is not an absolute path", v.name, v.value)
+		}
+		if strings.ContainsAny(v.value, " \t\n\"'\\%") {
+			return fmt.Errorf("%s %q has characters a systemd unit cannot hold; use a path without spaces or quotes", v.name, v.value)
+		}
+	}
+	if strings.ContainsAny(o.Profile, " \t\n\"'\\%") {
+		return fmt.Errorf("profile %q has characters a systemd unit cannot hold", o.Profile)
+	}
+	for _, v := range []string{o.User, o.Group} {
+		if v == "" || strings.ContainsAny(v, " \t\n\"'\\%") {
+			return fmt.Errorf("invalid user or group %q", v)
+		}
+	}
+	if o.ScanEvery > 0 && o.ScanEvery < time.Minute {
+		return fmt.Errorf("scans every %s are too frequent; use at least a minute", o.ScanEvery)
+	}
+	return nil
+}
+
+// command returns the start of the command lines of the services: the
+// binary with the config file and profile to use.
+func (o Options) command() string {
+	cmd := fmt.Sprintf("%s --config %s", o.Binary, o.ConfigFile)
+	if o.Profile != "" {
+		cmd += " --profile " + o.Profile
+	}
+	return cmd
+}
+
+func serverUnit(o Options) string {
+	var b strings.Builder
+	b.WriteString(`[Unit]
+Description=LAN Orangutan Network Discovery
+Documentation=https://github.com/291-Group/LAN-Orangutan
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+`)
+	fmt.Fprintf(&b, "User=%s\nGroup=%s\n", o.User, o.Group)
+	fmt.Fprintf(&b, "ExecStart=%s serve\n", o.command())
+	// The server reloads its config on SIGHUP, for systemctl reload.
+	b.WriteString("ExecReload=/bin/kill -HUP $MAINPID\n")
+	b.WriteString("Restart=on-failure\nRestartSec=10\n")
+	caps := []string{"CAP_NET_RAW", "CAP_NET_ADMIN"}
+	if o.Port > 0 && o.Port < 1024 {
+		caps = append(caps, "CAP_NET_BIND_SERVICE")
+	}
+	writeSandbox(&b, o, caps)
+	b.WriteString("\n[Install]\nWantedBy=multi-user.target\n")
+	return b.String()
+}
+
+func scanUnit(o Options) string {
+	var b strings.Builder
+	b.WriteString(`[Unit]
+Description=LAN Orangutan scheduled scan
+Documentation=https://github.com/291-Group/LAN-Orangutan
+After=network-online.target
+Wants=network-online.target
+`)
+	if o.Server != "" {
+		fmt.Fprintf(&b, "After=%s\n", ServerUnitName)
+	}
+	b.WriteString("\n[Service]\nType=oneshot\n")
+	fmt.Fprintf(&b, "User=%s\nGroup=%s\n", o.User, o.Group)
+	if o.Server != "" {
+		// The server does the scanning, so this needs no capabilities.
+		fmt.Fprintf(&b, "ExecStart=%s --server %s scan all\n", o.command(), o.Server)
+		writeSandbox(&b, o, nil)
+	} else {
+		fmt.Fprintf(&b, "ExecStart=%s scan all\n", o.command())
+		writeSandbox(&b, o, []string{"CAP_NET_RAW", "CAP_NET_ADMIN"})
+	}
+	return b.String()
+}
+
+func scanTimer(o Options) string {
+	// Seconds, as Go's own duration format is not one systemd reads.
+	every := int(o.ScanEvery / time.Second)
+	return fmt.Sprintf(`[Unit]
+Description=Run LAN Orangutan scans every %s
+Documentation=https://github.com/291-Group/LAN-Orangutan
+
+[Timer]
+OnBootSec=2min
+OnUnitActiveSec=%ds
+Unit=%s
+
+[Install]
+WantedBy=timers.target
+`, o.ScanEvery, every, ScanUnitName)
+}
+
+// writeSandbox writes the [Service] settings that confine a service to its
+// data directory; the config file is only read. A service user other than root is given caps,
+// which let nmap send the raw ARP packets that find MAC addresses once
+// NMAP_PRIVILEGED tells it it may.
+func writeSandbox(b *strings.Builder, o Options, caps []string) {
+	if o.User != "root" && len(caps) > 0 {
+		b.WriteString("Environment=NMAP_PRIVILEGED=1\n")
+		fmt.Fprintf(b, "AmbientCapabilities=%s\n", strings.Join(caps, " "))
+		fmt.Fprintf(b, "CapabilityBoundingSet=%s\n", strings.Join(caps, " "))
+	}
+	b.WriteString("StandardOutput=journal\nStandardError=journal\n")
+	b.WriteString("NoNewPrivileges=true\nProtectSystem=strict\n")
+	// ProtectHome would hide a data or config directory kept under /home.
+	if !underHome(o.DataDir) && !underHome(o.ConfigFile) {
+		b.WriteString("ProtectHome=true\n")
+	}
+	fmt.Fprintf(b, "ReadWritePaths=%s\n", o.DataDir)
+	b.WriteString("PrivateTmp=true\n")
+}
+
+func underHome(p string) bool {
+	return strings.HasPrefix(p, "/home/") || strings.HasPrefix(p, "/root/") || strings.HasPrefix(p, "/run/user/")
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func testOptions() Options {
+	return Options{
+		Binary:     "/usr/local/bin/orangutan",
+		ConfigFile: "/etc/lan-orangutan/config.ini",
+		DataDir:    "/var/lib/lan-orangutan",
+		User:       "lan-orangutan",
+		Group:      "lan-orangutan",
+		Serve:      true,
+		Port:       291,
+	}
+}
+
+// unit returns the content of the named unit, failing the test if it is
+// missing.
+func unit(t *testing.T, units []Unit, name string) string {
+	t.Helper()
+	for _, u := range units {
+		if u.Name == name {
+			return u.Content
+		}
+	}
+	t.Fatalf("no %s in %d units", name, len(units))
+	return ""
+}
+
+func TestServerUnit(t *testing.T) {
+	units, err := Units(testOptions())
+	if err != nil {
+		t.Fatalf("Units: %v", err)
+	}
+	if len(units) != 1 {
+		t.Fatalf("got %d units without a schedule, want just the server", len(units))
+	}
+	got := unit(t, units, ServerUnitName)
+	for _, want := range []string{
+		"User=lan-orangutan\n",
+		"ExecStart=/usr/local/bin/orangutan --config /etc/lan-orangutan/config.ini serve\n",
+		"ExecReload=/bin/kill -HUP $MAINPID\n",
+		"AmbientCapabilities=CAP_NET_RAW CAP_NET_ADMIN CAP_NET_BIND_SERVICE\n",
+		"Environment=NMAP_PRIVILEGED=1\n",
+		"ReadWritePaths=/var/lib/lan-orangutan\n",
+		"ProtectHome=true\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("server unit lacks %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestServerUnitOnHighPortNeedsNoBindCapability(t *testing.T) {
+	o := testOptions()
+	o.Port = 8291
+	units, err := Units(o)
+	if err != nil {
+		t.Fatalf("Units: %v", err)
+	}
+	if got := unit(t, units, ServerUnitName); strings.Contains(got, "CAP_NET_BIND_SERVICE") {
+		t.Errorf("server unit on port 8291 grants CAP_NET_BIND_SERVICE:\n%s", got)
+	}
+}
+
+func TestRootNeedsNoCapabilities(t *testing.T) {
+	o := testOptions()
+	o.User, o.Group = "root", "root"
+	units, err := Units(o)
+	if err != nil {
+		t.Fatalf("Units: %v", err)
+	}
+	if got := unit(t, units, ServerUnitName); strings.Contains(got, "AmbientCapabilities") {
+		t.Errorf("root server unit sets capabilities:\n%s", got)
+	}
+}
+
+func TestScheduledScansGoThroughServer(t *testing.T) {
+	o := testOptions()
+	o.ScanEvery = time.Hour
+	o.Server = "http://127.0.0.1:291"
+	units, err := Units(o)
+	if err != nil {
+		t.Fatalf("Units: %v", err)
+	}
+	if len(units) != 3 {
+		t.Fatalf("got %d units, want the server, scan and timer", len(units))
+	}
+
+	scan := unit(t, units, ScanUnitName)
+	if !strings.Contains(scan, "--server http://127.0.0.1:291 scan all\n") {
+		t.Errorf("scan unit does not scan through the server:\n%s", scan)
+	}
+	if strings.Contains(scan, "AmbientCapabilities") {
+		t.Errorf("scan unit that goes through the server sets capabilities:\n%s", scan)
+	}
+	if timer := unit(t, units, ScanTimerName); !strings.Contains(timer, "OnUnitActiveSec=3600s\n") {
+		t.Errorf("timer does not run hourly:\n%s", timer)
+	}
+}
+
+func TestScheduledScansWithoutServer(t *testing.T) {
+	o := testOptions()
+	o.Serve = false
+	o.ScanEvery = 30 * time.Minute
+	units, err := Units(o)
+	if err != nil {
+		t.Fatalf("Units: %v", err)
+	}
+	if len(units) != 2 {
+		t.Fatalf("got %d units, want the scan and timer", len(units))
+	}
+	scan := unit(t, units, ScanUnitName)
+	if !strings.Contains(scan, "--config /etc/lan-orangutan/config.ini scan all\n") || !strings.Contains(scan, "CAP_NET_RAW") {
+		t.Errorf("scan unit does not scan with raw sockets itself:\n%s", scan)
+	}
+}
+
+func TestUnitsUseTheProfile(t *testing.T) {
+	o := testOptions()
+	o.Profile = "office"
+	o.ScanEvery = time.Hour
+	o.Server = "http://127.0.0.1:8080"
+	units, err := Units(o)
+	if err != nil {
+		t.Fatalf("Units: %v", err)
+	}
+	for _, name := range []string{ServerUnitName, ScanUnitName} {
+		if u := unit(t, units, name); !strings.Contains(u, "--config /etc/lan-orangutan/config.ini --profile office ") {
+			t.Errorf("%s does not use the profile:\n%s", name, u)
+		}
+	}
+}
+
+func TestDataUnderHomeIsNotHidden(t *testing.T) {
+	o := testOptions()
+	o.DataDir = "/home/pat/.local/share/lan-orangutan"
+	units, err := Units(o)
+	if err != nil {
+		t.Fatalf("Units: %v", err)
+	}
+	if got := unit(t, units, ServerUnitName); strings.Contains(got, "ProtectHome") {
+		t.Errorf("server unit hides /home, where its data is:\n%s", got)
+	}
+}
+
+func TestUnitsRejectsBadOptions(t *testing.T) {
+	for name, change := range map[string]func(*Options){
+		"relative binary":   func(o *Options) { o.Binary = "orangutan" },
+		"space in data dir": func(o *Options) { o.DataDir = "/srv/my data" },
+		"no user":           func(o *Options) { o.User = "" },
+		"too frequent":      func(o *Options) { o.ScanEvery = 10 * time.Second },
+		"nothing":           func(o *Options) { o.Serve = false },
+	} {
+		o := testOptions()
+		change(&o)
+		if _, err := Units(o); err == nil {
+			t.Errorf("%s: Units accepted %+v", name, o)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// macIndexLocked returns a copy of each stored device by its MAC in lower
+// case, taken before a scan is merged so the devices can be compared with
+// what the scan found. A MAC stored at several addresses is the one seen
+// last, and devices without a MAC, such as Tailscale peers, are left out.
+// The caller must hold s.mu.
+func (s *Storage) macIndexLocked() map[string]types.Device {
+	macs := make(map[string]types.Device, len(s.devices))
+	for _, d := range s.devices {
+		if d.MAC == "" {
+			continue
+		}
+		mac := strings.ToLower(d.MAC)
+		if other, ok := macs[mac]; !ok || d.LastSeen.After(other.LastSeen) {
+			macs[mac] = *d
+		}
+	}
+	return macs
+}
+
+// recordAnomaliesLocked adds an event for each way d, as a scan of cidr found
+// it, differs from what was known in a way a device does not change on its
+// own: its address answering with another MAC, its MAC with another hostname
+// or vendor, or its MAC turning up on another network. before is the device
+// stored at d's address before the scan, or nil if the address is new, and
+// macs the devices by MAC from macIndexLocked. The caller must hold s.mu for
+// writing.
+//
+// Each is reported once, by the scan that first sees it: after that the
+// device is stored as the scan found it.
+func (s *Storage) recordAnomaliesLocked(cidr string, d *types.Device, before *types.Device, macs map[string]types.Device, now time.Time) {
+	name := deviceName(d)
+	add := func(kind, message string) {
+		s.addEventLocked(types.Event{
+			Type:    types.EventDeviceAnomaly,
+			Time:    now,
+			IP:      d.IP,
+			Name:    name,
+			Network: cidr,
+			Detail:  kind,
+			Message: message,
+		})
+	}
+
+	if before != nil && differs(before.MAC, d.MAC) {
+		add(types.AnomalyMAC, fmt.Sprintf("%s (%s) now answers with MAC %s instead of %s", name, d.IP, d.MAC, before.MAC))
+	}
+	if d.MAC == "" {
+		return
+	}
+
+	// What was known of the MAC: at this address, or at another one the
+	// device has left.
+	known, ok := macs[strings.ToLower(d.MAC)]
+	if before != nil && strings.EqualFold(before.MAC, d.MAC) {
+		known, ok = *before, true
+	}
+	if !ok {
+		return
+	}
+	if differs(known.Hostname, d.Hostname) {
+		add(types.AnomalyHostname, fmt.Sprintf("MAC %s at %s now has the hostname %s instead of %s", d.MAC, d.IP, d.Hostname, known.Hostname))
+	}
+	// Scanners word vendors differently, as "Apple" or "Apple, Inc.", so
+	// only a vendor that shares nothing with the last one counts.
+	if known.Vendor != "" && d.Vendor != "" {
+		was, is := strings.ToLower(known.Vendor), strings.ToLower(d.Vendor)
+		if !strings.Contains(was, is) && !strings.Contains(is, was) {
+			add(types.AnomalyVendor, fmt.Sprintf("MAC %s at %s now reports the vendor %s instead of %s", d.MAC, d.IP, d.Vendor, known.Vendor))
+		}
+	}
+	if known.IP != d.IP && cidr != "" && !inCIDR(known.IP, cidr) && !s.stillPresentLocked(&known) {
+		add(types.AnomalySubnet, fmt.Sprintf("%s (MAC %s) has moved from %s to %s on %s", name, d.MAC, known.IP, d.IP, cidr))
+	}
+}
+
+// differs reports whether a detail a scan found, such as a MAC, is a
+// different one from the last, rather than learnt or lost.
+func differs(old, new string) bool {
+	return old != "" && new != "" && !strings.EqualFold(old, new)
+}
+
+// inCIDR reports whether ip is an address in cidr.
+func inCIDR(ip, cidr string) bool {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	addr := net.ParseIP(ip)
+	return err == nil && addr != nil && ipNet.Contains(addr)
+}
+
+// stillPresentLocked reports whether the latest scan of d's network found it
+// there. A host on several VLANs answers on each with the same MAC, and is
+// not moving between them. The caller must hold s.mu.
+func (s *Storage) stillPresentLocked(d *types.Device) bool {
+	for cidr, merged := range s.state.LastMerge {
+		if inCIDR(d.IP, cidr) && !d.LastSeen.Before(merged) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// merge merges a scan of cidr that found devices.
+func merge(t *testing.T, s *Storage, cidr string, devices ...types.Device) {
+	t.Helper()
+	if err := s.MergeScan(cidr, devices); err != nil {
+		t.Fatalf("MergeScan: %v", err)
+	}
+}
+
+// anomalies returns the kinds of anomaly recorded, oldest first.
+func anomalies(s *Storage) []string {
+	var kinds []string
+	for _, e := range eventsOfType(s, types.EventDeviceAnomaly) {
+		kinds = append([]string{e.Detail}, kinds...)
+	}
+	return kinds
+}
+
+func TestAnomalies(t *testing.T) {
+	nas := types.Device{IP: "192.168.1.10", MAC: "aa:bb:cc:00:00:01", Hostname: "nas", Vendor: "Synology Incorporated"}
+
+	tests := []struct {
+		name  string
+		found types.Device
+		want  []string
+	}{
+		{"unchanged", nas, nil},
+		{"MAC learnt in other case", types.Device{IP: nas.IP, MAC: "AA:BB:CC:00:00:01", Hostname: "NAS", Vendor: "Synology"}, nil},
+		{"details lost", types.Device{IP: nas.IP, MAC: nas.MAC}, nil},
+		{"address answers with another MAC", types.Device{IP: nas.IP, MAC: "de:ad:be:ef:00:01", Hostname: "nas"}, []string{types.AnomalyMAC}},
+		{"MAC with another hostname", types.Device{IP: nas.IP, MAC: nas.MAC, Hostname: "printer"}, []string{types.AnomalyHostname}},
+		{"MAC with another vendor", types.Device{IP: nas.IP, MAC: nas.MAC, Vendor: "Espressif"}, []string{types.AnomalyVendor}},
+		{"MAC at a new address with another hostname", types.Device{IP: "192.168.1.20", MAC: nas.MAC, Hostname: "laptop"}, []string{types.AnomalyHostname}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			merge(t, s, testNetwork, nas)
+			merge(t, s, testNetwork, tt.found)
+			merge(t, s, testNetwork, tt.found)
+
+			if got := anomalies(s); len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+				t.Errorf("anomalies = %q, want %q once", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnomalySubnetJump(t *testing.T) {
+	s := newTestStorage(t)
+	camera := types.Device{IP: "192.168.1.30", MAC: "aa:bb:cc:00:00:30"}
+	merge(t, s, testNetwork, camera)
+	merge(t, s, "192.168.20.0/24", types.Device{IP: "192.168.20.1"})
+
+	// A host on two VLANs answers on both with the same MAC
+	merge(t, s, "192.168.20.0/24", types.Device{IP: "192.168.20.30", MAC: camera.MAC})
+	if got := anomalies(s); len(got) != 0 {
+		t.Fatalf("anomalies = %q for a device on two networks", got)
+	}
+
+	// but one gone from both has moved
+	merge(t, s, testNetwork)
+	merge(t, s, "192.168.20.0/24", types.Device{IP: "192.168.20.1"})
+	merge(t, s, "192.168.30.0/24", types.Device{IP: "192.168.30.30", MAC: camera.MAC})
+	merge(t, s, "192.168.30.0/24", types.Device{IP: "192.168.30.30", MAC: camera.MAC})
+	got := eventsOfType(s, types.EventDeviceAnomaly)
+	if len(got) != 1 || got[0].Detail != types.AnomalySubnet || got[0].IP != "192.168.30.30" {
+		t.Fatalf("anomalies = %+v, want the camera's move to 192.168.30.30", got)
+	}
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"sort"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// SetApproval makes each device that later scans find for the first time
+// wait for approval when pending reports true for it. nil, the default,
+// approves every device as it is found.
+//
+// Devices in the very first scan are approved whatever pending says: they
+// are the network as it was when the inventory started, and asking about
+// every one of them would bury anything that turns up later.
+func (s *Storage) SetApproval(pending func(*types.Device) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = pending
+}
+
+// ApproveDevices approves every pending device in ips, skipping addresses
+// with no device and devices already approved, and returns how many it
+// approved.
+func (s *Storage) ApproveDevices(ips []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, ip := range ips {
+		if d, ok := s.devices[ip]; ok && d.Pending {
+			d.Pending = false
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	return n, s.saveDevices()
+}
+
+// PendingDevices returns the devices waiting for approval, newest first.
+func (s *Storage) PendingDevices() []*types.Device {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return Pending(s.devices)
+}
+
+// Pending returns the devices among devices that are waiting for approval,
+// newest first.
+func Pending(devices map[string]*types.Device) []*types.Device {
+	var pending []*types.Device
+	for _, d := range devices {
+		if d.Pending {
+			pending = append(pending, d)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if !pending[i].FirstSeen.Equal(pending[j].FirstSeen) {
+			return pending[i].FirstSeen.After(pending[j].FirstSeen)
+		}
+		return pending[i].IP < pending[j].IP
+	})
+	return pending
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func TestNewDevicesWaitForApproval(t *testing.T) {
+	s := newTestStorage(t)
+	s.SetApproval(func(d *types.Device) bool { return d.Vendor != "Raspberry Pi" })
+
+	// The first scan is the network as it was: nothing in it waits.
+	if err := s.MergeScan("192.168.1.0/24", []types.Device{{IP: "192.168.1.1", Vendor: "Ubiquiti"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.MergeScan("192.168.1.0/24", []types.Device{
+		{IP: "192.168.1.1", Vendor: "Ubiquiti"},
+		{IP: "192.168.1.50", Vendor: "Unknown"},
+		{IP: "192.168.1.60", Vendor: "Raspberry Pi"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	pending := s.PendingDevices()
+	if len(pending) != 1 || pending[0].IP != "192.168.1.50" {
+		t.Fatalf("PendingDevices = %v; want only 192.168.1.50", pending)
+	}
+	if got := s.GetStats().Pending; got != 1 {
+		t.Errorf("GetStats().Pending = %d; want 1", got)
+	}
+	events := s.GetEvents(0, false)
+	if len(events) != 2 {
+		t.Fatalf("got %d events; want one for each new device", len(events))
+	}
+
+	n, err := s.ApproveDevices([]string{"192.168.1.50", "192.168.1.1", "192.168.1.99"})
+	if err != nil || n != 1 {
+		t.Fatalf("ApproveDevices = %d, %v; want 1", n, err)
+	}
+	// The next scan does not undo an approval.
+	if err := s.MergeScan("192.168.1.0/24", []types.Device{{IP: "192.168.1.50", Vendor: "Unknown"}}); err != nil {
+		t.Fatal(err)
+	}
+	if pending := s.PendingDevices(); len(pending) != 0 {
+		t.Errorf("PendingDevices after approving = %v", pending)
+	}
+}
+
+func TestWithoutApprovalNothingWaits(t *testing.T) {
+	s := newTestStorage(t)
+	for range 2 {
+		if err := s.MergeScan("192.168.1.0/24", []types.Device{{IP: "192.168.1.1"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.MergeScan("192.168.1.0/24", []types.Device{{IP: "192.168.1.2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if pending := s.PendingDevices(); len(pending) != 0 {
+		t.Errorf("PendingDevices = %v; want none", pending)
+	}
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// certsKept is how long the certificate of a port that stopped answering is
+// kept. A port that times out once keeps its certificate, and what was
+// reported about it, so that it is not reported again when it next answers.
+const certsKept = 7 * 24 * time.Hour
+
+// loadCerts reads the TLS certificates from their JSON file
+func (s *Storage) loadCerts() error {
+	if err := s.certsFile.load(&s.certs); err != nil {
+		return err
+	}
+	if s.certs == nil {
+		s.certs = make(map[string][]types.Certificate)
+	}
+	return nil
+}
+
+// refreshCertsLocked reads the certificates again if another process has
+// written them since, as 'orangutan certs --check' does beside a running
+// server. The caller must hold s.mu for writing.
+func (s *Storage) refreshCertsLocked() {
+	if !s.certsFile.changed() {
+		return
+	}
+	s.certs = make(map[string][]types.Certificate)
+	if err := s.loadCerts(); err != nil {
+		slog.Warn("cannot read certificates", "file", s.certsFile.path, "error", err)
+	}
+}
+
+// SetCertificates records certs as the TLS certificates the device at ip
+// serves, in place of those it served before on the same ports, and adds an
+// event for each that has come within warn of expiring, or has expired,
+// since it was last reported. A certificate is reported once at each stage;
+// a renewed one starts afresh. Those of ports not in certs are kept until
+// they have not been checked for certsKept. It returns false when there is
+// no device at ip.
+func (s *Storage) SetCertificates(ip string, certs []types.Certificate, warn time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.devices[ip]
+	if !ok {
+		return false, nil
+	}
+	s.refreshCertsLocked()
+	warned := make(map[string]string)
+	for _, c := range s.certs[ip] {
+		warned[strconv.Itoa(c.Port)+" "+c.Fingerprint] = c.Warned
+	}
+
+	now := time.Now()
+	kept := make([]types.Certificate, 0, len(certs))
+	seen := make(map[int]bool, len(certs))
+	for _, c := range certs {
+		seen[c.Port] = true
+	}
+	for _, c := range s.certs[ip] {
+		if !seen[c.Port] && now.Sub(c.Checked) < certsKept {
+			kept = append(kept, c)
+		}
+	}
+	reported := false
+	for _, c := range certs {
+		c.IP = ip
+		c.Warned = warned[strconv.Itoa(c.Port)+" "+c.Fingerprint]
+		stage := c.Expiry(now, warn)
+		if stage != "" && stage != c.Warned {
+			s.addEventLocked(types.Event{
+				Type:    types.EventCertExpiring,
+				Time:    now,
+				IP:      ip,
+				Name:    deviceName(d),
+				Network: s.networkOfLocked(ip),
+				Detail:  stage,
+				Message: certMessage(deviceName(d), c, now),
+			})
+			reported = true
+		}
+		c.Warned = stage
+		kept = append(kept, c)
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].Port < kept[j].Port })
+	if len(kept) == 0 {
+		delete(s.certs, ip)
+	} else {
+		s.certs[ip] = kept
+	}
+	if err := s.certsFile.save(s.certs); err != nil {
+		return true, err
+	}
+	if reported {
+		return true, s.saveEvents()
+	}
+	return true, nil
+}
+
+// certMessage describes c expiring for the event log, as in "The TLS
+// certificate of nas (192.168.1.5) on port 443 expires in 12 days, on
+// 2026-10-28".
+func certMessage(name string, c types.Certificate, now time.Time) string {
+	what := fmt.Sprintf("The TLS certificate of %s (%s) on port %d", name, c.IP, c.Port)
+	date := c.NotAfter.Local().Format("2006-01-02")
+	left := c.NotAfter.Sub(now)
+	switch {
+	case left <= 0:
+		return fmt.Sprintf("%s expired on %s", what, date)
+	case left < 24*time.Hour:
+		return fmt.Sprintf("%s expires within a day, on %s", what, date)
+	case left < 48*time.Hour:
+		return fmt.Sprintf("%s expires tomorrow, on %s", what, date)
+	}
+	return fmt.Sprintf("%s expires in %d days, on %s", what, int(left/(24*time.Hour)), date)
+}
+
+// GetCertificates returns the TLS certificates each device served when last
+// checked, by IP, each device's by port.
+func (s *Storage) GetCertificates() map[string][]types.Certificate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshCertsLocked()
+	result := make(map[string][]types.Certificate, len(s.certs))
+	for ip, certs := range s.certs {
+		result[ip] = append([]types.Certificate(nil), certs...)
+	}
+	return result
+}
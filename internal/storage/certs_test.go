@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// certEvents returns the cert_expiring events in the log, oldest first.
+func certEvents(s *Storage) []types.Event {
+	var result []types.Event
+	events := s.GetEvents(0, false)
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Type == types.EventCertExpiring {
+			result = append(result, events[i])
+		}
+	}
+	return result
+}
+
+func TestCertificates(t *testing.T) {
+	s := newTestStorage(t)
+	scan(t, s, "192.168.1.10", "192.168.1.20")
+	warn := 14 * 24 * time.Hour
+	now := time.Now()
+
+	good := types.Certificate{Port: 8006, Fingerprint: "aa", NotAfter: now.Add(300 * 24 * time.Hour), Checked: now}
+	soon := types.Certificate{Port: 443, Fingerprint: "bb", NotAfter: now.Add(10*24*time.Hour + time.Hour), Checked: now}
+	if ok, err := s.SetCertificates("192.168.1.10", []types.Certificate{good, soon}, warn); !ok || err != nil {
+		t.Fatalf("SetCertificates = %v, %v", ok, err)
+	}
+	if ok, _ := s.SetCertificates("192.168.1.99", []types.Certificate{soon}, warn); ok {
+		t.Error("SetCertificates recorded certificates of a device not in the inventory")
+	}
+	got := s.GetCertificates()["192.168.1.10"]
+	if len(got) != 2 || got[0].Port != 443 || got[0].IP != "192.168.1.10" || got[0].Warned != types.CertExpiring {
+		t.Fatalf("GetCertificates = %+v, want both by port with the expiring one warned of", got)
+	}
+	events := certEvents(s)
+	if len(events) != 1 || events[0].Detail != types.CertExpiring || events[0].Message != "The TLS certificate of 192.168.1.10 (192.168.1.10) on port 443 expires in 10 days, on "+soon.NotAfter.Local().Format("2006-01-02") {
+		t.Fatalf("events = %+v, want one about port 443 expiring", events)
+	}
+
+	// Checked again, it is not reported again until it expires.
+	if _, err := s.SetCertificates("192.168.1.10", []types.Certificate{good, soon}, warn); err != nil {
+		t.Fatalf("SetCertificates: %v", err)
+	}
+	if n := len(certEvents(s)); n != 1 {
+		t.Errorf("%d events after checking again, want still 1", n)
+	}
+	// A port that does not answer one round keeps what was reported.
+	if _, err := s.SetCertificates("192.168.1.10", []types.Certificate{good}, warn); err != nil {
+		t.Fatalf("SetCertificates: %v", err)
+	}
+	if got = s.GetCertificates()["192.168.1.10"]; len(got) != 2 || got[0].Warned != types.CertExpiring {
+		t.Fatalf("after port 443 did not answer = %+v, want its certificate kept", got)
+	}
+	if _, err := s.SetCertificates("192.168.1.10", []types.Certificate{good, soon}, warn); err != nil {
+		t.Fatalf("SetCertificates: %v", err)
+	}
+	if n := len(certEvents(s)); n != 1 {
+		t.Errorf("%d events after port 443 answered again, want still 1", n)
+	}
+	expired := soon
+	expired.NotAfter = now.Add(-time.Hour)
+	if _, err := s.SetCertificates("192.168.1.10", []types.Certificate{good, expired}, warn); err != nil {
+		t.Fatalf("SetCertificates: %v", err)
+	}
+	if events = certEvents(s); len(events) != 2 || events[1].Detail != types.CertExpired {
+		t.Fatalf("events = %+v, want a second about it having expired", events)
+	}
+
+	// A renewed certificate is new, and one with time to run is no news.
+	renewed := types.Certificate{Port: 443, Fingerprint: "cc", NotAfter: now.Add(90 * 24 * time.Hour), Checked: now}
+	if _, err := s.SetCertificates("192.168.1.10", []types.Certificate{good, renewed}, warn); err != nil {
+		t.Fatalf("SetCertificates: %v", err)
+	}
+	if n := len(certEvents(s)); n != 2 {
+		t.Errorf("%d events after renewal, want still 2", n)
+	}
+
+	// A port that has not answered for long enough is forgotten.
+	old := types.Certificate{Port: 443, Fingerprint: "dd", NotAfter: now.Add(90 * 24 * time.Hour), Checked: now.Add(-8 * 24 * time.Hour)}
+	if _, err := s.SetCertificates("192.168.1.20", []types.Certificate{old}, warn); err != nil {
+		t.Fatalf("SetCertificates: %v", err)
+	}
+	if _, err := s.SetCertificates("192.168.1.20", nil, warn); err != nil {
+		t.Fatalf("SetCertificates: %v", err)
+	}
+	if got := s.GetCertificates()["192.168.1.20"]; len(got) != 0 {
+		t.Errorf("GetCertificates = %+v, want the certificate not checked for a week forgotten", got)
+	}
+
+	reopened, err := New(s.devicesFile, s.stateFile)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if got = reopened.GetCertificates()["192.168.1.10"]; len(got) != 2 || got[0].Fingerprint != "cc" || got[0].Warned != "" {
+		t.Fatalf("after reopening = %+v, want the renewed certificate", got)
+	}
+	if err := reopened.DeleteDevice("192.168.1.10"); err != nil {
+		t.Fatalf("DeleteDevice: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if all := s.GetCertificates(); len(all) != 0 {
+		t.Errorf("GetCertificates = %+v after the device was deleted", all)
+	}
+}
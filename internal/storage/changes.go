@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// maxChanges caps the change history kept per device. A device whose
+// hostname flaps between two names would otherwise grow it with every scan.
+const maxChanges = 100
+
+// loadChanges reads the change history from its JSON file
+func (s *Storage) loadChanges() error {
+	data, err := os.ReadFile(s.changesFile)
+	if err != nil {
+		return err
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(data, &s.changes); err != nil {
+		return err
+	}
+	if s.changes == nil {
+		s.changes = make(map[string][]types.Change)
+	}
+	return nil
+}
+
+// saveChanges writes the change history to its JSON file atomically
+func (s *Storage) saveChanges() error {
+	data, err := json.MarshalIndent(s.changes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal changes: %w", err)
+	}
+
+	return atomicWrite(s.changesFile, data)
+}
+
+// saveChangesIf saves the change history if noted says something was added
+// to it, sparing a write on the many updates that change nothing worth
+// recording.
+func (s *Storage) saveChangesIf(noted bool) error {
+	if !noted {
+		return nil
+	}
+	return s.saveChanges()
+}
+
+// GetChanges returns the recorded changes to ip's details, oldest first.
+func (s *Storage) GetChanges(ip string) []types.Change {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append(make([]types.Change, 0), s.changes[ip]...)
+}
+
+// noteChangesLocked records each detail that differs between before and
+// after, and reports whether there were any. The caller must hold s.mu for
+// writing.
+func (s *Storage) noteChangesLocked(before, after *types.Device, now time.Time) bool {
+	fields := []struct{ name, old, new string }{
+		{"mac", before.MAC, after.MAC},
+		{"hostname", before.Hostname, after.Hostname},
+		{"label", before.Label, after.Label},
+		{"group", before.Group, after.Group},
+		{"notes", before.Notes, after.Notes},
+		{"type", before.Type, after.Type},
+		{"tags", strings.Join(before.Tags, ", "), strings.Join(after.Tags, ", ")},
+	}
+
+	noted := false
+	for _, f := range fields {
+		if f.old == f.new {
+			continue
+		}
+		// Scans see hardware and names: only a real change is news, not one
+		// learnt for the first time or lost to a lookup that failed. User
+		// edits are always worth keeping, including filling in a blank.
+		scanned := f.name == "mac" || f.name == "hostname"
+		if scanned && (f.old == "" || f.new == "" || strings.EqualFold(f.old, f.new)) {
+			continue
+		}
+		changes := append(s.changes[after.IP], types.Change{Time: now, Field: f.name, Old: f.old, New: f.new})
+		if over := len(changes) - maxChanges; over > 0 {
+			changes = append([]types.Change(nil), changes[over:]...)
+		}
+		s.changes[after.IP] = changes
+		noted = true
+	}
+	return noted
+}
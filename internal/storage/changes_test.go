@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func TestEditsAreRecorded(t *testing.T) {
+	s := newTestStorage(t)
+	scan(t, s, "192.168.1.1")
+
+	label := "Router"
+	if err := s.UpdateDeviceFields("192.168.1.1", &label, nil, nil, nil); err != nil {
+		t.Fatalf("UpdateDeviceFields: %v", err)
+	}
+	if _, err := s.UpdateDevices([]string{"192.168.1.1"}, func(d *types.Device) { d.AddTag("core") }); err != nil {
+		t.Fatalf("UpdateDevices: %v", err)
+	}
+
+	got := s.GetChanges("192.168.1.1")
+	if len(got) != 2 {
+		t.Fatalf("got %d changes, want 2: %+v", len(got), got)
+	}
+	if got[0].Field != "label" || got[0].Old != "" || got[0].New != "Router" {
+		t.Errorf("first change = %+v, want the label being set", got[0])
+	}
+	if got[1].Field != "tags" || got[1].New != "core" {
+		t.Errorf("second change = %+v, want the tag being added", got[1])
+	}
+}
+
+func TestScansRecordOnlyRealChanges(t *testing.T) {
+	s := newTestStorage(t)
+	merge := func(d types.Device) {
+		t.Helper()
+		if err := s.MergeScan(testNetwork, []types.Device{d}); err != nil {
+			t.Fatalf("MergeScan: %v", err)
+		}
+	}
+
+	merge(types.Device{IP: "192.168.1.1"})
+	merge(types.Device{IP: "192.168.1.1", Hostname: "printer.lan"})
+	merge(types.Device{IP: "192.168.1.1"})
+	merge(types.Device{IP: "192.168.1.1", Hostname: "printer.lan"})
+	if got := s.GetChanges("192.168.1.1"); len(got) != 0 {
+		t.Errorf("a hostname learnt or missed was recorded as a change: %+v", got)
+	}
+
+	merge(types.Device{IP: "192.168.1.1", Hostname: "scanner.lan"})
+	got := s.GetChanges("192.168.1.1")
+	if len(got) != 1 || got[0].Old != "printer.lan" || got[0].New != "scanner.lan" {
+		t.Errorf("got %+v, want one hostname change", got)
+	}
+}
+
+func TestChangesSurviveReloadAndDelete(t *testing.T) {
+	dir := t.TempDir()
+	devices := filepath.Join(dir, "devices.json")
+	state := filepath.Join(dir, "state.json")
+
+	s, err := New(devices, state)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	scan(t, s, "192.168.1.1")
+	group := "Network"
+	if err := s.UpdateDeviceFields("192.168.1.1", nil, nil, &group, nil); err != nil {
+		t.Fatalf("UpdateDeviceFields: %v", err)
+	}
+
+	reloaded, err := New(devices, state)
+	if err != nil {
+		t.Fatalf("reloading: %v", err)
+	}
+	if got := reloaded.GetChanges("192.168.1.1"); len(got) != 1 {
+		t.Fatalf("got %d changes after reload, want 1", len(got))
+	}
+
+	if err := reloaded.DeleteDevice("192.168.1.1"); err != nil {
+		t.Fatalf("DeleteDevice: %v", err)
+	}
+	if got := reloaded.GetChanges("192.168.1.1"); len(got) != 0 {
+		t.Errorf("a deleted device still has changes: %+v", got)
+	}
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// maxEscalations caps the escalations kept. Those that have stopped are kept
+// as a record of who acknowledged what, and the oldest of them dropped once
+// it is full.
+const maxEscalations = 200
+
+// loadEscalations reads the escalations from their JSON file
+func (s *Storage) loadEscalations() error {
+	if err := s.escalationsFile.load(&s.escalations); err != nil {
+		return err
+	}
+	for _, e := range s.escalations {
+		if e.ID >= s.nextEscalationID {
+			s.nextEscalationID = e.ID + 1
+		}
+	}
+	return nil
+}
+
+// refreshEscalationsLocked reads the escalations again if another process
+// has written them since, as 'orangutan ack' does beside a running monitor.
+// The caller must hold s.mu for writing.
+func (s *Storage) refreshEscalationsLocked() {
+	if !s.escalationsFile.changed() {
+		return
+	}
+	s.escalations = nil
+	if err := s.loadEscalations(); err != nil {
+		slog.Warn("cannot read escalations", "file", s.escalationsFile.path, "error", err)
+	}
+}
+
+// AddEscalation records e, filling in its ID and, if unset, when it started,
+// and returns it as recorded.
+func (s *Storage) AddEscalation(e types.Escalation) (types.Escalation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshEscalationsLocked()
+	if s.nextEscalationID == 0 {
+		s.nextEscalationID = 1
+	}
+	e.ID = s.nextEscalationID
+	s.nextEscalationID++
+	if e.Started.IsZero() {
+		e.Started = time.Now()
+	}
+
+	s.escalations = append(s.escalations, e)
+	for i := 0; len(s.escalations) > maxEscalations && i < len(s.escalations); {
+		if s.escalations[i].Open() {
+			i++
+			continue
+		}
+		s.escalations = append(s.escalations[:i:i], s.escalations[i+1:]...)
+	}
+	return e, s.escalationsFile.save(s.escalations)
+}
+
+// AcknowledgeEscalation stops the escalation id, recording by as who took it
+// on. It returns false when there is no such escalation still going.
+func (s *Storage) AcknowledgeEscalation(id int64, by string) (bool, error) {
+	return s.updateEscalation(id, func(e *types.Escalation) {
+		e.Acknowledged = time.Now()
+		e.AcknowledgedBy = by
+	})
+}
+
+// SetEscalationSteps records that the first steps of the escalation id have
+// been sent. It returns false when there is no such escalation still going.
+func (s *Storage) SetEscalationSteps(id int64, steps int) (bool, error) {
+	return s.updateEscalation(id, func(e *types.Escalation) {
+		e.Steps = steps
+	})
+}
+
+// ResolveEscalation stops the escalation id without anyone acknowledging
+// it, as when what it is about has put itself right. It returns false when
+// there is no such escalation still going.
+func (s *Storage) ResolveEscalation(id int64) (bool, error) {
+	return s.updateEscalation(id, func(e *types.Escalation) {
+		e.Resolved = time.Now()
+	})
+}
+
+// updateEscalation applies update to the escalation id if it is still
+// going, and saves the escalations.
+func (s *Storage) updateEscalation(id int64, update func(*types.Escalation)) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshEscalationsLocked()
+	for i := range s.escalations {
+		if s.escalations[i].ID != id || !s.escalations[i].Open() {
+			continue
+		}
+		update(&s.escalations[i])
+		return true, s.escalationsFile.save(s.escalations)
+	}
+	return false, nil
+}
+
+// GetEscalations returns the escalations still going, or all of them when
+// all is set, newest first.
+func (s *Storage) GetEscalations(all bool) []types.Escalation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshEscalationsLocked()
+	result := make([]types.Escalation, 0)
+	for i := len(s.escalations) - 1; i >= 0; i-- {
+		if all || s.escalations[i].Open() {
+			result = append(result, s.escalations[i])
+		}
+	}
+	return result
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func TestEscalations(t *testing.T) {
+	s := newTestStorage(t)
+	nas, err := s.AddEscalation(types.Escalation{Policy: "critical", Rule: "servers", Event: types.Event{Type: types.EventDeviceOffline, IP: "192.168.1.10"}})
+	if err != nil {
+		t.Fatalf("AddEscalation: %v", err)
+	}
+	router, _ := s.AddEscalation(types.Escalation{Policy: "critical", Rule: "servers", Event: types.Event{Type: types.EventDeviceOffline, IP: "192.168.1.1"}})
+	if nas.ID != 1 || router.ID != 2 || nas.Started.IsZero() {
+		t.Fatalf("added %+v and %+v, want IDs 1 and 2 and a start time", nas, router)
+	}
+
+	if ok, err := s.SetEscalationSteps(nas.ID, 2); !ok || err != nil {
+		t.Fatalf("SetEscalationSteps = %v, %v", ok, err)
+	}
+	if ok, err := s.AcknowledgeEscalation(nas.ID, "alice"); !ok || err != nil {
+		t.Fatalf("AcknowledgeEscalation = %v, %v", ok, err)
+	}
+	if ok, _ := s.AcknowledgeEscalation(nas.ID, "bob"); ok {
+		t.Error("acknowledged an escalation already acknowledged")
+	}
+	if ok, _ := s.SetEscalationSteps(nas.ID, 3); ok {
+		t.Error("went on with an escalation already acknowledged")
+	}
+
+	// The record survives a restart.
+	reopened, err := New(s.devicesFile, s.stateFile)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if got := reopened.GetEscalations(false); len(got) != 1 || got[0].ID != router.ID {
+		t.Errorf("GetEscalations(false) = %+v, want only the router's", got)
+	}
+	all := reopened.GetEscalations(true)
+	if len(all) != 2 || all[1].AcknowledgedBy != "alice" || all[1].Steps != 2 || all[1].Acknowledged.Before(all[1].Started.Add(-time.Second)) {
+		t.Errorf("GetEscalations(true) = %+v, want the NAS's acknowledged by alice after two steps", all)
+	}
+}
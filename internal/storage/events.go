@@ -0,0 +1,318 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// maxEvents caps the event log. It is a record of recent changes for the
+// notification panel, not an audit trail, so the oldest entries are dropped
+// once it is full.
+const maxEvents = 500
+
+// loadEvents reads the event log from its JSON file
+func (s *Storage) loadEvents() error {
+	data, err := os.ReadFile(s.eventsFile)
+	if err != nil {
+		return err
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(data, &s.events); err != nil {
+		return err
+	}
+	for _, e := range s.events {
+		if e.ID >= s.nextEventID {
+			s.nextEventID = e.ID + 1
+		}
+	}
+	return nil
+}
+
+// saveEvents writes the event log to its JSON file atomically
+func (s *Storage) saveEvents() error {
+	data, err := json.MarshalIndent(s.events, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal events: %w", err)
+	}
+
+	return atomicWrite(s.eventsFile, data)
+}
+
+// AddEvent records an event, filling in its ID and, if unset, its time.
+func (s *Storage) AddEvent(e types.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.addEventLocked(e)
+	return s.saveEvents()
+}
+
+// addEventLocked appends an event without saving. The caller must hold s.mu
+// for writing.
+func (s *Storage) addEventLocked(e types.Event) {
+	if s.nextEventID == 0 {
+		s.nextEventID = 1
+	}
+	e.ID = s.nextEventID
+	s.nextEventID++
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	s.events = append(s.events, e)
+	if over := len(s.events) - maxEvents; over > 0 {
+		s.events = append([]types.Event(nil), s.events[over:]...)
+	}
+}
+
+// ReloadEvents reads the event log from disk again, picking up events
+// another process has recorded since this one loaded it.
+func (s *Storage) ReloadEvents() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = nil
+	if err := s.loadEvents(); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// GetEvents returns up to limit events, newest first. A limit of zero or less
+// returns them all.
+func (s *Storage) GetEvents(limit int, unreadOnly bool) []types.Event {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]types.Event, 0)
+	for i := len(s.events) - 1; i >= 0; i-- {
+		if unreadOnly && s.events[i].Read {
+			continue
+		}
+		result = append(result, s.events[i])
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+	return result
+}
+
+// UnreadEvents returns how many events have not been marked as read.
+func (s *Storage) UnreadEvents() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := 0
+	for _, e := range s.events {
+		if !e.Read {
+			n++
+		}
+	}
+	return n
+}
+
+// MarkEventsRead marks the given events as read, or every event if ids is
+// empty.
+func (s *Storage) MarkEventsRead(ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	want := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+	}
+
+	changed := false
+	for i := range s.events {
+		if s.events[i].Read || (len(ids) > 0 && !want[s.events[i].ID]) {
+			continue
+		}
+		s.events[i].Read = true
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return s.saveEvents()
+}
+
+// RecordScanFailure adds a failed scan of cidr to the event log, so it is
+// noticed even when nobody was watching the scan.
+func (s *Storage) RecordScanFailure(cidr, reason string) error {
+	return s.AddEvent(types.Event{
+		Type:    types.EventScanFailed,
+		Network: cidr,
+		Detail:  reason,
+		Message: fmt.Sprintf("Scan of %s failed: %s", cidr, reason),
+	})
+}
+
+// deviceName is how an event refers to a device: its label if it has one,
+// then its hostname, then its address.
+func deviceName(d *types.Device) string {
+	switch {
+	case d.Label != "":
+		return d.Label
+	case d.Hostname != "":
+		return d.Hostname
+	default:
+		return d.IP
+	}
+}
+
+// recordOfflineLocked adds an event for each device in cidr that was present
+// in the previous scan of that network but is missing from this one. Devices
+// already missing last time are not reported again. A device with a grace
+// period is reported instead by the first scan after it has been missing for
+// that long, if it has not come back by then. The caller must hold s.mu for
+// writing, and must call this before merging the new results.
+//
+// Every device a scan finds has its LastSeen stamped with the same instant,
+// kept in the state as the network's LastMerge, so the devices present in the
+// previous scan are those seen at that instant or since, as by MarkSeen. State
+// from before LastMerge was kept has only the device times to go on; the
+// latest LastSeen on the network stands in for it.
+func (s *Storage) recordOfflineLocked(cidr string, found map[string]bool, now time.Time) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return
+	}
+
+	inNetwork := make([]*types.Device, 0)
+	previous, known := s.state.LastMerge[cidr]
+	for ip, d := range s.devices {
+		addr := net.ParseIP(ip)
+		if addr == nil || !ipNet.Contains(addr) {
+			continue
+		}
+		inNetwork = append(inNetwork, d)
+		if !known && d.LastSeen.After(previous) {
+			previous = d.LastSeen
+		}
+	}
+
+	reported := s.offlineReportsLocked()
+	for _, d := range inNetwork {
+		if found[d.IP] || reported[d.IP].After(d.LastSeen) {
+			// Found, or already reported between scans by ReportUnreachable.
+			continue
+		}
+		grace := s.offlineAfterLocked(d)
+		if grace == 0 {
+			if d.LastSeen.Before(previous) {
+				continue
+			}
+		} else if deadline := d.LastSeen.Add(grace); now.Before(deadline) || !deadline.After(previous) {
+			// Not gone long enough yet, or the scan before already found
+			// it had been.
+			continue
+		}
+		ip := d.IP
+		name := deviceName(d)
+		message := fmt.Sprintf("%s (%s) went offline", name, ip)
+		if grace > 0 {
+			message = fmt.Sprintf("%s (%s) has been offline since %s", name, ip, d.LastSeen.Format("2006-01-02 15:04"))
+		}
+		s.addEventLocked(types.Event{
+			Type:    types.EventDeviceOffline,
+			Time:    now,
+			IP:      ip,
+			Name:    name,
+			Network: cidr,
+			Message: message,
+		})
+	}
+}
+
+// ReportUnreachable records that the device at ip has stopped answering
+// between scans, as the watchlist's pings find, with an offline event saying
+// why, and ends its sighting. It returns false, recording nothing, when there
+// is no device at ip or it has already been reported offline since it was
+// last seen.
+func (s *Storage) ReportUnreachable(ip, why string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.devices[ip]
+	if !ok || s.offlineReportsLocked()[ip].After(d.LastSeen) {
+		return false, nil
+	}
+	name := deviceName(d)
+	s.addEventLocked(types.Event{
+		Type:    types.EventDeviceOffline,
+		Time:    time.Now(),
+		IP:      ip,
+		Name:    name,
+		Network: s.networkOfLocked(ip),
+		Detail:  why,
+		Message: fmt.Sprintf("%s (%s) stopped answering: %s", name, ip, why),
+	})
+	if spans := s.sightings[ip]; len(spans) > 0 {
+		spans[len(spans)-1].Closed = true
+	}
+	if err := s.saveSightings(); err != nil {
+		return true, err
+	}
+	return true, s.saveEvents()
+}
+
+// offlineReportsLocked returns when each device was last reported offline.
+// The caller must hold s.mu.
+func (s *Storage) offlineReportsLocked() map[string]time.Time {
+	reported := make(map[string]time.Time)
+	for _, e := range s.events {
+		if e.Type == types.EventDeviceOffline && e.Time.After(reported[e.IP]) {
+			reported[e.IP] = e.Time
+		}
+	}
+	return reported
+}
+
+// networkOfLocked returns the smallest scanned network holding ip, or "" when
+// none does. The caller must hold s.mu.
+func (s *Storage) networkOfLocked(ip string) string {
+	addr := net.ParseIP(ip)
+	best, bestOnes := "", -1
+	for cidr := range s.state.LastScan {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil || addr == nil || !ipNet.Contains(addr) {
+			continue
+		}
+		if ones, _ := ipNet.Mask.Size(); ones > bestOnes {
+			best, bestOnes = cidr, ones
+		}
+	}
+	return best
+}
+
+// SetOfflineAfter has devices reported offline only once they have been
+// missing from scans for as long as after returns for them, so that a phone
+// away for the evening is not news while a server gone for minutes is. A
+// device's own OfflineAfter wins over it. nil, the default, reports every
+// device at the first scan that misses it.
+func (s *Storage) SetOfflineAfter(after func(*types.Device) time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offlineAfter = after
+}
+
+// offlineAfterLocked returns the grace period of d. The caller must hold
+// s.mu.
+func (s *Storage) offlineAfterLocked(d *types.Device) time.Duration {
+	if d.OfflineAfter > 0 {
+		return time.Duration(d.OfflineAfter) * time.Second
+	}
+	if s.offlineAfter != nil {
+		return s.offlineAfter(d)
+	}
+	return 0
+}
@@ -0,0 +1,281 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+const testNetwork = "192.168.1.0/24"
+
+// scan simulates a completed scan of testNetwork that found the given IPs.
+func scan(t *testing.T, s *Storage, ips ...string) {
+	t.Helper()
+
+	devices := make([]types.Device, 0, len(ips))
+	for _, ip := range ips {
+		devices = append(devices, types.Device{IP: ip})
+	}
+	if err := s.MergeScan(testNetwork, devices); err != nil {
+		t.Fatalf("MergeScan: %v", err)
+	}
+	if err := s.SetLastScan(testNetwork, time.Now()); err != nil {
+		t.Fatalf("SetLastScan: %v", err)
+	}
+}
+
+// eventsOfType returns the recorded events of one type, newest first.
+func eventsOfType(s *Storage, typ string) []types.Event {
+	var out []types.Event
+	for _, e := range s.GetEvents(0, false) {
+		if e.Type == typ {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func TestFirstScanRecordsNoNewDeviceEvents(t *testing.T) {
+	s := newTestStorage(t)
+	scan(t, s, "192.168.1.1", "192.168.1.2")
+
+	if got := eventsOfType(s, types.EventDeviceNew); len(got) != 0 {
+		t.Errorf("got %d new device events on the first scan, want none", len(got))
+	}
+}
+
+func TestNewDeviceRecordedOnce(t *testing.T) {
+	s := newTestStorage(t)
+	scan(t, s, "192.168.1.1")
+	scan(t, s, "192.168.1.1", "192.168.1.2")
+	scan(t, s, "192.168.1.1", "192.168.1.2")
+
+	got := eventsOfType(s, types.EventDeviceNew)
+	if len(got) != 1 || got[0].IP != "192.168.1.2" {
+		t.Fatalf("new device events = %+v, want one for 192.168.1.2", got)
+	}
+}
+
+func TestOfflineRecordedOnceWhenDeviceDisappears(t *testing.T) {
+	s := newTestStorage(t)
+	scan(t, s, "192.168.1.1", "192.168.1.2")
+	scan(t, s, "192.168.1.1")
+	scan(t, s, "192.168.1.1")
+
+	got := eventsOfType(s, types.EventDeviceOffline)
+	if len(got) != 1 || got[0].IP != "192.168.1.2" {
+		t.Fatalf("offline events = %+v, want one for 192.168.1.2", got)
+	}
+}
+
+func TestOfflineIgnoresOtherNetworks(t *testing.T) {
+	s := newTestStorage(t)
+	scan(t, s, "192.168.1.1")
+	if err := s.MergeDevices([]types.Device{{IP: "10.0.0.5"}}); err != nil {
+		t.Fatalf("MergeDevices: %v", err)
+	}
+	scan(t, s, "192.168.1.1")
+
+	if got := eventsOfType(s, types.EventDeviceOffline); len(got) != 0 {
+		t.Errorf("offline events = %+v, want none for a device on another network", got)
+	}
+}
+
+// age moves every time the store keeps back by d, as if d had passed.
+func age(s *Storage, d time.Duration) {
+	for _, dev := range s.devices {
+		dev.LastSeen = dev.LastSeen.Add(-d)
+	}
+	for cidr, t := range s.state.LastMerge {
+		s.state.LastMerge[cidr] = t.Add(-d)
+	}
+}
+
+func TestOfflineWaitsForGracePeriod(t *testing.T) {
+	s := newTestStorage(t)
+	s.SetOfflineAfter(func(d *types.Device) time.Duration {
+		if d.Group == "Mobile" {
+			return time.Hour
+		}
+		return 0
+	})
+	scan(t, s, "192.168.1.1", "192.168.1.2", "192.168.1.3", "192.168.1.4")
+	s.UpdateDevices([]string{"192.168.1.2", "192.168.1.4"}, func(d *types.Device) { d.Group = "Mobile" })
+	s.UpdateDevices([]string{"192.168.1.3"}, func(d *types.Device) { d.OfflineAfter = 600 })
+
+	offline := func() []string {
+		var ips []string
+		for _, e := range eventsOfType(s, types.EventDeviceOffline) {
+			ips = append(ips, e.IP)
+		}
+		return ips
+	}
+
+	age(s, 5*time.Minute)
+	scan(t, s, "192.168.1.1")
+	if got := offline(); len(got) != 0 {
+		t.Fatalf("offline after 5 minutes: %v; want none", got)
+	}
+	// The server, with 10 minutes of its own, is reported by the first scan
+	// after they run out. The phone that came back is never reported.
+	age(s, 10*time.Minute)
+	scan(t, s, "192.168.1.1", "192.168.1.4")
+	if got := offline(); len(got) != 1 || got[0] != "192.168.1.3" {
+		t.Fatalf("offline after 15 minutes: %v; want the server", got)
+	}
+	age(s, 50*time.Minute)
+	scan(t, s, "192.168.1.1", "192.168.1.4")
+	age(s, time.Hour)
+	scan(t, s, "192.168.1.1", "192.168.1.4")
+	if got := offline(); len(got) != 2 || got[0] != "192.168.1.2" {
+		t.Fatalf("offline after two hours: %v; want the phone, then the server, once each", got)
+	}
+}
+
+func TestMarkEventsRead(t *testing.T) {
+	s := newTestStorage(t)
+	for i := 0; i < 3; i++ {
+		if err := s.RecordScanFailure(testNetwork, "boom"); err != nil {
+			t.Fatalf("RecordScanFailure: %v", err)
+		}
+	}
+
+	events := s.GetEvents(0, false)
+	if err := s.MarkEventsRead([]int64{events[0].ID}); err != nil {
+		t.Fatalf("MarkEventsRead: %v", err)
+	}
+	if got := s.UnreadEvents(); got != 2 {
+		t.Errorf("UnreadEvents = %d after marking one, want 2", got)
+	}
+	if got := len(s.GetEvents(0, true)); got != 2 {
+		t.Errorf("unread events listed = %d, want 2", got)
+	}
+
+	if err := s.MarkEventsRead(nil); err != nil {
+		t.Fatalf("MarkEventsRead: %v", err)
+	}
+	if got := s.UnreadEvents(); got != 0 {
+		t.Errorf("UnreadEvents = %d after marking all, want 0", got)
+	}
+}
+
+func TestEventLogIsCapped(t *testing.T) {
+	s := newTestStorage(t)
+	for i := 0; i < maxEvents+10; i++ {
+		s.addEventLocked(types.Event{Type: types.EventScanFailed})
+	}
+
+	events := s.GetEvents(0, false)
+	if len(events) != maxEvents {
+		t.Fatalf("kept %d events, want %d", len(events), maxEvents)
+	}
+	if events[0].ID != int64(maxEvents+10) {
+		t.Errorf("newest event ID = %d, want the oldest ones dropped", events[0].ID)
+	}
+}
+
+func TestEventsSurviveReload(t *testing.T) {
+	dir := t.TempDir()
+	devices := filepath.Join(dir, "devices.json")
+	state := filepath.Join(dir, "state.json")
+
+	s, err := New(devices, state)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := s.RecordScanFailure(testNetwork, "boom"); err != nil {
+		t.Fatalf("RecordScanFailure: %v", err)
+	}
+
+	reloaded, err := New(devices, state)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	got := reloaded.GetEvents(0, false)
+	if len(got) != 1 || got[0].Detail != "boom" {
+		t.Fatalf("events after reload = %+v", got)
+	}
+
+	// IDs carry on from where the log left off rather than starting again.
+	if err := reloaded.RecordScanFailure(testNetwork, "again"); err != nil {
+		t.Fatalf("RecordScanFailure: %v", err)
+	}
+	if ids := reloaded.GetEvents(0, false); ids[0].ID <= ids[1].ID {
+		t.Errorf("event IDs %d and %d should keep increasing", ids[0].ID, ids[1].ID)
+	}
+}
+
+func TestReloadEventsSeesOtherWriters(t *testing.T) {
+	dir := t.TempDir()
+	devices := filepath.Join(dir, "devices.json")
+	state := filepath.Join(dir, "state.json")
+
+	reader, err := New(devices, state)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	writer, err := New(devices, state)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := writer.RecordScanFailure(testNetwork, "nmap not found"); err != nil {
+		t.Fatalf("RecordScanFailure: %v", err)
+	}
+
+	if got := reader.GetEvents(0, false); len(got) != 0 {
+		t.Fatalf("reader saw %d events before reloading", len(got))
+	}
+	if err := reader.ReloadEvents(); err != nil {
+		t.Fatalf("ReloadEvents: %v", err)
+	}
+	if got := reader.GetEvents(0, false); len(got) != 1 {
+		t.Errorf("got %d events after reloading, want 1", len(got))
+	}
+}
+
+func TestOfflineRecordedAfterPing(t *testing.T) {
+	s := newTestStorage(t)
+	scan(t, s, "192.168.1.1", "192.168.1.2")
+	// A ping between scans must not make the other device look like it was
+	// missing from the last one.
+	time.Sleep(time.Millisecond)
+	if _, err := s.MarkSeen("192.168.1.2", nil); err != nil {
+		t.Fatalf("MarkSeen: %v", err)
+	}
+	scan(t, s, "192.168.1.2")
+
+	got := eventsOfType(s, types.EventDeviceOffline)
+	if len(got) != 1 || got[0].IP != "192.168.1.1" {
+		t.Fatalf("offline events = %+v, want one for 192.168.1.1", got)
+	}
+}
+
+func TestReportUnreachable(t *testing.T) {
+	s := newTestStorage(t)
+	scan(t, s, "192.168.1.1", "192.168.1.2")
+
+	if ok, err := s.ReportUnreachable("192.168.1.1", "no reply to 2 pings"); !ok || err != nil {
+		t.Fatalf("ReportUnreachable = %v, %v", ok, err)
+	}
+	if ok, _ := s.ReportUnreachable("192.168.1.1", "no reply to 2 pings"); ok {
+		t.Error("reported a device offline twice")
+	}
+	// The scan that misses it too does not report it again.
+	scan(t, s, "192.168.1.2")
+	got := eventsOfType(s, types.EventDeviceOffline)
+	if len(got) != 1 || got[0].Detail != "no reply to 2 pings" || got[0].Network != testNetwork {
+		t.Fatalf("offline events = %+v, want the one reported between scans", got)
+	}
+
+	// Once it answers again, it can go again.
+	time.Sleep(time.Millisecond)
+	if n, err := s.MarkSeenAll(map[string]*float64{"192.168.1.1": nil, "192.168.1.99": nil}); n != 1 || err != nil {
+		t.Fatalf("MarkSeenAll = %d, %v; want the one known device", n, err)
+	}
+	time.Sleep(time.Millisecond)
+	if ok, _ := s.ReportUnreachable("192.168.1.1", "no reply to a ping"); !ok {
+		t.Error("a device that came back was not reported going again")
+	}
+}
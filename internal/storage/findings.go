@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// loadFindings reads the deep scans from their JSON file
+func (s *Storage) loadFindings() error {
+	if err := s.findingsFile.load(&s.deepScans); err != nil {
+		return err
+	}
+	if s.deepScans == nil {
+		s.deepScans = make(map[string]types.DeepScan)
+	}
+	return nil
+}
+
+// refreshFindingsLocked reads the deep scans again if another process has
+// written them since, as 'orangutan vulnscan' does beside a running server.
+// The caller must hold s.mu for writing.
+func (s *Storage) refreshFindingsLocked() {
+	if !s.findingsFile.changed() {
+		return
+	}
+	s.deepScans = make(map[string]types.DeepScan)
+	if err := s.loadFindings(); err != nil {
+		slog.Warn("cannot read findings", "file", s.findingsFile.path, "error", err)
+	}
+}
+
+// SetDeepScan records scan as the latest deep scan of its device, in place
+// of the one before: what it did not find again has been put right. What
+// the default login check found is kept, since the deep scan did not look.
+func (s *Storage) SetDeepScan(scan types.DeepScan) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.devices[scan.IP]; !ok {
+		return fmt.Errorf("device not found: %s", scan.IP)
+	}
+	s.refreshFindingsLocked()
+	if before, ok := s.deepScans[scan.IP]; ok {
+		if checked, ok := before.Checked[types.CheckDefaultLogins]; ok {
+			for _, f := range before.Findings {
+				if f.Check == types.CheckDefaultLogins {
+					scan.Findings = append(scan.Findings, f)
+				}
+			}
+			types.SortFindings(scan.Findings)
+			scan.Checked = map[string]time.Time{types.CheckDefaultLogins: checked}
+		}
+	}
+	s.deepScans[scan.IP] = scan
+	return s.findingsFile.save(s.deepScans)
+}
+
+// GetDeepScan returns the latest deep scan of ip, or nil when it has had
+// none.
+func (s *Storage) GetDeepScan(ip string) *types.DeepScan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshFindingsLocked()
+	scan, ok := s.deepScans[ip]
+	if !ok {
+		return nil
+	}
+	return &scan
+}
+
+// GetDeepScans returns the latest deep scan of each device that has had one,
+// by IP.
+func (s *Storage) GetDeepScans() map[string]types.DeepScan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshFindingsLocked()
+	result := make(map[string]types.DeepScan, len(s.deepScans))
+	for ip, scan := range s.deepScans {
+		result[ip] = scan
+	}
+	return result
+}
+
+// SetFindings replaces the findings check made of the device at ip with
+// findings, keeping those of every other check, and records that it ran. A
+// device never deep scanned gets a record holding only these. It returns
+// false when there is no device at ip.
+func (s *Storage) SetFindings(ip, check string, findings []types.Finding) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.devices[ip]; !ok {
+		return false, nil
+	}
+	s.refreshFindingsLocked()
+	scan, ok := s.deepScans[ip]
+	if !ok {
+		scan = types.DeepScan{IP: ip}
+	}
+	kept := make([]types.Finding, 0, len(scan.Findings)+len(findings))
+	for _, f := range scan.Findings {
+		if f.Check != check {
+			kept = append(kept, f)
+		}
+	}
+	kept = append(kept, findings...)
+	types.SortFindings(kept)
+	scan.Findings = kept
+	checked := make(map[string]time.Time, len(scan.Checked)+1)
+	for name, t := range scan.Checked {
+		checked[name] = t
+	}
+	checked[check] = time.Now()
+	scan.Checked = checked
+	s.deepScans[ip] = scan
+	return true, s.findingsFile.save(s.deepScans)
+}
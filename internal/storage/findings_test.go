@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func TestDeepScans(t *testing.T) {
+	s := newTestStorage(t)
+	scan(t, s, "192.168.1.10", "192.168.1.20")
+
+	first := types.DeepScan{IP: "192.168.1.10", Time: time.Now(), Scripts: "vulners", Findings: []types.Finding{
+		{Port: 22, Protocol: "tcp", Check: "vulners", ID: "CVE-2016-10012", Severity: types.SeverityHigh, CVSS: 7.8},
+		{Port: 22, Protocol: "tcp", Check: "vulners", ID: "CVE-2018-15919", Severity: types.SeverityMedium, CVSS: 5.3},
+	}}
+	if err := s.SetDeepScan(first); err != nil {
+		t.Fatalf("SetDeepScan: %v", err)
+	}
+	if err := s.SetDeepScan(types.DeepScan{IP: "192.168.1.99"}); err == nil {
+		t.Error("SetDeepScan recorded a deep scan of a device not in the inventory")
+	}
+	// A later scan replaces what the one before found.
+	second := first
+	second.Findings = first.Findings[1:]
+	if err := s.SetDeepScan(second); err != nil {
+		t.Fatalf("SetDeepScan: %v", err)
+	}
+
+	reopened, err := New(s.devicesFile, s.stateFile)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	got := reopened.GetDeepScan("192.168.1.10")
+	if got == nil || len(got.Findings) != 1 || got.Findings[0].ID != "CVE-2018-15919" {
+		t.Fatalf("GetDeepScan = %+v, want the second scan's one finding", got)
+	}
+	if reopened.GetDeepScan("192.168.1.20") != nil {
+		t.Error("a device never deep scanned has findings")
+	}
+
+	// Another check's findings are added beside the scan's own, and
+	// replace only its own the next time.
+	nvd := []types.Finding{{Port: 22, Protocol: "tcp", Check: "nvd", ID: "CVE-2016-6210", Severity: types.SeverityMedium, CVSS: 5.9}}
+	if ok, err := reopened.SetFindings("192.168.1.10", "nvd", nvd); !ok || err != nil {
+		t.Fatalf("SetFindings = %v, %v", ok, err)
+	}
+	if ok, _ := reopened.SetFindings("192.168.1.99", "nvd", nvd); ok {
+		t.Error("SetFindings added findings to a device not in the inventory")
+	}
+	got = reopened.GetDeepScan("192.168.1.10")
+	if len(got.Findings) != 2 || got.Findings[0].ID != "CVE-2016-6210" {
+		t.Fatalf("findings = %+v, want the NVD one first by its score", got.Findings)
+	}
+	if _, err := reopened.SetFindings("192.168.1.10", "nvd", nil); err != nil {
+		t.Fatalf("SetFindings: %v", err)
+	}
+	if got = reopened.GetDeepScan("192.168.1.10"); len(got.Findings) != 1 || got.Findings[0].Check != "vulners" {
+		t.Errorf("findings = %+v, want only the scan's own", got.Findings)
+	}
+
+	// The default login check runs apart from deep scans: a device it
+	// alone checked has findings, and a deep scan after it keeps them.
+	logins := []types.Finding{{Port: 80, Protocol: "tcp", Check: types.CheckDefaultLogins, Title: "Web interface accepts the login admin/admin", Severity: types.SeverityHigh}}
+	if ok, err := reopened.SetFindings("192.168.1.20", types.CheckDefaultLogins, logins); !ok || err != nil {
+		t.Fatalf("SetFindings = %v, %v", ok, err)
+	}
+	if got = reopened.GetDeepScan("192.168.1.20"); got == nil || !got.Time.IsZero() || len(got.Findings) != 1 {
+		t.Fatalf("GetDeepScan = %+v, want the login finding alone", got)
+	}
+	if err := reopened.SetDeepScan(types.DeepScan{IP: "192.168.1.20", Time: time.Now()}); err != nil {
+		t.Fatalf("SetDeepScan: %v", err)
+	}
+	if got = reopened.GetDeepScan("192.168.1.20"); len(got.Findings) != 1 || got.Checked[types.CheckDefaultLogins].IsZero() {
+		t.Errorf("after a deep scan = %+v, want the login finding kept", got)
+	}
+	if err := reopened.DeleteDevice("192.168.1.20"); err != nil {
+		t.Fatalf("DeleteDevice: %v", err)
+	}
+
+	// Deleting the device drops its findings, and another process notices.
+	if err := reopened.DeleteDevice("192.168.1.10"); err != nil {
+		t.Fatalf("DeleteDevice: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if all := s.GetDeepScans(); len(all) != 0 {
+		t.Errorf("GetDeepScans = %+v after the device was deleted", all)
+	}
+}
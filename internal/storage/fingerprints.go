@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// loadFingerprints reads the device fingerprints from their JSON file
+func (s *Storage) loadFingerprints() error {
+	if err := s.fingerprintsFile.load(&s.fingerprints); err != nil {
+		return err
+	}
+	if s.fingerprints == nil {
+		s.fingerprints = make(map[string]types.Fingerprint)
+	}
+	return nil
+}
+
+// refreshFingerprintsLocked reads the fingerprints again if another process
+// has written them since, as 'orangutan fingerprint --check' does beside a
+// running server. The caller must hold s.mu for writing.
+func (s *Storage) refreshFingerprintsLocked() {
+	if !s.fingerprintsFile.changed() {
+		return
+	}
+	s.fingerprints = make(map[string]types.Fingerprint)
+	if err := s.loadFingerprints(); err != nil {
+		slog.Warn("cannot read fingerprints", "file", s.fingerprintsFile.path, "error", err)
+	}
+}
+
+// SetFingerprint records fp as the fingerprint of the device at ip, in place
+// of the one before. changes, when there are any, describe how it differs
+// from that one by enough to be another device, and are recorded as an
+// anomaly event. It returns false when there is no device at ip.
+func (s *Storage) SetFingerprint(ip string, fp types.Fingerprint, changes []string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.devices[ip]
+	if !ok {
+		return false, nil
+	}
+	s.refreshFingerprintsLocked()
+	fp.IP = ip
+	s.fingerprints[ip] = fp
+	if err := s.fingerprintsFile.save(s.fingerprints); err != nil {
+		return true, err
+	}
+	if len(changes) == 0 {
+		return true, nil
+	}
+	s.addEventLocked(types.Event{
+		Type:    types.EventDeviceAnomaly,
+		Time:    fp.Taken,
+		IP:      ip,
+		Name:    deviceName(d),
+		Network: s.networkOfLocked(ip),
+		Detail:  types.AnomalyFingerprint,
+		Message: fmt.Sprintf("%s (%s) looks like another device: %s", deviceName(d), ip, strings.Join(changes, "; ")),
+	})
+	return true, s.saveEvents()
+}
+
+// GetFingerprints returns the fingerprint of each device fingerprinted, by
+// IP.
+func (s *Storage) GetFingerprints() map[string]types.Fingerprint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshFingerprintsLocked()
+	result := make(map[string]types.Fingerprint, len(s.fingerprints))
+	for ip, fp := range s.fingerprints {
+		result[ip] = fp
+	}
+	return result
+}
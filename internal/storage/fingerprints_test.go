@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func TestFingerprints(t *testing.T) {
+	s := newTestStorage(t)
+	scan(t, s, "192.168.1.10")
+	now := time.Now()
+
+	fp := types.Fingerprint{Vendor: "Synology", Hostname: "nas", Taken: now}
+	if ok, err := s.SetFingerprint("192.168.1.10", fp, nil); !ok || err != nil {
+		t.Fatalf("SetFingerprint = %v, %v", ok, err)
+	}
+	if ok, _ := s.SetFingerprint("192.168.1.99", fp, nil); ok {
+		t.Error("SetFingerprint recorded the fingerprint of a device not in the inventory")
+	}
+	if n := len(eventsOfType(s, types.EventDeviceAnomaly)); n != 0 {
+		t.Fatalf("%d anomaly events for a fingerprint with no changes", n)
+	}
+
+	fp.Vendor = "Espressif"
+	if _, err := s.SetFingerprint("192.168.1.10", fp, []string{"vendor Espressif, was Synology"}); err != nil {
+		t.Fatalf("SetFingerprint: %v", err)
+	}
+	events := eventsOfType(s, types.EventDeviceAnomaly)
+	if len(events) != 1 || events[0].Detail != types.AnomalyFingerprint || events[0].Network != testNetwork || events[0].Message != "192.168.1.10 (192.168.1.10) looks like another device: vendor Espressif, was Synology" {
+		t.Fatalf("events = %+v, want one about the new vendor", events)
+	}
+
+	reopened, err := New(s.devicesFile, s.stateFile)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if got := reopened.GetFingerprints()["192.168.1.10"]; got.IP != "192.168.1.10" || got.Vendor != "Espressif" || got.Hostname != "nas" {
+		t.Fatalf("after reopening = %+v, want the last fingerprint", got)
+	}
+	if err := reopened.DeleteDevice("192.168.1.10"); err != nil {
+		t.Fatalf("DeleteDevice: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if all := s.GetFingerprints(); len(all) != 0 {
+		t.Errorf("GetFingerprints = %+v after the device was deleted", all)
+	}
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"strings"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// ImportResult says what an import did, or would do.
+type ImportResult struct {
+	Created   int
+	Updated   int
+	Unchanged int
+	// Skipped counts records that match no stored device and have no IP
+	// address to store a new one under.
+	Skipped int
+}
+
+// ImportDevices merges devices read from a file into the store. Each record
+// is matched to a stored device by IP address, or failing that by MAC address,
+// so a label follows the hardware after DHCP has moved it. Records that
+// match nothing become new devices.
+//
+// An import only ever adds information: labels, groups, notes and types that
+// the file gives replace stored ones, tags are added, and everything else is
+// only filled in where nothing is stored. A blank cell never clears a value,
+// so re-importing an export of unlabelled devices cannot wipe labels that were
+// set since.
+//
+// With dryRun set the result is worked out without changing anything.
+func (s *Storage) ImportDevices(devices []types.Device, dryRun bool) (ImportResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byMAC := make(map[string]*types.Device)
+	for _, d := range s.devices {
+		if d.MAC != "" {
+			byMAC[strings.ToUpper(d.MAC)] = d
+		}
+	}
+
+	now := time.Now()
+	noted := false
+	var result ImportResult
+	for _, rec := range devices {
+		existing, ok := s.devices[rec.IP]
+		if !ok && rec.MAC != "" {
+			existing, ok = byMAC[strings.ToUpper(rec.MAC)]
+		}
+
+		if !ok {
+			if rec.IP == "" {
+				result.Skipped++
+				continue
+			}
+			result.Created++
+			if !dryRun {
+				d := rec
+				s.devices[d.IP] = &d
+				if d.MAC != "" {
+					byMAC[strings.ToUpper(d.MAC)] = &d
+				}
+			}
+			continue
+		}
+
+		// Work on a copy so a dry run leaves the stored device alone.
+		merged := *existing
+		merged.Tags = append([]string(nil), existing.Tags...)
+		mergeImported(&merged, rec)
+		if devicesEqual(&merged, existing) {
+			result.Unchanged++
+			continue
+		}
+		result.Updated++
+		if !dryRun {
+			if s.noteChangesLocked(existing, &merged, now) {
+				noted = true
+			}
+			*existing = merged
+		}
+	}
+
+	if dryRun || result.Created+result.Updated == 0 {
+		return result, nil
+	}
+	if err := s.saveDevices(); err != nil {
+		return result, err
+	}
+	return result, s.saveChangesIf(noted)
+}
+
+// mergeImported applies the fields of an imported record to d as described
+// on ImportDevices.
+func mergeImported(d *types.Device, rec types.Device) {
+	if rec.Label != "" {
+		d.Label = rec.Label
+	}
+	if rec.Group != "" {
+		d.Group = rec.Group
+	}
+	if rec.Notes != "" {
+		d.Notes = rec.Notes
+	}
+	if rec.Type != "" {
+		d.Type = rec.Type
+	}
+	for _, tag := range rec.Tags {
+		d.AddTag(tag)
+	}
+
+	if d.MAC == "" {
+		d.MAC = rec.MAC
+	}
+	if d.Hostname == "" {
+		d.Hostname = rec.Hostname
+	}
+	if d.Vendor == "" || d.Vendor == "Unknown" {
+		if rec.Vendor != "" {
+			d.Vendor = rec.Vendor
+		}
+	}
+	// Exports give times to the second; compare at that precision, or every
+	// re-import would move first seen back by a fraction of a second.
+	if !rec.FirstSeen.IsZero() && (d.FirstSeen.IsZero() || rec.FirstSeen.Before(d.FirstSeen.Truncate(time.Second))) {
+		d.FirstSeen = rec.FirstSeen
+	}
+	if rec.LastSeen.After(d.LastSeen) {
+		d.LastSeen = rec.LastSeen
+	}
+	if rec.Wireless != nil && (d.Wireless == nil || rec.Wireless.Seen.After(d.Wireless.Seen)) {
+		d.Wireless = rec.Wireless
+	}
+}
+
+// devicesEqual compares the fields an import can change.
+func devicesEqual(a, b *types.Device) bool {
+	return a.Label == b.Label && a.Group == b.Group && a.Notes == b.Notes &&
+		a.Type == b.Type && strings.Join(a.Tags, "\x00") == strings.Join(b.Tags, "\x00") &&
+		a.MAC == b.MAC && a.Hostname == b.Hostname && a.Vendor == b.Vendor &&
+		a.FirstSeen.Equal(b.FirstSeen) && a.LastSeen.Equal(b.LastSeen) &&
+		(a.Wireless == nil) == (b.Wireless == nil) && (a.Wireless == nil || *a.Wireless == *b.Wireless)
+}
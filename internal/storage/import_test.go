@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func TestImportUpdatesAndCreates(t *testing.T) {
+	s := newTestStorage(t)
+	scan(t, s, "192.168.1.1")
+
+	result, err := s.ImportDevices([]types.Device{
+		{IP: "192.168.1.1", Label: "Router", Tags: []string{"core"}},
+		{IP: "192.168.1.50", Label: "Printer"},
+	}, false)
+	if err != nil {
+		t.Fatalf("ImportDevices: %v", err)
+	}
+	if result.Updated != 1 || result.Created != 1 {
+		t.Errorf("result = %+v, want one updated and one created", result)
+	}
+
+	if d := s.GetDevice("192.168.1.1"); d.Label != "Router" || !d.HasTag("core") {
+		t.Errorf("existing device not updated: %+v", d)
+	}
+	if d := s.GetDevice("192.168.1.50"); d == nil || d.Label != "Printer" {
+		t.Errorf("new device not created: %+v", d)
+	}
+}
+
+func TestImportBlankFieldsKeepStoredValues(t *testing.T) {
+	s := newTestStorage(t)
+	scan(t, s, "192.168.1.1")
+	label, group := "Router", "Network"
+	if err := s.UpdateDeviceFields("192.168.1.1", &label, nil, &group, nil); err != nil {
+		t.Fatalf("UpdateDeviceFields: %v", err)
+	}
+
+	result, err := s.ImportDevices([]types.Device{{IP: "192.168.1.1"}}, false)
+	if err != nil {
+		t.Fatalf("ImportDevices: %v", err)
+	}
+	if result.Unchanged != 1 {
+		t.Errorf("result = %+v, want the device unchanged", result)
+	}
+	if d := s.GetDevice("192.168.1.1"); d.Label != "Router" || d.Group != "Network" {
+		t.Errorf("blank fields cleared stored values: %+v", d)
+	}
+}
+
+func TestImportMatchesByMAC(t *testing.T) {
+	s := newTestStorage(t)
+	if err := s.MergeDevices([]types.Device{{IP: "192.168.1.9", MAC: "AA:BB:CC:DD:EE:09"}}); err != nil {
+		t.Fatalf("MergeDevices: %v", err)
+	}
+
+	result, err := s.ImportDevices([]types.Device{
+		{IP: "192.168.1.200", MAC: "AA:BB:CC:DD:EE:09", Label: "Laptop"},
+		{MAC: "AA:BB:CC:DD:EE:99", Label: "Nowhere"},
+	}, false)
+	if err != nil {
+		t.Fatalf("ImportDevices: %v", err)
+	}
+	if result.Updated != 1 || result.Skipped != 1 || result.Created != 0 {
+		t.Errorf("result = %+v, want one updated by MAC and one skipped", result)
+	}
+	if d := s.GetDevice("192.168.1.9"); d.Label != "Laptop" {
+		t.Errorf("label did not follow the MAC address: %+v", d)
+	}
+}
+
+func TestImportDryRunChangesNothing(t *testing.T) {
+	s := newTestStorage(t)
+	scan(t, s, "192.168.1.1")
+
+	result, err := s.ImportDevices([]types.Device{
+		{IP: "192.168.1.1", Label: "Router", Tags: []string{"core"}},
+		{IP: "192.168.1.50", Label: "Printer"},
+	}, true)
+	if err != nil {
+		t.Fatalf("ImportDevices: %v", err)
+	}
+	if result.Updated != 1 || result.Created != 1 {
+		t.Errorf("result = %+v, want one updated and one created", result)
+	}
+	if d := s.GetDevice("192.168.1.1"); d.Label != "" || len(d.Tags) != 0 {
+		t.Errorf("dry run changed a device: %+v", d)
+	}
+	if d := s.GetDevice("192.168.1.50"); d != nil {
+		t.Errorf("dry run created a device: %+v", d)
+	}
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// jsonFile is a JSON file beside the device list holding a T, which another
+// process may write while this one has it loaded, as the command line does
+// beside a running server. It remembers when the file was written as this
+// process last read or wrote it, so that such a change is noticed.
+type jsonFile[T any] struct {
+	path string
+	// what names what the file holds, for errors.
+	what string
+	read time.Time
+}
+
+// load reads the file into v. An empty file leaves v as it is.
+func (f *jsonFile[T]) load(v *T) error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	f.read = info.ModTime()
+
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+// save writes v to the file atomically.
+func (f *jsonFile[T]) save(v T) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", f.what, err)
+	}
+
+	if err := atomicWrite(f.path, data); err != nil {
+		return err
+	}
+	if info, err := os.Stat(f.path); err == nil {
+		f.read = info.ModTime()
+	}
+	return nil
+}
+
+// changed reports whether another process has written the file since this
+// one last read or wrote it.
+func (f *jsonFile[T]) changed() bool {
+	info, err := os.Stat(f.path)
+	return err == nil && !info.ModTime().Equal(f.read)
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJSONFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "things.json")
+	f := jsonFile[map[string]int]{path: path, what: "things"}
+	var got map[string]int
+	if err := f.load(&got); !os.IsNotExist(err) {
+		t.Fatalf("load of a file not written = %v; want it not to exist", err)
+	}
+	if f.changed() {
+		t.Error("a file not written has changed")
+	}
+
+	if err := f.save(map[string]int{"a": 1}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if f.changed() {
+		t.Error("the file changed after this process wrote it")
+	}
+
+	// Another process writes it, a second later.
+	other := jsonFile[map[string]int]{path: path, what: "things"}
+	if err := other.save(map[string]int{"a": 1, "b": 2}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if !f.changed() {
+		t.Fatal("the file another process wrote has not changed")
+	}
+	if err := f.load(&got); err != nil || len(got) != 2 || got["b"] != 2 {
+		t.Fatalf("load = %v, %v; want what the other process wrote", got, err)
+	}
+	if f.changed() {
+		t.Error("the file changed after this process read it")
+	}
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// ErrAmbiguous is returned, wrapped, when a key given to FindDevice names
+// more than one device.
+var ErrAmbiguous = errors.New("matches several devices")
+
+// FindDevice returns the device key names: its IP address, MAC address,
+// label or hostname. See Find.
+func (s *Storage) FindDevice(key string) (*types.Device, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return Find(s.devices, key)
+}
+
+// Find returns the device among devices that key names. An IP address must
+// match exactly; a MAC address matches however it is written. Anything else
+// is taken as a label or hostname, ignoring case, with labels winning over
+// hostnames as they are what the user chose to call a device.
+//
+// IP addresses change with DHCP leases, so people mostly know their devices
+// by the other three; but those need not be unique, and a key that names
+// several devices is an error wrapping ErrAmbiguous rather than a guess.
+func Find(devices map[string]*types.Device, key string) (*types.Device, error) {
+	if net.ParseIP(key) != nil {
+		if d, ok := devices[key]; ok {
+			return d, nil
+		}
+		return nil, fmt.Errorf("device not found: %s", key)
+	}
+
+	if mac, err := net.ParseMAC(key); err == nil {
+		matches := matchDevices(devices, func(d *types.Device) bool {
+			hw, err := net.ParseMAC(d.MAC)
+			return err == nil && bytes.Equal(hw, mac)
+		})
+		return oneDevice(matches, key)
+	}
+
+	for _, field := range []func(*types.Device) string{
+		func(d *types.Device) string { return d.Label },
+		func(d *types.Device) string { return d.Hostname },
+	} {
+		matches := matchDevices(devices, func(d *types.Device) bool {
+			v := field(d)
+			return v != "" && strings.EqualFold(v, key)
+		})
+		if len(matches) > 0 {
+			return oneDevice(matches, key)
+		}
+	}
+	return nil, fmt.Errorf("no device with the label or hostname %q", key)
+}
+
+func matchDevices(devices map[string]*types.Device, match func(*types.Device) bool) []*types.Device {
+	var matches []*types.Device
+	for _, d := range devices {
+		if match(d) {
+			matches = append(matches, d)
+		}
+	}
+	return matches
+}
+
+// oneDevice returns the only device in matches, or an error listing their
+// addresses so the user can pick one.
+func oneDevice(matches []*types.Device, key string) (*types.Device, error) {
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("device not found: %s", key)
+	case 1:
+		return matches[0], nil
+	}
+	ips := make([]string, len(matches))
+	for i, d := range matches {
+		ips[i] = d.IP
+	}
+	sort.Slice(ips, func(i, j int) bool { return types.LessIP(ips[i], ips[j]) })
+	return nil, fmt.Errorf("%q %w (%s); use the IP address instead", key, ErrAmbiguous, strings.Join(ips, ", "))
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func TestFind(t *testing.T) {
+	devices := map[string]*types.Device{
+		"192.168.1.2":  {IP: "192.168.1.2", MAC: "aa:bb:cc:dd:ee:01", Hostname: "nas.lan"},
+		"192.168.1.3":  {IP: "192.168.1.3", MAC: "aa:bb:cc:dd:ee:02", Hostname: "printer", Label: "Office printer"},
+		"192.168.1.4":  {IP: "192.168.1.4", Hostname: "Office Printer"},
+		"192.168.1.10": {IP: "192.168.1.10", Label: "Camera"},
+		"192.168.1.11": {IP: "192.168.1.11", Label: "camera"},
+	}
+
+	for key, want := range map[string]string{
+		"192.168.1.2":       "192.168.1.2",
+		"AA-BB-CC-DD-EE-02": "192.168.1.3",
+		"NAS.lan":           "192.168.1.2",
+		// The label wins over another device's hostname.
+		"office printer": "192.168.1.3",
+	} {
+		d, err := Find(devices, key)
+		if err != nil {
+			t.Errorf("Find(%q): %v", key, err)
+			continue
+		}
+		if d.IP != want {
+			t.Errorf("Find(%q) = %s, want %s", key, d.IP, want)
+		}
+	}
+
+	for _, key := range []string{"192.168.1.99", "aa:bb:cc:dd:ee:99", "nothing"} {
+		if d, err := Find(devices, key); err == nil {
+			t.Errorf("Find(%q) = %s, want an error", key, d.IP)
+		}
+	}
+
+	_, err := Find(devices, "camera")
+	if !errors.Is(err, ErrAmbiguous) {
+		t.Fatalf("Find of a shared label: got %v, want ErrAmbiguous", err)
+	}
+	if want := `"camera" matches several devices (192.168.1.10, 192.168.1.11); use the IP address instead`; err.Error() != want {
+		t.Errorf("got %q, want %q", err, want)
+	}
+}
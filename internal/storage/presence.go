@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// SetPeople sets the people whose devices scans look for, to say whether
+// they are home and record them arriving and leaving. nil, the default,
+// follows nobody.
+func (s *Storage) SetPeople(people []types.Person) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.people = people
+}
+
+// GetPresence returns whether each person SetPeople gave is home now, in the
+// order it gave them.
+func (s *Storage) GetPresence() []types.Presence {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	result := make([]types.Presence, 0, len(s.people))
+	for _, p := range s.people {
+		result = append(result, s.presenceLocked(p, now))
+	}
+	return result
+}
+
+// presenceLocked works out whether p is home at now: whether the latest scan
+// of its network found one of their devices, or one has been seen within
+// p.AwayAfter. Since is taken from the state while it agrees. The caller must
+// hold s.mu.
+func (s *Storage) presenceLocked(p types.Person, now time.Time) types.Presence {
+	pr := types.Presence{Name: p.Name}
+	for _, d := range s.devices {
+		if d.MAC == "" || !hasMAC(p.MACs, d.MAC) {
+			continue
+		}
+		if d.LastSeen.After(pr.LastSeen) {
+			pr.IP, pr.Device, pr.LastSeen = d.IP, deviceName(d), d.LastSeen
+		}
+		// A network no longer scanned leaves its last scan standing, so
+		// that alone does not keep anyone home for good.
+		if (s.stillPresentLocked(d) && d.IsOnline()) || now.Sub(d.LastSeen) < p.AwayAfter {
+			pr.Home = true
+		}
+	}
+	if known, ok := s.state.Presence[p.Name]; ok && known.Home == pr.Home {
+		pr.Since = known.Since
+	}
+	return pr
+}
+
+// recordPresenceLocked adds an event for each person who has come home or
+// left since it last looked, and reports whether anyone's state changed and
+// must be saved. People looked for the first time are taken as they are,
+// with no event, as the first scan takes devices. The caller must hold s.mu
+// for writing.
+func (s *Storage) recordPresenceLocked(now time.Time) bool {
+	changed := false
+	for _, p := range s.people {
+		pr := s.presenceLocked(p, now)
+		known, ok := s.state.Presence[p.Name]
+		if ok && known.Home == pr.Home {
+			continue
+		}
+		if ok {
+			pr.Since = now
+			e := types.Event{
+				Type:    types.EventPersonArrived,
+				Time:    now,
+				IP:      pr.IP,
+				Name:    p.Name,
+				Message: fmt.Sprintf("%s arrived home (%s, %s)", p.Name, pr.Device, pr.IP),
+			}
+			if !pr.Home {
+				e.Type = types.EventPersonLeft
+				e.Message = fmt.Sprintf("%s left home; %s (%s) was last seen at %s", p.Name, pr.Device, pr.IP, pr.LastSeen.Format("15:04"))
+			}
+			s.addEventLocked(e)
+		}
+		s.state.Presence[p.Name] = types.Presence{Name: p.Name, Home: pr.Home, Since: pr.Since}
+		changed = true
+	}
+	return changed
+}
+
+// hasMAC reports whether mac is one of macs, ignoring case.
+func hasMAC(macs []string, mac string) bool {
+	for _, m := range macs {
+		if strings.EqualFold(m, mac) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func TestPresence(t *testing.T) {
+	s := newTestStorage(t)
+	s.SetPeople([]types.Person{
+		{Name: "Alice", MACs: []string{"AA:BB:CC:00:00:01", "aa:bb:cc:00:00:02"}},
+		{Name: "Bob", MACs: []string{"aa:bb:cc:00:00:03"}, AwayAfter: time.Hour},
+	})
+	router := types.Device{IP: "192.168.1.1"}
+	phone := types.Device{IP: "192.168.1.20", MAC: "aa:bb:cc:00:00:01", Label: "Alice's phone"}
+	watch := types.Device{IP: "192.168.1.21", MAC: "aa:bb:cc:00:00:02"}
+	bobs := types.Device{IP: "192.168.1.30", MAC: "aa:bb:cc:00:00:03"}
+
+	merge(t, s, testNetwork, router, phone, bobs)
+	merge(t, s, testNetwork, router, watch)
+	if got := len(eventsOfType(s, types.EventPersonArrived)) + len(eventsOfType(s, types.EventPersonLeft)); got != 0 {
+		t.Fatalf("%d presence events while one of each person's devices stayed, want none", got)
+	}
+
+	merge(t, s, testNetwork, router)
+	left := eventsOfType(s, types.EventPersonLeft)
+	if len(left) != 1 || left[0].Name != "Alice" || left[0].IP != watch.IP {
+		t.Fatalf("left = %+v, want Alice leaving, last seen on her watch", left)
+	}
+	merge(t, s, testNetwork, router, phone)
+	arrived := eventsOfType(s, types.EventPersonArrived)
+	if len(arrived) != 1 || arrived[0].Name != "Alice" || arrived[0].IP != phone.IP {
+		t.Fatalf("arrived = %+v, want Alice arriving with her phone", arrived)
+	}
+
+	presence := s.GetPresence()
+	if len(presence) != 2 || !presence[0].Home || presence[0].Since.IsZero() || presence[0].Device != "Alice's phone" {
+		t.Errorf("Alice = %+v, want home since she arrived, on her phone", presence[0])
+	}
+	// Bob's device has been missed, but not for an hour.
+	if len(presence) != 2 || !presence[1].Home || !presence[1].Since.IsZero() {
+		t.Errorf("Bob = %+v, want still home", presence[1])
+	}
+
+	// The state outlives the process, so the arrival is not reported again.
+	reopened, err := New(s.devicesFile, s.stateFile)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	reopened.SetPeople(s.people)
+	merge(t, reopened, testNetwork, router, phone)
+	if got := len(eventsOfType(reopened, types.EventPersonArrived)); got != 1 {
+		t.Errorf("%d arrivals after reopening, want the one already recorded", got)
+	}
+}
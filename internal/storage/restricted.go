@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// SetRestricted sets the networks and groups only some devices may be on.
+// Each device a later scan finds entering one without being allowed there is
+// recorded as an intrusion event. nil, the default, restricts nothing.
+func (s *Storage) SetRestricted(zones []types.Restricted) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.restricted = zones
+}
+
+// recordIntrusionsLocked adds an event for each restricted zone that d, as a
+// scan of cidr found it, has entered without being allowed there. before is
+// the device stored at d's address before the scan, or nil if the address is
+// new, and lastMerge when the scan before this one of cidr was merged. The
+// caller must hold s.mu for writing.
+//
+// A device enters a zone when it is new, comes back after a scan missed it,
+// answers with another MAC than before, or is now somewhere the zone covers,
+// as when a scan tags it with a restricted group. While it stays it is not
+// reported again.
+func (s *Storage) recordIntrusionsLocked(cidr string, d, before *types.Device, lastMerge, now time.Time) {
+	for _, r := range s.restricted {
+		if !r.Covers(d) || r.Allows(d) {
+			continue
+		}
+		if before != nil && r.Covers(before) && !differs(before.MAC, d.MAC) && (cidr == "" || !before.LastSeen.Before(lastMerge)) {
+			continue
+		}
+		network := cidr
+		if network == "" {
+			network = s.networkOfLocked(d.IP)
+		}
+		who := d.IP
+		if d.MAC != "" {
+			who += ", MAC " + d.MAC
+		}
+		s.addEventLocked(types.Event{
+			Type:    types.EventIntrusion,
+			Time:    now,
+			IP:      d.IP,
+			Name:    deviceName(d),
+			Network: network,
+			Detail:  r.Name,
+			Message: fmt.Sprintf("%s (%s) turned up in %s, where it is not allowed", deviceName(d), who, r.Name),
+		})
+	}
+}
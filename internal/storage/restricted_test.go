@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func TestIntrusions(t *testing.T) {
+	s := newTestStorage(t)
+	s.SetRestricted([]types.Restricted{
+		{Name: "cameras", Networks: []string{"192.168.20.0/24"}, Allowed: []string{"aa:bb:cc:00:00:01", "192.168.20.9"}},
+		{Name: "iot", Groups: []string{"IoT"}},
+	})
+	cameras := "192.168.20.0/24"
+	allowed := types.Device{IP: "192.168.20.1", MAC: "AA-BB-CC-00-00-01"}
+	routed := types.Device{IP: "192.168.20.9"}
+	intruder := types.Device{IP: "192.168.20.77", MAC: "aa:bb:cc:00:00:77"}
+
+	// Even on the first scan, a device where it must not be is news.
+	merge(t, s, cameras, allowed, routed, intruder)
+	merge(t, s, testNetwork, types.Device{IP: "192.168.1.5", MAC: "aa:bb:cc:00:00:05"})
+	events := eventsOfType(s, types.EventIntrusion)
+	if len(events) != 1 || events[0].IP != intruder.IP || events[0].Detail != "cameras" || events[0].Network != cameras ||
+		events[0].Message != "192.168.20.77 (192.168.20.77, MAC aa:bb:cc:00:00:77) turned up in cameras, where it is not allowed" {
+		t.Fatalf("events = %+v, want the one device not allowed", events)
+	}
+
+	// While it stays it is not reported again, but it is once it has gone
+	// and come back, or another device answers at its address.
+	merge(t, s, cameras, allowed, routed, intruder)
+	if n := len(eventsOfType(s, types.EventIntrusion)); n != 1 {
+		t.Fatalf("%d events while it stayed, want 1", n)
+	}
+	merge(t, s, cameras, allowed, routed)
+	merge(t, s, cameras, allowed, routed, intruder)
+	swapped := types.Device{IP: allowed.IP, MAC: "aa:bb:cc:00:00:99"}
+	merge(t, s, cameras, swapped, routed, intruder)
+	if events = eventsOfType(s, types.EventIntrusion); len(events) != 3 || events[1].IP != intruder.IP || events[0].IP != allowed.IP {
+		t.Fatalf("events = %+v, want the return and the swapped device", events)
+	}
+
+	// A device put in a restricted group by hand was put there on purpose;
+	// one a scan tags with the group has turned up in it.
+	if _, err := s.UpdateDevices([]string{"192.168.1.5"}, func(d *types.Device) { d.Group = "iot" }); err != nil {
+		t.Fatalf("UpdateDevices: %v", err)
+	}
+	merge(t, s, testNetwork, types.Device{IP: "192.168.1.5", MAC: "aa:bb:cc:00:00:05"})
+	events = eventsOfType(s, types.EventIntrusion)
+	if len(events) != 3 {
+		t.Fatalf("events = %+v; a device already there is not new to the group", events)
+	}
+	merge(t, s, testNetwork, types.Device{IP: "192.168.1.6", MAC: "aa:bb:cc:00:00:06", Tags: []string{"IoT"}})
+	if events = eventsOfType(s, types.EventIntrusion); len(events) != 4 || events[0].Detail != "iot" {
+		t.Fatalf("events = %+v, want one about the device tagged IoT", events)
+	}
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// sightingGap is how far apart two scans can find a device and still have it
+// counted as present in between. It matches how long Device.IsOnline treats a
+// sighting as current, so the timeline and the status dot agree.
+const sightingGap = time.Hour
+
+// sightingRetention is how much history is kept per device: enough for the
+// longest timeline the device page offers, and no more.
+const sightingRetention = 31 * 24 * time.Hour
+
+// loadSightings reads the sighting history from its JSON file
+func (s *Storage) loadSightings() error {
+	data, err := os.ReadFile(s.sightingsFile)
+	if err != nil {
+		return err
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(data, &s.sightings); err != nil {
+		return err
+	}
+	if s.sightings == nil {
+		s.sightings = make(map[string][]types.Sighting)
+	}
+	return nil
+}
+
+// saveSightings writes the sighting history to its JSON file atomically
+func (s *Storage) saveSightings() error {
+	data, err := json.MarshalIndent(s.sightings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal sightings: %w", err)
+	}
+
+	return atomicWrite(s.sightingsFile, data)
+}
+
+// GetSightings returns the stretches of time ip was seen that end at or after
+// since, oldest first.
+func (s *Storage) GetSightings(ip string, since time.Time) []types.Sighting {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]types.Sighting, 0)
+	for _, sg := range s.sightings[ip] {
+		if !sg.End.Before(since) {
+			result = append(result, sg)
+		}
+	}
+	return result
+}
+
+// recordSightingsLocked notes that every device in discovered was seen at
+// now, extending its latest sighting when that one is recent and still open.
+// History older than sightingRetention is dropped on the way. The caller must
+// hold s.mu for writing.
+func (s *Storage) recordSightingsLocked(discovered []types.Device, now time.Time) {
+	for _, d := range discovered {
+		spans := s.sightings[d.IP]
+		if n := len(spans); n > 0 && !spans[n-1].Closed && now.Sub(spans[n-1].End) <= sightingGap {
+			spans[n-1].End = now
+		} else {
+			spans = append(spans, types.Sighting{Start: now, End: now})
+		}
+		s.sightings[d.IP] = spans
+	}
+
+	cutoff := now.Add(-sightingRetention)
+	for ip, spans := range s.sightings {
+		keep := 0
+		for keep < len(spans) && spans[keep].End.Before(cutoff) {
+			keep++
+		}
+		switch {
+		case keep == len(spans):
+			delete(s.sightings, ip)
+		case keep > 0:
+			s.sightings[ip] = append([]types.Sighting(nil), spans[keep:]...)
+		}
+	}
+}
+
+// closeSightingsLocked ends the open sighting of each device in cidr that the
+// scan did not find, so that a device which drops off for a few minutes shows
+// a gap rather than being bridged over. The caller must hold s.mu for writing.
+func (s *Storage) closeSightingsLocked(cidr string, found map[string]bool) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return
+	}
+
+	for ip, spans := range s.sightings {
+		addr := net.ParseIP(ip)
+		if found[ip] || addr == nil || !ipNet.Contains(addr) || len(spans) == 0 {
+			continue
+		}
+		spans[len(spans)-1].Closed = true
+	}
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func TestRepeatedSightingsExtendOneStretch(t *testing.T) {
+	s := newTestStorage(t)
+	scan(t, s, "192.168.1.1")
+	scan(t, s, "192.168.1.1")
+	scan(t, s, "192.168.1.1")
+
+	got := s.GetSightings("192.168.1.1", time.Time{})
+	if len(got) != 1 {
+		t.Fatalf("got %d sightings, want one continuous stretch: %+v", len(got), got)
+	}
+	if got[0].End.Before(got[0].Start) {
+		t.Errorf("sighting ends before it starts: %+v", got[0])
+	}
+}
+
+func TestMissedScanStartsNewStretch(t *testing.T) {
+	s := newTestStorage(t)
+	scan(t, s, "192.168.1.1", "192.168.1.2")
+	scan(t, s, "192.168.1.1")
+	scan(t, s, "192.168.1.1", "192.168.1.2")
+
+	if got := s.GetSightings("192.168.1.2", time.Time{}); len(got) != 2 {
+		t.Errorf("got %d sightings for the device that dropped off, want 2", len(got))
+	}
+	if got := s.GetSightings("192.168.1.1", time.Time{}); len(got) != 1 {
+		t.Errorf("got %d sightings for the device that stayed, want 1", len(got))
+	}
+}
+
+func TestSightingsAfterLongGapAreSeparate(t *testing.T) {
+	s := newTestStorage(t)
+	old := time.Now().Add(-2 * sightingGap)
+	s.sightings["192.168.1.1"] = []types.Sighting{{Start: old, End: old}}
+
+	scan(t, s, "192.168.1.1")
+
+	if got := s.GetSightings("192.168.1.1", time.Time{}); len(got) != 2 {
+		t.Errorf("got %d sightings, want a new stretch after a gap longer than %s", len(got), sightingGap)
+	}
+}
+
+func TestOldSightingsArePruned(t *testing.T) {
+	s := newTestStorage(t)
+	old := time.Now().Add(-sightingRetention - time.Hour)
+	s.sightings["192.168.1.9"] = []types.Sighting{{Start: old, End: old}}
+
+	scan(t, s, "192.168.1.1")
+
+	if got := s.GetSightings("192.168.1.9", time.Time{}); len(got) != 0 {
+		t.Errorf("sightings older than the retention period were kept: %+v", got)
+	}
+}
+
+func TestSightingsSurviveReload(t *testing.T) {
+	dir := t.TempDir()
+	devices := filepath.Join(dir, "devices.json")
+	state := filepath.Join(dir, "state.json")
+
+	s, err := New(devices, state)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	scan(t, s, "192.168.1.1")
+
+	reloaded, err := New(devices, state)
+	if err != nil {
+		t.Fatalf("reloading: %v", err)
+	}
+	if got := reloaded.GetSightings("192.168.1.1", time.Time{}); len(got) != 1 {
+		t.Errorf("got %d sightings after reload, want 1", len(got))
+	}
+}
+
+func TestDeletingDeviceForgetsSightings(t *testing.T) {
+	s := newTestStorage(t)
+	scan(t, s, "192.168.1.1")
+
+	if err := s.DeleteDevice("192.168.1.1"); err != nil {
+		t.Fatalf("DeleteDevice: %v", err)
+	}
+	if got := s.GetSightings("192.168.1.1", time.Time{}); len(got) != 0 {
+		t.Errorf("a deleted device still has sightings: %+v", got)
+	}
+}
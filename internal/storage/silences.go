@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// maxSilences caps the silences kept. Those that have ended are kept as a
+// record of who muted what, and the oldest of them dropped once it is full.
+const maxSilences = 200
+
+// loadSilences reads the silences from their JSON file
+func (s *Storage) loadSilences() error {
+	if err := s.silencesFile.load(&s.silences); err != nil {
+		return err
+	}
+	for _, silence := range s.silences {
+		if silence.ID >= s.nextSilenceID {
+			s.nextSilenceID = silence.ID + 1
+		}
+	}
+	return nil
+}
+
+// refreshSilencesLocked reads the silences again if another process has
+// written them since, as 'orangutan silence' does beside a running server.
+// The caller must hold s.mu for writing.
+func (s *Storage) refreshSilencesLocked() {
+	if !s.silencesFile.changed() {
+		return
+	}
+	s.silences = nil
+	if err := s.loadSilences(); err != nil {
+		slog.Warn("cannot read silences", "file", s.silencesFile.path, "error", err)
+	}
+}
+
+// AddSilence records silence, filling in its ID and, if unset, when it was
+// created, and returns it as recorded. It fails for a silence that has
+// already expired or names a network that is not a CIDR.
+func (s *Storage) AddSilence(silence types.Silence) (types.Silence, error) {
+	if !silence.Expires.After(time.Now()) {
+		return silence, fmt.Errorf("the silence has already expired")
+	}
+	for _, cidr := range silence.Networks {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return silence, fmt.Errorf("%q is not a CIDR such as 192.168.1.0/24", cidr)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshSilencesLocked()
+	if s.nextSilenceID == 0 {
+		s.nextSilenceID = 1
+	}
+	silence.ID = s.nextSilenceID
+	s.nextSilenceID++
+	if silence.Created.IsZero() {
+		silence.Created = time.Now()
+	}
+
+	s.silences = append(s.silences, silence)
+	now := time.Now()
+	for i := 0; len(s.silences) > maxSilences && i < len(s.silences); {
+		if s.silences[i].Active(now) {
+			i++
+			continue
+		}
+		s.silences = append(s.silences[:i:i], s.silences[i+1:]...)
+	}
+	return silence, s.silencesFile.save(s.silences)
+}
+
+// RemoveSilence ends the silence id early, recording by as who ended it. It
+// returns false when there is no such silence in force.
+func (s *Storage) RemoveSilence(id int64, by string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshSilencesLocked()
+	now := time.Now()
+	for i := range s.silences {
+		if s.silences[i].ID != id || !s.silences[i].Active(now) {
+			continue
+		}
+		s.silences[i].Removed = now
+		s.silences[i].RemovedBy = by
+		return true, s.silencesFile.save(s.silences)
+	}
+	return false, nil
+}
+
+// GetSilences returns the silences in force, or all of them when all is set,
+// newest first.
+func (s *Storage) GetSilences(all bool) []types.Silence {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshSilencesLocked()
+	now := time.Now()
+	result := make([]types.Silence, 0)
+	for i := len(s.silences) - 1; i >= 0; i-- {
+		if all || s.silences[i].Active(now) {
+			result = append(result, s.silences[i])
+		}
+	}
+	return result
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func TestSilences(t *testing.T) {
+	s := newTestStorage(t)
+	now := time.Now()
+	bulb, err := s.AddSilence(types.Silence{Devices: []string{"hall bulb"}, Reason: "flapping", CreatedBy: "alice", Expires: now.Add(7 * 24 * time.Hour)})
+	if err != nil {
+		t.Fatalf("AddSilence: %v", err)
+	}
+	lab, _ := s.AddSilence(types.Silence{Networks: []string{"10.0.20.0/24"}, CreatedBy: "bob", Expires: now.Add(time.Hour)})
+	if bulb.ID != 1 || lab.ID != 2 || bulb.Created.IsZero() {
+		t.Fatalf("added %+v and %+v, want IDs 1 and 2 and a creation time", bulb, lab)
+	}
+
+	// Another process, such as the server, sees the silences and who ends
+	// one.
+	other, err := New(s.devicesFile, s.stateFile)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if ok, err := other.RemoveSilence(lab.ID, "carol"); !ok || err != nil {
+		t.Fatalf("RemoveSilence = %v, %v", ok, err)
+	}
+	if ok, _ := other.RemoveSilence(lab.ID, "carol"); ok {
+		t.Error("removed a silence already ended")
+	}
+
+	// Set the file's time apart, as a second may not have passed.
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(s.silencesFile.path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.GetSilences(false); len(got) != 1 || got[0].ID != bulb.ID {
+		t.Errorf("GetSilences(false) = %+v, want only the bulb's", got)
+	}
+	all := s.GetSilences(true)
+	if len(all) != 2 || all[0].ID != lab.ID || all[0].RemovedBy != "carol" || all[0].Removed.IsZero() {
+		t.Errorf("GetSilences(true) = %+v, want the lab's first, ended by carol", all)
+	}
+}
@@ -4,8 +4,10 @@ package storage
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
@@ -16,9 +18,56 @@ import (
 type Storage struct {
 	devicesFile string
 	stateFile   string
+	eventsFile  string
 	mu          sync.RWMutex
 	devices     map[string]*types.Device
 	state       *types.ScanState
+	events      []types.Event
+	nextEventID int64
+
+	sightingsFile string
+	sightings     map[string][]types.Sighting
+
+	changesFile string
+	changes     map[string][]types.Change
+
+	// silencesFile notices silences another process adds, as do the other
+	// files the command line may write beside a running server.
+	silencesFile  jsonFile[[]types.Silence]
+	silences      []types.Silence
+	nextSilenceID int64
+
+	escalationsFile  jsonFile[[]types.Escalation]
+	escalations      []types.Escalation
+	nextEscalationID int64
+
+	// findingsFile holds the latest deep scan of each device by IP.
+	findingsFile jsonFile[map[string]types.DeepScan]
+	deepScans    map[string]types.DeepScan
+
+	// certsFile holds the TLS certificates each device served by IP.
+	certsFile jsonFile[map[string][]types.Certificate]
+	certs     map[string][]types.Certificate
+
+	// fingerprintsFile holds the last fingerprint of each device by IP.
+	fingerprintsFile jsonFile[map[string]types.Fingerprint]
+	fingerprints     map[string]types.Fingerprint
+
+	// pending reports whether a newly found device waits for approval, or
+	// is nil to approve every device as it is found. See SetApproval.
+	pending func(*types.Device) bool
+
+	// offlineAfter returns how long a device may be missing before it is
+	// reported offline, for one with no grace period of its own, or is nil
+	// to report it at once. See SetOfflineAfter.
+	offlineAfter func(*types.Device) time.Duration
+
+	// people are the people whose presence scans follow. See SetPeople.
+	people []types.Person
+
+	// restricted are the networks and groups only some devices may be on.
+	// See SetRestricted.
+	restricted []types.Restricted
 }
 
 // New creates a new Storage instance
@@ -26,10 +75,27 @@ func New(devicesFile, stateFile string) (*Storage, error) {
 	s := &Storage{
 		devicesFile: devicesFile,
 		stateFile:   stateFile,
-		devices:     make(map[string]*types.Device),
+		// The event log lives beside the device list rather than being
+		// configured separately: it is only meaningful alongside it.
+		eventsFile:       filepath.Join(filepath.Dir(devicesFile), "events.json"),
+		sightingsFile:    filepath.Join(filepath.Dir(devicesFile), "sightings.json"),
+		changesFile:      filepath.Join(filepath.Dir(devicesFile), "changes.json"),
+		silencesFile:     jsonFile[[]types.Silence]{path: filepath.Join(filepath.Dir(devicesFile), "silences.json"), what: "silences"},
+		escalationsFile:  jsonFile[[]types.Escalation]{path: filepath.Join(filepath.Dir(devicesFile), "escalations.json"), what: "escalations"},
+		findingsFile:     jsonFile[map[string]types.DeepScan]{path: filepath.Join(filepath.Dir(devicesFile), "findings.json"), what: "findings"},
+		certsFile:        jsonFile[map[string][]types.Certificate]{path: filepath.Join(filepath.Dir(devicesFile), "certs.json"), what: "certificates"},
+		fingerprintsFile: jsonFile[map[string]types.Fingerprint]{path: filepath.Join(filepath.Dir(devicesFile), "fingerprints.json"), what: "fingerprints"},
+		devices:          make(map[string]*types.Device),
+		sightings:        make(map[string][]types.Sighting),
+		changes:          make(map[string][]types.Change),
+		deepScans:        make(map[string]types.DeepScan),
+		certs:            make(map[string][]types.Certificate),
+		fingerprints:     make(map[string]types.Fingerprint),
 		state: &types.ScanState{
 			LastScan:     make(map[string]time.Time),
 			LastDuration: make(map[string]float64),
+			LastMerge:    make(map[string]time.Time),
+			Presence:     make(map[string]types.Presence),
 		},
 	}
 
@@ -47,7 +113,32 @@ func New(devicesFile, stateFile string) (*Storage, error) {
 	if err := s.loadState(); err != nil && !os.IsNotExist(err) {
 		return nil, fmt.Errorf("could not read the scan history at %s: %w", stateFile, err)
 	}
+	if err := s.loadEvents(); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("could not read the event log at %s: %w", s.eventsFile, err)
+	}
+	if err := s.loadSightings(); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("could not read the sighting history at %s: %w", s.sightingsFile, err)
+	}
+	if err := s.loadChanges(); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("could not read the change history at %s: %w", s.changesFile, err)
+	}
+	if err := s.loadSilences(); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("could not read the alert silences at %s: %w", s.silencesFile.path, err)
+	}
+	if err := s.loadEscalations(); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("could not read the alert escalations at %s: %w", s.escalationsFile.path, err)
+	}
+	if err := s.loadFindings(); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("could not read the deep scan findings at %s: %w", s.findingsFile.path, err)
+	}
+	if err := s.loadCerts(); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("could not read the TLS certificates at %s: %w", s.certsFile.path, err)
+	}
+	if err := s.loadFingerprints(); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("could not read the device fingerprints at %s: %w", s.fingerprintsFile.path, err)
+	}
 
+	slog.Debug("loaded data", "dir", filepath.Dir(devicesFile), "devices", len(s.devices), "events", len(s.events))
 	return s, nil
 }
 
@@ -88,6 +179,12 @@ func (s *Storage) loadState() error {
 	if s.state.LastDuration == nil {
 		s.state.LastDuration = make(map[string]float64)
 	}
+	if s.state.LastMerge == nil {
+		s.state.LastMerge = make(map[string]time.Time)
+	}
+	if s.state.Presence == nil {
+		s.state.Presence = make(map[string]types.Presence)
+	}
 	return nil
 }
 
@@ -144,6 +241,7 @@ func atomicWrite(path string, data []byte) error {
 	if err := os.Rename(tempPath, path); err != nil {
 		return fmt.Errorf("failed to rename temp file: %w", err)
 	}
+	slog.Debug("saved", "file", path, "bytes", len(data))
 
 	tempPath = "" // Prevent cleanup of renamed file
 	return nil
@@ -174,6 +272,7 @@ func (s *Storage) UpdateDevice(device *types.Device) error {
 	defer s.mu.Unlock()
 
 	// Preserve existing user data if device exists
+	noted := false
 	if existing, ok := s.devices[device.IP]; ok {
 		if device.Label == "" {
 			device.Label = existing.Label
@@ -184,17 +283,28 @@ func (s *Storage) UpdateDevice(device *types.Device) error {
 		if device.Group == "" {
 			device.Group = existing.Group
 		}
+		if device.Type == "" {
+			device.Type = existing.Type
+		}
+		if device.Tags == nil {
+			device.Tags = existing.Tags
+		}
 		if device.FirstSeen.IsZero() {
 			device.FirstSeen = existing.FirstSeen
 		}
+		noted = s.noteChangesLocked(existing, device, time.Now())
 	}
 
 	s.devices[device.IP] = device
-	return s.saveDevices()
+	if err := s.saveDevices(); err != nil {
+		return err
+	}
+	return s.saveChangesIf(noted)
 }
 
-// UpdateDeviceFields updates specific fields of a device
-func (s *Storage) UpdateDeviceFields(ip string, label, notes, group *string) error {
+// UpdateDeviceFields updates specific fields of a device. A nil field is left
+// as it is.
+func (s *Storage) UpdateDeviceFields(ip string, label, notes, group, deviceType *string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -203,6 +313,7 @@ func (s *Storage) UpdateDeviceFields(ip string, label, notes, group *string) err
 		return fmt.Errorf("device not found: %s", ip)
 	}
 
+	before := *device
 	if label != nil {
 		device.Label = *label
 	}
@@ -212,8 +323,111 @@ func (s *Storage) UpdateDeviceFields(ip string, label, notes, group *string) err
 	if group != nil {
 		device.Group = *group
 	}
+	if deviceType != nil {
+		device.Type = *deviceType
+	}
+	noted := s.noteChangesLocked(&before, device, time.Now())
 
-	return s.saveDevices()
+	if err := s.saveDevices(); err != nil {
+		return err
+	}
+	return s.saveChangesIf(noted)
+}
+
+// UpdateDevices calls update on each device in ips and saves once at the end,
+// so changing a hundred devices is one write rather than a hundred. Addresses
+// with no device are skipped. It returns how many devices were updated.
+func (s *Storage) UpdateDevices(ips []string, update func(*types.Device)) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	n := 0
+	noted := false
+	for _, ip := range ips {
+		if d, ok := s.devices[ip]; ok {
+			before := *d
+			update(d)
+			if s.noteChangesLocked(&before, d, now) {
+				noted = true
+			}
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	if err := s.saveDevices(); err != nil {
+		return n, err
+	}
+	return n, s.saveChangesIf(noted)
+}
+
+// DeleteDevices removes every device in ips, skipping addresses with no
+// device, and returns how many were removed.
+func (s *Storage) DeleteDevices(ips []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	sightingsChanged, changesChanged, findingsChanged, certsChanged, fingerprintsChanged := false, false, false, false, false
+	s.refreshFindingsLocked()
+	s.refreshCertsLocked()
+	s.refreshFingerprintsLocked()
+	for _, ip := range ips {
+		if _, ok := s.devices[ip]; !ok {
+			continue
+		}
+		delete(s.devices, ip)
+		n++
+		if _, ok := s.sightings[ip]; ok {
+			delete(s.sightings, ip)
+			sightingsChanged = true
+		}
+		if _, ok := s.changes[ip]; ok {
+			delete(s.changes, ip)
+			changesChanged = true
+		}
+		if _, ok := s.deepScans[ip]; ok {
+			delete(s.deepScans, ip)
+			findingsChanged = true
+		}
+		if _, ok := s.certs[ip]; ok {
+			delete(s.certs, ip)
+			certsChanged = true
+		}
+		if _, ok := s.fingerprints[ip]; ok {
+			delete(s.fingerprints, ip)
+			fingerprintsChanged = true
+		}
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	if err := s.saveDevices(); err != nil {
+		return n, err
+	}
+	if sightingsChanged {
+		if err := s.saveSightings(); err != nil {
+			return n, err
+		}
+	}
+	if findingsChanged {
+		if err := s.findingsFile.save(s.deepScans); err != nil {
+			return n, err
+		}
+	}
+	if certsChanged {
+		if err := s.certsFile.save(s.certs); err != nil {
+			return n, err
+		}
+	}
+	if fingerprintsChanged {
+		if err := s.fingerprintsFile.save(s.fingerprints); err != nil {
+			return n, err
+		}
+	}
+	return n, s.saveChangesIf(changesChanged)
 }
 
 // DeleteDevice removes a device by IP
@@ -226,32 +440,202 @@ func (s *Storage) DeleteDevice(ip string) error {
 	}
 
 	delete(s.devices, ip)
-	return s.saveDevices()
+	if err := s.saveDevices(); err != nil {
+		return err
+	}
+	if _, ok := s.sightings[ip]; ok {
+		delete(s.sightings, ip)
+		if err := s.saveSightings(); err != nil {
+			return err
+		}
+	}
+	s.refreshFindingsLocked()
+	if _, ok := s.deepScans[ip]; ok {
+		delete(s.deepScans, ip)
+		if err := s.findingsFile.save(s.deepScans); err != nil {
+			return err
+		}
+	}
+	s.refreshCertsLocked()
+	if _, ok := s.certs[ip]; ok {
+		delete(s.certs, ip)
+		if err := s.certsFile.save(s.certs); err != nil {
+			return err
+		}
+	}
+	s.refreshFingerprintsLocked()
+	if _, ok := s.fingerprints[ip]; ok {
+		delete(s.fingerprints, ip)
+		if err := s.fingerprintsFile.save(s.fingerprints); err != nil {
+			return err
+		}
+	}
+	_, hadChanges := s.changes[ip]
+	delete(s.changes, ip)
+	return s.saveChangesIf(hadChanges)
 }
 
 // MergeDevices merges discovered devices with existing data
 func (s *Storage) MergeDevices(discovered []types.Device) error {
+	return s.MergeScan("", discovered)
+}
+
+// MergeScan merges the devices found by a scan of cidr with existing data,
+// recording events for devices that are new, have gone missing from that
+// network since its last scan, or have changed in ways that suggest spoofing,
+// and for people arriving home or leaving.
+//
+// An empty cidr merges without looking for missing devices.
+func (s *Storage) MergeScan(cidr string, discovered []types.Device) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	now := time.Now()
+	eventsBefore := s.nextEventID
+	// On the very first scan every device is new, and fifty "new device"
+	// notifications say nothing the device list does not.
+	firstScan := len(s.devices) == 0
+	found := make(map[string]bool, len(discovered))
+	for _, d := range discovered {
+		found[d.IP] = true
+	}
+	lastMerge := s.state.LastMerge[cidr]
+	if cidr != "" {
+		s.recordOfflineLocked(cidr, found, now)
+		s.closeSightingsLocked(cidr, found)
+		s.state.LastMerge[cidr] = now
+	}
+	s.recordSightingsLocked(discovered, now)
+
+	macs := s.macIndexLocked()
+	changesNoted := false
 	for _, d := range discovered {
 		if existing, ok := s.devices[d.IP]; ok {
 			// Update existing device, preserve user data
+			before := *existing
+			s.recordAnomaliesLocked(cidr, &d, &before, macs, now)
 			existing.MAC = d.MAC
 			existing.Hostname = d.Hostname
 			existing.Vendor = d.Vendor
 			existing.LastSeen = now
 			existing.ResponseTime = d.ResponseTime
+			// Tags come from a scan's sources, such as a Pi-hole group, and are
+			// only ever added: the user's own must survive the next scan.
+			for _, tag := range d.Tags {
+				existing.AddTag(tag)
+			}
+			// A device off Wi-Fi, or on a network no router reports on, keeps
+			// where it was last seen.
+			if d.Wireless != nil {
+				existing.Wireless = d.Wireless
+			}
+			if s.noteChangesLocked(&before, existing, now) {
+				changesNoted = true
+			}
+			s.recordIntrusionsLocked(cidr, existing, &before, lastMerge, now)
 		} else {
 			// New device
 			d.FirstSeen = now
 			d.LastSeen = now
 			s.devices[d.IP] = &d
+			// Unlike other news, a device where it must not be is worth
+			// telling even on the first scan.
+			s.recordIntrusionsLocked(cidr, &d, nil, lastMerge, now)
+			if firstScan {
+				continue
+			}
+			s.recordAnomaliesLocked(cidr, &d, nil, macs, now)
+
+			name := deviceName(&d)
+			message := fmt.Sprintf("New device %s (%s)", name, d.IP)
+			if s.pending != nil && s.pending(&d) {
+				d.Pending = true
+				message += " is waiting for approval"
+			}
+			s.addEventLocked(types.Event{
+				Type:    types.EventDeviceNew,
+				Time:    now,
+				IP:      d.IP,
+				Name:    name,
+				Network: cidr,
+				Message: message,
+			})
 		}
 	}
 
-	return s.saveDevices()
+	presenceChanged := s.recordPresenceLocked(now)
+
+	if err := s.saveDevices(); err != nil {
+		return err
+	}
+	if err := s.saveSightings(); err != nil {
+		return err
+	}
+	if err := s.saveChangesIf(changesNoted); err != nil {
+		return err
+	}
+	if cidr != "" || presenceChanged {
+		if err := s.saveState(); err != nil {
+			return err
+		}
+	}
+	if s.nextEventID != eventsBefore {
+		return s.saveEvents()
+	}
+	return nil
+}
+
+// MarkSeen records that the device at ip answered just now, outside a scan,
+// such as to a ping. responseTime is how long it took to answer in
+// milliseconds, or nil if that is not known. It returns false if there is no
+// device at ip. A person's device answering brings them home.
+func (s *Storage) MarkSeen(ip string, responseTime *float64) (bool, error) {
+	n, err := s.MarkSeenAll(map[string]*float64{ip: responseTime})
+	return n > 0, err
+}
+
+// MarkSeenAll is MarkSeen for several devices at once, each address with its
+// response time, saving once for them all. It returns how many of the
+// addresses have a device.
+func (s *Storage) MarkSeenAll(seen map[string]*float64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var marked []types.Device
+	for ip, responseTime := range seen {
+		d, ok := s.devices[ip]
+		if !ok {
+			continue
+		}
+		d.LastSeen = now
+		if responseTime != nil {
+			d.ResponseTime = responseTime
+		}
+		marked = append(marked, *d)
+	}
+	if len(marked) == 0 {
+		return 0, nil
+	}
+	s.recordSightingsLocked(marked, now)
+	eventsBefore := s.nextEventID
+	presenceChanged := s.recordPresenceLocked(now)
+
+	if err := s.saveDevices(); err != nil {
+		return len(marked), err
+	}
+	if err := s.saveSightings(); err != nil {
+		return len(marked), err
+	}
+	if presenceChanged {
+		if err := s.saveState(); err != nil {
+			return len(marked), err
+		}
+	}
+	if s.nextEventID != eventsBefore {
+		return len(marked), s.saveEvents()
+	}
+	return len(marked), nil
 }
 
 // GetLastScan returns the last scan time for a network
@@ -281,6 +665,19 @@ func (s *Storage) GetMostRecentScan() time.Time {
 	return latest
 }
 
+// ScannedNetworks returns the networks that have been scanned, in order.
+func (s *Storage) ScannedNetworks() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	networks := make([]string, 0, len(s.state.LastScan))
+	for n := range s.state.LastScan {
+		networks = append(networks, n)
+	}
+	sort.Strings(networks)
+	return networks
+}
+
 // SetLastScan updates the last scan time for a network
 func (s *Storage) SetLastScan(network string, t time.Time) error {
 	s.mu.Lock()
@@ -306,6 +703,9 @@ func (s *Storage) SetLastDuration(network string, seconds float64) error {
 	if s.state.LastDuration == nil {
 		s.state.LastDuration = make(map[string]float64)
 	}
+	if s.state.LastMerge == nil {
+		s.state.LastMerge = make(map[string]time.Time)
+	}
 	s.state.LastDuration[network] = seconds
 	return s.saveState()
 }
@@ -326,6 +726,9 @@ func (s *Storage) GetStats() types.DeviceStats {
 		} else {
 			stats.Offline++
 		}
+		if d.Pending {
+			stats.Pending++
+		}
 		if d.Group != "" {
 			stats.Groups[d.Group]++
 		}
@@ -4,6 +4,8 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
 )
 
 // newTestStorage returns storage backed by a throwaway directory.
@@ -62,6 +64,23 @@ func TestMostRecentScanPicksTheLatest(t *testing.T) {
 	}
 }
 
+func TestScannedNetworks(t *testing.T) {
+	s := newTestStorage(t)
+	if got := s.ScannedNetworks(); len(got) != 0 {
+		t.Errorf("ScannedNetworks() = %v before any scan", got)
+	}
+
+	for _, n := range []string{"192.168.1.0/24", "10.0.0.0/24"} {
+		if err := s.SetLastScan(n, time.Now()); err != nil {
+			t.Fatalf("SetLastScan: %v", err)
+		}
+	}
+	got := s.ScannedNetworks()
+	if len(got) != 2 || got[0] != "10.0.0.0/24" || got[1] != "192.168.1.0/24" {
+		t.Errorf("ScannedNetworks() = %v, want both networks in order", got)
+	}
+}
+
 func TestMostRecentScanSurvivesReload(t *testing.T) {
 	dir := t.TempDir()
 	devices := filepath.Join(dir, "devices.json")
@@ -88,3 +107,136 @@ func TestMostRecentScanSurvivesReload(t *testing.T) {
 		t.Errorf("after reload GetMostRecentScan() = %v, want %v", got, when)
 	}
 }
+
+func TestDeviceTypeSurvivesRediscovery(t *testing.T) {
+	s := newTestStorage(t)
+
+	if err := s.MergeDevices([]types.Device{{IP: "192.168.1.20", Hostname: "host-20"}}); err != nil {
+		t.Fatalf("MergeDevices: %v", err)
+	}
+	printer := "printer"
+	if err := s.UpdateDeviceFields("192.168.1.20", nil, nil, nil, &printer); err != nil {
+		t.Fatalf("UpdateDeviceFields: %v", err)
+	}
+
+	// A later scan reports the device again without any type of its own.
+	if err := s.MergeDevices([]types.Device{{IP: "192.168.1.20", Hostname: "host-20"}}); err != nil {
+		t.Fatalf("MergeDevices: %v", err)
+	}
+	if got := s.GetDevice("192.168.1.20").Type; got != "printer" {
+		t.Errorf("Type = %q after a rescan, want the type chosen by hand kept", got)
+	}
+}
+
+func TestRescanAddsTags(t *testing.T) {
+	s := newTestStorage(t)
+	if err := s.MergeDevices([]types.Device{{IP: "192.168.1.1", Tags: []string{"office"}}}); err != nil {
+		t.Fatalf("MergeDevices: %v", err)
+	}
+	// A source such as Pi-hole tags the device; the user's tag stays.
+	if err := s.MergeDevices([]types.Device{{IP: "192.168.1.1", Tags: []string{"pihole:kids"}}}); err != nil {
+		t.Fatalf("MergeDevices: %v", err)
+	}
+	if got := s.GetDevice("192.168.1.1").Tags; len(got) != 2 || got[1] != "pihole:kids" {
+		t.Errorf("Tags = %v, want office and pihole:kids", got)
+	}
+}
+
+func TestRescanKeepsWireless(t *testing.T) {
+	s := newTestStorage(t)
+	wifi := &types.Wireless{SSID: "home", Band: "5GHz", Signal: -58}
+	if err := s.MergeDevices([]types.Device{{IP: "192.168.1.5", Wireless: wifi}}); err != nil {
+		t.Fatalf("MergeDevices: %v", err)
+	}
+	// A scan no router reports on says nothing about Wi-Fi.
+	if err := s.MergeDevices([]types.Device{{IP: "192.168.1.5"}}); err != nil {
+		t.Fatalf("MergeDevices: %v", err)
+	}
+	if got := s.GetDevice("192.168.1.5").Wireless; got == nil || *got != *wifi {
+		t.Errorf("Wireless = %+v, want %+v kept", got, wifi)
+	}
+	moved := &types.Wireless{SSID: "home", Band: "2.4GHz", Signal: -71}
+	if err := s.MergeDevices([]types.Device{{IP: "192.168.1.5", Wireless: moved}}); err != nil {
+		t.Fatalf("MergeDevices: %v", err)
+	}
+	if got := s.GetDevice("192.168.1.5").Wireless; got == nil || *got != *moved {
+		t.Errorf("Wireless = %+v, want %+v", got, moved)
+	}
+}
+
+func TestUpdateDevicesSkipsUnknownAddresses(t *testing.T) {
+	s := newTestStorage(t)
+	if err := s.MergeDevices([]types.Device{{IP: "192.168.1.1"}, {IP: "192.168.1.2"}}); err != nil {
+		t.Fatalf("MergeDevices: %v", err)
+	}
+
+	n, err := s.UpdateDevices([]string{"192.168.1.1", "192.168.1.2", "192.168.1.99"}, func(d *types.Device) {
+		d.AddTag("office")
+	})
+	if err != nil {
+		t.Fatalf("UpdateDevices: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("updated %d devices, want 2", n)
+	}
+	if !s.GetDevice("192.168.1.2").HasTag("Office") {
+		t.Error("tags should match regardless of case")
+	}
+	if s.GetDevice("192.168.1.99") != nil {
+		t.Error("updating an unknown address should not create a device")
+	}
+}
+
+func TestTagsSurviveUpdateDevice(t *testing.T) {
+	s := newTestStorage(t)
+	if err := s.UpdateDevice(&types.Device{IP: "192.168.1.1", Tags: []string{"office"}}); err != nil {
+		t.Fatalf("UpdateDevice: %v", err)
+	}
+	if err := s.UpdateDevice(&types.Device{IP: "192.168.1.1", Label: "Printer"}); err != nil {
+		t.Fatalf("UpdateDevice: %v", err)
+	}
+	if got := s.GetDevice("192.168.1.1").Tags; len(got) != 1 || got[0] != "office" {
+		t.Errorf("Tags = %v, want the existing tags kept", got)
+	}
+}
+
+func TestDeleteDevices(t *testing.T) {
+	s := newTestStorage(t)
+	if err := s.MergeDevices([]types.Device{{IP: "192.168.1.1"}, {IP: "192.168.1.2"}, {IP: "192.168.1.3"}}); err != nil {
+		t.Fatalf("MergeDevices: %v", err)
+	}
+
+	n, err := s.DeleteDevices([]string{"192.168.1.1", "192.168.1.3", "192.168.1.99"})
+	if err != nil {
+		t.Fatalf("DeleteDevices: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("deleted %d devices, want 2", n)
+	}
+	if got := len(s.GetDevices()); got != 1 {
+		t.Errorf("%d devices left, want 1", got)
+	}
+}
+
+func TestMarkSeen(t *testing.T) {
+	s := newTestStorage(t)
+	scan(t, s, "192.168.1.1")
+	before := s.GetDevice("192.168.1.1").LastSeen
+
+	rtt := 2.5
+	ok, err := s.MarkSeen("192.168.1.1", &rtt)
+	if err != nil || !ok {
+		t.Fatalf("MarkSeen = %v, %v; want true, nil", ok, err)
+	}
+	d := s.GetDevice("192.168.1.1")
+	if !d.LastSeen.After(before) {
+		t.Errorf("LastSeen = %v, want it moved on from %v", d.LastSeen, before)
+	}
+	if d.ResponseTime == nil || *d.ResponseTime != rtt {
+		t.Errorf("ResponseTime = %v, want %v", d.ResponseTime, rtt)
+	}
+
+	if ok, err := s.MarkSeen("192.168.1.99", nil); err != nil || ok {
+		t.Errorf("MarkSeen of an unknown device = %v, %v; want false, nil", ok, err)
+	}
+}
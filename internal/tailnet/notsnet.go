@@ -0,0 +1,13 @@
+//go:build !tsnet
+
+package tailnet
+
+import "context"
+
+// Available reports whether this build can serve on the tailnet.
+const Available = false
+
+// Listen fails, as this build was made without tsnet.
+func Listen(ctx context.Context, opts Options) (*Node, error) {
+	return nil, ErrNotBuilt
+}
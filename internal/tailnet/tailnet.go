@@ -0,0 +1,75 @@
+// Package tailnet serves the dashboard as a node of its own on a Tailscale
+// tailnet, using tsnet, so that it can be reached from anywhere on the
+// tailnet without a port open on the LAN and with a certificate browsers
+// trust.
+//
+// tsnet brings in most of Tailscale, so it is only compiled into builds made
+// with the tsnet tag:
+//
+//	go build -tags tsnet ./cmd/orangutan
+//
+// Other builds refuse to serve on the tailnet with ErrNotBuilt.
+package tailnet
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ErrNotBuilt is returned by Listen in builds without the tsnet tag.
+var ErrNotBuilt = errors.New("this build cannot serve on the tailnet; build it with -tags tsnet (see the README)")
+
+// Options say how to join the tailnet.
+type Options struct {
+	// Hostname is the node's name on the tailnet, and so the first label of
+	// its MagicDNS name.
+	Hostname string
+	// AuthKey joins the tailnet without an interactive login. Empty means
+	// the first start prints a login URL instead.
+	AuthKey string
+	// Dir holds the node's keys and certificates, so it keeps its name and
+	// address across restarts.
+	Dir string
+	// HTTPS serves on port 443 with a certificate for the node's MagicDNS
+	// name, which needs HTTPS enabled for the tailnet. Otherwise it serves
+	// plain HTTP on port 80; WireGuard encrypts it either way.
+	HTTPS bool
+	// Logf receives the messages meant for the user, such as the login URL.
+	Logf func(format string, args ...any)
+}
+
+// Node is the dashboard's node on the tailnet.
+type Node struct {
+	// Listener accepts connections from the tailnet.
+	Listener net.Listener
+	// URL is where the tailnet reaches the dashboard, such as
+	// https://orangutan.example.ts.net.
+	URL string
+
+	close func() error
+}
+
+// Close stops listening and leaves the tailnet.
+func (n *Node) Close() error {
+	n.Listener.Close()
+	return n.close()
+}
+
+// ValidHostname checks that name can be a node's name on the tailnet: a
+// single DNS label.
+func ValidHostname(name string) error {
+	if name == "" || len(name) > 63 {
+		return fmt.Errorf("%q must be 1 to 63 characters", name)
+	}
+	if strings.HasPrefix(name, "-") || strings.HasSuffix(name, "-") {
+		return fmt.Errorf("%q must not start or end with a hyphen", name)
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+			return fmt.Errorf("%q may only hold letters, digits and hyphens", name)
+		}
+	}
+	return nil
+}
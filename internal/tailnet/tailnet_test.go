@@ -0,0 +1,23 @@
+package tailnet
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidHostname(t *testing.T) {
+	for name, want := range map[string]bool{
+		"orangutan":             true,
+		"lan-orangutan2":        true,
+		"":                      false,
+		"-orangutan":            false,
+		"orangutan-":            false,
+		"lan.orangutan":         false,
+		"lan_orangutan":         false,
+		strings.Repeat("a", 64): false,
+	} {
+		if got := ValidHostname(name) == nil; got != want {
+			t.Errorf("ValidHostname(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
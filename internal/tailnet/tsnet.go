@@ -0,0 +1,54 @@
+//go:build tsnet
+
+package tailnet
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"tailscale.com/tsnet"
+)
+
+// Available reports whether this build can serve on the tailnet.
+const Available = true
+
+// Listen joins the tailnet as opts.Hostname and returns the node, listening
+// on port 443 or 80 as opts.HTTPS says. It waits until the node is up, which
+// on the first start without an auth key means until someone has followed
+// the login URL passed to opts.Logf, or ctx ends.
+func Listen(ctx context.Context, opts Options) (*Node, error) {
+	s := &tsnet.Server{
+		Hostname: opts.Hostname,
+		AuthKey:  opts.AuthKey,
+		Dir:      opts.Dir,
+		// tsnet's own logging is a running commentary meant for Tailscale's
+		// developers. The login URL and the like still reach the user.
+		Logf:     func(string, ...any) {},
+		UserLogf: opts.Logf,
+	}
+	status, err := s.Up(ctx)
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("joining the tailnet: %w", err)
+	}
+	name := strings.TrimSuffix(status.Self.DNSName, ".")
+	if name == "" {
+		name = opts.Hostname
+	}
+
+	var ln net.Listener
+	scheme := "http"
+	if opts.HTTPS {
+		scheme = "https"
+		ln, err = s.ListenTLS("tcp", ":443")
+	} else {
+		ln, err = s.Listen("tcp", ":80")
+	}
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("listening on the tailnet: %w", err)
+	}
+	return &Node{Listener: ln, URL: scheme + "://" + name, close: s.Close}, nil
+}
@@ -0,0 +1,240 @@
+// Package tlscert keeps track of the TLS certificates devices on the network
+// serve, so that one about to expire is reported in time to renew it. Self
+// hosted services, NAS boxes and hypervisors such as Proxmox or a UniFi
+// controller each have their own, and nothing else warns before the browser
+// refuses to connect.
+//
+// Certificates are fetched with a TLS handshake and never verified: most
+// devices sign their own, and what matters here is when each runs out. The
+// ports tried are the usual HTTPS ones and any other a deep scan found
+// speaking TLS.
+package tlscert
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// DefaultPorts are the ports tried on every device: HTTPS, and the ports
+// Proxmox, the UniFi controller, Synology and Portainer serve theirs on.
+var DefaultPorts = []int{443, 8443, 8006, 5001, 9443}
+
+const (
+	// DefaultInterval is how often certificates are checked.
+	DefaultInterval = 12 * time.Hour
+	// DefaultWarn is how long before a certificate expires it is reported.
+	DefaultWarn = 14 * 24 * time.Hour
+)
+
+// dialTimeout bounds connecting to one port and the handshake after it.
+const dialTimeout = 5 * time.Second
+
+// Store is where the devices come from and the certificates go; the storage
+// is one.
+type Store interface {
+	GetDevices() map[string]*types.Device
+	GetDeepScans() map[string]types.DeepScan
+	SetCertificates(ip string, certs []types.Certificate, warn time.Duration) (bool, error)
+}
+
+// Checker fetches the certificates devices serve.
+type Checker struct {
+	// Ports are tried on every device; DefaultPorts if empty.
+	Ports []int
+	// Warn is how long before a certificate expires it is reported.
+	Warn time.Duration
+	// Interval is how long Run waits between rounds.
+	Interval time.Duration
+}
+
+// Run checks the certificates of every online device every Interval until
+// ctx is done.
+func (c *Checker) Run(ctx context.Context, store Store) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		if n, err := c.CheckAll(ctx, store, nil); err != nil {
+			slog.Warn("cannot record certificates", "error", err)
+		} else {
+			slog.Debug("checked certificates", "devices", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckAll fetches the certificates of the devices at ips, or of every
+// online device when ips is empty, and records them. It returns how many
+// devices it checked.
+func (c *Checker) CheckAll(ctx context.Context, store Store, ips []string) (int, error) {
+	devices := store.GetDevices()
+	if len(ips) == 0 {
+		for ip, d := range devices {
+			if d.IsOnline() {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	scans := store.GetDeepScans()
+
+	type result struct {
+		ip    string
+		certs []types.Certificate
+	}
+	results := make(chan result, len(ips))
+	var wg sync.WaitGroup
+	for _, ip := range ips {
+		var scan *types.DeepScan
+		if s, ok := scans[ip]; ok {
+			scan = &s
+		}
+		wg.Add(1)
+		go func(ip string, ports []int) {
+			defer wg.Done()
+			results <- result{ip, c.Check(ctx, ip, ports)}
+		}(ip, Ports(c.Ports, scan))
+	}
+	wg.Wait()
+	close(results)
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for r := range results {
+		if _, err := store.SetCertificates(r.ip, r.certs, c.Warn); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Check fetches the certificate ip serves on each of ports, skipping those
+// that do not answer with one.
+func (c *Checker) Check(ctx context.Context, ip string, ports []int) []types.Certificate {
+	var certs []types.Certificate
+	for _, port := range ports {
+		cert, err := c.Fetch(ctx, ip, port)
+		if err != nil {
+			slog.Debug("no certificate", "ip", ip, "port", port, "error", err)
+			continue
+		}
+		certs = append(certs, cert)
+	}
+	return certs
+}
+
+// Fetch fetches the certificate ip serves on port.
+func (c *Checker) Fetch(ctx context.Context, ip string, port int) (types.Certificate, error) {
+	ctx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+	conn, err := dialTLS(ctx, net.JoinHostPort(ip, strconv.Itoa(port)))
+	if err != nil {
+		return types.Certificate{}, err
+	}
+	defer conn.Close()
+	peer := conn.ConnectionState().PeerCertificates
+	if len(peer) == 0 {
+		return types.Certificate{}, fmt.Errorf("%s:%d sent no certificate", ip, port)
+	}
+	leaf := peer[0]
+	sum := sha256.Sum256(leaf.Raw)
+	return types.Certificate{
+		IP:          ip,
+		Port:        port,
+		Subject:     leaf.Subject.CommonName,
+		Names:       leaf.DNSNames,
+		Issuer:      issuerName(leaf.Issuer.CommonName, leaf.Issuer.Organization),
+		SelfSigned:  leaf.Subject.String() == leaf.Issuer.String() && leaf.CheckSignatureFrom(leaf) == nil,
+		NotBefore:   leaf.NotBefore,
+		NotAfter:    leaf.NotAfter,
+		Fingerprint: hex.EncodeToString(sum[:]),
+		Checked:     time.Now(),
+	}, nil
+}
+
+// dialTLS connects to addr and completes a TLS handshake, accepting whatever
+// certificate it is shown.
+func dialTLS(ctx context.Context, addr string) (*tls.Conn, error) {
+	d := &tls.Dialer{Config: &tls.Config{InsecureSkipVerify: true}}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*tls.Conn), nil
+}
+
+// issuerName names a certificate's issuer by its common name, or its
+// organization when it has none.
+func issuerName(cn string, org []string) string {
+	if cn != "" || len(org) == 0 {
+		return cn
+	}
+	return org[0]
+}
+
+// Ports returns the ports to try on a device: ports, or DefaultPorts when it
+// is empty, and those its latest deep scan, scan, found speaking TLS. scan
+// is nil for a device never deep scanned.
+func Ports(ports []int, scan *types.DeepScan) []int {
+	if len(ports) == 0 {
+		ports = DefaultPorts
+	}
+	result := slices.Clone(ports)
+	if scan != nil {
+		for _, s := range scan.Services {
+			if s.Protocol == "tcp" && speaksTLS(s.Name) && !slices.Contains(result, s.Port) {
+				result = append(result, s.Port)
+			}
+		}
+	}
+	sort.Ints(result)
+	return result
+}
+
+// speaksTLS reports whether nmap's name for a service, such as "https" or
+// "ssl/imap", says it is wrapped in TLS.
+func speaksTLS(name string) bool {
+	name = strings.ToLower(name)
+	return strings.Contains(name, "https") || strings.HasPrefix(name, "ssl")
+}
+
+// ParsePorts parses the ports in list, such as "443" and "8443".
+func ParsePorts(list []string) ([]int, error) {
+	var ports []int
+	for _, s := range list {
+		port, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("%q is not a port", s)
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+// FormatPorts writes ports as ParsePorts reads them back from a config
+// file, as in "443, 8443".
+func FormatPorts(ports []int) string {
+	list := make([]string, len(ports))
+	for i, p := range ports {
+		list[i] = strconv.Itoa(p)
+	}
+	return strings.Join(list, ", ")
+}
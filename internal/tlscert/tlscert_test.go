@@ -0,0 +1,101 @@
+package tlscert
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// fakeStore holds devices and records the certificates it is given.
+type fakeStore struct {
+	devices map[string]*types.Device
+	scans   map[string]types.DeepScan
+	certs   map[string][]types.Certificate
+}
+
+func (s *fakeStore) GetDevices() map[string]*types.Device    { return s.devices }
+func (s *fakeStore) GetDeepScans() map[string]types.DeepScan { return s.scans }
+func (s *fakeStore) SetCertificates(ip string, certs []types.Certificate, warn time.Duration) (bool, error) {
+	s.certs[ip] = certs
+	return true, nil
+}
+
+func TestCheckAll(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	_, p, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	port, _ := strconv.Atoi(p)
+
+	// A port nothing listens on.
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	store := &fakeStore{
+		devices: map[string]*types.Device{
+			"127.0.0.1": {IP: "127.0.0.1", LastSeen: time.Now()},
+			"127.0.0.2": {IP: "127.0.0.2", LastSeen: time.Now().Add(-48 * time.Hour)},
+		},
+		scans: map[string]types.DeepScan{
+			"127.0.0.1": {Services: []types.Service{{Port: port, Protocol: "tcp", Name: "https"}}},
+		},
+		certs: make(map[string][]types.Certificate),
+	}
+	c := &Checker{Ports: []int{closedPort}, Warn: DefaultWarn}
+	n, err := c.CheckAll(context.Background(), store, nil)
+	if err != nil || n != 1 {
+		t.Fatalf("CheckAll = %d, %v; want the one online device", n, err)
+	}
+	certs := store.certs["127.0.0.1"]
+	if len(certs) != 1 {
+		t.Fatalf("certs = %+v, want the one on the port the deep scan found", certs)
+	}
+	want := srv.Certificate()
+	if got := certs[0]; got.Port != port || !got.NotAfter.Equal(want.NotAfter) || got.Issuer != "Acme Co" || !got.SelfSigned || len(got.Fingerprint) != 64 {
+		t.Errorf("cert = %+v, want the test server's", got)
+	}
+}
+
+func TestPorts(t *testing.T) {
+	scan := &types.DeepScan{Services: []types.Service{
+		{Port: 22, Protocol: "tcp", Name: "ssh"},
+		{Port: 443, Protocol: "tcp", Name: "https"},
+		{Port: 993, Protocol: "tcp", Name: "ssl/imap"},
+		{Port: 8443, Protocol: "tcp", Name: "https-alt"},
+	}}
+	if got := Ports(nil, scan); !slices.Equal(got, []int{443, 993, 5001, 8006, 8443, 9443}) {
+		t.Errorf("Ports = %v", got)
+	}
+	if got := Ports([]int{8443}, nil); !slices.Equal(got, []int{8443}) {
+		t.Errorf("Ports = %v, want only those given", got)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	now := time.Now()
+	for _, tt := range []struct {
+		left time.Duration
+		want string
+	}{
+		{30 * 24 * time.Hour, ""},
+		{14 * 24 * time.Hour, types.CertExpiring},
+		{time.Minute, types.CertExpiring},
+		{0, types.CertExpired},
+		{-time.Hour, types.CertExpired},
+	} {
+		c := types.Certificate{NotAfter: now.Add(tt.left)}
+		if got := c.Expiry(now, DefaultWarn); got != tt.want {
+			t.Errorf("Expiry with %v left = %q, want %q", tt.left, got, tt.want)
+		}
+	}
+}
@@ -1,20 +1,65 @@
 // Package types defines the core domain types for LAN Orangutan
 package types
 
-import "time"
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+)
 
 // Device represents a discovered network device
 type Device struct {
-	IP           string    `json:"ip"`
-	MAC          string    `json:"mac"`
-	Hostname     string    `json:"hostname"`
-	Vendor       string    `json:"vendor"`
-	Label        string    `json:"label"`
-	Notes        string    `json:"notes"`
-	Group        string    `json:"group"`
+	IP       string `json:"ip"`
+	MAC      string `json:"mac"`
+	Hostname string `json:"hostname"`
+	Vendor   string `json:"vendor"`
+	Label    string `json:"label"`
+	Notes    string `json:"notes"`
+	Group    string `json:"group"`
+	// Type is a device type chosen by hand, such as "printer". Empty means
+	// the type shown is detected from the hostname and vendor instead.
+	Type string `json:"type,omitempty"`
+	// Tags are free-form labels for slicing the inventory in ways groups do
+	// not, since a device has one group but can carry any number of tags.
+	Tags         []string  `json:"tags,omitempty"`
 	FirstSeen    time.Time `json:"first_seen"`
 	LastSeen     time.Time `json:"last_seen"`
 	ResponseTime *float64  `json:"response_time,omitempty"`
+	// Wireless is how the device was last seen on Wi-Fi, for those a router
+	// reports. It is kept after the device leaves, with the time it was seen.
+	Wireless *Wireless `json:"wireless,omitempty"`
+	// Pending is set on a device first found after the inventory was
+	// started, until someone approves it as one they know. Devices from
+	// before approval was asked for count as approved.
+	Pending bool `json:"pending,omitempty"`
+	// OfflineAfter is how many seconds the device may be missing from scans
+	// before it is reported offline, in place of its group's or the default
+	// grace period. 0 means it has none of its own.
+	OfflineAfter int `json:"offline_after,omitempty"`
+	// Watch puts the device on the watchlist of those expected always to be
+	// online, such as the router or the NAS: it is pinged between scans and
+	// reported offline as soon as it stops answering.
+	Watch bool `json:"watch,omitempty"`
+}
+
+// Wireless is a device's association with a Wi-Fi access point, as the
+// access point reports it.
+type Wireless struct {
+	SSID string `json:"ssid"`
+	// Band is "2.4GHz", "5GHz" or "6GHz".
+	Band    string `json:"band,omitempty"`
+	Channel int    `json:"channel,omitempty"`
+	// Signal is how strongly the access point hears the device, in dBm:
+	// -50 is excellent, -80 barely usable.
+	Signal int `json:"signal"`
+	// AccessPoint names the router and its radio interface, as in
+	// "attic/phy1-ap0".
+	AccessPoint string    `json:"access_point,omitempty"`
+	Seen        time.Time `json:"seen"`
 }
 
 // IsOnline returns true if the device was seen within the last hour
@@ -27,6 +72,52 @@ func (d *Device) IsRecent() bool {
 	return time.Since(d.LastSeen) < 5*time.Minute
 }
 
+// HasTag reports whether the device carries tag, ignoring case.
+func (d *Device) HasTag(tag string) bool {
+	for _, t := range d.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddTag adds tag unless the device already has it, and reports whether it
+// was added.
+func (d *Device) AddTag(tag string) bool {
+	tag = strings.TrimSpace(tag)
+	if tag == "" || d.HasTag(tag) {
+		return false
+	}
+	d.Tags = append(d.Tags, tag)
+	return true
+}
+
+// RemoveTag removes tag, ignoring case, and reports whether it was there.
+func (d *Device) RemoveTag(tag string) bool {
+	for i, t := range d.Tags {
+		if strings.EqualFold(t, strings.TrimSpace(tag)) {
+			d.Tags = append(d.Tags[:i:i], d.Tags[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// LessIP orders IP addresses numerically, so that .9 comes before .10, with
+// IPv4 before IPv6 and anything that is not an address last, by its text.
+func LessIP(a, b string) bool {
+	x, errA := netip.ParseAddr(a)
+	y, errB := netip.ParseAddr(b)
+	switch {
+	case errA != nil && errB != nil:
+		return a < b
+	case errA != nil || errB != nil:
+		return errB != nil
+	}
+	return x.Less(y)
+}
+
 // Network represents a detected network interface
 type Network struct {
 	CIDR         string `json:"cidr"`
@@ -43,6 +134,82 @@ type ScanState struct {
 	// LastDuration records how long the previous scan of each network took,
 	// in seconds, so the UI can estimate progress for subsequent scans.
 	LastDuration map[string]float64 `json:"last_duration,omitempty"`
+	// LastMerge is the time each network's latest scan stamped on the
+	// devices it found, which tells the next scan which devices were present
+	// last time even if something has seen one of them since.
+	LastMerge map[string]time.Time `json:"last_merge,omitempty"`
+	// Presence is whether each person was home at the last scan, by name,
+	// so an arrival or departure is reported once however many processes
+	// scan.
+	Presence map[string]Presence `json:"presence,omitempty"`
+}
+
+// Person is someone whose devices, given by MAC, say whether they are home.
+type Person struct {
+	Name string
+	MACs []string
+	// AwayAfter is how long none of their devices may have been seen before
+	// they count as away, on top of the scans that miss them, as a phone
+	// asleep on Wi-Fi stops answering for a while.
+	AwayAfter time.Duration
+}
+
+// Restricted is a network or group only some devices may be on, such as the
+// VLAN of the cameras.
+type Restricted struct {
+	Name string
+	// Networks, in CIDR notation, and Groups are what it covers: a device
+	// on one of the networks, or in one of the groups or tagged with it.
+	Networks []string
+	Groups   []string
+	// Allowed are the devices allowed there, by MAC, or by address for a
+	// network scanned from elsewhere, where no MACs are seen.
+	Allowed []string
+}
+
+// Covers reports whether d is somewhere r covers.
+func (r Restricted) Covers(d *Device) bool {
+	for _, g := range r.Groups {
+		if strings.EqualFold(d.Group, g) || d.HasTag(g) {
+			return true
+		}
+	}
+	ip := net.ParseIP(d.IP)
+	for _, cidr := range r.Networks {
+		if _, ipNet, err := net.ParseCIDR(cidr); err == nil && ip != nil && ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows reports whether d is one of the devices allowed where r covers.
+func (r Restricted) Allows(d *Device) bool {
+	mac, macErr := net.ParseMAC(d.MAC)
+	for _, allowed := range r.Allowed {
+		if hw, err := net.ParseMAC(allowed); err == nil {
+			if macErr == nil && bytes.Equal(hw, mac) {
+				return true
+			}
+		} else if allowed == d.IP {
+			return true
+		}
+	}
+	return false
+}
+
+// Presence is whether a person is home.
+type Presence struct {
+	Name string `json:"name"`
+	Home bool   `json:"home"`
+	// Since is when they arrived or left, as the scans saw it. It is zero
+	// while nothing has been seen to change since they were first looked
+	// for.
+	Since time.Time `json:"since,omitempty"`
+	// IP and Device are the device of theirs seen last, and LastSeen when.
+	IP       string    `json:"ip,omitempty"`
+	Device   string    `json:"device,omitempty"`
+	LastSeen time.Time `json:"last_seen,omitempty"`
 }
 
 // ScanResult represents the outcome of a network scan
@@ -73,6 +240,28 @@ type TailscaleStatus struct {
 	SelfHostname string `json:"self_hostname"`
 	PeerCount    int    `json:"peer_count"`
 	ExitNode     string `json:"exit_node,omitempty"`
+	// Error says why Tailscale could not be asked for its status, when it
+	// is installed but could not.
+	Error string `json:"error,omitempty"`
+}
+
+// TailscalePeer is one node of the tailnet, as Tailscale reports it.
+type TailscalePeer struct {
+	Name    string   `json:"name"`
+	DNSName string   `json:"dns_name"`
+	IP      string   `json:"ip"`
+	IPs     []string `json:"ips"`
+	OS      string   `json:"os"`
+	Online  bool     `json:"online"`
+	// LastSeen is when the peer was last connected. Tailscale leaves it unset
+	// for peers that are online now.
+	LastSeen time.Time `json:"last_seen"`
+	ExitNode bool      `json:"exit_node"`
+	Self     bool      `json:"self"`
+	// Tags are the ACL tags the peer is owned by, such as tag:server, and
+	// Routes the subnets it routes to the tailnet.
+	Tags   []string `json:"tags,omitempty"`
+	Routes []string `json:"routes,omitempty"`
 }
 
 // StatusLabel describes the Tailscale connection in words suitable for display.
@@ -111,8 +300,330 @@ type APIResponse struct {
 
 // DeviceStats holds device statistics for the dashboard
 type DeviceStats struct {
-	Total   int            `json:"total"`
-	Online  int            `json:"online"`
-	Offline int            `json:"offline"`
+	Total   int `json:"total"`
+	Online  int `json:"online"`
+	Offline int `json:"offline"`
+	// Pending counts the devices waiting to be approved.
+	Pending int            `json:"pending"`
 	Groups  map[string]int `json:"groups"`
 }
+
+// Sighting is a stretch of time during which a device kept turning up in
+// scans. A device seen by a single scan has a sighting with Start equal to End.
+type Sighting struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Closed is set once a scan of the device's network has missed it, so
+	// the next sighting starts a new stretch however soon it comes.
+	Closed bool `json:"closed,omitempty"`
+}
+
+// Change is an edit to one of a device's details, made by a user or noticed
+// by a scan, such as a new hostname.
+type Change struct {
+	Time  time.Time `json:"time"`
+	Field string    `json:"field"`
+	Old   string    `json:"old"`
+	New   string    `json:"new"`
+}
+
+// Event types recorded in the event log.
+const (
+	EventDeviceNew     = "device_new"
+	EventDeviceOffline = "device_offline"
+	EventScanFailed    = "scan_failed"
+	// EventDeviceAnomaly is a device doing what devices do not do on their
+	// own, which may be spoofing or a mistake in the network's setup. Its
+	// Detail is one of the Anomaly kinds.
+	EventDeviceAnomaly = "device_anomaly"
+	// EventPersonArrived and EventPersonLeft are a person coming home and
+	// leaving. Their Name is the person's, and IP the device that was seen.
+	EventPersonArrived = "person_arrived"
+	EventPersonLeft    = "person_left"
+	// EventCertExpiring is a device's TLS certificate about to expire, or
+	// expired. Its Detail is one of the CertExpiry stages.
+	EventCertExpiring = "cert_expiring"
+	// EventIntrusion is a device turning up on a restricted network, or in
+	// a restricted group, that is not on its list of allowed devices. Its
+	// Detail is the name of the restricted zone.
+	EventIntrusion = "intrusion"
+)
+
+// CertExpiry stages, the Detail of a cert_expiring event.
+const (
+	// CertExpiring is a certificate that expires within the warning
+	// period, and CertExpired one that already has.
+	CertExpiring = "expiring"
+	CertExpired  = "expired"
+)
+
+// Anomaly kinds, the Detail of a device_anomaly event.
+const (
+	// AnomalyMAC is an address answering with a different MAC.
+	AnomalyMAC = "mac"
+	// AnomalyHostname and AnomalyVendor are a known MAC answering with a
+	// different hostname or vendor.
+	AnomalyHostname = "hostname"
+	AnomalyVendor   = "vendor"
+	// AnomalySubnet is a known MAC leaving one network for another.
+	AnomalySubnet = "subnet"
+	// AnomalyFingerprint is what answers at an address looking like
+	// another device: its vendor, open ports, hostname and mDNS services
+	// changed too much together for one device to have changed them.
+	AnomalyFingerprint = "fingerprint"
+)
+
+// Silence stops alerts about some devices for a while, as someone asked, such
+// as a bulb that keeps dropping off. Events are still recorded.
+type Silence struct {
+	ID int64 `json:"id"`
+	// Devices, by address, MAC, label or hostname, Groups and Networks, in
+	// CIDR notation, are what it covers: an event about any of them is not
+	// alerted about. All empty covers everything.
+	Devices  []string `json:"devices,omitempty"`
+	Groups   []string `json:"groups,omitempty"`
+	Networks []string `json:"networks,omitempty"`
+	Reason   string   `json:"reason,omitempty"`
+	// CreatedBy is who asked for it and Created when; it ends by itself at
+	// Expires.
+	CreatedBy string    `json:"created_by"`
+	Created   time.Time `json:"created"`
+	Expires   time.Time `json:"expires"`
+	// RemovedBy and Removed record it being ended early. Silences are kept
+	// once they end, as a record of who muted what.
+	RemovedBy string    `json:"removed_by,omitempty"`
+	Removed   time.Time `json:"removed,omitempty"`
+}
+
+// Active reports whether the silence is in force at now.
+func (s Silence) Active(now time.Time) bool {
+	return s.Removed.IsZero() && now.Before(s.Expires)
+}
+
+// Escalation is an alert that goes to more people, a step at a time, until
+// someone acknowledges it, as an escalation policy says: a phone at once,
+// then email after half an hour, then someone else after two.
+type Escalation struct {
+	ID int64 `json:"id"`
+	// Policy is the escalation policy it follows and Rule the alert rule
+	// that started it, which words each step.
+	Policy string `json:"policy"`
+	Rule   string `json:"rule"`
+	Event  Event  `json:"event"`
+	// Started is when the first step was due; the policy's delays count
+	// from it. Steps is how many of its steps have been sent.
+	Started time.Time `json:"started"`
+	Steps   int       `json:"steps"`
+	// AcknowledgedBy and Acknowledged record someone taking it on, which
+	// stops it.
+	AcknowledgedBy string    `json:"acknowledged_by,omitempty"`
+	Acknowledged   time.Time `json:"acknowledged,omitempty"`
+	// Resolved is when it stopped by itself, as when a device reported
+	// offline came back before anyone acknowledged it.
+	Resolved time.Time `json:"resolved,omitempty"`
+}
+
+// Open reports whether the escalation is still going: neither acknowledged
+// nor resolved.
+func (e Escalation) Open() bool {
+	return e.Acknowledged.IsZero() && e.Resolved.IsZero()
+}
+
+// DeepScan is what the latest deep scan of a device found: the services it
+// runs and what nmap's scripts found wrong with them. It also holds the
+// findings of checks run apart from it; a device checked only that way has
+// one with no Time.
+type DeepScan struct {
+	IP   string    `json:"ip"`
+	Time time.Time `json:"time"`
+	// Scripts are the NSE scripts and categories it ran, as given to nmap.
+	Scripts  string    `json:"scripts"`
+	Services []Service `json:"services"`
+	Findings []Finding `json:"findings"`
+	// Checked is when each check run apart from nmap's scripts, such as
+	// the NVD lookup, last gave its findings.
+	Checked map[string]time.Time `json:"checked,omitempty"`
+}
+
+// CheckDefaultLogins is the check of the findings of the default login
+// check. It runs apart from deep scans, which keep what it found.
+const CheckDefaultLogins = "default-logins"
+
+// Service is a service found listening on a device.
+type Service struct {
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+	// Name is the kind of service, such as "ssh", and Product and Version
+	// what answers, such as "OpenSSH" "7.4", when nmap could tell.
+	Name    string `json:"name,omitempty"`
+	Product string `json:"product,omitempty"`
+	Version string `json:"version,omitempty"`
+	// CPE names the product in the form vulnerability databases use, as in
+	// "cpe:/a:openbsd:openssh:7.4".
+	CPE []string `json:"cpe,omitempty"`
+}
+
+// String names the service as it would be listed, as in "22/tcp OpenSSH
+// 7.4".
+func (s Service) String() string {
+	what := strings.TrimSpace(s.Product + " " + s.Version)
+	if what == "" {
+		what = s.Name
+	}
+	return strings.TrimSpace(fmt.Sprintf("%d/%s %s", s.Port, s.Protocol, what))
+}
+
+// Finding is something wrong with a device, such as a known vulnerability
+// in a service it runs or a login it accepts with the maker's password.
+type Finding struct {
+	// Port and Protocol are of the service it is in; Port is 0 for one
+	// about the device as a whole.
+	Port     int    `json:"port,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+	// Check is what found it, such as the NSE script vulners.
+	Check string `json:"check"`
+	// ID identifies it where it has an identity of its own, such as
+	// CVE-2016-10012.
+	ID       string `json:"id,omitempty"`
+	Title    string `json:"title"`
+	Severity string `json:"severity"`
+	// CVSS is its CVSS score, 0 when none was given.
+	CVSS float64 `json:"cvss,omitempty"`
+	// Exploit is set when an exploit for it is publicly known.
+	Exploit bool `json:"exploit,omitempty"`
+}
+
+// Finding severities, from most to least severe.
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+	SeverityInfo     = "info"
+)
+
+// Severities are the finding severities, most severe first.
+var Severities = []string{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityInfo}
+
+// SeverityRank returns how severe severity is, from 4 for critical down to 0
+// for info, or -1 when it is none of them.
+func SeverityRank(severity string) int {
+	for i, s := range Severities {
+		if strings.EqualFold(s, severity) {
+			return len(Severities) - 1 - i
+		}
+	}
+	return -1
+}
+
+// CVSSSeverity returns the severity of a CVSS score, using the bands of CVSS
+// v3: 9 and up is critical, 7 high, 4 medium and anything above 0 low.
+func CVSSSeverity(score float64) string {
+	switch {
+	case score >= 9:
+		return SeverityCritical
+	case score >= 7:
+		return SeverityHigh
+	case score >= 4:
+		return SeverityMedium
+	case score > 0:
+		return SeverityLow
+	}
+	return SeverityInfo
+}
+
+// SortFindings puts the most severe findings first, and those of the same
+// severity by score, then port and then ID.
+func SortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if ra, rb := SeverityRank(a.Severity), SeverityRank(b.Severity); ra != rb {
+			return ra > rb
+		}
+		if a.CVSS != b.CVSS {
+			return a.CVSS > b.CVSS
+		}
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		return a.ID < b.ID
+	})
+}
+
+// Certificate is the TLS certificate a device serves on one port, as it was
+// last checked.
+type Certificate struct {
+	IP   string `json:"ip"`
+	Port int    `json:"port"`
+	// Subject is the certificate's common name, and Names the host names it
+	// is for.
+	Subject string   `json:"subject,omitempty"`
+	Names   []string `json:"names,omitempty"`
+	Issuer  string   `json:"issuer,omitempty"`
+	// SelfSigned is set for a certificate issued by itself rather than by a
+	// certificate authority, as most devices make their own.
+	SelfSigned bool      `json:"self_signed,omitempty"`
+	NotBefore  time.Time `json:"not_before"`
+	NotAfter   time.Time `json:"not_after"`
+	// Fingerprint is the SHA-256 of the certificate, in hex, which tells a
+	// renewed certificate from the one before.
+	Fingerprint string    `json:"fingerprint"`
+	Checked     time.Time `json:"checked"`
+	// Warned is the last CertExpiry stage an event was recorded for, so
+	// that each is reported once.
+	Warned string `json:"warned,omitempty"`
+}
+
+// Expiry returns the CertExpiry stage the certificate is in at now, with
+// warn the warning period, or "" when it is good for longer.
+func (c Certificate) Expiry(now time.Time, warn time.Duration) string {
+	switch {
+	case !now.Before(c.NotAfter):
+		return CertExpired
+	case c.NotAfter.Sub(now) <= warn:
+		return CertExpiring
+	}
+	return ""
+}
+
+// Fingerprint is what a device looks like from the network, kept to tell
+// when what answers at its address has been replaced by another device. A
+// part that is empty was not known when it was taken.
+type Fingerprint struct {
+	IP     string `json:"ip"`
+	Vendor string `json:"vendor,omitempty"`
+	// Ports are the open TCP ports of the deep scan made at Scanned, which
+	// is zero for a device never deep scanned.
+	Ports   []int     `json:"ports,omitempty"`
+	Scanned time.Time `json:"scanned,omitempty"`
+	// Hostname is the device's hostname with its numbers and serials left
+	// out, as in "esp-*", which stays the same for a device renumbered.
+	Hostname string `json:"hostname,omitempty"`
+	// Services are the multicast DNS service types it advertises, such as
+	// "_ipp._tcp".
+	Services []string  `json:"services,omitempty"`
+	Taken    time.Time `json:"taken"`
+	// Changed is when it last changed enough to be reported.
+	Changed time.Time `json:"changed,omitempty"`
+}
+
+// Event is something that happened on the network worth telling the user
+// about, such as a new device appearing.
+type Event struct {
+	ID   int64     `json:"id"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	// IP and Name identify the device an event is about. Name is the label
+	// or hostname at the time, so the event still reads sensibly after the
+	// device is renamed or deleted.
+	IP      string `json:"ip,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Network string `json:"network,omitempty"`
+	// Detail carries extra information, such as why a scan failed or what
+	// kind of anomaly was seen.
+	Detail string `json:"detail,omitempty"`
+	// Message describes the event in English, for API clients that do not
+	// want to compose their own text from the fields above.
+	Message string `json:"message"`
+	Read    bool   `json:"read"`
+}
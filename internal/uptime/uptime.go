@@ -0,0 +1,171 @@
+// Package uptime works out how much of a period each device was online,
+// from the stretches of time scans kept finding it, so that a printer that
+// is down a fifth of the time can be shown to be.
+//
+// A device counts as online from the first scan of a stretch to the last,
+// and on until now while its latest stretch is still current. Time when no
+// scan ran counts as offline, as nothing saw the device then.
+package uptime
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/query"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// DefaultWindow is the period availability covers when none is chosen.
+const DefaultWindow = 7 * 24 * time.Hour
+
+// MaxWindow is the longest period availability can cover: all the sighting
+// history that is kept.
+const MaxWindow = 30 * 24 * time.Hour
+
+// current is how long after its last scan a stretch that has not been closed
+// still counts as going on. It matches how long Device.IsOnline treats a
+// sighting as current.
+const current = time.Hour
+
+// Availability is how much of a window a device was online.
+type Availability struct {
+	IP   string `json:"ip"`
+	Name string `json:"name"`
+	// Tracked is how much of the window the device was known, in seconds:
+	// all of it, or the part since it was first seen.
+	Tracked float64 `json:"tracked"`
+	// Online is how long it was online in that time, in seconds, and
+	// Percent that as a share of Tracked.
+	Online  float64 `json:"online"`
+	Percent float64 `json:"percent"`
+	// Outages counts the times it dropped off within the window.
+	Outages int `json:"outages"`
+}
+
+// ParseWindow reads a window written as a search writes ages, such as 24h,
+// 7d or 4w. Empty means DefaultWindow.
+func ParseWindow(s string) (time.Duration, error) {
+	if s == "" {
+		return DefaultWindow, nil
+	}
+	window, err := query.ParseAge(s)
+	if err != nil {
+		return 0, err
+	}
+	if window < time.Hour || window > MaxWindow {
+		return 0, fmt.Errorf("window %s must be between 1h and 30d", s)
+	}
+	return window, nil
+}
+
+// Compute works out the availability of d over the window ending at now,
+// from its sightings.
+func Compute(d *types.Device, sightings []types.Sighting, window time.Duration, now time.Time) Availability {
+	name := d.Label
+	if name == "" {
+		name = d.Hostname
+	}
+	a := Availability{IP: d.IP, Name: name}
+
+	start := now.Add(-window)
+	if d.FirstSeen.After(start) {
+		start = d.FirstSeen
+	}
+	if !now.After(start) {
+		return a
+	}
+	a.Tracked = now.Sub(start).Seconds()
+
+	var online time.Duration
+	for i, sg := range sightings {
+		from, to := sg.Start, sg.End
+		ongoing := i == len(sightings)-1 && !sg.Closed && now.Sub(sg.End) <= current
+		if ongoing {
+			to = now
+		}
+		if to.Before(start) || from.After(now) {
+			continue
+		}
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(now) {
+			to = now
+		}
+		online += to.Sub(from)
+		if !ongoing {
+			a.Outages++
+		}
+	}
+	a.Online = online.Seconds()
+	a.Percent = 100 * a.Online / a.Tracked
+	return a
+}
+
+// Sightings is where ComputeAll finds each device's history; the storage is
+// one.
+type Sightings interface {
+	GetSightings(ip string, since time.Time) []types.Sighting
+}
+
+// ComputeAll works out the availability of each of devices over the window
+// ending at now, least available first.
+func ComputeAll(devices map[string]*types.Device, source Sightings, window time.Duration, now time.Time) []Availability {
+	result := make([]Availability, 0, len(devices))
+	for ip, d := range devices {
+		result = append(result, Compute(d, source.GetSightings(ip, now.Add(-window)), window, now))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Percent != result[j].Percent {
+			return result[i].Percent < result[j].Percent
+		}
+		return types.LessIP(result[i].IP, result[j].IP)
+	})
+	return result
+}
+
+// OutlierDrop is how many percentage points less of a window than usual a
+// device must have been online to stand out.
+const OutlierDrop = 20
+
+// Outlier is a device that was online much less of a window than usual.
+type Outlier struct {
+	Availability
+	// Usual is its availability over the rest of the history kept, before
+	// the window.
+	Usual float64 `json:"usual"`
+}
+
+// Outliers returns the devices that were online at least OutlierDrop
+// percentage points less of the window ending at now than before it, most
+// fallen first: the printer that is always on and was down half the week,
+// rather than the phone that is out every day. Devices first seen within
+// the window have nothing to fall from, and a window of MaxWindow leaves no
+// history before it, so neither has outliers.
+func Outliers(devices map[string]*types.Device, source Sightings, window time.Duration, now time.Time) []Outlier {
+	before := MaxWindow - window
+	if before <= 0 {
+		return nil
+	}
+	var result []Outlier
+	for ip, d := range devices {
+		sightings := source.GetSightings(ip, now.Add(-MaxWindow))
+		usual := Compute(d, sightings, before, now.Add(-window))
+		if usual.Tracked == 0 {
+			continue
+		}
+		a := Compute(d, sightings, window, now)
+		if usual.Percent-a.Percent >= OutlierDrop {
+			result = append(result, Outlier{Availability: a, Usual: usual.Percent})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		di, dj := result[i].Usual-result[i].Percent, result[j].Usual-result[j].Percent
+		if di != dj {
+			return di > dj
+		}
+		return types.LessIP(result[i].IP, result[j].IP)
+	})
+	return result
+}
@@ -0,0 +1,114 @@
+package uptime
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+var now = time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+
+func hoursAgo(h float64) time.Time {
+	return now.Add(-time.Duration(h * float64(time.Hour)))
+}
+
+func TestCompute(t *testing.T) {
+	printer := &types.Device{IP: "192.168.1.40", Label: "Printer", FirstSeen: hoursAgo(1000)}
+
+	tests := []struct {
+		name        string
+		device      *types.Device
+		sightings   []types.Sighting
+		window      time.Duration
+		wantPercent float64
+		wantOutages int
+	}{
+		{"never seen", printer, nil, 10 * time.Hour, 0, 0},
+		{
+			"down a fifth of the time",
+			printer,
+			[]types.Sighting{
+				{Start: hoursAgo(20), End: hoursAgo(6), Closed: true},
+				// Still going: the last scan found it half an hour ago
+				{Start: hoursAgo(4), End: hoursAgo(0.5)},
+			},
+			10 * time.Hour, 80, 1,
+		},
+		{
+			"gone since its last scan",
+			printer,
+			[]types.Sighting{{Start: hoursAgo(10), End: hoursAgo(5), Closed: true}},
+			10 * time.Hour, 50, 1,
+		},
+		{
+			"not seen for more than an hour",
+			printer,
+			[]types.Sighting{{Start: hoursAgo(10), End: hoursAgo(2)}},
+			10 * time.Hour, 80, 1,
+		},
+		{
+			"first seen within the window",
+			&types.Device{IP: "192.168.1.41", FirstSeen: hoursAgo(2)},
+			[]types.Sighting{{Start: hoursAgo(2), End: hoursAgo(0.25)}},
+			DefaultWindow, 100, 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Compute(tt.device, tt.sightings, tt.window, now)
+			if math.Abs(a.Percent-tt.wantPercent) > 0.01 || a.Outages != tt.wantOutages {
+				t.Errorf("Compute = %+v, want %.0f%% with %d outages", a, tt.wantPercent, tt.wantOutages)
+			}
+		})
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	for in, want := range map[string]time.Duration{"": DefaultWindow, "24h": 24 * time.Hour, "30d": MaxWindow} {
+		if got, err := ParseWindow(in); err != nil || got != want {
+			t.Errorf("ParseWindow(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"10m", "31d", "week"} {
+		if _, err := ParseWindow(in); err == nil {
+			t.Errorf("ParseWindow(%q) should fail", in)
+		}
+	}
+}
+
+// history is a Sightings with fixed sightings by IP address.
+type history map[string][]types.Sighting
+
+func (h history) GetSightings(ip string, since time.Time) []types.Sighting { return h[ip] }
+
+func TestOutliers(t *testing.T) {
+	day := 24 * time.Hour
+	devices := map[string]*types.Device{
+		"192.168.1.40": {IP: "192.168.1.40", Label: "Printer", FirstSeen: hoursAgo(1000)},
+		"192.168.1.50": {IP: "192.168.1.50", Label: "Phone", FirstSeen: hoursAgo(1000)},
+		"192.168.1.60": {IP: "192.168.1.60", Label: "New laptop", FirstSeen: hoursAgo(24)},
+	}
+	source := history{
+		// Always on until it failed three and a half days ago
+		"192.168.1.40": {{Start: hoursAgo(800), End: hoursAgo(84), Closed: true}},
+		// Out every other half day, as it always is
+		"192.168.1.50": func() []types.Sighting {
+			var s []types.Sighting
+			for h := 700.0; h > 0; h -= 24 {
+				s = append(s, types.Sighting{Start: hoursAgo(h), End: hoursAgo(h - 12), Closed: true})
+			}
+			return s
+		}(),
+		"192.168.1.60": {{Start: hoursAgo(24), End: hoursAgo(12), Closed: true}},
+	}
+
+	got := Outliers(devices, source, 7*day, now)
+	if len(got) != 1 || got[0].IP != "192.168.1.40" || math.Abs(got[0].Percent-50) > 0.01 || math.Abs(got[0].Usual-100) > 0.01 {
+		t.Errorf("Outliers = %+v, want the printer, down from 100%% to 50%%", got)
+	}
+	if got := Outliers(devices, source, MaxWindow, now); got != nil {
+		t.Errorf("Outliers over all the history = %+v, want none", got)
+	}
+}
@@ -0,0 +1,209 @@
+// Package watch keeps a closer eye on the devices on the watchlist, those
+// expected always to be online such as the router, the NAS and the cameras.
+// It pings them between scans, so that one going quiet is reported within
+// seconds rather than at the next scan, which may be an hour away.
+//
+// A device counts as gone once it has missed several pings in a row, so that
+// one lost packet on a busy network does not raise the alarm. It is reported
+// with an offline event in the event log, which the alert rules send on as
+// they would one found by a scan.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// Store is where the watchlist comes from and what the pings find goes; the
+// storage is one.
+type Store interface {
+	GetDevices() map[string]*types.Device
+	MarkSeenAll(seen map[string]*float64) (int, error)
+	ReportUnreachable(ip, why string) (bool, error)
+}
+
+// PingFunc sends one ping to ip and returns how long the reply took, or an
+// error when none came.
+type PingFunc func(ctx context.Context, ip string) (time.Duration, error)
+
+// Watcher pings the devices on the watchlist.
+type Watcher struct {
+	// Interval is how long it waits between rounds of pings.
+	Interval time.Duration
+	// Failures is how many pings in a row a device must miss to be
+	// reported offline.
+	Failures int
+	// Ping sends a ping; SystemPing if nil.
+	Ping PingFunc
+
+	// missed counts the pings each device has missed in a row.
+	missed map[string]int
+	// warned is set once it has said there is no ping command.
+	warned bool
+}
+
+// Run pings the devices on the watchlist every Interval until ctx is done.
+// The watchlist is read again for each round, so devices added to it or
+// taken off are noticed without a restart.
+func (w *Watcher) Run(ctx context.Context, store Store) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		w.check(ctx, store)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check pings every device on the watchlist once, at the same time, and
+// records what it finds.
+func (w *Watcher) check(ctx context.Context, store Store) {
+	if w.missed == nil {
+		w.missed = make(map[string]int)
+	}
+	ping := w.Ping
+	if ping == nil {
+		ping = SystemPing
+	}
+
+	devices := store.GetDevices()
+	var watched []*types.Device
+	for _, d := range devices {
+		if d.Watch {
+			watched = append(watched, d)
+		}
+	}
+	// Forget devices that have left the watchlist, so one put back on it
+	// starts afresh.
+	for ip := range w.missed {
+		if d := devices[ip]; d == nil || !d.Watch {
+			delete(w.missed, ip)
+		}
+	}
+	if len(watched) == 0 {
+		return
+	}
+	if w.Ping == nil {
+		// Without it every device would seem to have gone.
+		if _, err := exec.LookPath("ping"); err != nil {
+			if !w.warned {
+				slog.Warn("watchlist not pinged: ping not found in PATH")
+				w.warned = true
+			}
+			return
+		}
+	}
+
+	type result struct {
+		ip   string
+		took time.Duration
+		err  error
+	}
+	results := make(chan result, len(watched))
+	var wg sync.WaitGroup
+	for _, d := range watched {
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+			defer cancel()
+			took, err := ping(pingCtx, ip)
+			results <- result{ip, took, err}
+		}(d.IP)
+	}
+	wg.Wait()
+	close(results)
+	if ctx.Err() != nil {
+		return
+	}
+
+	seen := make(map[string]*float64)
+	for r := range results {
+		if r.err == nil {
+			w.missed[r.ip] = 0
+			ms := float64(r.took) / float64(time.Millisecond)
+			seen[r.ip] = &ms
+			continue
+		}
+		w.missed[r.ip]++
+		if w.missed[r.ip] != w.failures() {
+			continue
+		}
+		why := fmt.Sprintf("no reply to %d pings", w.missed[r.ip])
+		if w.missed[r.ip] == 1 {
+			why = "no reply to a ping"
+		}
+		if reported, err := store.ReportUnreachable(r.ip, why); err != nil {
+			slog.Warn("cannot record watched device offline", "ip", r.ip, "error", err)
+		} else if reported {
+			slog.Info("watched device stopped answering", "ip", r.ip, "pings", w.missed[r.ip])
+		}
+	}
+	if len(seen) > 0 {
+		if _, err := store.MarkSeenAll(seen); err != nil {
+			slog.Warn("cannot record watched devices seen", "error", err)
+		}
+	}
+}
+
+// failures returns Failures, or 1 when it is not set.
+func (w *Watcher) failures() int {
+	if w.Failures < 1 {
+		return 1
+	}
+	return w.Failures
+}
+
+// pingTimeout bounds waiting for one reply. A device on the local network
+// that takes longer than this to answer is in no state to count as up.
+const pingTimeout = 2 * time.Second
+
+// replyTimeRe finds the round trip time in a reply line of ping's output:
+// "time=0.52 ms" on Linux and macOS, "time=1ms" or "time<1ms" on Windows.
+var replyTimeRe = regexp.MustCompile(`time[=<]\s*([0-9.]+)\s*ms`)
+
+// ReplyTimes returns the round trip times, in milliseconds, of the replies
+// in the output of the system ping command.
+func ReplyTimes(output string) []float64 {
+	var times []float64
+	for _, m := range replyTimeRe.FindAllStringSubmatch(output, -1) {
+		if ms, err := strconv.ParseFloat(m[1], 64); err == nil {
+			times = append(times, ms)
+		}
+	}
+	return times
+}
+
+// SystemPing pings ip once with the system ping command, which can send
+// ICMP without this process needing the privilege to.
+func SystemPing(ctx context.Context, ip string) (time.Duration, error) {
+	args := []string{"-c", "1", "-W", "1", ip}
+	switch runtime.GOOS {
+	case "windows":
+		args = []string{"-n", "1", "-w", "1000", ip}
+	case "darwin", "freebsd", "openbsd", "netbsd":
+		// Their -W is in milliseconds; -t bounds the whole run instead.
+		args = []string{"-c", "1", "-t", "1", ip}
+	}
+	out, err := exec.CommandContext(ctx, "ping", args...).Output()
+	times := ReplyTimes(string(out))
+	if len(times) == 0 {
+		if err == nil {
+			err = fmt.Errorf("no reply from %s", ip)
+		}
+		return 0, err
+	}
+	return time.Duration(times[0] * float64(time.Millisecond)), nil
+}
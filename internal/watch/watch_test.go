@@ -0,0 +1,105 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// fakeStore is a Store that keeps what the watcher records.
+type fakeStore struct {
+	mu          sync.Mutex
+	devices     map[string]*types.Device
+	seen        []string
+	unreachable []string
+}
+
+func (s *fakeStore) GetDevices() map[string]*types.Device { return s.devices }
+
+func (s *fakeStore) MarkSeenAll(seen map[string]*float64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ip := range seen {
+		s.seen = append(s.seen, ip)
+	}
+	return len(seen), nil
+}
+
+func (s *fakeStore) ReportUnreachable(ip, why string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unreachable = append(s.unreachable, ip+": "+why)
+	return true, nil
+}
+
+func TestWatcher(t *testing.T) {
+	store := &fakeStore{devices: map[string]*types.Device{
+		"192.168.1.1":  {IP: "192.168.1.1", Watch: true},
+		"192.168.1.10": {IP: "192.168.1.10", Watch: true},
+		"192.168.1.50": {IP: "192.168.1.50"},
+	}}
+	// The watcher pings the devices at once, so the fake ping locks.
+	var mu sync.Mutex
+	down := map[string]bool{"192.168.1.10": true}
+	var pinged []string
+	w := &Watcher{Failures: 2, Ping: func(ctx context.Context, ip string) (time.Duration, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		pinged = append(pinged, ip)
+		if down[ip] {
+			return 0, errors.New("no reply")
+		}
+		return time.Millisecond, nil
+	}}
+	ctx := context.Background()
+
+	w.check(ctx, store)
+	if len(pinged) != 2 || len(store.unreachable) != 0 || len(store.seen) != 1 || store.seen[0] != "192.168.1.1" {
+		t.Fatalf("first round: pinged %v, seen %v, unreachable %v; want the router seen and the NAS given another chance", pinged, store.seen, store.unreachable)
+	}
+	w.check(ctx, store)
+	w.check(ctx, store)
+	if len(store.unreachable) != 1 || store.unreachable[0] != "192.168.1.10: no reply to 2 pings" {
+		t.Fatalf("unreachable = %v; want the NAS reported once, after two missed pings", store.unreachable)
+	}
+
+	// It comes back, and goes again.
+	setDown := func(v bool) {
+		mu.Lock()
+		down["192.168.1.10"] = v
+		mu.Unlock()
+	}
+	setDown(false)
+	w.check(ctx, store)
+	setDown(true)
+	w.check(ctx, store)
+	w.check(ctx, store)
+	if len(store.unreachable) != 2 {
+		t.Errorf("unreachable = %v; want the NAS reported again after it came back", store.unreachable)
+	}
+}
+
+func TestReplyTimes(t *testing.T) {
+	for output, want := range map[string]float64{
+		"64 bytes from 192.168.1.1: icmp_seq=1 ttl=64 time=0.52 ms": 0.52,
+		"Reply from 192.168.1.1: bytes=32 time=3ms TTL=64":          3,
+		"Reply from 192.168.1.1: bytes=32 time<1ms TTL=64":          1,
+		"Request timeout for icmp_seq 0":                            -1,
+		"From 192.168.1.5 icmp_seq=1 Destination Host Unreachable":  -1,
+	} {
+		times := ReplyTimes(output)
+		if want < 0 {
+			if len(times) != 0 {
+				t.Errorf("ReplyTimes(%q) = %v; want none", output, times)
+			}
+			continue
+		}
+		if len(times) != 1 || times[0] != want {
+			t.Errorf("ReplyTimes(%q) = %v; want %v", output, times, want)
+		}
+	}
+}
@@ -3,20 +3,26 @@ package web
 
 import (
 	"bytes"
+	"crypto/subtle"
 	"embed"
-	"fmt"
 	"html/template"
+	"log/slog"
 	"net/http"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/291-Group/LAN-Orangutan/internal/auth"
 	"github.com/291-Group/LAN-Orangutan/internal/config"
+	"github.com/291-Group/LAN-Orangutan/internal/i18n"
 	"github.com/291-Group/LAN-Orangutan/internal/network"
+	"github.com/291-Group/LAN-Orangutan/internal/oidc"
+	"github.com/291-Group/LAN-Orangutan/internal/risk"
 	"github.com/291-Group/LAN-Orangutan/internal/scanner"
 	"github.com/291-Group/LAN-Orangutan/internal/storage"
 	"github.com/291-Group/LAN-Orangutan/internal/types"
+	"github.com/291-Group/LAN-Orangutan/internal/uptime"
 )
 
 //go:embed static/*
@@ -27,34 +33,117 @@ var templateFS embed.FS
 
 // Handler handles web requests
 type Handler struct {
-	store     *storage.Storage
-	cfg       *config.Config
+	store *storage.Storage
+	// cfg is replaced whole when the config is reloaded.
+	cfg       atomic.Pointer[config.Config]
 	auth      *auth.Authenticator
 	version   string
 	templates *template.Template
 	staticFS  http.Handler
+	// sso signs users in with an OpenID Connect provider; nil when there is
+	// none.
+	sso *oidc.Provider
 }
 
+// ssoStateCookie ties the browser coming back from the OpenID Connect
+// provider to the one that was sent there, so that nobody can sign someone
+// else in as themselves.
+const ssoStateCookie = "orangutan_sso_state"
+
+// languageCookie remembers a language picked on the settings page, so it wins
+// over whatever the browser asks for.
+const languageCookie = "orangutan_lang"
+
 // PageData holds data passed to templates
 type PageData struct {
-	Title        string
-	Theme        string
-	Version      string
-	Devices      []*DeviceView
-	Networks     []types.Network
-	Tailscale    types.TailscaleStatus
-	Stats        types.DeviceStats
-	Groups       []string
-	CurrentGroup string
-	Error        string
+	// Lang is the language the page is rendered in. Templates translate with
+	// {{.T "id"}} and {{.N "id" count}}, or {{$.T ...}} inside a range.
+	Lang string
+
+	// Languages lists every language that ships, for the settings page.
+	Languages []string
+
+	// LanguageChosen is set when this browser picked Lang on the settings
+	// page, rather than it being configured or negotiated.
+	LanguageChosen bool
+
+	// JSMessages carries the translations the browser script needs.
+	JSMessages map[string]string
+
+	Title     string
+	Theme     string
+	Version   string
+	Devices   []*DeviceView
+	Networks  []types.Network
+	Tailscale types.TailscaleStatus
+	Stats     types.DeviceStats
+	Groups    []string
+	Peers     []PeerView
+	Device    *DeviceView
+	Timeline  Timeline
+
+	// NewDevices, RecentlyOffline, Vendors, Vulnerable and ExpiringCerts
+	// feed the optional dashboard widgets.
+	NewDevices      []*DeviceView
+	RecentlyOffline []*DeviceView
+	Vendors         []VendorCount
+	Vulnerable      []VulnerableDevice
+	ExpiringCerts   []ExpiringCert
+
+	// PendingDevices are the devices waiting for approval, listed above
+	// everything else on the dashboard until each is approved or deleted.
+	PendingDevices []*DeviceView
+
+	// Report holds the sections of the printable report, grouped by network
+	// when ReportByNetwork is set and by group otherwise. GeneratedAt is
+	// when it was produced, since a printout outlives the page.
+	Report          []ReportSection
+	ReportByNetwork bool
+	GeneratedAt     string
+	DeviceTypes     []string
+	CurrentGroup    string
+	Error           string
+
+	// DeepScan is the latest deep scan of the device a device page is about,
+	// or nil when it has had none. DeepScanEnabled shows a button to start
+	// one, when [vulnscan] allows it.
+	DeepScan        *types.DeepScan
+	DeepScanEnabled bool
+
+	// Availability is how much of the report's window, UptimeWindow, each
+	// device was online, by IP address.
+	Availability map[string]uptime.Availability
+	UptimeWindow string
+
+	// UnreadEvents is how many notifications are waiting, so the bell's badge
+	// is right from the first paint rather than after a request.
+	UnreadEvents int
 
 	// AuthEnabled reports whether a password is configured, so pages can show
-	// a sign out link only when there is a session to end.
+	// a sign out link only when there is a session to end. UserName is who
+	// signed in, when that was with a directory or single sign-on. CanEdit
+	// is false for a viewer, whose pages leave out the controls that change
+	// or scan devices.
 	AuthEnabled bool
+	UserName    string
+	CanEdit     bool
 
 	// MinPasswordLength is shown on the setup page and enforced by the browser.
 	MinPasswordLength int
 
+	// UsernameRequired adds a username field to the sign in form.
+	UsernameRequired bool
+
+	// PasswordLogin shows the sign in form, and SingleSignOn a button to sign
+	// in with the OpenID Connect provider named SingleSignOnName.
+	PasswordLogin    bool
+	SingleSignOn     bool
+	SingleSignOnName string
+
+	// CSRFToken must accompany any request the page makes that changes data.
+	// Empty when there is no session, in which case none is checked.
+	CSRFToken string
+
 	// LastScanAgo and LastScanAt describe when the device list was last
 	// refreshed. Both are empty when nothing has been scanned yet.
 	LastScanAgo string
@@ -71,6 +160,101 @@ type PageData struct {
 	LastScanUnix int64
 }
 
+// T translates a message into the page's language.
+func (p PageData) T(id string, args ...any) string {
+	return i18n.T(p.Lang, id, args...)
+}
+
+// N translates a message that varies with a count.
+func (p PageData) N(id string, count int, args ...any) string {
+	return i18n.N(p.Lang, id, count, args...)
+}
+
+// TypeName is the display name of a device type in the page's language.
+func (p PageData) TypeName(deviceType string) string {
+	if deviceType == "" {
+		return p.T("type.unknown")
+	}
+	return p.T("type." + deviceType)
+}
+
+// SeverityName is the display name of a finding severity in the page's
+// language.
+func (p PageData) SeverityName(severity string) string {
+	return p.T("severity." + severity)
+}
+
+// GroupName is the display name of a group in the page's language. Groups
+// made up through the API have no translation and are shown as they are.
+func (p PageData) GroupName(group string) string {
+	id := "group." + group
+	if name := p.T(id); name != id {
+		return name
+	}
+	return group
+}
+
+// Ago describes how long ago t was, in the page's language.
+func (p PageData) Ago(t time.Time) string {
+	return timeAgo(p.Lang, t)
+}
+
+// LanguageName is a language's name for itself, as its catalog gives it.
+func (p PageData) LanguageName(lang string) string {
+	return i18n.T(lang, "language.name")
+}
+
+// newPageData starts the data for a page rendered in response to r, with the
+// language settled.
+func (h *Handler) newPageData(r *http.Request, title string) PageData {
+	lang := h.language(r)
+	return PageData{
+		Lang:           lang,
+		Languages:      i18n.Languages(),
+		LanguageChosen: chosenLanguage(r) != "",
+		JSMessages:     i18n.Messages(lang, "js."),
+		Title:          title,
+		Theme:          h.cfg.Load().UI.Theme,
+		UnreadEvents:   h.store.UnreadEvents(),
+	}
+}
+
+// language picks the language for r: one chosen on the settings page first,
+// then the configured language, then the browser's preference.
+func (h *Handler) language(r *http.Request) string {
+	if lang := chosenLanguage(r); lang != "" {
+		return lang
+	}
+	return i18n.Negotiate(h.cfg.Load().UI.Language, r.Header.Get("Accept-Language"))
+}
+
+// chosenLanguage returns the language remembered from the settings page, or
+// "" if none was picked or it no longer ships.
+func chosenLanguage(r *http.Request) string {
+	c, err := r.Cookie(languageCookie)
+	if err != nil {
+		return ""
+	}
+	for _, lang := range i18n.Languages() {
+		if lang == c.Value {
+			return lang
+		}
+	}
+	return ""
+}
+
+// PeerView is a tailnet peer with what the inventory knows about it.
+type PeerView struct {
+	types.TailscalePeer
+	TimeAgo      string
+	LastSeenUnix int64
+
+	// InInventory is set when the peer's address is already in the device
+	// list, in which case Label is whatever it is labelled there.
+	InInventory bool
+	Label       string
+}
+
 // DeviceView is a device with computed display properties
 type DeviceView struct {
 	*types.Device
@@ -82,14 +266,52 @@ type DeviceView struct {
 	// Vendor shadows the stored value so it can be resolved for records that
 	// predate the built-in manufacturer database.
 	Vendor string
+
+	// DisplayType is the type the row's icon shows: Type if one was chosen by
+	// hand, otherwise DetectedType.
+	DisplayType  string
+	DetectedType string
+
+	// Risk is the device's risk score and what it is made of.
+	Risk risk.Assessment
+}
+
+// newDeviceView works out how d is displayed in the given language.
+func newDeviceView(lang string, d *types.Device) *DeviceView {
+	dv := &DeviceView{
+		Device:       d,
+		TimeAgo:      timeAgo(lang, d.LastSeen),
+		LastSeenUnix: d.LastSeen.Unix(),
+		// Devices recorded by an older version have no vendor stored, so
+		// look it up now rather than showing "Unknown" until a rescan.
+		Vendor:       scanner.ResolveVendor(d.Vendor, d.MAC),
+		DetectedType: scanner.DetectType(d),
+	}
+	dv.DisplayType = d.Type
+	if dv.DisplayType == "" {
+		dv.DisplayType = dv.DetectedType
+	}
+
+	if d.IsRecent() {
+		dv.Status = "online"
+		dv.StatusClass = "status-online"
+	} else if d.IsOnline() {
+		dv.Status = "seen"
+		dv.StatusClass = "status-seen"
+	} else {
+		dv.Status = "offline"
+		dv.StatusClass = "status-offline"
+	}
+	return dv
 }
 
 // NewHandler creates a new web handler
 func NewHandler(store *storage.Storage, cfg *config.Config, authn *auth.Authenticator, version string) *Handler {
 	// Parse templates with custom functions
 	funcMap := template.FuncMap{
-		"timeAgo": timeAgo,
-		"lower":   strings.ToLower,
+		"lower":    strings.ToLower,
+		"join":     strings.Join,
+		"typeIcon": typeIcon,
 	}
 
 	tmpl := template.Must(template.New("").Funcs(funcMap).ParseFS(templateFS, "templates/*.html"))
@@ -98,14 +320,25 @@ func NewHandler(store *storage.Storage, cfg *config.Config, authn *auth.Authenti
 	staticSub, _ := staticSubFS()
 	staticHandler := newStaticHandler(staticSub)
 
-	return &Handler{
+	h := &Handler{
 		store:     store,
-		cfg:       cfg,
 		auth:      authn,
 		version:   version,
 		templates: tmpl,
 		staticFS:  staticHandler,
 	}
+	h.cfg.Store(cfg)
+	return h
+}
+
+// SetConfig switches to the settings in cfg, for a reloaded config file.
+func (h *Handler) SetConfig(cfg *config.Config) {
+	h.cfg.Store(cfg)
+}
+
+// SetSingleSignOn has the sign in page offer signing in with p.
+func (h *Handler) SetSingleSignOn(p *oidc.Provider) {
+	h.sso = p
 }
 
 // StaticHandler serves the embedded static assets.
@@ -116,6 +349,18 @@ func (h *Handler) StaticHandler() http.Handler {
 	return h.staticFS
 }
 
+// ServiceWorker serves the service worker script from the site root.
+//
+// A service worker only controls pages at or below the path it was served
+// from, so it cannot live under /static/ with the other assets if the
+// installed app is to work offline. Like the other assets it must be reachable
+// without a session, since the browser fetches it in the background.
+func (h *Handler) ServiceWorker(w http.ResponseWriter, r *http.Request) {
+	r2 := r.Clone(r.Context())
+	r2.URL.Path = "/static/sw.js"
+	h.staticFS.ServeHTTP(w, r2)
+}
+
 // HandleSetup renders the first run page and creates the initial password.
 //
 // onPasswordSet persists the new hash. It is supplied by the caller so this
@@ -131,36 +376,36 @@ func (h *Handler) HandleSetup(onPasswordSet func(hash string) error) http.Handle
 
 		switch r.Method {
 		case http.MethodGet:
-			h.renderSetup(w, "", http.StatusOK)
+			h.renderSetup(w, r, "", http.StatusOK)
 
 		case http.MethodPost:
 			if err := r.ParseForm(); err != nil {
-				h.renderSetup(w, "Could not read that submission. Please try again.", http.StatusBadRequest)
+				h.renderSetup(w, r, i18n.T(h.language(r), "login.bad_form"), http.StatusBadRequest)
 				return
 			}
 
 			password := r.FormValue("password")
 			if password != r.FormValue("confirm") {
-				h.renderSetup(w, "Those passwords do not match.", http.StatusBadRequest)
+				h.renderSetup(w, r, i18n.T(h.language(r), "setup.mismatch"), http.StatusBadRequest)
 				return
 			}
 
 			if err := auth.ValidatePassword(password); err != nil {
-				h.renderSetup(w, err.Error(), http.StatusBadRequest)
+				h.renderSetup(w, r, err.Error(), http.StatusBadRequest)
 				return
 			}
 
 			hash, err := h.auth.SetPassword(password)
 			if err != nil {
-				h.renderSetup(w, err.Error(), http.StatusBadRequest)
+				h.renderSetup(w, r, err.Error(), http.StatusBadRequest)
 				return
 			}
 
 			if err := onPasswordSet(hash); err != nil {
 				// The password is live in memory but could not be saved, so it
 				// would vanish on restart. Say so rather than pretend.
-				h.renderSetup(w,
-					"Your password was set, but could not be saved: "+err.Error(),
+				h.renderSetup(w, r,
+					i18n.T(h.language(r), "setup.not_saved", err.Error()),
 					http.StatusInternalServerError)
 				return
 			}
@@ -179,13 +424,11 @@ func (h *Handler) HandleSetup(onPasswordSet func(hash string) error) http.Handle
 }
 
 // renderSetup draws the first run page, optionally with an error message.
-func (h *Handler) renderSetup(w http.ResponseWriter, message string, status int) {
-	data := PageData{
-		Title:             "Welcome - LAN Orangutan",
-		Theme:             h.cfg.UI.Theme,
-		Error:             message,
-		MinPasswordLength: auth.MinPasswordLength,
-	}
+func (h *Handler) renderSetup(w http.ResponseWriter, r *http.Request, message string, status int) {
+	data := h.newPageData(r, "")
+	data.Title = data.T("setup.title") + " - LAN Orangutan"
+	data.Error = message
+	data.MinPasswordLength = auth.MinPasswordLength
 
 	var buf bytes.Buffer
 	if err := h.templates.ExecuteTemplate(&buf, "setup.html", data); err != nil {
@@ -215,22 +458,26 @@ func (h *Handler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
-		h.renderLogin(w, "")
+		h.renderLogin(w, r, "")
 
 	case http.MethodPost:
 		if err := r.ParseForm(); err != nil {
-			h.renderLogin(w, "Could not read that submission. Please try again.")
+			h.renderLogin(w, r, i18n.T(h.language(r), "login.bad_form"))
 			return
 		}
 
 		if h.auth.LockedOut(r.RemoteAddr) {
-			h.renderLogin(w, "Too many failed attempts. Please wait a few minutes and try again.")
+			h.renderLogin(w, r, i18n.T(h.language(r), "login.locked_out"))
 			return
 		}
 
-		token, ok := h.auth.Login(r.RemoteAddr, r.FormValue("password"))
+		token, ok := h.auth.LoginAs(r.RemoteAddr, r.FormValue("username"), r.FormValue("password"))
 		if !ok {
-			h.renderLogin(w, "Incorrect password.")
+			if h.auth.UsernameRequired() {
+				h.renderLogin(w, r, i18n.T(h.language(r), "login.incorrect_both"))
+			} else {
+				h.renderLogin(w, r, i18n.T(h.language(r), "login.incorrect_password"))
+			}
 			return
 		}
 
@@ -249,12 +496,95 @@ func (h *Handler) HandleLogout(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, auth.LoginPath, http.StatusSeeOther)
 }
 
+// HandleSingleSignOn sends the browser to the OpenID Connect provider to sign
+// in.
+func (h *Handler) HandleSingleSignOn(w http.ResponseWriter, r *http.Request) {
+	if h.sso == nil {
+		http.NotFound(w, r)
+		return
+	}
+	authURL, state, err := h.sso.Begin(r.Context(), h.ssoRedirectURL(r))
+	if err != nil {
+		slog.Warn("could not start single sign-on", "error", err)
+		h.renderLogin(w, r, i18n.T(h.language(r), "login.sso_unavailable"))
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoStateCookie,
+		Value:    state,
+		Path:     auth.SingleSignOnPath,
+		HttpOnly: true,
+		// Lax, so that it comes back with the provider's redirect.
+		SameSite: http.SameSiteLaxMode,
+		Secure:   r.TLS != nil,
+		MaxAge:   int((10 * time.Minute).Seconds()),
+	})
+	http.Redirect(w, r, authURL, http.StatusSeeOther)
+}
+
+// HandleSingleSignOnCallback signs in the user the OpenID Connect provider
+// sends back.
+func (h *Handler) HandleSingleSignOnCallback(w http.ResponseWriter, r *http.Request) {
+	if h.sso == nil {
+		http.NotFound(w, r)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: ssoStateCookie, Path: auth.SingleSignOnPath, HttpOnly: true, MaxAge: -1})
+
+	q := r.URL.Query()
+	if problem := q.Get("error"); problem != "" {
+		slog.Warn("the sign in provider refused the sign in", "error", problem, "description", q.Get("error_description"))
+		h.renderLogin(w, r, i18n.T(h.language(r), "login.sso_failed"))
+		return
+	}
+	state := q.Get("state")
+	cookie, err := r.Cookie(ssoStateCookie)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		h.renderLogin(w, r, i18n.T(h.language(r), "login.sso_failed"))
+		return
+	}
+	id, err := h.sso.Finish(r.Context(), state, q.Get("code"))
+	if err != nil {
+		slog.Warn("single sign-on failed", "error", err)
+		h.renderLogin(w, r, i18n.T(h.language(r), "login.sso_failed"))
+		return
+	}
+	token, role, ok := h.auth.SignIn(id.Username, id.Groups)
+	if !ok {
+		slog.Warn("sign in refused: the user is in no group with a role", "user", id.Username, "groups", id.Groups)
+		h.renderLogin(w, r, i18n.T(h.language(r), "login.no_role"))
+		return
+	}
+	slog.Info("signed in with single sign-on", "user", id.Username, "role", role)
+	h.auth.SetCookie(w, r, token)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// ssoRedirectURL returns where the OpenID Connect provider sends the browser
+// back to: the configured redirect_url, or the callback on the address the
+// dashboard was opened at, through a reverse proxy if need be.
+func (h *Handler) ssoRedirectURL(r *http.Request) string {
+	if u := h.cfg.Load().OIDC.RedirectURL; u != "" {
+		return u
+	}
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + auth.SingleSignOnCallbackPath
+}
+
 // renderLogin draws the sign in page, optionally with an error message.
-func (h *Handler) renderLogin(w http.ResponseWriter, message string) {
-	data := PageData{
-		Title: "Sign in - LAN Orangutan",
-		Theme: h.cfg.UI.Theme,
-		Error: message,
+func (h *Handler) renderLogin(w http.ResponseWriter, r *http.Request, message string) {
+	data := h.newPageData(r, "")
+	data.Title = data.T("login.title") + " - LAN Orangutan"
+	data.Error = message
+	data.UsernameRequired = h.auth.UsernameRequired()
+	data.PasswordLogin = h.auth.PasswordLogin()
+	data.SingleSignOn = h.sso != nil
+	data.SingleSignOnName = h.cfg.Load().OIDC.Name
+	if data.SingleSignOnName == "" {
+		data.SingleSignOnName = data.T("login.sso_default_name")
 	}
 
 	var buf bytes.Buffer
@@ -288,53 +618,48 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.handleIndex(w, r)
 	case "/settings", "/settings.html":
 		h.handleSettings(w, r)
+	case "/tailscale":
+		h.handleTailscale(w, r)
+	case "/device":
+		h.handleDevice(w, r)
+	case "/report":
+		h.handleReport(w, r)
 	default:
 		http.NotFound(w, r)
 	}
 }
 
+// deviceViews returns every device ready for display, sorted by IP.
+func (h *Handler) deviceViews(lang string) []*DeviceView {
+	var views []*DeviceView
+	scans := h.store.GetDeepScans()
+	for _, d := range h.store.GetDevices() {
+		dv := newDeviceView(lang, d)
+		var scan *types.DeepScan
+		if s, ok := scans[d.IP]; ok {
+			scan = &s
+		}
+		dv.Risk = risk.Assess(d, scan)
+		views = append(views, dv)
+	}
+	sort.Slice(views, func(i, j int) bool {
+		return ipToLong(views[i].IP) < ipToLong(views[j].IP)
+	})
+	return views
+}
+
 // handleIndex renders the main dashboard
 func (h *Handler) handleIndex(w http.ResponseWriter, r *http.Request) {
-	// Get devices
-	devices := h.store.GetDevices()
+	lang := h.language(r)
 
-	// Convert to view models
-	var deviceViews []*DeviceView
+	deviceViews := h.deviceViews(lang)
 	groupSet := make(map[string]bool)
-
-	for _, d := range devices {
-		dv := &DeviceView{
-			Device:       d,
-			TimeAgo:      timeAgo(d.LastSeen),
-			LastSeenUnix: d.LastSeen.Unix(),
-			// Devices recorded by an older version have no vendor stored, so
-			// look it up now rather than showing "Unknown" until a rescan.
-			Vendor: scanner.ResolveVendor(d.Vendor, d.MAC),
-		}
-
-		if d.IsRecent() {
-			dv.Status = "online"
-			dv.StatusClass = "status-online"
-		} else if d.IsOnline() {
-			dv.Status = "seen"
-			dv.StatusClass = "status-seen"
-		} else {
-			dv.Status = "offline"
-			dv.StatusClass = "status-offline"
-		}
-
-		deviceViews = append(deviceViews, dv)
-
-		if d.Group != "" {
-			groupSet[d.Group] = true
+	for _, dv := range deviceViews {
+		if dv.Group != "" {
+			groupSet[dv.Group] = true
 		}
 	}
 
-	// Sort by IP
-	sort.Slice(deviceViews, func(i, j int) bool {
-		return ipToLong(deviceViews[i].IP) < ipToLong(deviceViews[j].IP)
-	})
-
 	// Get groups
 	var groups []string
 	for g := range groupSet {
@@ -344,7 +669,7 @@ func (h *Handler) handleIndex(w http.ResponseWriter, r *http.Request) {
 
 	// Get networks
 	networks, _ := network.DetectNetworks()
-	networks = network.WithConfigured(networks, h.cfg.Scanning.Networks)
+	networks = network.WithConfigured(networks, h.cfg.Load().Scanning.Networks)
 
 	// Get Tailscale status
 	tailscale := network.GetTailscaleStatus()
@@ -352,24 +677,33 @@ func (h *Handler) handleIndex(w http.ResponseWriter, r *http.Request) {
 	// Get stats
 	stats := h.store.GetStats()
 
-	data := PageData{
-		Title:       "LAN Orangutan",
-		Theme:       h.cfg.UI.Theme,
-		Devices:     deviceViews,
-		Networks:    networks,
-		Tailscale:   tailscale,
-		Stats:       stats,
-		Groups:      groups,
-		AuthEnabled: h.auth.Enabled(),
-	}
+	data := h.newPageData(r, "LAN Orangutan")
+	data.Devices = deviceViews
+	data.Networks = networks
+	data.Tailscale = tailscale
+	data.Stats = stats
+	data.Groups = groups
+	data.DeviceTypes = scanner.DeviceTypes
+	now := time.Now()
+	data.NewDevices = newDevices(deviceViews, now)
+	data.RecentlyOffline = recentlyOffline(deviceViews, now)
+	data.Vendors = vendorBreakdown(deviceViews)
+	data.Vulnerable = vulnerableDevices(deviceViews, h.store.GetDeepScans())
+	data.ExpiringCerts = expiringCerts(deviceViews, h.store.GetCertificates(), h.cfg.Load().Certs.Warn, now)
+	data.PendingDevices = pendingDevices(deviceViews)
+	data.AuthEnabled = h.auth.Enabled()
+	user := h.auth.UserFor(r)
+	data.UserName = user.Name
+	data.CanEdit = user.Role >= auth.RoleEditor
+	data.CSRFToken = h.auth.CSRFToken(r)
 
 	data.NetworkWarning = network.IsolationWarning(networks)
 
 	// The table is only as current as the last scan. Say so, so that a "last
 	// seen" time is read against when the data was actually gathered.
 	if lastScan := h.store.GetMostRecentScan(); !lastScan.IsZero() {
-		data.LastScanAgo = timeAgo(lastScan)
-		data.LastScanAt = lastScan.Format("Jan 2, 2006 at 3:04 PM")
+		data.LastScanAgo = timeAgo(lang, lastScan)
+		data.LastScanAt = lastScan.Format(i18n.T(lang, "time.datetime_format"))
 		data.LastScanUnix = lastScan.Unix()
 	}
 
@@ -391,14 +725,16 @@ func (h *Handler) handleSettings(w http.ResponseWriter, r *http.Request) {
 	// Get stats
 	stats := h.store.GetStats()
 
-	data := PageData{
-		Title:       "Settings - LAN Orangutan",
-		Theme:       h.cfg.UI.Theme,
-		Version:     h.version,
-		Tailscale:   tailscale,
-		Stats:       stats,
-		AuthEnabled: h.auth.Enabled(),
-	}
+	data := h.newPageData(r, "")
+	data.Title = data.T("settings.title") + " - LAN Orangutan"
+	data.Version = h.version
+	data.Tailscale = tailscale
+	data.Stats = stats
+	data.AuthEnabled = h.auth.Enabled()
+	user := h.auth.UserFor(r)
+	data.UserName = user.Name
+	data.CanEdit = user.Role >= auth.RoleEditor
+	data.CSRFToken = h.auth.CSRFToken(r)
 
 	// Buffer the template output to avoid superfluous WriteHeader on error
 	var buf bytes.Buffer
@@ -410,37 +746,173 @@ func (h *Handler) handleSettings(w http.ResponseWriter, r *http.Request) {
 	buf.WriteTo(w)
 }
 
-// timeAgo returns a human-readable time difference
-func timeAgo(t time.Time) string {
+// handleTailscale renders the list of tailnet peers
+func (h *Handler) handleTailscale(w http.ResponseWriter, r *http.Request) {
+	lang := h.language(r)
+	status := network.GetTailscaleStatus()
+
+	var peers []PeerView
+	if status.Connected {
+		for _, p := range network.GetTailscalePeers() {
+			pv := PeerView{TailscalePeer: p}
+			// Online peers have no last seen time: they are being seen now.
+			if !p.Online && !p.LastSeen.IsZero() {
+				pv.TimeAgo = timeAgo(lang, p.LastSeen)
+				pv.LastSeenUnix = p.LastSeen.Unix()
+			}
+			if d := h.store.GetDevice(p.IP); d != nil && p.IP != "" {
+				pv.InInventory = true
+				pv.Label = d.Label
+			}
+			peers = append(peers, pv)
+		}
+	}
+
+	data := h.newPageData(r, "")
+	data.Title = data.T("tailscale.title") + " - LAN Orangutan"
+	data.Tailscale = status
+	data.Peers = peers
+	data.AuthEnabled = h.auth.Enabled()
+	user := h.auth.UserFor(r)
+	data.UserName = user.Name
+	data.CanEdit = user.Role >= auth.RoleEditor
+	data.CSRFToken = h.auth.CSRFToken(r)
+
+	var buf bytes.Buffer
+	if err := h.templates.ExecuteTemplate(&buf, "tailscale.html", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
+
+// handleDevice renders the detail page for the device given by the ip query
+// parameter, with a timeline of when it was seen over the last week or month.
+func (h *Handler) handleDevice(w http.ResponseWriter, r *http.Request) {
+	lang := h.language(r)
+
+	d := h.store.GetDevice(r.URL.Query().Get("ip"))
+	if d == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	days := 7
+	if r.URL.Query().Get("days") == "30" {
+		days = 30
+	}
+	now := time.Now()
+	sightings := h.store.GetSightings(d.IP, now.AddDate(0, 0, -days))
+
+	dv := newDeviceView(lang, d)
+	data := h.newPageData(r, "")
+	data.Title = deviceTitle(dv) + " - LAN Orangutan"
+	data.Device = dv
+	data.Timeline = buildTimeline(lang, sightings, now, days)
+	data.DeepScan = h.store.GetDeepScan(d.IP)
+	dv.Risk = risk.Assess(d, data.DeepScan)
+	data.DeepScanEnabled = h.cfg.Load().VulnScan.Enable
+	data.AuthEnabled = h.auth.Enabled()
+	user := h.auth.UserFor(r)
+	data.UserName = user.Name
+	data.CanEdit = user.Role >= auth.RoleEditor
+	data.CSRFToken = h.auth.CSRFToken(r)
+
+	var buf bytes.Buffer
+	if err := h.templates.ExecuteTemplate(&buf, "device.html", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
+
+// handleReport renders the whole inventory as a page meant for printing or
+// saving as a PDF, grouped by group or, with ?by=network, by network. Each
+// device's availability covers the last week, or the day or month with
+// ?window=24h or ?window=30d.
+func (h *Handler) handleReport(w http.ResponseWriter, r *http.Request) {
+	lang := h.language(r)
+	byNetwork := r.URL.Query().Get("by") == "network"
+	window := "7d"
+	switch r.URL.Query().Get("window") {
+	case "24h", "30d":
+		window = r.URL.Query().Get("window")
+	}
+
+	var networks []types.Network
+	if byNetwork {
+		var err error
+		networks, err = network.DetectNetworks()
+		if err != nil {
+			slog.Warn("failed to detect networks for the report", "error", err)
+		}
+		networks = network.WithConfigured(networks, h.cfg.Load().Scanning.Networks)
+	}
+
+	data := h.newPageData(r, "")
+	data.Title = data.T("report.title") + " - LAN Orangutan"
+	data.Stats = h.store.GetStats()
+	data.Report = groupReport(h.deviceViews(lang), byNetwork, networks)
+	data.ReportByNetwork = byNetwork
+	data.UptimeWindow = window
+	period, _ := uptime.ParseWindow(window)
+	data.Availability = make(map[string]uptime.Availability)
+	for _, a := range uptime.ComputeAll(h.store.GetDevices(), h.store, period, time.Now()) {
+		data.Availability[a.IP] = a
+	}
+	data.GeneratedAt = time.Now().Format(i18n.T(lang, "time.datetime_format"))
+	if lastScan := h.store.GetMostRecentScan(); !lastScan.IsZero() {
+		data.LastScanAt = lastScan.Format(i18n.T(lang, "time.datetime_format"))
+	}
+	data.AuthEnabled = h.auth.Enabled()
+	user := h.auth.UserFor(r)
+	data.UserName = user.Name
+	data.CanEdit = user.Role >= auth.RoleEditor
+	data.CSRFToken = h.auth.CSRFToken(r)
+
+	var buf bytes.Buffer
+	if err := h.templates.ExecuteTemplate(&buf, "report.html", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
+
+// deviceTitle is how the device page names a device: its label if it has
+// one, then its hostname, then its address.
+func deviceTitle(d *DeviceView) string {
+	switch {
+	case d.Label != "":
+		return d.Label
+	case d.Hostname != "":
+		return d.Hostname
+	default:
+		return d.IP
+	}
+}
+
+// timeAgo returns a human-readable time difference in the given language
+func timeAgo(lang string, t time.Time) string {
 	if t.IsZero() {
-		return "never"
+		return i18n.T(lang, "time.never")
 	}
 
 	diff := time.Since(t)
 
 	switch {
 	case diff < time.Minute:
-		return "just now"
+		return i18n.T(lang, "time.just_now")
 	case diff < time.Hour:
-		mins := int(diff.Minutes())
-		if mins == 1 {
-			return "1 min ago"
-		}
-		return fmt.Sprintf("%d min ago", mins)
+		return i18n.N(lang, "time.minutes", int(diff.Minutes()))
 	case diff < 24*time.Hour:
-		hours := int(diff.Hours())
-		if hours == 1 {
-			return "1 hr ago"
-		}
-		return fmt.Sprintf("%d hr ago", hours)
+		return i18n.N(lang, "time.hours", int(diff.Hours()))
 	case diff < 7*24*time.Hour:
-		days := int(diff.Hours() / 24)
-		if days == 1 {
-			return "1 day ago"
-		}
-		return fmt.Sprintf("%d days ago", days)
+		return i18n.N(lang, "time.days", int(diff.Hours()/24))
 	default:
-		return t.Format("Jan 2, 2006")
+		return t.Format(i18n.T(lang, "time.date_format"))
 	}
 }
 
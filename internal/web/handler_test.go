@@ -1,6 +1,7 @@
 package web
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -13,6 +14,7 @@ import (
 	"github.com/291-Group/LAN-Orangutan/internal/auth"
 	"github.com/291-Group/LAN-Orangutan/internal/config"
 	"github.com/291-Group/LAN-Orangutan/internal/storage"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
 )
 
 const testPassword = "test-password"
@@ -277,6 +279,122 @@ func TestDashboardOpenWhenNoPasswordSet(t *testing.T) {
 	}
 }
 
+func TestTailscalePageRenders(t *testing.T) {
+	h, _ := newTestHandler(t, "")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tailscale", nil))
+
+	// Whether or not Tailscale is on the test machine, the page should render
+	// and explain itself rather than fail.
+	if rec.Code != http.StatusOK {
+		t.Fatalf("tailscale page status = %d, want 200\n%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDevicePageRenders(t *testing.T) {
+	h, _ := newTestHandler(t, "")
+	if err := h.store.MergeScan("192.168.1.0/24", []types.Device{{IP: "192.168.1.5", Hostname: "console"}}); err != nil {
+		t.Fatalf("MergeScan: %v", err)
+	}
+
+	for _, days := range []string{"", "30"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/device?ip=192.168.1.5&days="+days, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("device page status = %d, want 200\n%s", rec.Code, rec.Body.String())
+		}
+		if !strings.Contains(rec.Body.String(), `class="timeline-bar"`) {
+			t.Error("the device was seen by a scan, so its timeline should have a bar")
+		}
+	}
+
+	scan := types.DeepScan{IP: "192.168.1.5", Time: time.Now(), Scripts: "vulners", Findings: []types.Finding{
+		{Port: 22, Protocol: "tcp", Check: "vulners", ID: "CVE-2016-10012", Title: "Known vulnerability in OpenSSH 7.4", Severity: types.SeverityHigh, CVSS: 7.8},
+	}}
+	if err := h.store.SetDeepScan(scan); err != nil {
+		t.Fatalf("SetDeepScan: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/device?ip=192.168.1.5", nil))
+	if body := rec.Body.String(); !strings.Contains(body, `class="severity-badge high"`) || !strings.Contains(body, "CVE-2016-10012") {
+		t.Error("the device page should list the findings of its deep scan")
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if body := rec.Body.String(); !strings.Contains(body, `id="widget-vulnerable"`) || !strings.Contains(body, "CVSS 7.8") {
+		t.Error("the vulnerable devices widget should list the device")
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `data-risk="25"`) || !strings.Contains(body, `class="risk-badge medium"`) {
+		t.Error("the device table should show the device's risk score")
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/device?ip=192.168.1.99", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown device status = %d, want 404", rec.Code)
+	}
+}
+
+func TestViewerPagesLeaveOutEditing(t *testing.T) {
+	h, authn := newTestHandler(t, testPassword)
+	authn.SetRoles(auth.Roles{Viewer: []string{"staff"}})
+	if err := h.store.MergeDevices([]types.Device{{IP: "192.168.1.5", Hostname: "nas"}}); err != nil {
+		t.Fatalf("MergeDevices: %v", err)
+	}
+	viewer, _, ok := authn.SignIn("vera", []string{"staff"})
+	if !ok {
+		t.Fatal("the viewer could not sign in")
+	}
+	admin, ok := authn.Login("192.168.1.9:5000", testPassword)
+	if !ok {
+		t.Fatal("login failed")
+	}
+
+	for _, tt := range []struct {
+		token   string
+		canEdit bool
+	}{{viewer, false}, {admin, true}} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: auth.SessionCookie, Value: tt.token})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		body := rec.Body.String()
+		for _, control := range []string{"scanAllNetworks()", "editInline(this, 'label')", "bulkDelete()", "editDevice('192.168.1.5')"} {
+			if got := strings.Contains(body, control); got != tt.canEdit {
+				t.Errorf("can edit %v: page has %s = %v", tt.canEdit, control, got)
+			}
+		}
+		if got := strings.Contains(body, `data-can-edit="true"`); got != tt.canEdit {
+			t.Errorf("can edit %v: page says data-can-edit=true = %v", tt.canEdit, got)
+		}
+	}
+}
+
+func TestReportPageRenders(t *testing.T) {
+	h, _ := newTestHandler(t, "")
+	if err := h.store.MergeDevices([]types.Device{{IP: "192.168.1.5", Hostname: "nas"}}); err != nil {
+		t.Fatalf("MergeDevices: %v", err)
+	}
+
+	for _, by := range []string{"group", "network"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/report?by="+by, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("report by %s status = %d, want 200\n%s", by, rec.Code, rec.Body.String())
+		}
+		if !strings.Contains(rec.Body.String(), "192.168.1.5") {
+			t.Errorf("report by %s should list every device", by)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/report?window=24h", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "Online (day)") || !strings.Contains(body, "100.0%") {
+		t.Errorf("report over a day should show the device online all the time it was known")
+	}
+}
+
 // --- First run setup ---------------------------------------------------
 
 // newSetupHandler builds a handler in the first run state, plus a pointer to
@@ -514,3 +632,49 @@ func TestNoSetupWhenPasswordAlreadyConfigured(t *testing.T) {
 		t.Errorf("status = %d, want a redirect when a password already exists", rec.Code)
 	}
 }
+
+// --- Translation ---------------------------------------------------------
+
+func TestDashboardEmbedsScriptMessages(t *testing.T) {
+	h, _ := newTestHandler(t, "")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("dashboard status = %d, want 200", rec.Code)
+	}
+
+	body := rec.Body.String()
+	if !strings.Contains(body, `<html lang="en"`) {
+		t.Error("the page should declare the language it is rendered in")
+	}
+
+	start := strings.Index(body, `<script id="i18n" type="application/json">`)
+	if start < 0 {
+		t.Fatal("the page should embed the script's messages")
+	}
+	rest := body[start:]
+	rest = rest[strings.Index(rest, ">")+1:]
+	raw := rest[:strings.Index(rest, "</script>")]
+
+	var messages map[string]string
+	if err := json.Unmarshal([]byte(raw), &messages); err != nil {
+		t.Fatalf("embedded messages are not valid JSON: %v\n%s", err, raw)
+	}
+	if messages["js.copied"] != "Copied!" {
+		t.Errorf("js.copied = %q, want the English message", messages["js.copied"])
+	}
+}
+
+func TestUnknownLanguageCookieIsIgnored(t *testing.T) {
+	h, _ := newTestHandler(t, testPassword)
+
+	req := httptest.NewRequest(http.MethodGet, auth.LoginPath, nil)
+	req.AddCookie(&http.Cookie{Name: languageCookie, Value: "klingon"})
+	rec := httptest.NewRecorder()
+	h.HandleLogin(rec, req)
+
+	if !strings.Contains(rec.Body.String(), `<html lang="en"`) {
+		t.Error("a language that does not ship should fall back to English")
+	}
+}
@@ -0,0 +1,38 @@
+package web
+
+import "html/template"
+
+// typeIconPaths holds the inner SVG for each device type, drawn on a 24x24
+// grid in the same outline style as the rest of the dashboard's icons. They
+// are inlined rather than served as files so a long device table costs no
+// extra requests and the icons pick up the text colour of the theme.
+var typeIconPaths = map[string]string{
+	"router":  `<rect x="2" y="14" width="20" height="7" rx="2"/><path d="M6 17.5h.01M10 17.5h.01M12 14V9"/><path d="M9 6.5a4.5 4.5 0 0 1 6 0M6.5 4a8 8 0 0 1 11 0"/>`,
+	"server":  `<rect x="3" y="3" width="18" height="8" rx="2"/><rect x="3" y="13" width="18" height="8" rx="2"/><path d="M7 7h.01M7 17h.01"/>`,
+	"desktop": `<rect x="2" y="3" width="20" height="14" rx="2"/><path d="M8 21h8M12 17v4"/>`,
+	"laptop":  `<rect x="4" y="4" width="16" height="11" rx="2"/><path d="M2 19h20"/>`,
+	"phone":   `<rect x="6" y="2" width="12" height="20" rx="2"/><path d="M11 18h2"/>`,
+	"tablet":  `<rect x="4" y="2" width="16" height="20" rx="2"/><path d="M11 18h2"/>`,
+	"tv":      `<rect x="2" y="6" width="20" height="14" rx="2"/><path d="m7 2 5 4 5-4"/>`,
+	"speaker": `<rect x="5" y="2" width="14" height="20" rx="2"/><circle cx="12" cy="14" r="4"/><path d="M12 6h.01"/>`,
+	"printer": `<path d="M6 9V2h12v7"/><rect x="2" y="9" width="20" height="8" rx="2"/><path d="M6 14h12v8H6z"/>`,
+	"camera":  `<path d="M14.5 4h-5L7 7H4a2 2 0 0 0-2 2v9a2 2 0 0 0 2 2h16a2 2 0 0 0 2-2V9a2 2 0 0 0-2-2h-3z"/><circle cx="12" cy="13" r="3"/>`,
+	"console": `<path d="M6 11h4M8 9v4M15 12h.01M18 10h.01"/><path d="M17.3 5H6.7a4 4 0 0 0-4 3.6L2 15a3 3 0 0 0 5.3 2l1.7-2h6l1.7 2a3 3 0 0 0 5.3-2l-.7-6.4A4 4 0 0 0 17.3 5z"/>`,
+	"nas":     `<rect x="4" y="2" width="16" height="20" rx="2"/><path d="M8 6h8M8 10h8M8 14h8M12 18h.01"/>`,
+	"pi":      `<rect x="4" y="4" width="16" height="16" rx="2"/><rect x="9" y="9" width="6" height="6"/><path d="M9 1v3M15 1v3M9 20v3M15 20v3M20 9h3M20 14h3M1 9h3M1 14h3"/>`,
+	"vm":      `<path d="M21 8a2 2 0 0 0-1-1.7l-7-4a2 2 0 0 0-2 0l-7 4A2 2 0 0 0 3 8v8a2 2 0 0 0 1 1.7l7 4a2 2 0 0 0 2 0l7-4a2 2 0 0 0 1-1.7z"/><path d="m3.3 7 8.7 5 8.7-5M12 22V12"/>`,
+	"iot":     `<path d="M9 18h6M10 22h4"/><path d="M15 14c.2-1 .7-1.7 1.5-2.5A4.7 4.7 0 0 0 18 8 6 6 0 0 0 6 8c0 1 .2 2.2 1.5 3.5.7.7 1.3 1.5 1.5 2.5"/>`,
+	"":        `<circle cx="12" cy="12" r="9"/><path d="M12 8v4M12 16h.01"/>`,
+}
+
+// typeIcon returns the inline SVG icon for a device type. An unknown or empty
+// type gets a neutral placeholder so every row keeps the same shape.
+func typeIcon(deviceType string) template.HTML {
+	paths, ok := typeIconPaths[deviceType]
+	if !ok {
+		paths = typeIconPaths[""]
+	}
+	// Safe to mark as HTML: the markup comes from the table above, never from
+	// device data.
+	return template.HTML(`<svg class="icon" width="18" height="18" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round" aria-hidden="true">` + paths + `</svg>`)
+}
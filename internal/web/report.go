@@ -0,0 +1,82 @@
+package web
+
+import (
+	"net"
+	"sort"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// tailnetRange is the address range Tailscale hands out. Tailnet peers are
+// not on any local network, so the report gives them a section of their own.
+var tailnetRange = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// ReportSection is one heading of the printable report and the devices under
+// it. An empty Title is the catch-all for devices with no group or network.
+type ReportSection struct {
+	Title   string
+	Devices []*DeviceView
+}
+
+// groupReport sorts views into report sections, either by group or by the
+// network each device's address belongs to. Sections come out in a stable
+// order, with the catch-all last, and views keep their order within each.
+func groupReport(views []*DeviceView, byNetwork bool, networks []types.Network) []ReportSection {
+	var nets []*net.IPNet
+	var names []string
+	if byNetwork {
+		for _, n := range networks {
+			_, ipNet, err := net.ParseCIDR(n.CIDR)
+			if err != nil || n.IsTailscale {
+				continue
+			}
+			nets = append(nets, ipNet)
+			names = append(names, n.CIDR)
+		}
+	}
+
+	index := make(map[string]int)
+	var sections []ReportSection
+	var rest []*DeviceView
+	for _, dv := range views {
+		title := dv.Group
+		if byNetwork {
+			title = networkOf(dv.IP, nets, names)
+		}
+		if title == "" {
+			rest = append(rest, dv)
+			continue
+		}
+		i, ok := index[title]
+		if !ok {
+			i = len(sections)
+			index[title] = i
+			sections = append(sections, ReportSection{Title: title})
+		}
+		sections[i].Devices = append(sections[i].Devices, dv)
+	}
+
+	sort.SliceStable(sections, func(i, j int) bool { return sections[i].Title < sections[j].Title })
+	if len(rest) > 0 {
+		sections = append(sections, ReportSection{Devices: rest})
+	}
+	return sections
+}
+
+// networkOf names the network ip belongs to: one of nets, "Tailscale" for a
+// tailnet address, or "" if it is on none of them.
+func networkOf(ip string, nets []*net.IPNet, names []string) string {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return ""
+	}
+	for i, n := range nets {
+		if n.Contains(addr) {
+			return names[i]
+		}
+	}
+	if tailnetRange.Contains(addr) {
+		return "Tailscale"
+	}
+	return ""
+}
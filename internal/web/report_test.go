@@ -0,0 +1,59 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func grouped(group, ip string) *DeviceView {
+	return &DeviceView{Device: &types.Device{IP: ip, Group: group}}
+}
+
+func TestReportByGroup(t *testing.T) {
+	views := []*DeviceView{
+		grouped("Server", "192.168.1.1"),
+		grouped("", "192.168.1.2"),
+		grouped("IoT", "192.168.1.3"),
+		grouped("Server", "192.168.1.4"),
+	}
+
+	got := groupReport(views, false, nil)
+	want := []struct {
+		title string
+		count int
+	}{{"IoT", 1}, {"Server", 2}, {"", 1}}
+	if len(got) != len(want) {
+		t.Fatalf("got %d sections, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].Title != w.title || len(got[i].Devices) != w.count {
+			t.Errorf("section %d = %q with %d devices, want %q with %d", i, got[i].Title, len(got[i].Devices), w.title, w.count)
+		}
+	}
+}
+
+func TestReportByNetwork(t *testing.T) {
+	views := []*DeviceView{
+		grouped("", "192.168.1.10"),
+		grouped("", "10.0.0.5"),
+		grouped("", "100.101.102.103"),
+		grouped("", "172.16.0.1"),
+	}
+	networks := []types.Network{{CIDR: "192.168.1.0/24"}, {CIDR: "10.0.0.0/24"}}
+
+	got := groupReport(views, true, networks)
+	titles := make([]string, len(got))
+	for i, s := range got {
+		titles[i] = s.Title
+	}
+	want := []string{"10.0.0.0/24", "192.168.1.0/24", "Tailscale", ""}
+	if len(titles) != len(want) {
+		t.Fatalf("sections = %q, want %q", titles, want)
+	}
+	for i := range want {
+		if titles[i] != want[i] {
+			t.Fatalf("sections = %q, want %q", titles, want)
+		}
+	}
+}
@@ -25,6 +25,13 @@ type staticHandler struct {
 	etags map[string]string
 }
 
+// contentTypes overrides the type inferred from the extension for assets whose
+// type is missing from, or wrong in, common MIME tables.
+var contentTypes = map[string]string{
+	".webmanifest": "application/manifest+json",
+	".js":          "text/javascript; charset=utf-8",
+}
+
 // staticSubFS returns the embedded static assets rooted at their directory.
 func staticSubFS() (fs.FS, error) {
 	return fs.Sub(staticFS, "static")
@@ -75,6 +82,12 @@ func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Not every system's MIME table knows the manifest extension, and a
+	// browser ignores a manifest served as text/plain.
+	if ctype, ok := contentTypes[path.Ext(name)]; ok {
+		w.Header().Set("Content-Type", ctype)
+	}
+
 	if etag, ok := h.etags[name]; ok {
 		w.Header().Set("Etag", etag)
 		// Cache, but revalidate against the ETag every load so an update is
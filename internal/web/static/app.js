@@ -35,6 +35,31 @@ function toggleTheme() {
     if (saved) document.documentElement.setAttribute('data-theme', saved);
 })();
 
+// Translations
+
+// The server embeds this page's "js." messages as JSON in #i18n, already in
+// the right language with English filling any gaps. See internal/i18n.
+const I18N = (function () {
+    const el = document.getElementById('i18n');
+    try {
+        return el ? JSON.parse(el.textContent) : {};
+    } catch (e) {
+        return {};
+    }
+})();
+
+// t returns a message with {0}, {1}... filled from args. An unknown ID is
+// shown as is so a missing message is noticed rather than rendering nothing.
+function t(id, ...args) {
+    const msg = I18N['js.' + id] ?? id;
+    return msg.replace(/\{(\d+)\}/g, (m, i) => (i < args.length ? String(args[i]) : m));
+}
+
+// tn picks the singular or plural form of id for count, which is {0}.
+function tn(id, count, ...args) {
+    return t(id + (count === 1 ? '.one' : '.other'), count, ...args);
+}
+
 // Toast notifications
 function showToast(message, type = 'info') {
     const toast = document.getElementById('toast');
@@ -44,6 +69,18 @@ function showToast(message, type = 'info') {
     setTimeout(() => toast.classList.remove('show'), 3000);
 }
 
+// csrfHeaders returns the headers every request that changes data must carry.
+// The token is embedded in the page by the server and is empty when no
+// password is set, in which case the server does not check it.
+function csrfHeaders() {
+    const token = document.querySelector('meta[name="csrf-token"]')?.content || '';
+    return token ? { 'X-CSRF-Token': token } : {};
+}
+
+// CAN_EDIT is false for a viewer. The server leaves the controls that change
+// or scan devices out of the page; the ones built here are left out too.
+const CAN_EDIT = document.body.dataset.canEdit !== 'false';
+
 // API helper
 async function api(action, params = {}, method = 'GET') {
     let url = `/api/${action}`;
@@ -52,7 +89,7 @@ async function api(action, params = {}, method = 'GET') {
         const queryParams = Object.keys(params).map(key => `${key}=${encodeURIComponent(params[key])}`).join('&');
         if (queryParams) url += `?${queryParams}`;
     } else {
-        options.headers = { 'Content-Type': 'application/json' };
+        options.headers = { 'Content-Type': 'application/json', ...csrfHeaders() };
         options.body = JSON.stringify(params);
     }
     try {
@@ -98,7 +135,7 @@ function showScanProgress(p) {
     panel.style.display = 'flex';
 
     const title = document.getElementById('scan-title');
-    if (title) title.textContent = p.current_network ? `Scanning ${p.current_network}` : 'Starting scan...';
+    if (title) title.textContent = p.current_network ? t('scanning', p.current_network) : t('scan_starting');
 
     // percent is -1 when the network has never been scanned and there is no
     // timing history to estimate from. Show a sweeping bar rather than a
@@ -112,7 +149,7 @@ function showScanProgress(p) {
     const detail = document.getElementById('scan-detail');
     if (detail) {
         const parts = [];
-        if (p.network_count > 1) parts.push(`Network ${p.network_index} of ${p.network_count}`);
+        if (p.network_count > 1) parts.push(t('scan_network_of', p.network_index, p.network_count));
         if (p.current_network) parts.push(p.current_network);
 
         detail.textContent = parts.join(' · ');
@@ -127,15 +164,15 @@ function showScanProgress(p) {
     const count = document.getElementById('scan-count');
     if (count) {
         count.textContent = p.device_count > 0
-            ? `${p.device_count} device${p.device_count === 1 ? '' : 's'} found`
+            ? tn('scan_devices_found', p.device_count)
             : '';
     }
 
     const eta = document.getElementById('scan-eta');
     if (eta) {
         eta.textContent = known && p.remaining != null
-            ? `~${formatSeconds(p.remaining)} left · ${Math.round(p.percent)}%`
-            : `${formatSeconds(p.elapsed)} elapsed`;
+            ? t('scan_left', formatSeconds(p.remaining), Math.round(p.percent))
+            : t('scan_elapsed', formatSeconds(p.elapsed));
     }
 
     // Set expectations explicitly while there is nothing to report yet.
@@ -143,7 +180,7 @@ function showScanProgress(p) {
     if (hint) {
         hint.textContent = p.device_count > 0
             ? ''
-            : 'Checking every address on the network. Devices are listed once the sweep finishes.';
+            : t('scan_hint');
     }
 }
 
@@ -155,9 +192,9 @@ function hideScanProgress() {
 async function cancelScan() {
     try {
         await api('scan/cancel', {}, 'POST');
-        showToast('Scan cancelled', 'warning');
+        showToast(t('scan_cancelled'), 'warning');
     } catch (e) {
-        showToast('Could not cancel: ' + e.message, 'error');
+        showToast(t('scan_cancel_failed', e.message), 'error');
     }
 }
 
@@ -166,23 +203,24 @@ async function cancelScan() {
 function reportScanOutcome(p) {
     const scanned = (p.networks || []).filter(n => n.status === 'scanned');
     if (p.status === 'cancelled') {
-        showToast('Scan cancelled', 'warning');
+        showToast(t('scan_cancelled'), 'warning');
         if (scanned.length) setTimeout(refreshAfterScan, 1000);
         return;
     }
     if (scanned.length === 0) {
         const reason = (p.networks || []).find(n => n.error)?.error;
-        showToast(reason ? 'Scan failed: ' + reason : 'No networks could be scanned', 'warning');
+        showToast(reason ? t('scan_failed', reason) : t('scan_nothing'), 'warning');
         return;
     }
-    const where = p.network_count > 1 ? ` across ${scanned.length} network${scanned.length === 1 ? '' : 's'}` : '';
-    showToast(`Found ${p.device_count} device${p.device_count === 1 ? '' : 's'}${where}`, 'success');
+    const where = p.network_count > 1 ? tn('scan_across', scanned.length) : '';
+    showToast(tn('scan_found', p.device_count) + where, 'success');
     setTimeout(refreshAfterScan, 1000);
 }
 
 // refreshAfterScan brings in the newly discovered devices without throwing away
 // the user's scroll position, search text or filters.
 async function refreshAfterScan() {
+    refreshEventBadge();
     if (!(await refreshInPlace())) location.reload();
 }
 
@@ -210,7 +248,7 @@ async function runScan(target) {
     } catch (e) {
         hideScanProgress();
         const isRateLimit = e.message.toLowerCase().includes('rate limit');
-        showToast(isRateLimit ? e.message : 'Scan failed: ' + e.message, isRateLimit ? 'warning' : 'error');
+        showToast(isRateLimit ? e.message : t('scan_failed', e.message), isRateLimit ? 'warning' : 'error');
     }
 }
 
@@ -247,14 +285,15 @@ function filterDevices() {
 
     let visible = 0;
     document.querySelectorAll('.device-row').forEach(row => {
-        const text = [row.dataset.ip, row.dataset.hostname, row.dataset.mac, row.dataset.vendor, row.dataset.label].join(' ').toLowerCase();
+        const text = [row.dataset.ip, row.dataset.hostname, row.dataset.mac, row.dataset.vendor, row.dataset.label, row.dataset.typeName, row.dataset.tags].join(' ').toLowerCase();
         const status = row.dataset.status;
         const group = row.dataset.group || '';
 
         const matchSearch = !search || text.includes(search);
         const matchStatus = statusFilter === 'all' ||
             (statusFilter === 'online' && (status === 'online' || status === 'recent')) ||
-            (statusFilter === 'offline' && status === 'offline');
+            (statusFilter === 'offline' && status === 'offline') ||
+            (statusFilter === 'pending' && row.dataset.pending === 'true');
         const matchGroup = groupFilter === 'all' || group === groupFilter;
 
         const show = matchSearch && matchStatus && matchGroup;
@@ -263,7 +302,8 @@ function filterDevices() {
     });
 
     const countEl = document.getElementById('device-count');
-    if (countEl) countEl.textContent = `Showing ${visible} device${visible === 1 ? '' : 's'}`;
+    if (countEl) countEl.textContent = tn('showing', visible);
+    updateSelection();
 }
 
 // Device editing
@@ -275,53 +315,57 @@ function editDevice(ip) {
     document.getElementById('edit-ip-display').value = ip;
     document.getElementById('edit-label').value = row.dataset.labelOriginal || '';
     document.getElementById('edit-group').value = row.dataset.group || '';
+    // Offer "automatic" with what it would pick, so choosing it is not a leap
+    // in the dark.
+    document.getElementById('edit-type-auto').textContent = t('type_auto', row.dataset.typeDetected || '');
+    document.getElementById('edit-type').value = row.dataset.typeManual || '';
     document.getElementById('edit-notes').value = row.dataset.notes || '';
     modal.style.display = 'flex';
 }
 
 function closeModal() {
-    const modal = document.getElementById('edit-modal');
-    if (modal) modal.style.display = 'none';
+    document.querySelectorAll('.modal').forEach(modal => { modal.style.display = 'none'; });
 }
 
 async function saveDevice() {
     const ip = document.getElementById('edit-ip').value;
     const label = document.getElementById('edit-label').value;
     const group = document.getElementById('edit-group').value;
+    const type = document.getElementById('edit-type').value;
     const notes = document.getElementById('edit-notes').value;
     try {
-        const result = await api('device', { ip, label, group, notes }, 'POST');
+        const result = await api('device', { ip, label, group, type, notes }, 'POST');
         if (result.success) {
-            showToast('Device updated', 'success');
+            showToast(t('device_updated'), 'success');
             closeModal();
             // Keep the user where they were; editing a device halfway down a
             // long list should not jump back to the top.
             if (!(await refreshInPlace())) location.reload();
         } else {
-            showToast(result.error || 'Failed to update device', 'error');
+            showToast(result.error || t('device_update_failed'), 'error');
         }
     } catch (e) {
-        showToast('Error: ' + e.message, 'error');
+        showToast(t('error', e.message), 'error');
     }
 }
 
 async function deleteDevice(ip) {
-    if (!confirm(`Delete device ${ip}?`)) return;
+    if (!confirm(t('delete_confirm', ip))) return;
     try {
         const response = await fetch(`/api/device?ip=${encodeURIComponent(ip)}`, {
             method: 'DELETE',
-            headers: { 'Content-Type': 'application/json' }
+            headers: { 'Content-Type': 'application/json', ...csrfHeaders() }
         });
         const result = await response.json();
         if (result.success) {
-            showToast('Device deleted', 'success');
+            showToast(t('device_deleted'), 'success');
             document.querySelector(`.device-row[data-ip="${CSS.escape(ip)}"]`)?.remove();
             filterDevices(); // Update count
         } else {
-            showToast(result.error || 'Failed to delete', 'error');
+            showToast(result.error || t('delete_failed'), 'error');
         }
     } catch (e) {
-        showToast('Error: ' + e.message, 'error');
+        showToast(t('error', e.message), 'error');
     }
 }
 
@@ -331,37 +375,352 @@ async function updateDeviceGroup(select) {
     try {
         const result = await api('device', { ip, group }, 'POST');
         if (result.success) {
-            showToast('Group updated', 'success');
+            showToast(t('group_updated'), 'success');
             // Update data attribute
             const row = select.closest('.device-row');
             if (row) row.dataset.group = group;
         } else {
-            showToast(result.error || 'Failed to update', 'error');
+            showToast(result.error || t('group_update_failed'), 'error');
         }
     } catch (e) {
-        showToast('Failed to update group', 'error');
+        showToast(t('group_update_failed'), 'error');
+    }
+}
+
+// --- Inline editing ---------------------------------------------------
+//
+// The label and notes can be changed straight from the table. Clicking either
+// swaps in an editor; Enter (Ctrl+Enter for notes) or moving away saves it,
+// Escape puts things back as they were.
+
+function editInline(trigger, field) {
+    const row = trigger.closest('.device-row');
+    const cell = trigger.closest('td');
+    if (!row || !cell || cell.querySelector('.inline-editor')) return;
+
+    const original = field === 'notes' ? (row.dataset.notes || '') : (row.dataset.labelOriginal || '');
+    const editor = document.createElement(field === 'notes' ? 'textarea' : 'input');
+    editor.className = 'inline-editor';
+    editor.value = original;
+    if (field === 'notes') {
+        editor.rows = 3;
+        editor.placeholder = t('notes_placeholder');
+    } else {
+        editor.type = 'text';
+        editor.placeholder = t('label_placeholder');
     }
+
+    const saved = [...cell.childNodes];
+    let done = false;
+    const finish = async save => {
+        if (done) return;
+        done = true;
+        const value = editor.value.trim();
+        cell.replaceChildren(...saved);
+        if (!save || value === original) return;
+        try {
+            await api('device', { ip: row.dataset.ip, [field]: value }, 'POST');
+            showToast(t('device_updated'), 'success');
+            if (!(await refreshInPlace())) location.reload();
+        } catch (e) {
+            showToast(t('error', e.message), 'error');
+        }
+    };
+
+    editor.addEventListener('keydown', e => {
+        e.stopPropagation();
+        if (e.key === 'Escape') {
+            finish(false);
+        } else if (e.key === 'Enter' && (field !== 'notes' || e.ctrlKey || e.metaKey)) {
+            e.preventDefault();
+            finish(true);
+        }
+    });
+    editor.addEventListener('blur', () => finish(true));
+    editor.addEventListener('click', e => e.stopPropagation());
+
+    cell.replaceChildren(editor);
+    editor.focus();
+    editor.select();
 }
 
+document.addEventListener('keydown', e => {
+    // The label and notes triggers are focusable, so open them from the
+    // keyboard as well.
+    if (e.key === 'Enter' && e.target.matches('.inline-edit, .notes-indicator')) {
+        e.target.click();
+    }
+});
+
 // Copy to clipboard
 function copyToClipboard(text, event) {
     navigator.clipboard.writeText(text).then(() => {
         // Show feedback at cursor position
         const feedback = document.createElement('div');
         feedback.className = 'copy-feedback';
-        feedback.textContent = 'Copied!';
+        feedback.textContent = t('copied');
         feedback.style.left = event.pageX + 'px';
         feedback.style.top = (event.pageY - 30) + 'px';
         document.body.appendChild(feedback);
         setTimeout(() => feedback.remove(), 800);
     }).catch(() => {
-        showToast('Failed to copy', 'error');
+        showToast(t('copy_failed'), 'error');
     });
 }
 
+// --- Notifications ---------------------------------------------------
+//
+// The bell lists recent events from /api/events. The unread count is polled
+// so a change found by a scan started elsewhere still turns up. Alerts
+// escalating to more people until someone acknowledges them come first.
+
+const EVENTS_POLL_MS = 60000;
+
+function eventText(e) {
+    switch (e.type) {
+        case 'device_new': return t('event_device_new', e.name || e.ip);
+        case 'device_offline': return t('event_device_offline', e.name || e.ip);
+        case 'scan_failed': return t('event_scan_failed', e.network);
+        default: return e.message;
+    }
+}
+
+// eventMeta is the smaller second line: where, and when.
+function eventMeta(e) {
+    const parts = [];
+    if (e.type === 'scan_failed' && e.detail) parts.push(e.detail);
+    else if (e.ip && e.ip !== e.name) parts.push(e.ip);
+    parts.push(relativeTime(Math.floor(new Date(e.time).getTime() / 1000)));
+    return parts.join(' · ');
+}
+
+function setUnreadBadge(count) {
+    const badge = document.getElementById('notif-badge');
+    if (!badge) return;
+    badge.textContent = count > 99 ? '99+' : String(count);
+    badge.hidden = count === 0;
+}
+
+async function refreshEventBadge() {
+    try {
+        const result = await api('events', { limit: 1, unread: 'true' });
+        setUnreadBadge(result.data.unread);
+    } catch (e) {
+        // The badge is a nicety; a failed poll is not worth a toast.
+    }
+}
+
+async function loadEvents() {
+    const list = document.getElementById('notif-list');
+    if (!list) return;
+    try {
+        const result = await api('events', { limit: 30 });
+        setUnreadBadge(result.data.unread);
+        list.replaceChildren();
+        await appendEscalations(list);
+        const events = result.data.events || [];
+        if (events.length === 0) {
+            const empty = document.createElement('div');
+            empty.className = 'notif-empty';
+            empty.textContent = t('events_empty');
+            list.appendChild(empty);
+            return;
+        }
+        for (const e of events) {
+            const item = document.createElement('button');
+            item.type = 'button';
+            item.className = 'notif-item' + (e.read ? '' : ' unread');
+            item.textContent = eventText(e);
+            const meta = document.createElement('span');
+            meta.className = 'notif-item-meta';
+            meta.textContent = eventMeta(e);
+            item.appendChild(meta);
+            if (!e.read) {
+                item.addEventListener('click', async () => {
+                    item.classList.remove('unread');
+                    await markEventsRead([e.id]);
+                });
+            }
+            list.appendChild(item);
+        }
+    } catch (e) {
+        showToast(t('events_failed'), 'error');
+    }
+}
+
+// appendEscalations adds the escalations waiting for acknowledgement to list,
+// each with a button that stops it going on to the next person.
+async function appendEscalations(list) {
+    let escalations;
+    try {
+        escalations = (await api('escalations')).data || [];
+    } catch (e) {
+        return; // The events still show.
+    }
+    for (const esc of escalations) {
+        const item = document.createElement('div');
+        item.className = 'notif-item escalation';
+        item.textContent = eventText(esc.event);
+        const meta = document.createElement('span');
+        meta.className = 'notif-item-meta';
+        meta.textContent = t('escalation_waiting', relativeTime(Math.floor(new Date(esc.started).getTime() / 1000)));
+        item.appendChild(meta);
+        if (!CAN_EDIT) {
+            list.appendChild(item);
+            continue;
+        }
+        const ack = document.createElement('button');
+        ack.type = 'button';
+        ack.className = 'notif-ack';
+        ack.textContent = t('escalation_ack');
+        ack.addEventListener('click', async (event) => {
+            event.stopPropagation();
+            try {
+                await api('escalations/ack', { id: esc.id }, 'POST');
+                item.remove();
+                showToast(t('escalation_acked'), 'success');
+            } catch (e) {
+                showToast(t('error', e.message), 'error');
+            }
+        });
+        item.appendChild(ack);
+        list.appendChild(item);
+    }
+}
+
+function toggleNotifications(event) {
+    event.stopPropagation();
+    const panel = document.getElementById('notif-panel');
+    if (!panel) return;
+    if (!panel.classList.contains('show')) loadEvents();
+    toggleDropdown('notif-panel');
+}
+
+// markEventsRead marks the given events read, or all of them when called
+// without any.
+async function markEventsRead(ids) {
+    try {
+        const result = await api('events/read', ids ? { ids } : {}, 'POST');
+        setUnreadBadge(result.data.unread);
+        if (!ids) {
+            document.querySelectorAll('.notif-item.unread').forEach(el => el.classList.remove('unread'));
+        }
+    } catch (e) {
+        showToast(t('error', e.message), 'error');
+    }
+}
+
+if (document.getElementById('notif-badge')) {
+    setInterval(refreshEventBadge, EVENTS_POLL_MS);
+}
+
+// --- Row actions -----------------------------------------------------
+//
+// The "more" button on each row opens one shared menu, built from what the
+// row knows about its device. Only actions that can work are offered: there is
+// no "Copy MAC" without a MAC, and no SSH link for a phone.
+
+// Device types that are unlikely to run an SSH server. Anything else,
+// including a device of unknown type, gets the link.
+const NO_SSH_TYPES = ['phone', 'tablet', 'tv', 'speaker', 'printer', 'camera', 'console', 'iot'];
+
+function rowMenuItems(row) {
+    const ip = row.dataset.ip;
+    const mac = row.dataset.macOriginal || '';
+    const items = [
+        { label: t('action_details'), href: `/device?ip=${encodeURIComponent(ip)}`, local: true },
+        { label: t('action_open_http'), href: `http://${ip}/` },
+        { label: t('action_open_https'), href: `https://${ip}/` },
+    ];
+    if (!NO_SSH_TYPES.includes(row.dataset.type)) {
+        items.push({ label: t('action_ssh'), href: `ssh://${ip}` });
+    }
+    items.push({ label: t('action_copy_ip'), run: e => copyToClipboard(ip, e) });
+    if (mac) {
+        items.push({ label: t('action_copy_mac'), run: e => copyToClipboard(mac, e) });
+        if (CAN_EDIT) items.push({ label: t('action_wake'), run: () => wakeDevice(ip) });
+    }
+    return items;
+}
+
+function openRowMenu(ip, button, event) {
+    event.stopPropagation();
+    const menu = document.getElementById('row-menu');
+    const row = document.querySelector(`.device-row[data-ip="${CSS.escape(ip)}"]`);
+    if (!menu || !row) return;
+
+    // Clicking the same button again closes the menu.
+    if (menu.classList.contains('show') && menu.dataset.ip === ip) {
+        closeRowMenu();
+        return;
+    }
+
+    menu.replaceChildren();
+    for (const item of rowMenuItems(row)) {
+        const el = document.createElement('a');
+        el.className = 'dropdown-item';
+        el.textContent = item.label;
+        if (item.href) {
+            el.href = item.href;
+            if (!item.local) {
+                el.target = '_blank';
+                el.rel = 'noopener noreferrer';
+            }
+            el.addEventListener('click', closeRowMenu);
+        } else {
+            el.addEventListener('click', e => { closeRowMenu(); item.run(e); });
+        }
+        menu.appendChild(el);
+    }
+
+    // Fixed positioning keeps the menu clear of the table's scroll container,
+    // which would otherwise clip it on the last few rows.
+    const rect = button.getBoundingClientRect();
+    menu.dataset.ip = ip;
+    menu.classList.add('show');
+    const top = rect.bottom + menu.offsetHeight > window.innerHeight
+        ? rect.top - menu.offsetHeight - 4
+        : rect.bottom + 4;
+    menu.style.top = `${Math.max(4, top)}px`;
+    menu.style.left = `${Math.max(4, rect.right - menu.offsetWidth)}px`;
+}
+
+function closeRowMenu() {
+    const menu = document.getElementById('row-menu');
+    if (menu) menu.classList.remove('show');
+}
+
+document.addEventListener('click', e => {
+    const menu = document.getElementById('row-menu');
+    if (menu && !menu.contains(e.target)) closeRowMenu();
+});
+window.addEventListener('scroll', closeRowMenu, { passive: true });
+
+async function wakeDevice(ip) {
+    try {
+        await api('device/wake', { ip }, 'POST');
+        showToast(t('wake_sent', ip), 'success');
+    } catch (e) {
+        showToast(t('wake_failed', e.message), 'error');
+    }
+}
+
+// Tailscale peers page: copy a peer into the device inventory under a label.
+async function promotePeer(ip, name) {
+    const label = prompt(t('promote_prompt', name), name);
+    if (label === null) return;
+    try {
+        await api('tailscale/promote', { ip, label: label.trim() }, 'POST');
+        showToast(t('promoted', label.trim() || ip), 'success');
+        setTimeout(() => window.location.reload(), 600);
+    } catch (e) {
+        showToast(t('promote_failed', e.message), 'error');
+    }
+}
+
 // Export devices
-function exportDevices(format) {
-    const rows = document.querySelectorAll('.device-row');
+function exportDevices(format, selectedOnly = false) {
+    const rows = selectedOnly ? selectedRows() : document.querySelectorAll('.device-row');
     const devices = [];
 
     rows.forEach(row => {
@@ -373,6 +732,7 @@ function exportDevices(format) {
                 vendor: row.querySelector('.vendor-cell')?.textContent?.trim() || '',
                 label: row.dataset.labelOriginal || '',
                 group: row.dataset.group || '',
+                tags: [...row.querySelectorAll('.tag-chip')].map(chip => chip.textContent).join('; '),
                 status: row.dataset.status || ''
             });
         }
@@ -381,10 +741,10 @@ function exportDevices(format) {
     let content, filename, type;
 
     if (format === 'csv') {
-        const headers = ['IP', 'Hostname', 'MAC', 'Vendor', 'Label', 'Group', 'Status'];
+        const headers = ['IP', 'Hostname', 'MAC', 'Vendor', 'Label', 'Group', 'Tags', 'Status'];
         const csvRows = [headers.join(',')];
         devices.forEach(d => {
-            csvRows.push([d.ip, d.hostname, d.mac, d.vendor, d.label, d.group, d.status]
+            csvRows.push([d.ip, d.hostname, d.mac, d.vendor, d.label, d.group, d.tags, d.status]
                 .map(v => `"${(v || '').replace(/"/g, '""')}"`)
                 .join(','));
         });
@@ -405,8 +765,141 @@ function exportDevices(format) {
     a.click();
     URL.revokeObjectURL(url);
 
-    toggleDropdown('export-menu');
-    showToast(`Exported ${devices.length} devices`, 'success');
+    if (!selectedOnly) toggleDropdown('export-menu');
+    showToast(tn('exported', devices.length), 'success');
+}
+
+// --- Bulk selection ---------------------------------------------------
+//
+// Ticked rows that a search or filter has hidden are left out, so an action
+// never reaches devices the user cannot currently see.
+
+function selectedRows() {
+    return [...document.querySelectorAll('.device-row')].filter(row =>
+        row.style.display !== 'none' && row.querySelector('.row-select')?.checked);
+}
+
+function updateSelection() {
+    const rows = selectedRows();
+    document.querySelectorAll('.device-row').forEach(row =>
+        row.classList.toggle('selected', rows.includes(row)));
+
+    const bar = document.getElementById('bulk-bar');
+    if (bar) bar.hidden = rows.length === 0;
+    const count = document.getElementById('bulk-count');
+    if (count) count.textContent = tn('selected', rows.length);
+
+    const all = document.getElementById('select-all');
+    if (all) {
+        const visible = [...document.querySelectorAll('.device-row')].filter(row => row.style.display !== 'none');
+        all.checked = rows.length > 0 && rows.length === visible.length;
+        all.indeterminate = rows.length > 0 && rows.length < visible.length;
+    }
+}
+
+function toggleSelectAll(box) {
+    document.querySelectorAll('.device-row').forEach(row => {
+        if (row.style.display === 'none') return;
+        const check = row.querySelector('.row-select');
+        if (check) check.checked = box.checked;
+    });
+    updateSelection();
+}
+
+function clearSelection() {
+    document.querySelectorAll('.row-select').forEach(check => { check.checked = false; });
+    updateSelection();
+}
+
+async function bulkAction(action, value = '') {
+    const ips = selectedRows().map(row => row.dataset.ip);
+    if (ips.length === 0) return;
+    try {
+        const result = await api('devices/batch', { ips, action, value }, 'POST');
+        showToast(tn('bulk_done', result.data?.updated ?? ips.length), 'success');
+        if (action === 'delete') clearSelection();
+        if (!(await refreshInPlace())) location.reload();
+    } catch (e) {
+        showToast(t('error', e.message), 'error');
+    }
+}
+
+function bulkTag(action) {
+    const input = document.getElementById('bulk-tag');
+    const tag = (input?.value || '').trim();
+    if (!tag) {
+        input?.focus();
+        return;
+    }
+    bulkAction(action, tag);
+}
+
+function bulkDelete() {
+    const count = selectedRows().length;
+    if (count === 0 || !confirm(tn('bulk_delete_confirm', count))) return;
+    bulkAction('delete');
+}
+
+// approveDevices approves the devices at ips, which then leave the list of
+// devices waiting for approval.
+async function approveDevices(ips) {
+    if (ips.length === 0) return;
+    try {
+        const result = await api('devices/batch', { ips, action: 'approve' }, 'POST');
+        showToast(tn('approved', result.data?.updated ?? ips.length), 'success');
+        if (!(await refreshInPlace())) location.reload();
+    } catch (e) {
+        showToast(t('error', e.message), 'error');
+    }
+}
+
+function approveAllPending() {
+    const ips = [...document.querySelectorAll('#pending-devices .pending-row')].map(row => row.dataset.ip);
+    approveDevices(ips);
+}
+
+// deletePending removes a device nobody recognises. If it is still there it
+// turns up again with the next scan, waiting for approval once more.
+async function deletePending(ip) {
+    if (!confirm(t('pending_delete_confirm', ip))) return;
+    try {
+        await api('devices/batch', { ips: [ip], action: 'delete' }, 'POST');
+        if (!(await refreshInPlace())) location.reload();
+    } catch (e) {
+        showToast(t('error', e.message), 'error');
+    }
+}
+
+// startDeepScan has the server deep scan the device at ip, then reloads the
+// page with what it found. The scan runs on the server, so leaving the page
+// does not stop it.
+async function startDeepScan(ip) {
+    const button = document.getElementById('deep-scan-button');
+    try {
+        await api('vulnscan', { ips: [ip] }, 'POST');
+    } catch (e) {
+        showToast(t('error', e.message), 'error');
+        return;
+    }
+    if (button) button.disabled = true;
+    showToast(t('deep_scan_started'), 'info');
+    for (;;) {
+        await new Promise(resolve => setTimeout(resolve, 5000));
+        let status;
+        try {
+            status = (await api('vulnscan')).data;
+        } catch (e) {
+            continue;
+        }
+        if (status.running) continue;
+        if (status.error) {
+            showToast(t('deep_scan_failed', status.error), 'error');
+            if (button) button.disabled = false;
+            return;
+        }
+        location.reload();
+        return;
+    }
 }
 
 // Dropdown toggle
@@ -471,6 +964,11 @@ function sortTable(column) {
                 valA = order[a.dataset.status] ?? 3;
                 valB = order[b.dataset.status] ?? 3;
                 break;
+            case 'risk':
+                // Riskiest first
+                valA = -(parseInt(a.dataset.risk) || 0);
+                valB = -(parseInt(b.dataset.risk) || 0);
+                break;
             case 'lastseen':
                 valA = parseInt(a.dataset.lastseen) || 0;
                 valB = parseInt(b.dataset.lastseen) || 0;
@@ -509,6 +1007,10 @@ let autoRefreshInterval = null;
 // Returns false if the refresh could not be applied, which usually means the
 // session has ended and the response was the login page.
 async function refreshInPlace() {
+    // Replacing the rows would throw away whatever is being typed into an
+    // inline editor; skip this round and catch up on the next.
+    if (document.querySelector('#devices-tbody .inline-editor')) return true;
+
     let doc;
     try {
         const response = await fetch(location.href, { credentials: 'same-origin' });
@@ -521,9 +1023,14 @@ async function refreshInPlace() {
     const freshRows = doc.getElementById('devices-tbody');
     if (!freshRows) return false;
 
+    // Keep ticked rows ticked across the refresh.
+    const selected = new Set(selectedRows().map(row => row.dataset.ip));
+    freshRows.querySelectorAll('.row-select').forEach(check => {
+        check.checked = selected.has(check.value);
+    });
     document.getElementById('devices-tbody')?.replaceWith(freshRows);
 
-    for (const selector of ['.stats-bar', '.table-footer', '#device-count']) {
+    for (const selector of ['#pending-devices', '.stats-bar', '.table-footer', '#device-count', '#widget-new-devices', '#widget-offline', '#widget-vendors', '#widget-vulnerable', '#widget-certs']) {
         const current = document.querySelector(selector);
         const replacement = doc.querySelector(selector);
         if (current && replacement) current.replaceWith(replacement);
@@ -559,7 +1066,7 @@ function toggleAutoRefresh() {
         startAutoRefresh();
         toggle.classList.add('active');
         localStorage.setItem('autoRefresh', 'true');
-        showToast('Auto-refresh enabled (30s)', 'info');
+        showToast(t('auto_refresh_on'), 'info');
     }
 }
 
@@ -576,8 +1083,17 @@ function toggleAutoRefresh() {
 
 // Keyboard shortcuts
 document.addEventListener('keydown', e => {
+    // Ctrl+K (Cmd+K on a Mac) works from anywhere, even inside a text box,
+    // since that is where people reach for it.
+    if ((e.ctrlKey || e.metaKey) && e.key.toLowerCase() === 'k') {
+        e.preventDefault();
+        openPalette();
+        return;
+    }
+
     // Ignore if typing in input
     if (e.target.matches('input, textarea, select')) return;
+    if (e.ctrlKey || e.metaKey || e.altKey) return;
 
     switch (e.key.toLowerCase()) {
         case '/':
@@ -587,15 +1103,318 @@ document.addEventListener('keydown', e => {
         case 'r':
             refreshAfterScan();
             break;
+        case 's':
+            scanAllNetworks();
+            break;
         case 't':
             toggleTheme();
             break;
+        case 'o':
+            cycleStatusFilter();
+            break;
+        case 'c':
+            clearFilters();
+            break;
+        case '?':
+            openPalette();
+            break;
         case 'escape':
             closeModal();
+            closeRowMenu();
             break;
     }
 });
 
+// cycleStatusFilter steps the status filter through all, online and offline.
+function cycleStatusFilter() {
+    const select = document.getElementById('device-filter');
+    if (!select) return;
+    const values = [...select.options].map(o => o.value);
+    select.value = values[(values.indexOf(select.value) + 1) % values.length];
+    filterDevices();
+    showToast(select.options[select.selectedIndex].text, 'info');
+}
+
+function setFilter(id, value) {
+    const select = document.getElementById(id);
+    if (!select) return;
+    select.value = value;
+    filterDevices();
+}
+
+function clearFilters() {
+    const search = document.getElementById('device-search');
+    if (search) search.value = '';
+    for (const id of ['device-filter', 'group-filter']) {
+        const select = document.getElementById(id);
+        if (select) select.value = 'all';
+    }
+    filterDevices();
+}
+
+// --- Dashboard widgets ------------------------------------------------
+//
+// The server renders every widget. Which ones show and in what order is a
+// matter of taste rather than data, so it is kept in this browser's
+// localStorage as a list of {id, visible}, in display order. Widgets the saved
+// layout does not know about, such as ones added by an upgrade, keep their
+// default and go at the end.
+
+const WIDGETS_KEY = 'dashboardWidgets';
+
+function widgetSections() {
+    return [...document.querySelectorAll('#widgets > .widget')];
+}
+
+function savedWidgetLayout() {
+    try {
+        const layout = JSON.parse(localStorage.getItem(WIDGETS_KEY) || 'null');
+        return Array.isArray(layout) ? layout : null;
+    } catch (e) {
+        return null;
+    }
+}
+
+function applyWidgetLayout() {
+    const container = document.getElementById('widgets');
+    if (!container) return;
+    const layout = savedWidgetLayout();
+    if (!layout) return;
+
+    const sections = new Map(widgetSections().map(el => [el.dataset.widget, el]));
+    for (const { id, visible } of layout) {
+        const el = sections.get(id);
+        if (!el) continue;
+        el.hidden = !visible;
+        container.appendChild(el);
+        sections.delete(id);
+    }
+    // Anything left over is new since the layout was saved.
+    sections.forEach(el => container.appendChild(el));
+}
+
+function saveWidgetLayout() {
+    const layout = widgetSections().map(el => ({ id: el.dataset.widget, visible: !el.hidden }));
+    localStorage.setItem(WIDGETS_KEY, JSON.stringify(layout));
+}
+
+function openWidgetSettings() {
+    renderWidgetSettings();
+    const modal = document.getElementById('widget-modal');
+    if (modal) modal.style.display = 'flex';
+}
+
+function renderWidgetSettings() {
+    const list = document.getElementById('widget-settings');
+    if (!list) return;
+    list.replaceChildren();
+
+    const sections = widgetSections();
+    sections.forEach((el, i) => {
+        const item = document.createElement('li');
+        item.className = 'widget-setting';
+
+        const label = document.createElement('label');
+        const check = document.createElement('input');
+        check.type = 'checkbox';
+        check.checked = !el.hidden;
+        check.addEventListener('change', () => {
+            el.hidden = !check.checked;
+            saveWidgetLayout();
+        });
+        label.append(check, ' ', el.dataset.title);
+
+        const move = (step, text, title) => {
+            const button = document.createElement('button');
+            button.type = 'button';
+            button.className = 'btn-icon';
+            button.textContent = text;
+            button.title = title;
+            const target = sections[i + step];
+            button.disabled = !target;
+            button.addEventListener('click', () => {
+                if (step < 0) target.before(el); else target.after(el);
+                saveWidgetLayout();
+                renderWidgetSettings();
+            });
+            return button;
+        };
+
+        item.append(label, move(-1, '↑', t('widget_up')), move(1, '↓', t('widget_down')));
+        list.appendChild(item);
+    });
+}
+
+function resetWidgets() {
+    localStorage.removeItem(WIDGETS_KEY);
+    location.reload();
+}
+
+applyWidgetLayout();
+
+// --- Command palette --------------------------------------------------
+//
+// Ctrl+K opens a search box over everything the dashboard can do, plus every
+// device by name or address. It is built here rather than in each template so
+// it is the same on every page.
+
+let paletteDevices = null;
+let paletteItems = [];
+let paletteIndex = 0;
+
+function paletteCommands() {
+    const onDashboard = !!document.getElementById('devices-tbody');
+    const commands = [
+        { label: t('cmd_dashboard'), hint: '', run: () => { location.href = '/'; } },
+        { label: t('cmd_tailscale'), hint: '', run: () => { location.href = '/tailscale'; } },
+        { label: t('cmd_settings'), hint: '', run: () => { location.href = '/settings'; } },
+        { label: t('cmd_report'), hint: '', run: () => { location.href = '/report'; } },
+        { label: t('cmd_scan_all'), hint: 'S', run: scanAllNetworks },
+        { label: t('cmd_theme'), hint: 'T', run: toggleTheme },
+    ];
+    if (onDashboard) {
+        commands.push(
+            { label: t('cmd_search'), hint: '/', run: () => document.getElementById('device-search')?.focus() },
+            { label: t('cmd_refresh'), hint: 'R', run: refreshAfterScan },
+            { label: t('cmd_auto_refresh'), hint: '', run: toggleAutoRefresh },
+            { label: t('cmd_show_online'), hint: 'O', run: () => setFilter('device-filter', 'online') },
+            { label: t('cmd_show_offline'), hint: 'O', run: () => setFilter('device-filter', 'offline') },
+            { label: t('cmd_clear_filters'), hint: 'C', run: clearFilters },
+        );
+        document.querySelectorAll('#group-filter option').forEach(option => {
+            if (option.value === 'all') return;
+            commands.push({ label: t('cmd_show_group', option.text), hint: '', run: () => setFilter('group-filter', option.value) });
+        });
+    }
+    return commands;
+}
+
+function ensurePalette() {
+    let palette = document.getElementById('palette');
+    if (palette) return palette;
+
+    palette = document.createElement('div');
+    palette.id = 'palette';
+    palette.className = 'palette-backdrop';
+    palette.hidden = true;
+    palette.innerHTML = '<div class="palette" role="dialog" aria-modal="true">' +
+        '<input type="text" class="palette-input" autocomplete="off" spellcheck="false">' +
+        '<div class="palette-list" role="listbox"></div></div>';
+    document.body.appendChild(palette);
+
+    const input = palette.querySelector('.palette-input');
+    input.placeholder = t('palette_placeholder');
+    input.setAttribute('aria-label', t('palette_placeholder'));
+    input.addEventListener('input', () => renderPalette(input.value));
+    input.addEventListener('keydown', e => {
+        if (e.key === 'ArrowDown' || e.key === 'ArrowUp') {
+            e.preventDefault();
+            const step = e.key === 'ArrowDown' ? 1 : -1;
+            paletteIndex = (paletteIndex + step + paletteItems.length) % Math.max(paletteItems.length, 1);
+            highlightPalette();
+        } else if (e.key === 'Enter') {
+            e.preventDefault();
+            runPaletteItem(paletteIndex);
+        } else if (e.key === 'Escape') {
+            closePalette();
+        }
+    });
+    palette.addEventListener('click', e => {
+        if (e.target === palette) closePalette();
+    });
+    return palette;
+}
+
+async function openPalette() {
+    const palette = ensurePalette();
+    const input = palette.querySelector('.palette-input');
+    palette.hidden = false;
+    input.value = '';
+    renderPalette('');
+    input.focus();
+
+    // Fetched once per page, and only when the palette is first used.
+    if (paletteDevices === null) {
+        paletteDevices = [];
+        try {
+            const result = await api('devices');
+            paletteDevices = Object.values(result.data || {}).map(d => ({
+                label: d.label || d.hostname || d.ip,
+                hint: d.ip,
+                search: [d.label, d.hostname, d.ip, ...(d.tags || [])].join(' ').toLowerCase(),
+                run: () => { location.href = `/device?ip=${encodeURIComponent(d.ip)}`; },
+            }));
+        } catch (e) {
+            // The commands still work without the device list.
+        }
+        if (!palette.hidden) renderPalette(input.value);
+    }
+}
+
+function closePalette() {
+    const palette = document.getElementById('palette');
+    if (palette) palette.hidden = true;
+}
+
+function renderPalette(query) {
+    const q = query.trim().toLowerCase();
+    const commands = paletteCommands().filter(c => !q || c.label.toLowerCase().includes(q));
+    // Devices only appear once something is typed; listing them all up front
+    // would bury the commands on a busy network.
+    const devices = q ? (paletteDevices || []).filter(d => d.search.includes(q)).slice(0, 20) : [];
+    paletteItems = [...commands, ...devices];
+    paletteIndex = 0;
+
+    const list = document.querySelector('#palette .palette-list');
+    list.replaceChildren();
+    if (paletteItems.length === 0) {
+        const empty = document.createElement('div');
+        empty.className = 'palette-empty';
+        empty.textContent = t('palette_empty');
+        list.appendChild(empty);
+        return;
+    }
+    paletteItems.forEach((item, i) => {
+        const el = document.createElement('div');
+        el.className = 'palette-item';
+        el.setAttribute('role', 'option');
+        const label = document.createElement('span');
+        label.textContent = item.label;
+        el.appendChild(label);
+        if (item.hint) {
+            const hint = document.createElement(item.search ? 'span' : 'kbd');
+            hint.className = item.search ? 'palette-hint' : 'kbd';
+            hint.textContent = item.hint;
+            el.appendChild(hint);
+        }
+        el.addEventListener('mousemove', () => {
+            if (paletteIndex !== i) {
+                paletteIndex = i;
+                highlightPalette();
+            }
+        });
+        el.addEventListener('click', () => runPaletteItem(i));
+        list.appendChild(el);
+    });
+    highlightPalette();
+}
+
+function highlightPalette() {
+    document.querySelectorAll('#palette .palette-item').forEach((el, i) => {
+        const active = i === paletteIndex;
+        el.classList.toggle('active', active);
+        el.setAttribute('aria-selected', active);
+        if (active) el.scrollIntoView({ block: 'nearest' });
+    });
+}
+
+function runPaletteItem(i) {
+    const item = paletteItems[i];
+    if (!item) return;
+    closePalette();
+    item.run();
+}
+
 // Close modal on backdrop click
 document.addEventListener('click', e => {
     if (e.target.classList.contains('modal')) closeModal();
@@ -616,20 +1435,20 @@ document.addEventListener('keydown', e => {
 function relativeTime(unixSeconds) {
     const diff = Math.floor(Date.now() / 1000) - unixSeconds;
 
-    if (diff < 0) return 'just now';
-    if (diff < 60) return 'just now';
+    if (diff < 0) return t('just_now');
+    if (diff < 60) return t('just_now');
 
     const minutes = Math.floor(diff / 60);
-    if (minutes < 60) return minutes === 1 ? '1 min ago' : `${minutes} min ago`;
+    if (minutes < 60) return tn('minutes', minutes);
 
     const hours = Math.floor(diff / 3600);
-    if (hours < 24) return hours === 1 ? '1 hr ago' : `${hours} hr ago`;
+    if (hours < 24) return tn('hours', hours);
 
     const days = Math.floor(diff / 86400);
-    if (days < 7) return days === 1 ? '1 day ago' : `${days} days ago`;
+    if (days < 7) return tn('days', days);
 
-    // Older than a week, show the date instead, matching the server format.
-    return new Date(unixSeconds * 1000).toLocaleDateString('en-US', {
+    // Older than a week, show the date instead, in the page's language.
+    return new Date(unixSeconds * 1000).toLocaleDateString(document.documentElement.lang || undefined, {
         month: 'short', day: 'numeric', year: 'numeric'
     });
 }
@@ -645,3 +1464,15 @@ function updateRelativeTimes() {
 
 updateRelativeTimes();
 setInterval(updateRelativeTimes, 30000);
+
+// --- Installable app ----------------------------------------------------
+//
+// Registering the service worker is what lets phones offer "Add to Home
+// Screen" as an app. Browsers only allow it over HTTPS or on localhost, so on
+// a plain http:// LAN address this quietly does nothing, which is fine: the
+// dashboard works exactly the same without it.
+if ('serviceWorker' in navigator && window.isSecureContext) {
+    window.addEventListener('load', () => {
+        navigator.serviceWorker.register('/sw.js').catch(() => {});
+    });
+}
@@ -0,0 +1,78 @@
+// Service worker for the installed app.
+//
+// It exists to make the dashboard installable and to keep the app shell (the
+// stylesheet, script and icon) available when the network drops. Device data
+// is never cached: the API and the pages are always fetched live, because a
+// cached device list would look current while being arbitrarily stale, and
+// would outlive signing out.
+//
+// Served from /sw.js rather than /static/ so that its scope covers the whole
+// site.
+
+const CACHE = 'orangutan-shell-v1';
+const SHELL = [
+    '/static/style.css',
+    '/static/app.js',
+    '/static/orangutan.svg',
+    '/static/manifest.webmanifest',
+];
+
+self.addEventListener('install', event => {
+    event.waitUntil(caches.open(CACHE).then(cache => cache.addAll(SHELL)));
+    self.skipWaiting();
+});
+
+self.addEventListener('activate', event => {
+    // Drop caches left by an older version of this worker.
+    event.waitUntil(
+        caches.keys()
+            .then(keys => Promise.all(keys.filter(k => k !== CACHE).map(k => caches.delete(k))))
+            .then(() => self.clients.claim())
+    );
+});
+
+self.addEventListener('fetch', event => {
+    const req = event.request;
+    if (req.method !== 'GET') return;
+
+    const url = new URL(req.url);
+    if (url.origin !== self.location.origin) return;
+
+    // Static assets: network first so an upgrade is picked up immediately
+    // (the server revalidates them by ETag, which is cheap), falling back to
+    // the cached copy when offline.
+    if (url.pathname.startsWith('/static/')) {
+        event.respondWith(
+            fetch(req)
+                .then(res => {
+                    if (res.ok) {
+                        const copy = res.clone();
+                        caches.open(CACHE).then(cache => cache.put(req, copy));
+                    }
+                    return res;
+                })
+                .catch(() => caches.match(req))
+        );
+        return;
+    }
+
+    // Pages: always live. When that fails, say why rather than showing the
+    // browser's generic error, which on a phone looks like the app crashed.
+    if (req.mode === 'navigate') {
+        event.respondWith(fetch(req).catch(() => offlinePage()));
+    }
+});
+
+function offlinePage() {
+    const html = `<!DOCTYPE html>
+<html lang="en"><head><meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>Offline - LAN Orangutan</title>
+<link rel="stylesheet" href="/static/style.css"></head>
+<body><main class="main"><div class="empty-state">
+<h3>Can't reach LAN Orangutan</h3>
+<p>This device is offline, or not on the network the server is on.</p>
+<p><button class="btn btn-primary" onclick="location.reload()">Try again</button></p>
+</div></main></body></html>`;
+    return new Response(html, { headers: { 'Content-Type': 'text/html; charset=utf-8' } });
+}
@@ -96,3 +96,35 @@ func TestStaticRejectsTraversal(t *testing.T) {
 		}
 	}
 }
+
+func TestStaticManifestContentType(t *testing.T) {
+	h := staticServer(t)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/static/manifest.webmanifest", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	// Browsers refuse to install from a manifest served with the wrong type.
+	if got := rec.Header().Get("Content-Type"); got != "application/manifest+json" {
+		t.Errorf("Content-Type = %q, want application/manifest+json", got)
+	}
+}
+
+func TestServiceWorkerServedFromRoot(t *testing.T) {
+	h, _ := newTestHandler(t, "")
+
+	rec := httptest.NewRecorder()
+	h.ServiceWorker(rec, httptest.NewRequest(http.MethodGet, "/sw.js", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/javascript; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want JavaScript", got)
+	}
+	if rec.Body.Len() == 0 {
+		t.Error("expected the service worker script")
+	}
+}
@@ -0,0 +1,97 @@
+package web
+
+import (
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/i18n"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// minBarWidth keeps a device seen by a single scan visible on the timeline. A
+// sighting with no duration would otherwise be drawn zero pixels wide.
+const minBarWidth = 0.3
+
+// Timeline is a device's sighting history laid out for drawing: positions are
+// percentages of the window, with the oldest time at the left.
+type Timeline struct {
+	Days  int
+	Bars  []TimelineBar
+	Ticks []TimelineTick
+
+	// Coverage is the share of the window the device was seen, in percent.
+	Coverage int
+}
+
+// TimelineBar is one stretch of time the device was seen.
+type TimelineBar struct {
+	Left, Width float64
+	Title       string
+}
+
+// TimelineTick marks the start of a day.
+type TimelineTick struct {
+	Left  float64
+	Label string
+}
+
+// buildTimeline lays out sightings over the days leading up to now.
+//
+// Positions are worked out here rather than in the browser so the page shows
+// the timeline without waiting on a script, and so the labels come from the
+// same catalogs as the rest of the page.
+func buildTimeline(lang string, sightings []types.Sighting, now time.Time, days int) Timeline {
+	start := now.AddDate(0, 0, -days)
+	window := now.Sub(start)
+	pos := func(t time.Time) float64 {
+		return float64(t.Sub(start)) / float64(window) * 100
+	}
+
+	tl := Timeline{Days: days}
+	format := i18n.T(lang, "time.datetime_format")
+	var seen time.Duration
+	for _, sg := range sightings {
+		from, to := sg.Start, sg.End
+		if from.Before(start) {
+			from = start
+		}
+		if to.Before(from) {
+			continue
+		}
+		seen += to.Sub(from)
+
+		width := pos(to) - pos(from)
+		if width < minBarWidth {
+			width = minBarWidth
+		}
+		left := pos(from)
+		if left+width > 100 {
+			left = 100 - width
+		}
+		tl.Bars = append(tl.Bars, TimelineBar{
+			Left:  left,
+			Width: width,
+			Title: i18n.T(lang, "timeline.span", sg.Start.Format(format), sg.End.Format(format)),
+		})
+	}
+	tl.Coverage = int(float64(seen) / float64(window) * 100)
+
+	// A tick a day is readable across a week; across a month it is a comb,
+	// so thin them out to about one a week.
+	step := 1
+	if days > 7 {
+		step = (days + 6) / 7
+	}
+	tickFormat := i18n.T(lang, "timeline.day_format")
+	if step > 1 {
+		tickFormat = i18n.T(lang, "timeline.date_format")
+	}
+	y, m, d := start.Date()
+	midnight := time.Date(y, m, d+1, 0, 0, 0, 0, start.Location())
+	for i := 0; midnight.Before(now); i++ {
+		if i%step == 0 {
+			tl.Ticks = append(tl.Ticks, TimelineTick{Left: pos(midnight), Label: midnight.Format(tickFormat)})
+		}
+		midnight = time.Date(y, m, d+2+i, 0, 0, 0, 0, start.Location())
+	}
+	return tl
+}
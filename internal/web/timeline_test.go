@@ -0,0 +1,60 @@
+package web
+
+import (
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func TestTimelinePlacesSightings(t *testing.T) {
+	now := time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC)
+	sightings := []types.Sighting{
+		// Half of the last day.
+		{Start: now.Add(-12 * time.Hour), End: now},
+	}
+
+	tl := buildTimeline("en", sightings, now, 7)
+
+	if len(tl.Bars) != 1 {
+		t.Fatalf("got %d bars, want 1", len(tl.Bars))
+	}
+	bar := tl.Bars[0]
+	if want := 100 - 100.0/14; bar.Left < want-0.01 || bar.Left > want+0.01 {
+		t.Errorf("bar starts at %.2f%%, want %.2f%%", bar.Left, want)
+	}
+	if want := 100.0 / 14; bar.Width < want-0.01 || bar.Width > want+0.01 {
+		t.Errorf("bar is %.2f%% wide, want %.2f%%", bar.Width, want)
+	}
+	if tl.Coverage != 7 {
+		t.Errorf("coverage = %d%%, want 7%%", tl.Coverage)
+	}
+	if len(tl.Ticks) != 7 {
+		t.Errorf("got %d day ticks across a week, want 7", len(tl.Ticks))
+	}
+}
+
+func TestTimelineClampsToWindow(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	sightings := []types.Sighting{
+		// Began before the window, so only the part inside it is drawn.
+		{Start: now.AddDate(0, 0, -10), End: now.AddDate(0, 0, -6)},
+		// A single scan, which still needs to be visible.
+		{Start: now.Add(-time.Hour), End: now.Add(-time.Hour)},
+	}
+
+	tl := buildTimeline("en", sightings, now, 7)
+
+	if len(tl.Bars) != 2 {
+		t.Fatalf("got %d bars, want 2", len(tl.Bars))
+	}
+	if tl.Bars[0].Left != 0 {
+		t.Errorf("a sighting that began before the window starts at %.2f%%, want 0", tl.Bars[0].Left)
+	}
+	if tl.Bars[1].Width < minBarWidth {
+		t.Errorf("a single sighting is %.2f%% wide, want at least %.2f%%", tl.Bars[1].Width, minBarWidth)
+	}
+	if month := buildTimeline("en", nil, now, 30); len(month.Ticks) > 7 {
+		t.Errorf("got %d ticks across a month, want about one a week", len(month.Ticks))
+	}
+}
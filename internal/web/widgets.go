@@ -0,0 +1,201 @@
+package web
+
+import (
+	"sort"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/cve"
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// widgetWindow is how far back the new devices and recently offline widgets
+// look. A week covers anything that happened since the user last checked in
+// on a weekday.
+const widgetWindow = 7 * 24 * time.Hour
+
+// widgetRows caps the lists in the dashboard widgets, which are summaries;
+// the device table below them has everything.
+const widgetRows = 8
+
+// VendorCount is one line of the vendor breakdown widget. An empty Name
+// stands for every vendor beyond the top few, and Unknown for devices whose
+// manufacturer could not be identified.
+type VendorCount struct {
+	Name    string
+	Unknown bool
+	Count   int
+	Percent int
+}
+
+// VulnerableDevice is one line of the vulnerable devices widget: a device
+// and what its latest deep scan found wrong with it.
+type VulnerableDevice struct {
+	Device *DeviceView
+	cve.Exposure
+}
+
+// ExpiringCert is one line of the expiring certificates widget: a device
+// and a certificate it serves that is about to expire, or has.
+type ExpiringCert struct {
+	Device *DeviceView
+	types.Certificate
+	Expired bool
+	// DaysLeft is how many whole days it has left.
+	DaysLeft int
+}
+
+// newDevices returns the devices first seen within widgetWindow of now,
+// newest first.
+func newDevices(views []*DeviceView, now time.Time) []*DeviceView {
+	var result []*DeviceView
+	for _, dv := range views {
+		if now.Sub(dv.FirstSeen) <= widgetWindow {
+			result = append(result, dv)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].FirstSeen.After(result[j].FirstSeen)
+	})
+	if len(result) > widgetRows {
+		result = result[:widgetRows]
+	}
+	return result
+}
+
+// pendingDevices returns the devices waiting for approval, newest first.
+// Unlike the widgets' lists it is not capped: each of them needs an answer.
+func pendingDevices(views []*DeviceView) []*DeviceView {
+	var result []*DeviceView
+	for _, dv := range views {
+		if dv.Pending {
+			result = append(result, dv)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].FirstSeen.After(result[j].FirstSeen)
+	})
+	return result
+}
+
+// recentlyOffline returns the devices that have dropped off within
+// widgetWindow of now, most recently lost first. Devices gone for longer are
+// left out: they are old news, and on a network with visitors there can be
+// dozens of them.
+func recentlyOffline(views []*DeviceView, now time.Time) []*DeviceView {
+	var result []*DeviceView
+	for _, dv := range views {
+		if !dv.IsOnline() && now.Sub(dv.LastSeen) <= widgetWindow {
+			result = append(result, dv)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].LastSeen.After(result[j].LastSeen)
+	})
+	if len(result) > widgetRows {
+		result = result[:widgetRows]
+	}
+	return result
+}
+
+// vendorBreakdown counts devices by manufacturer, largest first, folding
+// everything past the top few into a single line.
+func vendorBreakdown(views []*DeviceView) []VendorCount {
+	if len(views) == 0 {
+		return nil
+	}
+
+	counts := make(map[string]int)
+	unknown := 0
+	for _, dv := range views {
+		if dv.Vendor == "" || dv.Vendor == "Unknown" {
+			unknown++
+			continue
+		}
+		counts[dv.Vendor]++
+	}
+
+	result := make([]VendorCount, 0, len(counts))
+	for name, n := range counts {
+		result = append(result, VendorCount{Name: name, Count: n})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Name < result[j].Name
+	})
+
+	// Leave room for the "other" and "unknown" lines within widgetRows.
+	if top := widgetRows - 2; len(result) > top {
+		other := VendorCount{}
+		for _, vc := range result[top:] {
+			other.Count += vc.Count
+		}
+		result = append(result[:top], other)
+	}
+	if unknown > 0 {
+		result = append(result, VendorCount{Unknown: true, Count: unknown})
+	}
+
+	for i := range result {
+		result[i].Percent = result[i].Count * 100 / len(views)
+	}
+	return result
+}
+
+// vulnerableDevices returns the devices whose latest deep scan found
+// anything worse than information, worst first.
+func vulnerableDevices(views []*DeviceView, scans map[string]types.DeepScan) []VulnerableDevice {
+	byIP := make(map[string]*DeviceView, len(views))
+	for _, dv := range views {
+		byIP[dv.IP] = dv
+	}
+	var result []VulnerableDevice
+	for _, e := range cve.Exposures(scans) {
+		if dv := byIP[e.IP]; dv != nil {
+			result = append(result, VulnerableDevice{Device: dv, Exposure: e})
+		}
+	}
+	if len(result) > widgetRows {
+		result = result[:widgetRows]
+	}
+	return result
+}
+
+// expiringCerts returns the certificates within warn of expiring at now, or
+// expired, soonest first.
+func expiringCerts(views []*DeviceView, certs map[string][]types.Certificate, warn time.Duration, now time.Time) []ExpiringCert {
+	byIP := make(map[string]*DeviceView, len(views))
+	for _, dv := range views {
+		byIP[dv.IP] = dv
+	}
+	var result []ExpiringCert
+	for ip, list := range certs {
+		dv := byIP[ip]
+		if dv == nil {
+			continue
+		}
+		for _, c := range list {
+			stage := c.Expiry(now, warn)
+			if stage == "" {
+				continue
+			}
+			result = append(result, ExpiringCert{
+				Device:      dv,
+				Certificate: c,
+				Expired:     stage == types.CertExpired,
+				DaysLeft:    int(c.NotAfter.Sub(now) / (24 * time.Hour)),
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].NotAfter.Equal(result[j].NotAfter) {
+			return result[i].NotAfter.Before(result[j].NotAfter)
+		}
+		return ipToLong(result[i].IP) < ipToLong(result[j].IP)
+	})
+	if len(result) > widgetRows {
+		result = result[:widgetRows]
+	}
+	return result
+}
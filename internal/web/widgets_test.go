@@ -0,0 +1,116 @@
+package web
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+func view(ip, vendor string, firstSeen, lastSeen time.Time) *DeviceView {
+	return &DeviceView{
+		Device: &types.Device{IP: ip, FirstSeen: firstSeen, LastSeen: lastSeen},
+		Vendor: vendor,
+	}
+}
+
+func TestNewDevicesNewestFirst(t *testing.T) {
+	now := time.Now()
+	views := []*DeviceView{
+		view("192.168.1.1", "", now.Add(-30*24*time.Hour), now),
+		view("192.168.1.2", "", now.Add(-2*time.Hour), now),
+		view("192.168.1.3", "", now.Add(-time.Hour), now),
+	}
+
+	got := newDevices(views, now)
+	if len(got) != 2 || got[0].IP != "192.168.1.3" || got[1].IP != "192.168.1.2" {
+		t.Errorf("newDevices = %v, want .3 then .2", ips(got))
+	}
+}
+
+func TestPendingDevicesAreAllListed(t *testing.T) {
+	now := time.Now()
+	var views []*DeviceView
+	for i := range widgetRows + 2 {
+		v := view(fmt.Sprintf("192.168.1.%d", i+10), "", now.Add(-time.Duration(i)*time.Hour), now)
+		v.Pending = true
+		views = append(views, v)
+	}
+	views = append(views, view("192.168.1.1", "", now, now))
+
+	got := pendingDevices(views)
+	if len(got) != widgetRows+2 || got[0].IP != "192.168.1.10" {
+		t.Errorf("pendingDevices = %v, want every pending device, .10 first", ips(got))
+	}
+}
+
+func TestRecentlyOfflineLeavesOutOldNews(t *testing.T) {
+	now := time.Now()
+	views := []*DeviceView{
+		view("192.168.1.1", "", now, now),                       // online
+		view("192.168.1.2", "", now, now.Add(-3*time.Hour)),     // dropped off today
+		view("192.168.1.3", "", now, now.Add(-20*24*time.Hour)), // long gone
+		view("192.168.1.4", "", now, now.Add(-2*24*time.Hour)),  // dropped off this week
+	}
+
+	got := recentlyOffline(views, now)
+	if len(got) != 2 || got[0].IP != "192.168.1.2" || got[1].IP != "192.168.1.4" {
+		t.Errorf("recentlyOffline = %v, want .2 then .4", ips(got))
+	}
+}
+
+func TestVendorBreakdownFoldsTheLongTail(t *testing.T) {
+	now := time.Now()
+	var views []*DeviceView
+	for i := 0; i < 3; i++ {
+		views = append(views, view(fmt.Sprintf("10.0.0.%d", i), "Apple", now, now))
+	}
+	for i := 0; i < 10; i++ {
+		views = append(views, view(fmt.Sprintf("10.0.1.%d", i), fmt.Sprintf("Vendor %02d", i), now, now))
+	}
+	views = append(views, view("10.0.2.1", "Unknown", now, now))
+
+	got := vendorBreakdown(views)
+	if len(got) != widgetRows {
+		t.Fatalf("got %d lines, want %d", len(got), widgetRows)
+	}
+	if got[0].Name != "Apple" || got[0].Count != 3 {
+		t.Errorf("first line = %+v, want Apple with 3", got[0])
+	}
+	other := got[len(got)-2]
+	if other.Name != "" || other.Unknown || other.Count != 5 {
+		t.Errorf("second to last line = %+v, want the other 5 vendors folded together", other)
+	}
+	if last := got[len(got)-1]; !last.Unknown || last.Count != 1 {
+		t.Errorf("last line = %+v, want the one unknown vendor", last)
+	}
+}
+
+func TestExpiringCertsSoonestFirst(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+	views := []*DeviceView{view("192.168.1.5", "", now, now), view("192.168.1.6", "", now, now)}
+	certs := map[string][]types.Certificate{
+		"192.168.1.5": {
+			{IP: "192.168.1.5", Port: 443, NotAfter: now.Add(10*day + time.Hour)},
+			{IP: "192.168.1.5", Port: 8006, NotAfter: now.Add(200 * day)},
+		},
+		"192.168.1.6": {{IP: "192.168.1.6", Port: 443, NotAfter: now.Add(-day)}},
+		// Deleted since it was checked.
+		"192.168.1.7": {{IP: "192.168.1.7", Port: 443, NotAfter: now}},
+	}
+
+	got := expiringCerts(views, certs, 14*day, now)
+	if len(got) != 2 || got[0].IP != "192.168.1.6" || !got[0].Expired || got[1].Port != 443 || got[1].DaysLeft != 10 {
+		t.Errorf("expiringCerts = %+v, want the expired one, then the one with 10 days left", got)
+	}
+}
+
+func ips(views []*DeviceView) []string {
+	var out []string
+	for _, v := range views {
+		out = append(out, v.IP)
+	}
+	return out
+}
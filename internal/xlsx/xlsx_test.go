@@ -0,0 +1,90 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	rows := [][]string{
+		{"IP Address", "Notes"},
+		{"192.168.1.5", `NAS <"main"> & backups`},
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, "Devices", rows); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("workbook is not a zip: %v", err)
+	}
+	parts := map[string]string{}
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[f.Name] = string(data)
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		content, ok := parts[name]
+		if !ok {
+			t.Errorf("workbook lacks %s", name)
+			continue
+		}
+		if err := xml.Unmarshal([]byte(content), new(struct{})); err != nil {
+			t.Errorf("%s is not well-formed XML: %v", name, err)
+		}
+	}
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	var ws struct {
+		Rows []struct {
+			Cells []struct {
+				Ref   string `xml:"r,attr"`
+				Style string `xml:"s,attr"`
+				Text  string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := xml.Unmarshal([]byte(sheet), &ws); err != nil {
+		t.Fatalf("sheet: %v", err)
+	}
+	if len(ws.Rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(ws.Rows))
+	}
+	if c := ws.Rows[0].Cells[0]; c.Text != "IP Address" || c.Style != "1" {
+		t.Errorf("header cell = %+v, want bold IP Address", c)
+	}
+	if c := ws.Rows[1].Cells[1]; c.Ref != "B2" || c.Text != `NAS <"main"> & backups` {
+		t.Errorf("notes cell = %+v", c)
+	}
+	if !strings.Contains(parts["xl/workbook.xml"], `name="Devices"`) {
+		t.Errorf("workbook does not name the sheet Devices:\n%s", parts["xl/workbook.xml"])
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for c, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(c); got != want {
+			t.Errorf("columnName(%d) = %q, want %q", c, got, want)
+		}
+	}
+}
+
+func TestInvalidSheetName(t *testing.T) {
+	if err := Write(io.Discard, "a/b", nil); err == nil {
+		t.Error("Write accepted the sheet name a/b")
+	}
+}
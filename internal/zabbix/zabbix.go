@@ -0,0 +1,375 @@
+// Package zabbix sends the outcome of each scan to a Zabbix server with the
+// sender protocol, as zabbix_sender does: whether each device of the network
+// was up and how quickly it answered, and how long the scan took, for shops
+// whose monitoring is Zabbix.
+//
+// The values go to trapper items. Without registration they are all on one
+// host standing for LAN Orangutan, with a low-level discovery rule creating
+// the items of each device. With it, each device becomes a host of its own,
+// registered the way an active agent registers itself, so that Zabbix's
+// auto-registration actions can link it to a template.
+package zabbix
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// sendTimeout bounds a conversation with the server, so that one that has
+// gone away does not hold up the next scan.
+const sendTimeout = 10 * time.Second
+
+// DefaultPort is the port of the Zabbix server's trapper.
+const DefaultPort = "10051"
+
+// Keys of the items the values are sent to. On the LAN Orangutan host the
+// device items take the device's address as their parameter, and the scan
+// items the network's.
+const (
+	DiscoveryKey    = "lan_orangutan.discovery"
+	UpKey           = "lan_orangutan.up"
+	ResponseKey     = "lan_orangutan.response_ms"
+	ScanDevicesKey  = "lan_orangutan.scan.devices"
+	ScanDurationKey = "lan_orangutan.scan.duration"
+)
+
+// maxMetadata is the longest host metadata Zabbix keeps.
+const maxMetadata = 255
+
+// Options say where to send.
+type Options struct {
+	// Server is the Zabbix server or proxy, as host or host:port.
+	Server string
+	// Host is the name of the Zabbix host standing for LAN Orangutan.
+	Host string
+	// Register makes each device a host of its own, registered with
+	// Metadata as its host metadata.
+	Register bool
+	Metadata string
+}
+
+// Client sends scans to Zabbix.
+type Client struct {
+	opts Options
+
+	// mu guards registered, the host names the server has said it knows,
+	// which are not registered again.
+	mu         sync.Mutex
+	registered map[string]bool
+}
+
+// New returns a client sending with opts.
+func New(opts Options) *Client {
+	return &Client{opts: opts, registered: make(map[string]bool)}
+}
+
+// ValidServer checks that server is a host, or a host and port, Zabbix can
+// be reached at.
+func ValidServer(server string) error {
+	host, port, err := net.SplitHostPort(Address(server))
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		return fmt.Errorf("%q has no host", server)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%q has no valid port", server)
+	}
+	return nil
+}
+
+// Address returns server with DefaultPort added when it has no port.
+func Address(server string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(strings.Trim(server, "[]"), DefaultPort)
+}
+
+// hostNameChars are the characters Zabbix allows in host names.
+var hostNameChars = regexp.MustCompile(`[^A-Za-z0-9 ._-]`)
+
+// HostName is the name of a device's host when devices are registered: its
+// MAC address, which stays the same when its address or label changes, or
+// its IP address when the MAC is not known.
+func HostName(d *types.Device) string {
+	name := d.IP
+	if d.MAC != "" {
+		name = strings.ToLower(d.MAC)
+	}
+	return hostNameChars.ReplaceAllString(strings.ReplaceAll(name, ":", "-"), "_")
+}
+
+// item is one value of the sender protocol.
+type item struct {
+	Host  string `json:"host"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Clock int64  `json:"clock"`
+}
+
+// SendScan sends the values for a scan of the network cidr that found
+// result's devices, out of all the devices known, registering the devices
+// found first when the options ask for it.
+func (c *Client) SendScan(ctx context.Context, cidr string, result *types.ScanResult, known map[string]*types.Device, now time.Time) error {
+	devices, up := inNetwork(cidr, result, known)
+	if c.opts.Register {
+		for _, d := range devices {
+			if !up[d.IP] {
+				continue
+			}
+			if err := c.register(ctx, d); err != nil {
+				return fmt.Errorf("registering %s: %w", d.IP, err)
+			}
+		}
+	}
+	return c.send(ctx, c.items(cidr, result, devices, up, now))
+}
+
+// items returns the values for a scan of cidr: for each of devices whether
+// it was up and, if it was, its response time, and for the scan the devices
+// found and how long it took. Without registration they come after the
+// discovery of the devices.
+func (c *Client) items(cidr string, result *types.ScanResult, devices []*types.Device, up map[string]bool, now time.Time) []item {
+	clock := now.Unix()
+	var items []item
+	add := func(host, key, value string) {
+		items = append(items, item{Host: host, Key: key, Value: value, Clock: clock})
+	}
+
+	if !c.opts.Register {
+		var discovery []map[string]string
+		for _, d := range devices {
+			discovery = append(discovery, map[string]string{
+				"{#IP}": d.IP, "{#MAC}": d.MAC, "{#NAME}": name(d), "{#GROUP}": d.Group, "{#TYPE}": d.Type,
+			})
+		}
+		data, _ := json.Marshal(discovery)
+		if discovery == nil {
+			data = []byte("[]")
+		}
+		add(c.opts.Host, DiscoveryKey, string(data))
+	}
+	for _, d := range devices {
+		host, upKey, responseKey := c.opts.Host, UpKey+"["+d.IP+"]", ResponseKey+"["+d.IP+"]"
+		if c.opts.Register {
+			host, upKey, responseKey = HostName(d), UpKey, ResponseKey
+		}
+		if !up[d.IP] {
+			add(host, upKey, "0")
+			continue
+		}
+		add(host, upKey, "1")
+		if d.ResponseTime != nil {
+			add(host, responseKey, strconv.FormatFloat(*d.ResponseTime, 'f', -1, 64))
+		}
+	}
+	add(c.opts.Host, ScanDevicesKey+"["+cidr+"]", strconv.Itoa(result.DeviceCount))
+	add(c.opts.Host, ScanDurationKey+"["+cidr+"]", strconv.FormatFloat(result.Duration, 'f', -1, 64))
+	return items
+}
+
+// inNetwork returns the devices the scan found and the known devices in the
+// network cidr it did not, in address order, and which were found.
+func inNetwork(cidr string, result *types.ScanResult, known map[string]*types.Device) ([]*types.Device, map[string]bool) {
+	_, network, _ := net.ParseCIDR(cidr)
+	up := make(map[string]bool, len(result.Devices))
+	var devices []*types.Device
+	for i := range result.Devices {
+		d := result.Devices[i]
+		if k := known[d.IP]; k != nil {
+			// The inventory has the label and group the scan does not.
+			responseTime := d.ResponseTime
+			d = *k
+			d.ResponseTime = responseTime
+		}
+		up[d.IP] = true
+		devices = append(devices, &d)
+	}
+	for ip, d := range known {
+		if !up[ip] && network != nil && network.Contains(net.ParseIP(ip)) {
+			devices = append(devices, d)
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].IP < devices[j].IP })
+	return devices, up
+}
+
+// name is how a device is named in discovery: its label if it has one, then
+// its hostname, then its address.
+func name(d *types.Device) string {
+	switch {
+	case d.Label != "":
+		return d.Label
+	case d.Hostname != "":
+		return d.Hostname
+	default:
+		return d.IP
+	}
+}
+
+// Metadata is the host metadata a device registers with: the configured
+// metadata, then its type and group, so that auto-registration actions can
+// tell printers from cameras.
+func Metadata(metadata string, d *types.Device) string {
+	parts := []string{metadata}
+	if d.Type != "" {
+		parts = append(parts, "type:"+d.Type)
+	}
+	if d.Group != "" {
+		parts = append(parts, "group:"+strings.ReplaceAll(d.Group, " ", "_"))
+	}
+	s := strings.TrimSpace(strings.Join(parts, " "))
+	if len(s) > maxMetadata {
+		s = s[:maxMetadata]
+	}
+	return s
+}
+
+// register asks the server for the active checks of the device's host, as
+// an agent starting up does. For a host it does not know, that runs its
+// auto-registration actions. Once the server knows the host it is not asked
+// again.
+func (c *Client) register(ctx context.Context, d *types.Device) error {
+	host := HostName(d)
+	c.mu.Lock()
+	done := c.registered[host]
+	c.mu.Unlock()
+	if done {
+		return nil
+	}
+
+	var reply struct {
+		Response string `json:"response"`
+	}
+	err := c.call(ctx, map[string]string{
+		"request":       "active checks",
+		"host":          host,
+		"host_metadata": Metadata(c.opts.Metadata, d),
+		"ip":            d.IP,
+	}, &reply)
+	if err != nil {
+		return err
+	}
+	// The server says it failed while the host is still being created;
+	// it is asked again after the next scan.
+	if reply.Response == "success" {
+		c.mu.Lock()
+		c.registered[host] = true
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// send sends items to the server's trappers. Values the server has no
+// item for are dropped by it; only when it takes none of them is that an
+// error, as discovery creates the items of new devices a little after
+// their first values.
+func (c *Client) send(ctx context.Context, items []item) error {
+	var reply struct {
+		Response string `json:"response"`
+		Info     string `json:"info"`
+	}
+	err := c.call(ctx, map[string]any{
+		"request": "sender data",
+		"data":    items,
+	}, &reply)
+	if err != nil {
+		return err
+	}
+	if reply.Response != "success" {
+		return fmt.Errorf("Zabbix refused the values: %s", reply.Info)
+	}
+	var processed, failed, total int
+	fmt.Sscanf(reply.Info, "processed: %d; failed: %d; total: %d", &processed, &failed, &total)
+	if processed == 0 && failed > 0 {
+		return fmt.Errorf("Zabbix took none of the %d values; check that the host %q exists with the items", total, c.opts.Host)
+	}
+	return nil
+}
+
+// header starts every message of the protocol, followed by a flags byte.
+var header = []byte("ZBXD")
+
+// flagProtocol is the flag of a message without compression.
+const flagProtocol = 0x01
+
+// call sends request to the server and decodes its answer into reply.
+func (c *Client) call(ctx context.Context, request, reply any) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", Address(c.opts.Server))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(frame(data)); err != nil {
+		return err
+	}
+	answer, err := readFrame(conn)
+	if err != nil {
+		return fmt.Errorf("reading the answer: %w", err)
+	}
+	return json.Unmarshal(answer, reply)
+}
+
+// frame wraps data in the header, flags and length of the protocol.
+func frame(data []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(header)
+	buf.WriteByte(flagProtocol)
+	binary.Write(&buf, binary.LittleEndian, uint32(len(data)))
+	binary.Write(&buf, binary.LittleEndian, uint32(0))
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+// maxAnswer bounds the answers read, which are short.
+const maxAnswer = 1 << 20
+
+// readFrame reads one message and returns its data.
+func readFrame(r io.Reader) ([]byte, error) {
+	head := make([]byte, len(header)+1+8)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(head[:len(header)], header) {
+		return nil, errors.New("not an answer from Zabbix")
+	}
+	if head[len(header)]&^flagProtocol != 0 {
+		return nil, errors.New("the answer is compressed or too large")
+	}
+	size := binary.LittleEndian.Uint32(head[len(header)+1:])
+	if size > maxAnswer {
+		return nil, errors.New("the answer is too large")
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
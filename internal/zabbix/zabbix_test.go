@@ -0,0 +1,196 @@
+package zabbix
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/291-Group/LAN-Orangutan/internal/types"
+)
+
+// fakeServer answers the sender protocol like a Zabbix server that knows
+// the hosts in hosts and has an item for every value sent to them, and
+// returns the requests it was sent.
+func fakeServer(t *testing.T, hosts ...string) (string, func() []map[string]any) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var mu sync.Mutex
+	var requests []map[string]any
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			data, err := readFrame(conn)
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			var req map[string]any
+			json.Unmarshal(data, &req)
+			mu.Lock()
+			requests = append(requests, req)
+			mu.Unlock()
+
+			var reply any
+			switch req["request"] {
+			case "active checks":
+				reply = map[string]any{"response": "failed", "info": "host not found"}
+				for _, h := range hosts {
+					if h == req["host"] {
+						reply = map[string]any{"response": "success", "data": []any{}}
+					}
+				}
+			case "sender data":
+				processed, failed := 0, 0
+				for _, it := range req["data"].([]any) {
+					known := false
+					for _, h := range hosts {
+						known = known || h == it.(map[string]any)["host"]
+					}
+					if known {
+						processed++
+					} else {
+						failed++
+					}
+				}
+				reply = map[string]any{"response": "success",
+					"info": "processed: " + strconv.Itoa(processed) + "; failed: " + strconv.Itoa(failed) + "; total: " + strconv.Itoa(processed+failed) + "; seconds spent: 0.000055"}
+			}
+			answer, _ := json.Marshal(reply)
+			conn.Write(frame(answer))
+			conn.Close()
+		}
+	}()
+	return ln.Addr().String(), func() []map[string]any {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]map[string]any(nil), requests...)
+	}
+}
+
+func scanOf() (*types.ScanResult, map[string]*types.Device) {
+	rtt := 2.5
+	result := &types.ScanResult{
+		Devices:     []types.Device{{IP: "192.168.1.5", MAC: "AA:BB:CC:00:00:05", ResponseTime: &rtt}},
+		DeviceCount: 1,
+		Duration:    3.5,
+	}
+	known := map[string]*types.Device{
+		"192.168.1.5": {IP: "192.168.1.5", MAC: "AA:BB:CC:00:00:05", Label: "Phone", Type: "phone", Group: "Living room"},
+		"192.168.1.6": {IP: "192.168.1.6", Hostname: "printer.lan"},
+		"10.0.0.1":    {IP: "10.0.0.1"},
+	}
+	return result, known
+}
+
+// values returns the values sent by a sender data request, keyed by host
+// and key.
+func values(req map[string]any) map[string]string {
+	got := make(map[string]string)
+	for _, it := range req["data"].([]any) {
+		v := it.(map[string]any)
+		got[v["host"].(string)+" "+v["key"].(string)] = v["value"].(string)
+	}
+	return got
+}
+
+func TestSendScan(t *testing.T) {
+	addr, requests := fakeServer(t, "LAN Orangutan")
+	result, known := scanOf()
+	c := New(Options{Server: addr, Host: "LAN Orangutan"})
+	if err := c.SendScan(context.Background(), "192.168.1.0/24", result, known, time.Unix(1700000000, 0)); err != nil {
+		t.Fatalf("SendScan: %v", err)
+	}
+
+	reqs := requests()
+	if len(reqs) != 1 || reqs[0]["request"] != "sender data" {
+		t.Fatalf("requests = %v", reqs)
+	}
+	got := values(reqs[0])
+	want := map[string]string{
+		"LAN Orangutan lan_orangutan.up[192.168.1.5]":               "1",
+		"LAN Orangutan lan_orangutan.response_ms[192.168.1.5]":      "2.5",
+		"LAN Orangutan lan_orangutan.up[192.168.1.6]":               "0",
+		"LAN Orangutan lan_orangutan.scan.devices[192.168.1.0/24]":  "1",
+		"LAN Orangutan lan_orangutan.scan.duration[192.168.1.0/24]": "3.5",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+	if _, ok := got["LAN Orangutan lan_orangutan.up[10.0.0.1]"]; ok {
+		t.Error("a device outside the network was sent")
+	}
+
+	var discovery []map[string]string
+	if err := json.Unmarshal([]byte(got["LAN Orangutan lan_orangutan.discovery"]), &discovery); err != nil {
+		t.Fatalf("discovery: %v", err)
+	}
+	if len(discovery) != 2 || discovery[0]["{#NAME}"] != "Phone" || discovery[1]["{#NAME}"] != "printer.lan" {
+		t.Errorf("discovery = %v", discovery)
+	}
+}
+
+func TestSendScanToUnknownHost(t *testing.T) {
+	addr, _ := fakeServer(t)
+	result, known := scanOf()
+	err := New(Options{Server: addr, Host: "LAN Orangutan"}).SendScan(context.Background(), "192.168.1.0/24", result, known, time.Now())
+	if err == nil || !strings.Contains(err.Error(), `"LAN Orangutan"`) {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	addr, requests := fakeServer(t, "LAN Orangutan", "aa-bb-cc-00-00-05")
+	result, known := scanOf()
+	c := New(Options{Server: addr, Host: "LAN Orangutan", Register: true, Metadata: "lan-orangutan"})
+	for i := 0; i < 2; i++ {
+		if err := c.SendScan(context.Background(), "192.168.1.0/24", result, known, time.Now()); err != nil {
+			t.Fatalf("SendScan: %v", err)
+		}
+	}
+
+	reqs := requests()
+	// Registered once, as the server knows the host after that.
+	if len(reqs) != 3 || reqs[0]["request"] != "active checks" || reqs[1]["request"] != "sender data" {
+		t.Fatalf("requests = %v", reqs)
+	}
+	if reqs[0]["host"] != "aa-bb-cc-00-00-05" || reqs[0]["ip"] != "192.168.1.5" ||
+		reqs[0]["host_metadata"] != "lan-orangutan type:phone group:Living_room" {
+		t.Errorf("registration = %v", reqs[0])
+	}
+	got := values(reqs[1])
+	if got["aa-bb-cc-00-00-05 lan_orangutan.up"] != "1" || got["192.168.1.6 lan_orangutan.up"] != "0" {
+		t.Errorf("values = %v", got)
+	}
+	if _, ok := got["LAN Orangutan lan_orangutan.discovery"]; ok {
+		t.Error("discovery was sent with registration")
+	}
+}
+
+func TestValidServer(t *testing.T) {
+	for server, ok := range map[string]bool{
+		"zabbix.lan": true, "zabbix.lan:10051": true, "10.0.0.5": true, "[fd00::5]:10051": true, "fd00::5": true,
+		"": false, "zabbix.lan:port": false, ":10051": false,
+	} {
+		if err := ValidServer(server); (err == nil) != ok {
+			t.Errorf("ValidServer(%q) = %v", server, err)
+		}
+	}
+	if got := Address("zabbix.lan"); got != "zabbix.lan:10051" {
+		t.Errorf("Address = %q", got)
+	}
+}
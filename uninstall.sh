@@ -34,10 +34,15 @@ read -r -p "Uninstall LAN Orangutan? [y/N]: " confirm
 [[ ! "$confirm" =~ ^[Yy]$ ]] && { echo "Aborted."; exit 0; }
 
 # Stop service
+systemctl disable --now "$SERVICE_NAME-scan.timer" 2>/dev/null || true
 systemctl stop "$SERVICE_NAME" 2>/dev/null || true
 systemctl disable "$SERVICE_NAME" 2>/dev/null || true
-rm -f /etc/systemd/system/lan-orangutan.service
+rm -f /etc/systemd/system/lan-orangutan.service \
+      /etc/systemd/system/lan-orangutan-scan.service \
+      /etc/systemd/system/lan-orangutan-scan.timer
 systemctl daemon-reload
+# The user install-service created to run the service
+id lan-orangutan &>/dev/null && userdel lan-orangutan 2>/dev/null || true
 echo -e "${GREEN}✓${NC} Service removed"
 
 # Remove files